@@ -0,0 +1,286 @@
+//go:build detsched
+
+package atomic
+
+import (
+	"sync/atomic"
+
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// Bool is an atomic boolean value. Each of its operations is a scheduling
+// point. The zero value is false.
+type Bool struct {
+	v atomic.Bool
+}
+
+// Load atomically loads and returns the value stored in x.
+func (x *Bool) Load() bool {
+	scheduler.Checkpoint()
+	return x.v.Load()
+}
+
+// Store atomically stores val into x.
+func (x *Bool) Store(val bool) {
+	scheduler.Checkpoint()
+	x.v.Store(val)
+}
+
+// Swap atomically stores new into x and returns the previous value.
+func (x *Bool) Swap(new bool) (old bool) {
+	scheduler.Checkpoint()
+	return x.v.Swap(new)
+}
+
+// CompareAndSwap executes the compare-and-swap operation for the boolean
+// value x.
+func (x *Bool) CompareAndSwap(old, new bool) (swapped bool) {
+	scheduler.Checkpoint()
+	return x.v.CompareAndSwap(old, new)
+}
+
+// Int32 is an atomic int32. Each of its operations is a scheduling point.
+// The zero value is zero.
+type Int32 struct {
+	v atomic.Int32
+}
+
+// Load atomically loads and returns the value stored in x.
+func (x *Int32) Load() int32 {
+	scheduler.Checkpoint()
+	return x.v.Load()
+}
+
+// Store atomically stores val into x.
+func (x *Int32) Store(val int32) {
+	scheduler.Checkpoint()
+	x.v.Store(val)
+}
+
+// Swap atomically stores new into x and returns the previous value.
+func (x *Int32) Swap(new int32) (old int32) {
+	scheduler.Checkpoint()
+	return x.v.Swap(new)
+}
+
+// CompareAndSwap executes the compare-and-swap operation for x.
+func (x *Int32) CompareAndSwap(old, new int32) (swapped bool) {
+	scheduler.Checkpoint()
+	return x.v.CompareAndSwap(old, new)
+}
+
+// Add atomically adds delta to x and returns the new value.
+func (x *Int32) Add(delta int32) (new int32) {
+	scheduler.Checkpoint()
+	return x.v.Add(delta)
+}
+
+// Int64 is an atomic int64. Each of its operations is a scheduling point.
+// The zero value is zero.
+type Int64 struct {
+	v atomic.Int64
+}
+
+// Load atomically loads and returns the value stored in x.
+func (x *Int64) Load() int64 {
+	scheduler.Checkpoint()
+	return x.v.Load()
+}
+
+// Store atomically stores val into x.
+func (x *Int64) Store(val int64) {
+	scheduler.Checkpoint()
+	x.v.Store(val)
+}
+
+// Swap atomically stores new into x and returns the previous value.
+func (x *Int64) Swap(new int64) (old int64) {
+	scheduler.Checkpoint()
+	return x.v.Swap(new)
+}
+
+// CompareAndSwap executes the compare-and-swap operation for x.
+func (x *Int64) CompareAndSwap(old, new int64) (swapped bool) {
+	scheduler.Checkpoint()
+	return x.v.CompareAndSwap(old, new)
+}
+
+// Add atomically adds delta to x and returns the new value.
+func (x *Int64) Add(delta int64) (new int64) {
+	scheduler.Checkpoint()
+	return x.v.Add(delta)
+}
+
+// Uint32 is an atomic uint32. Each of its operations is a scheduling point.
+// The zero value is zero.
+type Uint32 struct {
+	v atomic.Uint32
+}
+
+// Load atomically loads and returns the value stored in x.
+func (x *Uint32) Load() uint32 {
+	scheduler.Checkpoint()
+	return x.v.Load()
+}
+
+// Store atomically stores val into x.
+func (x *Uint32) Store(val uint32) {
+	scheduler.Checkpoint()
+	x.v.Store(val)
+}
+
+// Swap atomically stores new into x and returns the previous value.
+func (x *Uint32) Swap(new uint32) (old uint32) {
+	scheduler.Checkpoint()
+	return x.v.Swap(new)
+}
+
+// CompareAndSwap executes the compare-and-swap operation for x.
+func (x *Uint32) CompareAndSwap(old, new uint32) (swapped bool) {
+	scheduler.Checkpoint()
+	return x.v.CompareAndSwap(old, new)
+}
+
+// Add atomically adds delta to x and returns the new value.
+func (x *Uint32) Add(delta uint32) (new uint32) {
+	scheduler.Checkpoint()
+	return x.v.Add(delta)
+}
+
+// Uint64 is an atomic uint64. Each of its operations is a scheduling point.
+// The zero value is zero.
+type Uint64 struct {
+	v atomic.Uint64
+}
+
+// Load atomically loads and returns the value stored in x.
+func (x *Uint64) Load() uint64 {
+	scheduler.Checkpoint()
+	return x.v.Load()
+}
+
+// Store atomically stores val into x.
+func (x *Uint64) Store(val uint64) {
+	scheduler.Checkpoint()
+	x.v.Store(val)
+}
+
+// Swap atomically stores new into x and returns the previous value.
+func (x *Uint64) Swap(new uint64) (old uint64) {
+	scheduler.Checkpoint()
+	return x.v.Swap(new)
+}
+
+// CompareAndSwap executes the compare-and-swap operation for x.
+func (x *Uint64) CompareAndSwap(old, new uint64) (swapped bool) {
+	scheduler.Checkpoint()
+	return x.v.CompareAndSwap(old, new)
+}
+
+// Add atomically adds delta to x and returns the new value.
+func (x *Uint64) Add(delta uint64) (new uint64) {
+	scheduler.Checkpoint()
+	return x.v.Add(delta)
+}
+
+// Uintptr is an atomic uintptr. Each of its operations is a scheduling point.
+// The zero value is zero.
+type Uintptr struct {
+	v atomic.Uintptr
+}
+
+// Load atomically loads and returns the value stored in x.
+func (x *Uintptr) Load() uintptr {
+	scheduler.Checkpoint()
+	return x.v.Load()
+}
+
+// Store atomically stores val into x.
+func (x *Uintptr) Store(val uintptr) {
+	scheduler.Checkpoint()
+	x.v.Store(val)
+}
+
+// Swap atomically stores new into x and returns the previous value.
+func (x *Uintptr) Swap(new uintptr) (old uintptr) {
+	scheduler.Checkpoint()
+	return x.v.Swap(new)
+}
+
+// CompareAndSwap executes the compare-and-swap operation for x.
+func (x *Uintptr) CompareAndSwap(old, new uintptr) (swapped bool) {
+	scheduler.Checkpoint()
+	return x.v.CompareAndSwap(old, new)
+}
+
+// Add atomically adds delta to x and returns the new value.
+func (x *Uintptr) Add(delta uintptr) (new uintptr) {
+	scheduler.Checkpoint()
+	return x.v.Add(delta)
+}
+
+// Pointer is an atomic pointer of type *T. Each of its operations is a
+// scheduling point. The zero value is a nil *T.
+type Pointer[T any] struct {
+	v atomic.Pointer[T]
+}
+
+// Load atomically loads and returns the value stored in x.
+func (x *Pointer[T]) Load() *T {
+	scheduler.Checkpoint()
+	return x.v.Load()
+}
+
+// Store atomically stores val into x.
+func (x *Pointer[T]) Store(val *T) {
+	scheduler.Checkpoint()
+	x.v.Store(val)
+}
+
+// Swap atomically stores new into x and returns the previous value.
+func (x *Pointer[T]) Swap(new *T) (old *T) {
+	scheduler.Checkpoint()
+	return x.v.Swap(new)
+}
+
+// CompareAndSwap executes the compare-and-swap operation for x.
+func (x *Pointer[T]) CompareAndSwap(old, new *T) (swapped bool) {
+	scheduler.Checkpoint()
+	return x.v.CompareAndSwap(old, new)
+}
+
+// Value provides an atomic load and store of a consistently typed value,
+// as a sync/atomic Value does. Each of its operations is a scheduling
+// point. The zero value returns nil from Load.
+type Value struct {
+	v atomic.Value
+}
+
+// Load returns the value set by the most recent Store. It returns nil if
+// there has been no call to Store for this Value.
+func (v *Value) Load() (val any) {
+	scheduler.Checkpoint()
+	return v.v.Load()
+}
+
+// Store sets the value of the Value v to val. All calls to Store for a
+// given Value must use values of the same concrete type. Store of an
+// inconsistent type panics, as does Store(nil).
+func (v *Value) Store(val any) {
+	scheduler.Checkpoint()
+	v.v.Store(val)
+}
+
+// Swap stores new into Value and returns the previous value. It returns
+// nil if the Value is empty. It panics as Store does.
+func (v *Value) Swap(new any) (old any) {
+	scheduler.Checkpoint()
+	return v.v.Swap(new)
+}
+
+// CompareAndSwap executes the compare-and-swap operation for the Value.
+// It panics as Store does.
+func (v *Value) CompareAndSwap(old, new any) (swapped bool) {
+	scheduler.Checkpoint()
+	return v.v.CompareAndSwap(old, new)
+}
@@ -0,0 +1,34 @@
+//go:build detsched
+
+package atomic
+
+import (
+	"testing"
+
+	"github.com/mziter/weft"
+)
+
+// TestLostUpdate verifies that the scheduler interleaves tasks between an
+// atomic load and the store that depends on it, so that some seeds lose an
+// increment made that way and others do not.
+func TestLostUpdate(t *testing.T) {
+	lost, kept := 0, 0
+	for seed := uint64(1); seed <= 50; seed++ {
+		s := weft.NewScheduler(seed)
+		var n Int64
+		for range 2 {
+			s.Go(func(weft.Context) {
+				n.Store(n.Load() + 1)
+			})
+		}
+		s.Wait()
+		if n.Load() == 2 {
+			kept++
+		} else {
+			lost++
+		}
+	}
+	if lost == 0 || kept == 0 {
+		t.Errorf("50 seeds lost the update %d times and kept it %d times, want both", lost, kept)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !detsched
+
+package atomic
+
+import "sync/atomic"
+
+// Bool is a standard atomic.Bool in production mode.
+type Bool = atomic.Bool
+
+// Int32 is a standard atomic.Int32 in production mode.
+type Int32 = atomic.Int32
+
+// Int64 is a standard atomic.Int64 in production mode.
+type Int64 = atomic.Int64
+
+// Uint32 is a standard atomic.Uint32 in production mode.
+type Uint32 = atomic.Uint32
+
+// Uint64 is a standard atomic.Uint64 in production mode.
+type Uint64 = atomic.Uint64
+
+// Uintptr is a standard atomic.Uintptr in production mode.
+type Uintptr = atomic.Uintptr
+
+// Pointer is a standard atomic.Pointer in production mode. It embeds one,
+// as a generic alias needs Go 1.23.
+type Pointer[T any] struct {
+	atomic.Pointer[T]
+}
+
+// Value is a standard atomic.Value in production mode.
+type Value = atomic.Value
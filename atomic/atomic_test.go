@@ -0,0 +1,70 @@
+package atomic
+
+import (
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/wefttest"
+)
+
+// TestOperations verifies that the types load, store, swap, add and
+// compare-and-swap as those of sync/atomic do, in both build modes.
+func TestOperations(t *testing.T) {
+	var b Bool
+	if b.Swap(true) || !b.Load() || b.CompareAndSwap(false, true) || !b.CompareAndSwap(true, false) {
+		t.Error("Bool operations disagree with sync/atomic")
+	}
+	var n Int64
+	n.Store(40)
+	if got := n.Add(2); got != 42 {
+		t.Errorf("Add(2) = %d, want 42", got)
+	}
+	if old := n.Swap(7); old != 42 || n.Load() != 7 {
+		t.Errorf("Swap(7) = %d leaving %d, want 42 leaving 7", old, n.Load())
+	}
+	if n.CompareAndSwap(42, 0) || !n.CompareAndSwap(7, 0) || n.Load() != 0 {
+		t.Error("Int64.CompareAndSwap disagrees with sync/atomic")
+	}
+	var u Uint32
+	if u.Add(^uint32(0)) != ^uint32(0) {
+		t.Error("Uint32.Add did not wrap around")
+	}
+	var p Pointer[int]
+	one, two := new(int), new(int)
+	if p.Load() != nil || !p.CompareAndSwap(nil, one) || p.Swap(two) != one || p.Load() != two {
+		t.Error("Pointer operations disagree with sync/atomic")
+	}
+	var v Value
+	if v.Load() != nil {
+		t.Error("zero Value loaded a value")
+	}
+	v.Store("a")
+	if !v.CompareAndSwap("a", "b") || v.Swap("c") != "b" || v.Load() != "c" {
+		t.Error("Value operations disagree with sync/atomic")
+	}
+}
+
+// TestCompareAndSwapLoop verifies that tasks incrementing a counter with a
+// compare-and-swap loop never lose an update, however their loads and
+// swaps interleave.
+func TestCompareAndSwapLoop(t *testing.T) {
+	wefttest.Explore(t, 50, func(s *weft.Scheduler) {
+		var n Int64
+		for range 3 {
+			s.Go(func(weft.Context) {
+				for range 2 {
+					for {
+						old := n.Load()
+						if n.CompareAndSwap(old, old+1) {
+							break
+						}
+					}
+				}
+			})
+		}
+		s.Wait()
+		if got := n.Load(); got != 6 {
+			t.Errorf("counter = %d after 6 increments", got)
+		}
+	})
+}
@@ -0,0 +1,10 @@
+// Package atomic is a replacement for the types of sync/atomic whose
+// operations are scheduling points. Under -tags=detsched each operation
+// lets the deterministic scheduler run another task before it takes
+// effect, so exploration interleaves the tasks of a lock-free structure
+// around its loads, stores and compare-and-swaps; otherwise the types are
+// those of sync/atomic themselves.
+//
+// The functions of sync/atomic, such as AddInt64, have no equivalent here;
+// use the types instead.
+package atomic
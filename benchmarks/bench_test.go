@@ -0,0 +1,222 @@
+package benchmarks
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// compare runs the weft and standard library versions of a benchmark as
+// sub-benchmarks named for the build mode and implementation, the weft
+// version in a run for the package-level weft.Go.
+func compare(b *testing.B, weftBench, stdBench func(b *testing.B)) {
+	b.Run("mode="+mode+"/impl=weft", func(b *testing.B) {
+		b.ReportAllocs()
+		weft.RunDefault(b, func() { weftBench(b) })
+	})
+	b.Run("mode="+mode+"/impl=std", func(b *testing.B) {
+		b.ReportAllocs()
+		stdBench(b)
+	})
+}
+
+func BenchmarkMutex(b *testing.B) {
+	compare(b, func(b *testing.B) {
+		var mu weft.Mutex
+		for i := 0; i < b.N; i++ {
+			mu.Lock()
+			mu.Unlock()
+		}
+	}, func(b *testing.B) {
+		var mu sync.Mutex
+		for i := 0; i < b.N; i++ {
+			mu.Lock()
+			mu.Unlock()
+		}
+	})
+}
+
+func BenchmarkRWMutex(b *testing.B) {
+	compare(b, func(b *testing.B) {
+		var mu weft.RWMutex
+		for i := 0; i < b.N; i++ {
+			mu.RLock()
+			mu.RUnlock()
+		}
+	}, func(b *testing.B) {
+		var mu sync.RWMutex
+		for i := 0; i < b.N; i++ {
+			mu.RLock()
+			mu.RUnlock()
+		}
+	})
+}
+
+// BenchmarkMutexContended has two tasks take turns at a mutex.
+func BenchmarkMutexContended(b *testing.B) {
+	compare(b, func(b *testing.B) {
+		var mu weft.Mutex
+		done := weft.MakeChan[struct{}](0)
+		weft.Go(func(weft.Context) {
+			for i := 0; i < b.N; i++ {
+				mu.Lock()
+				mu.Unlock()
+			}
+			done.Send(struct{}{})
+		})
+		for i := 0; i < b.N; i++ {
+			mu.Lock()
+			mu.Unlock()
+		}
+		done.Recv()
+	}, func(b *testing.B) {
+		var mu sync.Mutex
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < b.N; i++ {
+				mu.Lock()
+				mu.Unlock()
+			}
+			done <- struct{}{}
+		}()
+		for i := 0; i < b.N; i++ {
+			mu.Lock()
+			mu.Unlock()
+		}
+		<-done
+	})
+}
+
+func BenchmarkChan(b *testing.B) {
+	compare(b, func(b *testing.B) {
+		c := weft.MakeChan[int](1)
+		for i := 0; i < b.N; i++ {
+			c.Send(i)
+			c.Recv()
+		}
+	}, func(b *testing.B) {
+		c := make(chan int, 1)
+		for i := 0; i < b.N; i++ {
+			c <- i
+			<-c
+		}
+	})
+}
+
+// BenchmarkChanPingPong passes a value back and forth between two tasks
+// over unbuffered channels.
+func BenchmarkChanPingPong(b *testing.B) {
+	compare(b, func(b *testing.B) {
+		ping, pong := weft.MakeChan[int](0), weft.MakeChan[int](0)
+		weft.Go(func(weft.Context) {
+			for i := 0; i < b.N; i++ {
+				v, _ := ping.Recv()
+				pong.Send(v)
+			}
+		})
+		for i := 0; i < b.N; i++ {
+			ping.Send(i)
+			pong.Recv()
+		}
+	}, func(b *testing.B) {
+		ping, pong := make(chan int), make(chan int)
+		go func() {
+			for i := 0; i < b.N; i++ {
+				pong <- <-ping
+			}
+		}()
+		for i := 0; i < b.N; i++ {
+			ping <- i
+			<-pong
+		}
+	})
+}
+
+// BenchmarkCond has two tasks take turns, each signaling the other through
+// a condition variable.
+func BenchmarkCond(b *testing.B) {
+	compare(b, func(b *testing.B) {
+		var mu weft.Mutex
+		c := weft.NewCond(&mu)
+		turn := 0
+		take := func(me int) {
+			for i := 0; i < b.N; i++ {
+				mu.Lock()
+				for turn != me {
+					c.Wait()
+				}
+				turn = 1 - me
+				c.Signal()
+				mu.Unlock()
+			}
+		}
+		done := weft.MakeChan[struct{}](0)
+		weft.Go(func(weft.Context) {
+			take(1)
+			done.Send(struct{}{})
+		})
+		take(0)
+		done.Recv()
+	}, func(b *testing.B) {
+		var mu sync.Mutex
+		c := sync.NewCond(&mu)
+		turn := 0
+		take := func(me int) {
+			for i := 0; i < b.N; i++ {
+				mu.Lock()
+				for turn != me {
+					c.Wait()
+				}
+				turn = 1 - me
+				c.Signal()
+				mu.Unlock()
+			}
+		}
+		done := make(chan struct{})
+		go func() {
+			take(1)
+			done <- struct{}{}
+		}()
+		take(0)
+		<-done
+	})
+}
+
+// BenchmarkGo starts a task and waits for it to finish. The scheduler is
+// reset now and then, as an exploration resets it between runs, so that
+// its trace does not grow without bound.
+func BenchmarkGo(b *testing.B) {
+	compare(b, func(b *testing.B) {
+		s := weft.NewScheduler(1)
+		done := weft.MakeChan[struct{}](0)
+		for i := 0; i < b.N; i++ {
+			if i%1024 == 1023 {
+				s.Reset(1)
+			}
+			s.Go(func(weft.Context) { done.Send(struct{}{}) })
+			done.Recv()
+		}
+	}, func(b *testing.B) {
+		done := make(chan struct{})
+		for i := 0; i < b.N; i++ {
+			go func() { done <- struct{}{} }()
+			<-done
+		}
+	})
+}
+
+// BenchmarkSleep sleeps for no time, which on the virtual clock is a
+// scheduling point and nothing more.
+func BenchmarkSleep(b *testing.B) {
+	compare(b, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			weft.Sleep(0)
+		}
+	}, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			time.Sleep(0)
+		}
+	})
+}
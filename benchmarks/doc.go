@@ -0,0 +1,16 @@
+// Package benchmarks measures weft's primitives against their standard
+// library equivalents, in whichever build mode it is tested in, so that a
+// regression in either is caught.
+//
+// Each benchmark runs as sub-benchmarks named for the build mode and the
+// implementation, such as BenchmarkMutex/mode=detsched/impl=weft, for
+// benchstat to lay out in columns:
+//
+//	go test -run='^$' -bench=. -count=10 ./benchmarks > notag.txt
+//	go test -tags=detsched -run='^$' -bench=. -count=10 ./benchmarks > detsched.txt
+//	benchstat -col /impl notag.txt
+//	benchstat -col /impl detsched.txt
+//
+// To catch a regression, compare the same file from two commits with
+// benchstat old.txt new.txt.
+package benchmarks
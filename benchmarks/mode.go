@@ -0,0 +1,6 @@
+//go:build detsched
+
+package benchmarks
+
+// mode names the build mode in benchmark names.
+const mode = "detsched"
@@ -0,0 +1,80 @@
+//go:build detsched
+
+package weft
+
+import "github.com/mziter/weft/internal/scheduler"
+
+// Blocker lets primitives built outside weft, such as custom locks,
+// bounded queues or waiters for external events, block their tasks the
+// way weft's own primitives do: parking is a scheduling point, which task
+// an Unpark wakes is a decision of the schedule, and both are recorded in
+// the trace on the blocker's name, so the primitive takes part in
+// exploration, replay, Scheduler.Tasks and deadlock reports.
+//
+// A task joins the queue with Waiter while it holds the primitive's own
+// lock, releases the lock and then parks; an Unpark in between is not
+// lost. A semaphore:
+//
+//	type Semaphore struct {
+//		mu      sync.Mutex
+//		permits int
+//		waiting *weft.Blocker // weft.NewBlocker("semaphore")
+//	}
+//
+//	func (s *Semaphore) Acquire() {
+//		s.mu.Lock()
+//		if s.permits > 0 {
+//			s.permits--
+//			s.mu.Unlock()
+//			return
+//		}
+//		w := s.waiting.Waiter()
+//		s.mu.Unlock()
+//		w.Park() // Release hands its permit over
+//	}
+//
+//	func (s *Semaphore) Release() {
+//		s.mu.Lock()
+//		defer s.mu.Unlock()
+//		if !s.waiting.Unpark() {
+//			s.permits++
+//		}
+//	}
+type Blocker struct {
+	b *scheduler.Blocker
+}
+
+// NewBlocker creates a blocker whose trace events name name as their
+// object, such as "semaphore 1".
+func NewBlocker(name string) *Blocker {
+	return &Blocker{b: scheduler.NewBlocker(name)}
+}
+
+// Waiter adds the calling task to the queue of b and returns its place in
+// it, to Park on.
+func (b *Blocker) Waiter() *Waiter {
+	return &Waiter{w: b.b.Waiter()}
+}
+
+// Unpark wakes one task of the queue of b and reports whether there was
+// one. Which task is a decision of the schedule, recorded in Choices;
+// shrinking favors the one that joined the queue first.
+func (b *Blocker) Unpark() bool {
+	return b.b.Unpark()
+}
+
+// UnparkAll wakes every task of the queue of b and returns their number.
+func (b *Blocker) UnparkAll() int {
+	return b.b.UnparkAll()
+}
+
+// Waiter is a task's place in the queue of a Blocker.
+type Waiter struct {
+	w *scheduler.Waiter
+}
+
+// Park blocks the task until it is unparked, returning at once if it has
+// been already. Call it once, from the task that called Waiter.
+func (w *Waiter) Park() {
+	w.w.Park()
+}
@@ -0,0 +1,67 @@
+//go:build detsched
+
+package weft
+
+import (
+	"sync"
+	"testing"
+)
+
+// semaphore is the counting semaphore of Blocker's documentation.
+type semaphore struct {
+	mu      sync.Mutex
+	permits int
+	waiting *Blocker
+}
+
+func (s *semaphore) acquire() {
+	s.mu.Lock()
+	if s.permits > 0 {
+		s.permits--
+		s.mu.Unlock()
+		return
+	}
+	w := s.waiting.Waiter()
+	s.mu.Unlock()
+	w.Park()
+}
+
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.waiting.Unpark() {
+		s.permits++
+	}
+}
+
+// TestBlocker verifies that a semaphore built on a Blocker admits only as
+// many tasks at once as it has permits, and wakes every waiter in the end.
+func TestBlocker(t *testing.T) {
+	for seed := range uint64(10) {
+		s := NewScheduler(seed)
+		sem := &semaphore{permits: 2, waiting: NewBlocker("semaphore")}
+		var mu sync.Mutex
+		inside, most, done := 0, 0, 0
+		for range 6 {
+			s.Go(func(ctx Context) {
+				sem.acquire()
+				mu.Lock()
+				inside++
+				most = max(most, inside)
+				mu.Unlock()
+				ctx.Yield()
+				mu.Lock()
+				inside--
+				done++
+				mu.Unlock()
+				sem.release()
+			})
+		}
+		if err := s.Wait(); err != nil {
+			t.Fatalf("seed %d: Wait() = %v", seed, err)
+		}
+		if most > 2 || done != 6 {
+			t.Errorf("seed %d: %d tasks inside at most and %d done, want at most 2 and 6", seed, most, done)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+//go:build !detsched
+
+package weft
+
+import "sync"
+
+// Blocker is a queue of goroutines waiting on a custom primitive in
+// production mode, woken in the order they joined it.
+type Blocker struct {
+	mu      sync.Mutex
+	waiters []*Waiter
+}
+
+// NewBlocker creates a blocker in production mode, ignoring name.
+func NewBlocker(name string) *Blocker {
+	return &Blocker{}
+}
+
+// Waiter adds the calling goroutine to the queue of b in production mode.
+func (b *Blocker) Waiter() *Waiter {
+	w := &Waiter{woken: make(chan struct{}, 1)}
+	b.mu.Lock()
+	b.waiters = append(b.waiters, w)
+	b.mu.Unlock()
+	return w
+}
+
+// Unpark wakes the goroutine that joined the queue of b first, and
+// reports whether there was one, in production mode.
+func (b *Blocker) Unpark() bool {
+	b.mu.Lock()
+	if len(b.waiters) == 0 {
+		b.mu.Unlock()
+		return false
+	}
+	w := b.waiters[0]
+	b.waiters = b.waiters[1:]
+	b.mu.Unlock()
+	w.woken <- struct{}{}
+	return true
+}
+
+// UnparkAll wakes every goroutine of the queue of b and returns their
+// number in production mode.
+func (b *Blocker) UnparkAll() int {
+	b.mu.Lock()
+	waiters := b.waiters
+	b.waiters = nil
+	b.mu.Unlock()
+	for _, w := range waiters {
+		w.woken <- struct{}{}
+	}
+	return len(waiters)
+}
+
+// Waiter is a goroutine's place in the queue of a Blocker in production
+// mode.
+type Waiter struct {
+	woken chan struct{}
+}
+
+// Park blocks until the goroutine is unparked in production mode.
+func (w *Waiter) Park() {
+	<-w.woken
+}
@@ -7,6 +7,11 @@ import (
 )
 
 // Chan is a deterministic channel.
+//
+// The zero Chan is a nil channel and behaves as one: Send and Recv block
+// forever, which Wait reports as a deadlock if no other task is left
+// running; a case on it never proceeds in Select, so setting a case's
+// channel to the zero Chan disables the case; and Close panics.
 type Chan[T any] struct {
 	ch *scheduler.Chan[T]
 }
@@ -28,17 +33,19 @@ func (c Chan[T]) Recv() (T, bool) {
 	return c.ch.Recv()
 }
 
-// TrySend attempts to send without blocking.
+// TrySend attempts to send without blocking, after a scheduling point.
 func (c Chan[T]) TrySend(v T) bool {
+	scheduler.Checkpoint()
 	return c.ch.TrySend(v)
 }
 
-// TryRecv attempts to receive without blocking.
+// TryRecv attempts to receive without blocking, after a scheduling point.
 func (c Chan[T]) TryRecv() (T, bool) {
+	scheduler.Checkpoint()
 	return c.ch.TryRecv()
 }
 
-// Close closes the channel.
+// Close closes the channel. Closing the zero Chan panics.
 func (c Chan[T]) Close() {
 	c.ch.Close()
 }
\ No newline at end of file
@@ -3,6 +3,9 @@
 package weft
 
 // Chan is a regular Go channel in production mode.
+//
+// The zero Chan is a nil channel and behaves as one: Send and Recv block
+// forever, cases on it in Select never proceed, and Close panics.
 type Chan[T any] struct {
 	ch chan T
 }
@@ -46,7 +49,7 @@ func (c Chan[T]) TryRecv() (T, bool) {
 	}
 }
 
-// Close closes the channel.
+// Close closes the channel. Closing the zero Chan panics.
 func (c Chan[T]) Close() {
 	close(c.ch)
 }
\ No newline at end of file
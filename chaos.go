@@ -0,0 +1,32 @@
+package weft
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrChaos is the value tasks panic with when chaos injects a panic.
+var ErrChaos = errors.New("weft: chaos panic")
+
+// Chaos configures faults injected into tasks at random scheduling points,
+// to shake out error-handling paths that ordinary schedules never reach.
+// Whether a fault happens, and which, is a decision of the schedule, so a
+// failure it causes replays and shrinks like any other.
+type Chaos struct {
+	// Rate is the probability, from 0 to 1, of a fault at each scheduling
+	// point of a task. Rather than deciding at each point, the scheduler
+	// draws how many points a task runs through before its next fault,
+	// so that a schedule records one decision per fault.
+	Rate float64
+
+	// Panic enables panicking with ErrChaos.
+	Panic bool
+
+	// Cancel enables canceling the task's Context, closing its Done
+	// channel.
+	Cancel bool
+
+	// MaxDelay, if positive, enables delaying the task by up to that much
+	// virtual time.
+	MaxDelay time.Duration
+}
@@ -0,0 +1,89 @@
+//go:build detsched
+
+package weft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestChaos verifies that chaos injects each kind of fault it enables at a
+// scheduling point, and records it.
+func TestChaos(t *testing.T) {
+	tests := []struct {
+		name  string
+		chaos Chaos
+		check func(t *testing.T, ctx Context, recovered any)
+	}{
+		{"cancel", Chaos{Rate: 1, Cancel: true}, func(t *testing.T, ctx Context, _ any) {
+			select {
+			case <-ctx.Done():
+			default:
+				t.Error("Context not canceled")
+			}
+		}},
+		{"panic", Chaos{Rate: 1, Panic: true}, func(t *testing.T, _ Context, recovered any) {
+			if recovered != ErrChaos {
+				t.Errorf("recovered %v, want ErrChaos", recovered)
+			}
+		}},
+		{"delay", Chaos{Rate: 1, MaxDelay: time.Millisecond}, func(*testing.T, Context, any) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScheduler(1)
+			s.SetChaos(tt.chaos)
+			var mu Mutex
+			s.Go(func(ctx Context) {
+				defer func() {
+					tt.check(t, ctx, recover())
+				}()
+				mu.Lock()
+				mu.Unlock()
+			})
+			s.Wait()
+
+			var faults []string
+			for _, ev := range s.Events() {
+				if ev.Kind == trace.Chaos {
+					faults = append(faults, ev.Object)
+				}
+			}
+			if len(faults) == 0 || faults[0] != tt.name {
+				t.Errorf("chaos events = %v, want %s first", faults, tt.name)
+			}
+		})
+	}
+}
+
+// TestChaosRuns verifies that chaos at a rate below 1 faults about as often
+// as that rate, recording decisions per fault rather than per scheduling
+// point.
+func TestChaosRuns(t *testing.T) {
+	const points = 2000
+	s := NewScheduler(1)
+	s.SetChaos(Chaos{Rate: 0.05, MaxDelay: time.Microsecond})
+	var mu Mutex
+	s.Go(func(Context) {
+		for i := 0; i < points; i++ {
+			mu.Lock()
+			mu.Unlock()
+		}
+	})
+	s.Wait()
+
+	faults := 0
+	for _, ev := range s.Events() {
+		if ev.Kind == trace.Chaos {
+			faults++
+		}
+	}
+	if faults < points*0.05/2 || faults > points*0.05*2 {
+		t.Errorf("%d faults in %d scheduling points, want about %v", faults, points, points*0.05)
+	}
+	if n := len(s.Choices()); n > 2*faults+1 {
+		t.Errorf("%d decisions for %d faults, want at most %d", n, faults, 2*faults+1)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mziter/weft/trace"
+)
+
+// coverCmd dispatches the weft cover subcommands, which combine and
+// summarize interleaving coverage.
+func coverCmd(args []string) error {
+	if len(args) < 1 {
+		coverUsage()
+		os.Exit(2)
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "merge":
+		return mergeCmd(args)
+	case "report":
+		return reportCmd(args)
+	default:
+		fmt.Fprintf(os.Stderr, "weft cover: unknown command %q\n", cmd)
+		coverUsage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+func coverUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: weft cover <command> [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  merge    combine coverage files from several processes or CI shards\n")
+	fmt.Fprintf(os.Stderr, "  report   summarize a coverage file\n")
+}
+
+// shardSummary describes one input to a merge.
+type shardSummary struct {
+	File   string `json:"file"`
+	Runs   int    `json:"runs"`
+	Points int    `json:"points"`
+
+	// Unique counts the points no other input covered.
+	Unique int `json:"unique"`
+}
+
+// mergeResult is the outcome of a merge.
+type mergeResult struct {
+	Output string         `json:"output,omitempty"`
+	Runs   int            `json:"runs"`
+	Points int            `json:"points"`
+	Shards []shardSummary `json:"shards"`
+}
+
+func mergeCmd(args []string) error {
+	fs := flag.NewFlagSet("cover merge", flag.ExitOnError)
+	var (
+		output  = fs.String("o", "cover.json", "Write the merged coverage to this file")
+		jsonOut = fs.Bool("json", false, "Print the summary as JSON")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft cover merge [flags] file-or-dir...\n\n")
+		fmt.Fprintf(os.Stderr, "Merge combines the interleaving coverage written to WEFT_COVER_DIR by\nseparate test processes or CI shards. Directories contribute every\n.json file they contain.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	names, err := coverFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	merged := trace.NewCoverage()
+	shards := make([]*trace.Coverage, len(names))
+	for i, name := range names {
+		if shards[i], err = trace.ReadCoverage(name); err != nil {
+			return err
+		}
+		merged.Merge(shards[i])
+	}
+	if err := merged.WriteFile(*output); err != nil {
+		return err
+	}
+
+	// owners counts the inputs covering each point.
+	owners := make(map[string]int)
+	for _, c := range shards {
+		for point := range c.Points {
+			owners[point]++
+		}
+	}
+	res := &mergeResult{Output: *output, Runs: merged.Runs, Points: len(merged.Points)}
+	for i, c := range shards {
+		s := shardSummary{File: names[i], Runs: c.Runs, Points: len(c.Points)}
+		for point := range c.Points {
+			if owners[point] == 1 {
+				s.Unique++
+			}
+		}
+		res.Shards = append(res.Shards, s)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	for _, s := range res.Shards {
+		fmt.Printf("%s: %d runs, %d points, %d unique\n", s.File, s.Runs, s.Points, s.Unique)
+	}
+	fmt.Printf("merged %d files into %s: %d runs, %d points\n", len(names), res.Output, res.Runs, res.Points)
+	return nil
+}
+
+func reportCmd(args []string) error {
+	fs := flag.NewFlagSet("cover report", flag.ExitOnError)
+	var (
+		top     = fs.Int("n", 20, "Number of least covered points to list, or 0 for all")
+		points  = fs.String("points", "", "JSON from weft points -json; also report the synchronization points never reached")
+		jsonOut = fs.Bool("json", false, "Print the coverage as JSON")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft cover report [flags] cover.json\n\n")
+		fmt.Fprintf(os.Stderr, "Report summarizes interleaving coverage, listing the interleavings seen\nin the fewest runs first.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	c, err := trace.ReadCoverage(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	fmt.Printf("%d runs, %d points\n", c.Runs, len(c.Points))
+	list := rarest(c)
+	if *top > 0 && len(list) > *top {
+		list = list[:*top]
+	}
+	for _, point := range list {
+		fmt.Printf("%8d  %s\n", c.Points[point], point)
+	}
+	if *points == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(*points)
+	if err != nil {
+		return err
+	}
+	var inventory []syncPoint
+	if err := json.Unmarshal(data, &inventory); err != nil {
+		return fmt.Errorf("%s: %w", *points, err)
+	}
+	unreached := unreachedPoints(c, inventory)
+	fmt.Printf("\n%d of %d synchronization points reached\n", len(inventory)-len(unreached), len(inventory))
+	if *top > 0 && len(unreached) > *top {
+		unreached = unreached[:*top]
+	}
+	for _, p := range unreached {
+		fmt.Printf("    %s\t%s.%s in %s\n", p, p.Primitive, p.Operation, p.Func)
+	}
+	return nil
+}
+
+// unreachedPoints returns the points of inventory at which c covered no
+// interleaving.
+func unreachedPoints(c *trace.Coverage, inventory []syncPoint) []syncPoint {
+	sites := c.Sites()
+	var unreached []syncPoint
+	for _, p := range inventory {
+		if !sites[p.String()] {
+			unreached = append(unreached, p)
+		}
+	}
+	return unreached
+}
+
+// rarest returns the points of c ordered by the number of runs covering
+// them, fewest first.
+func rarest(c *trace.Coverage) []string {
+	points := make([]string, 0, len(c.Points))
+	for point := range c.Points {
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if c.Points[points[i]] != c.Points[points[j]] {
+			return c.Points[points[i]] < c.Points[points[j]]
+		}
+		return points[i] < points[j]
+	})
+	return points
+}
+
+// coverFiles expands args, which name coverage files or directories of
+// them, into a sorted list of files.
+func coverFiles(args []string) ([]string, error) {
+	var names []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			names = append(names, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		names = append(names, matches...)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no coverage files in %v", args)
+	}
+	return names, nil
+}
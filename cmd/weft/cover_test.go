@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestCoverFiles verifies that directories expand to the coverage files
+// they contain.
+func TestCoverFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"cover-b.json", "cover-a.json", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	single := filepath.Join(t.TempDir(), "shard.json")
+	if err := os.WriteFile(single, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := coverFiles([]string{single, dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{single, filepath.Join(dir, "cover-a.json"), filepath.Join(dir, "cover-b.json")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("coverFiles = %v, want %v", got, want)
+	}
+}
+
+// TestRarest verifies that points are listed fewest runs first.
+func TestRarest(t *testing.T) {
+	c := trace.NewCoverage()
+	c.Points = map[string]int{"b": 3, "a": 3, "c": 1}
+	if got, want := rarest(c), []string{"c", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rarest = %v, want %v", got, want)
+	}
+}
+
+// TestUnreachedPoints verifies that inventory points are matched against
+// the sites of covered interleavings.
+func TestUnreachedPoints(t *testing.T) {
+	c := trace.NewCoverage()
+	c.Points = map[string]int{"unlock /src/q.go:12 -> lock /src/q.go:20": 1}
+	inventory := []syncPoint{
+		{File: "/src/q.go", Line: 12, Primitive: "Mutex", Operation: "Unlock"},
+		{File: "/src/q.go", Line: 20, Primitive: "Mutex", Operation: "Lock"},
+		{File: "/src/q.go", Line: 31, Primitive: "Chan", Operation: "Send"},
+	}
+	got := unreachedPoints(c, inventory)
+	if want := inventory[2:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("unreachedPoints = %v, want %v", got, want)
+	}
+}
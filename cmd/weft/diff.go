@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mziter/weft/trace"
+	"github.com/mziter/weft/wefttest"
+)
+
+// diffContext is the number of events shown on each side of a divergence.
+const diffContext = 3
+
+// diffResult is the outcome of weft trace diff.
+type diffResult struct {
+	Failing *trace.Trace `json:"failing"`
+	Passing *trace.Trace `json:"passing"`
+
+	// PassingFile is the passing trace file, when one was given.
+	PassingFile string `json:"passingFile,omitempty"`
+
+	// Candidates is the number of passing traces compared.
+	Candidates int              `json:"candidates"`
+	Divergence trace.Divergence `json:"divergence"`
+}
+
+func diffCmd(args []string) error {
+	fs := flag.NewFlagSet("trace diff", flag.ExitOnError)
+	var (
+		runs    = fs.Int("runs", 100, "Passing schedules to record when no passing traces are given")
+		tags    = fs.String("tags", "", "Additional comma-separated build tags")
+		jsonOut = fs.Bool("json", false, "Print the result as JSON")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft trace diff [flags] failing.wefttrace [passing.wefttrace ...]\n\n")
+		fmt.Fprintf(os.Stderr, "Diff aligns a failing trace with the most similar passing trace and shows\nthe first scheduling decision and event where they diverge. Without passing\ntraces, it explores the failing test to record some.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	failing, err := trace.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	files := fs.Args()[1:]
+	var passing []*trace.Trace
+	for _, name := range files {
+		tr, err := trace.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		passing = append(passing, tr)
+	}
+	if len(passing) == 0 {
+		if passing, err = recordPassing(failing, *runs, *tags); err != nil {
+			return err
+		}
+		files = nil
+	}
+	if len(passing) == 0 {
+		return fmt.Errorf("no passing schedule of %s found in %d runs", failing.Test, *runs)
+	}
+
+	best := 0
+	bestChoices, bestEvents := trace.Similarity(failing, passing[0])
+	for i, tr := range passing[1:] {
+		c, e := trace.Similarity(failing, tr)
+		if c > bestChoices || (c == bestChoices && e > bestEvents) {
+			best, bestChoices, bestEvents = i+1, c, e
+		}
+	}
+	res := &diffResult{
+		Failing:    failing,
+		Passing:    passing[best],
+		Candidates: len(passing),
+		Divergence: trace.Diverge(failing, passing[best]),
+	}
+	if files != nil {
+		res.PassingFile = files[best]
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	printDiff(res)
+	return nil
+}
+
+// recordPassing explores the failing trace's test and returns the traces of
+// its passing schedules.
+func recordPassing(failing *trace.Trace, runs int, tags string) ([]*trace.Trace, error) {
+	if failing.Package == "" {
+		return nil, fmt.Errorf("trace does not record its package; pass passing traces explicitly")
+	}
+	p, cleanup, err := buildOne(failing.Package, tags)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	dir := filepath.Join(filepath.Dir(p.binary), "traces")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return nil, err
+	}
+	env := []string{
+		wefttest.EnvRuns + "=" + strconv.Itoa(runs),
+		wefttest.EnvTraceDir + "=" + dir,
+		wefttest.EnvTraceAll + "=1",
+	}
+	if _, _, err := p.run(env, nil, "-test.count=1", "-test.run="+testPattern(failing.Test)); err != nil {
+		return nil, err
+	}
+	found, err := collectTraces(dir, failing.Package, false)
+	if err != nil {
+		return nil, err
+	}
+	var passing []*trace.Trace
+	for _, f := range found {
+		if f.Trace.Failure == "" && f.Trace.Test == failing.Test {
+			passing = append(passing, f.Trace)
+		}
+	}
+	return passing, nil
+}
+
+// printDiff prints the divergence and the events around it side by side.
+func printDiff(res *diffResult) {
+	f, p, d := res.Failing, res.Passing, res.Divergence
+	fmt.Printf("failing: %s seed %d: %s\n", f.Test, f.Seed, f.Failure)
+	passing := fmt.Sprintf("seed %d", p.Seed)
+	if res.PassingFile != "" {
+		passing = res.PassingFile
+	}
+	fmt.Printf("closest passing of %d: %s\n", res.Candidates, passing)
+
+	switch {
+	case d.Choice >= 0:
+		fmt.Printf("first divergent decision: #%d (failing chose %d, passing chose %d) after %d shared\n",
+			d.Choice, f.Choices[d.Choice], p.Choices[d.Choice], d.Choice)
+	case len(f.Choices) != len(p.Choices):
+		fmt.Printf("decisions agree for %d; one run made more\n", min(len(f.Choices), len(p.Choices)))
+	default:
+		fmt.Printf("decisions identical (%d)\n", len(f.Choices))
+	}
+
+	if d.Event < 0 {
+		if len(f.Events) == len(p.Events) {
+			fmt.Println("events identical")
+			return
+		}
+		d.Event = min(len(f.Events), len(p.Events))
+	}
+	fmt.Printf("first divergent event: #%d\n\n", d.Event)
+	fmt.Printf("      %-38s %s\n", "failing", "passing")
+	for i := max(0, d.Event-diffContext); i <= d.Event+diffContext; i++ {
+		if i >= len(f.Events) && i >= len(p.Events) {
+			break
+		}
+		mark := " "
+		if i == d.Event {
+			mark = ">"
+		}
+		fmt.Printf("%s %4d %-48s %s\n", mark, i, describe(f.Events, i), describe(p.Events, i))
+	}
+	for _, side := range []struct {
+		name   string
+		events []trace.Event
+	}{{"failing", f.Events}, {"passing", p.Events}} {
+		if d.Event < len(side.events) && len(side.events[d.Event].Stack) > 0 {
+			fmt.Printf("\n%s event #%d at:\n    %s\n", side.name, d.Event, strings.Join(side.events[d.Event].Stack, "\n    "))
+		}
+	}
+}
+
+// describe returns a one-line summary of events[i], or "" past the end.
+func describe(events []trace.Event, i int) string {
+	if i >= len(events) {
+		return ""
+	}
+	return events[i].String()
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/mziter/weft/internal/codemod"
+)
+
+// weftPackages lists the packages of the weft module that code under test
+// imports, by their path below the module.
+var weftPackages = map[string]bool{
+	"wefttest":     true,
+	"trace":        true,
+	"errgroup":     true,
+	"semaphore":    true,
+	"singleflight": true,
+	"weftnet":      true,
+	"weftcontext":  true,
+	"weftfs":       true,
+	"syncer":       true,
+	"weftsql":      true,
+	"weftbus":      true,
+	"weftraft":     true,
+	"lincheck":     true,
+	"weftactor":    true,
+	"latency":      true,
+	"weftio":       true,
+	"weftobj":      true,
+	"weftexec":     true,
+	"weftmember":   true,
+}
+
+// wallClock lists the time functions whose results depend on the wall
+// clock rather than on the schedule.
+var wallClock = map[string]bool{
+	"Now": true, "Since": true, "Until": true, "Sleep": true, "After": true,
+	"AfterFunc": true, "Tick": true, "NewTimer": true, "NewTicker": true,
+}
+
+// problem is a setup problem found by weft doctor.
+type problem struct {
+	Pos     token.Position
+	Message string
+}
+
+func doctorCmd(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	tags := fs.String("tags", "", "Additional comma-separated build tags")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft doctor [flags] [packages]\n\n")
+		fmt.Fprintf(os.Stderr, "Doctor checks packages for common weft setup problems: declarations with\nno counterpart in the other build mode, packages mixing weft and sync,\nwrong weft import paths, and nondeterminism in the build functions of tests.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	problems, n, err := diagnose("", patterns, *tags)
+	if err != nil {
+		return err
+	}
+	wd, _ := os.Getwd()
+	for _, p := range problems {
+		file := p.Pos.Filename
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		fmt.Printf("%s:%d: %s\n", file, p.Pos.Line, p.Message)
+	}
+	if len(problems) > 0 {
+		return errFailed
+	}
+	fmt.Printf("no problems found in %d packages\n", n)
+	return nil
+}
+
+// diagnose checks the packages of the main module matching patterns, loaded
+// from dir, and returns the problems found, in file and line order, and the
+// number of packages checked.
+func diagnose(dir string, patterns []string, tags string) ([]problem, int, error) {
+	tags = strings.Trim(codemod.Tag+","+tags, ",")
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedSyntax |
+			packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedModule,
+		Dir:        dir,
+		Tests:      true,
+		BuildFlags: []string{"-tags=" + tags},
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	d := &doctor{fset: token.NewFileSet(), tags: strings.Split(tags, ",")}
+	dirs := make(map[string]string)
+	for _, pkg := range pkgs {
+		if pkg.Module == nil || !pkg.Module.Main {
+			continue
+		}
+		d.module = pkg.Module.Path
+		files := append(append([]string(nil), pkg.GoFiles...), pkg.IgnoredFiles...)
+		if len(files) > 0 && !strings.HasSuffix(pkg.Name, "_test") && !strings.HasSuffix(pkg.PkgPath, ".test") {
+			dirs[filepath.Dir(files[0])] = pkg.PkgPath
+		}
+		if len(pkg.Errors) == 0 {
+			d.checkTests(pkg)
+		}
+	}
+	for dir, pkgPath := range dirs {
+		if err := d.checkDir(dir, pkgPath); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	sort.Slice(d.problems, func(i, j int) bool {
+		a, b := d.problems[i].Pos, d.problems[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Line < b.Line
+	})
+	// Test variants share files with their package.
+	var problems []problem
+	for i, p := range d.problems {
+		if i == 0 || p != d.problems[i-1] {
+			problems = append(problems, p)
+		}
+	}
+	return problems, len(dirs), nil
+}
+
+// doctor accumulates the problems found in a module.
+type doctor struct {
+	fset     *token.FileSet
+	tags     []string
+	module   string
+	problems []problem
+}
+
+func (d *doctor) reportf(fset *token.FileSet, pos token.Pos, format string, args ...interface{}) {
+	d.problems = append(d.problems, problem{Pos: fset.Position(pos), Message: fmt.Sprintf(format, args...)})
+}
+
+// checkDir checks the source files in dir, of the package pkgPath, in both
+// build modes. Files are parsed directly, since files with a wrong import
+// path, or excluded by the build mode, are not loaded.
+func (d *doctor) checkDir(dir, pkgPath string) error {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	det, std := build.Default, build.Default
+	det.BuildTags = d.tags
+	std.BuildTags = nil
+	for _, tag := range d.tags {
+		if tag != codemod.Tag {
+			std.BuildTags = append(std.BuildTags, tag)
+		}
+	}
+
+	var detFiles, stdFiles []*ast.File
+	for _, name := range names {
+		f, err := parser.ParseFile(d.fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		d.checkImports(f)
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if ok, _ := det.MatchFile(dir, filepath.Base(name)); ok {
+			detFiles = append(detFiles, f)
+		}
+		if ok, _ := std.MatchFile(dir, filepath.Base(name)); ok {
+			stdFiles = append(stdFiles, f)
+		}
+	}
+	d.checkCounterparts(detFiles, stdFiles)
+	// weft's own packages implement the primitives on the standard
+	// library.
+	if pkgPath != codemod.WeftPath && !strings.HasPrefix(pkgPath, codemod.WeftPath+"/") {
+		d.checkMixed(detFiles)
+	}
+	return nil
+}
+
+// checkCounterparts reports exported declarations made in only one build
+// mode, which break the build, or callers, in the other.
+func (d *doctor) checkCounterparts(detFiles, stdFiles []*ast.File) {
+	det, std := exportedDecls(detFiles), exportedDecls(stdFiles)
+	d.missing(det, std, "%s is declared only with -tags=%s; add a !%[2]s counterpart")
+	d.missing(std, det, "%s is declared only without -tags=%s; add a %[2]s counterpart")
+}
+
+// missing reports the declarations of have that other lacks. Methods are
+// not reported for types whose counterpart can get them from elsewhere.
+func (d *doctor) missing(have, other *decls, format string) {
+	for name, pos := range have.names {
+		if _, ok := other.names[name]; ok {
+			continue
+		}
+		if recv, _, ok := strings.Cut(name, "."); ok && other.promoting[recv] {
+			continue
+		}
+		d.reportf(d.fset, pos, format, name, codemod.Tag)
+	}
+}
+
+// decls holds the exported declarations of a package in one build mode.
+type decls struct {
+	// names maps package-level names and methods, as "Name" and
+	// "Type.Method", to their positions.
+	names map[string]token.Pos
+
+	// promoting holds the types that may have methods declared elsewhere:
+	// aliases, such as type Group = errgroup.Group, and structs with
+	// embedded fields.
+	promoting map[string]bool
+}
+
+// exportedDecls returns the exported declarations in files.
+func exportedDecls(files []*ast.File) *decls {
+	d := &decls{names: make(map[string]token.Pos), promoting: make(map[string]bool)}
+	add := func(id *ast.Ident, prefix string) {
+		if id.IsExported() {
+			d.names[prefix+id.Name] = id.Pos()
+		}
+	}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil || len(decl.Recv.List) != 1 {
+					add(decl.Name, "")
+					continue
+				}
+				if recv := recvName(decl.Recv.List[0].Type); ast.IsExported(recv) {
+					add(decl.Name, recv+".")
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						add(spec.Name, "")
+						if spec.Assign.IsValid() || embeds(spec.Type) {
+							d.promoting[spec.Name.Name] = true
+						}
+					case *ast.ValueSpec:
+						for _, id := range spec.Names {
+							add(id, "")
+						}
+					}
+				}
+			}
+		}
+	}
+	return d
+}
+
+// embeds reports whether x is a struct type with embedded fields.
+func embeds(x ast.Expr) bool {
+	st, ok := x.(*ast.StructType)
+	if !ok {
+		return false
+	}
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// recvName returns the name of the type in a receiver type expression.
+func recvName(x ast.Expr) string {
+	for {
+		switch e := x.(type) {
+		case *ast.StarExpr:
+			x = e.X
+		case *ast.ParenExpr:
+			x = e.X
+		case *ast.IndexExpr:
+			x = e.X
+		case *ast.IndexListExpr:
+			x = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// checkMixed reports the sync imports of a package that also imports weft
+// under detsched: the scheduler cannot see goroutines blocked on sync
+// primitives, so runs are not reproducible.
+func (d *doctor) checkMixed(files []*ast.File) {
+	var syncImports []*ast.ImportSpec
+	usesWeft := false
+	for _, f := range files {
+		for _, imp := range f.Imports {
+			switch p, _ := strconv.Unquote(imp.Path.Value); p {
+			case codemod.WeftPath:
+				usesWeft = true
+			case "sync":
+				syncImports = append(syncImports, imp)
+			}
+		}
+	}
+	if !usesWeft {
+		return
+	}
+	for _, imp := range syncImports {
+		d.reportf(d.fset, imp.Pos(), "package uses both weft and sync, which the scheduler cannot see; convert with weftfix or see weftcheck")
+	}
+}
+
+// checkImports reports imports of weft under another path, such as the
+// github.com/yourusername/weft of early versions.
+func (d *doctor) checkImports(f *ast.File) {
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		root := p
+		if weftPackages[path.Base(p)] {
+			root = path.Dir(p)
+		}
+		if path.Base(root) != "weft" || root == codemod.WeftPath || root == d.module {
+			continue
+		}
+		want := codemod.WeftPath + strings.TrimPrefix(p, root)
+		d.reportf(d.fset, imp.Pos(), "import %q should be %q", p, want)
+	}
+}
+
+// checkTests reports nondeterminism in the build functions that test files
+// of pkg pass to wefttest: the scheduler controls only the interleaving, so
+// anything else that varies between runs defeats replay.
+func (d *doctor) checkTests(pkg *packages.Package) {
+	for _, f := range pkg.Syntax {
+		if !strings.HasSuffix(pkg.Fset.Position(f.Package).Filename, "_test.go") {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			callee, ok := typeutil.Callee(pkg.TypesInfo, call).(*types.Func)
+			if !ok || callee.Pkg() == nil || callee.Pkg().Path() != codemod.WeftPath+"/wefttest" {
+				return true
+			}
+			for _, arg := range call.Args {
+				if lit, ok := arg.(*ast.FuncLit); ok {
+					d.checkBuild(pkg, lit)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// checkBuild reports nondeterministic constructs in a build function.
+func (d *doctor) checkBuild(pkg *packages.Package, lit *ast.FuncLit) {
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GoStmt:
+			d.reportf(pkg.Fset, n.Pos(), "go statement in a build function is invisible to the scheduler; use s.Go")
+		case *ast.RangeStmt:
+			if _, ok := pkg.TypesInfo.TypeOf(n.X).Underlying().(*types.Map); ok {
+				d.reportf(pkg.Fset, n.Pos(), "map iteration order differs between runs; range over sorted keys")
+			}
+		case *ast.CallExpr:
+			fn, ok := typeutil.Callee(pkg.TypesInfo, n).(*types.Func)
+			if !ok || fn.Pkg() == nil || fn.Type().(*types.Signature).Recv() != nil {
+				return true
+			}
+			switch p := fn.Pkg().Path(); {
+			case p == "time" && wallClock[fn.Name()]:
+				d.reportf(pkg.Fset, n.Pos(), "time.%s reads the wall clock; use weft's virtual time", fn.Name())
+			case (p == "math/rand" || p == "math/rand/v2") && !strings.HasPrefix(fn.Name(), "New"):
+				d.reportf(pkg.Fset, n.Pos(), "rand.%s is not seeded by the schedule; use a rand.Rand with a fixed seed", fn.Name())
+			case p == "crypto/rand":
+				d.reportf(pkg.Fset, n.Pos(), "crypto/rand differs between runs; use a rand.Rand with a fixed seed")
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestDiagnose verifies each check of weft doctor against a module with one
+// problem of each kind.
+func TestDiagnose(t *testing.T) {
+	problems, n, err := diagnose("testdata/doctor", []string{"./..."}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("checked %d packages, want 1", n)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, fmt.Sprintf("%s:%d: %s", filepath.Base(p.Pos.Filename), p.Pos.Line, p.Message))
+	}
+	want := []string{
+		"clock.go:6: package uses both weft and sync, which the scheduler cannot see; convert with weftfix or see weftcheck",
+		"clock.go:14: Tick is declared only with -tags=detsched; add a !detsched counterpart",
+		`clock_notag.go:5: import "github.com/yourusername/weft" should be "github.com/mziter/weft"`,
+		"clock_notag.go:8: Now is declared only without -tags=detsched; add a detsched counterpart",
+		"doctor_test.go:14: time.Now reads the wall clock; use weft's virtual time",
+		"doctor_test.go:15: go statement in a build function is invisible to the scheduler; use s.Go",
+		"doctor_test.go:16: map iteration order differs between runs; range over sorted keys",
+		"doctor_test.go:17: rand.Intn is not seeded by the schedule; use a rand.Rand with a fixed seed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diagnose found:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// detschedTag is the build tag that enables the deterministic scheduler.
+const detschedTag = "detsched"
+
+// testPackage is a package with tests, compiled for the deterministic
+// scheduler.
+type testPackage struct {
+	ImportPath   string
+	Dir          string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Error        *struct{ Err string }
+
+	// binary is the compiled test binary.
+	binary string
+
+	// stdin, if set, is connected to the test binary's standard input.
+	stdin io.Reader
+}
+
+// listPackages resolves patterns to the packages that have tests.
+func listPackages(patterns []string) ([]*testPackage, error) {
+	args := append([]string{"list", "-e", "-json=ImportPath,Dir,TestGoFiles,XTestGoFiles,Error"}, patterns...)
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %v\n%s", err, stderr.Bytes())
+	}
+	var pkgs []*testPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		p := new(testPackage)
+		if err := dec.Decode(p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("go list: %v", err)
+		}
+		if p.Error != nil {
+			return nil, fmt.Errorf("%s: %s", p.ImportPath, p.Error.Err)
+		}
+		if len(p.TestGoFiles)+len(p.XTestGoFiles) > 0 {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs, nil
+}
+
+// build compiles the test binary for p into dir with the detsched tag and
+// any extra comma-separated tags.
+func (p *testPackage) build(dir, tags string) error {
+	if tags != "" {
+		tags = detschedTag + "," + tags
+	} else {
+		tags = detschedTag
+	}
+	p.binary = filepath.Join(dir, pathName(p.ImportPath)+".test")
+	cmd := exec.Command("go", "test", "-c", "-tags="+tags, "-o", p.binary, p.ImportPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("building %s: %v\n%s", p.ImportPath, err, out)
+	}
+	return nil
+}
+
+// run runs the test binary in the package directory with the extra
+// environment and arguments. A failing test is reported through passed, not
+// err; err is reserved for failures to run the binary.
+func (p *testPackage) run(env []string, stream io.Writer, args ...string) (output []byte, passed bool, err error) {
+	var buf bytes.Buffer
+	cmd := exec.Command(p.binary, args...)
+	cmd.Dir = p.Dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = p.stdin
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	if stream != nil {
+		cmd.Stdout = io.MultiWriter(&buf, stream)
+		cmd.Stderr = cmd.Stdout
+	}
+	err = cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return buf.Bytes(), false, nil
+	}
+	return buf.Bytes(), err == nil, err
+}
+
+// testPattern returns a -test.run pattern matching exactly the named test,
+// including subtests named with slashes.
+func testPattern(test string) string {
+	parts := strings.Split(test, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return strings.Join(parts, "/")
+}
+
+// pathName turns an import path into a file name.
+func pathName(importPath string) string {
+	return strings.NewReplacer("/", "_", ".", "_").Replace(importPath)
+}
@@ -0,0 +1,89 @@
+// Command weft runs, replays and shrinks weft explorations outside go test.
+//
+// Usage:
+//
+//	weft run [flags] [packages]          explore every test in packages
+//	weft replay [flags] trace.wefttrace  replay a recorded trace
+//	weft replay -seed N -test T pkg      replay a single seed
+//	weft shrink [flags] trace.wefttrace  shrink a failing trace
+//	weft repro -test T -seed N pkg       package a shrunk failure for a bug report
+//	weft trace view trace.wefttrace      render a trace as HTML
+//	weft trace export trace.wefttrace    write a trace as a sequence diagram
+//	weft trace diff failing.wefttrace    find where a failure diverges from a pass
+//	weft points [packages]               list the weft synchronization points
+//	weft cover merge dir...              merge interleaving coverage from shards
+//	weft cover report cover.json         summarize interleaving coverage
+//	weft doctor [packages]               check packages for setup problems
+//
+// Test binaries are built with -tags=detsched. Results are printed as text,
+// or as JSON with -json.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errFailed reports that a command ran to completion and found failures.
+var errFailed = errors.New("failures found")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "run":
+		err = runCmd(args)
+	case "replay":
+		err = replayCmd(args)
+	case "shrink":
+		err = shrinkCmd(args)
+	case "repro":
+		err = reproCmd(args)
+	case "trace":
+		err = traceCmd(args)
+	case "points":
+		err = pointsCmd(args)
+	case "cover":
+		err = coverCmd(args)
+	case "doctor":
+		err = doctorCmd(args)
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "weft: unknown command %q\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+	switch {
+	case errors.Is(err, errFailed):
+		os.Exit(1)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "weft: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: weft <command> [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "weft runs deterministic explorations outside go test.\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  run      explore the tests in packages and report failing schedules\n")
+	fmt.Fprintf(os.Stderr, "  replay   replay a trace file or seed\n")
+	fmt.Fprintf(os.Stderr, "  shrink   reduce a failing trace to fewer scheduling decisions\n")
+	fmt.Fprintf(os.Stderr, "  repro    turn a failing seed into a shrunk, standalone reproduction\n")
+	fmt.Fprintf(os.Stderr, "  trace    inspect trace files\n")
+	fmt.Fprintf(os.Stderr, "  points   list the weft synchronization points in packages\n")
+	fmt.Fprintf(os.Stderr, "  cover    merge and report interleaving coverage\n")
+	fmt.Fprintf(os.Stderr, "  doctor   check packages for common weft setup problems\n\n")
+	fmt.Fprintf(os.Stderr, "Run 'weft <command> -h' for the flags of a command.\n\n")
+	fmt.Fprintf(os.Stderr, "Examples:\n")
+	fmt.Fprintf(os.Stderr, "  weft run -runs 10000 -traces ./traces ./...\n")
+	fmt.Fprintf(os.Stderr, "  weft replay ./traces/TestQueue-seed_42.wefttrace\n")
+	fmt.Fprintf(os.Stderr, "  weft shrink -o min.wefttrace ./traces/TestQueue-seed_42.wefttrace\n")
+	fmt.Fprintf(os.Stderr, "  weft trace view min.wefttrace\n")
+}
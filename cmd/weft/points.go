@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/mziter/weft/internal/codemod"
+)
+
+// syncOps lists the weft operations that are scheduling points, keyed by
+// the name of the receiver's type, or "" for package-level functions.
+var syncOps = map[string]map[string]bool{
+	"":           {"Go": true, "Sleep": true, "After": true, "Select": true, "TrySelect": true},
+	"Scheduler":  {"Go": true, "Wait": true, "Sleep": true},
+	"Context":    {"Yield": true, "Done": true},
+	"Mutex":      {"Lock": true, "Unlock": true, "TryLock": true},
+	"RWMutex":    {"Lock": true, "Unlock": true, "RLock": true, "RUnlock": true},
+	"Locker":     {"Lock": true, "Unlock": true},
+	"Cond":       {"Wait": true, "Signal": true, "Broadcast": true},
+	"Chan":       {"Send": true, "Recv": true, "TrySend": true, "TryRecv": true, "Close": true},
+	"PipeReader": {"Read": true, "Close": true, "CloseWithError": true},
+	"PipeWriter": {"Write": true, "Close": true, "CloseWithError": true},
+}
+
+// syncPoint is a call to a weft operation that hands control to the
+// scheduler.
+type syncPoint struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Primitive string `json:"primitive"`
+	Operation string `json:"operation"`
+
+	// Func is the function containing the call.
+	Func string `json:"func"`
+}
+
+// String returns the point as file:line.
+func (p syncPoint) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+func pointsCmd(args []string) error {
+	fs := flag.NewFlagSet("points", flag.ExitOnError)
+	var (
+		tests   = fs.Bool("tests", false, "Include test files")
+		tags    = fs.String("tags", "", "Additional comma-separated build tags")
+		jsonOut = fs.Bool("json", false, "Print the points as JSON, for weft cover report -points")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft points [flags] [packages]\n\n")
+		fmt.Fprintf(os.Stderr, "Points lists every call to a weft primitive in packages: the places a\ntask can be preempted, and the sites interleaving coverage is measured at.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	points, err := loadPoints(patterns, *tests, *tags)
+	if err != nil {
+		return err
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(points)
+	}
+
+	wd, _ := os.Getwd()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	counts := make(map[string]int)
+	for _, p := range points {
+		file := p.File
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		primitive := p.Primitive
+		if primitive == "" {
+			primitive = "weft"
+		}
+		fmt.Fprintf(tw, "%s:%d\t%s\t%s\t%s\n", file, p.Line, primitive, p.Operation, p.Func)
+		counts[primitive+"."+p.Operation]++
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	ops := make([]string, 0, len(counts))
+	for op := range counts {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	fmt.Printf("\n%d points\n", len(points))
+	for _, op := range ops {
+		fmt.Printf("%8d  %s\n", counts[op], op)
+	}
+	return nil
+}
+
+// loadPoints returns the synchronization points in the packages of the main
+// module matching patterns, in file and line order.
+func loadPoints(patterns []string, tests bool, tags string) ([]syncPoint, error) {
+	tags = strings.Trim(detschedTag+","+tags, ",")
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedSyntax |
+			packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedModule,
+		Tests:      tests,
+		BuildFlags: []string{"-tags=" + tags},
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, e := range pkg.Errors {
+			errs = append(errs, e.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("loading packages:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+
+	seen := make(map[syncPoint]bool)
+	var points []syncPoint
+	for _, pkg := range pkgs {
+		if pkg.Module == nil || !pkg.Module.Main {
+			continue
+		}
+		for _, f := range pkg.Syntax {
+			for _, p := range filePoints(pkg, f) {
+				if !seen[p] {
+					seen[p] = true
+					points = append(points, p)
+				}
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].File != points[j].File {
+			return points[i].File < points[j].File
+		}
+		return points[i].Line < points[j].Line
+	})
+	return points, nil
+}
+
+// filePoints returns the synchronization points in f.
+func filePoints(pkg *packages.Package, f *ast.File) []syncPoint {
+	var points []syncPoint
+	for _, decl := range f.Decls {
+		var fn string
+		if fd, ok := decl.(*ast.FuncDecl); ok {
+			fn = fd.Name.Name
+			if fd.Recv != nil && len(fd.Recv.List) == 1 {
+				fn = types.ExprString(fd.Recv.List[0].Type) + "." + fn
+			}
+		}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			callee, ok := typeutil.Callee(pkg.TypesInfo, call).(*types.Func)
+			if !ok || callee.Pkg() == nil || callee.Pkg().Path() != codemod.WeftPath {
+				return true
+			}
+			primitive := receiverName(callee)
+			if !syncOps[primitive][callee.Name()] {
+				return true
+			}
+			pos := pkg.Fset.Position(call.Lparen)
+			points = append(points, syncPoint{
+				File:      pos.Filename,
+				Line:      pos.Line,
+				Primitive: primitive,
+				Operation: callee.Name(),
+				Func:      fn,
+			})
+			return true
+		})
+	}
+	return points
+}
+
+// receiverName returns the name of the type fn is a method of, or "" for a
+// function.
+func receiverName(fn *types.Func) string {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return ""
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Origin().Obj().Name()
+	}
+	return ""
+}
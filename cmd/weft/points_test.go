@@ -0,0 +1,27 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadPoints verifies that calls to weft primitives in the examples are
+// listed with their primitive, operation and enclosing function.
+func TestLoadPoints(t *testing.T) {
+	points, err := loadPoints([]string{"github.com/mziter/weft/examples"}, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range points {
+		if !syncOps[p.Primitive][p.Operation] {
+			t.Errorf("listed %+v, which is not a synchronization point", p)
+		}
+		if filepath.Base(p.File) == "counter.go" && p.Primitive == "Mutex" && p.Operation == "Lock" && p.Func == "*Counter.Increment" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Mutex.Lock in (*Counter).Increment not listed in %v", points)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mziter/weft/trace"
+	"github.com/mziter/weft/wefttest"
+)
+
+// replayResult is the outcome of a replay.
+type replayResult struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+	Passed  bool   `json:"passed"`
+	Output  string `json:"output"`
+}
+
+func replayCmd(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	var (
+		seed     = fs.String("seed", "", "Replay this seed instead of a trace file")
+		test     = fs.String("test", "", "Test to replay the seed in")
+		tags     = fs.String("tags", "", "Additional comma-separated build tags")
+		jsonOut  = fs.Bool("json", false, "Print the result as JSON")
+		interact = fs.Bool("i", false, "Choose interactively which task runs at each decision the trace does not fix")
+		rewind   = fs.Int("rewind", 0, "Replay the trace up to this many decisions before its end, then continue from the seed or interactively")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft replay [flags] trace.wefttrace\n")
+		fmt.Fprintf(os.Stderr, "       weft replay [flags] -seed N -test TestName package\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var (
+		pkgPath, testName string
+		env               []string
+	)
+	switch {
+	case *seed != "":
+		if fs.NArg() != 1 || *test == "" {
+			fs.Usage()
+			os.Exit(2)
+		}
+		if *rewind != 0 {
+			return fmt.Errorf("-rewind needs a trace file")
+		}
+		if _, err := strconv.ParseUint(*seed, 10, 64); err != nil {
+			return fmt.Errorf("invalid seed %q", *seed)
+		}
+		pkgPath, testName = fs.Arg(0), *test
+		env = []string{wefttest.EnvSeed + "=" + *seed}
+	case fs.NArg() == 1:
+		tr, err := trace.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if tr.Package == "" {
+			return fmt.Errorf("%s does not record its package; use -seed with a package", fs.Arg(0))
+		}
+		abs, err := filepath.Abs(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		pkgPath, testName = tr.Package, tr.Test
+		env = []string{wefttest.EnvTrace + "=" + abs}
+		if *rewind < 0 || *rewind > len(tr.Choices) {
+			return fmt.Errorf("-rewind %d: the trace has %d decisions", *rewind, len(tr.Choices))
+		}
+		if *rewind > 0 {
+			env = append(env, wefttest.EnvDecisions+"="+strconv.Itoa(len(tr.Choices)-*rewind))
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	p, cleanup, err := buildOne(pkgPath, *tags)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	var rewindFile string
+	if *interact {
+		if *jsonOut {
+			return fmt.Errorf("-i cannot be combined with -json")
+		}
+		p.stdin = os.Stdin
+		env = append(env, wefttest.EnvInteractive+"=stdin")
+		if *seed == "" {
+			// A trace can be rewound: the session's rewind command
+			// leaves the decisions to replay in rewindFile.
+			rewindFile = filepath.Join(filepath.Dir(p.binary), "rewind")
+			env = append(env, wefttest.EnvRewindFile+"="+rewindFile)
+		}
+	}
+
+	var stream io.Writer = os.Stdout
+	if *jsonOut {
+		stream = nil
+	}
+	out, passed, err := p.run(env, stream, "-test.count=1", "-test.v", "-test.run="+testPattern(testName))
+	for err == nil && rewindFile != "" {
+		data, rerr := os.ReadFile(rewindFile)
+		if rerr != nil {
+			break
+		}
+		os.Remove(rewindFile)
+		// Replay the run up to the decision rewound to, and resume the
+		// session there.
+		env = append(env, wefttest.EnvDecisions+"="+string(data))
+		out, passed, err = p.run(env, stream, "-test.count=1", "-test.v", "-test.run="+testPattern(testName))
+	}
+	if err != nil {
+		return err
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		res := replayResult{Package: p.ImportPath, Test: testName, Passed: passed, Output: string(out)}
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+	if !passed {
+		return errFailed
+	}
+	return nil
+}
+
+// buildOne builds the test binary for a single package into a temporary
+// directory, which cleanup removes.
+func buildOne(pattern, tags string) (p *testPackage, cleanup func(), err error) {
+	pkgs, err := listPackages([]string{pattern})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, nil, fmt.Errorf("%s: want exactly one package with tests, found %d", pattern, len(pkgs))
+	}
+	work, err := os.MkdirTemp("", "weft-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() { os.RemoveAll(work) }
+	if err := pkgs[0].build(work, tags); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return pkgs[0], cleanup, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/mziter/weft/trace"
+	"github.com/mziter/weft/wefttest"
+)
+
+func reproCmd(args []string) error {
+	fs := flag.NewFlagSet("repro", flag.ExitOnError)
+	var (
+		test    = fs.String("test", "", "Failing test, as named in its trace")
+		seed    = fs.String("seed", "", "Failing seed")
+		output  = fs.String("o", "", "Directory for the reproduction (default repro-TEST-seed_N)")
+		tags    = fs.String("tags", "", "Additional comma-separated build tags")
+		verbose = fs.Bool("v", false, "Report progress")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft repro [flags] -test TestName -seed N [package]\n\n")
+		fmt.Fprintf(os.Stderr, "Repro reruns a failing seed, shrinks its schedule, and writes a directory\nfor the bug tracker holding the shrunk trace, its HTML timeline, the\ntest output and a standalone test file that replays the failure.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *test == "" || *seed == "" || fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if _, err := strconv.ParseUint(*seed, 10, 64); err != nil {
+		return fmt.Errorf("invalid seed %q", *seed)
+	}
+	pattern := "."
+	if fs.NArg() == 1 {
+		pattern = fs.Arg(0)
+	}
+	dir := *output
+	if dir == "" {
+		dir = fmt.Sprintf("repro-%s-seed_%s", strings.ReplaceAll(*test, "/", "_"), *seed)
+	}
+
+	p, cleanup, err := buildOne(pattern, *tags)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	progress := func(format string, args ...interface{}) {
+		if *verbose {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		}
+	}
+	progress("rerunning %s seed %s", *test, *seed)
+	orig, _, err := p.record(*test, wefttest.EnvSeed+"="+*seed)
+	if err != nil {
+		return err
+	}
+	if orig == nil {
+		return fmt.Errorf("%s seed %s does not fail", *test, *seed)
+	}
+	progress("shrinking %d choices", len(orig.Choices))
+	shrunk, err := shrinkTrace(p, orig, *verbose)
+	if err != nil {
+		return err
+	}
+
+	// Replay the shrunk schedule to record its own events and output.
+	candidate := filepath.Join(filepath.Dir(p.binary), "candidate"+trace.Ext)
+	if err := shrunk.WriteFile(candidate); err != nil {
+		return err
+	}
+	final, out, err := p.record(orig.Test, wefttest.EnvTrace+"="+candidate)
+	if err != nil {
+		return err
+	}
+	if final == nil {
+		return fmt.Errorf("shrunk schedule of %s no longer fails", orig.Test)
+	}
+	final.Choices = shrunk.Choices
+
+	src, err := reproTest(p, final)
+	if err != nil {
+		return err
+	}
+	var html bytes.Buffer
+	if err := renderView(&html, final); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := final.WriteFile(filepath.Join(dir, "trace"+trace.Ext)); err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		"trace.html":         html.Bytes(),
+		"output.txt":         out,
+		reproFileName(final): src,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%s seed %d: %s\n", final.Test, final.Seed, final.Failure)
+	fmt.Printf("shrunk %d choices to %d\n", len(orig.Choices), len(final.Choices))
+	fmt.Printf("wrote %s\n", dir)
+	fmt.Printf("    trace.wefttrace   the shrunk trace, for weft replay\n")
+	fmt.Printf("    trace.html        the shrunk trace as a timeline\n")
+	fmt.Printf("    output.txt        the failing test output\n")
+	fmt.Printf("    %s  copy into %s to replay the failure with go test -tags=detsched\n", reproFileName(final), p.Dir)
+	return nil
+}
+
+// record runs test in p with the extra environment, recording failing
+// traces. It returns the first failing trace, with its package filled in,
+// and the test output, or a nil trace if the test passed.
+func (p *testPackage) record(test string, env ...string) (*trace.Trace, []byte, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(p.binary), "traces-")
+	if err != nil {
+		return nil, nil, err
+	}
+	env = append(env, wefttest.EnvTraceDir+"="+dir)
+	out, passed, err := p.run(env, nil, "-test.count=1", "-test.v", "-test.run="+testPattern(test))
+	if err != nil || passed {
+		return nil, out, err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+trace.Ext))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("%s failed outside weft exploration:\n%s", test, out)
+	}
+	sort.Strings(names)
+	tr, err := trace.ReadFile(names[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	tr.Package = p.ImportPath
+	return tr, out, nil
+}
+
+// reproFileName returns the name of the standalone test file for tr.
+func reproFileName(tr *trace.Trace) string {
+	return fmt.Sprintf("%s_seed_%d_repro_test.go", strings.ToLower(identifier(tr.Test)), tr.Seed)
+}
+
+var reproTemplate = template.Must(template.New("repro").Parse(`//go:build detsched
+
+package {{.Package}}
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mziter/weft/wefttest"
+)
+
+// {{.Const}} is the shrunk failing schedule of {{.Test}}, found by weft
+// repro from seed {{.Seed}}. Its failure was: {{.Failure}}
+const {{.Const}} = {{.Trace}}
+
+// {{.Func}} replays the shrunk failing schedule of {{.Test}}.
+// It fails until the bug is fixed.
+func {{.Func}}(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace.wefttrace")
+	if err := os.WriteFile(name, []byte({{.Const}}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(wefttest.EnvTrace, name)
+	{{.Call}}(t)
+}
+`))
+
+// reproTest returns a test file for p's package that replays tr by calling
+// the test that recorded it with the trace in EnvTrace. The file embeds the
+// trace, so it has no other dependencies.
+func reproTest(p *testPackage, tr *trace.Trace) ([]byte, error) {
+	top, _, _ := strings.Cut(tr.Test, "/")
+	pkg, err := declaringPackage(p, top)
+	if err != nil {
+		return nil, err
+	}
+	// Stacks and events only bloat the replay, which needs the choices.
+	replay := &trace.Trace{Package: tr.Package, Test: tr.Test, Seed: tr.Seed, Choices: tr.Choices, Failure: tr.Failure}
+	var js bytes.Buffer
+	if err := replay.Write(&js); err != nil {
+		return nil, err
+	}
+	lit := "`" + js.String() + "`"
+	if strings.Contains(js.String(), "`") {
+		lit = strconv.Quote(js.String())
+	}
+	name := identifier(tr.Test)
+	var buf bytes.Buffer
+	err = reproTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"Test":    tr.Test,
+		"Seed":    tr.Seed,
+		"Failure": strings.Join(strings.Fields(tr.Failure), " "),
+		"Const":   fmt.Sprintf("repro%sSeed%d", strings.TrimPrefix(name, "Test"), tr.Seed),
+		"Func":    fmt.Sprintf("%s_Seed%d_Repro", name, tr.Seed),
+		"Trace":   lit,
+		"Call":    top,
+	})
+	return buf.Bytes(), err
+}
+
+// declaringPackage returns the package clause of the test file in p that
+// declares the test function name: the package itself, or its _test package.
+func declaringPackage(p *testPackage, name string) (string, error) {
+	fset := token.NewFileSet()
+	for _, file := range append(append([]string(nil), p.TestGoFiles...), p.XTestGoFiles...) {
+		f, err := parser.ParseFile(fset, filepath.Join(p.Dir, file), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return "", err
+		}
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == name {
+				return f.Name.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%s: no test function %s", p.ImportPath, name)
+}
+
+// identifier turns a test name, which may include subtest names, into a Go
+// identifier.
+func identifier(test string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, test)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestReproTest verifies that the standalone test file is valid Go in the
+// package declaring the failing test and calls that test.
+func TestReproTest(t *testing.T) {
+	dir := t.TempDir()
+	src := "package queue_test\n\nimport \"testing\"\n\nfunc TestQueue(t *testing.T) {}\n"
+	if err := os.WriteFile(filepath.Join(dir, "queue_test.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &testPackage{ImportPath: "example.com/queue", Dir: dir, XTestGoFiles: []string{"queue_test.go"}}
+	tr := &trace.Trace{Package: p.ImportPath, Test: "TestQueue/drain", Seed: 42, Choices: []int{1, 0}, Failure: "panic: `boom`"}
+
+	out, err := reproTest(p, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), reproFileName(tr), out, 0)
+	if err != nil {
+		t.Fatalf("repro test does not parse: %v\n%s", err, out)
+	}
+	if f.Name.Name != "queue_test" {
+		t.Errorf("package = %s, want queue_test", f.Name.Name)
+	}
+	for _, want := range []string{"func TestQueue_drain_Seed42_Repro(t *testing.T)", "\tTestQueue(t)\n", `\"choices\"`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("repro test does not contain %q:\n%s", want, out)
+		}
+	}
+	if got, want := reproFileName(tr), "testqueue_drain_seed_42_repro_test.go"; got != want {
+		t.Errorf("reproFileName = %q, want %q", got, want)
+	}
+
+	tr.Test = "TestMissing"
+	if _, err := reproTest(p, tr); err == nil {
+		t.Error("reproTest succeeded for a test that is not declared")
+	}
+}
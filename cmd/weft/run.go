@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mziter/weft/trace"
+	"github.com/mziter/weft/wefttest"
+)
+
+// packageResult is the outcome of exploring one package.
+type packageResult struct {
+	Package  string    `json:"package"`
+	Passed   bool      `json:"passed"`
+	Failures []failure `json:"failures,omitempty"`
+
+	// Output is the test output, included when the package failed.
+	Output string `json:"output,omitempty"`
+}
+
+// failure is a failing schedule found during exploration.
+type failure struct {
+	// File is the trace file, when traces are kept.
+	File  string       `json:"file,omitempty"`
+	Trace *trace.Trace `json:"trace"`
+}
+
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var (
+		runs    = fs.Int("runs", 100, "Schedules to explore per Explore call (sets WEFT_RUNS)")
+		run     = fs.String("run", "", "Run only tests matching the regular expression")
+		tags    = fs.String("tags", "", "Additional comma-separated build tags")
+		traces  = fs.String("traces", "", "Directory in which to keep traces of failing schedules")
+		cover   = fs.String("coverdir", "", "Directory in which to write interleaving coverage (sets WEFT_COVER_DIR)")
+		jsonOut = fs.Bool("json", false, "Print results as JSON")
+		notes   = fs.String("annotate", "", "Annotate failures for CI: plain for file:line lines, github for workflow commands")
+		verbose = fs.Bool("v", false, "Stream test output")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft run [flags] [packages]\n\n")
+		fmt.Fprintf(os.Stderr, "Run builds the tests in packages with -tags=detsched and explores their\nschedules, reporting a trace for each failing one.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	switch *notes {
+	case "", wefttest.AnnotatePlain, wefttest.AnnotateGitHub:
+	default:
+		return fmt.Errorf("unknown -annotate format %q; want plain or github", *notes)
+	}
+
+	pkgs, err := listPackages(patterns)
+	if err != nil {
+		return err
+	}
+	work, err := os.MkdirTemp("", "weft-run-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	var stream io.Writer
+	if *verbose && !*jsonOut {
+		stream = os.Stdout
+	}
+	testArgs := []string{"-test.count=1"}
+	if *run != "" {
+		testArgs = append(testArgs, "-test.run="+*run)
+	}
+
+	var results []*packageResult
+	failed := false
+	for _, p := range pkgs {
+		if err := p.build(work, *tags); err != nil {
+			return err
+		}
+		dir := filepath.Join(work, pathName(p.ImportPath)+".traces")
+		if *traces != "" {
+			dir = filepath.Join(*traces, pathName(p.ImportPath))
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		env := []string{
+			wefttest.EnvRuns + "=" + strconv.Itoa(*runs),
+			wefttest.EnvTraceDir + "=" + dir,
+		}
+		if *cover != "" {
+			env = append(env, wefttest.EnvCoverDir+"="+*cover)
+		}
+		out, passed, err := p.run(env, stream, testArgs...)
+		if err != nil {
+			return fmt.Errorf("running %s: %v", p.ImportPath, err)
+		}
+		res := &packageResult{Package: p.ImportPath, Passed: passed}
+		if !passed {
+			failed = true
+			res.Output = string(out)
+		}
+		if res.Failures, err = collectTraces(dir, p.ImportPath, *traces != ""); err != nil {
+			return err
+		}
+		results = append(results, res)
+		if !*jsonOut {
+			printResult(res)
+		}
+		if *notes != "" {
+			w := os.Stdout
+			if *jsonOut {
+				w = os.Stderr
+			}
+			printAnnotations(w, res, *notes)
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// collectTraces reads the traces written to dir, recording pkg in each.
+// Kept traces are rewritten with the package filled in so they can be
+// replayed directly.
+func collectTraces(dir, pkg string, keep bool) ([]failure, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+trace.Ext))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	var failures []failure
+	for _, name := range names {
+		tr, err := trace.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		tr.Package = pkg
+		f := failure{Trace: tr}
+		if keep {
+			if err := tr.WriteFile(name); err != nil {
+				return nil, err
+			}
+			f.File = name
+		}
+		failures = append(failures, f)
+	}
+	return failures, nil
+}
+
+// printResult prints a package result in the style of go test.
+func printResult(res *packageResult) {
+	if res.Passed {
+		fmt.Printf("ok  \t%s\n", res.Package)
+		return
+	}
+	if len(res.Failures) == 0 {
+		// A failure outside Explore, such as a build-time panic.
+		os.Stdout.WriteString(res.Output)
+	}
+	for _, f := range res.Failures {
+		fmt.Printf("--- FAIL: %s seed %d: %s\n", f.Trace.Test, f.Trace.Seed, f.Trace.Failure)
+		if f.File != "" {
+			fmt.Printf("    trace: %s\n", f.File)
+		}
+	}
+	fmt.Printf("FAIL\t%s\n", res.Package)
+}
+
+// printAnnotations prints an annotation in format for each failure of res,
+// with the command that replays it. Plain annotations name files relative
+// to the working directory; GitHub ones are made relative to the workspace
+// by Annotation.Format.
+func printAnnotations(w io.Writer, res *packageResult, format string) {
+	wd, _ := os.Getwd()
+	for _, f := range res.Failures {
+		tr := f.Trace
+		pos := tr.Location
+		if rel, err := filepath.Rel(wd, pos); err == nil && pos != "" && format == wefttest.AnnotatePlain && !strings.HasPrefix(rel, "..") {
+			pos = rel
+		}
+		replay := fmt.Sprintf("weft replay -seed %d -test %s %s", tr.Seed, tr.Test, res.Package)
+		if f.File != "" {
+			replay = "weft replay " + f.File
+		}
+		a := wefttest.Annotation{Pos: pos, Test: tr.Test, Seed: tr.Seed, Message: tr.Failure, Replay: replay}
+		fmt.Fprintln(w, a.Format(format))
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mziter/weft/trace"
+	"github.com/mziter/weft/wefttest"
+)
+
+func shrinkCmd(args []string) error {
+	fs := flag.NewFlagSet("shrink", flag.ExitOnError)
+	var (
+		output  = fs.String("o", "", "Write the shrunk trace to this file instead of standard output")
+		tags    = fs.String("tags", "", "Additional comma-separated build tags")
+		verbose = fs.Bool("v", false, "Report progress")
+		goLit   = fs.Bool("go", false, "Print the shrunk choices as a wefttest.ReplayChoices call to paste into a test")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft shrink [flags] trace.wefttrace\n\n")
+		fmt.Fprintf(os.Stderr, "Shrink removes and simplifies scheduling decisions from a failing trace\nwhile it still fails, and prints the result as a trace, or with -go as Go\nsource pinning the interleaving in a regression test.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	orig, err := trace.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if orig.Package == "" {
+		return fmt.Errorf("%s does not record its package", fs.Arg(0))
+	}
+	p, cleanup, err := buildOne(orig.Package, *tags)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if *goLit {
+		// ReplayChoices makes the decisions past its choices as if by
+		// seed 0, so shrink under seed 0. The recorded choices cover
+		// every decision, so the original run does not change.
+		orig.Seed = 0
+	}
+	if ok, err := p.fails(orig); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("%s does not reproduce a failure", fs.Arg(0))
+	}
+	res, err := shrinkTrace(p, orig, *verbose)
+	if err != nil {
+		return err
+	}
+	if *goLit {
+		var buf bytes.Buffer
+		if err := writeGoChoices(&buf, res); err != nil {
+			return err
+		}
+		if *output != "" {
+			return os.WriteFile(*output, buf.Bytes(), 0o644)
+		}
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if *output != "" {
+		return res.WriteFile(*output)
+	}
+	return res.Write(os.Stdout)
+}
+
+// writeGoChoices writes the choices of tr as a wefttest.ReplayChoices call
+// on build, the function the test passes to Explore, formatted as Go.
+func writeGoChoices(w io.Writer, tr *trace.Trace) error {
+	var src strings.Builder
+	fmt.Fprintf(&src, "// Shrunk failing schedule of %s, found by weft shrink.\n", tr.Test)
+	if failure := strings.Join(strings.Fields(tr.Failure), " "); failure != "" {
+		fmt.Fprintf(&src, "// It failed with: %s\n", failure)
+	}
+	src.WriteString("wefttest.ReplayChoices(t, []int{")
+	for i, c := range tr.Choices {
+		if i%20 == 0 && len(tr.Choices) > 20 {
+			src.WriteString("\n")
+		}
+		fmt.Fprintf(&src, "%d,", c)
+	}
+	if len(tr.Choices) > 20 {
+		src.WriteString("\n")
+	}
+	src.WriteString("}, build)\n")
+
+	// Format the call as the body of a function, then strip the wrapper.
+	const prefix, suffix = "package p\nfunc _() {\n", "}\n"
+	out, err := format.Source([]byte(prefix + src.String() + suffix))
+	if err != nil {
+		return err
+	}
+	_, body, _ := strings.Cut(string(out), "func _() {\n")
+	body = strings.TrimSuffix(body, "}\n")
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if _, err := io.WriteString(w, strings.TrimPrefix(line, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shrinkTrace returns a copy of the failing trace orig with its choices
+// shrunk by replaying candidates in p.
+func shrinkTrace(p *testPackage, orig *trace.Trace, verbose bool) (*trace.Trace, error) {
+	runs := 0
+	choices, err := shrink(orig.Choices, func(choices []int) (bool, error) {
+		runs++
+		tr := *orig
+		tr.Choices = choices
+		return p.fails(&tr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "shrunk %d choices to %d in %d runs\n", len(orig.Choices), len(choices), runs)
+	}
+	res := *orig
+	res.Choices = choices
+	return &res, nil
+}
+
+// fails reports whether replaying tr in p fails.
+func (p *testPackage) fails(tr *trace.Trace) (bool, error) {
+	candidate := filepath.Join(filepath.Dir(p.binary), "candidate"+trace.Ext)
+	if err := tr.WriteFile(candidate); err != nil {
+		return false, err
+	}
+	_, passed, err := p.run([]string{wefttest.EnvTrace + "=" + candidate}, nil,
+		"-test.count=1", "-test.run="+testPattern(tr.Test))
+	return !passed, err
+}
+
+// shrink returns a smaller choice sequence for which fails still reports a
+// failure. It removes ever smaller chunks of decisions, then replaces the
+// remaining decisions with 0, the first runnable option.
+func shrink(choices []int, fails func([]int) (bool, error)) ([]int, error) {
+	choices = append([]int(nil), choices...)
+	for chunk := len(choices) / 2; chunk >= 1; {
+		removed := false
+		for i := 0; i+chunk <= len(choices); {
+			cand := append(append([]int(nil), choices[:i]...), choices[i+chunk:]...)
+			ok, err := fails(cand)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				choices, removed = cand, true
+				continue
+			}
+			i += chunk
+		}
+		if !removed {
+			chunk /= 2
+		}
+	}
+	for i, c := range choices {
+		if c == 0 {
+			continue
+		}
+		choices[i] = 0
+		ok, err := fails(choices)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			choices[i] = c
+		}
+	}
+	return choices, nil
+}
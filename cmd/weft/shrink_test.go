@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestShrink verifies that shrinking keeps only the decisions a failure
+// depends on and simplifies the rest.
+func TestShrink(t *testing.T) {
+	// The failure needs a 2 followed later by a 3.
+	fails := func(choices []int) (bool, error) {
+		seen2 := false
+		for _, c := range choices {
+			if c == 2 {
+				seen2 = true
+			}
+			if c == 3 && seen2 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	got, err := shrink([]int{1, 0, 2, 1, 1, 4, 3, 1, 0}, fails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shrink = %v, want %v", got, want)
+	}
+}
+
+// TestTestPattern verifies that test names are matched exactly.
+func TestTestPattern(t *testing.T) {
+	for name, want := range map[string]string{
+		"TestQueue":       "^TestQueue$",
+		"TestQueue/drain": "^TestQueue$/^drain$",
+		"TestA(1)":        `^TestA\(1\)$`,
+	} {
+		if got := testPattern(name); got != want {
+			t.Errorf("testPattern(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestWriteGoChoices verifies that shrunk choices print as a formatted
+// ReplayChoices call, wrapped when long.
+func TestWriteGoChoices(t *testing.T) {
+	var buf bytes.Buffer
+	tr := &trace.Trace{Test: "TestQueue", Choices: []int{1, 0, 2}, Failure: "panic: lost\nupdate"}
+	if err := writeGoChoices(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	want := `// Shrunk failing schedule of TestQueue, found by weft shrink.
+// It failed with: panic: lost update
+wefttest.ReplayChoices(t, []int{1, 0, 2}, build)
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	tr = &trace.Trace{Test: "TestQueue", Choices: make([]int, 25)}
+	if err := writeGoChoices(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || lines[1] != "wefttest.ReplayChoices(t, []int{" || lines[4] != "}, build)" {
+		t.Errorf("long choices not wrapped:\n%s", buf.String())
+	}
+}
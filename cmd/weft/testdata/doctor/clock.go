@@ -0,0 +1,18 @@
+//go:build detsched
+
+package doctor
+
+import (
+	"sync"
+
+	"github.com/mziter/weft"
+)
+
+var mu sync.Mutex
+
+// Tick waits for one tick of virtual time.
+func Tick() {
+	mu.Lock()
+	defer mu.Unlock()
+	weft.Sleep(1)
+}
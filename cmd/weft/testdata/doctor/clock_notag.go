@@ -0,0 +1,8 @@
+//go:build !detsched
+
+package doctor
+
+import "github.com/yourusername/weft"
+
+// Now returns the current time.
+func Now() int64 { return weft.Now() }
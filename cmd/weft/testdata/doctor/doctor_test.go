@@ -0,0 +1,21 @@
+package doctor
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/wefttest"
+)
+
+func TestTick(t *testing.T) {
+	wefttest.Explore(t, 10, func(s *weft.Scheduler) {
+		start := time.Now()
+		go Tick()
+		for k := range map[int]bool{1: true, 2: true} {
+			_ = k + rand.Intn(3)
+		}
+		_ = start
+	})
+}
@@ -0,0 +1,7 @@
+module example.com/doctor
+
+go 1.22.0
+
+require github.com/mziter/weft v0.0.0
+
+replace github.com/mziter/weft => ../../../..
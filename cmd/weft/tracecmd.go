@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mziter/weft/trace"
+)
+
+// traceCmd dispatches the weft trace subcommands, which inspect trace files.
+func traceCmd(args []string) error {
+	if len(args) < 1 {
+		traceUsage()
+		os.Exit(2)
+	}
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "view":
+		return viewCmd(args)
+	case "export":
+		return exportCmd(args)
+	case "diff":
+		return diffCmd(args)
+	default:
+		fmt.Fprintf(os.Stderr, "weft trace: unknown command %q\n", cmd)
+		traceUsage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+func traceUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: weft trace <command> [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  view     render a trace as an interactive HTML timeline\n")
+	fmt.Fprintf(os.Stderr, "  export   write a trace as a sequence diagram or Perfetto trace\n")
+	fmt.Fprintf(os.Stderr, "  diff     compare a failing trace with the closest passing one\n")
+}
+
+func exportCmd(args []string) error {
+	fs := flag.NewFlagSet("trace export", flag.ExitOnError)
+	var (
+		format = fs.String("format", "mermaid", "Output format: mermaid, dot, or chrome for Perfetto and chrome://tracing")
+		output = fs.String("o", "", "Write the output to this file instead of standard output")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft trace export [flags] trace.wefttrace\n\n")
+		fmt.Fprintf(os.Stderr, "Export writes a trace as a sequence diagram with one lifeline per task\nand arrows for spawns, channel handoffs, lock handoffs and signals, or\nwith -format chrome as a trace event file for ui.perfetto.dev.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var write func(io.Writer, *trace.Trace) error
+	switch *format {
+	case "mermaid":
+		write = trace.WriteMermaid
+	case "dot":
+		write = trace.WriteDOT
+	case "chrome", "perfetto":
+		write = trace.WriteChrome
+	default:
+		return fmt.Errorf("unknown format %q; want mermaid, dot or chrome", *format)
+	}
+	tr, err := trace.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return write(os.Stdout, tr)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(f, tr); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mziter/weft/trace"
+)
+
+//go:embed view.html
+var viewHTML string
+
+var viewTemplate = template.Must(template.New("view").Parse(viewHTML))
+
+// Task states shown on the timeline.
+const (
+	stateReady   = "ready"
+	stateRunning = "running"
+	stateBlocked = "blocked"
+)
+
+// timeline is a trace laid out as one lane per task.
+type timeline struct {
+	Title   string
+	Failure string
+	Steps   int
+	Lanes   []*lane
+	Events  []trace.Event
+}
+
+// lane is the history of one task.
+type lane struct {
+	Task     int
+	Name     string
+	Segments []segment
+}
+
+// segment is a span of steps [Start, End) a task spent in one state.
+type segment struct {
+	Start, End int
+	State      string
+}
+
+func viewCmd(args []string) error {
+	fs := flag.NewFlagSet("trace view", flag.ExitOnError)
+	output := fs.String("o", "", "Write the HTML to this file instead of trace.html next to the trace")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weft trace view [flags] trace.wefttrace\n\n")
+		fmt.Fprintf(os.Stderr, "View renders a trace as a self-contained HTML timeline with one lane\nper task. Click an event to see its details and stack.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	tr, err := trace.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	name := *output
+	if name == "" {
+		name = strings.TrimSuffix(fs.Arg(0), filepath.Ext(fs.Arg(0))) + ".html"
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := renderView(f, tr); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Println(name)
+	return nil
+}
+
+// renderView writes the HTML timeline of tr to w.
+func renderView(w io.Writer, tr *trace.Trace) error {
+	return viewTemplate.Execute(w, newTimeline(tr))
+}
+
+// newTimeline lays out the events of tr. Tasks are ready from the step they
+// are spawned, running from the step they are scheduled or act, and blocked
+// between Block and Unblock. Only one task runs at a time.
+func newTimeline(tr *trace.Trace) *timeline {
+	tl := &timeline{
+		Title:   fmt.Sprintf("%s seed %d", tr.Test, tr.Seed),
+		Failure: tr.Failure,
+		Steps:   len(tr.Events),
+		Events:  tr.Events,
+	}
+	if tr.Package != "" {
+		tl.Title = tr.Package + " " + tl.Title
+	}
+
+	lanes := make(map[int]*lane)
+	// open holds the state and start step of each live task.
+	open := make(map[int]segment)
+	running := -1
+
+	laneOf := func(task int) *lane {
+		l, ok := lanes[task]
+		if !ok {
+			name := fmt.Sprintf("task %d", task)
+			if task == 0 {
+				name = "test"
+			}
+			l = &lane{Task: task, Name: name}
+			lanes[task] = l
+			tl.Lanes = append(tl.Lanes, l)
+		}
+		return l
+	}
+	set := func(task int, state string, step int) {
+		if cur, ok := open[task]; ok {
+			if cur.State == state {
+				return
+			}
+			if step > cur.Start {
+				l := laneOf(task)
+				l.Segments = append(l.Segments, segment{Start: cur.Start, End: step, State: cur.State})
+			}
+		}
+		if state == "" {
+			delete(open, task)
+			return
+		}
+		open[task] = segment{Start: step, State: state}
+	}
+	run := func(task int, step int) {
+		if running == task {
+			return
+		}
+		if cur, ok := open[running]; ok && cur.State == stateRunning {
+			set(running, stateReady, step)
+		}
+		running = task
+		set(task, stateRunning, step)
+	}
+
+	for _, ev := range tr.Events {
+		laneOf(ev.Task)
+		switch ev.Kind {
+		case trace.Block:
+			run(ev.Task, ev.Step)
+			set(ev.Task, stateBlocked, ev.Step+1)
+			running = -1
+		case trace.Unblock:
+			set(ev.Task, stateReady, ev.Step)
+		case trace.Exit:
+			set(ev.Task, "", ev.Step+1)
+			if running == ev.Task {
+				running = -1
+			}
+		case trace.Kill:
+			run(ev.Task, ev.Step)
+			set(ev.Peer, "", ev.Step+1)
+		default:
+			run(ev.Task, ev.Step)
+			if ev.Kind == trace.Spawn && ev.Peer != 0 {
+				laneOf(ev.Peer)
+				set(ev.Peer, stateReady, ev.Step+1)
+			}
+		}
+	}
+	for task := range open {
+		set(task, "", tl.Steps)
+	}
+	sort.Slice(tl.Lanes, func(i, j int) bool { return tl.Lanes[i].Task < tl.Lanes[j].Task })
+	return tl
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mziter/weft/trace"
+)
+
+// testTrace is a trace in which task 2 blocks on a mutex held by task 1.
+var testTrace = &trace.Trace{
+	Test: "TestBank",
+	Seed: 7,
+	Events: []trace.Event{
+		{Step: 0, Task: 0, Kind: trace.Spawn, Peer: 1},
+		{Step: 1, Task: 0, Kind: trace.Spawn, Peer: 2},
+		{Step: 2, Task: 1, Kind: trace.Lock, Object: "mutex 1"},
+		{Step: 3, Task: 2, Kind: trace.Block, Object: "mutex 1"},
+		{Step: 4, Task: 1, Kind: trace.Unlock, Object: "mutex 1", Peer: 2},
+		{Step: 5, Task: 2, Kind: trace.Unblock},
+		{Step: 6, Task: 1, Kind: trace.Exit},
+		{Step: 7, Task: 2, Kind: trace.Lock, Object: "mutex 1", Stack: []string{"bank.Transfer bank.go:12"}},
+		{Step: 8, Task: 2, Kind: trace.Exit},
+	},
+}
+
+// TestTimeline verifies the state of each task over the steps of a trace.
+func TestTimeline(t *testing.T) {
+	tl := newTimeline(testTrace)
+	want := map[string][]segment{
+		"test": {{0, 2, stateRunning}, {2, 9, stateReady}},
+		"task 1": {
+			{1, 2, stateReady},
+			{2, 3, stateRunning},
+			{3, 4, stateReady},
+			{4, 7, stateRunning},
+		},
+		"task 2": {
+			{2, 3, stateReady},
+			{3, 4, stateRunning},
+			{4, 5, stateBlocked},
+			{5, 7, stateReady},
+			{7, 9, stateRunning},
+		},
+	}
+	if len(tl.Lanes) != len(want) {
+		t.Fatalf("got %d lanes, want %d", len(tl.Lanes), len(want))
+	}
+	for _, l := range tl.Lanes {
+		if !reflect.DeepEqual(l.Segments, want[l.Name]) {
+			t.Errorf("%s segments:\ngot  %v\nwant %v", l.Name, l.Segments, want[l.Name])
+		}
+	}
+}
+
+// TestRenderView verifies that the page embeds the trace data.
+func TestRenderView(t *testing.T) {
+	var buf bytes.Buffer
+	if err := renderView(&buf, testTrace); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"TestBank seed 7", `"bank.Transfer bank.go:12"`, `"kind":"block"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+}
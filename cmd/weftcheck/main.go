@@ -0,0 +1,16 @@
+// Command weftcheck reports code that escapes the weft scheduler. It runs
+// standalone on package patterns or as a go vet tool:
+//
+//	weftcheck ./...
+//	go vet -vettool=$(which weftcheck) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/multichecker"
+
+	"github.com/mziter/weft/weftcheck"
+)
+
+func main() {
+	multichecker.Main(weftcheck.Analyzer, weftcheck.BlockingAnalyzer, weftcheck.CopyAnalyzer)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/mziter/weft/internal/ignore"
+)
+
+// loadOptions selects which files loadFiles returns.
+type loadOptions struct {
+	// dir is the directory patterns are resolved in, the current one if
+	// empty.
+	dir string
+
+	// tests includes _test.go files and external test packages.
+	tests bool
+
+	// tags is a comma-separated list of build tags to apply.
+	tags string
+
+	// generated includes files carrying a "Code generated ... DO NOT EDIT."
+	// header.
+	generated bool
+}
+
+// sourceFile is a file to convert along with the package it was loaded in.
+type sourceFile struct {
+	path   string
+	pkg    *packages.Package
+	syntax *ast.File
+
+	// ignored is set when the package doc comment carries a //weft:ignore
+	// directive.
+	ignored bool
+}
+
+// loadFiles loads the packages matching patterns and returns the files to
+// convert, in path order. Packages outside the main module and files under a
+// vendor directory are skipped, as are generated files unless requested.
+func loadFiles(patterns []string, opts loadOptions) ([]*sourceFile, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedSyntax |
+			packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedModule,
+		Dir:   opts.dir,
+		Tests: opts.tests,
+	}
+	if opts.tags != "" {
+		cfg.BuildFlags = []string{"-tags=" + opts.tags}
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, e := range pkg.Errors {
+			errs = append(errs, e.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("loading packages:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+
+	seen := make(map[string]bool)
+	var files []*sourceFile
+	for _, pkg := range pkgs {
+		if pkg.Module == nil || !pkg.Module.Main {
+			continue
+		}
+		ignored := ignore.Package(pkg.Syntax)
+		for _, f := range pkg.Syntax {
+			path := pkg.Fset.Position(f.Pos()).Filename
+			if seen[path] || !inDir(path, pkg.Module.Dir) || isVendored(path) {
+				continue
+			}
+			if !opts.generated && ast.IsGenerated(f) {
+				continue
+			}
+			// With tests enabled, a package's files appear both in the
+			// package and in its test variant; the first occurrence wins.
+			seen[path] = true
+			files = append(files, &sourceFile{path: path, pkg: pkg, syntax: f, ignored: ignored})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// inDir reports whether path lies within dir. Files synthesized by the go
+// command, such as test mains, live in the build cache and fail this check.
+func inDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isVendored reports whether path lies under a vendor directory.
+func isVendored(path string) bool {
+	for _, elem := range strings.Split(filepath.ToSlash(path), "/") {
+		if elem == "vendor" {
+			return true
+		}
+	}
+	return false
+}
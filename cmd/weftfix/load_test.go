@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree writes files, keyed by slash-separated paths relative to dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestLoadFiles verifies which files of a module tree loadFiles returns:
+// those of the main module only, outside vendor directories, with tests,
+// generated files and tagged files only when asked for.
+func TestLoadFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"m/go.mod":                 "module example.com/m\n\ngo 1.22\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ../dep\n",
+		"m/a/a.go":                 "package a\n\nimport _ \"example.com/dep\"\n",
+		"m/a/a_test.go":            "package a\n",
+		"m/a/x_test.go":            "package a_test\n",
+		"m/a/gen.go":               "// Code generated by stringer. DO NOT EDIT.\n\npackage a\n",
+		"m/a/tagged.go":            "//go:build special\n\npackage a\n",
+		"m/internal/vendor/v/v.go": "package v\n",
+		"m/sub/go.mod":             "module example.com/m/sub\n\ngo 1.22\n",
+		"m/sub/sub.go":             "package sub\n",
+		"dep/go.mod":               "module example.com/dep\n\ngo 1.22\n",
+		"dep/dep.go":               "package dep\n",
+	})
+	dir := filepath.Join(root, "m")
+
+	tests := []struct {
+		name     string
+		patterns []string
+		opts     loadOptions
+		want     []string
+	}{
+		{
+			// The nested module in sub is not part of ./... at all.
+			name:     "default",
+			patterns: []string{"./..."},
+			want:     []string{"a/a.go"},
+		},
+		{
+			name:     "dependency in another module",
+			patterns: []string{"./a", "example.com/dep"},
+			want:     []string{"a/a.go"},
+		},
+		{
+			name:     "vendor directory",
+			patterns: []string{"./a", "./internal/vendor/v"},
+			want:     []string{"a/a.go"},
+		},
+		{
+			name:     "tests",
+			patterns: []string{"./a"},
+			opts:     loadOptions{tests: true},
+			want:     []string{"a/a.go", "a/a_test.go", "a/x_test.go"},
+		},
+		{
+			name:     "generated",
+			patterns: []string{"./a"},
+			opts:     loadOptions{generated: true},
+			want:     []string{"a/a.go", "a/gen.go"},
+		},
+		{
+			name:     "tags",
+			patterns: []string{"./a"},
+			opts:     loadOptions{tags: "special"},
+			want:     []string{"a/a.go", "a/tagged.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.dir = dir
+			files, err := loadFiles(tt.patterns, opts)
+			if err != nil {
+				t.Fatalf("loadFiles: %v", err)
+			}
+			var got []string
+			for _, f := range files {
+				rel, err := filepath.Rel(dir, f.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadFiles(%q) = %q, want %q", tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
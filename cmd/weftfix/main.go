@@ -3,58 +3,240 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mziter/weft/internal/codemod"
+	"github.com/mziter/weft/internal/ignore"
 )
 
 // weftfix is a codemod tool for converting standard Go concurrency
 // primitives to their weft equivalents.
 
 func main() {
+	os.Exit(run("", os.Args[1:]))
+}
+
+// run runs weftfix with the command-line arguments args, resolving package
+// patterns in dir, or the current directory if empty, and returns the exit
+// status.
+func run(dir string, args []string) int {
+	flags := flag.NewFlagSet("weftfix", flag.ExitOnError)
 	var (
-		dryRun  = flag.Bool("dry-run", false, "Show what would be changed without modifying files")
-		path    = flag.String("path", ".", "Path to directory or file to process")
-		verbose = flag.Bool("v", false, "Verbose output")
-		reverse = flag.Bool("reverse", false, "Convert weft primitives back to standard library")
+		dryRun    = flags.Bool("dry-run", false, "Show what would be changed without modifying files")
+		check     = flags.Bool("check", false, "Report unconverted concurrency without modifying files; exit 1 if any is left to convert")
+		path      = flags.String("path", "", "Path to directory or file to process (alternative to package patterns)")
+		verbose   = flags.Bool("v", false, "Verbose output")
+		reverse   = flags.Bool("reverse", false, "Convert weft primitives back to standard library")
+		split     = flags.Bool("split", false, "Keep each original file under !detsched and write the converted code to a _detsched.go file")
+		tests     = flags.Bool("tests", false, "Also convert _test.go files")
+		tags      = flags.String("tags", "", "Comma-separated build tags selecting the files to convert")
+		generated = flags.Bool("generated", false, "Also convert generated files")
 	)
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: weftfix [options]\n\n")
-		fmt.Fprintf(os.Stderr, "weftfix converts standard Go concurrency primitives to weft equivalents.\n\n")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: weftfix [options] [packages]\n\n")
+		fmt.Fprintf(os.Stderr, "weftfix converts standard Go concurrency primitives to weft equivalents.\n")
+		fmt.Fprintf(os.Stderr, "Imports of golang.org/x/sync/errgroup, semaphore and singleflight are\nswitched to the weft packages of the same name.\n")
+		fmt.Fprintf(os.Stderr, "Packages are named with go list patterns and default to ./... ; only\n")
+		fmt.Fprintf(os.Stderr, "packages in the main module are converted, and vendored and generated\nfiles are skipped.\n")
+		fmt.Fprintf(os.Stderr, "Constructs that cannot be converted safely are reported and left unchanged.\n")
+		fmt.Fprintf(os.Stderr, "A //weft:ignore comment on a statement, declaration, file or package\ndoc comment leaves that code unconverted.\n\n")
+		fmt.Fprintf(os.Stderr, "With --check, weftfix exits with status 0 if nothing is left to convert,\n1 if it would convert some code, and 2 if packages cannot be loaded.\nConstructs it cannot convert are reported but do not fail the check.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
+		flags.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
-		fmt.Fprintf(os.Stderr, "  weftfix --dry-run --path ./pkg  # Preview changes in ./pkg\n")
-		fmt.Fprintf(os.Stderr, "  weftfix --path ./cmd/myapp      # Apply changes to ./cmd/myapp\n")
-		fmt.Fprintf(os.Stderr, "  weftfix --reverse --path .      # Convert back to stdlib\n")
+		fmt.Fprintf(os.Stderr, "  weftfix --dry-run ./pkg/...     # Preview changes in ./pkg\n")
+		fmt.Fprintf(os.Stderr, "  weftfix --check ./pkg/...       # Fail CI if ./pkg is not fully converted\n")
+		fmt.Fprintf(os.Stderr, "  weftfix ./cmd/myapp             # Apply changes to ./cmd/myapp\n")
+		fmt.Fprintf(os.Stderr, "  weftfix --tests --tags linux    # Include tests and linux-only files\n")
+		fmt.Fprintf(os.Stderr, "  weftfix --split ./pkg           # Write converted copies as foo_detsched.go\n")
+		fmt.Fprintf(os.Stderr, "  weftfix --reverse ./...         # Convert back to stdlib\n")
+	}
+
+	flags.Parse(args)
+
+	if *reverse {
+		fmt.Fprintln(os.Stderr, "weftfix: --reverse is not yet implemented")
+		return 2
+	}
+
+	// errorExit is the status for failures other than unconverted code,
+	// kept apart from it in check mode.
+	errorExit := 1
+	if *check {
+		errorExit = 2
 	}
 
-	flag.Parse()
+	patterns := flags.Args()
+	if *path != "" {
+		p, err := pathPattern(*path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "weftfix: %v\n", err)
+			return errorExit
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
 
 	if *verbose {
-		fmt.Printf("weftfix - Processing path: %s\n", *path)
-		if *dryRun {
+		fmt.Printf("weftfix - Processing: %s\n", strings.Join(patterns, " "))
+		switch {
+		case *check:
+			fmt.Println("Running in check mode (no files will be modified)")
+		case *dryRun:
 			fmt.Println("Running in dry-run mode (no files will be modified)")
 		}
 	}
 
-	// TODO: Implement the actual codemod logic
-	// This would involve:
-	// 1. Walking the file tree
-	// 2. Parsing Go source files
-	// 3. Identifying standard library concurrency primitives
-	// 4. Replacing them with weft equivalents
-	// 5. Updating imports
-	// 6. Writing the modified files (unless dry-run)
-
-	fmt.Println("weftfix: Not yet implemented")
-	fmt.Println("This tool will convert:")
-	fmt.Println("  - go func() {...} → weft.Go(func(ctx weft.Context) {...})")
-	fmt.Println("  - time.Sleep(...) → weft.Sleep(...)")
-	fmt.Println("  - time.After(...) → weft.After(...)")
-	fmt.Println("  - sync.Mutex → weft.Mutex")
-	fmt.Println("  - sync.RWMutex → weft.RWMutex")
-	fmt.Println("  - sync.Cond → weft.Cond")
-	fmt.Println("  - make(chan T, n) → weft.MakeChan[T](n)")
-
-	os.Exit(1)
-}
\ No newline at end of file
+	files, err := loadFiles(patterns, loadOptions{dir: dir, tests: *tests, tags: *tags, generated: *generated})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "weftfix: %v\n", err)
+		return errorExit
+	}
+
+	failed, pending := false, false
+	opts := options{dryRun: *dryRun, check: *check, verbose: *verbose, split: *split}
+	for _, file := range files {
+		if file.ignored {
+			if *verbose {
+				fmt.Printf("skipping %s (package marked %s)\n", file.path, ignore.Directive)
+			}
+			continue
+		}
+		remains, err := processFile(file, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "weftfix: %v\n", err)
+			failed = true
+		}
+		pending = pending || remains
+	}
+	switch {
+	case failed:
+		return errorExit
+	case *check && pending:
+		return 1
+	}
+	return 0
+}
+
+// pathPattern turns the --path flag into a package pattern: a directory
+// selects every package beneath it and a file selects its own package.
+func pathPattern(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "file=" + path, nil
+	}
+	pattern := filepath.ToSlash(filepath.Join(path, "..."))
+	if !filepath.IsAbs(path) && !strings.HasPrefix(pattern, ".") {
+		pattern = "./" + pattern
+	}
+	return pattern, nil
+}
+
+// options controls how processFile writes its results.
+type options struct {
+	dryRun  bool
+	verbose bool
+
+	// check reports what remains to convert, to standard output, without
+	// writing anything.
+	check bool
+
+	// split keeps the original file under a !detsched constraint and
+	// writes the converted code to a paired _detsched.go file.
+	split bool
+}
+
+// processFile rewrites a single file, reporting anything left unconverted.
+// It reports whether the file still needs conversion: whether, in check
+// mode, it would rewrite any of it. Constructs it cannot convert are
+// reported but do not count, since no run of weftfix would change them.
+func processFile(file *sourceFile, opts options) (remains bool, err error) {
+	path := file.path
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if opts.split && codemod.HasTag(src) {
+		// Already one half of a split pair.
+		return false, nil
+	}
+	res, err := codemod.File(file.pkg.Fset, file.syntax, src, file.pkg.Types, file.pkg.TypesInfo)
+	if err != nil {
+		return false, err
+	}
+	out := os.Stderr
+	if opts.check {
+		out = os.Stdout
+	}
+	for _, d := range res.Diagnostics {
+		fmt.Fprintln(out, d)
+	}
+	if !res.Changed {
+		return false, nil
+	}
+	if opts.check {
+		fmt.Printf("%s: needs conversion\n", path)
+		if opts.verbose {
+			os.Stdout.Write(res.Source)
+		}
+		return true, nil
+	}
+	if opts.split {
+		return false, splitFile(path, src, res.Source, info.Mode().Perm(), opts)
+	}
+	if opts.dryRun {
+		fmt.Printf("would rewrite %s\n", path)
+		if opts.verbose {
+			os.Stdout.Write(res.Source)
+		}
+		return false, nil
+	}
+	if opts.verbose {
+		fmt.Printf("rewrote %s\n", path)
+	}
+	return false, os.WriteFile(path, res.Source, info.Mode().Perm())
+}
+
+// splitFile keeps the original implementation at path under !detsched and
+// writes the converted implementation next to it under detsched.
+func splitFile(path string, orig, converted []byte, perm fs.FileMode, opts options) error {
+	detPath := codemod.DetschedName(path)
+	if _, err := os.Stat(detPath); err == nil {
+		return fmt.Errorf("%s already exists; not splitting %s", detPath, path)
+	}
+	notag, err := codemod.Constrain(orig, "!"+codemod.Tag)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	det, err := codemod.Constrain(converted, codemod.Tag)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if opts.dryRun {
+		fmt.Printf("would split %s into %s (!%s) and %s (%s)\n", path, path, codemod.Tag, detPath, codemod.Tag)
+		if opts.verbose {
+			os.Stdout.Write(det)
+		}
+		return nil
+	}
+	if opts.verbose {
+		fmt.Printf("split %s into %s\n", path, detPath)
+	}
+	if err := os.WriteFile(detPath, det, perm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, notag, perm)
+}
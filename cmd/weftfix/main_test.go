@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestCheckExit verifies the exit status of weftfix --check: 0 when
+// nothing is left to convert, even if constructs it cannot convert remain,
+// 1 when it would convert some code, and 2 when packages fail to load.
+func TestCheckExit(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":           "module example.com/m\n\ngo 1.22\n",
+		"plain/plain.go":   "package plain\n\nfunc F() int { return 1 }\n",
+		"waits/waits.go":   "package waits\n\nimport \"sync\"\n\nfunc F() {\n\tvar wg sync.WaitGroup\n\twg.Wait()\n}\n",
+		"spawns/spawns.go": "package spawns\n\nfunc F() {\n\tgo F()\n}\n",
+		"broken/broken.go": "package broken\n\nfunc F() int { return \"x\" }\n",
+	})
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"./plain", 0},
+		{"./waits", 0},
+		{"./spawns", 1},
+		{"./broken", 2},
+		{"./missing", 2},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.pattern), func(t *testing.T) {
+			if got := run(root, []string{"--check", tt.pattern}); got != tt.want {
+				t.Errorf("weftfix --check %s exited %d, want %d", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
@@ -9,27 +9,43 @@ import (
 // Cond implements a condition variable for deterministic testing.
 type Cond struct {
 	cond *scheduler.Cond
+
+	// self is the address of the Cond when it was created, to detect
+	// copies.
+	self *Cond
 }
 
 // NewCond returns a new Cond with the given Locker.
 func NewCond(l Locker) *Cond {
-	return &Cond{
+	c := &Cond{
 		cond: scheduler.NewCond(l),
 	}
+	c.self = c
+	return c
+}
+
+// check panics if c is a copy, which would share the original's waiters.
+func (c *Cond) check() {
+	if c.self != c {
+		panic("weft: Cond copied")
+	}
 }
 
 // Wait atomically unlocks the Locker and waits to be signaled.
 func (c *Cond) Wait() {
+	c.check()
 	c.cond.Wait()
 }
 
 // Signal wakes one goroutine waiting on the condition variable.
 func (c *Cond) Signal() {
+	c.check()
 	c.cond.Signal()
 }
 
 // Broadcast wakes all goroutines waiting on the condition variable.
 func (c *Cond) Broadcast() {
+	c.check()
 	c.cond.Broadcast()
 }
 
@@ -5,6 +5,21 @@ type Context interface {
 	// Yield voluntarily yields control to the scheduler.
 	Yield()
 
-	// Done returns a channel that's closed when the context is cancelled.
+	// Done returns a channel that's closed when the context is cancelled:
+	// by chaos, by the task's node crashing, or, under detsched and unless
+	// the task was started with GoDetached, by the context of the task
+	// that started it being cancelled.
 	Done() <-chan struct{}
+
+	// SetPriority sets the priority of the task, 0 unless set; tasks it
+	// starts from then on inherit it. Call it from the task itself. Under
+	// detsched it is recorded in the trace and, with
+	// Options.StrictPriority, a task runs only while no task of higher
+	// priority can. In production mode it does nothing.
+	SetPriority(p int)
+
+	// TaskID returns the ID of the calling task under detsched, as in
+	// trace events and Scheduler.Tasks, for tagging log lines; call it
+	// from the task itself. In production mode it returns 0.
+	TaskID() int
 }
\ No newline at end of file
@@ -20,5 +20,23 @@
 //	}
 //
 // The framework uses build tags to switch between deterministic and standard
-// implementations. Use -tags=detsched for deterministic mode.
+// implementations. Use -tags=detsched for deterministic mode, where the
+// package-level Go, Sleep and After run on the scheduler of the current
+// run, as bound by wefttest.Explore or Run, and panic outside one.
+//
+// Under detsched, the tasks of a scheduler take turns: one runs at a time,
+// from one scheduling point, such as a channel operation or a Lock, to its
+// next, and the seed then picks which of the tasks ready to run goes next.
+// A task blocked on a weft primitive hands the turn back until it is woken.
+// One blocked on plain Go synchronization, such as a Go channel or
+// ctx.Done(), keeps the turn and stalls the run, so code under test should
+// block only on weft's primitives; weftcontext.Done turns a context's done
+// channel into one. Turns are given only while the goroutine that spawned
+// the tasks waits in Wait or on a weft primitive, or steps them with
+// RunUntil or Step, so that where a run goes never depends on how long
+// anything took.
+//
+// Under detsched, the goroutine running each task carries the profile labels
+// weft_task and weft_seed, so profiles taken during long explorations can be
+// broken down by task with go tool pprof -tagfocus or -tagshow.
 package weft
\ No newline at end of file
@@ -0,0 +1,5 @@
+// Package errgroup is a drop-in replacement for golang.org/x/sync/errgroup
+// whose goroutines are weft tasks. Under -tags=detsched a Group is built on
+// weft primitives, so the deterministic scheduler explores its interleavings;
+// otherwise it is the x/sync Group itself.
+package errgroup
@@ -0,0 +1,143 @@
+//go:build detsched
+
+package errgroup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mziter/weft"
+)
+
+// A Group is a collection of tasks working on subtasks that are part of the
+// same overall task.
+//
+// A zero Group is valid, has no limit on the number of active tasks, and
+// does not cancel on error.
+type Group struct {
+	cancel func(error)
+
+	mu weft.Mutex
+
+	// cond is signaled when a task finishes. It is created on first use,
+	// so that the zero Group is valid.
+	cond *weft.Cond
+
+	active  int
+	limit   int
+	limited bool
+	err     error
+}
+
+// WithContext returns a new Group and an associated Context derived from ctx.
+//
+// The derived Context is canceled the first time a function passed to Go
+// returns a non-nil error or the first time Wait returns, whichever occurs
+// first.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// Wait blocks until all function calls from the Go method have returned, then
+// returns the first non-nil error (if any) from them.
+func (g *Group) Wait() error {
+	g.mu.Lock()
+	for g.active > 0 {
+		g.wait()
+	}
+	err := g.err
+	g.mu.Unlock()
+	if g.cancel != nil {
+		g.cancel(err)
+	}
+	return err
+}
+
+// Go calls the given function in a new task. It blocks until the new task
+// can be added without the number of active tasks in the group exceeding
+// the configured limit.
+//
+// The first call to return a non-nil error cancels the group's context, if
+// the group was created by calling WithContext. The error will be returned
+// by Wait.
+func (g *Group) Go(f func() error) {
+	g.mu.Lock()
+	for g.limited && g.active >= g.limit {
+		g.wait()
+	}
+	g.active++
+	g.mu.Unlock()
+	g.start(f)
+}
+
+// TryGo calls the given function in a new task only if the number of active
+// tasks in the group is currently below the configured limit.
+//
+// The return value reports whether the task was started.
+func (g *Group) TryGo(f func() error) bool {
+	g.mu.Lock()
+	if g.limited && g.active >= g.limit {
+		g.mu.Unlock()
+		return false
+	}
+	g.active++
+	g.mu.Unlock()
+	g.start(f)
+	return true
+}
+
+// SetLimit limits the number of active tasks in this group to at most n.
+// A negative value indicates no limit.
+//
+// Any subsequent call to the Go method will block until it can add an
+// active task without exceeding the configured limit.
+//
+// The limit must not be modified while any tasks in the group are active.
+func (g *Group) SetLimit(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if n < 0 {
+		g.limited = false
+		return
+	}
+	if g.limited && g.active != 0 {
+		panic(fmt.Errorf("errgroup: modify limit while %v goroutines in the group are still active", g.active))
+	}
+	g.limit, g.limited = n, true
+}
+
+// start runs f in a task counted as active.
+func (g *Group) start(f func() error) {
+	weft.Go(func(weft.Context) {
+		defer g.done()
+		if err := f(); err != nil {
+			g.mu.Lock()
+			if g.err == nil {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			}
+			g.mu.Unlock()
+		}
+	})
+}
+
+// done records that a task finished and wakes the callers waiting on it.
+func (g *Group) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+	if g.cond != nil {
+		g.cond.Broadcast()
+	}
+}
+
+// wait waits for a task to finish. The caller must hold g.mu.
+func (g *Group) wait() {
+	if g.cond == nil {
+		g.cond = weft.NewCond(&g.mu)
+	}
+	g.cond.Wait()
+}
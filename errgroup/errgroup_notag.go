@@ -0,0 +1,17 @@
+//go:build !detsched
+
+package errgroup
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Group is a standard errgroup.Group in production mode.
+type Group = errgroup.Group
+
+// WithContext returns a new Group and an associated Context derived from ctx.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	return errgroup.WithContext(ctx)
+}
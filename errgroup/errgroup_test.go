@@ -0,0 +1,65 @@
+package errgroup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+	"github.com/mziter/weft/wefttest"
+)
+
+// TestWithContext verifies that the first error is returned by Wait and
+// cancels the group's context, in both build modes.
+func TestWithContext(t *testing.T) {
+	weft.RunDefault(t, func() {
+		errFirst := errors.New("first")
+		g, ctx := WithContext(context.Background())
+		g.Go(func() error { return errFirst })
+		g.Go(func() error {
+			weftcontext.Done(ctx).Recv()
+			return errors.New("canceled")
+		})
+		if err := g.Wait(); err != errFirst {
+			t.Errorf("Wait() = %v, want %v", err, errFirst)
+		}
+		if ctx.Err() == nil {
+			t.Error("context not canceled")
+		}
+	})
+}
+
+// TestLimit verifies that no more tasks than the limit run at once.
+func TestLimit(t *testing.T) {
+	wefttest.Explore(t, 20, func(s *weft.Scheduler) {
+		var (
+			g              Group
+			mu             weft.Mutex
+			active, maxAct int
+		)
+		g.SetLimit(2)
+		for i := 0; i < 5; i++ {
+			g.Go(func() error {
+				mu.Lock()
+				active++
+				maxAct = max(maxAct, active)
+				mu.Unlock()
+				weft.Sleep(0)
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			t.Errorf("Wait() = %v", err)
+		}
+		if maxAct > 2 {
+			t.Errorf("%d tasks active at once, want at most 2", maxAct)
+		}
+		if g.TryGo(func() error { return nil }) {
+			g.Wait()
+		}
+	})
+}
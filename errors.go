@@ -0,0 +1,30 @@
+package weft
+
+import (
+	"errors"
+
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// Errors reported by Scheduler.Result and Scheduler.Wait, each wrapped with
+// details of the failure, so that harnesses can tell the outcomes of runs
+// apart with errors.Is rather than by their messages.
+var (
+	// ErrDeadlock reports a run whose unfinished tasks were all blocked on
+	// weft primitives, waiting on one another with no timer left to wake
+	// them, as Wait finds them.
+	ErrDeadlock = scheduler.ErrDeadlock
+
+	// ErrTaskLeak reports tasks still unfinished long after the test
+	// returned, though not deadlocked: some wait outside weft's view, such
+	// as on a Go channel.
+	ErrTaskLeak = errors.New("weft: tasks unfinished")
+
+	// ErrStepLimit reports a run that passed Options.MaxSteps; the task
+	// passing it panics with it.
+	ErrStepLimit = errors.New("weft: too many scheduling steps")
+
+	// ErrLinearizability reports a history of operations that no
+	// sequential order explains, as found by lincheck.Verify.
+	ErrLinearizability = errors.New("weft: history not linearizable")
+)
@@ -0,0 +1,132 @@
+// Package kv is a concurrent in-memory key-value store, and a cache in
+// front of it with a deliberate bug, whose tests record histories of
+// operations under weft and check them for linearizability with lincheck:
+// the end-to-end way to verify a concurrent object against its sequential
+// specification.
+package kv
+
+import (
+	"hash/fnv"
+
+	"github.com/mziter/weft"
+)
+
+// Store is a key-value store whose keys are spread over shards, each with
+// a lock of its own, so operations on different shards proceed in
+// parallel. Every operation is atomic.
+type Store struct {
+	shards []*shard
+}
+
+type shard struct {
+	mu weft.RWMutex
+	m  map[string]string
+}
+
+// New returns an empty store with n shards.
+func New(n int) *Store {
+	s := &Store{shards: make([]*shard, n)}
+	for i := range s.shards {
+		s.shards[i] = &shard{m: make(map[string]string)}
+	}
+	return s
+}
+
+// shard returns the shard holding key.
+func (s *Store) shard(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// Get returns the value of key, and whether it is present.
+func (s *Store) Get(key string) (string, bool) {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	v, ok := sh.m[key]
+	return v, ok
+}
+
+// Put sets the value of key.
+func (s *Store) Put(key, value string) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.m[key] = value
+}
+
+// Delete removes key.
+func (s *Store) Delete(key string) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	delete(sh.m, key)
+}
+
+// Append appends suffix to the value of key, an absent key counting as
+// empty, and returns the new value.
+func (s *Store) Append(key, suffix string) string {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.m[key] += suffix
+	return sh.m[key]
+}
+
+// CompareAndSwap sets the value of key to new if it is present with value
+// old, and reports whether it did.
+func (s *Store) CompareAndSwap(key, old, new string) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if v, ok := sh.m[key]; !ok || v != old {
+		return false
+	}
+	sh.m[key] = new
+	return true
+}
+
+// CachedStore serves reads of a Store from a cache. It is NOT linearizable:
+// a Get that misses reads the store and then fills the cache, and a Put
+// landing in between invalidates the cache before the fill, so the fill
+// caches the value the Put replaced, and later reads return it long after
+// the Put has returned.
+type CachedStore struct {
+	store *Store
+
+	mu    weft.Mutex
+	cache map[string]string
+}
+
+// NewCached returns an empty cached store with n shards.
+func NewCached(n int) *CachedStore {
+	return &CachedStore{store: New(n), cache: make(map[string]string)}
+}
+
+// Get returns the value of key from the cache, or from the store on a
+// miss.
+func (c *CachedStore) Get(key string) (string, bool) {
+	c.mu.Lock()
+	v, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		return v, true
+	}
+	v, ok = c.store.Get(key)
+	if ok {
+		// BUG: a Put may have invalidated the cache since the read.
+		c.mu.Lock()
+		c.cache[key] = v
+		c.mu.Unlock()
+	}
+	return v, ok
+}
+
+// Put sets the value of key and invalidates its cached value.
+func (c *CachedStore) Put(key, value string) {
+	c.store.Put(key, value)
+	c.mu.Lock()
+	delete(c.cache, key)
+	c.mu.Unlock()
+}
@@ -0,0 +1,175 @@
+package kv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/lincheck"
+	"github.com/mziter/weft/wefttest"
+)
+
+// input is an operation on a key-value store, as recorded in a history.
+type input struct {
+	Op         string // "get", "put", "delete", "append" or "cas"
+	Key        string
+	Value, Old string
+}
+
+// output is the result of an operation.
+type output struct {
+	Value string
+	OK    bool
+}
+
+// state is the value of one key.
+type state struct {
+	value   string
+	present bool
+}
+
+// model is the sequential specification of Store, checked key by key.
+var model = lincheck.Model{
+	Init: func() any { return state{} },
+	Step: func(st, in, out any) (bool, any) {
+		s, i := st.(state), in.(input)
+		o, returned := out.(output)
+		switch i.Op {
+		case "get":
+			return !returned || o == output{s.value, s.present}, s
+		case "put":
+			return true, state{i.Value, true}
+		case "delete":
+			return true, state{}
+		case "append":
+			next := state{s.value + i.Value, true}
+			return !returned || o.Value == next.value, next
+		case "cas":
+			swapped := s.present && s.value == i.Old
+			if returned && o.OK != swapped {
+				return false, s
+			}
+			if swapped {
+				return true, state{i.Value, true}
+			}
+			return true, s
+		}
+		panic("unknown operation " + i.Op)
+	},
+	Partition: func(ops []lincheck.Operation) [][]lincheck.Operation {
+		byKey := map[string][]lincheck.Operation{}
+		var keys []string
+		for _, op := range ops {
+			k := op.Input.(input).Key
+			if _, ok := byKey[k]; !ok {
+				keys = append(keys, k)
+			}
+			byKey[k] = append(byKey[k], op)
+		}
+		var parts [][]lincheck.Operation
+		for _, k := range keys {
+			parts = append(parts, byKey[k])
+		}
+		return parts
+	},
+}
+
+// kv is the interface of the stores under test.
+type kv interface {
+	Get(key string) (string, bool)
+	Put(key, value string)
+}
+
+// client runs n operations on store, chosen by the schedule among ops, and
+// records them in h. Operations other than get and put need a *Store.
+func client(s *weft.Scheduler, h *lincheck.History, id int, store kv, ops []string, n int) {
+	keys := []string{"x", "y"}
+	for i := 0; i < n; i++ {
+		in := input{
+			Op:    ops[s.Choose(len(ops))],
+			Key:   keys[s.Choose(len(keys))],
+			Value: fmt.Sprint(id, ".", i),
+		}
+		if in.Op == "cas" {
+			in.Old = fmt.Sprint(s.Choose(3), ".", 0)
+		}
+		op := h.Call(id, in)
+		var out output
+		switch in.Op {
+		case "get":
+			out.Value, out.OK = store.Get(in.Key)
+		case "put":
+			store.Put(in.Key, in.Value)
+		case "delete":
+			store.(*Store).Delete(in.Key)
+		case "append":
+			out.Value = store.(*Store).Append(in.Key, in.Value)
+		case "cas":
+			out.OK = store.(*Store).CompareAndSwap(in.Key, in.Old, in.Value)
+		}
+		op.Return(out)
+	}
+}
+
+// run explores clients running concurrently on a store made by newStore,
+// and returns the number of schedules whose history is not linearizable.
+func run(t *testing.T, runs int, newStore func() kv, ops []string) (violations int) {
+	wefttest.Explore(t, runs, func(s *weft.Scheduler) {
+		var h lincheck.History
+		store := newStore()
+		for id := 0; id < 3; id++ {
+			s.Go(func(weft.Context) { client(s, &h, id, store, ops, 4) })
+		}
+		s.Wait()
+		if !lincheck.Check(model, h.Operations()) {
+			violations++
+			t.Logf("history is not linearizable: %+v", h.Operations())
+		}
+	})
+	return violations
+}
+
+// TestStoreSequential verifies each operation on its own.
+func TestStoreSequential(t *testing.T) {
+	s := New(4)
+	if _, ok := s.Get("x"); ok {
+		t.Error("Get() on empty store found a value")
+	}
+	s.Put("x", "a")
+	if got := s.Append("x", "b"); got != "ab" {
+		t.Errorf("Append() = %q, want ab", got)
+	}
+	if s.CompareAndSwap("x", "a", "c") {
+		t.Error("CompareAndSwap() with wrong old value swapped")
+	}
+	if !s.CompareAndSwap("x", "ab", "c") {
+		t.Error("CompareAndSwap() with right old value did not swap")
+	}
+	if v, ok := s.Get("x"); !ok || v != "c" {
+		t.Errorf("Get() = %q, %v, want c, true", v, ok)
+	}
+	s.Delete("x")
+	if _, ok := s.Get("x"); ok {
+		t.Error("Get() after Delete found a value")
+	}
+}
+
+// TestStoreLinearizable checks that every explored history of concurrent
+// clients of a Store is linearizable.
+func TestStoreLinearizable(t *testing.T) {
+	ops := []string{"get", "put", "delete", "append", "cas"}
+	if n := run(t, 200, func() kv { return New(2) }, ops); n > 0 {
+		t.Errorf("%d histories not linearizable", n)
+	}
+}
+
+// TestCachedStoreStale shows lincheck catching the stale reads of
+// CachedStore when the schedule lands a Put between a read miss and the
+// cache fill.
+func TestCachedStoreStale(t *testing.T) {
+	n := run(t, 200, func() kv { return NewCached(2) }, []string{"get", "put"})
+	if n > 0 {
+		// In real tests, this would be t.Errorf() until the bug is fixed.
+		t.Logf("stale reads detected in %d schedules", n)
+	}
+}
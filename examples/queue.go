@@ -29,6 +29,12 @@ func (q *Queue[T]) Pop() (T, bool) {
 	return q.items.TryRecv()
 }
 
+// Take removes and returns an item from the queue, waiting for one to be
+// added. It returns false once the queue is closed and drained.
+func (q *Queue[T]) Take() (T, bool) {
+	return q.items.Recv()
+}
+
 // Close signals that no more items will be added.
 func (q *Queue[T]) Close() {
 	q.items.Close()
@@ -59,10 +65,12 @@ func (pc *ProducerConsumer) Produce(items []int) {
 	}
 }
 
-// Consume processes items from the queue.
+// Consume processes items from the queue until it is closed. It waits for
+// items rather than stopping at the first empty Pop, which would miss the
+// items of a producer the consumer happened to run ahead of.
 func (pc *ProducerConsumer) Consume() []int {
 	for {
-		item, ok := pc.queue.Pop()
+		item, ok := pc.queue.Take()
 		if !ok {
 			break
 		}
@@ -0,0 +1,365 @@
+// Package raft is a small implementation of the Raft consensus algorithm,
+// leader election and log replication, over weftnet datagrams. It shows how
+// to test a consensus protocol with weftraft: members report elections and
+// applied entries to the cluster, which checks them for safety while
+// scenarios partition and crash them.
+package raft
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftnet"
+	"github.com/mziter/weft/weftraft"
+)
+
+// Port is the datagram port members and clients send messages to.
+const Port = 7000
+
+// Timing of the protocol, on virtual time.
+const (
+	electionMin = 1500 * time.Millisecond
+	electionMax = 3 * time.Second
+	heartbeat   = 500 * time.Millisecond
+)
+
+// Server is a member's durable state, which survives its crashes: its
+// current term, its vote in that term and its log.
+type Server struct {
+	name  string
+	peers []string
+
+	mu       weft.Mutex
+	term     uint64
+	votedFor string
+
+	// log holds the entry of index i at log[i-1].
+	log []weftraft.Entry
+
+	// conn is the connection of the incarnation running.
+	conn *weftnet.PacketConn
+}
+
+// NewServer returns the durable state of a new member called name, one of
+// members.
+func NewServer(name string, members []string) *Server {
+	srv := &Server{name: name}
+	for _, m := range members {
+		if m != name {
+			srv.peers = append(srv.peers, m)
+		}
+	}
+	return srv
+}
+
+// message is a message between members, or from a client.
+type message struct {
+	Kind string // "propose", "vote", "voteReply", "append" or "appendReply"
+	From string
+	Term uint64
+
+	// Command is a client's proposal.
+	Command string `json:",omitempty"`
+
+	// LastLogIndex and LastLogTerm describe a candidate's log, and Granted
+	// answers its request for a vote.
+	LastLogIndex, LastLogTerm uint64 `json:",omitempty"`
+	Granted                   bool   `json:",omitempty"`
+
+	// PrevLogIndex, PrevLogTerm, Entries and LeaderCommit make up an
+	// AppendEntries request; Success and Match answer it.
+	PrevLogIndex, PrevLogTerm uint64           `json:",omitempty"`
+	Entries                   []weftraft.Entry `json:",omitempty"`
+	LeaderCommit              uint64           `json:",omitempty"`
+	Success                   bool             `json:",omitempty"`
+	Match                     uint64           `json:",omitempty"`
+}
+
+// Propose sends cmd from conn to the member called to, which appends it to
+// the log if it leads.
+func Propose(conn *weftnet.PacketConn, to, cmd string) {
+	send(conn, to, message{Kind: "propose", Command: cmd})
+}
+
+func send(conn *weftnet.PacketConn, to string, m message) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	conn.WriteTo(b, weftnet.Addr{Host: to, Port: Port})
+}
+
+// role is a member's part in the protocol.
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+// replica is one incarnation of a member: its durable state and the state
+// it loses when it crashes.
+type replica struct {
+	*Server
+	c    *weftraft.Cluster
+	s    *weft.Scheduler
+	conn *weftnet.PacketConn
+
+	role                     role
+	votes                    map[string]bool
+	commitIndex, lastApplied uint64
+	next, match              map[string]uint64
+
+	timer *weftraft.Timer
+	inbox weft.Chan[message]
+	ticks weft.Chan[struct{}]
+}
+
+// Run runs an incarnation of the member as node n of cluster c. It is the
+// start function of the member's node, and runs until the node crashes.
+func (srv *Server) Run(c *weftraft.Cluster, n *weft.Node) {
+	srv.mu.Lock()
+	if srv.conn != nil {
+		// The previous incarnation's socket dies with it.
+		srv.conn.Close()
+	}
+	conn, err := c.Network().Host(srv.name).ListenPacket(fmt.Sprintf(":%d", Port))
+	srv.conn = conn
+	srv.mu.Unlock()
+	if err != nil {
+		panic(err)
+	}
+
+	r := &replica{
+		Server: srv,
+		c:      c,
+		s:      c.Scheduler(),
+		conn:   conn,
+		timer:  weftraft.NewTimer(c.Scheduler(), electionMin, electionMax),
+		inbox:  weft.MakeChan[message](64),
+		ticks:  weft.MakeChan[struct{}](1),
+	}
+	n.Go(func(weft.Context) { r.receive() })
+	n.Go(func(weft.Context) { r.tick() })
+	r.run()
+}
+
+// receive queues the messages arriving on the member's connection until it
+// closes. Messages arriving faster than they are handled are dropped.
+func (r *replica) receive() {
+	buf := make([]byte, 64<<10)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var m message
+		if json.Unmarshal(buf[:n], &m) == nil {
+			r.inbox.TrySend(m)
+		}
+	}
+}
+
+// tick paces the leader's heartbeats.
+func (r *replica) tick() {
+	for {
+		r.s.Sleep(heartbeat)
+		r.ticks.TrySend(struct{}{})
+	}
+}
+
+// run handles messages, election timeouts and heartbeats.
+func (r *replica) run() {
+	for {
+		in := weft.OnRecv(r.inbox)
+		i := weft.Select(in, weft.OnRecv(r.timer.C()), weft.OnRecv(r.ticks))
+		r.mu.Lock()
+		switch i {
+		case 0:
+			m, _ := in.Value()
+			r.handle(m)
+		case 1:
+			r.startElection()
+		case 2:
+			if r.role == leader {
+				r.broadcast()
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// The methods below run with r.mu held.
+
+func (r *replica) send(to string, m message) {
+	m.From, m.Term = r.name, r.term
+	send(r.conn, to, m)
+}
+
+func (r *replica) lastLog() (index, term uint64) {
+	if len(r.log) == 0 {
+		return 0, 0
+	}
+	e := r.log[len(r.log)-1]
+	return e.Index, e.Term
+}
+
+// majority reports whether n members, counting this one, are a majority.
+func (r *replica) majority(n int) bool {
+	return 2*n > len(r.peers)+1
+}
+
+func (r *replica) handle(m message) {
+	if m.Kind != "propose" && m.Term > r.term {
+		r.stepDown(m.Term)
+	}
+	switch m.Kind {
+	case "propose":
+		if r.role == leader {
+			r.log = append(r.log, weftraft.Entry{Index: uint64(len(r.log)) + 1, Term: r.term, Command: m.Command})
+		}
+	case "vote":
+		index, term := r.lastLog()
+		upToDate := m.LastLogTerm > term || m.LastLogTerm == term && m.LastLogIndex >= index
+		granted := m.Term == r.term && (r.votedFor == "" || r.votedFor == m.From) && upToDate
+		if granted {
+			r.votedFor = m.From
+			r.timer.Reset()
+		}
+		r.send(m.From, message{Kind: "voteReply", Granted: granted})
+	case "voteReply":
+		if r.role == candidate && m.Term == r.term && m.Granted {
+			r.votes[m.From] = true
+			if r.majority(len(r.votes)) {
+				r.becomeLeader()
+			}
+		}
+	case "append":
+		r.appendEntries(m)
+	case "appendReply":
+		if r.role != leader || m.Term != r.term {
+			return
+		}
+		if !m.Success {
+			r.next[m.From] = max(1, r.next[m.From]-1)
+			return
+		}
+		r.match[m.From] = max(r.match[m.From], m.Match)
+		r.next[m.From] = r.match[m.From] + 1
+		r.advanceCommit()
+	}
+}
+
+func (r *replica) stepDown(term uint64) {
+	if r.role == leader {
+		r.timer.Reset()
+	}
+	r.term, r.votedFor, r.role = term, "", follower
+}
+
+func (r *replica) startElection() {
+	if r.role == leader {
+		return
+	}
+	r.term++
+	r.role, r.votedFor = candidate, r.name
+	r.votes = map[string]bool{r.name: true}
+	r.timer.Reset()
+	if r.majority(1) {
+		r.becomeLeader()
+		return
+	}
+	index, term := r.lastLog()
+	for _, p := range r.peers {
+		r.send(p, message{Kind: "vote", LastLogIndex: index, LastLogTerm: term})
+	}
+}
+
+func (r *replica) becomeLeader() {
+	r.role = leader
+	r.timer.Stop()
+	r.c.ReportLeader(r.name, r.term)
+	r.next, r.match = make(map[string]uint64), make(map[string]uint64)
+	for _, p := range r.peers {
+		r.next[p] = uint64(len(r.log)) + 1
+	}
+	r.broadcast()
+}
+
+// broadcast sends each peer the entries it is missing, or a heartbeat.
+func (r *replica) broadcast() {
+	for _, p := range r.peers {
+		prev := r.next[p] - 1
+		var prevTerm uint64
+		if prev > 0 {
+			prevTerm = r.log[prev-1].Term
+		}
+		r.send(p, message{
+			Kind:         "append",
+			PrevLogIndex: prev,
+			PrevLogTerm:  prevTerm,
+			Entries:      r.log[prev:],
+			LeaderCommit: r.commitIndex,
+		})
+	}
+	r.advanceCommit()
+}
+
+func (r *replica) appendEntries(m message) {
+	if m.Term < r.term {
+		r.send(m.From, message{Kind: "appendReply"})
+		return
+	}
+	r.role = follower
+	r.timer.Reset()
+	if m.PrevLogIndex > uint64(len(r.log)) || m.PrevLogIndex > 0 && r.log[m.PrevLogIndex-1].Term != m.PrevLogTerm {
+		r.send(m.From, message{Kind: "appendReply"})
+		return
+	}
+	for _, e := range m.Entries {
+		if e.Index <= uint64(len(r.log)) {
+			if r.log[e.Index-1].Term == e.Term {
+				continue
+			}
+			r.log = r.log[:e.Index-1]
+		}
+		r.log = append(r.log, e)
+	}
+	match := m.PrevLogIndex + uint64(len(m.Entries))
+	if commit := min(m.LeaderCommit, match); commit > r.commitIndex {
+		r.commitIndex = commit
+		r.apply()
+	}
+	r.send(m.From, message{Kind: "appendReply", Success: true, Match: match})
+}
+
+// advanceCommit commits the latest entry of the leader's term stored on a
+// majority.
+func (r *replica) advanceCommit() {
+	for n := uint64(len(r.log)); n > r.commitIndex; n-- {
+		if r.log[n-1].Term != r.term {
+			break
+		}
+		count := 1
+		for _, p := range r.peers {
+			if r.match[p] >= n {
+				count++
+			}
+		}
+		if r.majority(count) {
+			r.commitIndex = n
+			r.apply()
+			return
+		}
+	}
+}
+
+func (r *replica) apply() {
+	for r.lastApplied < r.commitIndex {
+		r.lastApplied++
+		r.c.ReportApplied(r.name, r.log[r.lastApplied-1])
+	}
+}
@@ -0,0 +1,74 @@
+package raft
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftnet"
+	"github.com/mziter/weft/weftraft"
+	"github.com/mziter/weft/wefttest"
+)
+
+// newCluster starts a Raft cluster of three members.
+func newCluster(s *weft.Scheduler) *weftraft.Cluster {
+	members := []string{"a", "b", "c"}
+	servers := make(map[string]*Server)
+	for _, m := range members {
+		servers[m] = NewServer(m, members)
+	}
+	net := weftnet.New(s)
+	net.SetDefaultFaults(weftnet.Faults{Drop: 0.05, Duplicate: 0.05, Reorder: 0.05})
+	return weftraft.NewCluster(s, net, members, func(c *weftraft.Cluster, n *weft.Node) {
+		servers[n.Name()].Run(c, n)
+	})
+}
+
+// propose has a client propose cmd to the leader until it is committed,
+// or deadline passes.
+func propose(t *testing.T, c *weftraft.Cluster, conn *weftnet.PacketConn, cmd string, deadline time.Duration) {
+	for waited := time.Duration(0); waited < deadline; waited += heartbeat {
+		if committed(c, cmd) {
+			return
+		}
+		if to := c.Leader(); to != "" {
+			Propose(conn, to, cmd)
+		}
+		c.Scheduler().Sleep(heartbeat)
+	}
+	t.Errorf("%q not committed in %v: %v", cmd, deadline, c.Committed())
+}
+
+func committed(c *weftraft.Cluster, cmd string) bool {
+	return slices.ContainsFunc(c.Committed(), func(e weftraft.Entry) bool { return e.Command == cmd })
+}
+
+// TestRaft explores a Raft cluster under lossy networking, partitions of
+// its leader and of a minority, and a crashed leader, checking that it
+// stays safe and commits what clients propose.
+func TestRaft(t *testing.T) {
+	wefttest.Explore(t, 10, func(s *weft.Scheduler) {
+		c := newCluster(s)
+		c.Run(
+			weftraft.IsolateLeader(10*time.Second, 10*time.Second),
+			weftraft.SplitMinority(30*time.Second, 10*time.Second),
+			weftraft.CrashLeader(50*time.Second, 5*time.Second),
+		)
+		conn, err := c.Network().Host("client").ListenPacket(":0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Propose a command every ten seconds, through the scenarios.
+		for i := 0; i < 7; i++ {
+			propose(t, c, conn, fmt.Sprint("set x ", i), 100*time.Second)
+			s.Sleep(10 * time.Second)
+		}
+		conn.Close()
+		c.Stop()
+		if err := c.Err(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+//go:build detsched
+
+package weft
+
+import "sync"
+
+// Failpoint marks a named point in code where tests can inject a failure.
+// It returns nil unless the failpoint has been enabled, when it does what
+// the enabling action says: returns its error, panics or blocks.
+//
+//	if err := weft.Failpoint("wal/before-sync"); err != nil {
+//		return err
+//	}
+//
+// In builds without the detsched tag, Failpoint always returns nil.
+func Failpoint(name string) error {
+	failpoints.mu.Lock()
+	fp := failpoints.m[name]
+	failpoints.mu.Unlock()
+	if fp == nil || !fp.fire() {
+		return nil
+	}
+
+	a := fp.action
+	if a.Panic != nil {
+		panic(a.Panic)
+	}
+	if a.Block {
+		fp.release.Recv()
+	}
+	return a.Err
+}
+
+// FailpointAction is what an enabled failpoint does when reached.
+type FailpointAction struct {
+	// Err is returned by Failpoint.
+	Err error
+
+	// Panic, if not nil, is the value Failpoint panics with.
+	Panic any
+
+	// Block makes Failpoint block until the failpoint is disabled, and
+	// then return Err.
+	Block bool
+
+	// Rate is the probability, from 0 to 1, that the failpoint fires when
+	// reached, as a decision of the schedule. Zero means every time.
+	Rate float64
+
+	// Count is the number of times the failpoint fires before disabling
+	// itself. Zero means no limit.
+	Count int
+}
+
+// failpoints holds the enabled failpoints by name.
+var failpoints struct {
+	mu sync.Mutex
+	m  map[string]*failpoint
+}
+
+// failpoint is an enabled failpoint.
+type failpoint struct {
+	s      *Scheduler
+	action FailpointAction
+
+	// fired is guarded by failpoints.mu.
+	fired int
+
+	// release is closed when the failpoint is disabled, to unblock it.
+	release Chan[struct{}]
+}
+
+// fire reports whether fp fires now, counting it if it does.
+func (fp *failpoint) fire() bool {
+	a := fp.action
+	if a.Rate > 0 && !fp.s.Chance(a.Rate) {
+		return false
+	}
+	failpoints.mu.Lock()
+	defer failpoints.mu.Unlock()
+	if a.Count > 0 && fp.fired >= a.Count {
+		return false
+	}
+	fp.fired++
+	return true
+}
+
+// EnableFailpoint makes the failpoint called name do a when reached, until
+// disable is called, replacing any action it had. A Rate is decided by the
+// scheduler of the current run, as with Scheduler.EnableFailpoint; outside a
+// run, EnableFailpoint panics if given one.
+//
+// In builds without the detsched tag, failpoints never fire.
+func EnableFailpoint(name string, a FailpointAction) (disable func()) {
+	var s *Scheduler
+	if a.Rate > 0 && a.Rate < 1 {
+		s = current("EnableFailpoint with a Rate")
+	}
+	return s.EnableFailpoint(name, a)
+}
+
+// EnableFailpoint is like the package-level EnableFailpoint, but a Rate is
+// decided by s, so that whether the failpoint fires is explored, replayed
+// and shrunk with the rest of the schedule.
+//
+//	s.EnableFailpoint("wal/before-sync", weft.FailpointAction{Err: errDisk, Rate: 0.1})
+func (s *Scheduler) EnableFailpoint(name string, a FailpointAction) (disable func()) {
+	fp := &failpoint{s: s, action: a, release: MakeChan[struct{}](0)}
+	failpoints.mu.Lock()
+	defer failpoints.mu.Unlock()
+	if failpoints.m == nil {
+		failpoints.m = make(map[string]*failpoint)
+	}
+	if old := failpoints.m[name]; old != nil {
+		old.release.Close()
+	}
+	failpoints.m[name] = fp
+	return func() {
+		failpoints.mu.Lock()
+		defer failpoints.mu.Unlock()
+		if failpoints.m[name] == fp {
+			delete(failpoints.m, name)
+			fp.release.Close()
+		}
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !detsched
+
+package weft
+
+// Failpoint always returns nil in production mode, where failpoints never
+// fire.
+func Failpoint(name string) error {
+	return nil
+}
+
+// FailpointAction is what an enabled failpoint does when reached. It has
+// no effect in production mode.
+type FailpointAction struct {
+	Err   error
+	Panic any
+	Block bool
+	Rate  float64
+	Count int
+}
+
+// EnableFailpoint does nothing in production mode, where failpoints never
+// fire.
+func EnableFailpoint(name string, a FailpointAction) (disable func()) {
+	return func() {}
+}
+
+// EnableFailpoint does nothing in production mode, where failpoints never
+// fire.
+func (s *Scheduler) EnableFailpoint(name string, a FailpointAction) (disable func()) {
+	return func() {}
+}
@@ -0,0 +1,66 @@
+//go:build detsched
+
+package weft
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestFailpoint verifies that an enabled failpoint returns its error, no
+// more than Count times, and nothing once disabled.
+func TestFailpoint(t *testing.T) {
+	if err := Failpoint("test/off"); err != nil {
+		t.Errorf("Failpoint() before enabling = %v, want nil", err)
+	}
+	errDisk := errors.New("disk full")
+	disable := EnableFailpoint("test/err", FailpointAction{Err: errDisk, Count: 2})
+	defer disable()
+	for i := 0; i < 2; i++ {
+		if err := Failpoint("test/err"); err != errDisk {
+			t.Errorf("Failpoint() #%d = %v, want %v", i+1, err, errDisk)
+		}
+	}
+	if err := Failpoint("test/err"); err != nil {
+		t.Errorf("Failpoint() past Count = %v, want nil", err)
+	}
+
+	disable = EnableFailpoint("test/err", FailpointAction{Err: errDisk})
+	disable()
+	if err := Failpoint("test/err"); err != nil {
+		t.Errorf("Failpoint() after disable = %v, want nil", err)
+	}
+}
+
+// TestFailpointPanic verifies that a failpoint can panic.
+func TestFailpointPanic(t *testing.T) {
+	defer EnableFailpoint("test/panic", FailpointAction{Panic: "boom"})()
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recover() = %v, want boom", r)
+		}
+	}()
+	Failpoint("test/panic")
+}
+
+// TestFailpointBlock verifies that a blocking failpoint holds its caller
+// until it is disabled.
+func TestFailpointBlock(t *testing.T) {
+	s := NewScheduler(1)
+	disable := s.EnableFailpoint("test/block", FailpointAction{Block: true})
+	reached, passed := MakeChan[struct{}](1), MakeChan[struct{}](1)
+	s.Go(func(Context) {
+		reached.Send(struct{}{})
+		Failpoint("test/block")
+		passed.Send(struct{}{})
+	})
+	reached.Recv()
+	if _, ok := passed.TryRecv(); ok {
+		t.Fatal("blocking failpoint did not block")
+	}
+	disable()
+	s.Wait()
+	if _, ok := passed.TryRecv(); !ok {
+		t.Error("failpoint still blocked after disable")
+	}
+}
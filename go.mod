@@ -1,6 +1,10 @@
 module github.com/mziter/weft
 
-go 1.21
+go 1.22.0
 
 require (
-)
\ No newline at end of file
+	golang.org/x/sync v0.8.0
+	golang.org/x/tools v0.26.0
+)
+
+require golang.org/x/mod v0.21.0 // indirect
@@ -0,0 +1,129 @@
+//go:build detsched
+
+package weft
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// Harness runs a distributed-system test with a scheduler per simulated
+// node. The members' seeds are derived from the harness's and their names,
+// so a run repeats with the harness's seed alone, and they keep the
+// harness's virtual time, so that a timeout on one node and a delay on
+// another are ordered the same in every run. Tasks of different members
+// communicate over channels made with Connect.
+//
+//	h := weft.NewHarness(seed)
+//	a, b := h.Scheduler("a"), h.Scheduler("b")
+//	requests := weft.Connect[string](a, b, 1)
+//	a.Go(func(weft.Context) { requests.Send("ping") })
+//	b.Go(func(weft.Context) { requests.Recv() })
+//	h.Wait()
+type Harness struct {
+	s    *Scheduler
+	seed uint64
+
+	mu      sync.Mutex
+	members []*Scheduler
+	byName  map[string]*Scheduler
+	links   int
+}
+
+// NewHarness creates a harness with the given seed and no members.
+func NewHarness(seed uint64) *Harness {
+	return &Harness{s: NewScheduler(seed), seed: seed, byName: make(map[string]*Scheduler)}
+}
+
+// Scheduler returns the member scheduler called name, creating it on first
+// use.
+func (h *Harness) Scheduler(name string) *Scheduler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.byName[name]; ok {
+		return s
+	}
+	f := fnv.New64a()
+	f.Write([]byte(name))
+	s := &Scheduler{
+		sched:   scheduler.NewMember(h.s.sched, h.seed^f.Sum64()),
+		harness: h,
+		name:    name,
+	}
+	h.members = append(h.members, s)
+	h.byName[name] = s
+	return s
+}
+
+// Schedulers returns the members of the harness in the order they were
+// created.
+func (h *Harness) Schedulers() []*Scheduler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*Scheduler(nil), h.members...)
+}
+
+// Go spawns a task of the harness itself, such as one driving the test's
+// workload or faults, on none of its members.
+func (h *Harness) Go(fn func(Context)) {
+	h.s.Go(fn)
+}
+
+// Wait blocks until the tasks of the harness and of all its members
+// complete, or deadlock, when the runs blocked fail with ErrDeadlock.
+func (h *Harness) Wait() {
+	schedulers := []*scheduler.Scheduler{h.s.sched}
+	for _, s := range h.Schedulers() {
+		schedulers = append(schedulers, s.sched)
+	}
+	scheduler.WaitAll(schedulers...)
+}
+
+// Sleep pauses the current task for d of the harness's virtual time.
+func (h *Harness) Sleep(d time.Duration) {
+	h.s.Sleep(d)
+}
+
+// After returns a channel that receives after d of the harness's virtual
+// time.
+func (h *Harness) After(d time.Duration) Chan[time.Time] {
+	return h.s.After(d)
+}
+
+// Now returns the harness's virtual time.
+func (h *Harness) Now() time.Time {
+	return h.s.Now()
+}
+
+// Clock returns the virtual clock of the harness, which all its members
+// keep.
+func (h *Harness) Clock() Clock {
+	return h.s.Clock()
+}
+
+// SetSynctest marks the harness, and with it all its members, as running
+// inside a testing/synctest bubble.
+func (h *Harness) SetSynctest(on bool) {
+	h.s.SetSynctest(on)
+}
+
+// Connect creates a channel with capacity cap carrying values from tasks of
+// from to tasks of to, members of the same harness. Its sends are recorded
+// in from's events and its receives in to's, on an object named for the
+// link, such as "link 1 a->b", so the traces of the two nodes can be read
+// side by side.
+func Connect[T any](from, to *Scheduler, cap int) Chan[T] {
+	h := from.harness
+	if h == nil || to.harness != h {
+		panic("weft: Connect between schedulers not of the same harness")
+	}
+	h.mu.Lock()
+	h.links++
+	name := fmt.Sprintf("link %d %s->%s", h.links, from.name, to.name)
+	h.mu.Unlock()
+	return Chan[T]{ch: scheduler.MakeLink[T](from.sched, to.sched, name, cap)}
+}
@@ -0,0 +1,98 @@
+//go:build detsched
+
+package weft
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestHarnessLinkEvents verifies that a link's sends are recorded by the
+// sending member and its receives by the receiving one.
+func TestHarnessLinkEvents(t *testing.T) {
+	h := NewHarness(1)
+	a, b := h.Scheduler("a"), h.Scheduler("b")
+	c := Connect[string](a, b, 1)
+	a.Go(func(Context) { c.Send("hello") })
+	b.Go(func(Context) { c.Recv() })
+	h.Wait()
+
+	has := func(s *Scheduler, kind trace.Kind) bool {
+		return slices.ContainsFunc(s.Events(), func(ev trace.Event) bool {
+			return ev.Kind == kind && ev.Object == "link 1 a->b" && ev.Task != 0
+		})
+	}
+	if !has(a, trace.Send) || has(a, trace.Recv) {
+		t.Errorf("sender recorded %v, want the send alone", a.Events())
+	}
+	if !has(b, trace.Recv) || has(b, trace.Send) {
+		t.Errorf("receiver recorded %v, want the receive alone", b.Events())
+	}
+}
+
+// TestHarnessSeeds verifies that members' decisions follow from the
+// harness's seed and their names.
+func TestHarnessSeeds(t *testing.T) {
+	draw := func(seed uint64, name string) []int {
+		s := NewHarness(seed).Scheduler(name)
+		for range 8 {
+			s.Choose(1 << 20)
+		}
+		return s.Choices()
+	}
+	if !slices.Equal(draw(1, "a"), draw(1, "a")) {
+		t.Error("a member's decisions differ between runs with the same seed")
+	}
+	if slices.Equal(draw(1, "a"), draw(1, "b")) {
+		t.Error("members with different names made the same decisions")
+	}
+	if slices.Equal(draw(1, "a"), draw(2, "a")) {
+		t.Error("harnesses with different seeds made the same decisions")
+	}
+}
+
+// TestConnectOtherHarness verifies that schedulers of different harnesses
+// cannot be connected.
+func TestConnectOtherHarness(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Connect across harnesses did not panic")
+		}
+	}()
+	Connect[int](NewHarness(1).Scheduler("a"), NewHarness(1).Scheduler("b"), 0)
+}
+
+// TestHarnessClock verifies that the members keep the harness's virtual
+// time: a sleep on one wakes before a longer one on another, whatever the
+// seed, and the clock stops at the last deadline.
+func TestHarnessClock(t *testing.T) {
+	for seed := uint64(1); seed <= 10; seed++ {
+		h := NewHarness(seed)
+		a, b := h.Scheduler("a"), h.Scheduler("b")
+		start := h.Clock().Now()
+		var woken []string
+		var mu Mutex
+		a.Go(func(Context) {
+			a.Sleep(2 * time.Second)
+			mu.Lock()
+			woken = append(woken, "a")
+			mu.Unlock()
+		})
+		b.Go(func(Context) {
+			b.Sleep(time.Second)
+			mu.Lock()
+			woken = append(woken, "b")
+			mu.Unlock()
+		})
+		h.Wait()
+		if !slices.Equal(woken, []string{"b", "a"}) {
+			t.Errorf("seed %d: woke %v, want b after 1s then a after 2s", seed, woken)
+		}
+		if d := h.Clock().Now().Sub(start); d != 2*time.Second {
+			t.Errorf("seed %d: clock advanced %v, want 2s", seed, d)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+//go:build !detsched
+
+package weft
+
+import (
+	"sync"
+	"time"
+)
+
+// Harness groups the schedulers of a distributed-system test. In
+// production mode its members are no-op schedulers and its time is real.
+type Harness struct {
+	mu      sync.Mutex
+	members []*Scheduler
+	byName  map[string]*Scheduler
+}
+
+// NewHarness returns a harness with no members in production mode.
+func NewHarness(seed uint64) *Harness {
+	return &Harness{byName: make(map[string]*Scheduler)}
+}
+
+// Scheduler returns the member scheduler called name, creating it on first
+// use.
+func (h *Harness) Scheduler(name string) *Scheduler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.byName[name]; ok {
+		return s
+	}
+	s := &Scheduler{}
+	h.members = append(h.members, s)
+	h.byName[name] = s
+	return s
+}
+
+// Schedulers returns the members of the harness in the order they were
+// created.
+func (h *Harness) Schedulers() []*Scheduler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*Scheduler(nil), h.members...)
+}
+
+// Go spawns a regular goroutine in production mode.
+func (h *Harness) Go(fn func(Context)) {
+	go fn(productionContext{})
+}
+
+// Wait is a no-op in production mode.
+func (h *Harness) Wait() {}
+
+// Sleep delegates to time.Sleep in production mode.
+func (h *Harness) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// After returns a channel that receives the current time once the duration
+// has elapsed, like time.After, in production mode.
+func (h *Harness) After(d time.Duration) Chan[time.Time] {
+	return After(d)
+}
+
+// Now returns time.Now() in production mode.
+func (h *Harness) Now() time.Time {
+	return time.Now()
+}
+
+// Clock returns the wall clock in production mode.
+func (h *Harness) Clock() Clock {
+	return wallClock{}
+}
+
+// SetSynctest is a no-op in production mode.
+func (h *Harness) SetSynctest(on bool) {}
+
+// Connect returns a regular channel with capacity cap in production mode.
+func Connect[T any](from, to *Scheduler, cap int) Chan[T] {
+	return MakeChan[T](cap)
+}
@@ -0,0 +1,32 @@
+package weft
+
+import "testing"
+
+// TestHarnessConnect verifies that tasks of different members of a harness
+// exchange values over links.
+func TestHarnessConnect(t *testing.T) {
+	h := NewHarness(1)
+	a, b := h.Scheduler("a"), h.Scheduler("b")
+	if h.Scheduler("a") != a {
+		t.Fatal("Scheduler returned a new member for an existing name")
+	}
+	requests := Connect[int](a, b, 0)
+	replies := Connect[int](b, a, 0)
+	got := make(chan int, 1)
+	a.Go(func(Context) {
+		requests.Send(20)
+		v, _ := replies.Recv()
+		got <- v
+	})
+	b.Go(func(Context) {
+		v, _ := requests.Recv()
+		replies.Send(v + 1)
+	})
+	h.Wait()
+	if v := <-got; v != 21 {
+		t.Errorf("got reply %d, want 21", v)
+	}
+	if n := len(h.Schedulers()); n != 2 {
+		t.Errorf("harness has %d members, want 2", n)
+	}
+}
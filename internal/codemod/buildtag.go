@@ -0,0 +1,136 @@
+package codemod
+
+import (
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"path/filepath"
+	"strings"
+)
+
+// Tag is the build tag that selects weft's deterministic implementations.
+const Tag = "detsched"
+
+// Constrain returns src with its build constraint narrowed to builds where
+// expr also holds, such as "detsched" or "!detsched". An existing //go:build
+// line is combined with expr; legacy // +build lines are dropped.
+func Constrain(src []byte, expr string) ([]byte, error) {
+	add, err := constraint.Parse("//go:build " + expr)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	var out bytes.Buffer
+	found := false
+	for i, line := range lines {
+		text := strings.TrimSpace(string(line))
+		if strings.HasPrefix(text, "package ") || (text != "" && !strings.HasPrefix(text, "//")) {
+			// Build constraints must precede the package clause.
+			for _, rest := range lines[i:] {
+				out.Write(rest)
+			}
+			break
+		}
+		switch {
+		case constraint.IsGoBuild(text):
+			existing, err := constraint.Parse(text)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&out, "//go:build %s\n", &constraint.AndExpr{X: existing, Y: add})
+			found = true
+		case constraint.IsPlusBuild(text):
+			// Superseded by the //go:build line.
+		default:
+			out.Write(line)
+		}
+	}
+	if found {
+		return out.Bytes(), nil
+	}
+	return append([]byte("//go:build "+add.String()+"\n\n"), src...), nil
+}
+
+// HasTag reports whether src already has a build constraint mentioning the
+// detsched tag, meaning it is one half of a split pair.
+func HasTag(src []byte) bool {
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if strings.HasPrefix(text, "package ") {
+			break
+		}
+		if !constraint.IsGoBuild(text) {
+			continue
+		}
+		expr, err := constraint.Parse(text)
+		if err != nil {
+			continue
+		}
+		if mentions(expr, Tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// mentions reports whether tag appears anywhere in expr.
+func mentions(expr constraint.Expr, tag string) bool {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		return e.Tag == tag
+	case *constraint.NotExpr:
+		return mentions(e.X, tag)
+	case *constraint.AndExpr:
+		return mentions(e.X, tag) || mentions(e.Y, tag)
+	case *constraint.OrExpr:
+		return mentions(e.X, tag) || mentions(e.Y, tag)
+	}
+	return false
+}
+
+// DetschedName returns the name of the deterministic half of a split file:
+// foo.go becomes foo_detsched.go and foo_test.go becomes foo_detsched_test.go.
+// The tag goes before a GOOS or GOARCH suffix, so that foo_linux.go becomes
+// foo_detsched_linux.go and keeps the constraint its name implies.
+func DetschedName(filename string) string {
+	dir, file := filepath.Split(filename)
+	name := strings.TrimSuffix(file, ".go")
+	test := ""
+	if base, ok := strings.CutSuffix(name, "_test"); ok {
+		name, test = base, "_test"
+	}
+	suffix := ""
+	// As go/build does, only elements after the first underscore count.
+	if i := strings.Index(name, "_"); i >= 0 {
+		l := strings.Split(name[i:], "_")
+		n := len(l)
+		switch {
+		case n >= 3 && knownOS[l[n-2]] && knownArch[l[n-1]]:
+			suffix = "_" + l[n-2] + "_" + l[n-1]
+		case n >= 2 && (knownOS[l[n-1]] || knownArch[l[n-1]]):
+			suffix = "_" + l[n-1]
+		}
+	}
+	return dir + strings.TrimSuffix(name, suffix) + "_" + Tag + suffix + test + ".go"
+}
+
+// knownOS and knownArch list the GOOS and GOARCH values that go/build
+// recognizes as file name suffixes.
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true,
+		"freebsd": true, "hurd": true, "illumos": true, "ios": true, "js": true,
+		"linux": true, "nacl": true, "netbsd": true, "openbsd": true,
+		"plan9": true, "solaris": true, "wasip1": true, "windows": true,
+		"zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true,
+		"arm64": true, "arm64be": true, "loong64": true, "mips": true,
+		"mipsle": true, "mips64": true, "mips64le": true, "mips64p32": true,
+		"mips64p32le": true, "ppc": true, "ppc64": true, "ppc64le": true,
+		"riscv": true, "riscv64": true, "s390": true, "s390x": true,
+		"sparc": true, "sparc64": true, "wasm": true,
+	}
+)
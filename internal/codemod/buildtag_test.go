@@ -0,0 +1,63 @@
+package codemod
+
+import "testing"
+
+// TestConstrain verifies that build constraints are added and combined.
+func TestConstrain(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		expr string
+		want string
+	}{
+		{
+			name: "no constraint",
+			in:   "// Package p does things.\npackage p\n",
+			expr: "!detsched",
+			want: "//go:build !detsched\n\n// Package p does things.\npackage p\n",
+		},
+		{
+			name: "existing constraint",
+			in:   "//go:build linux || darwin\n// +build linux darwin\n\npackage p\n",
+			expr: "detsched",
+			want: "//go:build (linux || darwin) && detsched\n\npackage p\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Constrain([]byte(tt.in), tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if !HasTag(got) {
+				t.Error("HasTag = false for constrained source")
+			}
+		})
+	}
+	if HasTag([]byte("//go:build linux\n\npackage p\n")) {
+		t.Error("HasTag = true for source without the detsched tag")
+	}
+}
+
+// TestDetschedName verifies names of the deterministic half of a split.
+func TestDetschedName(t *testing.T) {
+	for in, want := range map[string]string{
+		"pool.go":                  "pool_detsched.go",
+		"pool_test.go":             "pool_detsched_test.go",
+		"dir/pool.go":              "dir/pool_detsched.go",
+		"pool_linux.go":            "pool_detsched_linux.go",
+		"pool_amd64.go":            "pool_detsched_amd64.go",
+		"pool_linux_amd64.go":      "pool_detsched_linux_amd64.go",
+		"pool_linux_amd64_test.go": "pool_detsched_linux_amd64_test.go",
+		"pool_linux_test.go":       "pool_detsched_linux_test.go",
+		"linux.go":                 "linux_detsched.go",
+		"pool_unix.go":             "pool_unix_detsched.go",
+	} {
+		if got := DetschedName(in); got != want {
+			t.Errorf("DetschedName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// chanType rewrites a channel type to weft.Chan. Channel direction is not
+// preserved because weft.Chan is always bidirectional.
+func (r *rewriter) chanType(ct *ast.ChanType) {
+	r.replace(ct.Pos(), ct.Value.Pos(), r.weft+".Chan[")
+	r.insert(ct.Value.End(), "]")
+}
+
+// makeChan rewrites make(chan T, n) to weft.MakeChan[T](n).
+func (r *rewriter) makeChan(call *ast.CallExpr) bool {
+	if !isBuiltin(call.Fun, "make") || len(call.Args) == 0 {
+		return false
+	}
+	ct, ok := call.Args[0].(*ast.ChanType)
+	if !ok {
+		return false
+	}
+	size := "0"
+	if len(call.Args) > 1 {
+		size = r.text(call.Args[1])
+	}
+	r.replace(call.Pos(), ct.Value.Pos(), r.weft+".MakeChan[")
+	r.replace(ct.Value.End(), call.End(), "]("+size+")")
+	return true
+}
+
+// closeChan rewrites close(ch) to ch.Close().
+func (r *rewriter) closeChan(call *ast.CallExpr) {
+	if !isBuiltin(call.Fun, "close") || len(call.Args) != 1 {
+		return
+	}
+	open, shut := r.receiver(call.Args[0])
+	r.replace(call.Pos(), call.Args[0].Pos(), open)
+	r.replace(call.Args[0].End(), call.End(), shut+".Close()")
+}
+
+// send rewrites ch <- v to ch.Send(v).
+func (r *rewriter) send(s *ast.SendStmt) {
+	open, shut := r.receiver(s.Chan)
+	r.insert(s.Chan.Pos(), open)
+	r.replace(s.Chan.End(), s.Value.Pos(), shut+".Send(")
+	r.insert(s.Value.End(), ")")
+}
+
+// recvStmt rewrites a receive whose result is discarded.
+func (r *rewriter) recvStmt(s *ast.ExprStmt) bool {
+	u, ok := s.X.(*ast.UnaryExpr)
+	if !ok || u.Op != token.ARROW {
+		return false
+	}
+	r.recv(u)
+	return true
+}
+
+// recvAssign rewrites v := <-ch and v, ok = <-ch forms.
+func (r *rewriter) recvAssign(s *ast.AssignStmt) bool {
+	if len(s.Rhs) != 1 || len(s.Lhs) > 2 {
+		return false
+	}
+	if s.Tok != token.DEFINE && s.Tok != token.ASSIGN {
+		return false
+	}
+	u, ok := s.Rhs[0].(*ast.UnaryExpr)
+	if !ok || u.Op != token.ARROW {
+		return false
+	}
+	if len(s.Lhs) == 1 {
+		r.insert(s.Lhs[0].End(), ", _")
+	}
+	r.recv(u)
+	return true
+}
+
+// recvValueSpec rewrites var v = <-ch and var v, ok = <-ch.
+func (r *rewriter) recvValueSpec(s *ast.ValueSpec) bool {
+	if s.Type != nil || len(s.Values) != 1 || len(s.Names) > 2 {
+		return false
+	}
+	u, ok := s.Values[0].(*ast.UnaryExpr)
+	if !ok || u.Op != token.ARROW {
+		return false
+	}
+	if len(s.Names) == 1 {
+		r.insert(s.Names[0].End(), ", _")
+	}
+	r.recv(u)
+	return true
+}
+
+// recv rewrites <-ch to ch.Recv().
+func (r *rewriter) recv(u *ast.UnaryExpr) {
+	open, shut := r.receiver(u.X)
+	r.replace(u.Pos(), u.X.Pos(), open)
+	r.insert(u.X.End(), shut+".Recv()")
+}
+
+// rangeChan rewrites a range loop over a channel into a three-clause loop
+// over Recv, which keeps break and continue semantics intact.
+func (r *rewriter) rangeChan(s *ast.RangeStmt) {
+	if !r.isChan(s.X) {
+		return
+	}
+	if _, ok := ast.Unparen(s.X).(*ast.CallExpr); ok {
+		// The loop would evaluate the call on every iteration.
+		r.report(s, "range over channel returned by %s; not converted", r.text(s.X))
+		return
+	}
+	if s.Value != nil || (s.Key != nil && s.Tok != token.DEFINE) {
+		r.report(s, "range over channel assigning to existing variables; not converted")
+		return
+	}
+	key := "_"
+	if s.Key != nil {
+		key = r.text(s.Key)
+	}
+	ok := r.freshName(s, "ok")
+	recv := r.operand(s.X) + ".Recv()"
+	r.replace(s.For, s.Body.Lbrace,
+		"for "+key+", "+ok+" := "+recv+"; "+ok+"; "+key+", "+ok+" = "+recv+" ")
+}
+
+// receiver returns the text to place around x to use it as the receiver of
+// a method call.
+func (r *rewriter) receiver(x ast.Expr) (open, shut string) {
+	if r.operand(x) != r.text(x) {
+		return "(", ")"
+	}
+	return "", ""
+}
+
+// isChanType reports whether t is a channel type or an already converted
+// weft.Chan.
+func (r *rewriter) isChanType(t ast.Expr) bool {
+	switch t := t.(type) {
+	case *ast.ChanType:
+		return true
+	case *ast.IndexExpr:
+		sel, ok := t.X.(*ast.SelectorExpr)
+		return ok && sel.Sel.Name == "Chan" && r.isPkg(sel.X, WeftPath)
+	}
+	return false
+}
+
+// isMakeChan reports whether x creates a channel.
+func (r *rewriter) isMakeChan(x ast.Expr) bool {
+	call, ok := x.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	if isBuiltin(call.Fun, "make") && len(call.Args) > 0 {
+		_, ok := call.Args[0].(*ast.ChanType)
+		return ok
+	}
+	if idx, ok := call.Fun.(*ast.IndexExpr); ok {
+		sel, ok := idx.X.(*ast.SelectorExpr)
+		return ok && sel.Sel.Name == "MakeChan" && r.isPkg(sel.X, WeftPath)
+	}
+	return false
+}
+
+// isChan reports whether x is known to be a channel. Without type
+// information it follows the declaration of identifiers within the file and
+// matches field selectors against channel fields declared in the file and
+// the C of timers.
+func (r *rewriter) isChan(x ast.Expr) bool {
+	if r.info != nil {
+		if t := r.info.TypeOf(x); t != nil {
+			return isChanOrWeft(t)
+		}
+	}
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return r.isChan(x.X)
+	case *ast.SelectorExpr:
+		if x.Sel.Name == "C" && r.isTimer(x.X) {
+			return true
+		}
+		return r.chanFields[x.Sel.Name]
+	case *ast.Ident:
+		if x.Obj == nil {
+			return false
+		}
+		switch decl := x.Obj.Decl.(type) {
+		case *ast.Field:
+			return r.isChanType(decl.Type)
+		case *ast.ValueSpec:
+			if decl.Type != nil {
+				return r.isChanType(decl.Type)
+			}
+			for i, name := range decl.Names {
+				if name.Name == x.Name && i < len(decl.Values) {
+					return r.isMakeChan(decl.Values[i]) || r.isTimerCall(decl.Values[i])
+				}
+			}
+		case *ast.AssignStmt:
+			if len(decl.Lhs) != len(decl.Rhs) {
+				return false
+			}
+			for i, lhs := range decl.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == x.Name {
+					return r.isMakeChan(decl.Rhs[i]) || r.isTimerCall(decl.Rhs[i])
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isChanOrWeft reports whether t is a channel or a weft.Chan.
+func isChanOrWeft(t types.Type) bool {
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == WeftPath && obj.Name() == "Chan" {
+			return true
+		}
+	}
+	_, ok := t.Underlying().(*types.Chan)
+	return ok
+}
+
+// isBuiltin reports whether fun refers to the predeclared function name.
+func isBuiltin(fun ast.Expr, name string) bool {
+	id, ok := fun.(*ast.Ident)
+	return ok && id.Name == name && id.Obj == nil
+}
+
+// freshName returns base, or base with a numeric suffix, such that it is not
+// used as an identifier anywhere within n.
+func (r *rewriter) freshName(n ast.Node, base string) string {
+	used := make(map[string]bool)
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+	name := base
+	for i := 1; used[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	return name
+}
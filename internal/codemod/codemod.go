@@ -0,0 +1,289 @@
+// Package codemod rewrites standard Go concurrency primitives in a source
+// file into their weft equivalents.
+//
+// Rewrites are expressed as text edits against the original source so that
+// everything the rewriter does not touch, including comments and layout, is
+// preserved. Constructs that cannot be converted safely are left alone and
+// reported as diagnostics. Code excluded with a //weft:ignore directive is
+// neither converted nor reported.
+package codemod
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/imports"
+)
+
+// WeftPath is the import path of the weft package.
+const WeftPath = "github.com/mziter/weft"
+
+// maxPasses bounds the number of rewrite passes over a file. Nested
+// constructs (a channel of channels, a send of a receive) produce
+// overlapping edits; the inner one is deferred to the next pass.
+const maxPasses = 8
+
+// Diagnostic reports a construct the rewriter found but did not convert.
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// Result is the outcome of rewriting a single file.
+type Result struct {
+	// Source is the rewritten, gofmt-formatted file.
+	Source []byte
+
+	// Changed reports whether Source differs from the input.
+	Changed bool
+
+	// Diagnostics lists constructs that were left unconverted.
+	Diagnostics []Diagnostic
+}
+
+// Source rewrites the Go source file src. The filename is used for
+// positions in diagnostics only.
+func Source(filename string, src []byte) (*Result, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return File(fset, f, src, nil, nil)
+}
+
+// File rewrites f, parsed from src, which belongs to the type-checked package
+// pkg. When info is non-nil it is used in place of syntactic heuristics to
+// decide which expressions are channels. Type information only covers the
+// first pass; constructs deferred to later passes fall back to the
+// heuristics.
+func File(fset *token.FileSet, f *ast.File, src []byte, pkg *types.Package, info *types.Info) (*Result, error) {
+	filename := fset.Position(f.Pos()).Filename
+	res := &Result{Source: src}
+	seen := make(map[string]bool)
+	for pass := 0; pass < maxPasses; pass++ {
+		if pass > 0 {
+			fset = token.NewFileSet()
+			var err error
+			if f, err = parser.ParseFile(fset, filename, res.Source, parser.ParseComments); err != nil {
+				return nil, err
+			}
+			pkg, info = nil, nil
+		}
+		r := newRewriter(fset, f, res.Source)
+		r.pkg, r.info = pkg, info
+		r.rewrite()
+		for _, d := range r.diags {
+			if !seen[d.String()] {
+				seen[d.String()] = true
+				res.Diagnostics = append(res.Diagnostics, d)
+			}
+		}
+		if len(r.edits) == 0 {
+			break
+		}
+		out, deferred := applyEdits(fset.File(f.Pos()), res.Source, r.edits)
+		out, err := fixImports(filename, out, r.weft)
+		if err != nil {
+			return nil, err
+		}
+		res.Source = out
+		if !deferred {
+			break
+		}
+	}
+	res.Changed = !bytes.Equal(res.Source, src)
+	return res, nil
+}
+
+// edit replaces the source between pos and end with text. Edits made for
+// the same conversion share a group and are applied all or nothing.
+type edit struct {
+	pos, end token.Pos
+	text     string
+	group    int
+}
+
+// applyEdits applies edits to src. Groups with an edit overlapping an
+// earlier group are skipped and reported through deferred so the caller
+// can run another pass.
+func applyEdits(tf *token.File, src []byte, edits []edit) (out []byte, deferred bool) {
+	groups := make(map[int][]edit)
+	var order []int
+	for _, e := range edits {
+		if _, ok := groups[e.group]; !ok {
+			order = append(order, e.group)
+		}
+		groups[e.group] = append(groups[e.group], e)
+	}
+
+	// Outer constructs are visited first, so earlier groups win.
+	var accepted []edit
+	for _, g := range order {
+		ok := true
+		for _, e := range groups[g] {
+			for _, a := range accepted {
+				if overlaps(a, e) {
+					ok = false
+				}
+			}
+		}
+		if !ok {
+			deferred = true
+			continue
+		}
+		accepted = append(accepted, groups[g]...)
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		a, b := accepted[i], accepted[j]
+		if a.pos != b.pos {
+			return a.pos < b.pos
+		}
+		return a.pos == a.end && b.pos != b.end
+	})
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range accepted {
+		start, end := tf.Offset(e.pos), tf.Offset(e.end)
+		buf.Write(src[last:start])
+		buf.WriteString(e.text)
+		last = end
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), deferred
+}
+
+// overlaps reports whether two edits touch the same source. An insertion
+// overlaps a replacement only if it falls strictly inside it.
+func overlaps(a, b edit) bool {
+	if a.pos == a.end || b.pos == b.end {
+		return (a.pos < b.pos && b.pos < a.end) || (b.pos < a.pos && a.pos < b.end)
+	}
+	return a.pos < b.end && b.pos < a.end
+}
+
+// fixImports adds the weft import under the given local name when the file
+// refers to it, drops the sync and time imports if the rewrite left them
+// unused, and formats the result the way goimports would, grouping the weft
+// import with other non-standard imports. Other imports are left alone.
+func fixImports(filename string, src []byte, weftName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("rewrite produced invalid source: %w", err)
+	}
+	for _, path := range []string{"sync", "time"} {
+		if importName(f, path) != "" && !astutil.UsesImport(f, path) {
+			deleteImport(fset, f, path)
+		}
+	}
+	if importName(f, WeftPath) == "" && refersTo(f, weftName) {
+		alias := weftName
+		if alias == defaultName(WeftPath) {
+			alias = ""
+		}
+		astutil.AddNamedImport(fset, f, alias, WeftPath)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, err
+	}
+	return imports.Process(filename, buf.Bytes(), &imports.Options{
+		FormatOnly: true,
+		Comments:   true,
+		TabIndent:  true,
+		TabWidth:   8,
+	})
+}
+
+// refersTo reports whether the file contains an unresolved selector on name,
+// which after a rewrite means a reference to a not-yet-imported package.
+func refersTo(f *ast.File, name string) bool {
+	found := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == name && id.Obj == nil {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// deleteImport removes the import of path along with any comment trailing
+// it on the same line.
+func deleteImport(fset *token.FileSet, f *ast.File, path string) {
+	for _, spec := range f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != path {
+			continue
+		}
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		line := fset.Position(spec.Pos()).Line
+		astutil.DeleteNamedImport(fset, f, name, path)
+
+		comments := f.Comments[:0]
+		for _, cg := range f.Comments {
+			if cg.Pos() > spec.Pos() && fset.Position(cg.Pos()).Line == line {
+				continue
+			}
+			comments = append(comments, cg)
+		}
+		f.Comments = comments
+		return
+	}
+}
+
+// importName returns the local name under which f imports path, or "" if it
+// does not import it.
+func importName(f *ast.File, path string) string {
+	for _, spec := range f.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || p != path {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return defaultName(p)
+	}
+	return ""
+}
+
+func defaultName(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
+
+// Equivalent reports whether the package-level name in the standard package
+// path has a weft equivalent of the same name that the rewriter converts it
+// to.
+func Equivalent(path, name string) bool {
+	switch path {
+	case "sync":
+		return syncNames[name]
+	case "time":
+		return timeNames[name]
+	}
+	return false
+}
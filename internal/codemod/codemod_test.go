@@ -0,0 +1,836 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+// TestSource verifies conversions against expected output.
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "mutex and goroutine",
+			in: `package p
+
+import "sync"
+
+type T struct {
+	mu sync.Mutex
+}
+
+func (t *T) Start() {
+	go func() {
+		t.mu.Lock()
+		t.mu.Unlock()
+	}()
+}
+`,
+			want: `package p
+
+import "github.com/mziter/weft"
+
+type T struct {
+	mu weft.Mutex
+}
+
+func (t *T) Start() {
+	weft.Go(func(weft.Context) {
+		t.mu.Lock()
+		t.mu.Unlock()
+	})
+}
+`,
+		},
+		{
+			name: "once",
+			in: `package p
+
+import "sync"
+
+var (
+	once sync.Once
+	load = sync.OnceValue(func() int { return 1 })
+)
+`,
+			want: `package p
+
+import "github.com/mziter/weft"
+
+var (
+	once weft.Once
+	load = weft.OnceValue(func() int { return 1 })
+)
+`,
+		},
+		{
+			name: "goroutine arguments are bound at spawn",
+			in: `package p
+
+func f(n int) {}
+
+func g(x int) {
+	go f(x)
+	go func(n int) {
+		f(n)
+	}(x)
+}
+`,
+			want: `package p
+
+import "github.com/mziter/weft"
+
+func f(n int) {}
+
+func g(x int) {
+	{
+		arg0 := x
+		weft.Go(func(weft.Context) {
+			f(arg0)
+		})
+	}
+	func(n int) {
+		weft.Go(func(weft.Context) {
+			f(n)
+		})
+	}(x)
+}
+`,
+		},
+		{
+			name: "goroutine function values are bound at spawn",
+			in: `package p
+
+import "net"
+
+type server struct{}
+
+func (s *server) handle(c net.Conn) {}
+
+func serve(s *server, c net.Conn, fns []func()) {
+	go s.handle(c)
+	for i := range fns {
+		go fns[i]()
+	}
+	go net.Dial("tcp", "a:1")
+}
+`,
+			want: `package p
+
+import (
+	"net"
+
+	"github.com/mziter/weft"
+)
+
+type server struct{}
+
+func (s *server) handle(c net.Conn) {}
+
+func serve(s *server, c net.Conn, fns []func()) {
+	{
+		fn, arg0 := s.handle, c
+		weft.Go(func(weft.Context) {
+			fn(arg0)
+		})
+	}
+	for i := range fns {
+		{
+			fn := fns[i]
+			weft.Go(func(weft.Context) {
+				fn()
+			})
+		}
+	}
+	weft.Go(func(weft.Context) {
+		net.Dial("tcp", "a:1")
+	})
+}
+`,
+		},
+		{
+			name: "channel operations",
+			in: `package p
+
+func f() {
+	ch := make(chan int, 1)
+	done := make(chan struct{})
+	ch <- 1
+	v := <-ch
+	v, ok := <-ch
+	<-done
+	close(done)
+	for x := range ch {
+		_ = x
+	}
+	_, _ = v, ok
+}
+`,
+			want: `package p
+
+import "github.com/mziter/weft"
+
+func f() {
+	ch := weft.MakeChan[int](1)
+	done := weft.MakeChan[struct{}](0)
+	ch.Send(1)
+	v, _ := ch.Recv()
+	v, ok := ch.Recv()
+	done.Recv()
+	done.Close()
+	for x, ok := ch.Recv(); ok; x, ok = ch.Recv() {
+		_ = x
+	}
+	_, _ = v, ok
+}
+`,
+		},
+		{
+			name: "nested channel types",
+			in: `package p
+
+var reqs = make(chan chan int)
+`,
+			want: `package p
+
+import "github.com/mziter/weft"
+
+var reqs = weft.MakeChan[weft.Chan[int]](0)
+`,
+		},
+		{
+			name: "select with default and bindings",
+			in: `package p
+
+func f(in chan int, out chan string, done chan struct{}) {
+loop:
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				break loop
+			}
+			_ = v
+		case out <- "x":
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+`,
+			want: `package p
+
+import "github.com/mziter/weft"
+
+func f(in weft.Chan[int], out weft.Chan[string], done weft.Chan[struct{}]) {
+loop:
+	for {
+		switch recv0 := weft.OnRecv(in); weft.TrySelect(recv0, weft.OnSend(out, "x"), weft.OnRecv(done)) {
+		case 0:
+			v, ok := recv0.Value()
+			if !ok {
+				break loop
+			}
+			_ = v
+		case 1:
+		case 2:
+			return
+		default:
+		}
+	}
+}
+`,
+		},
+		{
+			name: "select with single binding and empty select",
+			in: `package p
+
+func f(in chan int) int {
+	var v int
+	select {
+	case v = <-in:
+	}
+	select {}
+	return v
+}
+`,
+			want: `package p
+
+import "github.com/mziter/weft"
+
+func f(in weft.Chan[int]) int {
+	var v int
+	switch recv0 := weft.OnRecv(in); weft.Select(recv0) {
+	case 0:
+		v, _ = recv0.Value()
+	}
+	weft.Select()
+	return v
+}
+`,
+		},
+		{
+			name: "time functions",
+			in: `package p
+
+import "time"
+
+func f(in chan int) {
+	time.Sleep(time.Millisecond)
+	select {
+	case <-in:
+	case <-time.After(time.Second):
+	}
+}
+`,
+			want: `package p
+
+import (
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+func f(in weft.Chan[int]) {
+	weft.Sleep(time.Millisecond)
+	switch weft.Select(weft.OnRecv(in), weft.OnRecv(weft.After(time.Second))) {
+	case 0:
+	case 1:
+	}
+}
+`,
+		},
+		{
+			name: "unused time import is removed",
+			in: `package p
+
+import "time"
+
+func f() {
+	time.Sleep(1e6)
+	<-time.After(1e9)
+	time.AfterFunc(1e9, func() {}).Stop()
+	_ = time.Now()
+}
+`,
+			want: `package p
+
+import "github.com/mziter/weft"
+
+func f() {
+	weft.Sleep(1e6)
+	weft.After(1e9).Recv()
+	weft.AfterFunc(1e9, func() {}).Stop()
+	_ = weft.Now()
+}
+`,
+		},
+		{
+			name: "tick",
+			in: `package p
+
+import "time"
+
+func f(work func()) {
+	tick := time.Tick(time.Second)
+	for range tick {
+		work()
+	}
+	<-time.Tick(time.Minute)
+}
+`,
+			want: `package p
+
+import (
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+func f(work func()) {
+	tick := weft.Tick(time.Second)
+	for _, ok := tick.Recv(); ok; _, ok = tick.Recv() {
+		work()
+	}
+	weft.Tick(time.Minute).Recv()
+}
+`,
+		},
+		{
+			name: "ticker",
+			in: `package p
+
+import "time"
+
+type flusher struct {
+	ticker *time.Ticker
+}
+
+func (f *flusher) run(done chan struct{}) {
+	for {
+		select {
+		case <-f.ticker.C:
+		case <-done:
+			f.ticker.Stop()
+			return
+		}
+	}
+}
+
+func g() {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for now := range t.C {
+		_ = now
+	}
+}
+`,
+			want: `package p
+
+import (
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+type flusher struct {
+	ticker *weft.Ticker
+}
+
+func (f *flusher) run(done weft.Chan[struct{}]) {
+	for {
+		switch weft.Select(weft.OnRecv(f.ticker.C), weft.OnRecv(done)) {
+		case 0:
+		case 1:
+			f.ticker.Stop()
+			return
+		}
+	}
+}
+
+func g() {
+	t := weft.NewTicker(time.Second)
+	defer t.Stop()
+	for now, ok := t.C.Recv(); ok; now, ok = t.C.Recv() {
+		_ = now
+	}
+}
+`,
+		},
+		{
+			name: "timer",
+			in: `package p
+
+import "time"
+
+func f(in chan int) int {
+	var t *time.Timer = time.NewTimer(time.Second)
+	select {
+	case v := <-in:
+		if !t.Stop() {
+			<-t.C
+		}
+		return v
+	case <-t.C:
+		return 0
+	}
+}
+
+func g() time.Time {
+	t := time.NewTimer(time.Second)
+	t.Reset(time.Minute)
+	now := <-t.C
+	return now
+}
+`,
+			want: `package p
+
+import (
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+func f(in weft.Chan[int]) int {
+	var t *weft.Timer = weft.NewTimer(time.Second)
+	switch recv0 := weft.OnRecv(in); weft.Select(recv0, weft.OnRecv(t.C)) {
+	case 0:
+		v, _ := recv0.Value()
+		if !t.Stop() {
+			t.C.Recv()
+		}
+		return v
+	case 1:
+		return 0
+	}
+}
+
+func g() time.Time {
+	t := weft.NewTimer(time.Second)
+	t.Reset(time.Minute)
+	now, _ := t.C.Recv()
+	return now
+}
+`,
+		},
+		{
+			name: "x/sync packages",
+			in: `package p
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+	sem "golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	s     = sem.NewWeighted(2)
+	group singleflight.Group
+)
+
+func f(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return s.Acquire(ctx, 1)
+	})
+	return g.Wait()
+}
+`,
+			want: `package p
+
+import (
+	"context"
+
+	"github.com/mziter/weft/errgroup"
+	sem "github.com/mziter/weft/semaphore"
+	"github.com/mziter/weft/singleflight"
+)
+
+var (
+	s     = sem.NewWeighted(2)
+	group singleflight.Group
+)
+
+func f(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return s.Acquire(ctx, 1)
+	})
+	return g.Wait()
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Source("p.go", []byte(tt.in))
+			if err != nil {
+				t.Fatalf("Source: %v", err)
+			}
+			if got := string(res.Source); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if len(res.Diagnostics) != 0 {
+				t.Errorf("unexpected diagnostics: %v", res.Diagnostics)
+			}
+		})
+	}
+}
+
+// TestSourceIdempotent verifies that converted code is left unchanged.
+func TestSourceIdempotent(t *testing.T) {
+	src := `package p
+
+import "github.com/mziter/weft"
+
+func f(in weft.Chan[int]) {
+	weft.Go(func(weft.Context) {
+		in.Send(1)
+	})
+}
+`
+	res, err := Source("p.go", []byte(src))
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+	if res.Changed {
+		t.Errorf("converted source changed:\n%s", res.Source)
+	}
+}
+
+// TestSourceDiagnostics verifies that unsafe constructs are reported and
+// left alone.
+func TestSourceDiagnostics(t *testing.T) {
+	src := `package p
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+func f(ctx context.Context, in chan int) int {
+	var wg sync.WaitGroup
+	wg.Wait()
+	var cache sync.Map
+	cache.Store(1, 2)
+	_ = time.Since(time.Time{})
+	select {
+	case <-ctx.Done():
+	case <-in:
+	}
+	return <-in + 1
+}
+`
+	res, err := Source("p.go", []byte(src))
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+	var msgs []string
+	for _, d := range res.Diagnostics {
+		msgs = append(msgs, d.Message)
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		"sync.WaitGroup has no weft equivalent",
+		"sync.Map has a weft equivalent, weft.Map[K, V]",
+		"select on channel returned by ctx.Done()",
+		"receive used inside an expression",
+		"time.Since has no weft equivalent",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("missing diagnostic %q in:\n%s", want, all)
+		}
+	}
+	if !strings.Contains(string(res.Source), "case <-ctx.Done():") {
+		t.Errorf("select with unconvertible case was rewritten:\n%s", res.Source)
+	}
+}
+
+// TestSourceIgnoreDirective verifies that ignored code is neither converted
+// nor reported.
+func TestSourceIgnoreDirective(t *testing.T) {
+	src := `package p
+
+import "sync"
+
+type T struct {
+	mu sync.Mutex //weft:ignore contended hot path
+	wg sync.WaitGroup //weft:ignore
+}
+
+func (t *T) Start() {
+	go t.Start()
+}
+`
+	res, err := Source("p.go", []byte(src))
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+	if len(res.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", res.Diagnostics)
+	}
+	out := string(res.Source)
+	if !strings.Contains(out, "mu sync.Mutex") || !strings.Contains(out, `"sync"`) {
+		t.Errorf("ignored field was converted:\n%s", out)
+	}
+	if !strings.Contains(out, "weft.Go(") {
+		t.Errorf("go statement outside directive not converted:\n%s", out)
+	}
+}
+
+// TestSourcePreservesFormatting verifies that build constraints, comments
+// and untouched imports survive a rewrite.
+func TestSourcePreservesFormatting(t *testing.T) {
+	src := `//go:build linux
+
+// Package p is an example.
+package p
+
+import (
+	"fmt"  // for printing
+	"sync" // guards state
+
+	"example.com/other"
+)
+
+// State is shared.
+type State struct {
+	// mu guards n.
+	mu sync.Mutex
+	n  int // counter
+}
+
+func (s *State) Print() {
+	s.mu.Lock() // hold while printing
+	fmt.Println(s.n, other.X)
+	s.mu.Unlock()
+}
+`
+	want := `//go:build linux
+
+// Package p is an example.
+package p
+
+import (
+	"fmt" // for printing
+
+	"example.com/other"
+	"github.com/mziter/weft"
+)
+
+// State is shared.
+type State struct {
+	// mu guards n.
+	mu weft.Mutex
+	n  int // counter
+}
+
+func (s *State) Print() {
+	s.mu.Lock() // hold while printing
+	fmt.Println(s.n, other.X)
+	s.mu.Unlock()
+}
+`
+	res, err := Source("p.go", []byte(src))
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+	if got := string(res.Source); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestSourceShadowedWeft verifies that the weft import is renamed when the
+// file already declares the name weft.
+func TestSourceShadowedWeft(t *testing.T) {
+	src := `package p
+
+import "sync"
+
+var weft = 1
+
+var mu sync.Mutex
+`
+	res, err := Source("p.go", []byte(src))
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+	out := string(res.Source)
+	if !strings.Contains(out, `weftpkg "github.com/mziter/weft"`) || !strings.Contains(out, "var mu weftpkg.Mutex") {
+		t.Errorf("weft import not renamed:\n%s", out)
+	}
+}
+
+// TestFileTypeInfo verifies that type information identifies channels the
+// syntactic heuristics miss.
+func TestFileTypeInfo(t *testing.T) {
+	src := `package p
+
+func events() chan int { return nil }
+
+func f() {
+	ch := events()
+	for v := range ch {
+		_ = v
+	}
+	select {
+	case <-events():
+	default:
+	}
+	for v := range events() {
+		_ = v
+	}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := File(fset, f, []byte(src), pkg, info)
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+	out := string(res.Source)
+	for _, want := range []string{
+		"for v, ok := ch.Recv(); ok; v, ok = ch.Recv() {",
+		"weft.TrySelect(weft.OnRecv(events()))",
+		"for v := range events() {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if len(res.Diagnostics) != 1 || !strings.Contains(res.Diagnostics[0].Message, "range over channel returned by events()") {
+		t.Errorf("unexpected diagnostics: %v", res.Diagnostics)
+	}
+}
+
+// TestFileTypeInfoGoFunc verifies that type information decides which go
+// statement functions are values bound at the statement.
+func TestFileTypeInfoGoFunc(t *testing.T) {
+	src := `package p
+
+var hook func()
+
+func work() {}
+
+func run[T any]() {}
+
+func f() {
+	go hook()
+	go work()
+	go run[int]()
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types:     make(map[ast.Expr]types.TypeAndValue),
+		Defs:      make(map[*ast.Ident]types.Object),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Instances: make(map[*ast.Ident]types.Instance),
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := File(fset, f, []byte(src), pkg, info)
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+	out := string(res.Source)
+	for _, want := range []string{
+		"fn := hook",
+		"weft.Go(func(weft.Context) {\n\t\twork()\n\t})",
+		"weft.Go(func(weft.Context) {\n\t\trun[int]()\n\t})",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
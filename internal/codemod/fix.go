@@ -0,0 +1,69 @@
+package codemod
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// Edit replaces the source between Pos and End with NewText.
+type Edit struct {
+	Pos, End token.Pos
+	NewText  []byte
+}
+
+// Fix returns the edits that convert the single construct n in f, parsed
+// from src, along with any changes to the file's imports. Constructs nested
+// in n that need their own conversion, such as the channels in the body of a
+// go statement, are left for their own fixes. Fix returns no edits if n
+// cannot be converted.
+func Fix(fset *token.FileSet, f *ast.File, src []byte, n ast.Node, pkg *types.Package, info *types.Info) ([]Edit, error) {
+	r := newRewriter(fset, f, src)
+	r.pkg, r.info = pkg, info
+	r.only = n
+	r.rewrite()
+	if len(r.edits) == 0 {
+		return nil, nil
+	}
+
+	var edits []Edit
+	for _, e := range r.edits {
+		edits = append(edits, Edit{Pos: e.pos, End: e.end, NewText: []byte(e.text)})
+	}
+
+	tf := fset.File(f.Pos())
+	out, _ := applyEdits(tf, src, r.edits)
+	filename := tf.Name()
+	out, err := fixImports(filename, out, r.weft)
+	if err != nil {
+		return nil, err
+	}
+	nf, err := parser.ParseFile(token.NewFileSet(), filename, out, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	oldStart, oldEnd := importsRange(f)
+	newStart, newEnd := importsRange(nf)
+	oldText := src[tf.Offset(oldStart):tf.Offset(oldEnd)]
+	newText := out[int(newStart)-int(nf.FileStart) : int(newEnd)-int(nf.FileStart)]
+	if !bytes.Equal(oldText, newText) {
+		edits = append(edits, Edit{Pos: oldStart, End: oldEnd, NewText: newText})
+	}
+	return edits, nil
+}
+
+// importsRange returns the extent of f from the end of the package name to
+// the end of its last import declaration.
+func importsRange(f *ast.File) (start, end token.Pos) {
+	start, end = f.Name.End(), f.Name.End()
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			break
+		}
+		end = gd.End()
+	}
+	return start, end
+}
@@ -0,0 +1,220 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/mziter/weft/internal/ignore"
+)
+
+// rewriter collects the edits for a single pass over a file.
+type rewriter struct {
+	fset *token.FileSet
+	file *ast.File
+	src  []byte
+
+	// pkg and info describe the type-checked package containing the file.
+	// Both are nil when only syntax is available.
+	pkg  *types.Package
+	info *types.Info
+
+	// weft is the local name used to refer to the weft package.
+	weft string
+
+	// ignored holds the nodes excluded by //weft:ignore directives.
+	ignored *ignore.Set
+
+	// chanFields holds the names of struct fields declared with a
+	// channel type anywhere in the file.
+	chanFields map[string]bool
+
+	// timerFields holds the names of struct fields declared with a
+	// timer or ticker type anywhere in the file.
+	timerFields map[string]bool
+
+	// only, when set, restricts the rewrite to that node.
+	only ast.Node
+
+	edits []edit
+	group int
+	diags []Diagnostic
+}
+
+func newRewriter(fset *token.FileSet, f *ast.File, src []byte) *rewriter {
+	r := &rewriter{
+		fset:        fset,
+		file:        f,
+		src:         src,
+		weft:        importName(f, WeftPath),
+		ignored:     ignore.File(fset, f),
+		chanFields:  make(map[string]bool),
+		timerFields: make(map[string]bool),
+	}
+	if r.weft == "" {
+		r.weft = freeName(f, defaultName(WeftPath))
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if st, ok := n.(*ast.StructType); ok {
+			for _, field := range st.Fields.List {
+				if r.isChanType(field.Type) {
+					for _, name := range field.Names {
+						r.chanFields[name.Name] = true
+					}
+				}
+				if r.isTimerType(field.Type) {
+					for _, name := range field.Names {
+						r.timerFields[name.Name] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+	return r
+}
+
+// rewrite walks the file and records edits for every convertible construct.
+func (r *rewriter) rewrite() {
+	handled := make(map[ast.Node]bool)
+	ast.Inspect(r.file, func(n ast.Node) bool {
+		if n == nil || handled[n] {
+			return true
+		}
+		if r.ignored.Ignores(n) {
+			return false
+		}
+		if r.only != nil && n != r.only {
+			return true
+		}
+		r.group++
+		switch n := n.(type) {
+		case *ast.ImportSpec:
+			r.importSpec(n)
+		case *ast.GoStmt:
+			r.goStmt(n)
+		case *ast.SelectorExpr:
+			r.syncSelector(n)
+			r.timeSelector(n)
+		case *ast.ChanType:
+			r.chanType(n)
+		case *ast.CallExpr:
+			if r.makeChan(n) {
+				// The channel type is rewritten as part of the call.
+				handled[n.Args[0]] = true
+			}
+			r.closeChan(n)
+		case *ast.SendStmt:
+			r.send(n)
+		case *ast.ExprStmt:
+			if r.recvStmt(n) {
+				handled[n.X] = true
+			}
+		case *ast.AssignStmt:
+			if r.recvAssign(n) {
+				handled[n.Rhs[0]] = true
+			}
+		case *ast.ValueSpec:
+			if r.recvValueSpec(n) {
+				handled[n.Values[0]] = true
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				r.report(n, "receive used inside an expression; not converted")
+			}
+		case *ast.RangeStmt:
+			r.rangeChan(n)
+		case *ast.SelectStmt:
+			// Comm clauses are converted with their select statement or
+			// not at all.
+			r.selectStmt(n)
+			for _, clause := range n.Body.List {
+				if comm := clause.(*ast.CommClause).Comm; comm != nil {
+					handled[comm] = true
+					markRecv(comm, handled)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// markRecv marks the receive expression of a select comm clause as handled.
+func markRecv(comm ast.Stmt, handled map[ast.Node]bool) {
+	switch comm := comm.(type) {
+	case *ast.ExprStmt:
+		handled[comm.X] = true
+	case *ast.AssignStmt:
+		handled[comm.Rhs[0]] = true
+	}
+}
+
+// freeName returns name, or a variant of it, that no declaration in f uses,
+// so that a newly added import under that name is not shadowed.
+func freeName(f *ast.File, name string) string {
+	declared := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Obj != nil {
+			declared[id.Name] = true
+		}
+		return true
+	})
+	for _, obj := range f.Scope.Objects {
+		declared[obj.Name] = true
+	}
+	base := name
+	for i := 0; declared[name]; i++ {
+		name = base + "pkg"
+		if i > 0 {
+			name += strconv.Itoa(i)
+		}
+	}
+	return name
+}
+
+// text returns the source text of n.
+func (r *rewriter) text(n ast.Node) string {
+	return string(r.src[r.offset(n.Pos()):r.offset(n.End())])
+}
+
+// operand returns the source text of x, parenthesized if needed to use it as
+// the receiver of a method call.
+func (r *rewriter) operand(x ast.Expr) string {
+	switch x.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr,
+		*ast.CallExpr, *ast.ParenExpr, *ast.CompositeLit:
+		return r.text(x)
+	}
+	return "(" + r.text(x) + ")"
+}
+
+func (r *rewriter) offset(pos token.Pos) int {
+	return r.fset.File(r.file.Pos()).Offset(pos)
+}
+
+func (r *rewriter) replace(pos, end token.Pos, text string) {
+	r.edits = append(r.edits, edit{pos: pos, end: end, text: text, group: r.group})
+}
+
+func (r *rewriter) insert(pos token.Pos, text string) {
+	r.replace(pos, pos, text)
+}
+
+func (r *rewriter) report(n ast.Node, format string, args ...interface{}) {
+	r.diags = append(r.diags, Diagnostic{
+		Pos:     r.fset.Position(n.Pos()),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// isPkg reports whether x refers to the package imported from path.
+func (r *rewriter) isPkg(x ast.Expr, path string) bool {
+	id, ok := x.(*ast.Ident)
+	if !ok || id.Obj != nil {
+		return false
+	}
+	name := importName(r.file, path)
+	return name != "" && id.Name == name
+}
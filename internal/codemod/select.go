@@ -0,0 +1,114 @@
+package codemod
+
+import (
+	"go/ast"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// selectStmt rewrites a select statement into a switch over weft.Select:
+//
+//	select {                      switch recv0 := weft.OnRecv(in); weft.TrySelect(recv0, weft.OnSend(out, v)) {
+//	case x, ok := <-in:           case 0:
+//	                                      x, ok := recv0.Value()
+//		...                               ...
+//	case out <- v:                case 1:
+//		...                               ...
+//	default:                      default:
+//		...                               ...
+//	}                             }
+//
+// Case bodies are left in place, so break statements and labels keep their
+// meaning. The select is left unconverted if any of its cases is not.
+func (r *rewriter) selectStmt(s *ast.SelectStmt) bool {
+	if len(s.Body.List) == 0 {
+		r.replace(s.Pos(), s.End(), r.weft+".Select()")
+		return true
+	}
+
+	var (
+		cases      []string
+		initNames  []string
+		initValues []string
+		headers    = make(map[*ast.CommClause]string)
+		hasDefault bool
+	)
+	for _, stmt := range s.Body.List {
+		cc := stmt.(*ast.CommClause)
+		index := len(cases)
+		header := "case " + strconv.Itoa(index) + ":"
+		switch comm := cc.Comm.(type) {
+		case nil:
+			hasDefault = true
+			continue
+		case *ast.SendStmt:
+			if !r.selectOperand(comm.Chan) {
+				return false
+			}
+			cases = append(cases, r.weft+".OnSend("+r.text(comm.Chan)+", "+r.text(comm.Value)+")")
+		case *ast.ExprStmt:
+			u := comm.X.(*ast.UnaryExpr)
+			if !r.selectOperand(u.X) {
+				return false
+			}
+			cases = append(cases, r.weft+".OnRecv("+r.text(u.X)+")")
+		case *ast.AssignStmt:
+			u := comm.Rhs[0].(*ast.UnaryExpr)
+			if !r.selectOperand(u.X) {
+				return false
+			}
+			name := r.freshName(s, "recv"+strconv.Itoa(index))
+			initNames = append(initNames, name)
+			initValues = append(initValues, r.weft+".OnRecv("+r.text(u.X)+")")
+			cases = append(cases, name)
+
+			lhs := make([]string, len(comm.Lhs))
+			for i, x := range comm.Lhs {
+				lhs[i] = r.text(x)
+			}
+			if len(lhs) == 1 {
+				lhs = append(lhs, "_")
+			}
+			header += "\n" + strings.Join(lhs, ", ") + " " + comm.Tok.String() + " " + name + ".Value()"
+		}
+		headers[cc] = header
+	}
+
+	fn := ".Select("
+	if hasDefault {
+		fn = ".TrySelect("
+	}
+	head := "switch "
+	if len(initNames) > 0 {
+		head += strings.Join(initNames, ", ") + " := " + strings.Join(initValues, ", ") + "; "
+	}
+	head += r.weft + fn + strings.Join(cases, ", ") + ") {"
+
+	r.replace(s.Select, s.Body.Lbrace+1, head)
+	for cc, header := range headers {
+		r.replace(cc.Case, cc.Colon+1, header)
+	}
+	return true
+}
+
+// selectOperand reports whether the channel operand of a select case can be
+// converted. Channels produced by calls, such as ctx.Done(), may not be weft
+// channels and are left alone; timer channels from After are weft channels,
+// as are the results of functions declared in the package being converted.
+func (r *rewriter) selectOperand(x ast.Expr) bool {
+	if r.isTimerCall(x) {
+		return true
+	}
+	if call, ok := ast.Unparen(x).(*ast.CallExpr); ok {
+		if r.info != nil {
+			if fn := typeutil.Callee(r.info, call); fn != nil && r.pkg != nil && fn.Pkg() == r.pkg {
+				return true
+			}
+		}
+		r.report(x, "select on channel returned by %s; not converted", r.text(x))
+		return false
+	}
+	return true
+}
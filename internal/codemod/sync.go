@@ -0,0 +1,173 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// syncNames lists the sync identifiers that have a weft equivalent of the
+// same name.
+var syncNames = map[string]bool{
+	"Mutex":      true,
+	"RWMutex":    true,
+	"Cond":       true,
+	"NewCond":    true,
+	"Locker":     true,
+	"Once":       true,
+	"OnceFunc":   true,
+	"OnceValue":  true,
+	"OnceValues": true,
+}
+
+// syncSelector rewrites references to sync types and functions.
+func (r *rewriter) syncSelector(sel *ast.SelectorExpr) {
+	if !r.isPkg(sel.X, "sync") {
+		return
+	}
+	if sel.Sel.Name == "Map" {
+		r.report(sel, "sync.Map has a weft equivalent, weft.Map[K, V], with typed keys and values; convert it and its type assertions by hand")
+		return
+	}
+	if !syncNames[sel.Sel.Name] {
+		r.report(sel, "sync.%s has no weft equivalent; not converted", sel.Sel.Name)
+		return
+	}
+	r.replace(sel.X.Pos(), sel.X.End(), r.weft)
+}
+
+// goStmt rewrites a go statement to weft.Go. The function value and the
+// arguments are evaluated at the point of the go statement, as they would be
+// for the original goroutine.
+func (r *rewriter) goStmt(g *ast.GoStmt) {
+	call := g.Call
+	spawn := r.weft + ".Go(func(" + r.weft + ".Context) {"
+	if lit, ok := call.Fun.(*ast.FuncLit); ok {
+		if lit.Type.Results != nil {
+			r.report(g, "go statement calling a function literal with results; not converted")
+			return
+		}
+		if len(call.Args) == 0 {
+			r.replace(g.Go, lit.Body.Lbrace+1, spawn)
+			r.replace(lit.Body.Rbrace, call.End(), "})")
+			return
+		}
+		// Keep the literal's parameters so the arguments are still bound
+		// when the statement executes, and spawn from inside the call.
+		r.replace(g.Go, lit.Body.Lbrace+1, "func"+r.text(lit.Type.Params)+" {\n"+spawn)
+		r.replace(lit.Body.Rbrace, lit.Body.Rbrace+1, "})\n}")
+		return
+	}
+	bindFun := r.isFuncValue(call.Fun)
+	if len(call.Args) == 0 && !bindFun {
+		r.replace(g.Go, call.Pos(), spawn+"\n")
+		r.insert(call.End(), "\n})")
+		return
+	}
+	// Bind the function value and the arguments to fresh variables so they
+	// are evaluated now rather than when the task runs. Literals and named
+	// functions need no binding.
+	var names, args, params []string
+	fun := r.text(call.Fun)
+	if bindFun {
+		name := r.freshName(g, "fn")
+		names = append(names, name)
+		args = append(args, fun)
+		fun = name
+	}
+	for i, arg := range call.Args {
+		if _, ok := arg.(*ast.BasicLit); ok {
+			params = append(params, r.text(arg))
+			continue
+		}
+		name := r.freshName(g, "arg"+strconv.Itoa(i))
+		names = append(names, name)
+		args = append(args, r.text(arg))
+		params = append(params, name)
+	}
+	ellipsis := ""
+	if call.Ellipsis.IsValid() {
+		ellipsis = "..."
+	}
+	body := spawn + "\n" + fun + "(" + strings.Join(params, ", ") + ellipsis + ")\n})"
+	if len(names) == 0 {
+		r.replace(g.Pos(), g.End(), body)
+		return
+	}
+	r.replace(g.Pos(), g.End(), "{\n"+
+		strings.Join(names, ", ")+" := "+strings.Join(args, ", ")+"\n"+body+"\n}")
+}
+
+// isFuncValue reports whether fun, the function of a go statement, is a
+// value evaluated by the statement that could change before the task runs:
+// a variable, a method value, an element or a call result, but not a
+// declared function, a function of another package or a builtin.
+func (r *rewriter) isFuncValue(fun ast.Expr) bool {
+	switch x := fun.(type) {
+	case *ast.ParenExpr:
+		return r.isFuncValue(x.X)
+	case *ast.Ident:
+		if r.info != nil {
+			_, ok := r.info.Uses[x].(*types.Var)
+			return ok
+		}
+		return x.Obj != nil && x.Obj.Kind == ast.Var
+	case *ast.SelectorExpr:
+		if r.info != nil {
+			if id, ok := x.X.(*ast.Ident); ok {
+				if _, ok := r.info.Uses[id].(*types.PkgName); ok {
+					_, ok := r.info.Uses[x.Sel].(*types.Var)
+					return ok
+				}
+			}
+			return true
+		}
+		return !r.isPkgName(x.X)
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		// An instantiation of a generic function is not a value to bind.
+		if r.info != nil {
+			if id := funcIdent(x); id != nil {
+				if _, ok := r.info.Instances[id]; ok {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// funcIdent returns the identifier naming the function instantiated by x,
+// or nil.
+func funcIdent(x ast.Expr) *ast.Ident {
+	switch x := x.(type) {
+	case *ast.IndexExpr:
+		return funcIdent(x.X)
+	case *ast.IndexListExpr:
+		return funcIdent(x.X)
+	case *ast.SelectorExpr:
+		return x.Sel
+	case *ast.Ident:
+		return x
+	}
+	return nil
+}
+
+// isPkgName reports whether x names an imported package.
+func (r *rewriter) isPkgName(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	if !ok || id.Obj != nil {
+		return false
+	}
+	for _, spec := range r.file.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if name := importName(r.file, p); name == id.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package codemod
+
+import (
+	"go/ast"
+	"slices"
+)
+
+// timeNames lists the time functions and types that have a weft equivalent
+// of the same name. The channels of Tick and After, and the C of a Timer or
+// Ticker, are weft.Chans, which the receives from them are converted to
+// use.
+var timeNames = map[string]bool{
+	"Sleep":     true,
+	"After":     true,
+	"AfterFunc": true,
+	"Tick":      true,
+	"NewTicker": true,
+	"NewTimer":  true,
+	"Ticker":    true,
+	"Timer":     true,
+	"Now":       true,
+}
+
+// timeUnsupported lists time functions that depend on the wall clock or
+// real timers and have no weft equivalent yet.
+var timeUnsupported = map[string]bool{
+	"Since": true,
+	"Until": true,
+}
+
+// timeSelector rewrites references to time functions driven by the clock.
+func (r *rewriter) timeSelector(sel *ast.SelectorExpr) {
+	if !r.isPkg(sel.X, "time") {
+		return
+	}
+	switch {
+	case timeNames[sel.Sel.Name]:
+		r.replace(sel.X.Pos(), sel.X.End(), r.weft)
+	case timeUnsupported[sel.Sel.Name]:
+		r.report(sel, "time.%s has no weft equivalent; not converted", sel.Sel.Name)
+	}
+}
+
+// isTimerCall reports whether x is a call to After or Tick of time or weft,
+// whose result is a weft channel once converted.
+func (r *rewriter) isTimerCall(x ast.Expr) bool {
+	return r.isTimeCall(x, "After", "Tick")
+}
+
+// isTimer reports whether x is known to be a Timer or Ticker of time or
+// weft, whose C is a weft channel once converted. Without type information
+// it follows the declaration of identifiers within the file and matches
+// field selectors against timer fields declared in the file.
+func (r *rewriter) isTimer(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return r.isTimer(x.X)
+	case *ast.SelectorExpr:
+		return r.timerFields[x.Sel.Name]
+	case *ast.Ident:
+		if x.Obj == nil {
+			return false
+		}
+		switch decl := x.Obj.Decl.(type) {
+		case *ast.Field:
+			return r.isTimerType(decl.Type)
+		case *ast.ValueSpec:
+			if decl.Type != nil {
+				return r.isTimerType(decl.Type)
+			}
+			for i, name := range decl.Names {
+				if name.Name == x.Name && i < len(decl.Values) {
+					return r.isTimeCall(decl.Values[i], "NewTimer", "NewTicker")
+				}
+			}
+		case *ast.AssignStmt:
+			if len(decl.Lhs) != len(decl.Rhs) {
+				return false
+			}
+			for i, lhs := range decl.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == x.Name {
+					return r.isTimeCall(decl.Rhs[i], "NewTimer", "NewTicker")
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isTimerType reports whether t is a pointer to a Timer or Ticker of time
+// or weft.
+func (r *rewriter) isTimerType(t ast.Expr) bool {
+	star, ok := t.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Timer" && sel.Sel.Name != "Ticker" {
+		return false
+	}
+	return r.isPkg(sel.X, "time") || r.isPkg(sel.X, WeftPath)
+}
+
+// isTimeCall reports whether x is a call to a function of time or weft
+// named one of names.
+func (r *rewriter) isTimeCall(x ast.Expr, names ...string) bool {
+	call, ok := ast.Unparen(x).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !slices.Contains(names, sel.Sel.Name) {
+		return false
+	}
+	return r.isPkg(sel.X, "time") || r.isPkg(sel.X, WeftPath)
+}
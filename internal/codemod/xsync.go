@@ -0,0 +1,26 @@
+package codemod
+
+import (
+	"go/ast"
+	"strconv"
+)
+
+// xsyncPaths maps the golang.org/x/sync packages that have a weft
+// counterpart with the same API to its import path.
+var xsyncPaths = map[string]string{
+	"golang.org/x/sync/errgroup":     WeftPath + "/errgroup",
+	"golang.org/x/sync/semaphore":    WeftPath + "/semaphore",
+	"golang.org/x/sync/singleflight": WeftPath + "/singleflight",
+}
+
+// importSpec rewrites an import of an x/sync package to its weft
+// counterpart. The package names match, so references need no change.
+func (r *rewriter) importSpec(spec *ast.ImportSpec) {
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return
+	}
+	if weftPath, ok := xsyncPaths[path]; ok {
+		r.replace(spec.Path.Pos(), spec.Path.End(), strconv.Quote(weftPath))
+	}
+}
@@ -0,0 +1,147 @@
+// Package ignore implements the //weft:ignore directive, which excludes
+// intentionally raw concurrency from weft tooling.
+//
+// The directive may appear in four places:
+//
+//   - in the package doc comment of any file, ignoring the whole package;
+//   - before the package clause but outside the doc comment, ignoring the file;
+//   - on the line before a declaration, statement, spec or field, ignoring it;
+//   - at the end of the first line of such a node, ignoring it.
+//
+// Text after the directive is free-form and conventionally explains why the
+// code is excluded:
+//
+//	//weft:ignore hot path, measured in BenchmarkIngest
+//	go s.flushLoop()
+package ignore
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// Directive is the comment text that marks code as ignored.
+const Directive = "//weft:ignore"
+
+// isDirective reports whether c is a //weft:ignore comment.
+func isDirective(c *ast.Comment) bool {
+	rest, ok := strings.CutPrefix(c.Text, Directive)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+// hasDirective reports whether the comment group contains the directive.
+func hasDirective(cg *ast.CommentGroup) bool {
+	if cg == nil {
+		return false
+	}
+	for _, c := range cg.List {
+		if isDirective(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Package reports whether any file of a package carries the directive in its
+// package doc comment.
+func Package(files []*ast.File) bool {
+	for _, f := range files {
+		if hasDirective(f.Doc) {
+			return true
+		}
+	}
+	return false
+}
+
+// Set records which parts of a file are excluded by directives.
+type Set struct {
+	file   bool
+	ranges []span
+}
+
+type span struct {
+	pos, end token.Pos
+}
+
+// File collects the directives in f. The file must have been parsed with
+// comments.
+func File(fset *token.FileSet, f *ast.File) *Set {
+	s := &Set{}
+	var directives []*ast.Comment
+	groupEnd := make(map[*ast.Comment]int)
+	for _, cg := range f.Comments {
+		if cg.Pos() < f.Package {
+			if hasDirective(cg) {
+				s.file = true
+			}
+			continue
+		}
+		for _, c := range cg.List {
+			if isDirective(c) {
+				directives = append(directives, c)
+				groupEnd[c] = fset.Position(cg.End()).Line
+			}
+		}
+	}
+	if s.file || len(directives) == 0 {
+		return s
+	}
+
+	// A directive following code on the same line applies to the node
+	// starting on that line; a directive on a line of its own applies to
+	// the node starting after its comment group.
+	lineOf := func(pos token.Pos) int { return fset.Position(pos).Line }
+	trailing := make(map[*ast.Comment]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		for _, c := range directives {
+			if n.Pos() < c.Pos() && lineOf(n.Pos()) == lineOf(c.Pos()) {
+				trailing[c] = true
+			}
+		}
+		return true
+	})
+	targets := make(map[int]bool)
+	for _, c := range directives {
+		if trailing[c] {
+			targets[lineOf(c.Pos())] = true
+		} else {
+			targets[groupEnd[c]+1] = true
+		}
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case ast.Stmt, ast.Decl, ast.Spec, *ast.Field:
+		default:
+			return true
+		}
+		if targets[lineOf(n.Pos())] {
+			s.ranges = append(s.ranges, span{n.Pos(), n.End()})
+			return false
+		}
+		return true
+	})
+	return s
+}
+
+// IgnoresFile reports whether the whole file is excluded.
+func (s *Set) IgnoresFile() bool {
+	return s.file
+}
+
+// Ignores reports whether n lies within an excluded file or node.
+func (s *Set) Ignores(n ast.Node) bool {
+	if s.file {
+		return true
+	}
+	for _, r := range s.ranges {
+		if r.pos <= n.Pos() && n.End() <= r.end {
+			return true
+		}
+	}
+	return false
+}
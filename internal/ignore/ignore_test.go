@@ -0,0 +1,108 @@
+package ignore
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func parse(t *testing.T, src string) (*token.FileSet, *ast.File) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fset, f
+}
+
+// goStmts returns the go statements in f in source order.
+func goStmts(f *ast.File) []*ast.GoStmt {
+	var stmts []*ast.GoStmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		if g, ok := n.(*ast.GoStmt); ok {
+			stmts = append(stmts, g)
+		}
+		return true
+	})
+	return stmts
+}
+
+// TestStatementDirectives verifies directives above and after statements.
+func TestStatementDirectives(t *testing.T) {
+	fset, f := parse(t, `package p
+
+func f() {
+	//weft:ignore hot path
+	go f()
+	go f() //weft:ignore
+	go f()
+	// not a directive: //weft:ignore
+	go f()
+}
+`)
+	s := File(fset, f)
+	want := []bool{true, true, false, false}
+	for i, g := range goStmts(f) {
+		if got := s.Ignores(g); got != want[i] {
+			t.Errorf("go statement %d: Ignores = %v, want %v", i, got, want[i])
+		}
+	}
+	if s.IgnoresFile() {
+		t.Error("statement directive ignored the whole file")
+	}
+}
+
+// TestDeclarationDirective verifies that a directive in a doc comment
+// covers the whole declaration.
+func TestDeclarationDirective(t *testing.T) {
+	fset, f := parse(t, `package p
+
+// f spawns work.
+//weft:ignore cgo callback
+func f() {
+	go f()
+}
+
+func g() {
+	go g()
+}
+`)
+	s := File(fset, f)
+	stmts := goStmts(f)
+	if !s.Ignores(stmts[0]) {
+		t.Error("statement in ignored function not ignored")
+	}
+	if s.Ignores(stmts[1]) {
+		t.Error("statement in other function ignored")
+	}
+}
+
+// TestFileAndPackageDirectives verifies directives before the package
+// clause.
+func TestFileAndPackageDirectives(t *testing.T) {
+	fset, file := parse(t, `//weft:ignore
+
+// Package p does things.
+package p
+`)
+	if !File(fset, file).IgnoresFile() {
+		t.Error("file directive not honored")
+	}
+	if Package([]*ast.File{file}) {
+		t.Error("file directive ignored the package")
+	}
+
+	fset, doc := parse(t, `// Package p does things.
+//
+//weft:ignore
+package p
+`)
+	if !Package([]*ast.File{file, doc}) {
+		t.Error("package directive not honored")
+	}
+	if !File(fset, doc).IgnoresFile() {
+		t.Error("package directive did not ignore its own file")
+	}
+}
@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"slices"
+	"sync"
+)
+
+// Blocker is where the tasks waiting on a primitive built outside the
+// scheduler park until the primitive unparks them. Parking is a
+// scheduling point and records a Block event on the blocker's name, and
+// unparking an Unblock event for the task woken, so that such primitives
+// show in traces, Tasks and deadlock reports like the built-in ones.
+type Blocker struct {
+	name string
+
+	mu      sync.Mutex
+	waiters []*Waiter
+}
+
+// Waiter is a task's place in the queue of a Blocker.
+type Waiter struct {
+	b *Blocker
+
+	// s and task are the scheduler and the task that joined the queue, or
+	// nil if it was not a task, and woken is set, under s.mu, once it has
+	// been unparked. A goroutine other than a task parks on wakeup
+	// instead, buffered so that an Unpark before Park is not lost, and
+	// clock is set if it waits for a timer of that clock.
+	s      *Scheduler
+	task   *running
+	woken  bool
+	wakeup chan struct{}
+	clock  *clock
+
+	// drives are the schedulers driven by the goroutine that joined the
+	// queue. held is set while it counts among their drivers waiting,
+	// from parking until it is woken, and done once it has been; both
+	// are guarded by mu.
+	drives     []*Scheduler
+	mu         sync.Mutex
+	held, done bool
+}
+
+// NewBlocker creates a blocker whose events name name as their object.
+func NewBlocker(name string) *Blocker {
+	return &Blocker{name: name}
+}
+
+// Waiter adds the calling task to the queue of b and returns its place,
+// to Park on once the caller has released the locks of its primitive.
+func (b *Blocker) Waiter() *Waiter {
+	w := newWaiter(b)
+	b.mu.Lock()
+	b.waiters = append(b.waiters, w)
+	b.mu.Unlock()
+	return w
+}
+
+// newWaiter returns a place for the calling task to park on b, outside its
+// queue. A task unwinding once killed, which is given no more turns, waits
+// as a goroutine other than a task does.
+func newWaiter(b *Blocker) *Waiter {
+	w := &Waiter{b: b}
+	gid := goid()
+	v, ok := byGoroutine.Load(gid)
+	if ok && !v.(*running).exiting.Load() {
+		t := v.(*running)
+		w.s, w.task = t.s, t
+		if !t.drives.Load() {
+			return w
+		}
+	} else {
+		w.wakeup = make(chan struct{}, 1)
+	}
+	w.drives = driven(gid)
+	return w
+}
+
+// Park blocks until w is unparked, at once if it has been already. A task
+// parks in its scheduler's blocked set, passing the turn.
+func (w *Waiter) Park() {
+	Checkpoint()
+	if !w.park() {
+		w.task.exit()
+	}
+}
+
+// park is Park without its scheduling point, for primitives that made one
+// already. It returns false if the task's group was killed before w was
+// unparked, when the caller must exit.
+func (w *Waiter) park() bool {
+	w.hold()
+	defer w.release()
+	if w.s == nil {
+		<-w.wakeup
+		return true
+	}
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	return w.woken || w.s.park(w.task, w.b.name)
+}
+
+// hold counts w among the drivers waiting on the schedulers it drives,
+// unless it has been woken already.
+func (w *Waiter) hold() {
+	if len(w.drives) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.held || w.done {
+		return
+	}
+	w.held = true
+	for _, s := range w.drives {
+		s.driverWaits(1)
+	}
+}
+
+// release stops counting w among the drivers waiting, as it is woken, so
+// that the turns of the schedulers it drives stop at the same point of
+// their schedules in every run.
+func (w *Waiter) release() {
+	if len(w.drives) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+	if !w.held {
+		return
+	}
+	w.held = false
+	for _, s := range w.drives {
+		s.driverWaits(-1)
+	}
+}
+
+// Unpark wakes one task of the queue of b, chosen by the schedule, and
+// reports whether there was one. Shrinking favors the task that joined
+// the queue first.
+func (b *Blocker) Unpark() bool {
+	b.mu.Lock()
+	if len(b.waiters) == 0 {
+		b.mu.Unlock()
+		return false
+	}
+	i := pick(len(b.waiters), b.waiters[0].s)
+	w := b.waiters[i]
+	b.waiters = slices.Delete(b.waiters, i, i+1)
+	b.mu.Unlock()
+	w.wake()
+	return true
+}
+
+// pick chooses one of n waiters by the schedule of the caller's scheduler,
+// or of s if the caller has none, or the first without either.
+func pick(n int, s *Scheduler) int {
+	if n < 2 {
+		return 0
+	}
+	if cur := Current(); cur != nil {
+		s = cur
+	}
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.choose(n)
+}
+
+// scheduler returns the scheduler of the first task in the queue of b, or
+// nil if there is none, for decisions made on behalf of the queue.
+func (b *Blocker) scheduler() *Scheduler {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.waiters) == 0 {
+		return nil
+	}
+	return b.waiters[0].s
+}
+
+// UnparkAll wakes every task of the queue of b, in the order they joined
+// it, and returns their number.
+func (b *Blocker) UnparkAll() int {
+	b.mu.Lock()
+	waiters := b.waiters
+	b.waiters = nil
+	b.mu.Unlock()
+	for _, w := range waiters {
+		w.wake()
+	}
+	return len(waiters)
+}
+
+// remove takes w out of the queue of b and reports whether it was still
+// there, not unparked.
+func (b *Blocker) remove(w *Waiter) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := slices.Index(b.waiters, w)
+	if i < 0 {
+		return false
+	}
+	b.waiters = slices.Delete(b.waiters, i, i+1)
+	return true
+}
+
+// wake makes w's task ready again, if it has parked, and lets its Park
+// return.
+func (w *Waiter) wake() {
+	w.release()
+	if w.s == nil {
+		w.wakeup <- struct{}{}
+		return
+	}
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.woken = true
+	w.s.unpark(w.task, w.b.name)
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestBlocker verifies that the task Unpark wakes is a decision of the
+// schedule, and that parking and unparking are recorded.
+func TestBlocker(t *testing.T) {
+	run := func(seed uint64) []int {
+		s := New(seed)
+		b := NewBlocker("gate")
+		c := MakeChan[int](3)
+		for range 3 {
+			s.Spawn(func(interface{}) {
+				b.Waiter().Park()
+				c.Send(s.TaskID())
+			})
+		}
+		if s.RunUntil(func() bool { return false }) {
+			t.Fatal("RunUntil returned true with its tasks parked")
+		}
+		for _, task := range s.Tasks() {
+			if task.State != TaskBlocked || task.BlockedOn != "gate" {
+				t.Errorf("task %+v, want it blocked on gate", task)
+			}
+		}
+		s.Resume()
+		var woken []int
+		for b.Unpark() {
+			id, _ := c.Recv()
+			woken = append(woken, id)
+		}
+		s.Wait()
+		blocks, unblocks := 0, 0
+		for _, ev := range s.Events() {
+			if ev.Object != "gate" {
+				continue
+			}
+			switch ev.Kind {
+			case trace.Block:
+				blocks++
+			case trace.Unblock:
+				unblocks++
+			}
+		}
+		if blocks != 3 || unblocks != 3 {
+			t.Errorf("%d blocks and %d unblocks on gate, want 3 of each", blocks, unblocks)
+		}
+		return woken
+	}
+	first := run(3)
+	if len(first) != 3 {
+		t.Fatalf("woke %v, want 3 tasks", first)
+	}
+	if again := run(3); !slices.Equal(again, first) {
+		t.Errorf("woke %v with the same seed, then %v", first, again)
+	}
+}
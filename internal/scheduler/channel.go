@@ -1,51 +1,334 @@
 package scheduler
 
+import (
+	"slices"
+	"sync"
+
+	"github.com/mziter/weft/trace"
+)
+
 // Chan is a deterministic channel.
+//
+// A task that cannot send or receive at once parks in its scheduler's
+// blocked set. When several tasks wait to receive from a channel, or to
+// send on it, the schedule chooses which one a send or receive pairs with.
 type Chan[T any] struct {
-	ch   chan T
-	// TODO: Add deterministic scheduling
+	// buf holds the values sent but not yet received, at most cap. recvq
+	// and sendq are the operations parked receiving from the channel and
+	// sending on it. All are guarded by chanMu.
+	buf          []T
+	cap          int
+	closed       bool
+	recvq, sendq []*chanOp[T]
+
+	// link is set if the channel connects the tasks of two schedulers,
+	// and clock if a timer of the clock sends on it.
+	link  *link
+	clock *clock
+}
+
+// chanMu guards the state of every channel. A single lock for all lets a
+// select look at all its channels, and park on them, at once, channels
+// linking two schedulers included.
+var chanMu sync.Mutex
+
+// Blockers naming the objects of the Block and Unblock events of tasks
+// parked in channel operations, after the goroutine states of blocked
+// channel operations.
+var (
+	recvBlocker   = NewBlocker("chan receive")
+	sendBlocker   = NewBlocker("chan send")
+	selectBlocker = NewBlocker("select")
+)
+
+// link is a channel carrying values from the tasks of one scheduler to
+// those of another: its sends are recorded in the sender's trace and its
+// receives in the receiver's.
+type link struct {
+	from, to *Scheduler
+	name     string
+}
+
+// MakeLink creates a channel called name carrying values from the tasks of
+// from to the tasks of to.
+func MakeLink[T any](from, to *Scheduler, name string, cap int) *Chan[T] {
+	// The tasks using the link are looked up to attribute its events.
+	hooks.Add(1)
+	c := MakeChan[T](cap)
+	c.link = &link{from: from, to: to, name: name}
+	return c
+}
+
+// sent records a send on the link, if the channel is one.
+func (l *link) sent() {
+	if l != nil {
+		l.from.recordOn(trace.Send, l.name)
+	}
+}
+
+// received records a receive from the link, if the channel is one.
+func (l *link) received() {
+	if l != nil {
+		l.to.recordOn(trace.Recv, l.name)
+	}
 }
 
 // MakeChan creates a new deterministic channel.
 func MakeChan[T any](cap int) *Chan[T] {
-	return &Chan[T]{
-		ch: make(chan T, cap),
+	if cap < 0 {
+		panic("makechan: size out of range")
 	}
+	return &Chan[T]{cap: cap}
 }
 
-// Send sends a value.
+// blockNil blocks the calling task forever, as an operation on a nil
+// channel does, parking it so that a run it leaves unfinished is reported
+// as deadlocked.
+func blockNil() {
+	parkCaller("nil chan")
+}
+
+// Send sends a value. On a nil channel, it blocks forever.
 func (c *Chan[T]) Send(v T) {
-	c.ch <- v
+	Checkpoint()
+	if c == nil {
+		blockNil()
+	}
+	perform([]Case{SendCase(c, v)}, true, sendBlocker)
 }
 
-// Recv receives a value.
+// Recv receives a value. On a nil channel, it blocks forever.
 func (c *Chan[T]) Recv() (T, bool) {
-	v, ok := <-c.ch
+	Checkpoint()
+	if c == nil {
+		blockNil()
+	}
+	var v T
+	var ok bool
+	perform([]Case{RecvCase(c, &v, &ok)}, true, recvBlocker)
 	return v, ok
 }
 
 // TrySend tries to send without blocking.
 func (c *Chan[T]) TrySend(v T) bool {
-	select {
-	case c.ch <- v:
-		return true
-	default:
-		return false
-	}
+	return c != nil && perform([]Case{SendCase(c, v)}, false, nil) == 0
 }
 
 // TryRecv tries to receive without blocking.
 func (c *Chan[T]) TryRecv() (T, bool) {
-	select {
-	case v, ok := <-c.ch:
-		return v, ok
-	default:
-		var zero T
-		return zero, false
+	var v T
+	var ok bool
+	if c != nil {
+		perform([]Case{RecvCase(c, &v, &ok)}, false, nil)
 	}
+	return v, ok
 }
 
-// Close closes the channel.
+// Close closes the channel, waking every task parked on it: receivers get
+// the zero value, and senders panic. It panics on a nil channel.
 func (c *Chan[T]) Close() {
-	close(c.ch)
-}
\ No newline at end of file
+	if c == nil {
+		panic("close of nil channel")
+	}
+	var woken []*Waiter
+	func() {
+		chanMu.Lock()
+		defer chanMu.Unlock()
+		if c.closed {
+			panic("close of closed channel")
+		}
+		c.closed = true
+		recvq, sendq := c.recvq, c.sendq
+		c.recvq, c.sendq = nil, nil
+		for _, op := range recvq {
+			if op.p.fired < 0 {
+				woken = append(woken, op.p.fire(op.i))
+			}
+		}
+		for _, op := range sendq {
+			if op.p.fired < 0 {
+				op.closed = true
+				woken = append(woken, op.p.fire(op.i))
+			}
+		}
+	}()
+	for _, w := range woken {
+		w.wake()
+	}
+}
+
+// canSend reports whether a send on c would proceed at once, panicking if
+// c is closed. The caller must hold chanMu.
+func (c *Chan[T]) canSend() bool {
+	return c.closed || len(c.recvq) > 0 || len(c.buf) < c.cap
+}
+
+// canRecv reports whether a receive from c would proceed at once. The
+// caller must hold chanMu.
+func (c *Chan[T]) canRecv() bool {
+	return c.closed || len(c.buf) > 0 || len(c.sendq) > 0
+}
+
+// send sends v on c, which canSend allows, and returns the waiter of the
+// receiver it paired with, if any, to wake. The caller must hold chanMu.
+func (c *Chan[T]) send(v T) *Waiter {
+	if c.closed {
+		panic("send on closed channel")
+	}
+	if op := take(&c.recvq); op != nil {
+		op.v, op.ok = v, true
+		return op.p.fire(op.i)
+	}
+	c.buf = append(c.buf, v)
+	return nil
+}
+
+// recv receives from c, which canRecv allows, and returns the waiter of
+// the sender it took a value from, if any, to wake. The caller must hold
+// chanMu.
+func (c *Chan[T]) recv() (v T, ok bool, w *Waiter) {
+	if len(c.buf) > 0 {
+		v = c.buf[0]
+		var zero T
+		copy(c.buf, c.buf[1:])
+		c.buf[len(c.buf)-1] = zero
+		c.buf = c.buf[:len(c.buf)-1]
+		// A sender parked on the full buffer takes the place freed.
+		if op := take(&c.sendq); op != nil {
+			c.buf = append(c.buf, op.v)
+			w = op.p.fire(op.i)
+		}
+		return v, true, w
+	}
+	if op := take(&c.sendq); op != nil {
+		return op.v, true, op.p.fire(op.i)
+	}
+	// Closed.
+	return v, false, nil
+}
+
+// chanOp is a case of a parked operation, waiting in the queue of its
+// channel, with the value it sends or, once it fires, the one it received.
+type chanOp[T any] struct {
+	p      *parking
+	i      int
+	v      T
+	ok     bool
+	closed bool
+}
+
+// take removes the operation, chosen by the schedule, that a send or
+// receive pairs with from q, or returns nil if q is empty. The caller must
+// hold chanMu.
+func take[T any](q *[]*chanOp[T]) *chanOp[T] {
+	if len(*q) == 0 {
+		return nil
+	}
+	i := pick(len(*q), (*q)[0].p.w.s)
+	op := (*q)[i]
+	*q = slices.Delete(*q, i, i+1)
+	return op
+}
+
+// remove removes the case of p from q, if it is there.
+func remove[T any](q *[]*chanOp[T], p *parking) {
+	*q = slices.DeleteFunc(*q, func(op *chanOp[T]) bool { return op.p == p })
+}
+
+// parking is a channel operation or select parked on the channels of its
+// cases until one of them proceeds.
+type parking struct {
+	w     *Waiter
+	cases []Case
+	// fired is the index of the case that proceeded, or -1. It is guarded
+	// by chanMu.
+	fired int
+}
+
+// fire makes case i of p the one that proceeded, taking the others out of
+// the queues of their channels, and returns p's waiter, to wake. The
+// caller must hold chanMu.
+func (p *parking) fire(i int) *Waiter {
+	p.fired = i
+	for j, c := range p.cases {
+		if j != i && !c.nilChan() {
+			c.dequeue(p)
+		}
+	}
+	return p.w
+}
+
+// clock returns the clock of the first of p's cases receiving from a
+// timer's channel if p is not a task's, or nil.
+func (p *parking) clock() *clock {
+	if p.w.s != nil {
+		return nil
+	}
+	for _, c := range p.cases {
+		if clock := c.clock(); clock != nil {
+			return clock
+		}
+	}
+	return nil
+}
+
+// perform performs one of cases, chosen by the schedule among those that
+// can proceed at once, or, if none can and block is set, parks the caller
+// on b until one does, and returns its index. If none can and block is
+// not set, it returns -1.
+func perform(cases []Case, block bool, b *Blocker) int {
+	var ready []int
+	var w *Waiter
+	var p *parking
+	i := func() int {
+		chanMu.Lock()
+		defer chanMu.Unlock()
+		for i, c := range cases {
+			if !c.nilChan() && c.ready() {
+				ready = append(ready, i)
+			}
+		}
+		if len(ready) > 0 {
+			i := ready[pick(len(ready), nil)]
+			w = cases[i].proceed()
+			return i
+		}
+		if !block {
+			return -1
+		}
+		p = &parking{w: newWaiter(b), cases: cases, fired: -1}
+		p.w.clock = p.clock()
+		for i, c := range cases {
+			if !c.nilChan() {
+				c.enqueue(p, i)
+			}
+		}
+		return -1
+	}()
+	if p == nil {
+		if w != nil {
+			w.wake()
+		}
+		if i >= 0 {
+			cases[i].done()
+		}
+		return i
+	}
+	if p.w.clock != nil {
+		// A goroutine other than a task waiting for a timer advances its
+		// clock, which no task may be left to.
+		p.w.clock.wait(p.w)
+	} else if !p.w.park() {
+		// Killed: leave the queues, unless a case has fired all the same.
+		chanMu.Lock()
+		if p.fired < 0 {
+			p.fire(-1)
+		}
+		chanMu.Unlock()
+		p.w.task.exit()
+	}
+	i = p.fired
+	cases[i].collect()
+	cases[i].done()
+	return i
+}
@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// TestChanReceivers verifies that tasks waiting to receive from a channel
+// park in the scheduler, and that which of them each send pairs with is a
+// decision of the schedule: the same for the same seed, and not for every
+// seed.
+func TestChanReceivers(t *testing.T) {
+	run := func(seed uint64) []int {
+		s := New(seed)
+		c := MakeChan[int](0)
+		got := make([]int, 3)
+		for i := range 3 {
+			s.Spawn(func(interface{}) {
+				got[i], _ = c.Recv()
+			})
+		}
+		if s.RunUntil(func() bool { return false }) {
+			t.Fatal("RunUntil returned true with its tasks receiving")
+		}
+		for _, task := range s.Tasks() {
+			if task.State != TaskBlocked || task.BlockedOn != "chan receive" {
+				t.Errorf("task %+v, want it blocked on chan receive", task)
+			}
+		}
+		s.Spawn(func(interface{}) {
+			for v := range 3 {
+				c.Send(v + 1)
+			}
+		})
+		s.Wait()
+		return got
+	}
+	orders := make(map[string]bool)
+	for seed := uint64(1); seed <= 20; seed++ {
+		got := run(seed)
+		sorted := slices.Clone(got)
+		slices.Sort(sorted)
+		if !slices.Equal(sorted, []int{1, 2, 3}) {
+			t.Fatalf("seed %d: receivers got %v, want 1, 2 and 3 once each", seed, got)
+		}
+		if again := run(seed); !slices.Equal(again, got) {
+			t.Errorf("seed %d: receivers got %v, then %v", seed, got, again)
+		}
+		orders[fmt.Sprint(got)] = true
+	}
+	if len(orders) < 2 {
+		t.Errorf("20 seeds all paired the sends as %v", orders)
+	}
+}
+
+// TestChanBuffered verifies that a buffered channel delivers in order,
+// parks a sender while it is full, and lets receivers drain it once it is
+// closed.
+func TestChanBuffered(t *testing.T) {
+	s := New(1)
+	c := MakeChan[int](2)
+	s.Spawn(func(interface{}) {
+		for v := range 5 {
+			c.Send(v)
+		}
+		c.Close()
+	})
+	var got []int
+	s.Spawn(func(interface{}) {
+		for {
+			v, ok := c.Recv()
+			if !ok {
+				return
+			}
+			got = append(got, v)
+		}
+	})
+	s.Wait()
+	if want := []int{0, 1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+	if v, ok := c.TryRecv(); v != 0 || ok {
+		t.Errorf("TryRecv() on a closed, drained channel = %d, %v; want 0, false", v, ok)
+	}
+}
+
+// TestChanClose verifies that closing a channel wakes the tasks parked on
+// it: receivers with the zero value, senders with a panic.
+func TestChanClose(t *testing.T) {
+	s := New(1)
+	recv, send := MakeChan[int](0), MakeChan[int](0)
+	recvOK := true
+	var sendPanic any
+	s.Spawn(func(interface{}) {
+		_, recvOK = recv.Recv()
+	})
+	s.Spawn(func(interface{}) {
+		defer func() { sendPanic = recover() }()
+		send.Send(1)
+	})
+	if s.RunUntil(func() bool { return false }) {
+		t.Fatal("RunUntil returned true with its tasks parked")
+	}
+	s.Spawn(func(interface{}) {
+		recv.Close()
+		send.Close()
+	})
+	s.Wait()
+	if recvOK {
+		t.Error("receive woken by Close reported a value sent")
+	}
+	if sendPanic != "send on closed channel" {
+		t.Errorf("send woken by Close panicked with %v, want send on closed channel", sendPanic)
+	}
+}
+
+// TestSelectReady verifies that the case performed among several ready is
+// a decision of the schedule.
+func TestSelectReady(t *testing.T) {
+	run := func(seed uint64) []int {
+		s := New(seed)
+		a, b := MakeChan[int](5), MakeChan[int](5)
+		var chosen []int
+		s.Spawn(func(interface{}) {
+			for range 5 {
+				a.Send(1)
+				b.Send(2)
+			}
+			for range 5 {
+				var v int
+				var ok bool
+				i := Select([]Case{RecvCase(a, &v, &ok), RecvCase(b, &v, &ok)}, true)
+				chosen = append(chosen, i)
+			}
+		})
+		s.Wait()
+		return chosen
+	}
+	seen := make(map[string]bool)
+	for seed := uint64(1); seed <= 20; seed++ {
+		chosen := run(seed)
+		if again := run(seed); !slices.Equal(again, chosen) {
+			t.Errorf("seed %d: chose %v, then %v", seed, chosen, again)
+		}
+		seen[fmt.Sprint(chosen)] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("20 seeds all chose the cases %v", seen)
+	}
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"math"
+	"time"
+
+	"github.com/mziter/weft/trace"
+)
+
+// Chaos configures the faults injected at the scheduling points of tasks.
+type Chaos struct {
+	// Rate is the probability of a fault at each scheduling point.
+	Rate float64
+
+	// Panic, if not nil, is a value to panic with.
+	Panic any
+
+	// Cancel enables canceling the task's context.
+	Cancel bool
+
+	// MaxDelay, if positive, enables delays of up to that long.
+	MaxDelay time.Duration
+}
+
+// chaosResolution is the number of outcomes a chaos rate is drawn from,
+// and delaySteps the number of delays.
+const (
+	chaosResolution = 1 << 16
+	delaySteps      = 16
+)
+
+// SetChaos makes s inject faults into its tasks as c describes, from the
+// next scheduling point on.
+func (s *Scheduler) SetChaos(c Chaos) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chaos == nil {
+		hooks.Add(1)
+	}
+	s.chaos = &c
+}
+
+// runLength draws the number of scheduling points a task passes before its
+// next fault at rate, distributed as if a fault were decided at each point
+// separately. Decision 0 is the longest run, so shrinking drops the faults a
+// failure does not need. The caller must hold s.mu.
+func (s *Scheduler) runLength(rate float64) int {
+	u := float64(s.choose(chaosResolution)+1) / (chaosResolution + 1)
+	return int(min(math.Log(u)/math.Log1p(-rate), math.MaxInt32))
+}
+
+// injectChaos decides whether a fault befalls t at this scheduling point,
+// and injects it.
+func (s *Scheduler) injectChaos(t *running) {
+	s.mu.Lock()
+	c := s.chaos
+	if c == nil || c.Rate <= 0 || t.exited {
+		s.mu.Unlock()
+		return
+	}
+	if c.Rate < 1 {
+		// t runs through the scheduling points of its run undisturbed,
+		// with no decision made at each, and faults at the one after.
+		if t.runChaos != c {
+			t.runChaos, t.runFor = c, s.runLength(c.Rate)
+		}
+		if t.runFor > 0 {
+			t.runFor--
+			s.mu.Unlock()
+			return
+		}
+		t.runChaos = nil
+	}
+	var kinds []string
+	if c.Panic != nil {
+		kinds = append(kinds, "panic")
+	}
+	if c.Cancel && !t.canceled {
+		kinds = append(kinds, "cancel")
+	}
+	if c.MaxDelay > 0 {
+		kinds = append(kinds, "delay")
+	}
+	if len(kinds) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	kind := kinds[0]
+	if len(kinds) > 1 {
+		kind = kinds[s.choose(len(kinds))]
+	}
+	var d time.Duration
+	if kind == "delay" {
+		d = c.MaxDelay * time.Duration(s.choose(delaySteps)+1) / delaySteps
+	}
+	s.record(event{task: t.id, kind: trace.Chaos, object: kind, stack: callerStack()})
+	if kind == "cancel" {
+		t.cancel()
+	}
+	s.mu.Unlock()
+
+	switch kind {
+	case "panic":
+		panic(c.Panic)
+	case "delay":
+		s.sleep(d)
+	}
+}
@@ -0,0 +1,386 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mziter/weft/trace"
+)
+
+// Sleep and After wait for virtual time, kept by a clock each scheduler
+// shares with the members of its harness. Virtual time stands still while
+// any task of the schedulers keeping it can run, or any driver of their
+// tasks runs: once every task is blocked and the drivers wait, with a task
+// or a goroutine other than a task waiting for a timer, the clock jumps to
+// the earliest deadline and fires the timers due then, all before any task
+// they wake takes a turn, so that which of them runs first is a decision
+// of the schedule like any other. The watchdog advances the clock when no
+// task is left ready; a goroutine other than a task waiting for a timer
+// advances it itself. Advance moves it explicitly.
+
+// epoch is the virtual time a clock starts at, the time testing/synctest's
+// bubbles start at too.
+var epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// sleepBlocker names the object of the Block and Unblock events of tasks
+// sleeping.
+var sleepBlocker = NewBlocker("sleep")
+
+// clock is the virtual time of the schedulers keeping it.
+type clock struct {
+	// advancing is held while the clock advances, so that one advance
+	// fires its timers before the next looks whether the schedulers are
+	// idle.
+	advancing sync.Mutex
+
+	mu  sync.Mutex
+	now time.Time
+	// timers are the timers not yet fired, by deadline and, for the same
+	// deadline, in the order they were set.
+	timers []*timer
+	// schedulers are the schedulers keeping the clock, its owner first.
+	schedulers []*Scheduler
+	// sleepers counts the goroutines other than tasks waiting for a
+	// timer.
+	sleepers int
+	// synctest is set when the owner runs inside a testing/synctest
+	// bubble, whose fake clock stands in for the virtual one.
+	synctest bool
+}
+
+// timer calls f with the virtual time once it reaches when.
+type timer struct {
+	when time.Time
+	f    func(now time.Time)
+}
+
+// newClock returns a clock at epoch owned by s.
+func newClock(s *Scheduler) *clock {
+	return &clock{now: epoch, schedulers: []*Scheduler{s}}
+}
+
+// join adds s to the schedulers keeping c.
+func (c *clock) join(s *Scheduler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedulers = append(c.schedulers, s)
+}
+
+// replace makes r keep c in place of s, which Reset replaced with r.
+func (c *clock) replace(s, r *Scheduler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := slices.Index(c.schedulers, s); i >= 0 {
+		c.schedulers[i] = r
+	}
+}
+
+// reset sets c back to epoch with no timers if s owns it.
+func (c *clock) reset(s *Scheduler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schedulers[0] == s {
+		c.now, c.timers, c.synctest = epoch, nil, false
+	}
+}
+
+// add sets a timer calling f once d of virtual time has passed, or at the
+// next advance if d is not positive.
+func (c *clock) add(d time.Duration, f func(now time.Time)) *timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{when: c.now.Add(max(d, 0)), f: f}
+	i, _ := slices.BinarySearchFunc(c.timers, t.when, func(t *timer, when time.Time) int {
+		if t.when.After(when) {
+			return 1
+		}
+		return -1
+	})
+	c.timers = slices.Insert(c.timers, i, t)
+	return t
+}
+
+// stop removes t and reports whether it had not fired yet.
+func (c *clock) stop(t *timer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := slices.Index(c.timers, t)
+	if i < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, i, i+1)
+	return true
+}
+
+// due removes the timers due by limit, or by any time if limit is zero,
+// with the earliest deadline, moving the time to it, and returns them.
+func (c *clock) due(limit time.Time) []*timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.timers) == 0 || !limit.IsZero() && c.timers[0].when.After(limit) {
+		return nil
+	}
+	c.now = c.timers[0].when
+	n := 1
+	for n < len(c.timers) && c.timers[n].when.Equal(c.now) {
+		n++
+	}
+	due := slices.Clone(c.timers[:n])
+	c.timers = slices.Delete(c.timers, 0, n)
+	return due
+}
+
+// fire calls the functions of timers, due at now.
+func fire(timers []*timer, now time.Time) {
+	for _, t := range timers {
+		t.f(now)
+	}
+}
+
+// advance moves the time to the earliest deadline and fires the timers
+// due then, if every scheduler keeping c is quiet and a task or goroutine
+// waits, and reports whether it did. Before that it spawns the tasks of
+// the calls of AfterDone whose events came, reporting true as well; with
+// no timer left either, it looks whether the tasks are deadlocked.
+func (c *clock) advance() bool {
+	c.advancing.Lock()
+	defer c.advancing.Unlock()
+	c.mu.Lock()
+	schedulers := slices.Clone(c.schedulers)
+	waiting := c.sleepers > 0
+	c.mu.Unlock()
+	frozen := 0
+	defer func() { thaw(schedulers[:frozen]) }()
+	for _, s := range schedulers {
+		s.mu.Lock()
+		idle := s.quiet()
+		if idle {
+			s.frozen = true
+			waiting = waiting || len(s.tasks.inState(TaskBlocked)) > 0
+		}
+		s.mu.Unlock()
+		if !idle {
+			return false
+		}
+		frozen++
+	}
+	spawned := false
+	for _, s := range schedulers {
+		spawned = s.spawnAfters() || spawned
+	}
+	if spawned {
+		return true
+	}
+	if !waiting {
+		return false
+	}
+	due := c.due(time.Time{})
+	if due == nil {
+		c.deadlock(schedulers)
+		return false
+	}
+	fire(due, due[0].when)
+	return true
+}
+
+// deadlock fails the runs of schedulers, the schedulers keeping c, all
+// frozen, with ErrDeadlock and ends their Waits if their tasks are
+// deadlocked: a goroutine waits for them in Wait, none of them is ready or
+// running and at least one is blocked, every one that has not exited is
+// blocked on a weft primitive, with no timer left to fire and no killed
+// task unwinding, work aside or goroutine other than a task sleeping that
+// could wake it.
+func (c *clock) deadlock(schedulers []*Scheduler) {
+	c.mu.Lock()
+	pending := c.sleepers > 0 || len(c.timers) > 0 || c.synctest
+	c.mu.Unlock()
+	if pending {
+		return
+	}
+	blocked := make([]int, len(schedulers))
+	total := 0
+	for i, s := range schedulers {
+		s.mu.Lock()
+		n := len(s.tasks.inState(TaskBlocked))
+		stuck := s.settled() || s.waits > 0 && !s.stepping && s.aside == 0 && s.idle() &&
+			len(s.tasks.inState(TaskRunning)) == 0 && s.goroutines == n
+		s.mu.Unlock()
+		if !stuck {
+			return
+		}
+		blocked[i] = n
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+	for i, s := range schedulers {
+		s.mu.Lock()
+		if blocked[i] > 0 {
+			s.fail(fmt.Errorf("%w: %d tasks blocked", ErrDeadlock, blocked[i]))
+		}
+		s.deadlocked = true
+		s.wake.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// advanceBy moves the time forward by d, firing every timer due by then
+// in the order of their deadlines, whatever the tasks are doing.
+func (c *clock) advanceBy(d time.Duration) {
+	c.advancing.Lock()
+	defer c.advancing.Unlock()
+	c.mu.Lock()
+	schedulers := slices.Clone(c.schedulers)
+	limit := c.now.Add(max(d, 0))
+	c.mu.Unlock()
+	for _, s := range schedulers {
+		s.mu.Lock()
+		s.frozen = true
+		s.mu.Unlock()
+	}
+	defer thaw(schedulers)
+	for {
+		due := c.due(limit)
+		if due == nil {
+			break
+		}
+		fire(due, due[0].when)
+	}
+	c.mu.Lock()
+	c.now = limit
+	c.mu.Unlock()
+}
+
+// thaw lets schedulers, frozen while timers fired, give turns again to
+// the tasks the timers woke.
+func thaw(schedulers []*Scheduler) {
+	for _, s := range schedulers {
+		s.mu.Lock()
+		s.frozen = false
+		if s.holder == 0 && len(s.tasks.inState(TaskReady)) > 0 {
+			s.pass()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// idle reports whether no task of s runs or can run. The caller must hold
+// s.mu.
+func (s *Scheduler) idle() bool {
+	return s.holder == 0 && len(s.tasks.inState(TaskReady)) == 0
+}
+
+// quiet reports whether s is idle with no driver of its tasks running
+// either, the turns being given, or with no task left. The caller must
+// hold s.mu.
+func (s *Scheduler) quiet() bool {
+	return s.idle() && (s.begun() || s.stepping || s.settled())
+}
+
+// Now returns the virtual time of s, or the wall-clock time inside a
+// testing/synctest bubble, whose clock is fake already.
+func (s *Scheduler) Now() time.Time {
+	c := s.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synctest {
+		return time.Now()
+	}
+	return c.now
+}
+
+// Advance moves the virtual time of s forward by d, firing the sleeps and
+// timers due by then in the order of their deadlines before any task they
+// wake takes a turn. Inside a testing/synctest bubble it sleeps for d.
+func (s *Scheduler) Advance(d time.Duration) {
+	if s.inSynctest() {
+		time.Sleep(d)
+		return
+	}
+	s.clock.advanceBy(d)
+}
+
+// inSynctest reports whether the clock of s is a testing/synctest
+// bubble's.
+func (s *Scheduler) inSynctest() bool {
+	c := s.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.synctest
+}
+
+// Sleep pauses the current task for d of virtual time.
+func (s *Scheduler) Sleep(d time.Duration) {
+	Checkpoint()
+	s.sleep(d)
+}
+
+// sleep pauses the current task without being a scheduling point. A task
+// parks until its timer fires, recording a Sleep event once it wakes; a
+// goroutine other than a task waits for it, advancing the clock itself
+// while the tasks are all blocked.
+func (s *Scheduler) sleep(d time.Duration) {
+	if s.inSynctest() {
+		time.Sleep(d)
+		return
+	}
+	c := s.clock
+	w := newWaiter(sleepBlocker)
+	if w.s == nil {
+		w.clock = c
+		c.add(d, func(time.Time) { w.wake() })
+		c.wait(w)
+		return
+	}
+	t := c.add(d, func(time.Time) { w.wake() })
+	if !w.park() {
+		c.stop(t)
+		w.task.exit()
+	}
+	w.s.recordOn(trace.Sleep, d.String())
+}
+
+// wait waits until w, the place of a goroutine other than a task, is
+// woken, advancing c as long as the schedulers keeping it are quiet. Once
+// they are not, the watchdog of the one last to become idle again
+// advances it.
+func (c *clock) wait(w *Waiter) {
+	w.hold()
+	defer w.release()
+	c.mu.Lock()
+	c.sleepers++
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.sleepers--
+		c.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-w.wakeup:
+			return
+		default:
+		}
+		if !c.advance() {
+			<-w.wakeup
+			return
+		}
+	}
+}
+
+// After returns a channel that receives the virtual time once d of it has
+// passed. A goroutine other than a task receiving from it advances the
+// clock as a sleeping one does.
+func (s *Scheduler) After(d time.Duration) *Chan[time.Time] {
+	ch := MakeChan[time.Time](1)
+	if s.inSynctest() {
+		time.AfterFunc(d, func() { ch.TrySend(time.Now()) })
+		return ch
+	}
+	ch.clock = s.clock
+	s.clock.add(d, func(now time.Time) { ch.TrySend(now) })
+	return ch
+}
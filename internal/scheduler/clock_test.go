@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestSleepVirtual verifies that sleeping tasks wake in the order of their
+// deadlines, at them, as the clock advances once they are all blocked.
+func TestSleepVirtual(t *testing.T) {
+	run := func(seed uint64) ([]string, time.Time) {
+		s := New(seed)
+		var woken []string
+		for _, d := range []time.Duration{3 * time.Hour, time.Hour, 2 * time.Hour, time.Hour} {
+			s.Spawn(func(interface{}) {
+				s.Sleep(d)
+				woken = append(woken, fmt.Sprint(d, "@", s.Now().Sub(epoch)))
+			})
+		}
+		s.Wait()
+		return woken, s.Now()
+	}
+	for seed := uint64(1); seed <= 20; seed++ {
+		woken, now := run(seed)
+		sorted := slices.Clone(woken)
+		slices.Sort(sorted)
+		if want := []string{"1h0m0s@1h0m0s", "1h0m0s@1h0m0s", "2h0m0s@2h0m0s", "3h0m0s@3h0m0s"}; !slices.Equal(sorted, want) {
+			t.Fatalf("seed %d: woke %v, want each task at its deadline", seed, woken)
+		}
+		if !slices.IsSorted(woken) {
+			t.Errorf("seed %d: woke %v, out of the order of their deadlines", seed, woken)
+		}
+		if want := epoch.Add(3 * time.Hour); !now.Equal(want) {
+			t.Errorf("seed %d: Now() = %v after the sleeps, want %v", seed, now, want)
+		}
+	}
+}
+
+// TestSleepSameDeadline verifies that the tasks woken at the same virtual
+// time all become ready before one runs, so that the schedule chooses
+// among them.
+func TestSleepSameDeadline(t *testing.T) {
+	run := func(seed uint64) []int {
+		s := New(seed)
+		var woken []int
+		for i := range 3 {
+			s.Spawn(func(interface{}) {
+				s.Sleep(time.Minute)
+				woken = append(woken, i)
+			})
+		}
+		s.Wait()
+		return woken
+	}
+	orders := make(map[string]bool)
+	for seed := uint64(1); seed <= 20; seed++ {
+		woken := run(seed)
+		if again := run(seed); !slices.Equal(again, woken) {
+			t.Errorf("seed %d: woke %v, then %v", seed, woken, again)
+		}
+		orders[fmt.Sprint(woken)] = true
+	}
+	if len(orders) < 2 {
+		t.Errorf("20 seeds all woke the tasks in the order %v", orders)
+	}
+}
+
+// TestAfterVirtual verifies that After delivers the virtual time of its
+// deadline, advancing the clock once its receiver is blocked.
+func TestAfterVirtual(t *testing.T) {
+	s := New(1)
+	var got time.Time
+	s.Spawn(func(interface{}) {
+		got, _ = s.After(90 * time.Second).Recv()
+	})
+	s.Wait()
+	if want := epoch.Add(90 * time.Second); !got.Equal(want) {
+		t.Errorf("After(90s) received %v, want %v", got, want)
+	}
+}
+
+// TestAdvance verifies that Advance moves the clock forward, firing the
+// timers due by then, and that a goroutine other than a task sleeping
+// with no task to wait for advances the clock itself.
+func TestAdvance(t *testing.T) {
+	s := New(1)
+	if now := s.Now(); !now.Equal(epoch) {
+		t.Fatalf("Now() = %v on a new scheduler, want %v", now, epoch)
+	}
+	early, late := s.After(time.Minute), s.After(time.Hour)
+	s.Advance(30 * time.Minute)
+	if now := s.Now(); !now.Equal(epoch.Add(30 * time.Minute)) {
+		t.Errorf("Now() = %v after Advance(30m), want %v", now, epoch.Add(30*time.Minute))
+	}
+	if v, ok := early.TryRecv(); !ok || !v.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("After(1m).TryRecv() = %v, %v after Advance(30m); want %v, true", v, ok, epoch.Add(time.Minute))
+	}
+	if _, ok := late.TryRecv(); ok {
+		t.Error("After(1h) fired after Advance(30m)")
+	}
+	s.Sleep(time.Hour)
+	if now := s.Now(); !now.Equal(epoch.Add(90 * time.Minute)) {
+		t.Errorf("Now() = %v after Sleep(1h), want %v", now, epoch.Add(90*time.Minute))
+	}
+	if _, ok := late.TryRecv(); !ok {
+		t.Error("After(1h) did not fire by the end of Sleep(1h)")
+	}
+}
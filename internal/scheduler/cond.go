@@ -3,36 +3,46 @@ package scheduler
 import "sync"
 
 // Cond is a deterministic condition variable.
+//
+// Wait parks the calling task in its scheduler's blocked set, and Signal
+// wakes a waiting task chosen by the schedule.
 type Cond struct {
-	cond *sync.Cond
-	// TODO: Add deterministic scheduling
+	l       sync.Locker
+	waiters Blocker
 }
 
-// NewCond creates a new deterministic condition variable.
-func NewCond(l interface{}) *Cond {
-	if locker, ok := l.(sync.Locker); ok {
-		return &Cond{
-			cond: sync.NewCond(locker),
-		}
-	}
-	// For weft types, we need to extract the underlying sync.Locker
-	// This is a stub implementation
-	return &Cond{
-		cond: sync.NewCond(&sync.Mutex{}),
-	}
+// NewCond creates a new deterministic condition variable with the lock l.
+func NewCond(l sync.Locker) *Cond {
+	return &Cond{l: l, waiters: Blocker{name: "cond"}}
 }
 
-// Wait waits for the condition.
+// Wait unlocks the lock and parks until Signal or Broadcast wakes the
+// caller, then locks it again. A Signal after the unlock is not lost, and
+// a task killed while waiting still locks it again as it unwinds, for the
+// deferred Unlock of its caller.
 func (c *Cond) Wait() {
-	c.cond.Wait()
+	w := c.waiters.Waiter()
+	c.l.Unlock()
+	woken := false
+	defer func() {
+		if !woken {
+			// Killed: give up the place, and pass on a Signal it took.
+			if !c.waiters.remove(w) {
+				c.waiters.Unpark()
+			}
+		}
+		c.l.Lock()
+	}()
+	w.Park()
+	woken = true
 }
 
-// Signal wakes one waiter.
+// Signal wakes one waiter, chosen by the schedule.
 func (c *Cond) Signal() {
-	c.cond.Signal()
+	c.waiters.Unpark()
 }
 
 // Broadcast wakes all waiters.
 func (c *Cond) Broadcast() {
-	c.cond.Broadcast()
-}
\ No newline at end of file
+	c.waiters.UnparkAll()
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestCondSignal verifies that tasks waiting on a condition variable park
+// in the scheduler, and that the one each Signal wakes is a decision of the
+// schedule: the same for the same seed, and not for every seed.
+func TestCondSignal(t *testing.T) {
+	run := func(seed uint64) []int {
+		s := New(seed)
+		m := NewMutex()
+		c := NewCond(m)
+		var woken []int
+		for i := range 3 {
+			s.Spawn(func(interface{}) {
+				m.Lock()
+				defer m.Unlock()
+				c.Wait()
+				woken = append(woken, i)
+			})
+		}
+		if s.RunUntil(func() bool { return false }) {
+			t.Fatal("RunUntil returned true with its tasks waiting")
+		}
+		for _, task := range s.Tasks() {
+			if task.State != TaskBlocked || task.BlockedOn != "cond" {
+				t.Errorf("task %+v, want it blocked on cond", task)
+			}
+		}
+		s.Spawn(func(interface{}) {
+			for range 3 {
+				m.Lock()
+				c.Signal()
+				m.Unlock()
+			}
+		})
+		s.Wait()
+		return woken
+	}
+	orders := make(map[string]bool)
+	for seed := uint64(1); seed <= 20; seed++ {
+		woken := run(seed)
+		if len(woken) != 3 {
+			t.Fatalf("seed %d: woke %v, want all 3 tasks", seed, woken)
+		}
+		if again := run(seed); !slices.Equal(again, woken) {
+			t.Errorf("seed %d: woke %v, then %v", seed, woken, again)
+		}
+		orders[fmt.Sprint(woken)] = true
+	}
+	if len(orders) < 2 {
+		t.Errorf("20 seeds all woke the tasks in the order %v", orders)
+	}
+}
+
+// TestCondKilled verifies that a task killed while waiting locks the lock
+// again as it unwinds, for its deferred Unlock, and passes on the Signal it
+// may have taken.
+func TestCondKilled(t *testing.T) {
+	s := New(1)
+	m := NewMutex()
+	c := NewCond(m)
+	g := NewGroup("waiter")
+	s.SpawnIn(g, func(interface{}) {
+		m.Lock()
+		defer m.Unlock()
+		c.Wait()
+		t.Error("killed task returned from Wait")
+	})
+	woken := false
+	s.Spawn(func(interface{}) {
+		m.Lock()
+		defer m.Unlock()
+		c.Wait()
+		woken = true
+	})
+	s.RunUntil(func() bool { return false })
+	s.Kill(g)
+	s.Spawn(func(interface{}) {
+		m.Lock()
+		c.Signal()
+		m.Unlock()
+	})
+	s.Wait()
+	if !woken {
+		t.Error("Signal did not wake the task left waiting")
+	}
+	for {
+		s.mu.Lock()
+		left := s.goroutines
+		s.mu.Unlock()
+		if left == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !m.TryLock() {
+		t.Error("lock still held after the killed task unwound")
+	}
+}
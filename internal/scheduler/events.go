@@ -0,0 +1,280 @@
+package scheduler
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mziter/weft/trace"
+)
+
+// event is a trace event as recorded: fixed in size, with its stack
+// interned, so that recording one allocates nothing once the log has
+// grown. It becomes a trace.Event only when the trace is read.
+type event struct {
+	step, task, peer int
+	kind             trace.Kind
+	object           string
+	stack            stackID
+}
+
+// traceEvent returns ev as a trace.Event. Events with the same stack share
+// its frames, which must not be modified.
+func (ev event) traceEvent() trace.Event {
+	return trace.Event{
+		Step:   ev.step,
+		Task:   ev.task,
+		Kind:   ev.kind,
+		Object: ev.object,
+		Peer:   ev.peer,
+		Stack:  stacks.frames(ev.stack),
+	}
+}
+
+// eventLog holds the recorded events: every one, or only the most recent
+// limit, overwritten in a ring, once a limit is set.
+type eventLog struct {
+	buf   []event
+	limit int
+
+	// oldest is the index in buf of the oldest event once the ring is
+	// full.
+	oldest int
+
+	// budget, if positive, bounds the bytes of events held in memory,
+	// bytes; the older half of them moves to spill when it is exceeded.
+	budget, bytes int
+	spill         *spillFile
+}
+
+// initialEvents is the capacity an eventLog starts with, enough for most
+// runs never to grow it.
+const initialEvents = 1024
+
+// add appends ev to the log, overwriting the oldest event if the ring is
+// full.
+func (l *eventLog) add(ev event) {
+	if l.limit > 0 && len(l.buf) == l.limit {
+		l.buf[l.oldest] = ev
+		l.oldest = (l.oldest + 1) % l.limit
+		return
+	}
+	if l.buf == nil {
+		l.buf = make([]event, 0, max(initialEvents, l.limit))
+	}
+	l.buf = append(l.buf, ev)
+	if l.budget > 0 && l.limit == 0 {
+		l.bytes += ev.size()
+		if l.bytes > l.budget {
+			l.spillOlder()
+		}
+	}
+}
+
+// setBudget makes the log hold at most budget bytes of events in memory,
+// spilling older ones to a temporary file, or lifts the bound if budget is
+// not positive. A log with a limit has no need of one.
+func (l *eventLog) setBudget(budget int) {
+	if l.limit > 0 {
+		return
+	}
+	l.budget, l.bytes = budget, 0
+	for _, ev := range l.buf {
+		l.bytes += ev.size()
+	}
+	if budget > 0 && l.bytes > budget {
+		l.spillOlder()
+	}
+}
+
+// spillOlder moves the older half of the events in memory to the spill
+// file. If the file cannot be written, the log keeps its events in memory
+// and its budget is lifted.
+func (l *eventLog) spillOlder() {
+	var err error
+	if l.spill == nil {
+		l.spill, err = newSpillFile()
+	}
+	n := len(l.buf) / 2
+	if err == nil {
+		err = l.spill.write(l.buf[:n])
+	}
+	if err != nil {
+		l.budget = 0
+		return
+	}
+	l.buf = l.buf[:copy(l.buf, l.buf[n:])]
+	l.bytes = 0
+	for _, ev := range l.buf {
+		l.bytes += ev.size()
+	}
+}
+
+// setLimit makes the log keep only the most recent limit events, dropping
+// older ones it holds already.
+func (l *eventLog) setLimit(limit int) {
+	events := l.ordered()
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	l.buf = append(make([]event, 0, limit), events...)
+	l.limit, l.oldest = limit, 0
+	l.budget, l.bytes = 0, 0
+	if l.spill != nil {
+		l.spill.close()
+		l.spill = nil
+	}
+}
+
+// reset empties the log and lifts its limit and budget, keeping its
+// buffer and removing its spill file.
+func (l *eventLog) reset() {
+	l.buf, l.limit, l.oldest = l.buf[:0], 0, 0
+	l.budget, l.bytes = 0, 0
+	if l.spill != nil {
+		l.spill.close()
+		l.spill = nil
+	}
+}
+
+// ordered returns the events of the log, oldest first, reading back those
+// spilled; if the spill file cannot be read, those before the damage.
+func (l *eventLog) ordered() []event {
+	if l.spill != nil {
+		events, _ := l.spill.read()
+		return append(events, l.buf...)
+	}
+	if l.oldest == 0 {
+		return l.buf
+	}
+	return append(append([]event(nil), l.buf[l.oldest:]...), l.buf[:l.oldest]...)
+}
+
+// traceEvents returns the events of the log as trace.Events, oldest first.
+func (l *eventLog) traceEvents() []trace.Event {
+	events := l.ordered()
+	out := make([]trace.Event, len(events))
+	for i, ev := range events {
+		out[i] = ev.traceEvent()
+	}
+	return out
+}
+
+// maxStack bounds the frames kept for each trace event; the innermost few
+// locate an operation, and deep stacks would dominate long traces.
+const maxStack = 8
+
+// stackID identifies an interned call stack. The zero stackID is no stack.
+type stackID int32
+
+// stacks interns the call stacks of the events of every scheduler. A
+// program has few distinct call sites, so the table stays small however
+// many runs an exploration makes.
+var stacks = stackTable{ids: make(map[[32]uintptr]stackID), formatted: [][]string{nil}, tasks: []bool{false}}
+
+// stackTable maps the program counters of call stacks to their IDs, and
+// the IDs to the formatted frames and whether they may be a task's.
+type stackTable struct {
+	mu        sync.RWMutex
+	ids       map[[32]uintptr]stackID
+	formatted [][]string
+	tasks     []bool
+}
+
+// intern returns the ID of the stack whose program counters are pcs,
+// formatting it the first time it is seen.
+func (t *stackTable) intern(pcs [32]uintptr, n int) stackID {
+	t.mu.RLock()
+	id, ok := t.ids[pcs]
+	t.mu.RUnlock()
+	if ok {
+		return id
+	}
+	// Copied, so that pcs stays off the heap on the fast path.
+	cp := append([]uintptr(nil), pcs[:n]...)
+	frames := formatStack(cp)
+	task := n == len(pcs) || runsUnderDo(cp)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids[pcs]; ok {
+		return id
+	}
+	id = stackID(len(t.formatted))
+	t.formatted = append(t.formatted, frames)
+	t.tasks = append(t.tasks, task)
+	t.ids[pcs] = id
+	return id
+}
+
+// task reports whether the stack id may be a task's: whether it runs under
+// pprof.Do, as tasks do, or is too deep to tell. A stack that is not need
+// not be looked up by goroutine, which is slow.
+func (t *stackTable) task(id stackID) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.tasks[id]
+}
+
+// runsUnderDo reports whether the stack pcs passes through pprof.Do.
+func runsUnderDo(pcs []uintptr) bool {
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if f.Function == "runtime/pprof.Do" {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// frames returns the formatted frames of the stack id.
+func (t *stackTable) frames(id stackID) []string {
+	if id == 0 {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.formatted[id]
+}
+
+// callerStack returns the stack of the code calling into weft, interned
+// for a trace event.
+func callerStack() stackID {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	return stacks.intern(pcs, n)
+}
+
+// formatStack formats the frames of pcs that belong to the code under
+// test, innermost first, up to maxStack of them.
+func formatStack(pcs []uintptr) []string {
+	frames := runtime.CallersFrames(pcs)
+	var stack []string
+	for {
+		f, more := frames.Next()
+		if strings.HasPrefix(f.Function, "testing.") || strings.HasPrefix(f.Function, "runtime.") || strings.HasPrefix(f.Function, "runtime/pprof.") {
+			break
+		}
+		if !isWeftFrame(f.Function) {
+			stack = append(stack, fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line))
+		}
+		if !more || len(stack) == maxStack {
+			break
+		}
+	}
+	return stack
+}
+
+// isWeftFrame reports whether function belongs to weft's implementation
+// rather than the code under test.
+func isWeftFrame(function string) bool {
+	for _, pkg := range []string{"github.com/mziter/weft.", "github.com/mziter/weft/internal/", "github.com/mziter/weft/wefttest."} {
+		if strings.HasPrefix(function, pkg) {
+			return true
+		}
+	}
+	return false
+}
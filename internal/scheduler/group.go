@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"slices"
+	"sync/atomic"
+
+	"github.com/mziter/weft/trace"
+)
+
+// Group is a set of tasks killed together, such as the tasks of a simulated
+// node.
+type Group struct {
+	name string
+
+	killed atomic.Bool
+
+	// tasks is guarded by the scheduler's mu.
+	tasks map[int]*running
+}
+
+// NewGroup returns an empty group named name, for trace events.
+func NewGroup(name string) *Group {
+	return &Group{name: name, tasks: make(map[int]*running)}
+}
+
+// SpawnIn creates a new task belonging to g. If g has been killed the task
+// is not created.
+func (s *Scheduler) SpawnIn(g *Group, fn func(interface{})) {
+	Checkpoint()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g.killed.Load() {
+		return
+	}
+	s.spawn(g, false, fn)
+}
+
+// Kill kills the tasks of g, and any spawned in it later. Each stops at its
+// next scheduling point, running its deferred calls as it unwinds; Wait no
+// longer waits for them.
+func (s *Scheduler) Kill(g *Group) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g.killed.Load() {
+		return
+	}
+	g.killed.Store(true)
+	hooks.Add(1)
+	ids := make([]int, 0, len(g.tasks))
+	for id := range g.tasks {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		t := g.tasks[id]
+		t.exited = true
+		t.cancel()
+		s.record(event{kind: trace.Kill, peer: id, object: "node " + g.name})
+		s.retire(t)
+		s.waitGroup.Done()
+	}
+	g.tasks = nil
+}
@@ -0,0 +1,341 @@
+package scheduler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mziter/weft/trace"
+)
+
+// Decision is a scheduling decision put to a Decider: which of the runnable
+// tasks runs next.
+type Decision struct {
+	// Runnable lists the IDs of the tasks that can run.
+	Runnable []int
+
+	// Priorities holds the priority of each task of Runnable, for
+	// deciders that favor tasks of higher priority.
+	Priorities []int
+
+	// Events are the events recorded so far, or the most recent ones if
+	// the trace is being streamed.
+	Events []trace.Event
+
+	// Made is the number of decisions made before this one.
+	Made int
+}
+
+// A Decider makes scheduling decisions in place of the seed. Decide returns
+// the index in d.Runnable of the task to run, or false to leave the decision
+// to the seed.
+type Decider interface {
+	Decide(d Decision) (int, bool)
+}
+
+// SetDecider makes d decide which task runs at each scheduling point not
+// covered by replayed choices. Decisions are recorded as choices, so a
+// session can be replayed afterwards.
+func (s *Scheduler) SetDecider(d Decider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.decider = d
+}
+
+// chooseTask picks the task to run from runnable and returns its index. The
+// caller must hold s.mu.
+func (s *Scheduler) chooseTask(runnable []int) int {
+	candidates := runnable
+	if s.strictPriority {
+		candidates = s.highestPriority(runnable)
+	}
+	c := s.chooseAmong(candidates)
+	if len(candidates) < len(runnable) {
+		c = slices.Index(runnable, candidates[c])
+	}
+	return c
+}
+
+// chooseAmong picks the task to run from candidates and returns its index.
+// The caller must hold s.mu.
+func (s *Scheduler) chooseAmong(candidates []int) int {
+	if s.decider != nil && len(s.replay) == 0 && len(candidates) > 1 {
+		d := Decision{
+			Runnable:   append([]int(nil), candidates...),
+			Priorities: make([]int, len(candidates)),
+			Events:     s.events.traceEvents(),
+			Made:       len(s.choices),
+		}
+		for i, id := range candidates {
+			if t := s.live[id]; t != nil {
+				d.Priorities[i] = t.priority
+			}
+		}
+		if c, ok := s.decider.Decide(d); ok {
+			s.choices = append(s.choices, c)
+			return c
+		}
+	}
+	return s.choose(len(candidates))
+}
+
+// highestPriority returns the tasks of runnable whose priority is the
+// highest among them, in the same order. The caller must hold s.mu.
+func (s *Scheduler) highestPriority(runnable []int) []int {
+	var top []int
+	best := 0
+	for _, id := range runnable {
+		p := 0
+		if t := s.live[id]; t != nil {
+			p = t.priority
+		}
+		switch {
+		case len(top) == 0 || p > best:
+			top, best = append(top[:0], id), p
+		case p == best:
+			top = append(top, id)
+		}
+	}
+	return top
+}
+
+// SetStrictPriority makes each task decision, replayed or not, choose only
+// among the runnable tasks of highest priority, so that a task runs only
+// while none of higher priority can.
+func (s *Scheduler) SetStrictPriority(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strictPriority = on
+}
+
+// Interactive is a Decider that puts each decision to a person over a line
+// protocol, read from r and answered on w, such as a terminal or a TCP
+// connection. At each decision it lists the runnable tasks and accepts
+// commands to inspect tasks, events and primitives before choosing.
+type Interactive struct {
+	in  *bufio.Scanner
+	out io.Writer
+
+	// auto is set once the user hands the remaining decisions to the seed.
+	auto bool
+
+	// rewind, if set, restarts the run replaying the given number of its
+	// decisions.
+	rewind func(decisions int)
+}
+
+// NewInteractive returns an Interactive decider reading commands from r and
+// writing to w.
+func NewInteractive(r io.Reader, w io.Writer) *Interactive {
+	return &Interactive{in: bufio.NewScanner(r), out: w}
+}
+
+// SetRewind makes the rewind command call rewind with the number of
+// decisions to replay, for it to restart the run from there. rewind is not
+// expected to return.
+func (i *Interactive) SetRewind(rewind func(decisions int)) {
+	i.rewind = rewind
+}
+
+// SetRewind sets the rewind function of the scheduler's decider, if it is
+// an Interactive.
+func (s *Scheduler) SetRewind(rewind func(decisions int)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, ok := s.decider.(*Interactive); ok {
+		i.SetRewind(rewind)
+	}
+}
+
+const interactiveHelp = `commands:
+  N, run N     run task N next
+  ls           list the runnable tasks
+  tasks        show where every task is
+  objects      show the state of mutexes and channels
+  events [N]   show the last N events (default 10)
+  rewind [N]   go back N decisions (default 1), replaying the run to there
+  c, continue  let the seed make the remaining decisions
+  help         show this help
+`
+
+// Decide implements Decider.
+func (i *Interactive) Decide(d Decision) (int, bool) {
+	if i.auto {
+		return 0, false
+	}
+	last := lastEvents(d.Events)
+	step := 0
+	if n := len(d.Events); n > 0 {
+		step = d.Events[n-1].Step + 1
+	}
+	fmt.Fprintf(i.out, "step %d, decision %d: %d tasks runnable\n", step, d.Made, len(d.Runnable))
+	i.listRunnable(d, last)
+	for {
+		fmt.Fprint(i.out, "weft> ")
+		if !i.in.Scan() {
+			// The session ended: finish the run on the seed.
+			i.auto = true
+			return 0, false
+		}
+		fields := strings.Fields(i.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		cmd, args := fields[0], fields[1:]
+		if cmd == "run" && len(args) == 1 {
+			cmd, args = args[0], nil
+		}
+		switch cmd {
+		case "ls":
+			i.listRunnable(d, last)
+		case "tasks":
+			i.listTasks(last)
+		case "objects":
+			i.listObjects(d.Events)
+		case "events":
+			n := 10
+			if len(args) == 1 {
+				if v, err := strconv.Atoi(args[0]); err == nil && v > 0 {
+					n = v
+				}
+			}
+			start := max(len(d.Events)-n, 0)
+			for _, ev := range d.Events[start:] {
+				fmt.Fprintf(i.out, "  %4d  %s\n", ev.Step, ev)
+			}
+		case "rewind":
+			n := 1
+			if len(args) == 1 {
+				if v, err := strconv.Atoi(args[0]); err == nil && v > 0 {
+					n = v
+				}
+			}
+			if i.rewind == nil {
+				fmt.Fprintln(i.out, "rewind needs a replay under the weft command: weft replay -i")
+				continue
+			}
+			to := max(d.Made-n, 0)
+			fmt.Fprintf(i.out, "rewinding to decision %d\n", to)
+			i.rewind(to)
+		case "c", "continue":
+			i.auto = true
+			return 0, false
+		case "help", "?":
+			fmt.Fprint(i.out, interactiveHelp)
+		default:
+			id, err := strconv.Atoi(cmd)
+			if err != nil {
+				fmt.Fprintf(i.out, "unknown command %q; type help for commands\n", cmd)
+				continue
+			}
+			for idx, task := range d.Runnable {
+				if task == id {
+					return idx, true
+				}
+			}
+			fmt.Fprintf(i.out, "task %d is not runnable\n", id)
+		}
+	}
+}
+
+func (i *Interactive) listRunnable(d Decision, last map[int]trace.Event) {
+	for _, task := range d.Runnable {
+		fmt.Fprintf(i.out, "  %s\n", describeTask(task, last))
+	}
+}
+
+func (i *Interactive) listTasks(last map[int]trace.Event) {
+	tasks := make([]int, 0, len(last))
+	for task := range last {
+		tasks = append(tasks, task)
+	}
+	sort.Ints(tasks)
+	for _, task := range tasks {
+		fmt.Fprintf(i.out, "  %s\n", describeTask(task, last))
+	}
+}
+
+// listObjects prints, for each primitive seen in events, the task holding
+// it, if it is a mutex, the tasks blocked on it, and its last operation.
+func (i *Interactive) listObjects(events []trace.Event) {
+	type state struct {
+		holder  int
+		held    bool
+		blocked []int
+		last    trace.Event
+	}
+	objects := make(map[string]*state)
+	var names []string
+	for _, ev := range events {
+		if ev.Object == "" {
+			continue
+		}
+		st, ok := objects[ev.Object]
+		if !ok {
+			st = new(state)
+			objects[ev.Object] = st
+			names = append(names, ev.Object)
+		}
+		switch ev.Kind {
+		case trace.Lock:
+			st.holder, st.held = ev.Task, true
+		case trace.Unlock:
+			st.held = false
+		}
+		st.last = ev
+	}
+	for task, ev := range lastEvents(events) {
+		if ev.Kind == trace.Block && objects[ev.Object] != nil {
+			objects[ev.Object].blocked = append(objects[ev.Object].blocked, task)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(i.out, "  no primitives used yet")
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		st := objects[name]
+		fmt.Fprintf(i.out, "  %s:", name)
+		if st.held {
+			fmt.Fprintf(i.out, " held by %s;", taskName(st.holder))
+		}
+		if len(st.blocked) > 0 {
+			sort.Ints(st.blocked)
+			blocked := make([]string, len(st.blocked))
+			for j, task := range st.blocked {
+				blocked[j] = taskName(task)
+			}
+			fmt.Fprintf(i.out, " blocking %s;", strings.Join(blocked, ", "))
+		}
+		fmt.Fprintf(i.out, " last %s\n", st.last)
+	}
+}
+
+// lastEvents maps each task in events to its last event.
+func lastEvents(events []trace.Event) map[int]trace.Event {
+	last := make(map[int]trace.Event)
+	for _, ev := range events {
+		last[ev.Task] = ev
+	}
+	return last
+}
+
+// describeTask describes a task by its last event.
+func describeTask(task int, last map[int]trace.Event) string {
+	ev, ok := last[task]
+	if !ok {
+		return taskName(task) + " not started"
+	}
+	return ev.String()
+}
+
+func taskName(task int) string {
+	if task == 0 {
+		return "test"
+	}
+	return "task " + strconv.Itoa(task)
+}
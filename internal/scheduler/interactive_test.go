@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestInteractive verifies that commands inspect the run without deciding,
+// that only runnable tasks can be chosen, and that continue hands the rest
+// of the run to the seed.
+func TestInteractive(t *testing.T) {
+	d := Decision{
+		Runnable: []int{1, 3},
+		Events: []trace.Event{
+			{Step: 0, Task: 0, Kind: trace.Spawn, Peer: 1},
+			{Step: 1, Task: 1, Kind: trace.Lock, Object: "mutex 1"},
+			{Step: 2, Task: 2, Kind: trace.Block, Object: "mutex 1"},
+		},
+	}
+	var out bytes.Buffer
+	in := NewInteractive(strings.NewReader("objects\n2\nbogus\nrun 3\ncontinue\n"), &out)
+	if c, ok := in.Decide(d); !ok || c != 1 {
+		t.Fatalf("Decide = %d, %v; want 1, true\n%s", c, ok, out.String())
+	}
+	for _, want := range []string{
+		"task 1 lock mutex 1",
+		"mutex 1: held by task 1; blocking task 2;",
+		"task 2 is not runnable",
+		`unknown command "bogus"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out.String())
+		}
+	}
+	if _, ok := in.Decide(d); ok {
+		t.Error("Decide decided after continue")
+	}
+	if _, ok := in.Decide(d); ok {
+		t.Error("Decide decided after continue")
+	}
+}
+
+// TestChooseTaskDecider verifies that a decider's decisions are recorded as
+// choices, and that replayed choices take precedence over it.
+func TestChooseTaskDecider(t *testing.T) {
+	s := NewReplay(1, []int{0})
+	s.SetDecider(NewInteractive(strings.NewReader("7\n"), new(bytes.Buffer)))
+	if c := s.chooseTask([]int{4, 7}); c != 0 {
+		t.Errorf("replayed choice = %d, want 0", c)
+	}
+	if c := s.chooseTask([]int{4, 7}); c != 1 {
+		t.Errorf("interactive choice = %d, want 1", c)
+	}
+	if got := s.Choices(); len(got) != 2 || got[1] != 1 {
+		t.Errorf("Choices = %v, want [0 1]", got)
+	}
+}
+
+// TestInteractiveRewind verifies that rewind asks to replay the decisions
+// made before the last N, and is refused without a rewind function.
+func TestInteractiveRewind(t *testing.T) {
+	d := Decision{Runnable: []int{1, 2}, Made: 5}
+	var out bytes.Buffer
+	in := NewInteractive(strings.NewReader("rewind 2\nrewind 9\n1\n"), &out)
+	var rewound []int
+	in.SetRewind(func(decisions int) { rewound = append(rewound, decisions) })
+	if c, ok := in.Decide(d); !ok || c != 0 {
+		t.Fatalf("Decide = %d, %v; want 0, true\n%s", c, ok, out.String())
+	}
+	if len(rewound) != 2 || rewound[0] != 3 || rewound[1] != 0 {
+		t.Errorf("rewound to %v, want [3 0]", rewound)
+	}
+
+	out.Reset()
+	in = NewInteractive(strings.NewReader("rewind\n1\n"), &out)
+	in.Decide(d)
+	if !strings.Contains(out.String(), "rewind needs") {
+		t.Errorf("rewind without a rewind function printed:\n%s", out.String())
+	}
+}
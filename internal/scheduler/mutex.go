@@ -1,60 +1,269 @@
 package scheduler
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 // Mutex is a deterministic mutex.
+//
+// A task waiting for the mutex parks in its scheduler's blocked set, and
+// Unlock hands the mutex to a waiting task chosen by the schedule, so that
+// each seed explores its own order of acquisition.
 type Mutex struct {
-	mu sync.Mutex
-	// TODO: Add deterministic scheduling
+	// state holds mutexLocked and, above it, the number of tasks waiting
+	// for the mutex.
+	state atomic.Int32
+
+	// mu orders the tasks joining the queue of waiters against Unlock
+	// handing the mutex to one of them, which then holds it without
+	// contending for it again.
+	mu      sync.Mutex
+	waiters Blocker
 }
 
+const (
+	mutexLocked = 1
+	mutexWaiter = 2
+)
+
 // NewMutex creates a new deterministic mutex.
 func NewMutex() *Mutex {
-	return &Mutex{}
+	return &Mutex{waiters: Blocker{name: "mutex"}}
 }
 
-// Lock locks the mutex.
+// Lock locks the mutex after a scheduling point.
+//
+// An uncontended mutex, unlocked with no task waiting for it, is then taken
+// with a single compare-and-swap, inside a run or out of one.
 func (m *Mutex) Lock() {
+	Checkpoint()
+	if m.state.CompareAndSwap(0, mutexLocked) {
+		return
+	}
+	m.lockSlow()
+}
+
+// lockSlow locks a mutex held or contended, parking until Unlock hands it
+// over if it is held.
+func (m *Mutex) lockSlow() {
 	m.mu.Lock()
+	for {
+		old := m.state.Load()
+		if old&mutexLocked == 0 {
+			if m.state.CompareAndSwap(old, old|mutexLocked) {
+				m.mu.Unlock()
+				return
+			}
+			continue
+		}
+		if m.state.CompareAndSwap(old, old+mutexWaiter) {
+			break
+		}
+	}
+	w := m.waiters.Waiter()
+	m.mu.Unlock()
+	if !w.park() {
+		m.abandon(w)
+		w.task.exit()
+	}
 }
 
-// Unlock unlocks the mutex.
-func (m *Mutex) Unlock() {
+// abandon gives up the place of a killed task in the queue of waiters, or
+// the mutex, if Unlock handed it over all the same.
+func (m *Mutex) abandon(w *Waiter) {
+	m.mu.Lock()
+	if m.waiters.remove(w) {
+		m.state.Add(-mutexWaiter)
+		m.mu.Unlock()
+		return
+	}
 	m.mu.Unlock()
+	m.Unlock()
+}
+
+// Unlock unlocks the mutex, or hands it to a task waiting for it.
+func (m *Mutex) Unlock() {
+	if m.state.CompareAndSwap(mutexLocked, 0) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		old := m.state.Load()
+		if old&mutexLocked == 0 {
+			panic("unlock of unlocked mutex")
+		}
+		if old == mutexLocked {
+			if m.state.CompareAndSwap(old, 0) {
+				return
+			}
+			continue
+		}
+		// The mutex stays locked, now on behalf of the waiter.
+		if m.state.CompareAndSwap(old, old-mutexWaiter) {
+			m.waiters.Unpark()
+			return
+		}
+	}
 }
 
 // TryLock tries to lock the mutex.
 func (m *Mutex) TryLock() bool {
-	return m.mu.TryLock()
+	for {
+		old := m.state.Load()
+		if old&mutexLocked != 0 {
+			return false
+		}
+		if m.state.CompareAndSwap(old, old|mutexLocked) {
+			return true
+		}
+	}
 }
 
 // RWMutex is a deterministic reader/writer mutex.
+//
+// Readers and writers waiting for the mutex park in their scheduler's
+// blocked set, and the Unlock or RUnlock releasing it hands it over: to one
+// waiting writer, chosen by the schedule, or to every waiting reader, the
+// schedule choosing which of the two when both wait. As with
+// sync.RWMutex, a waiting writer keeps new readers out.
 type RWMutex struct {
-	mu sync.RWMutex
-	// TODO: Add deterministic scheduling
+	// mu guards the rest. readers counts the readers holding the mutex,
+	// and writer is set while a writer does; waitingReaders and
+	// waitingWriters count the tasks parked in readerQueue and
+	// writerQueue, which hold the mutex once handed it.
+	mu             sync.Mutex
+	readers        int
+	writer         bool
+	waitingReaders int
+	waitingWriters int
+	readerQueue    Blocker
+	writerQueue    Blocker
 }
 
 // NewRWMutex creates a new deterministic RWMutex.
 func NewRWMutex() *RWMutex {
-	return &RWMutex{}
+	return &RWMutex{
+		readerQueue: Blocker{name: "rwmutex"},
+		writerQueue: Blocker{name: "rwmutex"},
+	}
 }
 
 // Lock locks for writing.
 func (rw *RWMutex) Lock() {
+	Checkpoint()
+	rw.mu.Lock()
+	if !rw.writer && rw.readers == 0 {
+		rw.writer = true
+		rw.mu.Unlock()
+		return
+	}
+	rw.waitingWriters++
+	w := rw.writerQueue.Waiter()
+	rw.mu.Unlock()
+	if !w.park() {
+		rw.abandonLock(w)
+		w.task.exit()
+	}
+}
+
+// abandonLock gives up the place of a killed writer in the queue, letting
+// in the readers it kept out, or the mutex, if it was handed over all the
+// same.
+func (rw *RWMutex) abandonLock(w *Waiter) {
 	rw.mu.Lock()
+	if !rw.writerQueue.remove(w) {
+		rw.mu.Unlock()
+		rw.Unlock()
+		return
+	}
+	defer rw.mu.Unlock()
+	rw.waitingWriters--
+	if !rw.writer && rw.waitingWriters == 0 {
+		rw.admitReaders()
+	}
 }
 
-// Unlock unlocks for writing.
+// Unlock unlocks for writing, handing the mutex to waiting tasks.
 func (rw *RWMutex) Unlock() {
-	rw.mu.Unlock()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if !rw.writer {
+		panic("unlock of unlocked mutex")
+	}
+	rw.writer = false
+	switch {
+	case rw.waitingReaders > 0 && rw.waitingWriters > 0:
+		if pick(2, rw.writerQueue.scheduler()) == 0 {
+			rw.admitReaders()
+		} else {
+			rw.admitWriter()
+		}
+	case rw.waitingReaders > 0:
+		rw.admitReaders()
+	case rw.waitingWriters > 0:
+		rw.admitWriter()
+	}
 }
 
 // RLock locks for reading.
 func (rw *RWMutex) RLock() {
-	rw.mu.RLock()
+	Checkpoint()
+	rw.mu.Lock()
+	if !rw.writer && rw.waitingWriters == 0 {
+		rw.readers++
+		rw.mu.Unlock()
+		return
+	}
+	rw.waitingReaders++
+	w := rw.readerQueue.Waiter()
+	rw.mu.Unlock()
+	if !w.park() {
+		rw.abandonRLock(w)
+		w.task.exit()
+	}
+}
+
+// abandonRLock gives up the place of a killed reader in the queue, or its
+// hold on the mutex, if it was handed over all the same.
+func (rw *RWMutex) abandonRLock(w *Waiter) {
+	rw.mu.Lock()
+	if rw.readerQueue.remove(w) {
+		rw.waitingReaders--
+		rw.mu.Unlock()
+		return
+	}
+	rw.mu.Unlock()
+	rw.RUnlock()
 }
 
-// RUnlock unlocks for reading.
+// RUnlock unlocks for reading, handing the mutex to a waiting writer once
+// the last reader has.
 func (rw *RWMutex) RUnlock() {
-	rw.mu.RUnlock()
-}
\ No newline at end of file
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.readers == 0 {
+		panic("runlock of unlocked mutex")
+	}
+	rw.readers--
+	if rw.readers == 0 && rw.waitingWriters > 0 {
+		rw.admitWriter()
+	}
+}
+
+// admitReaders hands the mutex to every waiting reader. The caller must
+// hold rw.mu.
+func (rw *RWMutex) admitReaders() {
+	rw.readers += rw.waitingReaders
+	rw.waitingReaders = 0
+	rw.readerQueue.UnparkAll()
+}
+
+// admitWriter hands the mutex to a waiting writer chosen by the schedule.
+// The caller must hold rw.mu.
+func (rw *RWMutex) admitWriter() {
+	rw.writer = true
+	rw.waitingWriters--
+	rw.writerQueue.Unpark()
+}
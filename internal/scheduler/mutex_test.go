@@ -0,0 +1,238 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestMutexContended verifies that tasks contending for a mutex, handed it
+// by Unlock, still exclude each other.
+func TestMutexContended(t *testing.T) {
+	s := New(1)
+	m := NewMutex()
+	n := 0
+	for i := 0; i < 8; i++ {
+		s.Spawn(func(interface{}) {
+			for j := 0; j < 1000; j++ {
+				m.Lock()
+				n++
+				m.Unlock()
+			}
+		})
+	}
+	s.Wait()
+	if n != 8000 {
+		t.Errorf("n = %d, want 8000", n)
+	}
+	if !m.TryLock() {
+		t.Fatal("TryLock failed on an unlocked mutex")
+	}
+	if m.TryLock() {
+		t.Error("TryLock succeeded on a locked mutex")
+	}
+	m.Unlock()
+}
+
+// TestMutexOrder verifies that tasks waiting for a mutex park in the
+// scheduler and acquire it in an order chosen by the seed: the same for the
+// same seed, and not for every seed.
+func TestMutexOrder(t *testing.T) {
+	run := func(seed uint64) (order []int, blocked int) {
+		s := New(seed)
+		m := NewMutex()
+		for i := 0; i < 4; i++ {
+			s.Spawn(func(interface{}) {
+				m.Lock()
+				// Let the others queue up behind the holder.
+				Checkpoint()
+				order = append(order, i)
+				m.Unlock()
+			})
+		}
+		s.Wait()
+		for _, ev := range s.Events() {
+			if ev.Kind == trace.Block && ev.Object == "mutex" {
+				blocked++
+			}
+		}
+		return order, blocked
+	}
+	orders := make(map[string]bool)
+	blocked := 0
+	for seed := uint64(1); seed <= 20; seed++ {
+		order, n := run(seed)
+		if again, _ := run(seed); !slices.Equal(again, order) {
+			t.Errorf("seed %d: orders %v, then %v", seed, order, again)
+		}
+		orders[fmt.Sprint(order)] = true
+		blocked += n
+	}
+	if blocked == 0 {
+		t.Error("no task blocked on the mutex with any seed")
+	}
+	if len(orders) < 2 {
+		t.Errorf("20 seeds all acquired the mutex in the order %v", orders)
+	}
+}
+
+// TestMutexFastPath verifies that an uncontended Lock and Unlock allocate
+// nothing.
+func TestMutexFastPath(t *testing.T) {
+	if hooks.Load() != 0 {
+		t.Skip("a hook is in use, as after a kill, so Lock looks up its task")
+	}
+	m := NewMutex()
+	allocs := testing.AllocsPerRun(100, func() {
+		m.Lock()
+		m.Unlock()
+	})
+	if allocs != 0 {
+		t.Errorf("Lock and Unlock allocate %v times, want 0", allocs)
+	}
+}
+
+// TestMutexKilledWaiter verifies that a task killed while waiting for a
+// mutex leaves it free once unlocked, whether or not Unlock handed it over.
+func TestMutexKilledWaiter(t *testing.T) {
+	s := New(1)
+	m := NewMutex()
+	g := NewGroup("waiter")
+	m.Lock()
+	s.SpawnIn(g, func(interface{}) {
+		m.Lock()
+		t.Error("killed task acquired the mutex")
+	})
+	s.RunUntil(func() bool { return false })
+	s.Kill(g)
+	m.Unlock()
+	s.Wait()
+	for {
+		s.mu.Lock()
+		left := s.goroutines
+		s.mu.Unlock()
+		if left == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !m.TryLock() {
+		t.Error("mutex still locked after its killed waiter unwound")
+	}
+}
+
+// TestRWMutex verifies that writers exclude readers and each other while
+// readers share the mutex, that waiters park in the scheduler, and that
+// the order in which Unlock and RUnlock hand the mutex over is chosen by
+// the seed: the same for the same seed, and not for every seed.
+func TestRWMutex(t *testing.T) {
+	run := func(seed uint64) (order []string, blocked int) {
+		s := New(seed)
+		rw := NewRWMutex()
+		readers, writers := 0, 0
+		check := func() {
+			if writers > 1 || writers == 1 && readers > 0 {
+				t.Errorf("seed %d: %d writers and %d readers hold the mutex", seed, writers, readers)
+			}
+		}
+		for i := range 4 {
+			s.Spawn(func(interface{}) {
+				if i%2 == 0 {
+					rw.Lock()
+					writers++
+					check()
+					Checkpoint()
+					order = append(order, fmt.Sprint("w", i))
+					writers--
+					rw.Unlock()
+					return
+				}
+				rw.RLock()
+				readers++
+				check()
+				Checkpoint()
+				order = append(order, fmt.Sprint("r", i))
+				readers--
+				rw.RUnlock()
+			})
+		}
+		s.Wait()
+		for _, ev := range s.Events() {
+			if ev.Kind == trace.Block && ev.Object == "rwmutex" {
+				blocked++
+			}
+		}
+		return order, blocked
+	}
+	orders := make(map[string]bool)
+	blocked := 0
+	for seed := uint64(1); seed <= 20; seed++ {
+		order, n := run(seed)
+		if again, _ := run(seed); !slices.Equal(again, order) {
+			t.Errorf("seed %d: orders %v, then %v", seed, order, again)
+		}
+		orders[fmt.Sprint(order)] = true
+		blocked += n
+	}
+	if blocked == 0 {
+		t.Error("no task blocked on the mutex with any seed")
+	}
+	if len(orders) < 2 {
+		t.Errorf("20 seeds all acquired the mutex in the order %v", orders)
+	}
+}
+
+// TestRWMutexKilledWaiter verifies that a writer killed while waiting lets
+// in the readers it kept out.
+func TestRWMutexKilledWaiter(t *testing.T) {
+	s := New(1)
+	rw := NewRWMutex()
+	g := NewGroup("writer")
+	rw.RLock()
+	s.SpawnIn(g, func(interface{}) {
+		rw.Lock()
+		t.Error("killed task acquired the mutex")
+	})
+	s.RunUntil(func() bool { return false })
+	read := false
+	s.Spawn(func(interface{}) {
+		rw.RLock()
+		read = true
+		rw.RUnlock()
+	})
+	s.RunUntil(func() bool { return false })
+	if read {
+		t.Fatal("reader acquired the mutex past a waiting writer")
+	}
+	s.Kill(g)
+	s.Wait()
+	if !read {
+		t.Error("reader did not acquire the mutex once the writer was killed")
+	}
+	rw.RUnlock()
+}
+
+func BenchmarkMutex(b *testing.B) {
+	m := NewMutex()
+	for i := 0; i < b.N; i++ {
+		m.Lock()
+		m.Unlock()
+	}
+}
+
+// BenchmarkMutexTask measures an uncontended Lock and Unlock by a task,
+// each Lock a scheduling point of its run.
+func BenchmarkMutexTask(b *testing.B) {
+	s := New(1)
+	m := NewMutex()
+	s.Spawn(func(interface{}) {
+		for i := 0; i < b.N; i++ {
+			m.Lock()
+			m.Unlock()
+		}
+	})
+	s.Wait()
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+// Events outside the schedule, such as a context canceled by a goroutine
+// other than a task, or real I/O finishing, would wake tasks whenever that
+// goroutine happened to run. AfterDone brings the first kind into the
+// schedule, polling for it only when no task can run, and Aside keeps the
+// second from passing for a deadlock while it is pending.
+
+// after is a call of AfterDone still waiting.
+type after struct {
+	done func() bool
+	f    func()
+}
+
+// AfterDone spawns a task calling f once done reports true, as
+// context.AfterFunc calls its function on a new goroutine once a context
+// is done. done is polled only when no task of the schedulers keeping the
+// clock of s can run, before the clock advances and before a deadlock is
+// declared, so the task is spawned at a point of the schedule rather than
+// whenever the goroutine ending the context ran. The task is detached, a
+// child of no task. The returned stop ends the wait and reports whether
+// it did, false if the task was spawned already.
+func (s *Scheduler) AfterDone(done func() bool, f func()) (stop func() bool) {
+	a := &after{done: done, f: f}
+	s.mu.Lock()
+	s.afters = append(s.afters, a)
+	s.mu.Unlock()
+	return func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, b := range s.afters {
+			if b == a {
+				s.afters = append(s.afters[:i], s.afters[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// spawnAfters spawns the tasks of the calls of AfterDone whose done
+// reports true and reports whether there were any. s must be frozen, so
+// that none of them runs before the clock thaws s.
+func (s *Scheduler) spawnAfters() bool {
+	s.mu.Lock()
+	afters := append([]*after(nil), s.afters...)
+	s.mu.Unlock()
+	spawned := false
+	for _, a := range afters {
+		if !a.done() {
+			continue
+		}
+		s.mu.Lock()
+		for i, b := range s.afters {
+			if b == a {
+				s.afters = append(s.afters[:i], s.afters[i+1:]...)
+				s.spawn(nil, true, func(interface{}) { a.f() })
+				spawned = true
+				break
+			}
+		}
+		s.mu.Unlock()
+	}
+	return spawned
+}
+
+// Aside records that a goroutine other than a task is doing work, such as
+// real I/O, that will wake a task of s, until the returned done is
+// called, so that the tasks all blocked meanwhile are not deadlocked.
+func (s *Scheduler) Aside() (done func()) {
+	s.mu.Lock()
+	s.aside++
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.aside--
+		if s.holder == 0 {
+			s.pass()
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestAfterDone verifies that a call of AfterDone spawns its task once its
+// event has come and every task is blocked, and that a stopped one does
+// not.
+func TestAfterDone(t *testing.T) {
+	s := New(1)
+	var canceled, stopped atomic.Bool
+	ch := MakeChan[struct{}](0)
+	s.AfterDone(canceled.Load, func() { ch.Close() })
+	stop := s.AfterDone(func() bool { return true }, func() { stopped.Store(true) })
+	if !stop() {
+		t.Error("stop() = false before the event, want true")
+	}
+	s.Spawn(func(interface{}) { ch.Recv() })
+	s.RunUntil(func() bool { return false })
+	canceled.Store(true)
+	s.Wait()
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil once the event closed the channel", err)
+	}
+	if stopped.Load() {
+		t.Error("stopped call of AfterDone ran its function")
+	}
+}
+
+// TestAside verifies that a task waiting for work aside is not taken for
+// deadlocked.
+func TestAside(t *testing.T) {
+	s := New(1)
+	ch := MakeChan[int](1)
+	finish := make(chan struct{})
+	s.Spawn(func(interface{}) {
+		done := s.Aside()
+		go func() {
+			defer done()
+			<-finish
+			ch.Send(1)
+		}()
+		ch.Recv()
+	})
+	s.RunUntil(func() bool { return false })
+	waited := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Errorf("Wait returned with the work aside pending: %v", s.Err())
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(finish)
+	<-waited
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
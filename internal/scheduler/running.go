@@ -0,0 +1,374 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mziter/weft/trace"
+)
+
+// running is a task that has been spawned and not yet exited.
+type running struct {
+	s     *Scheduler
+	id    int
+	group *Group
+
+	// done is closed when the task's context is canceled, by chaos or by
+	// its group being killed.
+	done chan struct{}
+
+	// exited and canceled are guarded by s.mu. exited is set once the task
+	// returned or was killed; exiting once it has started to unwind after
+	// being killed.
+	exited, canceled bool
+	exiting          atomic.Bool
+
+	// runFor is the number of scheduling points t has left to pass
+	// before chaos next injects a fault, in a run drawn for runChaos. It
+	// is guarded by s.mu.
+	runFor   int
+	runChaos *Chaos
+
+	// children are the IDs of the tasks t spawned that were not detached,
+	// which are canceled with it. It is guarded by s.mu.
+	children []int
+
+	// priority is the task's priority, inherited from the task that
+	// spawned it. It is guarded by s.mu.
+	priority int
+
+	// task is the task's entry in s.tasks, holding its state, and gid the
+	// ID of the goroutine running the task, once it has started; they are
+	// guarded by s.mu. token is sent on when the task is given the turn or
+	// killed. drives is set once the task has spawned a task of another
+	// scheduler, of which it is a driver.
+	task   *Task
+	gid    uint64
+	token  chan struct{}
+	drives atomic.Bool
+
+	// parent is the task that spawned t, or 0, and spawn the stack that
+	// did. steps counts the events recorded for t, and blockedOn is the
+	// object of its last event if that was a Block. They are guarded by
+	// s.mu and reported by Tasks.
+	parent    int
+	spawn     stackID
+	steps     int
+	blockedOn string
+}
+
+// cancel closes t.done, once, and cancels t's children that have not
+// exited. The caller must hold t.s.mu.
+func (t *running) cancel() {
+	if t.canceled {
+		return
+	}
+	t.canceled = true
+	close(t.done)
+	for _, id := range t.children {
+		if c := t.s.live[id]; c != nil {
+			c.cancel()
+		}
+	}
+}
+
+// adopt makes c a child of t, canceled at once if t has been. The caller
+// must hold t.s.mu.
+func (t *running) adopt(c *running) {
+	if len(t.children) == cap(t.children) {
+		// Forget the children that exited rather than grow, so that a
+		// long-lived task spawning many keeps a short list.
+		t.children = slices.DeleteFunc(t.children, func(id int) bool { return t.s.live[id] == nil })
+	}
+	t.children = append(t.children, c.id)
+	if t.canceled {
+		c.cancel()
+	}
+}
+
+// runningPool holds the records of exited tasks for reuse, so that the
+// thousands of runs of an exploration do not each allocate one per task.
+var runningPool = sync.Pool{New: func() any { return new(running) }}
+
+// byGoroutine maps goroutine IDs to the tasks they run.
+var byGoroutine sync.Map
+
+// bindings maps the IDs of the goroutines bound by Bind, which run no task,
+// to their schedulers.
+var bindings sync.Map
+
+// hooks counts the schedulers with task goroutines running, and the groups
+// killed, schedulers given chaos or a bound on scheduling points, and links
+// made so far; until there is one, Checkpoint need not look up the calling
+// task.
+var hooks atomic.Int64
+
+// spawn creates a new task, in g if g is not nil, and passes fn the
+// channel closed when the task's context is canceled. Unless detached, the
+// task is a child of the calling task, canceled with it. The caller must
+// hold s.mu.
+func (s *Scheduler) spawn(g *Group, detached bool, fn func(interface{})) {
+	s.nextID++
+	id := s.nextID
+	// The done channel is not reused: the task's context may outlive it.
+	// Nor is token, which must belong to a synctest bubble the task runs
+	// in to block it durably.
+	t := runningPool.Get().(*running)
+	t.s, t.id, t.group, t.done = s, id, g, make(chan struct{})
+	t.token = make(chan struct{}, 1)
+	t.task = NewTask(id, nil)
+	s.tasks.add(t.task)
+	ev := event{kind: trace.Spawn, peer: id, stack: callerStack()}
+	t.spawn = ev.stack
+	if s.live == nil {
+		s.live = make(map[int]*running)
+	}
+	s.live[id] = t
+	var parent *running
+	if stacks.task(ev.stack) {
+		parent = s.caller()
+	}
+	switch {
+	case parent == nil && s.frozen:
+		// Spawned by a timer as the clock fires it: the clock is no
+		// driver.
+	case parent == nil:
+		s.addDriver()
+	default:
+		ev.task, t.parent = parent.id, parent.id
+		t.priority = parent.priority
+		if !detached {
+			parent.adopt(t)
+		}
+	}
+	if g != nil {
+		g.tasks[id] = t
+		ev.object = "node " + g.name
+	}
+	s.record(ev)
+	if s.goroutines == 0 {
+		hooks.Add(1)
+	}
+	s.goroutines++
+	s.waitGroup.Add(1)
+	s.startWatch()
+	if s.holder == 0 {
+		s.pass()
+	}
+	onPanic := s.onPanic
+	go func() {
+		defer t.release()
+		gid := goid()
+		byGoroutine.Store(gid, t)
+		defer byGoroutine.Delete(gid)
+		s.mu.Lock()
+		t.gid = gid
+		s.await(t)
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if t.exited {
+				// Killed: Kill already accounted for the task.
+				return
+			}
+			t.exited = true
+			if g != nil {
+				delete(g.tasks, id)
+			}
+			s.record(event{task: id, kind: trace.Exit})
+			s.retire(t)
+			s.waitGroup.Done()
+		}()
+		pprof.Do(context.Background(), s.labels(id), func(context.Context) {
+			if onPanic != nil {
+				defer func() {
+					if r := recover(); r != nil {
+						onPanic(id, r)
+					}
+				}()
+			}
+			fn((<-chan struct{})(t.done))
+		})
+	}()
+}
+
+// retire marks t, which exited or was killed, done, and passes the turn
+// if it is free, as it is once t held it; a killed task holding the turn
+// keeps it until its goroutine finishes. The caller must hold s.mu.
+func (s *Scheduler) retire(t *running) {
+	s.tasks.setState(t.task, TaskDone)
+	// Wake t if it waits for a turn, to unwind.
+	select {
+	case t.token <- struct{}{}:
+	default:
+	}
+	if s.holder == t.id {
+		if t.killed() {
+			return
+		}
+		s.holder = 0
+	}
+	if s.holder == 0 {
+		s.pass()
+	}
+	if s.settled() {
+		// Let the watchdog exit, and Wait return.
+		s.poke()
+		s.wake.Broadcast()
+	}
+}
+
+// release returns t to runningPool once its goroutine has finished with
+// it; by then neither its group nor byGoroutine refers to it.
+func (t *running) release() {
+	s := t.s
+	s.mu.Lock()
+	if s.holder == t.id {
+		s.holder = 0
+		s.pass()
+	}
+	s.goroutines--
+	if s.goroutines == 0 {
+		hooks.Add(-1)
+		s.dropDrivers()
+	}
+	// A killed task unwinding may have been all that kept the rest from
+	// deadlock.
+	s.poke()
+	delete(s.live, t.id)
+	*t = running{children: t.children[:0]}
+	s.mu.Unlock()
+	runningPool.Put(t)
+}
+
+// Checkpoint ends the calling task if its group has been killed, passes
+// the turn and waits for its next, counts the point
+// against its scheduler's bound, if any, and injects chaos into it if its
+// scheduler has chaos. Scheduling points call it before they might block.
+func Checkpoint() {
+	if hooks.Load() == 0 {
+		return
+	}
+	v, ok := byGoroutine.Load(goid())
+	if !ok {
+		return
+	}
+	t := v.(*running)
+	if !t.killed() {
+		t.s.checkpoint(t)
+		if !t.killed() {
+			return
+		}
+	}
+	t.exit()
+}
+
+// killed reports whether t's group has been killed.
+func (t *running) killed() bool {
+	return t.group != nil && t.group.killed.Load()
+}
+
+// Bind makes s the scheduler Current returns on the calling goroutine, as
+// it does in the tasks of s, until unbind is called.
+func (s *Scheduler) Bind() (unbind func()) {
+	gid := goid()
+	bindings.Store(gid, s)
+	return func() { bindings.Delete(gid) }
+}
+
+// Current returns the scheduler of the calling goroutine: that of the task
+// it runs, or the one it was bound to, or nil if it has neither.
+func Current() *Scheduler {
+	gid := goid()
+	if v, ok := byGoroutine.Load(gid); ok {
+		return v.(*running).s
+	}
+	if v, ok := bindings.Load(gid); ok {
+		return v.(*Scheduler)
+	}
+	return nil
+}
+
+// Driving returns Current, or else a scheduler the calling goroutine
+// drives, having spawned its tasks without being bound to it, or nil.
+func Driving() *Scheduler {
+	if s := Current(); s != nil {
+		return s
+	}
+	if d := driven(goid()); len(d) > 0 {
+		return d[0]
+	}
+	return nil
+}
+
+// caller returns the calling task, or nil if the caller is not a task of s.
+func (s *Scheduler) caller() *running {
+	if v, ok := byGoroutine.Load(goid()); ok && v.(*running).s == s {
+		return v.(*running)
+	}
+	return nil
+}
+
+// TaskID returns the ID of the calling task, or 0 if the caller is not a
+// task of s.
+func (s *Scheduler) TaskID() int {
+	if t := s.caller(); t != nil {
+		return t.id
+	}
+	return 0
+}
+
+// SetPriority sets the priority of the calling task, which the tasks it
+// spawns from then on inherit, and records it. It does nothing if the caller
+// is not a task of s.
+func (s *Scheduler) SetPriority(p int) {
+	t := s.caller()
+	if t == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.priority = p
+	s.record(event{task: t.id, kind: trace.Priority, object: strconv.Itoa(p), stack: callerStack()})
+}
+
+// Priority returns the priority of task id, or 0 if it is not running.
+func (s *Scheduler) Priority(id int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t := s.live[id]; t != nil {
+		return t.priority
+	}
+	return 0
+}
+
+// recordOn records an event of kind on object for the calling task, or
+// for task 0 if it is not a task of s.
+func (s *Scheduler) recordOn(kind trace.Kind, object string) {
+	id := 0
+	if t := s.caller(); t != nil {
+		id = t.id
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(event{task: id, kind: kind, object: object, stack: callerStack()})
+}
+
+// goid returns the ID of the calling goroutine.
+func goid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -1,54 +1,458 @@
 package scheduler
 
 import (
+	"errors"
 	"math/rand"
+	"runtime/pprof"
+	"strconv"
 	"sync"
-	"time"
+
+	"github.com/mziter/weft/trace"
 )
 
 // Scheduler manages deterministic task execution.
 type Scheduler struct {
-	mu       sync.Mutex
-	rng      *rand.Rand
-	tasks    []*Task
-	runnable []int
-	current  int
+	mu        sync.Mutex
+	rng       *rand.Rand
+	tasks     taskTable
+	current   int
 	waitGroup sync.WaitGroup
+
+	// replay holds recorded decisions still to be replayed, and choices
+	// every decision made so far.
+	replay  []int
+	choices []int
+
+	// nextID is the ID of the last task spawned; task 0 is the caller.
+	// goroutines counts the goroutines of tasks that have not finished,
+	// killed tasks still unwinding included.
+	nextID     int
+	goroutines int
+	events     eventLog
+
+	// live holds the tasks whose goroutines have not finished, by ID.
+	live map[int]*running
+
+	// seed labels the goroutines running tasks in profiles.
+	seed uint64
+
+	// decider, if set, makes task decisions in place of the seed.
+	decider Decider
+
+	// strictPriority limits each task decision to the runnable tasks of
+	// highest priority.
+	strictPriority bool
+
+	// chaos, if set, injects faults at scheduling points.
+	chaos *Chaos
+
+	// onPanic, if set, is passed the ID of a task that panics and the
+	// value it panicked with, in place of the panic ending the process.
+	onPanic func(task int, v any)
+
+	// maxPoints, if positive, bounds the scheduling points the tasks pass,
+	// counted in points; the task passing one more panics with
+	// pointsPanic.
+	maxPoints, points int
+	pointsPanic       any
+
+	// observer, if set, is called with each event as it is recorded, and
+	// noEvents keeps events from being held.
+	observer func(trace.Event)
+	noEvents bool
+
+	// synctest is set when the scheduler runs inside a testing/synctest
+	// bubble, whose fake clock times Sleep and After at full duration.
+	synctest bool
+
+	// err is the first failure of the run, reported by Err.
+	err error
+
+	// holder is the task holding the turn, or 0, and last the task given
+	// the turn before; turns counts the turns given. stepping is set
+	// while RunUntil or Step give them; wake is broadcast for them when
+	// the turn is free. watching is set while the watchdog runs, and kick
+	// wakes it. readyIDs is the buffer of the ready tasks' IDs at each
+	// decision.
+	holder, last, turns int
+	stepping            bool
+	wake                sync.Cond
+	watching            bool
+	kick                chan struct{}
+	readyIDs            []int
+
+	// drivers are the goroutines, other than tasks of s, that spawned
+	// tasks of s, and waiting counts the goroutines in Wait and the
+	// drivers blocked on weft primitives, while which turns are given;
+	// waits counts those in Wait alone. deadlocked is set once the clock
+	// finds the tasks deadlocked, which ends the Waits.
+	drivers    []uint64
+	waiting    int
+	waits      int
+	deadlocked bool
+
+	// afters are the calls of AfterDone still waiting, and aside counts
+	// the calls of Aside not yet done.
+	afters []*after
+	aside  int
+
+	// stream, if set, receives every event as it is recorded, and events
+	// keeps only the most recent; steps counts the events recorded.
+	stream *trace.Writer
+	steps  int
+
+	// clock keeps the virtual time of s, shared with the other members of
+	// its harness; frozen is set while it fires timers, holding the turns
+	// back until every timer due has fired.
+	clock  *clock
+	frozen bool
 }
 
 // New creates a new scheduler with the given seed.
 func New(seed uint64) *Scheduler {
-	return &Scheduler{
-		rng: rand.New(rand.NewSource(int64(seed))),
+	s := &Scheduler{
+		rng:  rand.New(rand.NewSource(int64(seed))),
+		seed: seed,
+		kick: make(chan struct{}, 1),
+	}
+	s.wake.L = &s.mu
+	s.clock = newClock(s)
+	return s
+}
+
+// NewMember creates a scheduler with the given seed that keeps the virtual
+// time of clock, so that the sleeps and timers of both advance together.
+func NewMember(clock *Scheduler, seed uint64) *Scheduler {
+	s := New(seed)
+	s.clock = clock.clock
+	s.clock.join(s)
+	return s
+}
+
+// Reset readies s for another run, in the state New(seed) creates a
+// scheduler in, or NewReplay(seed, choices) if choices is not empty, keeping
+// the buffers it has grown. It returns s, or a new scheduler keeping the
+// same clock if goroutines of s's tasks, such as killed tasks blocked
+// forever, are still running and could disturb the next run. The clock is
+// set back to the start of virtual time unless s keeps another
+// scheduler's. The caller must not run tasks of s meanwhile.
+func (s *Scheduler) Reset(seed uint64, choices []int) *Scheduler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.goroutines > 0 {
+		s.resume()
+		s.dropDrivers()
+		r := NewReplay(seed, choices)
+		r.clock = s.clock
+		r.clock.replace(s, r)
+		r.clock.reset(r)
+		return r
+	}
+	s.clock.reset(s)
+	s.rng.Seed(int64(seed))
+	s.seed = seed
+	s.replay = append(s.replay[:0], choices...)
+	s.choices = s.choices[:0]
+	s.nextID, s.steps = 0, 0
+	s.tasks.reset()
+	s.events.reset()
+	s.decider, s.chaos, s.stream, s.synctest = nil, nil, nil, false
+	s.strictPriority = false
+	s.onPanic, s.observer, s.noEvents = nil, nil, false
+	s.maxPoints, s.points, s.pointsPanic = 0, 0, nil
+	s.err = nil
+	s.last, s.turns = 0, 0
+	s.afters, s.aside = nil, 0
+	s.resume()
+	return s
+}
+
+// NewReplay creates a scheduler that replays the recorded choices and then
+// continues with decisions drawn from seed.
+func NewReplay(seed uint64, choices []int) *Scheduler {
+	s := New(seed)
+	s.replay = append([]int(nil), choices...)
+	return s
+}
+
+// Choices returns the scheduling decisions made so far.
+func (s *Scheduler) Choices() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int{}, s.choices...)
+}
+
+// Seed returns the seed s was created or last reset with.
+func (s *Scheduler) Seed() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seed
+}
+
+// Steps returns the number of events recorded so far, which is the step
+// of the next.
+func (s *Scheduler) Steps() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.steps
+}
+
+// choose picks one of n options. A recorded choice is replayed if one
+// remains and is in range; otherwise the choice is drawn from the seed.
+// The caller must hold s.mu.
+func (s *Scheduler) choose(n int) int {
+	var c int
+	if len(s.replay) > 0 && s.replay[0] < n {
+		c = s.replay[0]
+	} else {
+		c = s.rng.Intn(n)
+	}
+	if len(s.replay) > 0 {
+		s.replay = s.replay[1:]
 	}
+	s.choices = append(s.choices, c)
+	return c
 }
 
-// Spawn creates a new task.
+// Choose makes a decision among n options for the code under test, such as
+// whether a simulated fault happens, recorded and replayed like a
+// scheduling decision.
+func (s *Scheduler) Choose(n int) int {
+	Checkpoint()
+	if n <= 0 {
+		panic("weft: Choose with no options")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n == 1 {
+		return 0
+	}
+	return s.choose(n)
+}
+
+// SetPanicHandler makes a panic in a task spawned from now on end only the
+// task, and passes its ID and the value it panicked with to handle, which
+// may be called from any task's goroutine.
+func (s *Scheduler) SetPanicHandler(handle func(task int, v any)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onPanic = handle
+}
+
+// Spawn creates a new task, a child of the calling task if it is one. fn
+// is passed a <-chan struct{} closed when the task's context is canceled,
+// as it is when its parent's is.
 func (s *Scheduler) Spawn(fn func(interface{})) {
+	Checkpoint()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spawn(nil, false, fn)
+}
+
+// SpawnDetached is like Spawn, but the task is not canceled with the task
+// that spawned it.
+func (s *Scheduler) SpawnDetached(fn func(interface{})) {
+	Checkpoint()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spawn(nil, true, fn)
+}
+
+// Profile label keys set on the goroutine running each task, so that CPU,
+// heap and goroutine profiles taken during exploration attribute cost to
+// tasks. Filter on them with, for example, go tool pprof -tagfocus.
+const (
+	LabelTask = "weft_task"
+	LabelSeed = "weft_seed"
+)
+
+// labels returns the profile labels for task id.
+func (s *Scheduler) labels(id int) pprof.LabelSet {
+	return pprof.Labels(LabelTask, "task "+strconv.Itoa(id), LabelSeed, strconv.FormatUint(s.seed, 10))
+}
+
+// Events returns the events recorded so far, or only the most recent ones
+// if they are being streamed.
+func (s *Scheduler) Events() []trace.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events.traceEvents()
+}
+
+// Stream makes the scheduler write each event to w as it is recorded
+// instead of keeping them all, so that memory stays bounded however long
+// the run. Errors writing the stream are returned by w.Close.
+func (s *Scheduler) Stream(w *trace.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stream = w
+	s.events.setLimit(streamTail)
+}
+
+// SetEventMemory bounds the memory the events of s take to about bytes,
+// moving older events to a temporary file when it is exceeded, so that long
+// runs keep their whole trace without holding it in memory. Events reads
+// them back. A bytes of 0 or less lifts the bound. Streaming, which keeps
+// only recent events, makes the bound moot.
+func (s *Scheduler) SetEventMemory(bytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events.setBudget(bytes)
+}
+
+// streamTail is the number of recent events kept while streaming, for
+// interactive sessions and failure reports.
+const streamTail = 4096
+
+// record appends ev to the trace, numbering its step. The caller must hold
+// s.mu.
+func (s *Scheduler) record(ev event) {
+	ev.step = s.steps
+	s.steps++
+	if ev.task != 0 {
+		s.note(ev)
+	}
+	if s.stream != nil {
+		s.stream.Event(ev.traceEvent())
+	}
+	if s.observer != nil {
+		s.observer(ev.traceEvent())
+	}
+	if !s.noEvents {
+		s.events.add(ev)
+	}
+}
+
+// SetObserver makes s call observe with each event as it is recorded.
+// observe runs with s locked, so it must not call s.
+func (s *Scheduler) SetObserver(observe func(trace.Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observer = observe
+}
+
+// SetRecording turns the holding of events for Events on or off; streams
+// and observers still receive them.
+func (s *Scheduler) SetRecording(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noEvents = !on
+}
+
+// SetMaxPoints makes the task that passes more than max scheduling points
+// in all, counted across the tasks of s, panic with v, ending a run that
+// livelocks rather than letting it spin forever. If v is an error, it is
+// also reported by Err.
+func (s *Scheduler) SetMaxPoints(max int, v any) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
-	// TODO: Implement task spawning
-	s.waitGroup.Add(1)
-	go func() {
-		defer s.waitGroup.Done()
-		fn(nil)
-	}()
+	if s.maxPoints == 0 && max > 0 {
+		hooks.Add(1)
+	}
+	s.maxPoints, s.pointsPanic = max, v
 }
 
-// Wait waits for all tasks to complete.
+// checkpoint counts a scheduling point of t against s's bound, then
+// injects chaos into t if s has any.
+func (s *Scheduler) checkpoint(t *running) {
+	s.mu.Lock()
+	s.gate(t)
+	if s.maxPoints > 0 && !t.exited {
+		s.points++
+		if s.points > s.maxPoints {
+			v := s.pointsPanic
+			if err, ok := v.(error); ok {
+				s.fail(err)
+			}
+			s.mu.Unlock()
+			panic(v)
+		}
+	}
+	s.mu.Unlock()
+	s.injectChaos(t)
+}
+
+// ErrDeadlock reports a run whose unfinished tasks were all blocked on
+// weft primitives, waiting on one another, with no timer left to wake
+// them.
+var ErrDeadlock = errors.New("weft: deadlock")
+
+// Wait ends stepping, if RunUntil or Step began it, gives the tasks turns
+// and waits for all tasks to complete, or for them to deadlock, when it
+// fails the run with ErrDeadlock.
 func (s *Scheduler) Wait() {
-	s.waitGroup.Wait()
+	WaitAll(s)
 }
 
-// Sleep pauses the current task.
-func (s *Scheduler) Sleep(d time.Duration) {
-	// TODO: Implement virtual time sleep
-	time.Sleep(d / 1000) // Speed up for testing
+// WaitAll is Wait for the tasks of schedulers, which take turns together
+// until they have all completed or deadlocked, as the tasks of the members
+// of a harness must when they wait on each other.
+func WaitAll(schedulers ...*Scheduler) {
+	for _, s := range schedulers {
+		s.mu.Lock()
+		s.resume()
+		s.waiting++
+		s.waits++
+		s.deadlocked = false
+		if s.holder == 0 {
+			s.pass()
+		}
+		s.mu.Unlock()
+	}
+	for _, s := range schedulers {
+		s.mu.Lock()
+		for !s.deadlocked && !s.settled() {
+			s.wake.Wait()
+		}
+		deadlocked := s.deadlocked
+		s.mu.Unlock()
+		if !deadlocked {
+			s.waitGroup.Wait()
+		}
+	}
+	for _, s := range schedulers {
+		s.mu.Lock()
+		s.waiting--
+		s.waits--
+		s.mu.Unlock()
+	}
 }
 
-// After returns a timer channel.
-func (s *Scheduler) After(d time.Duration) <-chan time.Time {
-	// TODO: Implement virtual time after
-	return time.After(d / 1000) // Speed up for testing
-}
\ No newline at end of file
+// Fail records err as a failure of the run, reported by Err unless an
+// earlier failure was.
+func (s *Scheduler) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail(err)
+}
+
+// fail is Fail with s.mu held.
+func (s *Scheduler) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Err returns the first failure of the run, or nil if there was none.
+func (s *Scheduler) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// SetSynctest marks the scheduler as running inside a testing/synctest
+// bubble, or not. Inside one, the tasks are given turns as soon as they
+// are spawned, since the test waits for them in synctest.Wait, which the
+// scheduler cannot see, and the bubble's fake clock stands in for the
+// virtual one of s and of the members keeping its time.
+func (s *Scheduler) SetSynctest(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.synctest = on
+	c := s.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schedulers[0] == s {
+		c.synctest = on
+	}
+}
@@ -0,0 +1,389 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestSpawnLabels verifies that the goroutine running a task carries its
+// task and seed as profile labels.
+func TestSpawnLabels(t *testing.T) {
+	s := New(42)
+	running, release := MakeChan[struct{}](0), make(chan struct{})
+	s.Spawn(func(interface{}) {
+		running.Close()
+		<-release
+	})
+	running.Recv()
+	var buf bytes.Buffer
+	err := pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	close(release)
+	s.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `labels: {"` + LabelSeed + `":"42", "` + LabelTask + `":"task 1"}`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("goroutine profile does not contain %s:\n%s", want, buf.String())
+	}
+}
+
+// TestBind verifies that Current finds the scheduler a goroutine is bound
+// to, in it and in the tasks spawned while it is bound, and nothing once it
+// is unbound.
+func TestBind(t *testing.T) {
+	s := New(1)
+	if got := Current(); got != nil {
+		t.Fatalf("Current() before Bind = %p, want nil", got)
+	}
+	unbind := s.Bind()
+	if got := Current(); got != s {
+		t.Errorf("Current() after Bind = %p, want %p", got, s)
+	}
+	var inTask, inNested *Scheduler
+	s.Spawn(func(interface{}) {
+		inTask = Current()
+		s.Spawn(func(interface{}) { inNested = Current() })
+	})
+	s.Wait()
+	unbind()
+	if inTask != s || inNested != s {
+		t.Errorf("Current() in tasks = %p, %p, want %p", inTask, inNested, s)
+	}
+	if got := Current(); got != nil {
+		t.Errorf("Current() after unbind = %p, want nil", got)
+	}
+}
+
+// TestSpawnChildren verifies that a task's spawn is recorded as its
+// parent's, and that canceling a task cancels its children and theirs, but
+// not those spawned detached.
+func TestSpawnChildren(t *testing.T) {
+	s := New(1)
+	g := NewGroup("node")
+	b := NewBlocker("never")
+	var detached, child, grandchild <-chan struct{}
+	s.SpawnIn(g, func(done interface{}) {
+		s.SpawnDetached(func(done interface{}) { detached = done.(<-chan struct{}) })
+		s.Spawn(func(done interface{}) {
+			child = done.(<-chan struct{})
+			s.Spawn(func(done interface{}) {
+				grandchild = done.(<-chan struct{})
+				b.Waiter().Park()
+			})
+			b.Waiter().Park()
+		})
+		b.Waiter().Park()
+	})
+	s.RunUntil(func() bool { return false })
+	s.Kill(g)
+	for _, done := range []<-chan struct{}{child, grandchild} {
+		select {
+		case <-done:
+		default:
+			t.Error("descendant not canceled with its parent")
+		}
+	}
+	select {
+	case <-detached:
+		t.Error("detached task canceled with its parent")
+	default:
+	}
+	b.UnparkAll()
+	s.Wait()
+
+	parents := make(map[int]int)
+	for _, ev := range s.Events() {
+		if ev.Kind == trace.Spawn {
+			parents[ev.Peer] = ev.Task
+		}
+	}
+	if want := map[int]int{1: 0, 2: 1, 3: 1, 4: 3}; !maps.Equal(parents, want) {
+		t.Errorf("parents of tasks %v, want %v", parents, want)
+	}
+}
+
+// TestSpawnReuse verifies that a task's record, reused by later tasks once
+// it exits, does not tie their contexts together.
+func TestSpawnReuse(t *testing.T) {
+	var first <-chan struct{}
+	s := New(1)
+	s.Spawn(func(done interface{}) { first = done.(<-chan struct{}) })
+	s.Wait()
+
+	for i := 0; i < 100; i++ {
+		s := New(uint64(i))
+		g := NewGroup("node")
+		s.SpawnIn(g, func(interface{}) { NewBlocker("never").Waiter().Park() })
+		s.RunUntil(func() bool { return false })
+		s.Kill(g)
+	}
+	select {
+	case <-first:
+		t.Error("killing a later task canceled an exited task's context")
+	default:
+	}
+}
+
+// TestSpawnMany verifies that a group of tens of thousands of tasks runs
+// and is killed.
+func TestSpawnMany(t *testing.T) {
+	const n = 20000
+	s := New(1)
+	g := NewGroup("workers")
+	b := NewBlocker("never")
+	for i := 0; i < n; i++ {
+		s.SpawnIn(g, func(interface{}) { b.Waiter().Park() })
+	}
+	s.RunUntil(func() bool { return false })
+	s.Kill(g)
+	s.Wait()
+	kinds := make(map[trace.Kind]int)
+	for _, ev := range s.Events() {
+		kinds[ev.Kind]++
+	}
+	for _, kind := range []trace.Kind{trace.Spawn, trace.Block, trace.Kill} {
+		if kinds[kind] != n {
+			t.Errorf("%d %v events, want one for each of %d tasks", kinds[kind], kind, n)
+		}
+	}
+	// Let the killed tasks unwind, lest the goroutine dumps of later tests
+	// walk them all.
+	for {
+		s.mu.Lock()
+		left := s.goroutines
+		s.mu.Unlock()
+		if left == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func BenchmarkSpawn(b *testing.B) {
+	b.ReportAllocs()
+	s := New(1)
+	for i := 0; i < b.N; i++ {
+		s.Spawn(func(interface{}) {})
+		s.Wait()
+	}
+}
+
+// TestRecordAllocs verifies that recording an event from a call site seen
+// before allocates nothing once the log has room.
+func TestRecordAllocs(t *testing.T) {
+	s := New(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	allocs := testing.AllocsPerRun(100, func() {
+		s.record(event{task: 1, kind: trace.Exit, object: "mutex 1", stack: callerStack()})
+	})
+	if allocs != 0 {
+		t.Errorf("record allocates %v times per event, want 0", allocs)
+	}
+}
+
+// TestRecordRing verifies that a streaming scheduler keeps only its most
+// recent events, in order.
+func TestRecordRing(t *testing.T) {
+	s := New(1)
+	s.Stream(trace.NewWriter(io.Discard, &trace.Trace{}))
+	s.mu.Lock()
+	for i := 0; i < streamTail+10; i++ {
+		s.record(event{task: i})
+	}
+	s.mu.Unlock()
+	events := s.Events()
+	if len(events) != streamTail {
+		t.Fatalf("len(Events()) = %d, want %d", len(events), streamTail)
+	}
+	for i, ev := range events {
+		if want := i + 10; ev.Step != want || ev.Task != want {
+			t.Fatalf("Events()[%d] = step %d, task %d; want %d", i, ev.Step, ev.Task, want)
+		}
+	}
+}
+
+// TestEventMemory verifies that a scheduler over its event memory keeps
+// only recent events in memory, and still returns every event, in order.
+func TestEventMemory(t *testing.T) {
+	const n = 1000
+	s := New(1)
+	s.SetEventMemory(100 * event{}.size())
+	s.mu.Lock()
+	for i := 0; i < n; i++ {
+		s.record(event{task: i, kind: trace.Send, object: "chan 1"})
+	}
+	if len(s.events.buf) > 100 || s.events.spill == nil {
+		t.Errorf("%d events in memory, spilled %v; want at most 100 and a spill file", len(s.events.buf), s.events.spill != nil)
+	}
+	s.mu.Unlock()
+	events := s.Events()
+	if len(events) != n {
+		t.Fatalf("len(Events()) = %d, want %d", len(events), n)
+	}
+	for i, ev := range events {
+		if ev.Step != i || ev.Task != i || ev.Kind != trace.Send || ev.Object != "chan 1" {
+			t.Fatalf("Events()[%d] = %+v, want step and task %d", i, ev, i)
+		}
+	}
+	s.Reset(1, nil)
+	if s.events.spill != nil || len(s.Events()) != 0 {
+		t.Error("Reset kept spilled events")
+	}
+}
+
+// TestReset verifies that a reset scheduler decides and records as a new
+// one with the same seed does, and that one whose killed tasks are still
+// running is replaced instead.
+func TestReset(t *testing.T) {
+	run := func(s *Scheduler) ([]int, int) {
+		s.Spawn(func(interface{}) { s.Choose(10) })
+		s.Wait()
+		s.Choose(100)
+		return s.Choices(), len(s.Events())
+	}
+	wantChoices, wantEvents := run(New(7))
+
+	s := New(1)
+	run(s)
+	if r := s.Reset(7, nil); r != s {
+		t.Fatal("Reset replaced a scheduler with no tasks running")
+	}
+	if choices, events := run(s); !slices.Equal(choices, wantChoices) || events != wantEvents {
+		t.Errorf("after Reset: choices %v and %d events, want %v and %d", choices, events, wantChoices, wantEvents)
+	}
+	if choices, _ := run(s.Reset(3, wantChoices)); !slices.Equal(choices, wantChoices) {
+		t.Errorf("after Reset with choices: choices %v, want %v", choices, wantChoices)
+	}
+
+	g := NewGroup("node")
+	blocked := make(chan struct{})
+	defer close(blocked)
+	s.SpawnIn(g, func(interface{}) { <-blocked })
+	s.Kill(g)
+	s.Wait()
+	if s.Reset(7, nil) == s {
+		t.Error("Reset reused a scheduler whose killed task is still running")
+	}
+}
+
+// decisionRecorder is a Decider that keeps the decisions put to it and
+// leaves them to the seed.
+type decisionRecorder []Decision
+
+func (r *decisionRecorder) Decide(d Decision) (int, bool) {
+	*r = append(*r, d)
+	return 0, false
+}
+
+// TestPriority verifies that tasks inherit the priority of the task that
+// spawned them, that deciders see it, and that strict priority limits task
+// decisions to the runnable tasks of highest priority.
+func TestPriority(t *testing.T) {
+	s := New(1)
+	release := NewBlocker("release")
+	s.Spawn(func(interface{}) {
+		s.SetPriority(2)
+		s.Spawn(func(interface{}) { release.Waiter().Park() })
+		s.Spawn(func(interface{}) {
+			s.SetPriority(1)
+			release.Waiter().Park()
+		})
+		release.Waiter().Park()
+	})
+	s.RunUntil(func() bool { return false })
+	for id, want := range []int{0, 2, 2, 1} {
+		if p := s.Priority(id); p != want {
+			t.Errorf("Priority(%d) = %d, want %d", id, p, want)
+		}
+	}
+
+	var decisions decisionRecorder
+	s.SetDecider(&decisions)
+	s.mu.Lock()
+	s.chooseTask([]int{3, 1})
+	s.mu.Unlock()
+	if len(decisions) != 1 || !slices.Equal(decisions[0].Priorities, []int{1, 2}) {
+		t.Errorf("decisions = %+v, want one with priorities [1 2]", decisions)
+	}
+
+	s.SetStrictPriority(true)
+	seen := make(map[int]bool)
+	for range 50 {
+		s.mu.Lock()
+		seen[s.chooseTask([]int{3, 2, 1})] = true
+		s.mu.Unlock()
+	}
+	if want := map[int]bool{1: true, 2: true}; !maps.Equal(seen, want) {
+		t.Errorf("strict priority chose indexes %v, want 1 and 2", seen)
+	}
+	release.UnparkAll()
+	s.Wait()
+
+	var set []string
+	for _, ev := range s.Events() {
+		if ev.Kind == trace.Priority {
+			set = append(set, ev.String())
+		}
+	}
+	if want := []string{"task 1 priority 2", "task 3 priority 1"}; !slices.Equal(set, want) {
+		t.Errorf("priority events = %q, want %q", set, want)
+	}
+}
+
+// TestErr verifies that Err reports the first failure of a run, a bound on
+// scheduling points passed included, until Reset.
+func TestErr(t *testing.T) {
+	errLimit, errOther := errors.New("limit"), errors.New("other")
+	s := New(1)
+	s.SetMaxPoints(1, errLimit)
+	s.Spawn(func(interface{}) {
+		defer func() { recover() }()
+		for {
+			Checkpoint()
+		}
+	})
+	s.Wait()
+	s.Fail(errOther)
+	if err := s.Err(); err != errLimit {
+		t.Errorf("Err() = %v, want %v", err, errLimit)
+	}
+	if err := s.Reset(1, nil).Err(); err != nil {
+		t.Errorf("Err() after Reset = %v, want nil", err)
+	}
+}
+
+// TestWaitDeadlock verifies that Wait returns once every task is blocked
+// with no timer left to wake one, failing the run with ErrDeadlock, but
+// not while a sleep could still wake them.
+func TestWaitDeadlock(t *testing.T) {
+	s := New(1)
+	b := NewBlocker("never")
+	woken := false
+	for range 2 {
+		s.Spawn(func(interface{}) { b.Waiter().Park() })
+	}
+	s.Spawn(func(interface{}) {
+		s.Sleep(time.Second)
+		woken = b.Unpark()
+	})
+	s.Wait()
+	if !woken {
+		t.Error("Wait found a deadlock while a task slept")
+	}
+	if err := s.Err(); !errors.Is(err, ErrDeadlock) || !strings.Contains(err.Error(), "1 tasks blocked") {
+		t.Errorf("Err() = %v, want ErrDeadlock for 1 task", err)
+	}
+	b.UnparkAll()
+	s.Wait()
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import "slices"
+
+// Case is a single select case over a scheduler channel. Apart from
+// nilChan, collect, done and clock, its methods are called with chanMu
+// held.
+type Case interface {
+	// nilChan reports whether the case is on a nil channel, never
+	// proceeding.
+	nilChan() bool
+	// ready reports whether the case can proceed at once.
+	ready() bool
+	// proceed performs the case, which is ready, and returns the waiter of
+	// the parked operation it paired with, if any, to wake.
+	proceed() *Waiter
+	// enqueue parks the case on its channel as case i of p, and dequeue
+	// takes it out of the channel's queue.
+	enqueue(p *parking, i int)
+	dequeue(p *parking)
+	// collect completes the case once it has fired while parked.
+	collect()
+	// done records the case having proceeded, once chanMu is released.
+	done()
+	// clock returns the clock of the timer sending on the case's channel,
+	// if the case receives from one.
+	clock() *clock
+}
+
+type recvCase[T any] struct {
+	c  *Chan[T]
+	v  *T
+	ok *bool
+	op chanOp[T]
+}
+
+// RecvCase returns a case receiving from c into v and ok.
+func RecvCase[T any](c *Chan[T], v *T, ok *bool) Case {
+	return &recvCase[T]{c: c, v: v, ok: ok}
+}
+
+func (rc *recvCase[T]) nilChan() bool { return rc.c == nil }
+
+func (rc *recvCase[T]) ready() bool { return rc.c.canRecv() }
+
+func (rc *recvCase[T]) proceed() *Waiter {
+	v, ok, w := rc.c.recv()
+	*rc.v, *rc.ok = v, ok
+	return w
+}
+
+func (rc *recvCase[T]) enqueue(p *parking, i int) {
+	rc.op = chanOp[T]{p: p, i: i}
+	rc.c.recvq = append(rc.c.recvq, &rc.op)
+}
+
+func (rc *recvCase[T]) dequeue(p *parking) { remove(&rc.c.recvq, p) }
+
+func (rc *recvCase[T]) collect() {
+	*rc.v, *rc.ok = rc.op.v, rc.op.ok
+}
+
+func (rc *recvCase[T]) done() {
+	if *rc.ok {
+		rc.c.link.received()
+	}
+}
+
+func (rc *recvCase[T]) clock() *clock {
+	if rc.c == nil {
+		return nil
+	}
+	return rc.c.clock
+}
+
+type sendCase[T any] struct {
+	c  *Chan[T]
+	v  T
+	op chanOp[T]
+}
+
+// SendCase returns a case sending v on c.
+func SendCase[T any](c *Chan[T], v T) Case {
+	return &sendCase[T]{c: c, v: v}
+}
+
+func (sc *sendCase[T]) nilChan() bool { return sc.c == nil }
+
+func (sc *sendCase[T]) ready() bool { return sc.c.canSend() }
+
+func (sc *sendCase[T]) proceed() *Waiter { return sc.c.send(sc.v) }
+
+func (sc *sendCase[T]) enqueue(p *parking, i int) {
+	sc.op = chanOp[T]{p: p, i: i, v: sc.v}
+	sc.c.sendq = append(sc.c.sendq, &sc.op)
+}
+
+func (sc *sendCase[T]) dequeue(p *parking) { remove(&sc.c.sendq, p) }
+
+func (sc *sendCase[T]) collect() {
+	if sc.op.closed {
+		panic("send on closed channel")
+	}
+}
+
+func (sc *sendCase[T]) done() {
+	sc.c.link.sent()
+}
+
+func (sc *sendCase[T]) clock() *clock { return nil }
+
+// Select performs one of the cases and returns its index. When block is
+// false and no case is ready, Select returns -1. Cases on nil channels never
+// proceed, so Select blocks forever if every case is on one. When several
+// cases are ready, the schedule chooses which one proceeds.
+func Select(cases []Case, block bool) int {
+	Checkpoint()
+	if block && !slices.ContainsFunc(cases, func(c Case) bool { return !c.nilChan() }) {
+		blockNil()
+	}
+	return perform(cases, block, selectBlocker)
+}
+
+// Op is a select case over a channel of T, for Select2, Select3 and
+// Select4, which take their cases typed.
+type Op[T any] struct {
+	c    *Chan[T]
+	send bool
+	v    T
+	out  *T
+	ok   *bool
+}
+
+// RecvOp returns an op receiving from c into v and ok.
+func RecvOp[T any](c *Chan[T], v *T, ok *bool) Op[T] {
+	return Op[T]{c: c, out: v, ok: ok}
+}
+
+// SendOp returns an op sending v on c.
+func SendOp[T any](c *Chan[T], v T) Op[T] {
+	return Op[T]{c: c, send: true, v: v}
+}
+
+// selectCase returns the case o performs.
+func (o Op[T]) selectCase() Case {
+	if o.send {
+		return SendCase(o.c, o.v)
+	}
+	return RecvCase(o.c, o.out, o.ok)
+}
+
+// Select2 is Select over two ops, blocking.
+func Select2[A, B any](a Op[A], b Op[B]) int {
+	return Select([]Case{a.selectCase(), b.selectCase()}, true)
+}
+
+// Select3 is Select over three ops, blocking.
+func Select3[A, B, C any](a Op[A], b Op[B], c Op[C]) int {
+	return Select([]Case{a.selectCase(), b.selectCase(), c.selectCase()}, true)
+}
+
+// Select4 is Select over four ops, blocking.
+func Select4[A, B, C, D any](a Op[A], b Op[B], c Op[C], d Op[D]) int {
+	return Select([]Case{a.selectCase(), b.selectCase(), c.selectCase(), d.selectCase()}, true)
+}
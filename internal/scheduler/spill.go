@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"unsafe"
+
+	"github.com/mziter/weft/trace"
+)
+
+// spillFile holds the older events of an eventLog over its memory budget,
+// in a temporary file removed once the log is reset.
+type spillFile struct {
+	f *os.File
+	w *bufio.Writer
+
+	// name is the file's name while it has to be removed on close; where
+	// the system allows, it is removed as soon as it is created.
+	name string
+	n    int
+}
+
+// newSpillFile creates a spill file in the system's temporary directory.
+func newSpillFile() (*spillFile, error) {
+	f, err := os.CreateTemp("", "weft-events-*")
+	if err != nil {
+		return nil, err
+	}
+	sf := &spillFile{f: f, w: bufio.NewWriter(f), name: f.Name()}
+	if os.Remove(sf.name) == nil {
+		sf.name = ""
+	}
+	return sf, nil
+}
+
+// write appends events to the file.
+func (sf *spillFile) write(events []event) error {
+	var buf [binary.MaxVarintLen64]byte
+	putInt := func(v int) {
+		sf.w.Write(buf[:binary.PutVarint(buf[:], int64(v))])
+	}
+	putString := func(s string) {
+		putInt(len(s))
+		sf.w.WriteString(s)
+	}
+	for _, ev := range events {
+		putInt(ev.step)
+		putInt(ev.task)
+		putInt(ev.peer)
+		putString(string(ev.kind))
+		putString(ev.object)
+		putInt(int(ev.stack))
+	}
+	if err := sf.w.Flush(); err != nil {
+		return err
+	}
+	sf.n += len(events)
+	return nil
+}
+
+// read returns the events in the file, oldest first.
+func (sf *spillFile) read() ([]event, error) {
+	r := bufio.NewReader(io.NewSectionReader(sf.f, 0, 1<<62))
+	var err error
+	getInt := func() int {
+		if err != nil {
+			return 0
+		}
+		var v int64
+		v, err = binary.ReadVarint(r)
+		return int(v)
+	}
+	getString := func() string {
+		n := getInt()
+		if err != nil {
+			return ""
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return string(b)
+	}
+	events := make([]event, sf.n)
+	for i := range events {
+		ev := &events[i]
+		ev.step, ev.task, ev.peer = getInt(), getInt(), getInt()
+		ev.kind, ev.object = trace.Kind(getString()), getString()
+		ev.stack = stackID(getInt())
+	}
+	return events, err
+}
+
+// close closes the file and removes it if it still has a name.
+func (sf *spillFile) close() {
+	sf.f.Close()
+	if sf.name != "" {
+		os.Remove(sf.name)
+	}
+}
+
+// size estimates the memory ev takes in an eventLog.
+func (ev event) size() int {
+	return int(unsafe.Sizeof(ev)) + len(ev.object)
+}
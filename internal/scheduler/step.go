@@ -0,0 +1,87 @@
+package scheduler
+
+// Stepping gives the turns of the tasks of a scheduler from the caller of
+// RunUntil and Step, in place of the tasks passing it, so that the caller
+// can look at the state the tasks reached between turns.
+
+// startStepping makes the caller give the turns from now on.
+func (s *Scheduler) startStepping() {
+	if s.caller() != nil {
+		panic("weft: RunUntil or Step called from a task")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stepping = true
+}
+
+// Resume ends stepping, the tasks passing the turn among themselves again.
+// Wait resumes.
+func (s *Scheduler) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resume()
+}
+
+// resume is Resume with s.mu held.
+func (s *Scheduler) resume() {
+	if s.stepping {
+		s.stepping = false
+		if s.holder == 0 {
+			s.pass()
+		}
+	}
+}
+
+// settle waits until no task holds the turn. The caller must hold s.mu.
+func (s *Scheduler) settle() {
+	for s.holder != 0 {
+		s.wake.Wait()
+	}
+}
+
+// RunUntil gives turns to the tasks of s until pred holds once they have
+// settled, and reports whether it did; it returns false once no task is
+// left to run, all having exited or blocked with no sleep or timer left to
+// wake them as the clock advances. pred runs on the caller, with
+// no task running. Tasks stay waiting for a turn when RunUntil returns,
+// until the next RunUntil or Step, or until Resume or Wait.
+func (s *Scheduler) RunUntil(pred func() bool) bool {
+	s.startStepping()
+	for {
+		s.mu.Lock()
+		s.settle()
+		s.mu.Unlock()
+		if pred() {
+			return true
+		}
+		s.mu.Lock()
+		s.settle()
+		given := s.giveTurn()
+		s.mu.Unlock()
+		if !given && !s.clock.advance() {
+			return false
+		}
+	}
+}
+
+// Step gives n turns to tasks of s, as RunUntil does, and returns the
+// number given: fewer than n if no task was left to run.
+func (s *Scheduler) Step(n int) int {
+	s.startStepping()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.settle()
+		for !s.giveTurn() {
+			s.mu.Unlock()
+			advanced := s.clock.advance()
+			s.mu.Lock()
+			if !advanced {
+				return i
+			}
+			s.settle()
+		}
+	}
+	s.settle()
+	return n
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+)
+
+// stepCounters spawns three tasks on s that each increment a counter under
+// a mutex five times, and returns a function reading the counter.
+func stepCounters(s *Scheduler) func() int {
+	m := NewMutex()
+	count := 0
+	for range 3 {
+		s.Spawn(func(interface{}) {
+			for range 5 {
+				m.Lock()
+				count++
+				m.Unlock()
+			}
+		})
+	}
+	return func() int {
+		m.Lock()
+		defer m.Unlock()
+		return count
+	}
+}
+
+// TestRunUntil verifies that RunUntil stops the tasks as soon as its
+// condition holds, that Step then runs them to the end, and that the turns
+// follow the seed.
+func TestRunUntil(t *testing.T) {
+	run := func(seed uint64) []int {
+		s := New(seed)
+		count := stepCounters(s)
+		if !s.RunUntil(func() bool { return count() >= 7 }) {
+			t.Fatal("RunUntil returned false")
+		}
+		if c := count(); c != 7 {
+			t.Errorf("count after RunUntil = %d, want 7", c)
+		}
+		if n := s.Step(1000); n == 1000 {
+			t.Error("Step took every step with tasks left to run")
+		}
+		if c := count(); c != 15 {
+			t.Errorf("count after Step = %d, want 15", c)
+		}
+		if s.RunUntil(func() bool { return false }) {
+			t.Error("RunUntil returned true with no tasks left")
+		}
+		s.Wait()
+		return s.Choices()
+	}
+	first := run(7)
+	if len(first) == 0 {
+		t.Fatal("no turns were chosen")
+	}
+	if again := run(7); !slices.Equal(again, first) {
+		t.Errorf("choices with the same seed = %v, then %v", first, again)
+	}
+}
+
+// TestRunUntilBlocked verifies that RunUntil gives up once every task is
+// blocked, and that Wait lets the tasks run freely again.
+func TestRunUntilBlocked(t *testing.T) {
+	s := New(1)
+	c := MakeChan[int](0)
+	s.Spawn(func(interface{}) { c.Recv() })
+	if s.RunUntil(func() bool { return false }) {
+		t.Error("RunUntil returned true with its task blocked")
+	}
+	c.Send(1)
+	s.Wait()
+}
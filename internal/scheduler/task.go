@@ -6,6 +6,10 @@ type Task struct {
 	state    TaskState
 	blocked  bool
 	function func()
+
+	// index is the task's position in the set of its state in a
+	// taskTable.
+	index int
 }
 
 // TaskState represents the state of a task.
@@ -16,6 +20,8 @@ const (
 	TaskRunning
 	TaskBlocked
 	TaskDone
+
+	numTaskStates
 )
 
 // NewTask creates a new task.
@@ -32,4 +38,76 @@ func (t *Task) Run() {
 	t.state = TaskRunning
 	t.function()
 	t.state = TaskDone
-}
\ No newline at end of file
+}
+
+// taskTable holds the tasks of a run by ID, and by state in a set for each,
+// so that blocking and waking a task, and finding the runnable ones, take
+// constant time however many tasks there are. Task IDs are compact: the
+// tasks of a run are numbered from 1 as they are added.
+type taskTable struct {
+	byID   []*Task
+	states [numTaskStates]taskSet
+}
+
+// add adds t to the table in its state, numbering it.
+func (tt *taskTable) add(t *Task) {
+	tt.byID = append(tt.byID, t)
+	t.id = len(tt.byID)
+	tt.states[t.state].add(t)
+}
+
+// task returns the task with ID id, or nil if there is none.
+func (tt *taskTable) task(id int) *Task {
+	if id < 1 || id > len(tt.byID) {
+		return nil
+	}
+	return tt.byID[id-1]
+}
+
+// setState moves t to state.
+func (tt *taskTable) setState(t *Task, state TaskState) {
+	if t.state == state {
+		return
+	}
+	tt.states[t.state].remove(t)
+	t.state, t.blocked = state, state == TaskBlocked
+	tt.states[state].add(t)
+}
+
+// inState returns the tasks in state, in no particular order but the same
+// for the same sequence of changes. The slice is the table's own, valid
+// until its next change.
+func (tt *taskTable) inState(state TaskState) []*Task {
+	return tt.states[state].tasks
+}
+
+// reset empties the table, keeping its buffers.
+func (tt *taskTable) reset() {
+	clear(tt.byID)
+	tt.byID = tt.byID[:0]
+	for i := range tt.states {
+		clear(tt.states[i].tasks)
+		tt.states[i].tasks = tt.states[i].tasks[:0]
+	}
+}
+
+// taskSet is a set of tasks that adds and removes a task in constant time,
+// each task recording its position.
+type taskSet struct {
+	tasks []*Task
+}
+
+// add adds t to the set.
+func (ts *taskSet) add(t *Task) {
+	t.index = len(ts.tasks)
+	ts.tasks = append(ts.tasks, t)
+}
+
+// remove removes t from the set, moving the last task into its place.
+func (ts *taskSet) remove(t *Task) {
+	last := ts.tasks[len(ts.tasks)-1]
+	ts.tasks[t.index] = last
+	last.index = t.index
+	ts.tasks[len(ts.tasks)-1] = nil
+	ts.tasks = ts.tasks[:len(ts.tasks)-1]
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestTaskTable verifies that tasks are numbered compactly and move between
+// the sets of their states.
+func TestTaskTable(t *testing.T) {
+	var tt taskTable
+	tasks := make([]*Task, 4)
+	for i := range tasks {
+		tasks[i] = NewTask(0, func() {})
+		tt.add(tasks[i])
+	}
+	for i, task := range tasks {
+		if task.id != i+1 || tt.task(i+1) != task {
+			t.Fatalf("task %d numbered %d", i+1, task.id)
+		}
+	}
+	tt.setState(tasks[0], TaskBlocked)
+	tt.setState(tasks[2], TaskBlocked)
+	tt.setState(tasks[0], TaskReady)
+	ids := func(state TaskState) []int {
+		var ids []int
+		for _, task := range tt.inState(state) {
+			ids = append(ids, task.id)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	if got := ids(TaskReady); !slices.Equal(got, []int{1, 2, 4}) {
+		t.Errorf("ready tasks = %v, want [1 2 4]", got)
+	}
+	if got := ids(TaskBlocked); !slices.Equal(got, []int{3}) || !tasks[2].blocked {
+		t.Errorf("blocked tasks = %v, want [3]", got)
+	}
+	tt.reset()
+	if len(tt.inState(TaskReady)) != 0 || tt.task(1) != nil {
+		t.Error("reset kept tasks")
+	}
+}
+
+// BenchmarkTaskTable blocks and wakes tasks among ten thousand; each
+// operation takes the same time however many tasks there are.
+func BenchmarkTaskTable(b *testing.B) {
+	var tt taskTable
+	for i := 0; i < 10000; i++ {
+		tt.add(NewTask(0, func() {}))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := tt.task(i%10000 + 1)
+		tt.setState(t, TaskBlocked)
+		tt.setState(t, TaskReady)
+	}
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"bytes"
+	"runtime"
+	"slices"
+	"strconv"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TaskInfo describes a task of a scheduler that has not yet exited.
+type TaskInfo struct {
+	ID int
+
+	// Parent is the task that spawned it, or 0 if the test did.
+	Parent int
+
+	// Group is the name of the task's group, or "" if it has none.
+	Group string
+
+	// State is TaskReady for a task yet to start or waiting for its turn,
+	// TaskBlocked for one blocked, TaskDone for one killed and unwinding,
+	// and otherwise TaskRunning.
+	State TaskState
+
+	// BlockedOn is what a blocked task waits on: the object of the Block
+	// event it recorded last, or the wait reason of its goroutine, such
+	// as "chan receive" or "sync.Mutex.Lock".
+	BlockedOn string
+
+	// Spawn is the stack of the code that spawned the task, formatted as
+	// in trace events.
+	Spawn []string
+
+	// Steps is the number of events recorded for the task.
+	Steps int
+
+	// Priority is the task's priority: inherited from the task that
+	// spawned it, or 0, until it sets its own with SetPriority.
+	Priority int
+}
+
+// note counts ev against the task it belongs to, remembering the object
+// of a Block event until the task's next event. The caller must hold
+// s.mu.
+func (s *Scheduler) note(ev event) {
+	t := s.live[ev.task]
+	if t == nil {
+		return
+	}
+	t.steps++
+	t.blockedOn = ""
+	if ev.kind == trace.Block {
+		t.blockedOn = ev.object
+	}
+}
+
+// Tasks returns the tasks of s that have not exited, in ID order.
+func (s *Scheduler) Tasks() []TaskInfo {
+	// Read the goroutines before locking s.mu, which a task waiting for
+	// it would otherwise seem blocked on.
+	states := goroutineStates()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]TaskInfo, 0, len(s.live))
+	for id, t := range s.live {
+		info := TaskInfo{
+			ID:       id,
+			Parent:   t.parent,
+			Spawn:    stacks.frames(t.spawn),
+			Steps:    t.steps,
+			Priority: t.priority,
+		}
+		if t.group != nil {
+			info.Group = t.group.name
+		}
+		switch reason := states[t.gid]; {
+		case t.exited:
+			info.State = TaskDone
+		case t.task.state == TaskBlocked:
+			info.State, info.BlockedOn = TaskBlocked, t.blockedOn
+		case t.task.state == TaskReady:
+			info.State = TaskReady
+		case blockingStates[reason]:
+			info.State, info.BlockedOn = TaskBlocked, reason
+		default:
+			info.State = TaskRunning
+		}
+		tasks = append(tasks, info)
+	}
+	slices.SortFunc(tasks, func(a, b TaskInfo) int { return a.ID - b.ID })
+	return tasks
+}
+
+// blockingStates are the states, in goroutine dumps, of goroutines waiting
+// on channels and locks, which only another goroutine can wake.
+var blockingStates = map[string]bool{
+	"chan receive":            true,
+	"chan send":               true,
+	"chan receive (nil chan)": true,
+	"chan send (nil chan)":    true,
+	"select":                  true,
+	"select (no cases)":       true,
+	"sync.Cond.Wait":          true,
+	"sync.Mutex.Lock":         true,
+	"sync.RWMutex.Lock":       true,
+	"sync.RWMutex.RLock":      true,
+	"sync.WaitGroup.Wait":     true,
+	"semacquire":              true,
+}
+
+// goroutineStates maps the ID of every goroutine to its state, such as
+// "running" or "chan receive", read from a dump of every goroutine. A
+// goroutine waiting for one of the scheduler's own locks, which are only
+// ever held briefly, is reported running.
+func goroutineStates() map[uint64]string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	states := make(map[uint64]string)
+	var gid uint64
+	var lock bool
+	for _, line := range bytes.Split(buf, []byte("\n")) {
+		// goroutine 7 [chan receive, 2 minutes]:
+		rest, ok := bytes.CutPrefix(line, []byte("goroutine "))
+		if !ok {
+			if lock && len(line) > 0 && line[0] != '\t' && !syncFrame(line) {
+				// The first frame above package sync tells whose lock it is.
+				if bytes.HasPrefix(line, []byte(schedulerPkg)) {
+					states[gid] = "running"
+				}
+				lock = false
+			}
+			continue
+		}
+		id, state, ok := bytes.Cut(rest, []byte(" ["))
+		if !ok {
+			continue
+		}
+		if i := bytes.IndexAny(state, ",]"); i >= 0 {
+			state = state[:i]
+		}
+		gid, _ = strconv.ParseUint(string(id), 10, 64)
+		states[gid] = string(state)
+		lock = bytes.HasPrefix(state, []byte("sync.Mutex.")) || bytes.HasPrefix(state, []byte("sync.RWMutex."))
+	}
+	return states
+}
+
+// schedulerPkg prefixes the functions of this package in goroutine dumps.
+const schedulerPkg = "github.com/mziter/weft/internal/scheduler."
+
+// syncFrame reports whether the function of a frame line of a goroutine
+// dump belongs to the runtime or to package sync.
+func syncFrame(line []byte) bool {
+	for _, prefix := range []string{"runtime.", "sync.", "internal/sync."} {
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTasks verifies that Tasks describes the tasks left blocked by
+// RunUntil, and that a task killed while parked unwinds, leaving none
+// once Wait has returned.
+func TestTasks(t *testing.T) {
+	s := New(1)
+	c := MakeChan[int](0)
+	g := NewGroup("db")
+	s.Spawn(func(interface{}) {
+		s.SpawnIn(g, func(interface{}) {
+			var nilChan *Chan[int]
+			nilChan.Recv()
+		})
+		c.Recv()
+	})
+	if s.RunUntil(func() bool { return false }) {
+		t.Fatal("RunUntil returned true with its tasks blocked")
+	}
+	tasks := s.Tasks()
+	if len(tasks) != 2 {
+		t.Fatalf("Tasks() = %+v, want 2 tasks", tasks)
+	}
+	parent, child := tasks[0], tasks[1]
+	if parent.ID != 1 || parent.Parent != 0 || parent.Group != "" || parent.State != TaskBlocked || parent.BlockedOn != "chan receive" {
+		t.Errorf("parent = %+v, want task 1 blocked on chan receive", parent)
+	}
+	if parent.Steps == 0 {
+		t.Errorf("parent = %+v, want its steps counted", parent)
+	}
+	if child.ID != 2 || child.Parent != 1 || child.Group != "db" || child.State != TaskBlocked || child.BlockedOn != "nil chan" {
+		t.Errorf("child = %+v, want task 2 of db blocked on nil chan", child)
+	}
+	c.Send(1)
+	s.Kill(g)
+	s.Wait()
+	// Task 2 unwinds without waiting for its nil channel, but perhaps not
+	// before Wait returns.
+	deadline := time.Now().Add(time.Second)
+	for tasks := s.Tasks(); len(tasks) > 0; tasks = s.Tasks() {
+		if len(tasks) != 1 || tasks[0].ID != 2 || tasks[0].State != TaskDone || time.Now().After(deadline) {
+			t.Fatalf("Tasks() after Wait = %+v, want task 2 killed and gone", tasks)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
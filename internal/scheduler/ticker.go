@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// Ticker sends the virtual time on C at intervals, as a time.Ticker sends
+// the wall-clock time. Each tick fires with the other timers due at the
+// same time, so the schedule decides which of the tasks they wake runs
+// first. Like a time.Ticker since Go 1.23, no tick sent before Stop or
+// Reset is received after it.
+type Ticker struct {
+	C *Chan[time.Time]
+
+	s *Scheduler
+
+	// mu guards the rest. d is the interval and next the time of the next
+	// tick. timer is the pending tick on the virtual clock, or real the
+	// one on the wall clock inside a testing/synctest bubble; gen counts
+	// the ticks armed, so that a tick armed before Stop or Reset does
+	// nothing.
+	mu    sync.Mutex
+	d     time.Duration
+	next  time.Time
+	timer *timer
+	real  *time.Timer
+	gen   int
+}
+
+// NewTicker returns a ticker sending the time on its channel every d of
+// virtual time. It panics if d is not positive.
+func (s *Scheduler) NewTicker(d time.Duration) *Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c := MakeChan[time.Time](1)
+	c.clock = s.clock
+	t := &Ticker{C: c, s: s, d: d}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.next = s.Now().Add(d)
+	t.arm()
+	return t
+}
+
+// arm sets the timer of the next tick. The caller must hold t.mu.
+func (t *Ticker) arm() {
+	t.gen++
+	gen := t.gen
+	if t.s.inSynctest() {
+		t.real = time.AfterFunc(time.Until(t.next), func() { t.tick(gen, time.Now()) })
+		return
+	}
+	t.timer = t.s.clock.add(t.next.Sub(t.s.Now()), func(now time.Time) { t.tick(gen, now) })
+}
+
+// tick sends now on C, dropping it if the last tick is still unread as a
+// time.Ticker does, and arms the next one, unless Stop or Reset came
+// first.
+func (t *Ticker) tick(gen int, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if gen != t.gen {
+		return
+	}
+	t.C.TrySend(now)
+	for !t.next.After(now) {
+		t.next = t.next.Add(t.d)
+	}
+	t.arm()
+}
+
+// disarm stops the pending tick and drops a tick unread. The caller must
+// hold t.mu.
+func (t *Ticker) disarm() {
+	t.gen++
+	if t.timer != nil {
+		t.s.clock.stop(t.timer)
+		t.timer = nil
+	}
+	if t.real != nil {
+		t.real.Stop()
+		t.real = nil
+	}
+	t.C.TryRecv()
+}
+
+// Stop turns off the ticker. It does not close C.
+func (t *Ticker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disarm()
+}
+
+// Reset stops the ticker and restarts it with the interval d, the next
+// tick arriving once d has passed. It panics if d is not positive.
+func (t *Ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disarm()
+	t.d = d
+	t.next = t.s.Now().Add(d)
+	t.arm()
+}
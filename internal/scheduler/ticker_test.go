@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestTicker verifies that a ticker ticks at each interval of virtual
+// time, and that Reset changes the interval and Stop ends the ticks.
+func TestTicker(t *testing.T) {
+	s := New(1)
+	var got []time.Duration
+	s.Spawn(func(interface{}) {
+		tk := s.NewTicker(time.Second)
+		for range 3 {
+			now, _ := tk.C.Recv()
+			got = append(got, now.Sub(epoch))
+		}
+		tk.Reset(time.Minute)
+		now, _ := tk.C.Recv()
+		got = append(got, now.Sub(epoch))
+		tk.Stop()
+		s.Sleep(time.Hour)
+		if _, ok := tk.C.TryRecv(); ok {
+			t.Error("stopped ticker ticked")
+		}
+	})
+	s.Wait()
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 63 * time.Second}
+	if !slices.Equal(got, want) {
+		t.Errorf("ticks at %v, want %v", got, want)
+	}
+}
+
+// TestTickerUnread verifies that a tick nobody receives does not keep the
+// clock from advancing to the sleeps and timers due after it.
+func TestTickerUnread(t *testing.T) {
+	s := New(1)
+	var woke time.Time
+	s.Spawn(func(interface{}) {
+		tk := s.NewTicker(time.Second)
+		defer tk.Stop()
+		s.Sleep(1500 * time.Millisecond)
+		woke = s.Now()
+	})
+	s.Wait()
+	if want := epoch.Add(1500 * time.Millisecond); !woke.Equal(want) {
+		t.Errorf("woke at %v, want %v", woke, want)
+	}
+}
+
+// TestTickerDrops verifies that a ticker whose receiver falls behind drops
+// ticks rather than queuing them, and that Stop drops the tick unread.
+func TestTickerDrops(t *testing.T) {
+	s := New(1)
+	tk := s.NewTicker(time.Second)
+	s.Advance(5 * time.Second)
+	if now, ok := tk.C.TryRecv(); !ok || !now.Equal(epoch.Add(time.Second)) {
+		t.Errorf("first tick = %v, %v; want %v, true", now, ok, epoch.Add(time.Second))
+	}
+	if _, ok := tk.C.TryRecv(); ok {
+		t.Error("ticker queued a second tick unread")
+	}
+	s.Advance(time.Second)
+	tk.Stop()
+	if _, ok := tk.C.TryRecv(); ok {
+		t.Error("tick sent before Stop received after it")
+	}
+}
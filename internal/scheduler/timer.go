@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer runs a function on a new task once its time comes, as a timer of
+// time.AfterFunc runs it on a new goroutine, or sends the time on C, as a
+// timer of time.NewTimer does. The task is spawned, or the time sent, when
+// the clock fires the timer, with the other timers due at the same time, so
+// the schedule decides whether it runs before or after the tasks they wake.
+// Like a time.Timer since Go 1.23, no time sent on C before Stop or Reset is
+// received after it.
+type Timer struct {
+	// C is nil for a timer of AfterFunc.
+	C *Chan[time.Time]
+
+	s *Scheduler
+	f func()
+
+	// mu guards the rest. timer is the pending run on the virtual clock,
+	// or real the one on the wall clock inside a testing/synctest bubble,
+	// and active is set while one is pending; gen counts the runs armed,
+	// so that one armed before Stop or Reset does nothing.
+	mu     sync.Mutex
+	timer  *timer
+	real   *time.Timer
+	active bool
+	gen    int
+}
+
+// AfterFunc returns a timer that spawns a task calling f once d of virtual
+// time has passed. The task is detached, a child of no task.
+func (s *Scheduler) AfterFunc(d time.Duration, f func()) *Timer {
+	t := &Timer{s: s, f: f}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.arm(d)
+	return t
+}
+
+// NewTimer returns a timer that sends the virtual time on its channel once
+// d has passed.
+func (s *Scheduler) NewTimer(d time.Duration) *Timer {
+	c := MakeChan[time.Time](1)
+	c.clock = s.clock
+	t := &Timer{C: c, s: s}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.arm(d)
+	return t
+}
+
+// arm sets the timer to fire once d has passed. The caller must hold t.mu.
+func (t *Timer) arm(d time.Duration) {
+	t.gen++
+	gen := t.gen
+	t.active = true
+	if t.s.inSynctest() {
+		t.real = time.AfterFunc(d, func() { t.fire(gen, time.Now()) })
+		return
+	}
+	t.timer = t.s.clock.add(d, func(now time.Time) { t.fire(gen, now) })
+}
+
+// fire sends now on C, or spawns the task calling f, unless Stop or Reset
+// came first.
+func (t *Timer) fire(gen int, now time.Time) {
+	t.mu.Lock()
+	if gen != t.gen {
+		t.mu.Unlock()
+		return
+	}
+	t.active, t.timer, t.real = false, nil, nil
+	if t.C != nil {
+		t.C.TrySend(now)
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+	s := t.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spawn(nil, true, func(interface{}) { t.f() })
+}
+
+// disarm stops the pending run, if any, drops a time sent on C unread, and
+// reports whether there was one. The caller must hold t.mu.
+func (t *Timer) disarm() bool {
+	t.gen++
+	if t.timer != nil {
+		t.s.clock.stop(t.timer)
+	}
+	if t.real != nil {
+		t.real.Stop()
+	}
+	if t.C != nil {
+		t.C.TryRecv()
+	}
+	active := t.active
+	t.active, t.timer, t.real = false, nil, nil
+	return active
+}
+
+// Stop prevents the timer from firing and reports whether it stopped it,
+// false if it had fired or been stopped already. It does not wait for a
+// task f already runs on.
+func (t *Timer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.disarm()
+}
+
+// Reset makes the timer fire once d has passed, as if just created, and
+// reports whether it had been active.
+func (t *Timer) Reset(d time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := t.disarm()
+	t.arm(d)
+	return active
+}
@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAfterFunc verifies that a timer runs its function on a new task at
+// its deadline, and that a stopped timer does not.
+func TestAfterFunc(t *testing.T) {
+	s := New(1)
+	var at time.Time
+	var ran bool
+	s.AfterFunc(time.Minute, func() {
+		at = s.Now()
+		if Current() != s {
+			t.Error("AfterFunc's function did not run on a task")
+		}
+	})
+	stopped := s.AfterFunc(time.Second, func() { ran = true })
+	if !stopped.Stop() {
+		t.Error("Stop() on a pending timer = false, want true")
+	}
+	if stopped.Stop() {
+		t.Error("Stop() on a stopped timer = true, want false")
+	}
+	reset := s.AfterFunc(time.Hour, func() {})
+	if !reset.Reset(time.Second) {
+		t.Error("Reset() on a pending timer = false, want true")
+	}
+	s.Spawn(func(interface{}) {
+		s.Sleep(2 * time.Minute)
+	})
+	s.Wait()
+	if want := epoch.Add(time.Minute); !at.Equal(want) {
+		t.Errorf("AfterFunc(1m) ran at %v, want %v", at, want)
+	}
+	if ran {
+		t.Error("stopped timer ran its function")
+	}
+	if reset.Stop() {
+		t.Error("Stop() on a fired timer = true, want false")
+	}
+}
+
+// TestNewTimer verifies that a timer sends the time on its channel at its
+// deadline, and that Stop and Reset drop a time sent unread.
+func TestNewTimer(t *testing.T) {
+	s := New(1)
+	var at time.Time
+	s.Spawn(func(interface{}) {
+		tm := s.NewTimer(time.Minute)
+		at, _ = tm.C.Recv()
+		if tm.Stop() {
+			t.Error("Stop() on a fired timer = true, want false")
+		}
+		tm.Reset(time.Second)
+		s.Sleep(2 * time.Second)
+		if tm.Reset(time.Hour) {
+			t.Error("Reset() on a fired timer = true, want false")
+		}
+		if _, ok := tm.C.TryRecv(); ok {
+			t.Error("received a time sent before Reset")
+		}
+		if !tm.Stop() {
+			t.Error("Stop() on a pending timer = false, want true")
+		}
+	})
+	s.Wait()
+	if want := epoch.Add(time.Minute); !at.Equal(want) {
+		t.Errorf("NewTimer(1m) sent %v, want %v", at, want)
+	}
+}
+
+// TestAfterFuncRace verifies that whether a timeout runs before the work
+// it races with, due at the same time, is a decision of the schedule.
+func TestAfterFuncRace(t *testing.T) {
+	timedOut := func(seed uint64) bool {
+		s := New(seed)
+		done, timedOut := false, false
+		s.Spawn(func(interface{}) {
+			s.Sleep(time.Second)
+			done = true
+		})
+		s.AfterFunc(time.Second, func() { timedOut = !done })
+		s.Wait()
+		return timedOut
+	}
+	seen := make(map[bool]bool)
+	for seed := uint64(1); seed <= 20; seed++ {
+		got := timedOut(seed)
+		if again := timedOut(seed); again != got {
+			t.Errorf("seed %d: timed out %v, then %v", seed, got, again)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("20 seeds all timed out %v", seen)
+	}
+}
@@ -0,0 +1,295 @@
+package scheduler
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/mziter/weft/trace"
+)
+
+// Tasks take turns: one task at a time holds the turn and runs, from one
+// scheduling point to its next, until it blocks or until it exits, and
+// then passes the turn to a ready task chosen by the schedule, itself
+// included. A task blocked through park is in the scheduler's blocked set
+// until another makes it ready with unpark. Every decision is made where
+// no task runs, so the ready tasks to choose from are the same in every
+// run making the same decisions.
+//
+// Turns are given only while the goroutines that spawned the tasks, the
+// drivers, are not running: while one waits in Wait, or blocks on a weft
+// primitive, when its place counts among the drivers waiting from the
+// moment it parks to the moment it is woken. A driver woken by a task
+// stops the turns at that task's next scheduling point, so that it goes
+// on from the same point of the schedule in every run. RunUntil and Step
+// give the turns themselves.
+//
+// A task that blocks outside the scheduler's sight, on a Go channel or
+// lock, keeps the turn, and the run stalls until it is woken; code under
+// test should block only on weft's primitives.
+//
+// When no task is left ready, the watchdog advances the virtual clock to
+// wake the tasks sleeping, or RunUntil and Step do while they give the
+// turns.
+
+// pass gives the turn, which no task holds, to a ready task chosen by the
+// schedule, while turns are given. While the tasks are stepped it wakes
+// RunUntil or Step to give it instead, and while no task is ready, the
+// watchdog. While the clock fires timers it waits for them all to have
+// fired. The caller must hold s.mu.
+func (s *Scheduler) pass() {
+	if s.frozen {
+		return
+	}
+	if s.stepping {
+		s.wake.Broadcast()
+		return
+	}
+	if !s.begun() || s.holder != 0 {
+		return
+	}
+	if !s.giveTurn() {
+		// With no task left, the watchdog may still have AfterDone
+		// calls to poll or a deadlock to find.
+		s.startWatch()
+		s.poke()
+	}
+}
+
+// giveTurn gives the turn to a ready task chosen by the schedule and
+// reports whether there was one. The caller must hold s.mu.
+func (s *Scheduler) giveTurn() bool {
+	ready := s.tasks.inState(TaskReady)
+	var id int
+	switch {
+	case len(ready) == 0:
+		return false
+	case len(ready) == 1:
+		id = ready[0].id
+	case s.decider == nil && !s.strictPriority:
+		// Spare listing the IDs for the seed alone.
+		id = ready[s.choose(len(ready))].id
+	default:
+		ids := s.readyIDs[:0]
+		for _, task := range ready {
+			ids = append(ids, task.id)
+		}
+		s.readyIDs = ids
+		id = ids[s.chooseTask(ids)]
+	}
+	t := s.live[id]
+	s.holder = id
+	s.turns++
+	s.tasks.setState(t.task, TaskRunning)
+	if id != s.last {
+		s.record(event{task: id, kind: trace.Run})
+		s.last = id
+	}
+	select {
+	case t.token <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// begun reports whether turns are given: while a driver waits, or always
+// inside a testing/synctest bubble. The caller must hold s.mu.
+func (s *Scheduler) begun() bool {
+	return s.waiting > 0 || s.synctest
+}
+
+// driverWaits counts a driver of s waiting, if n is 1, or woken, if n is
+// -1, and gives the turn if turns begin.
+func (s *Scheduler) driverWaits(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waiting += n
+	if s.holder == 0 {
+		s.pass()
+	}
+}
+
+// settled reports whether the run has no task left that could take a
+// turn. The caller must hold s.mu.
+func (s *Scheduler) settled() bool {
+	return len(s.tasks.inState(TaskReady))+len(s.tasks.inState(TaskRunning))+len(s.tasks.inState(TaskBlocked)) == 0
+}
+
+// await waits, without s.mu, until t holds the turn or is killed. The
+// caller must hold s.mu.
+func (s *Scheduler) await(t *running) {
+	for s.holder != t.id && !t.exited {
+		s.mu.Unlock()
+		<-t.token
+		s.mu.Lock()
+	}
+	select {
+	case <-t.token:
+	default:
+	}
+}
+
+// gate makes t, at a scheduling point, ready, passes the turn if t held it
+// and waits for t's next turn. The caller must hold s.mu.
+func (s *Scheduler) gate(t *running) {
+	if t.exited {
+		return
+	}
+	s.tasks.setState(t.task, TaskReady)
+	if s.holder == t.id {
+		s.holder = 0
+	}
+	if s.holder == 0 {
+		s.pass()
+	}
+	s.await(t)
+}
+
+// park blocks t, which the caller runs, on object, recording it, until
+// unpark makes it ready and it is given a turn; it returns false if t's
+// group was killed meanwhile, when the caller must exit. The caller must
+// hold s.mu.
+func (s *Scheduler) park(t *running, object string) bool {
+	s.record(event{task: t.id, kind: trace.Block, object: object, stack: callerStack()})
+	s.tasks.setState(t.task, TaskBlocked)
+	if s.holder == t.id {
+		s.holder = 0
+		s.pass()
+	}
+	s.await(t)
+	return !t.exited
+}
+
+// unpark makes t, blocked by park on object, ready again. The caller must
+// hold s.mu.
+func (s *Scheduler) unpark(t *running, object string) {
+	if t.exited || t.task.state != TaskBlocked {
+		return
+	}
+	s.record(event{task: t.id, kind: trace.Unblock, object: object, stack: callerStack()})
+	s.tasks.setState(t.task, TaskReady)
+	if s.holder == 0 {
+		s.pass()
+	}
+}
+
+// parkCaller parks the calling task on object until it is unparked and
+// given a turn, exiting it if its group is killed, or blocks forever, as
+// a driver waiting, if the caller is not a task.
+func parkCaller(object string) {
+	v, ok := byGoroutine.Load(goid())
+	if !ok {
+		newWaiter(NewBlocker(object)).park()
+		return
+	}
+	t := v.(*running)
+	t.s.mu.Lock()
+	ok = t.s.park(t, object)
+	t.s.mu.Unlock()
+	if !ok {
+		t.exit()
+	}
+}
+
+// exit ends the calling task t, killed with its group, as Checkpoint does.
+func (t *running) exit() {
+	if t.exiting.CompareAndSwap(false, true) {
+		runtime.Goexit()
+	}
+}
+
+// poke wakes the watchdog. The caller must hold s.mu.
+func (s *Scheduler) poke() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// startWatch starts the watchdog of s, unless it is running. The caller
+// must hold s.mu.
+func (s *Scheduler) startWatch() {
+	if !s.watching {
+		s.watching = true
+		go s.watch()
+	}
+}
+
+// watch is the watchdog of s, running while s has tasks that have not
+// exited. Woken when no task is left ready, it advances the clock if no
+// task of the schedulers keeping it can run and their drivers wait, until
+// one can.
+func (s *Scheduler) watch() {
+	for range s.kick {
+		s.mu.Lock()
+		settled := s.settled()
+		if settled {
+			s.watching = false
+		}
+		idle := !s.stepping && s.idle()
+		s.mu.Unlock()
+		// Settled, s may still hold back the sleep of a goroutine other
+		// than a task. Timers that fire waking no task, such as a tick
+		// nobody receives yet, leave s idle, so advance again.
+		for idle && s.clock.advance() {
+			s.mu.Lock()
+			idle = !s.stepping && s.idle()
+			s.mu.Unlock()
+		}
+		if settled {
+			return
+		}
+	}
+}
+
+// drivers maps the IDs of the drivers, the goroutines that spawned tasks
+// of a scheduler other than their own, to the schedulers they drive, each
+// until its tasks have all exited.
+var (
+	driversMu sync.Mutex
+	drivers   = make(map[uint64][]*Scheduler)
+)
+
+// addDriver adds the calling goroutine, which spawned a task of s but is
+// not one of its tasks, to the drivers of s. The caller must hold s.mu.
+func (s *Scheduler) addDriver() {
+	gid := goid()
+	if slices.Contains(s.drivers, gid) {
+		return
+	}
+	s.drivers = append(s.drivers, gid)
+	if v, ok := byGoroutine.Load(gid); ok {
+		v.(*running).drives.Store(true)
+	}
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[gid] = append(drivers[gid], s)
+}
+
+// dropDrivers forgets the drivers of s, once its tasks have all exited.
+// The caller must hold s.mu.
+func (s *Scheduler) dropDrivers() {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	for _, gid := range s.drivers {
+		driven := slices.DeleteFunc(slices.Clone(drivers[gid]), func(d *Scheduler) bool { return d == s })
+		if len(driven) == 0 {
+			delete(drivers, gid)
+		} else {
+			drivers[gid] = driven
+		}
+	}
+	s.drivers = s.drivers[:0]
+}
+
+// driven returns the schedulers the goroutine gid drives, including the
+// one it is bound to, whose run it drives even before spawning a task.
+func driven(gid uint64) []*Scheduler {
+	driversMu.Lock()
+	d := drivers[gid]
+	driversMu.Unlock()
+	if v, ok := bindings.Load(gid); ok && !slices.Contains(d, v.(*Scheduler)) {
+		d = append(slices.Clip(d), v.(*Scheduler))
+	}
+	return d
+}
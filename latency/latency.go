@@ -0,0 +1,96 @@
+// Package latency draws durations of virtual time from distributions, as
+// decisions of a weft schedule, for simulated operations to take. weftnet
+// and weftfs take a Distribution for the latency of messages and file
+// operations, so timeouts, retries and hedged requests meet latencies that
+// are realistic in shape yet replay exactly with their seed:
+//
+//	n.SetFaults("client", "server", weftnet.Faults{
+//		Latency: latency.Bimodal(latency.Exponential(time.Millisecond, 2*time.Millisecond), 0.01,
+//			latency.Uniform(100*time.Millisecond, time.Second)),
+//	})
+//
+// Decision 0 of the schedule always draws the shortest duration, and the
+// fast mode of a Bimodal, so shrinking a failing trace keeps only the slow
+// operations the failure needs.
+package latency
+
+import (
+	"math"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// A Distribution draws durations of virtual time.
+type Distribution interface {
+	// Draw returns a duration as a decision of s's schedule.
+	Draw(s *weft.Scheduler) time.Duration
+}
+
+// steps is the number of outcomes a draw chooses among.
+const steps = 1 << 16
+
+// Constant returns the distribution of d alone.
+func Constant(d time.Duration) Distribution {
+	return constant(d)
+}
+
+type constant time.Duration
+
+func (c constant) Draw(*weft.Scheduler) time.Duration {
+	return time.Duration(c)
+}
+
+// Uniform returns the distribution of durations from min to max, each as
+// likely.
+func Uniform(min, max time.Duration) Distribution {
+	return uniform{min, max}
+}
+
+type uniform struct {
+	min, max time.Duration
+}
+
+func (u uniform) Draw(s *weft.Scheduler) time.Duration {
+	if u.max <= u.min {
+		return u.min
+	}
+	return u.min + time.Duration(float64(u.max-u.min)*float64(s.Choose(steps))/(steps-1))
+}
+
+// Exponential returns the distribution of min plus an exponentially
+// distributed duration with the given mean: mostly short, with a long tail,
+// as the service times of a queue are. Draws are at most min plus about
+// eleven times mean.
+func Exponential(min, mean time.Duration) Distribution {
+	return exponential{min, mean}
+}
+
+type exponential struct {
+	min, mean time.Duration
+}
+
+func (e exponential) Draw(s *weft.Scheduler) time.Duration {
+	u := float64(s.Choose(steps)) / steps
+	return e.min + time.Duration(-float64(e.mean)*math.Log1p(-u))
+}
+
+// Bimodal returns the distribution that draws from slow with probability
+// p, from 0 to 1, and otherwise from fast: the tail spikes of garbage
+// collection pauses, cache misses and retransmissions.
+func Bimodal(fast Distribution, p float64, slow Distribution) Distribution {
+	return bimodal{fast, p, slow}
+}
+
+type bimodal struct {
+	fast Distribution
+	p    float64
+	slow Distribution
+}
+
+func (b bimodal) Draw(s *weft.Scheduler) time.Duration {
+	if s.Chance(b.p) {
+		return b.slow.Draw(s)
+	}
+	return b.fast.Draw(s)
+}
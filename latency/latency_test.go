@@ -0,0 +1,60 @@
+package latency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// draws returns n draws from d.
+func draws(d Distribution, n int) []time.Duration {
+	s := weft.NewScheduler(1)
+	ds := make([]time.Duration, n)
+	for i := range ds {
+		ds[i] = d.Draw(s)
+	}
+	return ds
+}
+
+// TestBounds verifies that draws stay within their distribution's range.
+func TestBounds(t *testing.T) {
+	for _, d := range draws(Constant(time.Second), 10) {
+		if d != time.Second {
+			t.Fatalf("Constant(1s) drew %v", d)
+		}
+	}
+	for _, d := range draws(Uniform(time.Millisecond, 2*time.Millisecond), 1000) {
+		if d < time.Millisecond || d > 2*time.Millisecond {
+			t.Fatalf("Uniform(1ms, 2ms) drew %v", d)
+		}
+	}
+	for _, d := range draws(Exponential(time.Millisecond, time.Millisecond), 1000) {
+		if d < time.Millisecond || d > 13*time.Millisecond {
+			t.Fatalf("Exponential(1ms, 1ms) drew %v", d)
+		}
+	}
+}
+
+// TestShape verifies the mean of an exponential distribution and the share
+// of a bimodal one's spikes.
+func TestShape(t *testing.T) {
+	const n = 4000
+	var sum time.Duration
+	for _, d := range draws(Exponential(0, 10*time.Millisecond), n) {
+		sum += d
+	}
+	if mean := sum / n; mean < 8*time.Millisecond || mean > 12*time.Millisecond {
+		t.Errorf("Exponential(0, 10ms) mean = %v, want about 10ms", mean)
+	}
+
+	spikes := 0
+	for _, d := range draws(Bimodal(Constant(time.Millisecond), 0.1, Constant(time.Second)), n) {
+		if d == time.Second {
+			spikes++
+		}
+	}
+	if spikes < n/20 || spikes > n/5 {
+		t.Errorf("Bimodal(p=0.1) drew %d spikes in %d, want about %d", spikes, n, n/10)
+	}
+}
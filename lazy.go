@@ -0,0 +1,42 @@
+//go:build detsched
+
+package weft
+
+import "sync/atomic"
+
+// lazy holds a scheduler primitive created on first use, so that the zero
+// value of a weft primitive is ready to use. Tasks using a zero value for
+// the first time at once agree on a single primitive.
+type lazy[T any] struct {
+	p atomic.Pointer[lazyPrimitive[T]]
+}
+
+// lazyPrimitive is a primitive with the address of the lazy that created
+// it, to detect copies.
+type lazyPrimitive[T any] struct {
+	v    *T
+	self *lazy[T]
+}
+
+// get returns the primitive, creating it with create on first use. It
+// panics if l is a copy of one that was used before it was copied, which
+// would otherwise share the original's state; name names the primitive in
+// the panic.
+func (l *lazy[T]) get(name string, create func() *T) *T {
+	p := l.p.Load()
+	if p == nil {
+		p = &lazyPrimitive[T]{v: create(), self: l}
+		if !l.p.CompareAndSwap(nil, p) {
+			p = l.p.Load()
+		}
+	}
+	if p.self != l {
+		panic("weft: " + name + " copied after first use")
+	}
+	return p.v
+}
+
+// used reports whether the primitive has been created.
+func (l *lazy[T]) used() bool {
+	return l.p.Load() != nil
+}
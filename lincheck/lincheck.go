@@ -0,0 +1,253 @@
+// Package lincheck checks histories of concurrent operations for
+// linearizability: whether every operation can be taken to happen at an
+// instant between its call and its return such that, in that order, the
+// operations are valid for a sequential model of the object.
+//
+// Record a History as weft tasks call and return from the object under
+// test, then check it against a Model:
+//
+//	var h lincheck.History
+//	s.Go(func(weft.Context) {
+//		op := h.Call(1, kvInput{Op: "put", Key: "x", Value: "a"})
+//		store.Put("x", "a")
+//		op.Return(nil)
+//	})
+//	s.Wait()
+//	if !lincheck.Check(kvModel, h.Operations()) {
+//		t.Fatal("history is not linearizable")
+//	}
+//
+// Check searches the orders the history allows, with the algorithm of Wing
+// and Gong as improved by Lowe, so it suits the short histories of an
+// explored schedule rather than hours of production traffic.
+package lincheck
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/mziter/weft"
+)
+
+// Operation is a call of an operation and its result.
+type Operation struct {
+	// Client identifies the task that made the call; its operations do
+	// not overlap.
+	Client int
+
+	Input, Output any
+
+	// Call and Return are the logical times of the call and its return;
+	// an operation that never returned has a Return of math.MaxInt64.
+	Call, Return int64
+}
+
+// Model is the sequential specification of an object.
+type Model struct {
+	// Init returns the initial state. States must be comparable.
+	Init func() any
+
+	// Step applies an operation with input to state, reporting whether
+	// output is a valid result of it and the state after it. The output
+	// of an operation that never returned is nil, and any result is valid
+	// for it.
+	Step func(state, input, output any) (ok bool, next any)
+
+	// Partition, if set, splits a history into histories of independent
+	// parts of the object, such as the keys of a map, that are checked
+	// separately: much faster than checking them together.
+	Partition func(ops []Operation) [][]Operation
+}
+
+// History records the operations called on an object. The zero History is
+// empty and ready to use.
+type History struct {
+	mu    weft.Mutex
+	clock int64
+	ops   []*Operation
+}
+
+// Pending is an operation called and not yet returned.
+type Pending struct {
+	h  *History
+	op *Operation
+}
+
+// Call records that client called an operation with input, and returns the
+// operation to record its result with. Call it just before calling the
+// object, and Return just after, so the history brackets the operation.
+func (h *History) Call(client int, input any) *Pending {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clock++
+	op := &Operation{Client: client, Input: input, Call: h.clock, Return: math.MaxInt64}
+	h.ops = append(h.ops, op)
+	return &Pending{h, op}
+}
+
+// Return records that the operation returned output.
+func (p *Pending) Return(output any) {
+	p.h.mu.Lock()
+	defer p.h.mu.Unlock()
+	p.h.clock++
+	p.op.Output, p.op.Return = output, p.h.clock
+}
+
+// Operations returns the operations recorded so far, in the order they were
+// called.
+func (h *History) Operations() []Operation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ops := make([]Operation, len(h.ops))
+	for i, op := range h.ops {
+		ops[i] = *op
+	}
+	return ops
+}
+
+// Check reports whether the history ops is linearizable with respect to m.
+// Operations that never returned may or may not have taken effect.
+func Check(m Model, ops []Operation) bool {
+	parts := [][]Operation{ops}
+	if m.Partition != nil {
+		parts = m.Partition(ops)
+	}
+	for _, part := range parts {
+		if !check(m, part) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify is like Check, but returns an error wrapping
+// weft.ErrLinearizability if the history is not linearizable, for a test
+// to pass to Scheduler.Fail.
+func Verify(m Model, ops []Operation) error {
+	if Check(m, ops) {
+		return nil
+	}
+	return fmt.Errorf("%w: %d operations", weft.ErrLinearizability, len(ops))
+}
+
+// event is a call or return in the list of events still to linearize.
+type event struct {
+	op         int
+	call       bool
+	match      *event // the call's return
+	prev, next *event
+}
+
+// lift removes a call and its return from the list.
+func (e *event) lift() {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	r := e.match
+	r.prev.next = r.next
+	if r.next != nil {
+		r.next.prev = r.prev
+	}
+}
+
+// unlift puts a lifted call and its return back.
+func (e *event) unlift() {
+	r := e.match
+	r.prev.next = r
+	if r.next != nil {
+		r.next.prev = r
+	}
+	e.prev.next = e
+	e.next.prev = e
+}
+
+// cacheKey is a set of linearized operations and the state they lead to.
+type cacheKey struct {
+	linearized string
+	state      any
+}
+
+// check searches for a linearization of ops: it linearizes calls in list
+// order as the model allows, and backtracks on reaching a return whose call
+// it could not linearize, skipping configurations it has explored before.
+func check(m Model, ops []Operation) bool {
+	head := buildEvents(ops)
+	linearized := make([]byte, (len(ops)+7)/8)
+	seen := map[cacheKey]bool{}
+	type frame struct {
+		e     *event
+		state any
+	}
+	var stack []frame
+	state := m.Init()
+
+	e := head.next
+	for head.next != nil {
+		if e.call {
+			op := ops[e.op]
+			if ok, next := m.Step(state, op.Input, op.Output); ok {
+				linearized[e.op/8] |= 1 << (e.op % 8)
+				key := cacheKey{string(linearized), next}
+				if !seen[key] {
+					seen[key] = true
+					stack = append(stack, frame{e, state})
+					state = next
+					e.lift()
+					e = head.next
+					continue
+				}
+				linearized[e.op/8] &^= 1 << (e.op % 8)
+			}
+			e = e.next
+			continue
+		}
+		if ops[e.op].Return == math.MaxInt64 {
+			// Only operations that never returned are left, and they
+			// need not have taken effect.
+			return true
+		}
+		if len(stack) == 0 {
+			return false
+		}
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		state = f.state
+		linearized[f.e.op/8] &^= 1 << (f.e.op % 8)
+		f.e.unlift()
+		e = f.e.next
+	}
+	return true
+}
+
+// buildEvents returns the head of a list of the calls and returns of ops,
+// in time order.
+func buildEvents(ops []Operation) *event {
+	type timed struct {
+		t int64
+		e *event
+	}
+	var events []timed
+	for i, op := range ops {
+		call := &event{op: i, call: true}
+		ret := &event{op: i}
+		call.match = ret
+		events = append(events, timed{op.Call, call}, timed{op.Return, ret})
+	}
+	slices.SortStableFunc(events, func(a, b timed) int {
+		switch {
+		case a.t < b.t:
+			return -1
+		case a.t > b.t:
+			return 1
+		}
+		return 0
+	})
+	head := &event{}
+	prev := head
+	for _, te := range events {
+		te.e.prev = prev
+		prev.next = te.e
+		prev = te.e
+	}
+	return head
+}
@@ -0,0 +1,109 @@
+package lincheck
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mziter/weft"
+)
+
+// register is a model of a read/write register holding an int, whose
+// inputs are a value to write or nil to read.
+var register = Model{
+	Init: func() any { return 0 },
+	Step: func(state, input, output any) (bool, any) {
+		if input != nil {
+			return true, input
+		}
+		return output == nil || output == state, state
+	},
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Operation
+		want bool
+	}{
+		{
+			name: "sequential",
+			ops: []Operation{
+				{Client: 0, Input: 1, Call: 1, Return: 2},
+				{Client: 1, Input: nil, Output: 1, Call: 3, Return: 4},
+			},
+			want: true,
+		},
+		{
+			name: "stale read",
+			ops: []Operation{
+				{Client: 0, Input: 1, Call: 1, Return: 2},
+				{Client: 1, Input: nil, Output: 0, Call: 3, Return: 4},
+			},
+			want: false,
+		},
+		{
+			name: "concurrent read sees either value",
+			ops: []Operation{
+				{Client: 0, Input: 1, Call: 1, Return: 4},
+				{Client: 1, Input: nil, Output: 0, Call: 2, Return: 3},
+			},
+			want: true,
+		},
+		{
+			name: "reads disagree on order",
+			ops: []Operation{
+				{Client: 0, Input: 1, Call: 1, Return: 10},
+				{Client: 1, Input: 2, Call: 2, Return: 10},
+				{Client: 2, Input: nil, Output: 1, Call: 3, Return: 4},
+				{Client: 3, Input: nil, Output: 2, Call: 5, Return: 6},
+				{Client: 2, Input: nil, Output: 1, Call: 7, Return: 8},
+			},
+			want: false,
+		},
+		{
+			name: "pending write may have happened",
+			ops: []Operation{
+				{Client: 0, Input: 1, Call: 1, Return: math.MaxInt64},
+				{Client: 1, Input: nil, Output: 1, Call: 2, Return: 3},
+				{Client: 2, Input: 2, Call: 4, Return: math.MaxInt64},
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Check(register, tt.ops); got != tt.want {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+			if err := Verify(register, tt.ops); (err == nil) != tt.want || err != nil && !errors.Is(err, weft.ErrLinearizability) {
+				t.Errorf("Verify() = %v", err)
+			}
+		})
+	}
+}
+
+// TestHistory verifies that a history brackets each operation between its
+// call and return.
+func TestHistory(t *testing.T) {
+	var h History
+	w := h.Call(0, 1)
+	r := h.Call(1, nil)
+	w.Return(nil)
+	r.Return(1)
+	h.Call(2, 2)
+
+	ops := h.Operations()
+	if len(ops) != 3 {
+		t.Fatalf("Operations() = %d operations, want 3", len(ops))
+	}
+	if !(ops[0].Call < ops[1].Call && ops[1].Call < ops[0].Return && ops[0].Return < ops[1].Return) {
+		t.Errorf("operations not overlapping as called: %+v", ops[:2])
+	}
+	if ops[2].Return != math.MaxInt64 {
+		t.Errorf("pending operation Return = %d, want math.MaxInt64", ops[2].Return)
+	}
+	if !Check(register, ops) {
+		t.Error("Check() = false, want true")
+	}
+}
@@ -0,0 +1,197 @@
+//go:build detsched
+
+package weft
+
+import (
+	"sync"
+
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// Map is a deterministic sync.Map with typed keys and values. Each of its
+// operations is a scheduling point, so the schedule decides how tasks
+// sharing a cache interleave their loads and stores, and Range visits the
+// keys in an order the schedule decides too, as ranging over a sync.Map
+// visits them in an order that changes from run to run.
+//
+// The zero Map is empty and ready for use. A Map must not be copied after
+// first use.
+type Map[K comparable, V any] struct {
+	// mu guards the rest, for goroutines other than tasks. entries holds
+	// the entries in the order their keys were stored, including deleted
+	// ones until deleted reaches half of them, and index the live ones by
+	// key.
+	mu      sync.Mutex
+	entries []*mapEntry[K, V]
+	index   map[K]*mapEntry[K, V]
+	deleted int
+}
+
+// mapEntry is a key of a Map and its value, until deleted.
+type mapEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	deleted bool
+}
+
+// Load returns the value stored in the map for a key, or the zero value
+// if no value is present. The ok result indicates whether value was found
+// in the map.
+func (m *Map[K, V]) Load(key K) (value V, ok bool) {
+	scheduler.Checkpoint()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.index[key]; ok {
+		return e.value, true
+	}
+	return value, false
+}
+
+// Store sets the value for a key.
+func (m *Map[K, V]) Store(key K, value V) {
+	m.Swap(key, value)
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value. The loaded result is
+// true if the value was loaded, false if stored.
+func (m *Map[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	scheduler.Checkpoint()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.index[key]; ok {
+		return e.value, true
+	}
+	m.add(key, value)
+	return value, false
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value
+// if any. The loaded result reports whether the key was present.
+func (m *Map[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	scheduler.Checkpoint()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.index[key]
+	if !ok {
+		return value, false
+	}
+	m.remove(e)
+	return e.value, true
+}
+
+// Delete deletes the value for a key.
+func (m *Map[K, V]) Delete(key K) {
+	m.LoadAndDelete(key)
+}
+
+// Swap swaps the value for a key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Map[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	scheduler.Checkpoint()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.index[key]; ok {
+		previous, e.value = e.value, value
+		return previous, true
+	}
+	m.add(key, value)
+	return previous, false
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored
+// in the map is equal to old. The old value must be of a comparable type.
+func (m *Map[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	scheduler.Checkpoint()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.index[key]
+	if !ok || any(e.value) != any(old) {
+		return false
+	}
+	e.value = new
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+// The old value must be of a comparable type.
+//
+// If there is no current value for key in the map, CompareAndDelete
+// returns false (even if the old value is the zero value of V).
+func (m *Map[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	scheduler.Checkpoint()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.index[key]
+	if !ok || any(e.value) != any(old) {
+		return false
+	}
+	m.remove(e)
+	return true
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// As for a sync.Map, Range does not correspond to any consistent snapshot
+// of the map's contents: no key is visited more than once, but a key
+// stored or deleted concurrently may or may not be visited, and each visit
+// is a scheduling point at which other tasks may change the map. Under a
+// scheduler the iteration starts at a key the schedule chooses and goes
+// on in the order the keys were stored, wrapping around.
+func (m *Map[K, V]) Range(f func(key K, value V) bool) {
+	m.mu.Lock()
+	entries := make([]*mapEntry[K, V], 0, len(m.index))
+	for _, e := range m.entries {
+		if !e.deleted {
+			entries = append(entries, e)
+		}
+	}
+	m.mu.Unlock()
+	start := 0
+	if s := scheduler.Current(); s != nil && len(entries) > 0 {
+		start = s.Choose(len(entries))
+	}
+	for i := range entries {
+		e := entries[(start+i)%len(entries)]
+		scheduler.Checkpoint()
+		m.mu.Lock()
+		deleted, value := e.deleted, e.value
+		m.mu.Unlock()
+		if deleted {
+			continue
+		}
+		if !f(e.key, value) {
+			return
+		}
+	}
+}
+
+// add stores a new entry for key. The caller must hold m.mu.
+func (m *Map[K, V]) add(key K, value V) {
+	if m.index == nil {
+		m.index = make(map[K]*mapEntry[K, V])
+	}
+	e := &mapEntry[K, V]{key: key, value: value}
+	m.entries = append(m.entries, e)
+	m.index[key] = e
+}
+
+// remove deletes e, compacting the entries once half of them are deleted.
+// The caller must hold m.mu.
+func (m *Map[K, V]) remove(e *mapEntry[K, V]) {
+	e.deleted = true
+	delete(m.index, e.key)
+	m.deleted++
+	if m.deleted*2 < len(m.entries) {
+		return
+	}
+	live := m.entries[:0]
+	for _, e := range m.entries {
+		if !e.deleted {
+			live = append(live, e)
+		}
+	}
+	clear(m.entries[len(live):])
+	m.entries, m.deleted = live, 0
+}
@@ -0,0 +1,67 @@
+//go:build detsched
+
+package weft
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// TestMapLoadOrStoreRace verifies that tasks racing to fill a Map agree on
+// the value stored first, and that seeds differ in which task stores it.
+func TestMapLoadOrStoreRace(t *testing.T) {
+	winners := make(map[int]bool)
+	for seed := uint64(1); seed <= 20; seed++ {
+		s := NewScheduler(seed)
+		var m Map[string, int]
+		var got []int
+		for i := range 3 {
+			s.Go(func(Context) {
+				v, _ := m.LoadOrStore("k", i)
+				got = append(got, v)
+			})
+		}
+		s.Wait()
+		if got[0] != got[1] || got[1] != got[2] {
+			t.Fatalf("seed %d: tasks loaded %v, want the same value", seed, got)
+		}
+		winners[got[0]] = true
+	}
+	if len(winners) < 2 {
+		t.Errorf("20 seeds all stored the value of task %v", winners)
+	}
+}
+
+// TestMapRangeOrder verifies that the order in which Range visits the keys
+// is a decision of the schedule: the same for a seed, different across
+// seeds.
+func TestMapRangeOrder(t *testing.T) {
+	run := func(seed uint64) []int {
+		s := NewScheduler(seed)
+		var m Map[int, bool]
+		var keys []int
+		s.Go(func(Context) {
+			for k := range 5 {
+				m.Store(k, true)
+			}
+			m.Range(func(k int, _ bool) bool {
+				keys = append(keys, k)
+				return true
+			})
+		})
+		s.Wait()
+		return keys
+	}
+	orders := make(map[string]bool)
+	for seed := uint64(1); seed <= 20; seed++ {
+		keys := run(seed)
+		if again := run(seed); !slices.Equal(again, keys) {
+			t.Errorf("seed %d: Range visited %v, then %v", seed, keys, again)
+		}
+		orders[fmt.Sprint(keys)] = true
+	}
+	if len(orders) < 2 {
+		t.Errorf("20 seeds all ranged in the order %v", orders)
+	}
+}
@@ -0,0 +1,60 @@
+package weft
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestMap verifies that a Map loads, stores, swaps and deletes as a
+// sync.Map does, including nil values of an interface type, in both build
+// modes.
+func TestMap(t *testing.T) {
+	var m Map[string, int]
+	if _, ok := m.Load("a"); ok {
+		t.Error("zero Map loaded a value")
+	}
+	m.Store("a", 1)
+	if v, loaded := m.LoadOrStore("a", 2); v != 1 || !loaded {
+		t.Errorf("LoadOrStore(a, 2) = %d, %v; want 1, true", v, loaded)
+	}
+	if v, loaded := m.LoadOrStore("b", 2); v != 2 || loaded {
+		t.Errorf("LoadOrStore(b, 2) = %d, %v; want 2, false", v, loaded)
+	}
+	if prev, loaded := m.Swap("b", 3); prev != 2 || !loaded {
+		t.Errorf("Swap(b, 3) = %d, %v; want 2, true", prev, loaded)
+	}
+	if m.CompareAndSwap("b", 2, 4) || !m.CompareAndSwap("b", 3, 4) {
+		t.Error("CompareAndSwap disagrees with sync.Map")
+	}
+	if m.CompareAndDelete("c", 0) || m.CompareAndDelete("b", 3) || !m.CompareAndDelete("b", 4) {
+		t.Error("CompareAndDelete disagrees with sync.Map")
+	}
+	m.Store("c", 5)
+	if v, loaded := m.LoadAndDelete("c"); v != 5 || !loaded {
+		t.Errorf("LoadAndDelete(c) = %d, %v; want 5, true", v, loaded)
+	}
+	m.Delete("a")
+	for i, k := range []string{"x", "y", "z"} {
+		m.Store(k, i)
+	}
+	var keys []string
+	m.Range(func(k string, v int) bool {
+		keys = append(keys, k)
+		return true
+	})
+	slices.Sort(keys)
+	if want := []string{"x", "y", "z"}; !slices.Equal(keys, want) {
+		t.Errorf("Range visited %v, want %v", keys, want)
+	}
+	visits := 0
+	m.Range(func(string, int) bool { visits++; return false })
+	if visits != 1 {
+		t.Errorf("Range visited %d keys after f returned false, want 1", visits)
+	}
+
+	var errs Map[int, error]
+	errs.Store(1, nil)
+	if err, ok := errs.Load(1); err != nil || !ok {
+		t.Errorf("Load of a nil value = %v, %v; want nil, true", err, ok)
+	}
+}
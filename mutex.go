@@ -7,67 +7,71 @@ import (
 )
 
 // Mutex is a deterministic mutual exclusion lock.
+//
+// The zero value is an unlocked mutex. It must not be copied after first
+// use.
 type Mutex struct {
-	mu *scheduler.Mutex
+	mu lazy[scheduler.Mutex]
+}
+
+// lazy returns the scheduler mutex, creating it on first use.
+func (m *Mutex) lazy() *scheduler.Mutex {
+	return m.mu.get("Mutex", scheduler.NewMutex)
 }
 
 // Lock locks the mutex.
 func (m *Mutex) Lock() {
-	if m.mu == nil {
-		m.mu = scheduler.NewMutex()
-	}
-	m.mu.Lock()
+	m.lazy().Lock()
 }
 
 // Unlock unlocks the mutex.
 func (m *Mutex) Unlock() {
-	if m.mu == nil {
+	if !m.mu.used() {
 		panic("unlock of unlocked mutex")
 	}
-	m.mu.Unlock()
+	m.lazy().Unlock()
 }
 
 // TryLock tries to lock the mutex and returns true if successful.
 func (m *Mutex) TryLock() bool {
-	if m.mu == nil {
-		m.mu = scheduler.NewMutex()
-	}
-	return m.mu.TryLock()
+	return m.lazy().TryLock()
 }
 
 // RWMutex is a deterministic reader/writer mutual exclusion lock.
+//
+// The zero value is an unlocked mutex. It must not be copied after first
+// use.
 type RWMutex struct {
-	mu *scheduler.RWMutex
+	mu lazy[scheduler.RWMutex]
+}
+
+// lazy returns the scheduler mutex, creating it on first use.
+func (rw *RWMutex) lazy() *scheduler.RWMutex {
+	return rw.mu.get("RWMutex", scheduler.NewRWMutex)
 }
 
 // Lock locks the mutex for writing.
 func (rw *RWMutex) Lock() {
-	if rw.mu == nil {
-		rw.mu = scheduler.NewRWMutex()
-	}
-	rw.mu.Lock()
+	rw.lazy().Lock()
 }
 
 // Unlock unlocks the mutex for writing.
 func (rw *RWMutex) Unlock() {
-	if rw.mu == nil {
+	if !rw.mu.used() {
 		panic("unlock of unlocked mutex")
 	}
-	rw.mu.Unlock()
+	rw.lazy().Unlock()
 }
 
 // RLock locks the mutex for reading.
 func (rw *RWMutex) RLock() {
-	if rw.mu == nil {
-		rw.mu = scheduler.NewRWMutex()
-	}
-	rw.mu.RLock()
+	rw.lazy().RLock()
 }
 
 // RUnlock unlocks the mutex for reading.
 func (rw *RWMutex) RUnlock() {
-	if rw.mu == nil {
+	if !rw.mu.used() {
 		panic("runlock of unlocked mutex")
 	}
-	rw.mu.RUnlock()
-}
\ No newline at end of file
+	rw.lazy().RUnlock()
+}
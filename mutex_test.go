@@ -0,0 +1,51 @@
+//go:build detsched
+
+package weft
+
+import "testing"
+
+// TestMutexCopyPanics verifies that a mutex copied after first use panics
+// when used, while one copied before first use is independent.
+func TestMutexCopyPanics(t *testing.T) {
+	// Copy field by field, as an assignment would, without tripping vet.
+	var fresh, before Mutex
+	before.mu.p.Store(fresh.mu.p.Load())
+	before.Lock()
+	before.Unlock()
+
+	var mu Mutex
+	mu.Lock()
+	mu.Unlock()
+	var after Mutex
+	after.mu.p.Store(mu.mu.p.Load())
+	defer func() {
+		if r := recover(); r != "weft: Mutex copied after first use" {
+			t.Errorf("recover() = %v, want the copy panic", r)
+		}
+	}()
+	after.Lock()
+}
+
+// TestMutexZeroValue verifies that tasks using a zero-value mutex for the
+// first time at once share a single mutex. Run with -race.
+func TestMutexZeroValue(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		s := NewScheduler(uint64(i))
+		var mu Mutex
+		var rw RWMutex
+		n := 0
+		for j := 0; j < 8; j++ {
+			s.Go(func(Context) {
+				mu.Lock()
+				n++
+				mu.Unlock()
+				rw.RLock()
+				rw.RUnlock()
+			})
+		}
+		s.Wait()
+		if n != 8 {
+			t.Fatalf("n = %d, want 8", n)
+		}
+	}
+}
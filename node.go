@@ -0,0 +1,110 @@
+package weft
+
+import "time"
+
+// Node is a group of tasks that crash and restart together, like the
+// processes of one machine. Crashing a node kills its tasks wherever they
+// are: each stops at its next scheduling point, and the state they held in
+// memory is gone. Restarting it runs the node's start function again on a
+// fresh task, which recovers from whatever the node kept durably, such as
+// its weftfs files.
+//
+// Where in the node's work a crash lands is up to the schedule: call Crash
+// or Restart from a task of its own, or use CrashAfter, and the scheduler
+// explores crashes at every point.
+//
+// In builds without the detsched tag, tasks cannot be killed: the tasks of
+// a crashed node are only told so through the Done channel of their
+// Context.
+type Node struct {
+	s     *Scheduler
+	name  string
+	start func(*Node)
+
+	mu          Mutex
+	group       *taskGroup
+	crashed     bool
+	incarnation int
+}
+
+// StartNode starts a node called name by running start on a new task of
+// the node. start, and the tasks it spawns with Node.Go, make up the node.
+//
+//	db := s.StartNode("db", func(n *weft.Node) {
+//		store := recoverStore(fsys)
+//		n.Go(func(weft.Context) { serve(store) })
+//	})
+//	s.Go(func(weft.Context) { db.Restart() })
+func (s *Scheduler) StartNode(name string, start func(n *Node)) *Node {
+	// A node is started as if restarted from a crash before it began.
+	n := &Node{s: s, name: name, start: start, crashed: true}
+	n.Restart()
+	return n
+}
+
+// Name returns the name of the node.
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Incarnation returns the number of times the node has been started: 1
+// until it is first restarted.
+func (n *Node) Incarnation() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.incarnation
+}
+
+// Crashed reports whether the node has crashed and not been restarted.
+func (n *Node) Crashed() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.crashed
+}
+
+// Go spawns a task belonging to the node. It does nothing if the node has
+// crashed: a dead process starts nothing.
+func (n *Node) Go(fn func(Context)) {
+	n.mu.Lock()
+	g := n.group
+	n.mu.Unlock()
+	n.s.goIn(g, fn)
+}
+
+// Crash kills the node's tasks. It does nothing if the node has already
+// crashed.
+func (n *Node) Crash() {
+	n.mu.Lock()
+	if n.crashed {
+		n.mu.Unlock()
+		return
+	}
+	n.crashed = true
+	g := n.group
+	n.mu.Unlock()
+	n.s.kill(g)
+}
+
+// CrashAfter crashes the node once d of virtual time has passed.
+func (n *Node) CrashAfter(d time.Duration) {
+	n.s.Go(func(Context) {
+		n.s.Sleep(d)
+		n.Crash()
+	})
+}
+
+// Restart crashes the node, unless it has crashed already, and starts it
+// again by running its start function on a new task. A node can restart
+// itself: the new incarnation starts before the calling task is killed.
+func (n *Node) Restart() {
+	n.mu.Lock()
+	old, crashed := n.group, n.crashed
+	g := n.s.newGroup(n.name)
+	n.group, n.crashed = g, false
+	n.incarnation++
+	n.mu.Unlock()
+	n.s.goIn(g, func(Context) { n.start(n) })
+	if !crashed {
+		n.s.kill(old)
+	}
+}
@@ -0,0 +1,71 @@
+//go:build detsched
+
+package weft
+
+import "testing"
+
+// TestNodeCrashKills verifies that a crashed node's tasks stop at their
+// next scheduling point and are no longer waited for.
+func TestNodeCrashKills(t *testing.T) {
+	s := NewScheduler(1)
+	wake := MakeChan[struct{}](1)
+	exited := make(chan struct{})
+	var mu Mutex
+	var after bool
+	n := s.StartNode("db", func(n *Node) {
+		n.Go(func(Context) {
+			defer close(exited)
+			wake.Recv()
+			mu.Lock()
+			after = true
+			mu.Unlock()
+		})
+	})
+	s.RunUntil(func() bool { return false })
+	n.Crash()
+	s.Wait()
+
+	// The killed task wakes, but dies at its next scheduling point.
+	wake.TrySend(struct{}{})
+	<-exited
+	mu.Lock()
+	defer mu.Unlock()
+	if after {
+		t.Error("killed task ran past a scheduling point")
+	}
+}
+
+// TestNodeCrashCancelsChildren verifies that crashing a node cancels the
+// contexts of the tasks its tasks spawned with Go, but not GoDetached.
+func TestNodeCrashCancelsChildren(t *testing.T) {
+	s := NewScheduler(1)
+	var child, detached Context
+	never := NewBlocker("never")
+	n := s.StartNode("web", func(n *Node) {
+		n.Go(func(ctx Context) {
+			s.Go(func(ctx Context) {
+				child = ctx
+				never.Waiter().Park()
+			})
+			s.GoDetached(func(ctx Context) {
+				detached = ctx
+				never.Waiter().Park()
+			})
+			never.Waiter().Park()
+		})
+	})
+	s.RunUntil(func() bool { return false })
+	n.Crash()
+	select {
+	case <-child.Done():
+	default:
+		t.Error("child task not canceled with the node")
+	}
+	select {
+	case <-detached.Done():
+		t.Error("detached task canceled with the node")
+	default:
+	}
+	never.UnparkAll()
+	s.Wait()
+}
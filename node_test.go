@@ -0,0 +1,47 @@
+package weft
+
+import "testing"
+
+// TestNodeRestart verifies that restarting a node tells its tasks through
+// their Context and runs its start function again.
+func TestNodeRestart(t *testing.T) {
+	s := NewScheduler(1)
+	started := MakeChan[int](2)
+	crashed := MakeChan[struct{}](0)
+	var incarnation int
+	n := s.StartNode("db", func(n *Node) {
+		incarnation++
+		i := incarnation
+		n.Go(func(ctx Context) {
+			started.Send(i)
+			if i == 1 {
+				<-ctx.Done()
+				crashed.Close()
+			}
+		})
+	})
+	if i, _ := started.Recv(); i != 1 {
+		t.Fatalf("first start saw incarnation %d, want 1", i)
+	}
+	n.Restart()
+	crashed.Recv()
+	if i, _ := started.Recv(); i != 2 {
+		t.Errorf("restart saw incarnation %d, want 2", i)
+	}
+	if n.Incarnation() != 2 || n.Crashed() {
+		t.Errorf("Incarnation() = %d, Crashed() = %v; want 2, false", n.Incarnation(), n.Crashed())
+	}
+
+	n.Crash()
+	if !n.Crashed() {
+		t.Error("Crashed() = false after Crash")
+	}
+	ran := make(chan struct{}, 1)
+	n.Go(func(Context) { ran <- struct{}{} })
+	s.Wait()
+	select {
+	case <-ran:
+		t.Error("Go on a crashed node started a task")
+	default:
+	}
+}
@@ -0,0 +1,125 @@
+//go:build detsched
+
+package weft
+
+import "sync/atomic"
+
+// Once is an object that will perform exactly one action. A task calling
+// Do while another runs the action blocks on a weft mutex until it
+// returns, so the schedule decides which of the tasks racing to initialize
+// runs it and when the others see it done.
+//
+// The zero value is ready to use. A Once must not be copied after first
+// use.
+type Once struct {
+	done atomic.Bool
+	m    Mutex
+}
+
+// Do calls f if and only if Do is being called for the first time for
+// this instance of Once. No call to Do returns until the one call to f
+// returns; if f panics, Do considers it to have returned.
+func (o *Once) Do(f func()) {
+	if o.done.Load() {
+		return
+	}
+	o.doSlow(f)
+}
+
+// doSlow runs f under the mutex unless a task that held it first did.
+func (o *Once) doSlow(f func()) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	if !o.done.Load() {
+		defer o.done.Store(true)
+		f()
+	}
+}
+
+// OnceFunc returns a function that invokes f only once, on a Once. If f
+// panics, the returned function panics with the same value on every call.
+func OnceFunc(f func()) func() {
+	var (
+		once  Once
+		valid bool
+		p     any
+	)
+	g := func() {
+		defer func() {
+			p = recover()
+			if !valid {
+				panic(p)
+			}
+		}()
+		f()
+		f = nil
+		valid = true
+	}
+	return func() {
+		once.Do(g)
+		if !valid {
+			panic(p)
+		}
+	}
+}
+
+// OnceValue returns a function that invokes f only once, on a Once, and
+// returns the value returned by f. If f panics, the returned function
+// panics with the same value on every call.
+func OnceValue[T any](f func() T) func() T {
+	var (
+		once   Once
+		valid  bool
+		p      any
+		result T
+	)
+	g := func() {
+		defer func() {
+			p = recover()
+			if !valid {
+				panic(p)
+			}
+		}()
+		result = f()
+		f = nil
+		valid = true
+	}
+	return func() T {
+		once.Do(g)
+		if !valid {
+			panic(p)
+		}
+		return result
+	}
+}
+
+// OnceValues returns a function that invokes f only once, on a Once, and
+// returns the values returned by f. If f panics, the returned function
+// panics with the same value on every call.
+func OnceValues[T1, T2 any](f func() (T1, T2)) func() (T1, T2) {
+	var (
+		once  Once
+		valid bool
+		p     any
+		r1    T1
+		r2    T2
+	)
+	g := func() {
+		defer func() {
+			p = recover()
+			if !valid {
+				panic(p)
+			}
+		}()
+		r1, r2 = f()
+		f = nil
+		valid = true
+	}
+	return func() (T1, T2) {
+		once.Do(g)
+		if !valid {
+			panic(p)
+		}
+		return r1, r2
+	}
+}
@@ -0,0 +1,38 @@
+//go:build detsched
+
+package weft
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestOnceRace verifies that tasks racing to initialize through a Once all
+// wait for the one the schedule lets run the action, and that seeds differ
+// in which task that is.
+func TestOnceRace(t *testing.T) {
+	winners := make(map[string]bool)
+	for seed := uint64(1); seed <= 20; seed++ {
+		s := NewScheduler(seed)
+		var once Once
+		winner, ready := -1, false
+		for i := range 3 {
+			s.Go(func(Context) {
+				once.Do(func() {
+					winner = i
+					Sleep(time.Second)
+					ready = true
+				})
+				if !ready {
+					t.Errorf("seed %d: task %d returned from Do before the action did", seed, i)
+				}
+			})
+		}
+		s.Wait()
+		winners[fmt.Sprint(winner)] = true
+	}
+	if len(winners) < 2 {
+		t.Errorf("20 seeds all ran the action on task %v", winners)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !detsched
+
+package weft
+
+import "sync"
+
+// Once is a standard sync.Once in production mode.
+type Once struct {
+	sync.Once
+}
+
+// OnceFunc is sync.OnceFunc in production mode.
+func OnceFunc(f func()) func() {
+	return sync.OnceFunc(f)
+}
+
+// OnceValue is sync.OnceValue in production mode.
+func OnceValue[T any](f func() T) func() T {
+	return sync.OnceValue(f)
+}
+
+// OnceValues is sync.OnceValues in production mode.
+func OnceValues[T1, T2 any](f func() (T1, T2)) func() (T1, T2) {
+	return sync.OnceValues(f)
+}
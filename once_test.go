@@ -0,0 +1,48 @@
+package weft
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestOnceValues verifies that the Once helpers call their function once,
+// return its results on every call, and repeat its panic, in both build
+// modes.
+func TestOnceValues(t *testing.T) {
+	calls := 0
+	var once Once
+	for range 3 {
+		once.Do(func() { calls++ })
+	}
+	f := OnceFunc(func() { calls++ })
+	f()
+	f()
+	v := OnceValue(func() int { calls++; return 42 })
+	if a, b := v(), v(); a != 42 || b != 42 {
+		t.Errorf("OnceValue returned %d, then %d; want 42 both times", a, b)
+	}
+	errBoom := errors.New("boom")
+	vs := OnceValues(func() (int, error) { calls++; return 7, errBoom })
+	vs()
+	if n, err := vs(); n != 7 || err != errBoom {
+		t.Errorf("OnceValues returned %d, %v; want 7, %v", n, err, errBoom)
+	}
+	if calls != 4 {
+		t.Errorf("functions called %d times, want once each, 4", calls)
+	}
+
+	p := OnceFunc(func() { calls++; panic("init failed") })
+	for range 2 {
+		func() {
+			defer func() {
+				if r := recover(); r != "init failed" {
+					t.Errorf("recover() = %v, want the function's panic", r)
+				}
+			}()
+			p()
+		}()
+	}
+	if calls != 5 {
+		t.Errorf("panicking function called %d times, want once", calls-4)
+	}
+}
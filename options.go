@@ -0,0 +1,43 @@
+package weft
+
+import "github.com/mziter/weft/trace"
+
+// Options configures a scheduler made by NewSchedulerWithOptions. The zero
+// Options configures it as NewScheduler does. In production mode, where
+// the Go runtime schedules, options are ignored.
+type Options struct {
+	// Replay holds scheduling decisions, as returned by Choices, made in
+	// place of the seed's until they run out, as by NewReplayScheduler.
+	Replay []int
+
+	// MaxSteps, if positive, bounds the scheduling points the tasks of a
+	// run pass in all. The task passing one more panics with ErrStepLimit,
+	// ending a run that livelocks rather than letting it spin forever.
+	MaxSteps int
+
+	// NoEvents turns off the holding of trace events, for runs whose
+	// trace is not needed; Events then returns none. Stream and Observer
+	// still receive them.
+	NoEvents bool
+
+	// EventMemory bounds the memory events take, as SetEventMemory does.
+	EventMemory int
+
+	// Stream, if set, receives each event as it is recorded, as with
+	// StreamEvents.
+	Stream *trace.Writer
+
+	// Chaos injects faults into the tasks, as SetChaos does, if its Rate
+	// is positive.
+	Chaos Chaos
+
+	// Observer, if set, is called with each event as it is recorded. It
+	// runs with the scheduler locked, so it must not call the scheduler.
+	Observer func(trace.Event)
+
+	// StrictPriority makes every scheduling decision choose among the
+	// runnable tasks of highest priority only, as a strict priority
+	// scheduler would; see Context.SetPriority. Without it, priorities are
+	// recorded but every runnable task is a candidate.
+	StrictPriority bool
+}
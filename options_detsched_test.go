@@ -0,0 +1,93 @@
+//go:build detsched
+
+package weft
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TestOptionsMaxSteps verifies that a task taking a scheduler past
+// MaxSteps panics with ErrStepLimit, and that Wait reports it.
+func TestOptionsMaxSteps(t *testing.T) {
+	s := NewSchedulerWithOptions(1, Options{MaxSteps: 100})
+	var mu Mutex
+	var recovered any
+	steps := 0
+	s.Go(func(Context) {
+		defer func() { recovered = recover() }()
+		for {
+			mu.Lock()
+			mu.Unlock()
+			steps++
+		}
+	})
+	err := s.Wait()
+	if recovered != ErrStepLimit || steps != 100 {
+		t.Errorf("task recovered %v after %d steps, want ErrStepLimit after 100", recovered, steps)
+	}
+	if !errors.Is(err, ErrStepLimit) {
+		t.Errorf("Wait() = %v, want ErrStepLimit", err)
+	}
+}
+
+// TestOptionsEvents verifies that an observer sees each event, with or
+// without the scheduler holding them, and that Replay replays.
+func TestOptionsEvents(t *testing.T) {
+	for _, noEvents := range []bool{false, true} {
+		var observed []trace.Kind
+		s := NewSchedulerWithOptions(1, Options{
+			NoEvents: noEvents,
+			Observer: func(ev trace.Event) { observed = append(observed, ev.Kind) },
+			Replay:   []int{3},
+		})
+		s.Go(func(Context) {})
+		s.Wait()
+		if want := []trace.Kind{trace.Spawn, trace.Run, trace.Exit}; !slices.Equal(observed, want) {
+			t.Errorf("NoEvents %v: observed %v, want %v", noEvents, observed, want)
+		}
+		if got := len(s.Events()); (got == 0) != noEvents {
+			t.Errorf("NoEvents %v: %d events held", noEvents, got)
+		}
+		if c := s.Choose(10); c != 3 {
+			t.Errorf("Choose(10) = %d, want the replayed 3", c)
+		}
+	}
+}
+
+// TestChance verifies that Chance makes no decision for a certain outcome,
+// and that decision 0 never makes an uncertain one happen.
+func TestChance(t *testing.T) {
+	s := NewReplayScheduler(1, []int{0, chanceResolution - 1})
+	if s.Chance(0) || !s.Chance(1) || len(s.Choices()) != 0 {
+		t.Errorf("Chance(0), Chance(1) made decisions %v", s.Choices())
+	}
+	if s.Chance(0.999) {
+		t.Error("Chance(0.999) happened on decision 0")
+	}
+	if !s.Chance(0.001) {
+		t.Errorf("Chance(0.001) did not happen on decision %d", chanceResolution-1)
+	}
+}
+
+// TestSetPriority verifies that a task's priority is recorded when it sets
+// it.
+func TestSetPriority(t *testing.T) {
+	s := NewSchedulerWithOptions(1, Options{StrictPriority: true})
+	s.Go(func(ctx Context) {
+		ctx.SetPriority(5)
+	})
+	s.Wait()
+	var got []string
+	for _, ev := range s.Events() {
+		if ev.Kind == trace.Priority {
+			got = append(got, ev.String())
+		}
+	}
+	if want := []string{"task 1 priority 5"}; !slices.Equal(got, want) {
+		t.Errorf("priority events = %q, want %q", got, want)
+	}
+}
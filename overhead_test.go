@@ -0,0 +1,81 @@
+//go:build !detsched
+
+package weft
+
+import (
+	"os/exec"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestInlined verifies that the production wrappers inline into their
+// callers, leaving only the standard library call they wrap. Spawning a
+// goroutine cannot be inlined, but costs only the go statement it wraps.
+func TestInlined(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles the package")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(gobin, "test", "-c", "-o", "/dev/null", "-gcflags=-m", ".").CombinedOutput()
+	if err != nil {
+		t.Fatalf("go test -c -gcflags=-m: %v\n%s", err, out)
+	}
+	for _, fn := range []string{
+		`MakeChan\[go\.shape\.int\]`,
+		`Chan\[go\.shape\.int\]\.Send`,
+		`Chan\[go\.shape\.int\]\.Recv`,
+		`Chan\[go\.shape\.int\]\.TrySend`,
+		`Chan\[go\.shape\.int\]\.TryRecv`,
+		`Chan\[go\.shape\.int\]\.Close`,
+		`\(\*Mutex\)\.TryLock`,
+		`NewCond`,
+		`OnceFunc`,
+		`OnceValue\[go\.shape\.int\]`,
+		`\(\*Map\[string,int\]\)\.Load`,
+		`\(\*Map\[go\.shape\.string,go\.shape\.int\]\)\.Store`,
+		`Sleep`,
+		`\(\*Scheduler\)\.Sleep`,
+		`After`,
+		`\(\*Scheduler\)\.After`,
+		`Now`,
+		`\(\*Scheduler\)\.Now`,
+	} {
+		if !regexp.MustCompile(`_notag\.go:\d+:\d+: can inline ` + fn + `\n`).Match(out) {
+			t.Errorf("%s does not inline", fn)
+		}
+	}
+}
+
+// TestZeroAllocs verifies that the production wrappers allocate no more
+// than the primitives they wrap.
+func TestZeroAllocs(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		weft, raw func()
+	}{
+		{
+			"Mutex",
+			func() { var mu Mutex; mu.Lock(); mu.Unlock() },
+			func() { var mu sync.Mutex; mu.Lock(); mu.Unlock() },
+		},
+		{
+			"Chan",
+			func() { c := MakeChan[int](1); c.Send(1); c.Recv() },
+			func() { c := make(chan int, 1); c <- 1; <-c },
+		},
+		{
+			"Sleep",
+			func() { Sleep(0) },
+			func() { time.Sleep(0) },
+		},
+	} {
+		if w, r := testing.AllocsPerRun(100, tt.weft), testing.AllocsPerRun(100, tt.raw); w > r {
+			t.Errorf("%s allocates %v times, the standard library %v", tt.name, w, r)
+		}
+	}
+}
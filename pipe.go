@@ -0,0 +1,214 @@
+//go:build detsched
+
+package weft
+
+import (
+	"io"
+
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// Pipe creates a synchronous in-memory pipe, like io.Pipe, whose reads and
+// writes block on the scheduler, so a reader and writer on different tasks
+// are explored like any other communication and a pipe nobody drains is
+// reported as a deadlock.
+//
+// Each Write blocks until one or more Reads have consumed all of its data;
+// there is no internal buffering. Parallel Reads and Writes are served
+// sequentially.
+func Pipe() (*PipeReader, *PipeWriter) {
+	p := &pipe{
+		wrCh: MakeChan[[]byte](0),
+		rdCh: MakeChan[int](0),
+		done: MakeChan[struct{}](0),
+	}
+	return &PipeReader{p}, &PipeWriter{p}
+}
+
+// pipe is the shared state of a pipe, as in io.Pipe.
+type pipe struct {
+	// wrMu serializes writes.
+	wrMu Mutex
+	wrCh Chan[[]byte]
+	rdCh Chan[int]
+
+	// mu guards closed, rerr and werr; done is closed with the pipe.
+	mu         Mutex
+	closed     bool
+	rerr, werr error
+	done       Chan[struct{}]
+}
+
+func (p *pipe) read(b []byte) (int, error) {
+	if p.isClosed() {
+		return 0, p.readCloseError()
+	}
+	wr := OnRecv(p.wrCh)
+	if Select(wr, OnRecv(p.done)) == 1 {
+		return 0, p.readCloseError()
+	}
+	bw, _ := wr.Value()
+	n := copy(b, bw)
+	p.rdCh.Send(n)
+	return n, nil
+}
+
+func (p *pipe) write(b []byte) (n int, err error) {
+	if p.isClosed() {
+		return 0, p.writeCloseError()
+	}
+	p.wrMu.Lock()
+	defer p.wrMu.Unlock()
+	for once := true; once || len(b) > 0; once = false {
+		if Select(OnSend(p.wrCh, b), OnRecv(p.done)) == 1 {
+			return n, p.writeCloseError()
+		}
+		nw, _ := p.rdCh.Recv()
+		b = b[nw:]
+		n += nw
+	}
+	return n, nil
+}
+
+func (p *pipe) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// close closes the pipe, recording err in *side, the error of the half
+// being closed, unless that half was closed before.
+func (p *pipe) close(side *error, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if *side == nil {
+		*side = err
+	}
+	if !p.closed {
+		p.closed = true
+		p.done.Close()
+	}
+}
+
+func (p *pipe) readCloseError() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rerr == nil && p.werr != nil {
+		return p.werr
+	}
+	return io.ErrClosedPipe
+}
+
+func (p *pipe) writeCloseError() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.werr == nil && p.rerr != nil {
+		return p.rerr
+	}
+	return io.ErrClosedPipe
+}
+
+// PipeReader is the read half of a pipe.
+type PipeReader struct{ p *pipe }
+
+// Read reads data from the pipe, blocking until a writer arrives or the
+// write end is closed. If the write end is closed with an error, that error
+// is returned; otherwise the error is io.EOF.
+func (r *PipeReader) Read(data []byte) (int, error) {
+	return r.p.read(data)
+}
+
+// Close closes the reader; subsequent writes to the write half of the pipe
+// return io.ErrClosedPipe.
+func (r *PipeReader) Close() error {
+	return r.CloseWithError(nil)
+}
+
+// CloseWithError closes the reader; subsequent writes to the write half of
+// the pipe return err, or io.ErrClosedPipe if err is nil. It never
+// overwrites a previous error and always returns nil.
+func (r *PipeReader) CloseWithError(err error) error {
+	if err == nil {
+		err = io.ErrClosedPipe
+	}
+	r.p.close(&r.p.rerr, err)
+	return nil
+}
+
+// PipeWriter is the write half of a pipe.
+type PipeWriter struct{ p *pipe }
+
+// Write writes data to the pipe, blocking until one or more readers have
+// consumed all of it or the read end is closed. If the read end is closed
+// with an error, that error is returned; otherwise the error is
+// io.ErrClosedPipe.
+func (w *PipeWriter) Write(data []byte) (int, error) {
+	return w.p.write(data)
+}
+
+// Close closes the writer; subsequent reads from the read half of the pipe
+// return no bytes and io.EOF.
+func (w *PipeWriter) Close() error {
+	return w.CloseWithError(nil)
+}
+
+// CloseWithError closes the writer; subsequent reads from the read half of
+// the pipe return no bytes and err, or io.EOF if err is nil. It never
+// overwrites a previous error and always returns nil.
+func (w *PipeWriter) CloseWithError(err error) error {
+	if err == nil {
+		err = io.EOF
+	}
+	w.p.close(&w.p.werr, err)
+	return nil
+}
+
+// NewReader returns a Reader whose reads block on the scheduler: each Read
+// of r runs aside while the calling task waits for it on a channel. Wrap
+// readers that block outside weft's view, such as an os.Pipe or a stream
+// from a real network, so that a task stuck reading one takes part in
+// exploration, and the tasks waiting for it are not taken for deadlocked.
+func NewReader(r io.Reader) io.Reader {
+	return &reader{r}
+}
+
+type reader struct{ r io.Reader }
+
+func (r *reader) Read(p []byte) (int, error) {
+	return await(func() (int, error) { return r.r.Read(p) })
+}
+
+// NewWriter returns a Writer whose writes block on the scheduler, as
+// NewReader does for reads.
+func NewWriter(w io.Writer) io.Writer {
+	return &writer{w}
+}
+
+type writer struct{ w io.Writer }
+
+func (w *writer) Write(p []byte) (int, error) {
+	return await(func() (int, error) { return w.w.Write(p) })
+}
+
+// ioResult is the result of an I/O call.
+type ioResult struct {
+	n   int
+	err error
+}
+
+// await runs f on a goroutine of its own, aside from the scheduler of the
+// calling task, if any, and waits on a channel for its result.
+func await(f func() (int, error)) (int, error) {
+	done := MakeChan[ioResult](1)
+	aside := func() {}
+	if s := scheduler.Current(); s != nil {
+		aside = s.Aside()
+	}
+	go func() {
+		defer aside()
+		n, err := f()
+		done.Send(ioResult{n, err})
+	}()
+	res, _ := done.Recv()
+	return res.n, res.err
+}
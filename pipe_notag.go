@@ -0,0 +1,26 @@
+//go:build !detsched
+
+package weft
+
+import "io"
+
+// PipeReader is io.PipeReader in production mode.
+type PipeReader = io.PipeReader
+
+// PipeWriter is io.PipeWriter in production mode.
+type PipeWriter = io.PipeWriter
+
+// Pipe delegates to io.Pipe in production mode.
+func Pipe() (*PipeReader, *PipeWriter) {
+	return io.Pipe()
+}
+
+// NewReader returns r itself in production mode.
+func NewReader(r io.Reader) io.Reader {
+	return r
+}
+
+// NewWriter returns w itself in production mode.
+func NewWriter(w io.Writer) io.Writer {
+	return w
+}
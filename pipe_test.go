@@ -0,0 +1,69 @@
+package weft
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestPipe verifies that a write is consumed by several reads and that
+// closing the write end ends the reads.
+func TestPipe(t *testing.T) {
+	s := NewScheduler(1)
+	r, w := Pipe()
+	written := MakeChan[error](1)
+	s.Go(func(Context) {
+		_, err := io.WriteString(w, "hello, world")
+		w.Close()
+		written.Send(err)
+	})
+
+	var got []string
+	buf := make([]byte, 5)
+	for {
+		n, err := r.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	if err, _ := written.Recv(); err != nil {
+		t.Errorf("Write() error = %v", err)
+	}
+	if strings.Join(got, "|") != "hello|, wor|ld" {
+		t.Errorf("reads = %q, want hello|, wor|ld", got)
+	}
+}
+
+// TestPipeCloseWithError verifies that closing one end with an error fails
+// the other end's operations with it.
+func TestPipeCloseWithError(t *testing.T) {
+	errBroken := errors.New("broken")
+	r, w := Pipe()
+	w.CloseWithError(errBroken)
+	if _, err := r.Read(make([]byte, 1)); err != errBroken {
+		t.Errorf("Read() error = %v, want %v", err, errBroken)
+	}
+
+	r, w = Pipe()
+	r.Close()
+	if _, err := w.Write([]byte("x")); err != io.ErrClosedPipe {
+		t.Errorf("Write() error = %v, want io.ErrClosedPipe", err)
+	}
+}
+
+// TestNewReader verifies that a wrapped reader and writer pass data through.
+func TestNewReader(t *testing.T) {
+	b, err := io.ReadAll(NewReader(strings.NewReader("stream")))
+	if err != nil || string(b) != "stream" {
+		t.Errorf("ReadAll() = %q, %v; want %q", b, err, "stream")
+	}
+	var sb strings.Builder
+	if _, err := io.WriteString(NewWriter(&sb), "stream"); err != nil || sb.String() != "stream" {
+		t.Errorf("WriteString() = %q, %v; want %q", sb.String(), err, "stream")
+	}
+}
@@ -0,0 +1,80 @@
+package weft
+
+import (
+	"context"
+	"os"
+	"slices"
+	"syscall"
+)
+
+// MainFunc is the entry point of a whole program, as run by Main and
+// StartProgram: the body of its func main, given the command-line
+// arguments after the program name and a context canceled when the program
+// is asked to shut down, and returning its exit code. It should parse args
+// with a flag.FlagSet of its own, since under test os.Args and
+// flag.CommandLine are the test binary's.
+type MainFunc func(ctx context.Context, args []string) int
+
+// Main runs main as the program, from its func main, and exits with the
+// code main returns. main's context is canceled when the process receives
+// SIGINT or SIGTERM. Tests run the same main with StartProgram.
+//
+//	func main() { weft.Main(server.Main) }
+func Main(main MainFunc) {
+	s := NewScheduler(0)
+	// The binding lasts as long as the process, as its goroutines do.
+	s.Bind()
+	os.Exit(s.StartProgram(os.Args[1:], main).Wait())
+}
+
+// Program is a program started by StartProgram.
+type Program struct {
+	stop context.CancelFunc
+	done Chan[struct{}]
+
+	// code is set before done is closed.
+	code int
+}
+
+// StartProgram runs main as a whole program, with args as its command-line
+// arguments after the program name, on a new task of s, so that a test can
+// run a service binary's flag parsing, servers and shutdown as they run in
+// production. main's context is canceled by Stop, or by SIGINT or SIGTERM
+// raised with RaiseSignal, as Main's is by the signals the process
+// receives. The tasks main starts are its children, canceled with it.
+//
+//	p := s.StartProgram([]string{"-addr", ":8080"}, server.Main)
+//	s.Go(func(weft.Context) {
+//		// ... exercise the server, then shut it down
+//		p.Stop()
+//		if code := p.Wait(); code != 0 {
+//			t.Errorf("server exited with %d", code)
+//		}
+//	})
+func (s *Scheduler) StartProgram(args []string, main MainFunc) *Program {
+	ctx, stop := NotifySignalContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	p := &Program{stop: stop, done: MakeChan[struct{}](0)}
+	args = slices.Clone(args)
+	s.Go(func(Context) {
+		code := 2 // the exit code of a Go program that panics
+		defer func() {
+			p.code = code
+			stop()
+			p.done.Close()
+		}()
+		code = main(ctx, args)
+	})
+	return p
+}
+
+// Stop asks the program to shut down by canceling main's context, as SIGTERM
+// would.
+func (p *Program) Stop() {
+	p.stop()
+}
+
+// Wait waits for main to return and returns its exit code.
+func (p *Program) Wait() int {
+	p.done.Recv()
+	return p.code
+}
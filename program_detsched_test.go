@@ -0,0 +1,22 @@
+//go:build detsched
+
+package weft
+
+import (
+	"syscall"
+	"testing"
+)
+
+// TestProgramSignal verifies that SIGTERM raised with RaiseSignal shuts a
+// program down, as it does a process running Main.
+func TestProgramSignal(t *testing.T) {
+	Run(t, 1, func(s *Scheduler) {
+		p := s.StartProgram([]string{"-code", "4"}, serve)
+		s.Go(func(Context) {
+			RaiseSignal(syscall.SIGTERM)
+			if code := p.Wait(); code != 4 {
+				t.Errorf("Wait() = %d, want 4", code)
+			}
+		})
+	})
+}
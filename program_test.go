@@ -0,0 +1,38 @@
+package weft
+
+import (
+	"context"
+	"flag"
+	"io"
+	"testing"
+)
+
+// serve is the entry point of a program that parses a flag, serves until
+// it is shut down, and exits with the flag's value.
+func serve(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	code := fs.Int("code", 0, "exit code")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	<-ctx.Done()
+	return *code
+}
+
+// TestProgram verifies that a program started with StartProgram gets its
+// arguments, shuts down on Stop and reports its exit code.
+func TestProgram(t *testing.T) {
+	s := NewScheduler(1)
+	p := s.StartProgram([]string{"-code", "3"}, serve)
+	p.Stop()
+	if code := p.Wait(); code != 3 {
+		t.Errorf("Wait() = %d, want 3", code)
+	}
+	s.Wait()
+
+	if code := s.StartProgram([]string{"-bogus"}, serve).Wait(); code != 2 {
+		t.Errorf("Wait() with a bad flag = %d, want 2", code)
+	}
+	s.Wait()
+}
@@ -0,0 +1,115 @@
+//go:build detsched
+
+package weft
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mziter/weft/trace"
+)
+
+// TB is the part of testing.TB that Run uses.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+	Skip(args ...any)
+}
+
+// leakTimeout is how long Run waits, in real time, for the tasks of a test
+// to finish once its function has returned, unless they deadlock first.
+var leakTimeout = time.Minute
+
+// Run runs a single-seed deterministic test without wefttest's exploration:
+// it calls fn with a new scheduler for seed, bound as by Bind, waits for
+// the tasks fn started to finish, and fails t, naming the seed, if fn or one
+// of its tasks panicked, if the tasks deadlocked or were still unfinished a
+// minute after fn returned, listing where they were and which tasks spawned
+// them, or if the run failed otherwise, as reported by Result.
+//
+//	weft.Run(t, 42, func(s *weft.Scheduler) {
+//		s.Go(func(weft.Context) { q.Put(1) })
+//		s.Go(func(weft.Context) { q.Get() })
+//	})
+//
+// Without the detsched tag, Run skips the test.
+func Run(t TB, seed uint64, fn func(s *Scheduler)) {
+	t.Helper()
+	run(t, NewScheduler(seed), seed, fn)
+}
+
+// RunDefault is like Run with seed 0, for code that starts tasks with the
+// package-level Go, Sleep and After rather than a scheduler's methods,
+// which use the scheduler Run binds.
+//
+// Without the detsched tag, RunDefault just calls fn, whose package-level
+// functions are the standard library's.
+func RunDefault(t TB, fn func()) {
+	t.Helper()
+	run(t, NewScheduler(0), 0, func(*Scheduler) { fn() })
+}
+
+// run runs fn on s for Run and RunDefault.
+func run(t TB, s *Scheduler, seed uint64, fn func(s *Scheduler)) {
+	t.Helper()
+	defer s.Bind()()
+	var mu sync.Mutex
+	var panics []string
+	s.sched.SetPanicHandler(func(task int, v any) {
+		mu.Lock()
+		defer mu.Unlock()
+		panics = append(panics, fmt.Sprintf("task %d panicked: %v", task, v))
+	})
+	defer s.sched.SetPanicHandler(nil)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				panics = append(panics, fmt.Sprint("panic: ", r))
+			}
+		}()
+		fn(s)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+	leaked := false
+	select {
+	case <-done:
+	case <-time.After(leakTimeout):
+		leaked = true
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var failures []string
+	failures = append(failures, panics...)
+	deadlocked := !leaked && errors.Is(s.Result(), ErrDeadlock)
+	if leaked || deadlocked {
+		tr := &trace.Trace{Events: s.Events()}
+		live := tr.Live()
+		lines := make([]string, len(live))
+		for i, ev := range live {
+			lines[i] = "\n\t" + tr.Describe(ev)
+		}
+		if deadlocked {
+			failures = append(failures, fmt.Sprintf("deadlock: every unfinished task is blocked:%s", strings.Join(lines, "")))
+		} else {
+			s.Fail(fmt.Errorf("%w: %d tasks after %v", ErrTaskLeak, len(live), leakTimeout))
+			failures = append(failures, fmt.Sprintf("tasks unfinished %v after the test returned:%s", leakTimeout, strings.Join(lines, "")))
+		}
+	}
+	if err := s.Result(); err != nil && len(failures) == 0 {
+		failures = append(failures, err.Error())
+	}
+	if len(failures) > 0 {
+		t.Fatalf("weft: seed %d: %s", seed, strings.Join(failures, "\n"))
+	}
+}
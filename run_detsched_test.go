@@ -0,0 +1,263 @@
+//go:build detsched
+
+package weft
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft/trace"
+)
+
+// failTB records the failure Run reports instead of failing the test.
+type failTB struct {
+	testing.TB
+	failure string
+}
+
+func (f *failTB) Helper() {}
+
+func (f *failTB) Fatalf(format string, args ...any) {
+	f.failure = fmt.Sprintf(format, args...)
+}
+
+// TestRun verifies that Run waits for the tasks of a test, and reports
+// panics, unfinished tasks and failures of the run with the seed.
+func TestRun(t *testing.T) {
+	done := false
+	Run(t, 1, func(s *Scheduler) {
+		s.Go(func(Context) {
+			s.Sleep(time.Second)
+			done = true
+		})
+	})
+	if !done {
+		t.Error("Run returned before its task finished")
+	}
+
+	defer func(d time.Duration) { leakTimeout = d }(leakTimeout)
+	// A task waiting on a Go channel is unfinished, not deadlocked: the
+	// scheduler cannot tell who might wake it.
+	release := make(chan struct{})
+	defer close(release)
+	tests := []struct {
+		name string
+		fn   func(s *Scheduler)
+		want string
+		err  error
+	}{
+		{"task panic", func(s *Scheduler) { s.Go(func(Context) { panic("boom") }) }, "task 1 panicked: boom", nil},
+		{"panic", func(s *Scheduler) { panic("boom") }, "panic: boom", nil},
+		{"unfinished", func(s *Scheduler) { s.Go(func(Context) { <-release }) }, "tasks unfinished", ErrTaskLeak},
+		{"orphan", func(s *Scheduler) {
+			s.Go(func(Context) { s.Go(func(Context) { <-release }) })
+		}, "task 2 run, spawned by test > task 1", ErrTaskLeak},
+		{"nil chan", func(s *Scheduler) {
+			s.Go(func(Context) {
+				var c Chan[int]
+				c.Recv()
+			})
+		}, "deadlock: every unfinished task is blocked:\n\ttask 1 blocked on nil chan", ErrDeadlock},
+		{"blocker", func(s *Scheduler) {
+			s.Go(func(Context) { NewBlocker("semaphore").Waiter().Park() })
+		}, "deadlock: every unfinished task is blocked:\n\ttask 1 blocked on semaphore", ErrDeadlock},
+		{"failed", func(s *Scheduler) {
+			s.Fail(fmt.Errorf("%w: 2 operations", ErrLinearizability))
+		}, "history not linearizable: 2 operations", ErrLinearizability},
+	}
+	for _, tt := range tests {
+		// Wait finds a deadlock at once; only unfinished tasks wait out
+		// leakTimeout.
+		leakTimeout = 50 * time.Millisecond
+		if tt.err == ErrDeadlock {
+			leakTimeout = time.Hour
+		}
+		f := &failTB{TB: t}
+		var sched *Scheduler
+		Run(f, 7, func(s *Scheduler) {
+			sched = s
+			tt.fn(s)
+		})
+		if !strings.HasPrefix(f.failure, "weft: seed 7: ") || !strings.Contains(f.failure, tt.want) {
+			t.Errorf("%s: Run failed with %q, want the seed and %q", tt.name, f.failure, tt.want)
+		}
+		if err := sched.Result(); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Result() = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// TestRunDefault verifies that RunDefault waits for tasks started with the
+// package-level Go.
+func TestRunDefault(t *testing.T) {
+	done := false
+	RunDefault(t, func() {
+		Go(func(Context) {
+			Sleep(time.Second)
+			done = true
+		})
+	})
+	if !done {
+		t.Error("RunDefault returned before its task finished")
+	}
+}
+
+// TestPackageLevel verifies that the package-level Go runs tasks on the
+// scheduler of the current run, nested tasks included, and panics outside
+// a run.
+func TestPackageLevel(t *testing.T) {
+	var sched *Scheduler
+	Run(t, 1, func(s *Scheduler) {
+		sched = s
+		Go(func(Context) {
+			Go(func(Context) { Sleep(time.Second) })
+		})
+	})
+	spawns := 0
+	for _, ev := range sched.Events() {
+		if ev.Kind == trace.Spawn {
+			spawns++
+		}
+	}
+	if spawns != 2 {
+		t.Errorf("run's scheduler recorded %d spawns, want 2", spawns)
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "outside a run") {
+			t.Errorf("Go outside a run panicked with %v, want a panic naming the run", r)
+		}
+	}()
+	Go(func(Context) {})
+}
+
+// TestSpin verifies that Yield, TrySend and TryRecv are scheduling points:
+// a task spinning on one until another task acts lets that task run.
+func TestSpin(t *testing.T) {
+	Run(t, 0, func(s *Scheduler) {
+		done := false
+		Go(func(ctx Context) {
+			for !done {
+				ctx.Yield()
+			}
+		})
+		Go(func(Context) { done = true })
+	})
+	Run(t, 0, func(s *Scheduler) {
+		c := MakeChan[int](1)
+		Go(func(Context) {
+			for {
+				if _, ok := c.TryRecv(); ok {
+					break
+				}
+			}
+		})
+		Go(func(Context) {
+			for !c.TrySend(1) {
+			}
+		})
+	})
+}
+
+// TestNow verifies that Now reads the virtual time of the current run,
+// which a Sleep moves forward by exactly its duration.
+func TestNow(t *testing.T) {
+	var before, after time.Time
+	Run(t, 1, func(s *Scheduler) {
+		Go(func(Context) {
+			before = Now()
+			Sleep(90 * time.Minute)
+			after = Now()
+		})
+	})
+	if want := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC); !before.Equal(want) {
+		t.Errorf("Now() = %v at the start of a run, want %v", before, want)
+	}
+	if d := after.Sub(before); d != 90*time.Minute {
+		t.Errorf("Now() advanced by %v over Sleep(90m), want 1h30m0s", d)
+	}
+}
+
+// TestRunUntil verifies that RunUntil pauses the tasks in the state its
+// condition asks for, and that Wait lets them finish.
+func TestRunUntil(t *testing.T) {
+	s := NewScheduler(1)
+	var mu Mutex
+	phase := 0
+	s.Go(func(Context) {
+		for range 3 {
+			mu.Lock()
+			phase++
+			mu.Unlock()
+		}
+	})
+	get := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return phase
+	}
+	if !s.RunUntil(func() bool { return get() == 2 }) {
+		t.Fatal("RunUntil returned false")
+	}
+	if p := get(); p != 2 {
+		t.Errorf("phase after RunUntil = %d, want 2", p)
+	}
+	s.Wait()
+	if p := get(); p != 3 {
+		t.Errorf("phase after Wait = %d, want 3", p)
+	}
+}
+
+// TestTasks verifies that Tasks describes a task RunUntil left blocked, and
+// lists no task once Wait has returned.
+func TestTasks(t *testing.T) {
+	s := NewScheduler(1)
+	c := MakeChan[int](0)
+	s.Go(func(ctx Context) {
+		ctx.SetPriority(3)
+		c.Recv()
+	})
+	if s.RunUntil(func() bool { return false }) {
+		t.Fatal("RunUntil returned true with its task blocked")
+	}
+	want := Task{ID: 1, State: TaskBlocked, BlockedOn: "chan receive", Priority: 3}
+	if tasks := s.Tasks(); len(tasks) != 1 || tasks[0].ID != want.ID || tasks[0].State != want.State ||
+		tasks[0].BlockedOn != want.BlockedOn || tasks[0].Priority != want.Priority || tasks[0].Steps == 0 {
+		t.Errorf("Tasks() = %+v, want [%+v]", tasks, want)
+	}
+	c.Send(1)
+	s.Wait()
+	if tasks := s.Tasks(); len(tasks) != 0 {
+		t.Errorf("Tasks() after Wait = %+v, want none", tasks)
+	}
+}
+
+// TestPosition verifies that Seed, CurrentStep and Context.TaskID tag a
+// log line with the run's seed, the step of its next event and the task.
+func TestPosition(t *testing.T) {
+	s := NewScheduler(42)
+	if seed := s.Seed(); seed != 42 {
+		t.Errorf("Seed() = %d, want 42", seed)
+	}
+	var id, step int
+	s.Go(func(ctx Context) {
+		id, step = ctx.TaskID(), s.CurrentStep()
+	})
+	s.Wait()
+	if id != 1 {
+		t.Errorf("TaskID() = %d, want 1", id)
+	}
+	if step != 2 {
+		t.Errorf("CurrentStep() in the task = %d, want 2, after its spawn and first turn", step)
+	}
+	if end := s.CurrentStep(); end != len(s.Events()) {
+		t.Errorf("CurrentStep() after Wait = %d, want %d events", end, len(s.Events()))
+	}
+	s.Reset(7)
+	if seed := s.Seed(); seed != 7 {
+		t.Errorf("Seed() after Reset = %d, want 7", seed)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !detsched
+
+package weft
+
+// TB is the part of testing.TB that Run uses.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+	Skip(args ...any)
+}
+
+// Run skips the test in production mode, where there is no deterministic
+// scheduler to run it on.
+func Run(t TB, seed uint64, fn func(s *Scheduler)) {
+	t.Helper()
+	t.Skip("weft.Run needs -tags=detsched")
+}
+
+// RunDefault calls fn in production mode, where the package-level Go,
+// Sleep and After it uses are the standard library's.
+func RunDefault(t TB, fn func()) {
+	fn()
+}
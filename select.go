@@ -0,0 +1,102 @@
+//go:build detsched
+
+package weft
+
+import (
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// SelectCase is a single communication case passed to Select.
+type SelectCase interface {
+	schedCase() scheduler.Case
+}
+
+// RecvCase is a receive case created by OnRecv.
+type RecvCase[T any] struct {
+	c  Chan[T]
+	v  T
+	ok bool
+}
+
+// OnRecv returns a case that receives from c.
+func OnRecv[T any](c Chan[T]) *RecvCase[T] {
+	return &RecvCase[T]{c: c}
+}
+
+// Value returns the value received by the case and whether it was
+// delivered by a send rather than by the channel being closed.
+func (rc *RecvCase[T]) Value() (T, bool) {
+	return rc.v, rc.ok
+}
+
+func (rc *RecvCase[T]) schedCase() scheduler.Case {
+	return scheduler.RecvCase(rc.c.ch, &rc.v, &rc.ok)
+}
+
+// SendCase is a send case created by OnSend.
+type SendCase[T any] struct {
+	c Chan[T]
+	v T
+}
+
+// OnSend returns a case that sends v on c.
+func OnSend[T any](c Chan[T], v T) *SendCase[T] {
+	return &SendCase[T]{c: c, v: v}
+}
+
+func (sc *SendCase[T]) schedCase() scheduler.Case {
+	return scheduler.SendCase(sc.c.ch, sc.v)
+}
+
+// Select blocks until one of the cases can proceed, performs it, and
+// returns its index. With no cases, Select blocks forever.
+func Select(cases ...SelectCase) int {
+	return scheduler.Select(schedCases(cases), true)
+}
+
+// TrySelect is like Select but returns -1 instead of blocking when no case
+// can proceed, mirroring a select statement with a default clause.
+func TrySelect(cases ...SelectCase) int {
+	return scheduler.Select(schedCases(cases), false)
+}
+
+func schedCases(cases []SelectCase) []scheduler.Case {
+	scs := make([]scheduler.Case, len(cases))
+	for i, c := range cases {
+		scs[i] = c.schedCase()
+	}
+	return scs
+}
+
+// Case is a SelectCase on a channel of T, as OnRecv and OnSend return, for
+// Select2, Select3 and Select4.
+type Case[T any] interface {
+	SelectCase
+	op() scheduler.Op[T]
+}
+
+func (rc *RecvCase[T]) op() scheduler.Op[T] {
+	return scheduler.RecvOp(rc.c.ch, &rc.v, &rc.ok)
+}
+
+func (sc *SendCase[T]) op() scheduler.Op[T] {
+	return scheduler.SendOp(sc.c.ch, sc.v)
+}
+
+// Select2 is Select over two cases, as in the default build, where it
+// spares the reflection Select needs for any number of them.
+func Select2[A, B any](a Case[A], b Case[B]) int {
+	return scheduler.Select2(a.op(), b.op())
+}
+
+// Select3 is Select over three cases, as in the default build, where it
+// spares the reflection Select needs for any number of them.
+func Select3[A, B, C any](a Case[A], b Case[B], c Case[C]) int {
+	return scheduler.Select3(a.op(), b.op(), c.op())
+}
+
+// Select4 is Select over four cases, as in the default build, where it
+// spares the reflection Select needs for any number of them.
+func Select4[A, B, C, D any](a Case[A], b Case[B], c Case[C], d Case[D]) int {
+	return scheduler.Select4(a.op(), b.op(), c.op(), d.op())
+}
@@ -0,0 +1,189 @@
+//go:build !detsched
+
+package weft
+
+import "reflect"
+
+// SelectCase is a single communication case passed to Select.
+type SelectCase interface {
+	reflectCase() reflect.SelectCase
+	received(v reflect.Value, ok bool)
+}
+
+// RecvCase is a receive case created by OnRecv.
+type RecvCase[T any] struct {
+	c  Chan[T]
+	v  T
+	ok bool
+}
+
+// OnRecv returns a case that receives from c.
+func OnRecv[T any](c Chan[T]) *RecvCase[T] {
+	return &RecvCase[T]{c: c}
+}
+
+// Value returns the value received by the case and whether it was
+// delivered by a send rather than by the channel being closed.
+func (rc *RecvCase[T]) Value() (T, bool) {
+	return rc.v, rc.ok
+}
+
+func (rc *RecvCase[T]) reflectCase() reflect.SelectCase {
+	return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(rc.c.ch)}
+}
+
+func (rc *RecvCase[T]) received(v reflect.Value, ok bool) {
+	rc.ok = ok
+	if ok {
+		reflect.ValueOf(&rc.v).Elem().Set(v)
+	}
+}
+
+// SendCase is a send case created by OnSend.
+type SendCase[T any] struct {
+	c Chan[T]
+	v T
+}
+
+// OnSend returns a case that sends v on c.
+func OnSend[T any](c Chan[T], v T) *SendCase[T] {
+	return &SendCase[T]{c: c, v: v}
+}
+
+func (sc *SendCase[T]) reflectCase() reflect.SelectCase {
+	return reflect.SelectCase{
+		Dir:  reflect.SelectSend,
+		Chan: reflect.ValueOf(sc.c.ch),
+		Send: reflect.ValueOf(&sc.v).Elem(),
+	}
+}
+
+func (sc *SendCase[T]) received(reflect.Value, bool) {}
+
+// Select blocks until one of the cases can proceed, performs it, and
+// returns its index. With no cases, Select blocks forever.
+func Select(cases ...SelectCase) int {
+	return selectCases(cases, true)
+}
+
+// TrySelect is like Select but returns -1 instead of blocking when no case
+// can proceed, mirroring a select statement with a default clause.
+func TrySelect(cases ...SelectCase) int {
+	return selectCases(cases, false)
+}
+
+func selectCases(cases []SelectCase, block bool) int {
+	rcs := make([]reflect.SelectCase, len(cases), len(cases)+1)
+	for i, c := range cases {
+		rcs[i] = c.reflectCase()
+	}
+	if !block {
+		rcs = append(rcs, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+	chosen, v, ok := reflect.Select(rcs)
+	if chosen == len(cases) {
+		return -1
+	}
+	cases[chosen].received(v, ok)
+	return chosen
+}
+
+// Case is a SelectCase on a channel of T, as OnRecv and OnSend return, for
+// Select2, Select3 and Select4.
+type Case[T any] interface {
+	SelectCase
+	// chans returns the channel the case sends on, with the value to
+	// send, and the one it receives from. The other channel is nil, so
+	// that it never proceeds in a select statement.
+	chans() (send chan T, v T, recv chan T)
+	done(v T, ok bool)
+}
+
+func (rc *RecvCase[T]) chans() (send chan T, v T, recv chan T) {
+	return nil, v, rc.c.ch
+}
+
+func (rc *RecvCase[T]) done(v T, ok bool) {
+	rc.v, rc.ok = v, ok
+}
+
+func (sc *SendCase[T]) chans() (send chan T, v T, recv chan T) {
+	return sc.c.ch, sc.v, nil
+}
+
+func (sc *SendCase[T]) done(T, bool) {}
+
+// Select2 is Select over two cases, without the reflection Select needs
+// for any number of them.
+func Select2[A, B any](a Case[A], b Case[B]) int {
+	as, av, ar := a.chans()
+	bs, bv, br := b.chans()
+	select {
+	case as <- av:
+		return 0
+	case v, ok := <-ar:
+		a.done(v, ok)
+		return 0
+	case bs <- bv:
+		return 1
+	case v, ok := <-br:
+		b.done(v, ok)
+		return 1
+	}
+}
+
+// Select3 is Select over three cases, without the reflection Select needs
+// for any number of them.
+func Select3[A, B, C any](a Case[A], b Case[B], c Case[C]) int {
+	as, av, ar := a.chans()
+	bs, bv, br := b.chans()
+	cs, cv, cr := c.chans()
+	select {
+	case as <- av:
+		return 0
+	case v, ok := <-ar:
+		a.done(v, ok)
+		return 0
+	case bs <- bv:
+		return 1
+	case v, ok := <-br:
+		b.done(v, ok)
+		return 1
+	case cs <- cv:
+		return 2
+	case v, ok := <-cr:
+		c.done(v, ok)
+		return 2
+	}
+}
+
+// Select4 is Select over four cases, without the reflection Select needs
+// for any number of them.
+func Select4[A, B, C, D any](a Case[A], b Case[B], c Case[C], d Case[D]) int {
+	as, av, ar := a.chans()
+	bs, bv, br := b.chans()
+	cs, cv, cr := c.chans()
+	ds, dv, dr := d.chans()
+	select {
+	case as <- av:
+		return 0
+	case v, ok := <-ar:
+		a.done(v, ok)
+		return 0
+	case bs <- bv:
+		return 1
+	case v, ok := <-br:
+		b.done(v, ok)
+		return 1
+	case cs <- cv:
+		return 2
+	case v, ok := <-cr:
+		c.done(v, ok)
+		return 2
+	case ds <- dv:
+		return 3
+	case v, ok := <-dr:
+		d.done(v, ok)
+		return 3
+	}
+}
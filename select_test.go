@@ -0,0 +1,134 @@
+package weft
+
+import "testing"
+
+// TestSelectRecv verifies that Select performs a ready receive case and
+// exposes the received value.
+func TestSelectRecv(t *testing.T) {
+	empty := MakeChan[int](1)
+	full := MakeChan[string](1)
+	full.Send("hello")
+
+	recv := OnRecv(full)
+	if got := Select(OnRecv(empty), recv); got != 1 {
+		t.Fatalf("Select returned %d, want 1", got)
+	}
+	if v, ok := recv.Value(); v != "hello" || !ok {
+		t.Errorf("Value() = %q, %v; want \"hello\", true", v, ok)
+	}
+}
+
+// TestSelectClosed verifies that a receive from a closed channel reports
+// ok=false.
+func TestSelectClosed(t *testing.T) {
+	ch := MakeChan[int](0)
+	ch.Close()
+
+	recv := OnRecv(ch)
+	Select(recv)
+	if v, ok := recv.Value(); v != 0 || ok {
+		t.Errorf("Value() = %d, %v; want 0, false", v, ok)
+	}
+}
+
+// TestSelectSend verifies that a send case delivers its value.
+func TestSelectSend(t *testing.T) {
+	ch := MakeChan[error](1)
+	if got := Select(OnSend(ch, error(nil))); got != 0 {
+		t.Fatalf("Select returned %d, want 0", got)
+	}
+	if v, ok := ch.TryRecv(); v != nil || !ok {
+		t.Errorf("TryRecv() = %v, %v; want nil, true", v, ok)
+	}
+}
+
+// TestTrySelect verifies that TrySelect returns -1 when no case is ready.
+func TestTrySelect(t *testing.T) {
+	ch := MakeChan[int](0)
+	if got := TrySelect(OnRecv(ch), OnSend(ch, 1)); got != -1 {
+		t.Errorf("TrySelect returned %d, want -1", got)
+	}
+	if got := TrySelect(); got != -1 {
+		t.Errorf("TrySelect() with no cases returned %d, want -1", got)
+	}
+}
+
+// TestSelectN verifies that Select2, Select3 and Select4 perform the ready
+// case, send or receive, among channels of different types.
+func TestSelectN(t *testing.T) {
+	ints := MakeChan[int](0)
+	strs := MakeChan[string](1)
+	errs := MakeChan[error](1)
+	closed := MakeChan[struct{}](0)
+	closed.Close()
+
+	recv := OnRecv(strs)
+	strs.Send("hello")
+	if got := Select2(OnRecv(ints), recv); got != 1 {
+		t.Fatalf("Select2 returned %d, want 1", got)
+	}
+	if v, ok := recv.Value(); v != "hello" || !ok {
+		t.Errorf("Value() = %q, %v; want \"hello\", true", v, ok)
+	}
+
+	if got := Select3(OnRecv(ints), OnSend(ints, 1), OnSend(errs, nil)); got != 2 {
+		t.Fatalf("Select3 returned %d, want 2", got)
+	}
+	if _, ok := errs.TryRecv(); !ok {
+		t.Error("Select3 did not send on the ready channel")
+	}
+
+	done := OnRecv(closed)
+	if got := Select4(OnRecv(ints), OnRecv(strs), OnRecv(errs), done); got != 3 {
+		t.Fatalf("Select4 returned %d, want 3", got)
+	}
+	if _, ok := done.Value(); ok {
+		t.Error("Value() of a closed channel reported ok")
+	}
+}
+
+func BenchmarkSelect(b *testing.B) {
+	c, d := MakeChan[int](1), MakeChan[string](0)
+	for i := 0; i < b.N; i++ {
+		c.Send(i)
+		Select(OnRecv(c), OnRecv(d))
+	}
+}
+
+func BenchmarkSelect2(b *testing.B) {
+	c, d := MakeChan[int](1), MakeChan[string](0)
+	for i := 0; i < b.N; i++ {
+		c.Send(i)
+		Select2(OnRecv(c), OnRecv(d))
+	}
+}
+
+// TestNilChan verifies that the zero Chan behaves as a nil channel: its
+// cases never proceed, it is never ready, and closing it panics.
+func TestNilChan(t *testing.T) {
+	var nilChan Chan[int]
+	ready := MakeChan[int](2)
+	ready.Send(1)
+	ready.Send(2)
+	if got := Select(OnRecv(nilChan), OnSend(nilChan, 1), OnRecv(ready)); got != 2 {
+		t.Errorf("Select returned %d, want 2", got)
+	}
+	if got := Select2(OnSend(nilChan, 1), OnRecv(ready)); got != 1 {
+		t.Errorf("Select2 returned %d, want 1", got)
+	}
+	if got := TrySelect(OnRecv(nilChan), OnSend(nilChan, 1)); got != -1 {
+		t.Errorf("TrySelect returned %d, want -1", got)
+	}
+	if nilChan.TrySend(1) {
+		t.Error("TrySend succeeded")
+	}
+	if v, ok := nilChan.TryRecv(); v != 0 || ok {
+		t.Errorf("TryRecv() = %d, %v; want 0, false", v, ok)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Close did not panic")
+		}
+	}()
+	nilChan.Close()
+}
@@ -0,0 +1,5 @@
+// Package semaphore is a drop-in replacement for
+// golang.org/x/sync/semaphore whose waiters block on weft primitives. Under
+// -tags=detsched the deterministic scheduler explores the order in which
+// they acquire it; otherwise it is the x/sync Weighted itself.
+package semaphore
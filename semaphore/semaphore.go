@@ -0,0 +1,144 @@
+//go:build detsched
+
+package semaphore
+
+import (
+	"context"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+)
+
+// Weighted provides a way to bound concurrent access to a resource. The
+// callers can request access with a given weight.
+type Weighted struct {
+	size int64
+	cur  int64
+	mu   weft.Mutex
+
+	// cond is broadcast when waiters are granted the semaphore or a
+	// waiter's context is done.
+	cond    *weft.Cond
+	waiters []*waiter
+}
+
+// waiter is a call to Acquire waiting its turn.
+type waiter struct {
+	n     int64
+	ready bool
+}
+
+// NewWeighted creates a new weighted semaphore with the given maximum
+// combined weight for concurrent access.
+func NewWeighted(n int64) *Weighted {
+	s := &Weighted{size: n}
+	s.cond = weft.NewCond(&s.mu)
+	return s
+}
+
+// Acquire acquires the semaphore with a weight of n, blocking until resources
+// are available or ctx is done. On success, returns nil. On failure, returns
+// ctx.Err() and leaves the semaphore unchanged.
+func (s *Weighted) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.size-s.cur >= n && len(s.waiters) == 0 {
+		s.cur += n
+		return nil
+	}
+
+	// Wake the waiters when ctx is done, so that this one can give up.
+	stop := weftcontext.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	})
+	defer stop()
+
+	if n > s.size {
+		// Don't make other Acquire calls block on one that's doomed to fail.
+		for ctx.Err() == nil {
+			s.cond.Wait()
+		}
+		return ctx.Err()
+	}
+
+	w := &waiter{n: n}
+	s.waiters = append(s.waiters, w)
+	for !w.ready && ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		if w.ready {
+			// Acquired the semaphore after we were canceled. Pretend
+			// we didn't and put the tokens back.
+			s.cur -= n
+			s.notifyWaiters()
+			return err
+		}
+		isFront := s.waiters[0] == w
+		s.remove(w)
+		// If we were at the front and there are extra tokens left,
+		// notify other waiters.
+		if isFront && s.size > s.cur {
+			s.notifyWaiters()
+		}
+		return err
+	}
+	return nil
+}
+
+// TryAcquire acquires the semaphore with a weight of n without blocking.
+// On success, returns true. On failure, returns false and leaves the
+// semaphore unchanged.
+func (s *Weighted) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	success := s.size-s.cur >= n && len(s.waiters) == 0
+	if success {
+		s.cur += n
+	}
+	return success
+}
+
+// Release releases the semaphore with a weight of n.
+func (s *Weighted) Release(n int64) {
+	s.mu.Lock()
+	s.cur -= n
+	if s.cur < 0 {
+		s.mu.Unlock()
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+	s.mu.Unlock()
+}
+
+// notifyWaiters grants the semaphore to waiters in order for as long as
+// they fit. The caller must hold s.mu.
+func (s *Weighted) notifyWaiters() {
+	for len(s.waiters) > 0 {
+		w := s.waiters[0]
+		if s.size-s.cur < w.n {
+			// Not enough tokens for the next waiter. Stop rather than
+			// let smaller later waiters starve it.
+			break
+		}
+		s.cur += w.n
+		w.ready = true
+		s.waiters = s.waiters[1:]
+	}
+	s.cond.Broadcast()
+}
+
+// remove removes w from the waiters. The caller must hold s.mu.
+func (s *Weighted) remove(w *waiter) {
+	for i, other := range s.waiters {
+		if other == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return
+		}
+	}
+}
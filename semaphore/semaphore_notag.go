@@ -0,0 +1,14 @@
+//go:build !detsched
+
+package semaphore
+
+import "golang.org/x/sync/semaphore"
+
+// Weighted is a standard semaphore.Weighted in production mode.
+type Weighted = semaphore.Weighted
+
+// NewWeighted creates a new weighted semaphore with the given maximum
+// combined weight for concurrent access.
+func NewWeighted(n int64) *Weighted {
+	return semaphore.NewWeighted(n)
+}
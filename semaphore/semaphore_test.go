@@ -0,0 +1,61 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/wefttest"
+)
+
+// TestAcquireCanceled verifies that an Acquire that cannot be satisfied
+// returns when its context is canceled and leaves the semaphore unchanged.
+func TestAcquireCanceled(t *testing.T) {
+	s := NewWeighted(2)
+	if !s.TryAcquire(2) {
+		t.Fatal("TryAcquire(2) on an empty semaphore failed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Acquire(ctx, 1) }()
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Acquire = %v, want %v", err, context.Canceled)
+	}
+	s.Release(2)
+	if !s.TryAcquire(2) {
+		t.Error("semaphore not fully released")
+	}
+}
+
+// TestWeighted verifies that holders never exceed the semaphore's size.
+func TestWeighted(t *testing.T) {
+	wefttest.Explore(t, 20, func(sched *weft.Scheduler) {
+		s := NewWeighted(3)
+		var (
+			mu        weft.Mutex
+			held, top int64
+		)
+		for _, n := range []int64{1, 2, 3, 1} {
+			sched.Go(func(weft.Context) {
+				if err := s.Acquire(context.Background(), n); err != nil {
+					t.Errorf("Acquire(%d) = %v", n, err)
+					return
+				}
+				mu.Lock()
+				held += n
+				top = max(top, held)
+				mu.Unlock()
+				weft.Sleep(0)
+				mu.Lock()
+				held -= n
+				mu.Unlock()
+				s.Release(n)
+			})
+		}
+		sched.Wait()
+		if top > 3 {
+			t.Errorf("%d held at once, want at most 3", top)
+		}
+	})
+}
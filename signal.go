@@ -0,0 +1,91 @@
+//go:build detsched
+
+package weft
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sync"
+)
+
+// NotifySignal is signal.Notify for weft channels: it makes RaiseSignal
+// relay the signals sig, or all signals if none are given, to c. As with
+// signal.Notify, delivery does not block, so a signal is dropped if c is
+// full; give c a buffer.
+//
+// No real signals are delivered to c: tests raise them with RaiseSignal, on
+// a task of their own, so shutdown logic runs at whatever scheduling point
+// the signal lands.
+func NotifySignal(c Chan[os.Signal], sig ...os.Signal) {
+	signals.mu.Lock()
+	defer signals.mu.Unlock()
+	signals.handlers = append(signals.handlers, &signalHandler{c: c, sigs: sig})
+}
+
+// StopSignal stops relaying signals to c.
+func StopSignal(c Chan[os.Signal]) {
+	signals.mu.Lock()
+	defer signals.mu.Unlock()
+	signals.handlers = slices.DeleteFunc(signals.handlers, func(h *signalHandler) bool {
+		return h.cancel == nil && h.c == c
+	})
+}
+
+// NotifySignalContext is signal.NotifyContext for signals raised with
+// RaiseSignal: the returned context is canceled when one of sig, or any
+// signal if none are given, is raised, when stop is called, or when parent
+// is done.
+func NotifySignalContext(parent context.Context, sig ...os.Signal) (ctx context.Context, stop context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	h := &signalHandler{sigs: sig, cancel: cancel}
+	signals.mu.Lock()
+	signals.handlers = append(signals.handlers, h)
+	signals.mu.Unlock()
+	return ctx, func() {
+		signals.mu.Lock()
+		signals.handlers = slices.DeleteFunc(signals.handlers, func(o *signalHandler) bool { return o == h })
+		signals.mu.Unlock()
+		cancel()
+	}
+}
+
+// RaiseSignal delivers sig to the channels and contexts registered for it
+// with NotifySignal and NotifySignalContext, as if the process received it.
+//
+//	s.Go(func(weft.Context) { weft.RaiseSignal(syscall.SIGTERM) })
+//
+// In builds without the detsched tag, RaiseSignal does nothing.
+func RaiseSignal(sig os.Signal) {
+	signals.mu.Lock()
+	handlers := slices.Clone(signals.handlers)
+	signals.mu.Unlock()
+	for _, h := range handlers {
+		if !h.wants(sig) {
+			continue
+		}
+		if h.cancel != nil {
+			h.cancel()
+		} else {
+			h.c.TrySend(sig)
+		}
+	}
+}
+
+// signals holds the registered signal handlers, in registration order.
+var signals struct {
+	mu       sync.Mutex
+	handlers []*signalHandler
+}
+
+// signalHandler relays signals to a channel or cancels a context.
+type signalHandler struct {
+	c      Chan[os.Signal]
+	sigs   []os.Signal
+	cancel context.CancelFunc
+}
+
+// wants reports whether h is registered for sig.
+func (h *signalHandler) wants(sig os.Signal) bool {
+	return len(h.sigs) == 0 || slices.Contains(h.sigs, sig)
+}
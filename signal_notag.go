@@ -0,0 +1,28 @@
+//go:build !detsched
+
+package weft
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// NotifySignal delegates to signal.Notify in production mode.
+func NotifySignal(c Chan[os.Signal], sig ...os.Signal) {
+	signal.Notify(c.ch, sig...)
+}
+
+// StopSignal delegates to signal.Stop in production mode.
+func StopSignal(c Chan[os.Signal]) {
+	signal.Stop(c.ch)
+}
+
+// NotifySignalContext delegates to signal.NotifyContext in production mode.
+func NotifySignalContext(parent context.Context, sig ...os.Signal) (ctx context.Context, stop context.CancelFunc) {
+	return signal.NotifyContext(parent, sig...)
+}
+
+// RaiseSignal does nothing in production mode, where signals come from the
+// operating system.
+func RaiseSignal(sig os.Signal) {}
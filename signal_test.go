@@ -0,0 +1,48 @@
+//go:build detsched
+
+package weft
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// TestRaiseSignal verifies that raised signals reach the channels notified
+// of them until they are stopped.
+func TestRaiseSignal(t *testing.T) {
+	c := MakeChan[os.Signal](1)
+	NotifySignal(c, syscall.SIGTERM)
+	defer StopSignal(c)
+
+	RaiseSignal(syscall.SIGINT)
+	if sig, ok := c.TryRecv(); ok {
+		t.Errorf("received %v, not notified of it", sig)
+	}
+	s := NewScheduler(1)
+	s.Go(func(Context) { RaiseSignal(syscall.SIGTERM) })
+	if sig, _ := c.Recv(); sig != syscall.SIGTERM {
+		t.Errorf("received %v, want SIGTERM", sig)
+	}
+
+	StopSignal(c)
+	RaiseSignal(syscall.SIGTERM)
+	if sig, ok := c.TryRecv(); ok {
+		t.Errorf("received %v after StopSignal", sig)
+	}
+}
+
+// TestNotifySignalContext verifies that a raised signal cancels the context.
+func TestNotifySignalContext(t *testing.T) {
+	ctx, stop := NotifySignalContext(context.Background(), os.Interrupt)
+	defer stop()
+	RaiseSignal(syscall.SIGTERM)
+	if ctx.Err() != nil {
+		t.Fatal("context canceled by a signal it was not notified of")
+	}
+	RaiseSignal(os.Interrupt)
+	if ctx.Err() != context.Canceled {
+		t.Errorf("Err() = %v, want context.Canceled", ctx.Err())
+	}
+}
@@ -0,0 +1,5 @@
+// Package singleflight is a drop-in replacement for
+// golang.org/x/sync/singleflight whose duplicate callers wait on weft
+// primitives. Under -tags=detsched the deterministic scheduler explores how
+// calls for the same key overlap; otherwise it is the x/sync Group itself.
+package singleflight
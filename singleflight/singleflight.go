@@ -0,0 +1,136 @@
+//go:build detsched
+
+package singleflight
+
+import (
+	"errors"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/mziter/weft"
+)
+
+// Result holds the results of Do, so they can be passed on a channel.
+type Result = singleflight.Result
+
+// errGoexit is the error of a call whose function called runtime.Goexit.
+var errGoexit = errors.New("runtime.Goexit was called")
+
+// call is an in-flight or completed Do call.
+type call struct {
+	// done is closed when the call completes.
+	done weft.Chan[struct{}]
+
+	val interface{}
+	err error
+
+	// panicked reports whether the function panicked, with value.
+	panicked bool
+	value    interface{}
+
+	dups  int
+	chans []chan<- Result
+}
+
+// Group represents a class of work and forms a namespace in which units of
+// work can be executed with duplicate suppression.
+type Group struct {
+	mu weft.Mutex
+	m  map[string]*call
+}
+
+// Do executes and returns the results of the given function, making sure
+// that only one execution is in-flight for a given key at a time. If a
+// duplicate comes in, the duplicate caller waits for the original to
+// complete and receives the same results. The return value shared reports
+// whether v was given to multiple callers.
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.done.Recv()
+		if c.panicked {
+			panic(c.value)
+		}
+		return c.val, c.err, true
+	}
+	c := &call{done: weft.MakeChan[struct{}](0)}
+	g.m[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn)
+	return c.val, c.err, c.dups > 0
+}
+
+// DoChan is like Do but returns a channel that will receive the results
+// when they are ready. The channel is a built-in one, so receiving from it
+// is not a scheduling point; prefer Do in code explored by weft.
+//
+// The returned channel will not be closed.
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := &call{done: weft.MakeChan[struct{}](0), chans: []chan<- Result{ch}}
+	g.m[key] = c
+	g.mu.Unlock()
+
+	weft.Go(func(weft.Context) {
+		g.doCall(c, key, fn)
+	})
+	return ch
+}
+
+// doCall handles the single call for a key, then releases its duplicates.
+// A panic in fn is repanicked in every caller waiting on it.
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	normalReturn := false
+	defer func() {
+		if !normalReturn {
+			if r := recover(); r != nil {
+				c.panicked, c.value = true, r
+			} else {
+				c.err = errGoexit
+			}
+		}
+
+		g.mu.Lock()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		if !c.panicked {
+			for _, ch := range c.chans {
+				ch <- Result{Val: c.val, Err: c.err, Shared: c.dups > 0}
+			}
+		}
+		g.mu.Unlock()
+		c.done.Close()
+
+		if c.panicked {
+			panic(c.value)
+		}
+	}()
+	c.val, c.err = fn()
+	normalReturn = true
+}
+
+// Forget tells the singleflight to forget about a key. Future calls to Do
+// for this key will call the function rather than waiting for an earlier
+// call to complete.
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
@@ -0,0 +1,11 @@
+//go:build !detsched
+
+package singleflight
+
+import "golang.org/x/sync/singleflight"
+
+// Group is a standard singleflight.Group in production mode.
+type Group = singleflight.Group
+
+// Result holds the results of Do, so they can be passed on a channel.
+type Result = singleflight.Result
@@ -0,0 +1,60 @@
+package singleflight
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/wefttest"
+)
+
+// TestDo verifies that a single call returns its function's results.
+func TestDo(t *testing.T) {
+	var res <-chan Result
+	weft.RunDefault(t, func() {
+		var g Group
+		errFailed := errors.New("failed")
+		v, err, shared := g.Do("key", func() (interface{}, error) {
+			return "bar", errFailed
+		})
+		if v != "bar" || err != errFailed || shared {
+			t.Errorf("Do = %v, %v, %v; want bar, %v, false", v, err, shared, errFailed)
+		}
+		res = g.DoChan("key", func() (interface{}, error) { return 1, nil })
+	})
+	// A receive from the channel is no wait the scheduler sees, so take
+	// the result once the run is over.
+	if res := <-res; res.Val != 1 || res.Err != nil || res.Shared {
+		t.Errorf("DoChan = %+v, want {1 <nil> false}", res)
+	}
+}
+
+// TestDoDuplicates verifies that overlapping calls for a key share one
+// execution, whichever way they interleave.
+func TestDoDuplicates(t *testing.T) {
+	wefttest.Explore(t, 20, func(s *weft.Scheduler) {
+		var (
+			g     Group
+			mu    weft.Mutex
+			calls int
+		)
+		fn := func() (interface{}, error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			weft.Sleep(0)
+			return "v", nil
+		}
+		for i := 0; i < 3; i++ {
+			s.Go(func(weft.Context) {
+				if v, err, _ := g.Do("key", fn); v != "v" || err != nil {
+					t.Errorf("Do = %v, %v", v, err)
+				}
+			})
+		}
+		s.Wait()
+		if calls < 1 || calls > 3 {
+			t.Errorf("fn called %d times", calls)
+		}
+	})
+}
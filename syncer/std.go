@@ -0,0 +1,54 @@
+package syncer
+
+import (
+	"sync"
+	"time"
+)
+
+// Std is the Syncer of the standard library: sync's types, goroutines and
+// Go channels.
+var Std Syncer = std{}
+
+type std struct{}
+
+func (std) NewMutex() sync.Locker   { return new(sync.Mutex) }
+func (std) NewRWMutex() RWLocker    { return new(sync.RWMutex) }
+func (std) NewWaitGroup() WaitGroup { return new(sync.WaitGroup) }
+func (std) Go(fn func())            { go fn() }
+func (std) Sleep(d time.Duration)   { time.Sleep(d) }
+func (std) weft() bool              { return false }
+
+// stdChan is a Go channel.
+type stdChan[T any] chan T
+
+func (c stdChan[T]) Send(v T) {
+	c <- v
+}
+
+func (c stdChan[T]) Recv() (T, bool) {
+	v, ok := <-c
+	return v, ok
+}
+
+func (c stdChan[T]) TrySend(v T) bool {
+	select {
+	case c <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c stdChan[T]) TryRecv() (T, bool) {
+	select {
+	case v, ok := <-c:
+		return v, ok
+	default:
+		var zero T
+		return zero, false
+	}
+}
+
+func (c stdChan[T]) Close() {
+	close(c)
+}
@@ -0,0 +1,94 @@
+// Package syncer lets a library take its synchronization primitives as a
+// dependency instead of from build tags. Code written against a Syncer
+// runs on the standard library when given Std and on a weft scheduler when
+// given Weft, so a library that cannot ask its users to build with
+// -tags=detsched can still be explored deterministically by its own tests:
+//
+//	type Cache struct {
+//		mu    sync.Locker
+//		fills syncer.Chan[string]
+//	}
+//
+//	func NewCache(sy syncer.Syncer) *Cache {
+//		return &Cache{mu: sy.NewMutex(), fills: syncer.MakeChan[string](sy, 16)}
+//	}
+//
+// Production code passes syncer.Std; tests built with -tags=detsched pass
+// syncer.Weft(s).
+package syncer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// A Syncer makes the synchronization primitives of one implementation and
+// runs functions concurrently on it. The implementations are Std and Weft.
+type Syncer interface {
+	// NewMutex returns an unlocked mutual exclusion lock.
+	NewMutex() sync.Locker
+
+	// NewRWMutex returns an unlocked reader/writer lock.
+	NewRWMutex() RWLocker
+
+	// NewWaitGroup returns a wait group with a zero counter.
+	NewWaitGroup() WaitGroup
+
+	// Go runs fn concurrently: on a goroutine, or a weft task.
+	Go(fn func())
+
+	// Sleep pauses the calling goroutine or task for at least d.
+	Sleep(d time.Duration)
+
+	// weft reports whether primitives are weft's, for MakeChan; it keeps
+	// the set of implementations closed.
+	weft() bool
+}
+
+// RWLocker is a reader/writer lock, as implemented by sync.RWMutex.
+type RWLocker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// WaitGroup waits for a collection of goroutines or tasks to finish, as
+// sync.WaitGroup does.
+type WaitGroup interface {
+	Add(delta int)
+	Done()
+	Wait()
+}
+
+// Chan is a channel of values of type T made by a Syncer. Go's select
+// statement cannot wait on it; libraries needing select should use the
+// weft package with build tags instead.
+type Chan[T any] interface {
+	// Send sends v, blocking until there is room or a receiver.
+	Send(v T)
+
+	// Recv receives a value, blocking until one is available. ok is false
+	// if the channel is closed and drained.
+	Recv() (v T, ok bool)
+
+	// TrySend sends v if it can do so without blocking.
+	TrySend(v T) bool
+
+	// TryRecv receives a value if it can do so without blocking.
+	TryRecv() (v T, ok bool)
+
+	// Close closes the channel.
+	Close()
+}
+
+// MakeChan makes a channel of capacity cap from sy's primitives. Interface
+// methods cannot be generic, which is why this is a function.
+func MakeChan[T any](sy Syncer, cap int) Chan[T] {
+	if sy.weft() {
+		return weftChan[T]{weft.MakeChan[T](cap)}
+	}
+	return stdChan[T](make(chan T, cap))
+}
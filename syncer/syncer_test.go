@@ -0,0 +1,87 @@
+package syncer
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/wefttest"
+)
+
+// counter is library code written against a Syncer.
+type counter struct {
+	sy    Syncer
+	mu    sync.Locker
+	n     int
+	added Chan[int]
+}
+
+func newCounter(sy Syncer) *counter {
+	return &counter{sy: sy, mu: sy.NewMutex(), added: MakeChan[int](sy, 10)}
+}
+
+// addAll increments the counter from n concurrent workers and reports each
+// increment on added.
+func (c *counter) addAll(n int) {
+	wg := c.sy.NewWaitGroup()
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		c.sy.Go(func() {
+			defer wg.Done()
+			c.mu.Lock()
+			c.n++
+			c.mu.Unlock()
+			c.added.Send(1)
+		})
+	}
+	wg.Wait()
+	c.added.Close()
+}
+
+// check verifies that addAll counted and reported every increment.
+func check(t *testing.T, c *counter, n int) {
+	t.Helper()
+	c.addAll(n)
+	if c.n != n {
+		t.Errorf("n = %d, want %d", c.n, n)
+	}
+	sum := 0
+	for {
+		v, ok := c.added.Recv()
+		if !ok {
+			break
+		}
+		sum += v
+	}
+	if sum != n {
+		t.Errorf("sum of added = %d, want %d", sum, n)
+	}
+}
+
+// TestStd verifies library code running on the standard library.
+func TestStd(t *testing.T) {
+	check(t, newCounter(Std), 5)
+}
+
+// TestWeft verifies the same code running on a weft scheduler.
+func TestWeft(t *testing.T) {
+	wefttest.Explore(t, 20, func(s *weft.Scheduler) {
+		check(t, newCounter(Weft(s)), 5)
+	})
+}
+
+// TestTryChan verifies TrySend and TryRecv in both implementations.
+func TestTryChan(t *testing.T) {
+	for _, sy := range []Syncer{Std, Weft(weft.NewScheduler(1))} {
+		c := MakeChan[int](sy, 1)
+		if _, ok := c.TryRecv(); ok {
+			t.Error("TryRecv() on empty channel ok = true")
+		}
+		if !c.TrySend(1) || c.TrySend(2) {
+			t.Error("TrySend() did not fill exactly the capacity")
+		}
+		if v, ok := c.TryRecv(); !ok || v != 1 {
+			t.Errorf("TryRecv() = %d, %v, want 1, true", v, ok)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package syncer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// Weft returns the Syncer of the weft scheduler s: its primitives are
+// weft's and Go spawns tasks on s. Under -tags=detsched the scheduler
+// explores the interleavings of code using it; otherwise it behaves as Std.
+func Weft(s *weft.Scheduler) Syncer {
+	return weftSyncer{s}
+}
+
+type weftSyncer struct{ s *weft.Scheduler }
+
+func (weftSyncer) NewMutex() sync.Locker   { return new(weft.Mutex) }
+func (weftSyncer) NewRWMutex() RWLocker    { return new(weft.RWMutex) }
+func (weftSyncer) NewWaitGroup() WaitGroup { return newWaitGroup() }
+func (w weftSyncer) Sleep(d time.Duration) { w.s.Sleep(d) }
+func (weftSyncer) weft() bool              { return true }
+
+func (w weftSyncer) Go(fn func()) {
+	w.s.Go(func(weft.Context) { fn() })
+}
+
+// waitGroup is a WaitGroup built on a weft mutex and condition variable.
+type waitGroup struct {
+	mu   weft.Mutex
+	cond *weft.Cond
+	n    int
+}
+
+func newWaitGroup() *waitGroup {
+	wg := &waitGroup{}
+	wg.cond = weft.NewCond(&wg.mu)
+	return wg
+}
+
+func (wg *waitGroup) Add(delta int) {
+	wg.mu.Lock()
+	defer wg.mu.Unlock()
+	wg.n += delta
+	if wg.n < 0 {
+		panic("sync: negative WaitGroup counter")
+	}
+	if wg.n == 0 {
+		wg.cond.Broadcast()
+	}
+}
+
+func (wg *waitGroup) Done() {
+	wg.Add(-1)
+}
+
+func (wg *waitGroup) Wait() {
+	wg.mu.Lock()
+	defer wg.mu.Unlock()
+	for wg.n > 0 {
+		wg.cond.Wait()
+	}
+}
+
+// weftChan is a weft channel.
+type weftChan[T any] struct{ c weft.Chan[T] }
+
+func (c weftChan[T]) Send(v T)           { c.c.Send(v) }
+func (c weftChan[T]) Recv() (T, bool)    { return c.c.Recv() }
+func (c weftChan[T]) TrySend(v T) bool   { return c.c.TrySend(v) }
+func (c weftChan[T]) TryRecv() (T, bool) { return c.c.TryRecv() }
+func (c weftChan[T]) Close()             { c.c.Close() }
@@ -0,0 +1,50 @@
+package weft
+
+// Task describes a task of a Scheduler that has not yet exited, as
+// returned by Scheduler.Tasks, for harnesses and monitors to build their
+// own reports and assertions on.
+type Task struct {
+	// ID is the task's ID in trace events.
+	ID int
+
+	// Parent is the ID of the task that spawned it, or 0 if the test did.
+	Parent int
+
+	// Node is the name of the node the task belongs to, or "" if none.
+	Node string
+
+	State TaskState
+
+	// BlockedOn is what a blocked task waits on: a primitive, such as
+	// "nil chan", or the wait reason of its goroutine, such as "chan
+	// receive" or "sync.Mutex.Lock".
+	BlockedOn string
+
+	// Spawn is the stack of the code that spawned the task, innermost
+	// first, formatted as in trace events.
+	Spawn []string
+
+	// Steps is the number of events recorded for the task.
+	Steps int
+
+	// Priority is the priority set with Context.SetPriority, or inherited.
+	Priority int
+}
+
+// TaskState is the state of a task.
+type TaskState string
+
+const (
+	// TaskReady is a task yet to start, or one paused by RunUntil or Step
+	// until its turn.
+	TaskReady TaskState = "ready"
+
+	// TaskRunning is a task running, or able to.
+	TaskRunning TaskState = "running"
+
+	// TaskBlocked is a task blocked on a channel, lock or primitive.
+	TaskBlocked TaskState = "blocked"
+
+	// TaskKilled is a task of a crashed node, unwinding.
+	TaskKilled TaskState = "killed"
+)
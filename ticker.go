@@ -0,0 +1,71 @@
+//go:build detsched
+
+package weft
+
+import (
+	"time"
+
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// Ticker sends the virtual time on C at intervals, like a time.Ticker, for
+// periodic work such as heartbeats and flush loops. Each tick fires with
+// the other sleeps and timers due at the same virtual time, so which of
+// the tasks they wake runs first is a decision of the schedule, and
+// different seeds interleave a tick with the work around it differently.
+// A tick the receiver has not taken by the next is dropped, and, as with
+// time.Ticker since Go 1.23, none sent before Stop or Reset is received
+// after it.
+//
+//	t := s.NewTicker(time.Second)
+//	defer t.Stop()
+//	for {
+//		now, _ := t.C.Recv()
+//		heartbeat(now)
+//	}
+type Ticker struct {
+	C Chan[time.Time]
+
+	t *scheduler.Ticker
+}
+
+// NewTicker returns a ticker sending the time on its channel every d, on
+// the scheduler of the current run. It panics if d is not positive, and
+// outside a run; see Bind.
+func NewTicker(d time.Duration) *Ticker {
+	return current("NewTicker").NewTicker(d)
+}
+
+// NewTicker returns a ticker sending the time on its channel every d of
+// the scheduler's virtual time. It panics if d is not positive.
+func (s *Scheduler) NewTicker(d time.Duration) *Ticker {
+	t := s.sched.NewTicker(d)
+	return &Ticker{C: Chan[time.Time]{ch: t.C}, t: t}
+}
+
+// Stop turns off the ticker. It does not close C.
+func (t *Ticker) Stop() {
+	t.t.Stop()
+}
+
+// Reset stops the ticker and restarts it with the interval d, the next
+// tick arriving once d has passed. It panics if d is not positive.
+func (t *Ticker) Reset(d time.Duration) {
+	t.t.Reset(d)
+}
+
+// Tick returns the channel of a ticker that is never stopped, on the
+// scheduler of the current run, or the zero Chan if d is not positive, as
+// time.Tick does. It panics outside a run; see Bind.
+func Tick(d time.Duration) Chan[time.Time] {
+	return current("Tick").Tick(d)
+}
+
+// Tick returns the channel of a ticker of s that is never stopped, or the
+// zero Chan if d is not positive.
+func (s *Scheduler) Tick(d time.Duration) Chan[time.Time] {
+	if d <= 0 {
+		return Chan[time.Time]{}
+	}
+	return s.NewTicker(d).C
+}
@@ -0,0 +1,109 @@
+//go:build !detsched
+
+package weft
+
+import (
+	"sync"
+	"time"
+)
+
+// Ticker sends the current time on C at intervals, like a time.Ticker, in
+// production mode. A tick the receiver has not taken by the next is
+// dropped, and none sent before Stop or Reset is received after it.
+type Ticker struct {
+	C Chan[time.Time]
+
+	// mu guards the rest. d is the interval and next the time of the next
+	// tick; gen counts the ticks armed, so that a tick armed before Stop
+	// or Reset does nothing.
+	mu    sync.Mutex
+	d     time.Duration
+	next  time.Time
+	timer *time.Timer
+	gen   int
+}
+
+// NewTicker returns a ticker sending the time on its channel every d in
+// production mode. It panics if d is not positive.
+func NewTicker(d time.Duration) *Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &Ticker{C: MakeChan[time.Time](1), d: d}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.next = time.Now().Add(d)
+	t.arm()
+	return t
+}
+
+// NewTicker is NewTicker in production mode.
+func (s *Scheduler) NewTicker(d time.Duration) *Ticker {
+	return NewTicker(d)
+}
+
+// arm sets the timer of the next tick. The caller must hold t.mu.
+func (t *Ticker) arm() {
+	t.gen++
+	gen := t.gen
+	t.timer = time.AfterFunc(time.Until(t.next), func() { t.tick(gen) })
+}
+
+// tick sends the time on C and arms the next tick, unless Stop or Reset
+// came first.
+func (t *Ticker) tick(gen int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if gen != t.gen {
+		return
+	}
+	now := time.Now()
+	t.C.TrySend(now)
+	for !t.next.After(now) {
+		t.next = t.next.Add(t.d)
+	}
+	t.arm()
+}
+
+// disarm stops the pending tick and drops a tick unread. The caller must
+// hold t.mu.
+func (t *Ticker) disarm() {
+	t.gen++
+	t.timer.Stop()
+	t.C.TryRecv()
+}
+
+// Stop turns off the ticker. It does not close C.
+func (t *Ticker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disarm()
+}
+
+// Reset stops the ticker and restarts it with the interval d, the next
+// tick arriving once d has passed. It panics if d is not positive.
+func (t *Ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disarm()
+	t.d = d
+	t.next = time.Now().Add(d)
+	t.arm()
+}
+
+// Tick returns the channel of a ticker that is never stopped, or the zero
+// Chan if d is not positive, as time.Tick does, in production mode.
+func Tick(d time.Duration) Chan[time.Time] {
+	if d <= 0 {
+		return Chan[time.Time]{}
+	}
+	return NewTicker(d).C
+}
+
+// Tick is Tick in production mode.
+func (s *Scheduler) Tick(d time.Duration) Chan[time.Time] {
+	return Tick(d)
+}
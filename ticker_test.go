@@ -0,0 +1,37 @@
+package weft
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTicker verifies that a ticker ticks until it is stopped, that Reset
+// restarts it, and that Tick refuses a non-positive interval, in both
+// build modes.
+func TestTicker(t *testing.T) {
+	s := NewScheduler(1)
+	ticks := 0
+	done := MakeChan[struct{}](0)
+	s.Go(func(Context) {
+		defer done.Close()
+		tk := s.NewTicker(time.Millisecond)
+		defer tk.Stop()
+		for range 3 {
+			if _, ok := tk.C.Recv(); ok {
+				ticks++
+			}
+		}
+		tk.Reset(2 * time.Millisecond)
+		if _, ok := tk.C.Recv(); ok {
+			ticks++
+		}
+	})
+	s.Wait()
+	done.Recv()
+	if ticks != 4 {
+		t.Errorf("received %d ticks, want 4", ticks)
+	}
+	if c := s.Tick(0); c != (Chan[time.Time]{}) {
+		t.Error("Tick(0) returned a channel, want the zero Chan")
+	}
+}
@@ -0,0 +1,66 @@
+//go:build detsched
+
+package weft
+
+import (
+	"time"
+
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// Timer runs a function on a new task once d of virtual time has passed,
+// like a timer of time.AfterFunc, or sends the virtual time on C, like a
+// timer of time.NewTimer, so that a timeout racing with the work it
+// cancels is part of the schedule: the task is spawned, or the time sent,
+// with the other sleeps and timers due at the same time, and which runs
+// first is a decision the seeds explore. As with time.Timer since Go 1.23,
+// no time sent before Stop or Reset is received after it.
+//
+//	t := s.AfterFunc(time.Second, cancel)
+//	defer t.Stop()
+type Timer struct {
+	// C is the zero Chan for a timer of AfterFunc.
+	C Chan[time.Time]
+
+	t *scheduler.Timer
+}
+
+// AfterFunc returns a timer that calls f on a new task once d has passed,
+// on the scheduler of the current run. It panics outside a run; see Bind.
+func AfterFunc(d time.Duration, f func()) *Timer {
+	return current("AfterFunc").AfterFunc(d, f)
+}
+
+// AfterFunc returns a timer that calls f on a new task once d of the
+// scheduler's virtual time has passed. The task is a child of no task, as
+// if started with GoDetached.
+func (s *Scheduler) AfterFunc(d time.Duration, f func()) *Timer {
+	return &Timer{t: s.sched.AfterFunc(d, f)}
+}
+
+// NewTimer returns a timer that sends the time on its channel once d has
+// passed, on the scheduler of the current run. It panics outside a run;
+// see Bind.
+func NewTimer(d time.Duration) *Timer {
+	return current("NewTimer").NewTimer(d)
+}
+
+// NewTimer returns a timer that sends the time on its channel once d of
+// the scheduler's virtual time has passed.
+func (s *Scheduler) NewTimer(d time.Duration) *Timer {
+	t := s.sched.NewTimer(d)
+	return &Timer{C: Chan[time.Time]{ch: t.C}, t: t}
+}
+
+// Stop prevents the timer from firing and reports whether it stopped it,
+// false if it had fired or been stopped already. It does not wait for f
+// to return if it has started.
+func (t *Timer) Stop() bool {
+	return t.t.Stop()
+}
+
+// Reset makes the timer fire once d has passed, and reports whether it had
+// been active.
+func (t *Timer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
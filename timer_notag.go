@@ -0,0 +1,98 @@
+//go:build !detsched
+
+package weft
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer is a timer of time.AfterFunc, or one sending the current time on
+// C like a timer of time.NewTimer, in production mode. No time sent before
+// Stop or Reset is received after it.
+type Timer struct {
+	// C is the zero Chan for a timer of AfterFunc.
+	C Chan[time.Time]
+
+	// mu guards the rest for a timer of NewTimer. gen counts the sends
+	// armed, so that one armed before Stop or Reset does nothing.
+	mu  sync.Mutex
+	t   *time.Timer
+	gen int
+}
+
+// AfterFunc calls f on its own goroutine once d has passed, as
+// time.AfterFunc does, in production mode.
+func AfterFunc(d time.Duration, f func()) *Timer {
+	return &Timer{t: time.AfterFunc(d, f)}
+}
+
+// AfterFunc is AfterFunc in production mode.
+func (s *Scheduler) AfterFunc(d time.Duration, f func()) *Timer {
+	return AfterFunc(d, f)
+}
+
+// NewTimer returns a timer that sends the current time on its channel once
+// d has passed, as time.NewTimer does, in production mode.
+func NewTimer(d time.Duration) *Timer {
+	t := &Timer{C: MakeChan[time.Time](1)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.arm(d)
+	return t
+}
+
+// NewTimer is NewTimer in production mode.
+func (s *Scheduler) NewTimer(d time.Duration) *Timer {
+	return NewTimer(d)
+}
+
+// arm sets the timer of NewTimer to send once d has passed. The caller
+// must hold t.mu.
+func (t *Timer) arm(d time.Duration) {
+	t.gen++
+	gen := t.gen
+	t.t = time.AfterFunc(d, func() { t.send(gen) })
+}
+
+// send sends the time on C, unless Stop or Reset came first.
+func (t *Timer) send(gen int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if gen == t.gen {
+		t.C.TrySend(time.Now())
+	}
+}
+
+// disarm stops the pending send of a timer of NewTimer, drops a time sent
+// unread, and reports whether the send was pending. The caller must hold
+// t.mu.
+func (t *Timer) disarm() bool {
+	t.gen++
+	active := t.t.Stop()
+	t.C.TryRecv()
+	return active
+}
+
+// Stop prevents the timer from firing and reports whether it stopped it.
+func (t *Timer) Stop() bool {
+	if t.C == (Chan[time.Time]{}) {
+		return t.t.Stop()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.disarm()
+}
+
+// Reset makes the timer fire once d has passed, and reports whether it had
+// been active.
+func (t *Timer) Reset(d time.Duration) bool {
+	if t.C == (Chan[time.Time]{}) {
+		return t.t.Reset(d)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	active := t.disarm()
+	t.arm(d)
+	return active
+}
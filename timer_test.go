@@ -0,0 +1,44 @@
+package weft
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAfterFunc verifies that a timer calls its function once, and that a
+// stopped timer does not, in both build modes.
+func TestAfterFunc(t *testing.T) {
+	s := NewScheduler(1)
+	fired := MakeChan[string](2)
+	s.AfterFunc(time.Millisecond, func() { fired.Send("fired") })
+	stopped := s.AfterFunc(time.Millisecond, func() { fired.Send("stopped") })
+	if !stopped.Stop() {
+		t.Error("Stop() on a pending timer = false, want true")
+	}
+	s.Go(func(Context) { s.Sleep(5 * time.Millisecond) })
+	s.Wait()
+	if v, _ := fired.Recv(); v != "fired" {
+		t.Errorf("timer sent %q, want fired", v)
+	}
+	if v, ok := fired.TryRecv(); ok {
+		t.Errorf("stopped timer sent %q", v)
+	}
+}
+
+// TestNewTimer verifies that a timer sends the time on its channel, and
+// that a stopped timer does not, in both build modes.
+func TestNewTimer(t *testing.T) {
+	s := NewScheduler(1)
+	tm := s.NewTimer(time.Millisecond)
+	stopped := s.NewTimer(time.Millisecond)
+	if !stopped.Stop() {
+		t.Error("Stop() on a pending timer = false, want true")
+	}
+	if _, ok := tm.C.Recv(); !ok {
+		t.Error("timer channel closed")
+	}
+	s.Sleep(5 * time.Millisecond)
+	if _, ok := stopped.C.TryRecv(); ok {
+		t.Error("stopped timer sent")
+	}
+}
@@ -0,0 +1,127 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// chromeEvent is an entry in the Chrome trace event format.
+type chromeEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Ph    string                 `json:"ph"`
+	Ts    int                    `json:"ts"`
+	Dur   int                    `json:"dur,omitempty"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	ID    int                    `json:"id,omitempty"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// chromeTrace is the top level of a Chrome trace file.
+type chromeTrace struct {
+	TraceEvents     []chromeEvent     `json:"traceEvents"`
+	DisplayTimeUnit string            `json:"displayTimeUnit"`
+	OtherData       map[string]string `json:"otherData,omitempty"`
+}
+
+// WriteChrome writes the events of t in the Chrome trace event format, which
+// Perfetto and chrome://tracing open. Each task is a thread and each step
+// one microsecond. Running and blocked spans are slices, every event is an
+// instant marker carrying its stack, and spawns and handoffs are flow
+// arrows to the next slice of the receiving task.
+func WriteChrome(w io.Writer, t *Trace) error {
+	const pid = 1
+	ct := &chromeTrace{
+		DisplayTimeUnit: "ns",
+		OtherData: map[string]string{
+			"test": t.Test,
+			"seed": fmt.Sprint(t.Seed),
+		},
+	}
+	if t.Package != "" {
+		ct.OtherData["package"] = t.Package
+	}
+	if t.Failure != "" {
+		ct.OtherData["failure"] = t.Failure
+	}
+	add := func(ev chromeEvent) {
+		ev.Pid = pid
+		ct.TraceEvents = append(ct.TraceEvents, ev)
+	}
+
+	d := newDiagram(t)
+	add(chromeEvent{Name: "process_name", Ph: "M", Args: map[string]interface{}{"name": t.Test}})
+	for _, task := range d.tasks {
+		add(chromeEvent{Name: "thread_name", Ph: "M", Tid: task, Args: map[string]interface{}{"name": taskName(task)}})
+		add(chromeEvent{Name: "thread_sort_index", Ph: "M", Tid: task, Args: map[string]interface{}{"sort_index": task}})
+	}
+
+	// Running spans last from a task's first action until another task
+	// acts; blocked spans from Block until Unblock.
+	running, runStart := -1, 0
+	endRun := func(step int) {
+		if running >= 0 && step > runStart {
+			add(chromeEvent{Name: "running", Cat: "state", Ph: "X", Ts: runStart, Dur: step - runStart, Tid: running})
+		}
+		running = -1
+	}
+	blocked := make(map[int]Event)
+	steps := 0
+	for _, ev := range t.Events {
+		steps = ev.Step + 1
+		switch ev.Kind {
+		case Unblock:
+			if b, ok := blocked[ev.Task]; ok {
+				delete(blocked, ev.Task)
+				add(chromeEvent{Name: "blocked on " + b.Object, Cat: "state", Ph: "X", Ts: b.Step + 1, Dur: max(ev.Step-b.Step-1, 1), Tid: ev.Task})
+			}
+		default:
+			if running != ev.Task {
+				endRun(ev.Step)
+				running, runStart = ev.Task, ev.Step
+			}
+			if ev.Kind == Block {
+				blocked[ev.Task] = ev
+				endRun(ev.Step + 1)
+			} else if ev.Kind == Exit {
+				endRun(ev.Step + 1)
+			} else if b, ok := blocked[ev.Peer]; ok && ev.Kind == Kill {
+				delete(blocked, ev.Peer)
+				add(chromeEvent{Name: "blocked on " + b.Object, Cat: "state", Ph: "X", Ts: b.Step + 1, Dur: max(ev.Step-b.Step, 1), Tid: ev.Peer})
+			}
+		}
+
+		args := map[string]interface{}{"step": ev.Step}
+		if ev.Object != "" {
+			args["object"] = ev.Object
+		}
+		if ev.Peer != 0 {
+			args["peer"] = taskName(ev.Peer)
+		}
+		if len(ev.Stack) > 0 {
+			args["stack"] = strings.Join(ev.Stack, "\n")
+		}
+		add(chromeEvent{Name: strings.TrimSpace(string(ev.Kind) + " " + ev.Object), Cat: string(ev.Kind), Ph: "i", Ts: ev.Step, Tid: ev.Task, Scope: "t", Args: args})
+	}
+	endRun(steps)
+	for _, task := range d.tasks {
+		b, ok := blocked[task]
+		if !ok {
+			continue
+		}
+		add(chromeEvent{Name: "blocked on " + b.Object, Cat: "state", Ph: "X", Ts: b.Step + 1, Dur: max(steps-b.Step-1, 1), Tid: task})
+	}
+
+	for i, m := range d.messages {
+		add(chromeEvent{Name: m.label, Cat: "flow", Ph: "s", Ts: m.step, Tid: m.from, ID: i + 1})
+		add(chromeEvent{Name: m.label, Cat: "flow", Ph: "f", Ts: m.step + 1, Tid: m.to, ID: i + 1})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(ct)
+}
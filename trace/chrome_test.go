@@ -0,0 +1,62 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// TestWriteChrome verifies the state slices and flow arrows of the handoff
+// trace.
+func TestWriteChrome(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteChrome(&buf, handoffTrace); err != nil {
+		t.Fatal(err)
+	}
+	var ct chromeTrace
+	if err := json.Unmarshal(buf.Bytes(), &ct); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.Bytes())
+	}
+
+	type slice struct {
+		name    string
+		tid     int
+		ts, dur int
+	}
+	var slices []slice
+	flows, instants := 0, 0
+	for _, ev := range ct.TraceEvents {
+		switch ev.Ph {
+		case "X":
+			slices = append(slices, slice{ev.Name, ev.Tid, ev.Ts, ev.Dur})
+		case "s":
+			flows++
+		case "i":
+			instants++
+		}
+	}
+	want := []slice{
+		{"running", 0, 0, 2},
+		{"running", 1, 2, 1},
+		{"running", 2, 3, 1},
+		{"blocked on mutex 1", 2, 4, 1},
+		{"running", 1, 4, 3},
+		{"running", 2, 7, 1},
+		{"running", 1, 8, 1},
+	}
+	if len(slices) != len(want) {
+		t.Fatalf("slices = %v, want %v", slices, want)
+	}
+	for i := range want {
+		if slices[i] != want[i] {
+			t.Errorf("slice %d = %v, want %v", i, slices[i], want[i])
+		}
+	}
+	// Two spawns, the lock handoff and the deduplicated rendezvous.
+	if flows != 4 {
+		t.Errorf("flows = %d, want 4", flows)
+	}
+	if instants != len(handoffTrace.Events) {
+		t.Errorf("instants = %d, want %d", instants, len(handoffTrace.Events))
+	}
+}
@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Coverage counts the interleavings observed across many runs.
+//
+// A coverage point is an ordered pair of operations by different tasks on
+// the same object that happened one after the other, identified by the
+// kind and source location of each, such as
+//
+//	lock queue.go:31 -> lock queue.go:52
+//
+// Each point counts the runs that covered it. Coverage from separate
+// processes or CI shards is combined with Merge.
+type Coverage struct {
+	Version int `json:"version"`
+
+	// Runs is the number of schedules the coverage was gathered from.
+	Runs int `json:"runs"`
+
+	Points map[string]int `json:"points"`
+}
+
+// NewCoverage returns empty coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{Version: Version, Points: make(map[string]int)}
+}
+
+// Add records the interleavings of one run.
+func (c *Coverage) Add(t *Trace) {
+	c.Runs++
+	last := make(map[string]Event)
+	seen := make(map[string]bool)
+	for _, ev := range t.Events {
+		if ev.Object == "" {
+			continue
+		}
+		if prev, ok := last[ev.Object]; ok && prev.Task != ev.Task {
+			point := site(prev) + " -> " + site(ev)
+			if !seen[point] {
+				seen[point] = true
+				c.Points[point]++
+			}
+		}
+		last[ev.Object] = ev
+	}
+}
+
+// Merge adds the coverage in o to c.
+func (c *Coverage) Merge(o *Coverage) {
+	c.Runs += o.Runs
+	for point, n := range o.Points {
+		c.Points[point] += n
+	}
+}
+
+// Sites returns the locations, as file:line, that appear in the covered
+// points. Points at events recorded without a stack contribute none.
+func (c *Coverage) Sites() map[string]bool {
+	sites := make(map[string]bool)
+	for point := range c.Points {
+		for _, side := range strings.Split(point, " -> ") {
+			_, loc, _ := strings.Cut(side, " ")
+			if i := strings.LastIndexByte(loc, ':'); i > 0 && isDigits(loc[i+1:]) {
+				sites[loc] = true
+			}
+		}
+	}
+	return sites
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// site identifies where an event happened: its kind and the innermost
+// frame of its stack, or its object when no stack was recorded.
+func site(ev Event) string {
+	if pos := ev.Pos(); pos != "" {
+		return string(ev.Kind) + " " + pos
+	}
+	return string(ev.Kind) + " " + ev.Object
+}
+
+// ReadCoverage reads coverage from the named file.
+func ReadCoverage(name string) (*Coverage, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCoverage()
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if c.Version < 1 || c.Version > Version {
+		return nil, fmt.Errorf("%s: unsupported coverage version %d", name, c.Version)
+	}
+	if c.Points == nil {
+		c.Points = make(map[string]int)
+	}
+	return c, nil
+}
+
+// WriteFile writes c to the named file.
+func (c *Coverage) WriteFile(name string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return os.WriteFile(name, buf.Bytes(), 0o644)
+}
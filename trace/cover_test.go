@@ -0,0 +1,49 @@
+package trace
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestCoverage verifies that only handoffs between tasks count, once per
+// run, and that merged coverage sums runs and points.
+func TestCoverage(t *testing.T) {
+	run := &Trace{Events: []Event{
+		{Task: 1, Kind: Lock, Object: "mutex 1", Stack: []string{"q.Push q.go:10"}},
+		{Task: 1, Kind: Unlock, Object: "mutex 1", Stack: []string{"q.Push q.go:12"}},
+		{Task: 2, Kind: Lock, Object: "mutex 1", Stack: []string{"q.Pop q.go:20"}},
+		{Task: 1, Kind: Lock, Object: "mutex 1", Stack: []string{"q.Push q.go:10"}},
+		{Task: 2, Kind: Send, Object: "chan 1"},
+		{Task: 1, Kind: Recv, Object: "chan 1"},
+	}}
+	c := NewCoverage()
+	c.Add(run)
+	c.Add(run)
+	want := map[string]int{
+		"unlock q.go:12 -> lock q.go:20": 2,
+		"lock q.go:20 -> lock q.go:10":   2,
+		"send chan 1 -> recv chan 1":     2,
+	}
+	if !reflect.DeepEqual(c.Points, want) {
+		t.Errorf("points = %v, want %v", c.Points, want)
+	}
+
+	wantSites := map[string]bool{"q.go:10": true, "q.go:12": true, "q.go:20": true}
+	if got := c.Sites(); !reflect.DeepEqual(got, wantSites) {
+		t.Errorf("sites = %v, want %v", got, wantSites)
+	}
+
+	name := filepath.Join(t.TempDir(), "cover.json")
+	if err := c.WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+	shard, err := ReadCoverage(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Merge(shard)
+	if c.Runs != 4 || c.Points["send chan 1 -> recv chan 1"] != 4 {
+		t.Errorf("merged coverage = %+v", c)
+	}
+}
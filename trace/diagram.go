@@ -0,0 +1,199 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A message is an arrow between two tasks in a sequence diagram.
+type message struct {
+	step     int
+	from, to int
+	label    string
+}
+
+// A note annotates a single task's lifeline.
+type note struct {
+	step int
+	task int
+	text string
+}
+
+// diagram is a trace reduced to lifelines, arrows and notes.
+type diagram struct {
+	tasks    []int
+	messages []message
+	notes    []note
+}
+
+// newDiagram derives a sequence diagram from the events of t. Spawns,
+// channel handoffs, mutex handoffs and condition signals become arrows;
+// other events become notes. A rendezvous recorded on both the send and the
+// receive side is drawn once.
+func newDiagram(t *Trace) *diagram {
+	d := new(diagram)
+	seen := make(map[int]bool)
+	addTask := func(task int) {
+		if !seen[task] {
+			seen[task] = true
+			d.tasks = append(d.tasks, task)
+		}
+	}
+	type handoff struct {
+		from, to int
+		object   string
+	}
+	pending := make(map[handoff]int)
+
+	for _, ev := range t.Events {
+		addTask(ev.Task)
+		if ev.Peer != 0 {
+			addTask(ev.Peer)
+		}
+		arrow := func(from, to int, label string) {
+			d.messages = append(d.messages, message{step: ev.Step, from: from, to: to, label: label})
+		}
+		annotate := func(text string) {
+			d.notes = append(d.notes, note{step: ev.Step, task: ev.Task, text: text})
+		}
+		switch {
+		case ev.Kind == Spawn && ev.Peer != 0:
+			arrow(ev.Task, ev.Peer, "spawn")
+		case ev.Kind == Send && ev.Peer != 0:
+			pending[handoff{ev.Task, ev.Peer, ev.Object}]++
+			arrow(ev.Task, ev.Peer, "send "+ev.Object)
+		case ev.Kind == Recv && ev.Peer != 0:
+			h := handoff{ev.Peer, ev.Task, ev.Object}
+			if pending[h] > 0 {
+				pending[h]--
+				continue
+			}
+			arrow(ev.Peer, ev.Task, "recv "+ev.Object)
+		case ev.Kind == Unlock && ev.Peer != 0:
+			arrow(ev.Task, ev.Peer, "hand off "+ev.Object)
+		case ev.Kind == Signal && ev.Peer != 0:
+			arrow(ev.Task, ev.Peer, "signal "+ev.Object)
+		case ev.Kind == Run || ev.Kind == Unblock:
+			// State changes are implied by the arrows and notes around
+			// them.
+		case ev.Kind == Block:
+			annotate("blocked on " + ev.Object)
+		default:
+			annotate(strings.TrimSpace(string(ev.Kind) + " " + ev.Object))
+		}
+	}
+	sort.Ints(d.tasks)
+	return d
+}
+
+func taskName(task int) string {
+	if task == 0 {
+		return "test"
+	}
+	return fmt.Sprintf("task %d", task)
+}
+
+// WriteMermaid writes the events of t as a Mermaid sequence diagram, with
+// one lifeline per task.
+func WriteMermaid(w io.Writer, t *Trace) error {
+	d := newDiagram(t)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "sequenceDiagram")
+	for _, task := range d.tasks {
+		fmt.Fprintf(bw, "    participant T%d as %s\n", task, taskName(task))
+	}
+	mi, ni := 0, 0
+	for mi < len(d.messages) || ni < len(d.notes) {
+		if ni == len(d.notes) || (mi < len(d.messages) && d.messages[mi].step <= d.notes[ni].step) {
+			m := d.messages[mi]
+			fmt.Fprintf(bw, "    T%d->>T%d: %s\n", m.from, m.to, mermaidText(m.label))
+			mi++
+			continue
+		}
+		n := d.notes[ni]
+		fmt.Fprintf(bw, "    Note over T%d: %s\n", n.task, mermaidText(n.text))
+		ni++
+	}
+	return bw.Flush()
+}
+
+// mermaidText escapes characters with meaning in Mermaid message text.
+func mermaidText(s string) string {
+	return strings.NewReplacer(";", "#59;", "#", "#35;").Replace(s)
+}
+
+// WriteDOT writes the events of t as a Graphviz digraph laid out as a
+// sequence diagram: each task is a column of points joined by a dashed
+// lifeline, and points at the same step share a rank.
+func WriteDOT(w io.Writer, t *Trace) error {
+	d := newDiagram(t)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph trace {")
+	fmt.Fprintln(bw, "    rankdir=TB;")
+	fmt.Fprintln(bw, "    node [shape=point];")
+	fmt.Fprintln(bw, "    edge [fontsize=10];")
+
+	// points[task] lists the steps at which a task has a point.
+	points := make(map[int][]int)
+	ranks := make(map[int][]string)
+	point := func(task, step int) string {
+		id := fmt.Sprintf("t%d_s%d", task, step)
+		steps := points[task]
+		if len(steps) == 0 || steps[len(steps)-1] != step {
+			points[task] = append(steps, step)
+			ranks[step] = append(ranks[step], id)
+		}
+		return id
+	}
+	type edge struct{ from, to, attrs string }
+	var edges []edge
+	mi, ni := 0, 0
+	for mi < len(d.messages) || ni < len(d.notes) {
+		if ni == len(d.notes) || (mi < len(d.messages) && d.messages[mi].step <= d.notes[ni].step) {
+			m := d.messages[mi]
+			edges = append(edges, edge{point(m.from, m.step), point(m.to, m.step), fmt.Sprintf("label=%q", m.label)})
+			mi++
+			continue
+		}
+		n := d.notes[ni]
+		id := point(n.task, n.step)
+		fmt.Fprintf(bw, "    %s [shape=box, fontsize=10, label=%q];\n", id, n.text)
+		ni++
+	}
+
+	for _, task := range d.tasks {
+		head := fmt.Sprintf("t%d", task)
+		fmt.Fprintf(bw, "    %s [shape=box, label=%q];\n", head, taskName(task))
+		prev := head
+		for _, step := range points[task] {
+			id := fmt.Sprintf("t%d_s%d", task, step)
+			fmt.Fprintf(bw, "    %s -> %s [style=dashed, arrowhead=none];\n", prev, id)
+			prev = id
+		}
+	}
+	fmt.Fprintf(bw, "    { rank=same; %s }\n", strings.Join(headNames(d.tasks), "; "))
+	steps := make([]int, 0, len(ranks))
+	for step := range ranks {
+		steps = append(steps, step)
+	}
+	sort.Ints(steps)
+	for _, step := range steps {
+		fmt.Fprintf(bw, "    { rank=same; %s }\n", strings.Join(ranks[step], "; "))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(bw, "    %s -> %s [%s, constraint=false];\n", e.from, e.to, e.attrs)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func headNames(tasks []int) []string {
+	names := make([]string, len(tasks))
+	for i, task := range tasks {
+		names[i] = fmt.Sprintf("t%d", task)
+	}
+	return names
+}
@@ -0,0 +1,67 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// handoffTrace spawns two tasks that exchange a value and a lock.
+var handoffTrace = &Trace{
+	Test: "TestHandoff",
+	Events: []Event{
+		{Step: 0, Task: 0, Kind: Spawn, Peer: 1},
+		{Step: 1, Task: 0, Kind: Spawn, Peer: 2},
+		{Step: 2, Task: 1, Kind: Lock, Object: "mutex 1"},
+		{Step: 3, Task: 2, Kind: Block, Object: "mutex 1"},
+		{Step: 4, Task: 1, Kind: Unlock, Object: "mutex 1", Peer: 2},
+		{Step: 5, Task: 2, Kind: Unblock},
+		{Step: 6, Task: 1, Kind: Send, Object: "chan 1", Peer: 2},
+		{Step: 7, Task: 2, Kind: Recv, Object: "chan 1", Peer: 1},
+		{Step: 8, Task: 1, Kind: Exit},
+	},
+}
+
+// TestWriteMermaid verifies arrows, notes and the deduplicated rendezvous.
+func TestWriteMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMermaid(&buf, handoffTrace); err != nil {
+		t.Fatal(err)
+	}
+	want := `sequenceDiagram
+    participant T0 as test
+    participant T1 as task 1
+    participant T2 as task 2
+    T0->>T1: spawn
+    T0->>T2: spawn
+    Note over T1: lock mutex 1
+    Note over T2: blocked on mutex 1
+    T1->>T2: hand off mutex 1
+    T1->>T2: send chan 1
+    Note over T1: exit
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestWriteDOT verifies that tasks become lifelines and handoffs edges.
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, handoffTrace); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"digraph trace {",
+		`t2 [shape=box, label="task 2"];`,
+		`t1_s4 -> t2_s4 [label="hand off mutex 1", constraint=false];`,
+		`t2_s3 [shape=box, fontsize=10, label="blocked on mutex 1"];`,
+		"t1 -> t1_s0 [style=dashed, arrowhead=none];",
+		"{ rank=same; t0; t1; t2 }",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
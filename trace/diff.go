@@ -0,0 +1,112 @@
+package trace
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+)
+
+// Divergence locates where two traces of the same test part ways.
+type Divergence struct {
+	// Choice is the index of the first scheduling decision that differs,
+	// or -1 if one sequence of choices is a prefix of the other.
+	Choice int
+
+	// Event is the index of the first event that differs, or -1 if one
+	// sequence of events is a prefix of the other.
+	Event int
+}
+
+// Diverge compares two traces decision by decision and event by event.
+// Events are compared by task, kind, object and peer; steps and stacks are
+// ignored.
+func Diverge(a, b *Trace) Divergence {
+	d := Divergence{Choice: -1, Event: -1}
+	for i := 0; i < len(a.Choices) && i < len(b.Choices); i++ {
+		if a.Choices[i] != b.Choices[i] {
+			d.Choice = i
+			break
+		}
+	}
+	for i := 0; i < len(a.Events) && i < len(b.Events); i++ {
+		if !sameEvent(a.Events[i], b.Events[i]) {
+			d.Event = i
+			break
+		}
+	}
+	return d
+}
+
+// Similarity scores how long two traces agree: the number of leading
+// decisions they share, then the number of leading events. Larger is more
+// similar.
+func Similarity(a, b *Trace) (choices, events int) {
+	d := Diverge(a, b)
+	choices = d.Choice
+	if choices < 0 {
+		choices = min(len(a.Choices), len(b.Choices))
+	}
+	events = d.Event
+	if events < 0 {
+		events = min(len(a.Events), len(b.Events))
+	}
+	return choices, events
+}
+
+func sameEvent(a, b Event) bool {
+	return a.Task == b.Task && a.Kind == b.Kind && a.Object == b.Object && a.Peer == b.Peer
+}
+
+// Class hashes the equivalence class of the trace's events: traces that
+// differ only in the order of independent events, those of different tasks
+// on different objects, hash the same, as such reorderings cannot change
+// what the run computed. Run, Block and Unblock events, which record how
+// the tasks were scheduled rather than what they did, are ignored; other
+// events are compared as Diverge compares them.
+//
+// The class is fixed by each task's sequence of events together with, for
+// each object, the order in which the tasks' events on it happened.
+func (t *Trace) Class() uint64 {
+	// Each event is identified by its task and its index among the task's
+	// events.
+	type id struct{ task, n int }
+	tasks := make(map[int][]Event)
+	objects := make(map[string][]id)
+	for _, ev := range t.Events {
+		switch ev.Kind {
+		case Run, Block, Unblock:
+			continue
+		}
+		n := len(tasks[ev.Task])
+		tasks[ev.Task] = append(tasks[ev.Task], ev)
+		if ev.Object != "" {
+			objects[ev.Object] = append(objects[ev.Object], id{ev.Task, n})
+		}
+	}
+
+	taskIDs := make([]int, 0, len(tasks))
+	for task := range tasks {
+		taskIDs = append(taskIDs, task)
+	}
+	slices.Sort(taskIDs)
+	names := make([]string, 0, len(objects))
+	for object := range objects {
+		names = append(names, object)
+	}
+	slices.Sort(names)
+
+	h := fnv.New64a()
+	for _, task := range taskIDs {
+		fmt.Fprintf(h, "task %d\n", task)
+		for _, ev := range tasks[task] {
+			fmt.Fprintf(h, "%s %q %d\n", ev.Kind, ev.Object, ev.Peer)
+		}
+	}
+	for _, object := range names {
+		fmt.Fprintf(h, "object %q\n", object)
+		for _, id := range objects[object] {
+			fmt.Fprintf(h, "%d.%d\n", id.task, id.n)
+		}
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,76 @@
+package trace
+
+import "testing"
+
+// TestDiverge verifies the first differing decision and event are found,
+// ignoring steps and stacks.
+func TestDiverge(t *testing.T) {
+	a := &Trace{
+		Choices: []int{0, 1, 1, 0},
+		Events: []Event{
+			{Step: 0, Task: 0, Kind: Spawn, Peer: 1},
+			{Step: 1, Task: 1, Kind: Lock, Object: "mutex 1", Stack: []string{"f a.go:1"}},
+			{Step: 2, Task: 1, Kind: Unlock, Object: "mutex 1"},
+		},
+	}
+	b := &Trace{
+		Choices: []int{0, 1, 0},
+		Events: []Event{
+			{Step: 0, Task: 0, Kind: Spawn, Peer: 1},
+			{Step: 1, Task: 1, Kind: Lock, Object: "mutex 1"},
+			{Step: 2, Task: 2, Kind: Block, Object: "mutex 1"},
+		},
+	}
+	if got, want := Diverge(a, b), (Divergence{Choice: 2, Event: 2}); got != want {
+		t.Errorf("Diverge = %+v, want %+v", got, want)
+	}
+	if c, e := Similarity(a, b); c != 2 || e != 2 {
+		t.Errorf("Similarity = %d, %d, want 2, 2", c, e)
+	}
+
+	prefix := &Trace{Choices: a.Choices[:2], Events: a.Events[:1]}
+	if got, want := Diverge(a, prefix), (Divergence{Choice: -1, Event: -1}); got != want {
+		t.Errorf("Diverge with prefix = %+v, want %+v", got, want)
+	}
+	if c, e := Similarity(a, prefix); c != 2 || e != 1 {
+		t.Errorf("Similarity with prefix = %d, %d, want 2, 1", c, e)
+	}
+}
+
+// TestClass verifies that traces differing only in the order of
+// independent events share a class, and that reordering events on the same
+// object changes it.
+func TestClass(t *testing.T) {
+	lock := func(task int, object string) []Event {
+		return []Event{
+			{Task: task, Kind: Lock, Object: object},
+			{Task: task, Kind: Unlock, Object: object},
+		}
+	}
+	trace := func(parts ...[]Event) *Trace {
+		tr := &Trace{}
+		for _, p := range parts {
+			tr.Events = append(tr.Events, p...)
+		}
+		for i := range tr.Events {
+			tr.Events[i].Step = i
+		}
+		return tr
+	}
+	spawn := []Event{{Task: 0, Kind: Spawn, Peer: 1}, {Task: 0, Kind: Spawn, Peer: 2}}
+
+	a := trace(spawn, lock(1, "mutex 1"), lock(2, "mutex 2"))
+	b := trace(spawn, lock(2, "mutex 2"), []Event{{Task: 1, Kind: Run}}, lock(1, "mutex 1"))
+	if a.Class() != b.Class() {
+		t.Error("traces reordering locks of different mutexes are in different classes")
+	}
+
+	c := trace(spawn, lock(1, "mutex 1"), lock(2, "mutex 1"))
+	d := trace(spawn, lock(2, "mutex 1"), lock(1, "mutex 1"))
+	if c.Class() == d.Class() {
+		t.Error("traces reordering locks of the same mutex are in the same class")
+	}
+	if a.Class() == c.Class() {
+		t.Error("traces locking different mutexes are in the same class")
+	}
+}
@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+)
+
+// Writer streams a trace to an underlying writer as its events occur, so
+// that runs of millions of steps need not hold their events in memory. The
+// trace is gzip-compressed; Read decompresses it transparently.
+//
+// A Writer keeps the first error it encounters and returns it from Close.
+type Writer struct {
+	gz     *gzip.Writer
+	bw     *bufio.Writer
+	sample int
+	events int
+	err    error
+}
+
+// streamHeader holds the members of a trace known before it runs.
+type streamHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	Package string `json:"package,omitempty"`
+	Test    string `json:"test"`
+	Seed    uint64 `json:"seed"`
+	Sampled int    `json:"sampled,omitempty"`
+}
+
+// NewWriter starts a trace on w with the package, test, seed and sampling
+// of header. Its events are written by Event, and its choices and failure
+// by Close.
+func NewWriter(w io.Writer, header *Trace) *Writer {
+	tw := &Writer{gz: gzip.NewWriter(w), sample: header.Sampled}
+	tw.bw = bufio.NewWriter(tw.gz)
+	data, err := json.Marshal(streamHeader{
+		Format:  Format,
+		Version: Version,
+		Package: header.Package,
+		Test:    header.Test,
+		Seed:    header.Seed,
+		Sampled: header.Sampled,
+	})
+	if err != nil {
+		tw.err = err
+		return tw
+	}
+	// Leave the object open for the events.
+	tw.write(data[:len(data)-1])
+	tw.write([]byte(`,"events":[`))
+	return tw
+}
+
+// Event writes ev to the trace, unless sampling drops it.
+func (w *Writer) Event(ev Event) error {
+	if w.sample > 1 && ev.Step%w.sample != 0 && ev.Kind != Spawn && ev.Kind != Exit {
+		return w.err
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+		return w.err
+	}
+	if w.events > 0 {
+		w.write([]byte{','})
+	}
+	w.events++
+	w.write([]byte{'\n'})
+	w.write(data)
+	return w.err
+}
+
+// Close ends the trace with the Choices, Failure and Location of end, known
+// once the run is over, and flushes it. It does not close the underlying
+// writer.
+func (w *Writer) Close(end *Trace) error {
+	data, err := json.Marshal(struct {
+		Choices  []int  `json:"choices"`
+		Failure  string `json:"failure,omitempty"`
+		Location string `json:"location,omitempty"`
+	}{end.Choices, end.Failure, end.Location})
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	w.write([]byte("\n],"))
+	if err == nil {
+		w.write(data[1:])
+	}
+	w.write([]byte{'\n'})
+	if err := w.bw.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if err := w.gz.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+func (w *Writer) write(data []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.bw.Write(data)
+}
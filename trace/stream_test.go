@@ -0,0 +1,63 @@
+package trace
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestWriterRoundTrip verifies that a streamed trace reads back like one
+// written whole, and that sampling keeps spawns and exits.
+func TestWriterRoundTrip(t *testing.T) {
+	events := []Event{
+		{Step: 0, Task: 0, Kind: Spawn, Peer: 1},
+		{Step: 1, Task: 1, Kind: Lock, Object: "mutex 1", Stack: []string{"app.f /src/app/f.go:3"}},
+		{Step: 2, Task: 1, Kind: Unlock, Object: "mutex 1"},
+		{Step: 3, Task: 1, Kind: Exit},
+		{Step: 4, Task: 0, Kind: Send, Object: "chan 1"},
+	}
+	tests := []struct {
+		sample int
+		steps  []int
+	}{
+		{0, []int{0, 1, 2, 3, 4}},
+		{2, []int{0, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, &Trace{Test: "TestQueue", Seed: 7, Sampled: tt.sample})
+		for _, ev := range events {
+			if err := w.Event(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(&Trace{Choices: []int{1, 0}, Failure: "deadlock", Location: "/src/app/f.go:3"}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("sample %d: Read: %v", tt.sample, err)
+		}
+		if got.Test != "TestQueue" || got.Seed != 7 || got.Failure != "deadlock" || got.Location != "/src/app/f.go:3" || !reflect.DeepEqual(got.Choices, []int{1, 0}) || got.Sampled != tt.sample {
+			t.Errorf("sample %d: got %+v", tt.sample, got)
+		}
+		var steps []int
+		for _, ev := range got.Events {
+			steps = append(steps, ev.Step)
+		}
+		if !reflect.DeepEqual(steps, tt.steps) {
+			t.Errorf("sample %d: steps = %v, want %v", tt.sample, steps, tt.steps)
+		}
+	}
+}
+
+// TestWriterEmpty verifies that a trace without events is well formed.
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf, &Trace{Test: "T"}).Close(&Trace{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,310 @@
+// Package trace defines the file format for recorded weft schedules.
+//
+// Traces are stored as JSON objects in files with the .wefttrace extension,
+// identified by a "format" member of "wefttrace" and a "version" member.
+// The format is stable so that traces kept as CI artifacts or attached to
+// issues remain replayable:
+//
+//   - Read accepts every version up to Version, upgrading older traces as
+//     it decodes them. A trace written by any release replays under every
+//     later release.
+//   - Within a version, members are only ever added, never renamed or
+//     removed, and readers ignore members they do not know.
+//   - Changes that older readers would misinterpret increment Version, and
+//     older readers reject such traces rather than misreplay them.
+//
+// Long runs stream their traces through a Writer, which compresses them with
+// gzip and can sample their events. Read accepts compressed and plain traces
+// alike.
+//
+// wefttest writes a trace for every failing schedule when WEFT_TRACE_DIR is
+// set, and replays one when WEFT_TRACE names a trace file. The weft command
+// uses the same files to replay and shrink failures outside go test.
+// WriteMermaid and WriteDOT render a trace as a sequence diagram for bug
+// reports and design documents, and WriteChrome converts it for trace
+// viewers such as Perfetto.
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Version is the version of the trace format written by this package.
+const Version = 1
+
+// Format identifies weft trace files; see the package documentation.
+const Format = "wefttrace"
+
+// Ext is the file name extension of trace files.
+const Ext = ".wefttrace"
+
+// Trace records a single run of a test under the deterministic scheduler.
+type Trace struct {
+	// Format is always Format. Traces written before it was introduced
+	// lack it.
+	Format string `json:"format,omitempty"`
+
+	// Version is the format version; see Version.
+	Version int `json:"version"`
+
+	// Package is the import path of the package containing the test. It
+	// is filled in by tools that know it; tests do not.
+	Package string `json:"package,omitempty"`
+
+	// Test is the name of the test that explored the schedule, as
+	// reported by testing.T.Name.
+	Test string `json:"test"`
+
+	// Seed seeds the decisions made after Choices are exhausted.
+	Seed uint64 `json:"seed"`
+
+	// Choices are the scheduling decisions made during the run, in order.
+	Choices []int `json:"choices"`
+
+	// Failure describes how the run failed, if it did.
+	Failure string `json:"failure,omitempty"`
+
+	// Location is the source position of the failure, as "file:line",
+	// when it is known, such as the site of a panic.
+	Location string `json:"location,omitempty"`
+
+	// Events are the scheduling and synchronization events of the run,
+	// in the order they occurred.
+	Events []Event `json:"events,omitempty"`
+
+	// Sampled, if greater than 1, means that only the events whose step
+	// is a multiple of Sampled were kept, along with every spawn and exit.
+	// Choices are always complete, so a sampled trace still replays.
+	Sampled int `json:"sampled,omitempty"`
+}
+
+// Kind identifies the type of an event.
+type Kind string
+
+// Event kinds. Spawn, Run, Block, Unblock, Exit and Kill change the state
+// of a task; the rest describe what it did while running.
+const (
+	Spawn   Kind = "spawn"   // Task started Peer.
+	Run     Kind = "run"     // Task was scheduled.
+	Block   Kind = "block"   // Task blocked on Object.
+	Unblock Kind = "unblock" // Task became runnable again.
+	Exit    Kind = "exit"    // Task returned.
+	Kill    Kind = "kill"    // Task killed Peer, crashing the node Object.
+
+	Lock   Kind = "lock"   // Task acquired the mutex Object.
+	Unlock Kind = "unlock" // Task released the mutex Object, handing it to Peer if set.
+	Send   Kind = "send"   // Task sent on the channel Object, to Peer if set.
+	Recv   Kind = "recv"   // Task received from the channel Object, from Peer if set.
+	Close  Kind = "close"  // Task closed the channel Object.
+	Signal Kind = "signal" // Task woke Peer through the condition variable Object.
+	Sleep  Kind = "sleep"  // Task slept until virtual time advanced.
+	Chaos  Kind = "chaos"  // Chaos injected the fault Object, a panic, cancel or delay, into Task.
+
+	Priority Kind = "priority" // Task set its priority to Object, a number.
+)
+
+// Event is one step of a recorded run.
+type Event struct {
+	// Step orders events within the trace, starting at 0.
+	Step int `json:"step"`
+
+	// Task is the task the event belongs to. Task 0 is the test itself.
+	Task int `json:"task"`
+
+	Kind Kind `json:"kind"`
+
+	// Object names the primitive involved, such as "mutex 3" or "chan 1".
+	Object string `json:"object,omitempty"`
+
+	// Peer is the other task involved, or 0 if none. Only spawned tasks
+	// are peers, never the test itself.
+	Peer int `json:"peer,omitempty"`
+
+	// Stack holds the caller frames, innermost first, formatted as
+	// "function file:line".
+	Stack []string `json:"stack,omitempty"`
+}
+
+// Pos returns the source position of the code that caused the event, as
+// "file:line" taken from the innermost frame of its stack, or "" if the
+// event has no stack.
+func (e Event) Pos() string {
+	if len(e.Stack) == 0 {
+		return ""
+	}
+	frame := e.Stack[0]
+	if i := strings.LastIndexByte(frame, ' '); i >= 0 {
+		frame = frame[i+1:]
+	}
+	return frame
+}
+
+// String describes the event for failure messages and tools, as in
+// "task 2 blocked on chan 1 at queue.go:42".
+func (e Event) String() string {
+	var b strings.Builder
+	if e.Task == 0 {
+		b.WriteString("test")
+	} else {
+		fmt.Fprintf(&b, "task %d", e.Task)
+	}
+	switch {
+	case e.Kind == Spawn && e.Peer == 0:
+		b.WriteString(" spawned")
+	case e.Kind == Block:
+		b.WriteString(" blocked on")
+	case e.Kind == Unblock:
+		b.WriteString(" unblocked")
+	default:
+		b.WriteString(" " + string(e.Kind))
+	}
+	if e.Object != "" {
+		b.WriteString(" " + e.Object)
+	}
+	if e.Peer != 0 {
+		fmt.Fprintf(&b, " (task %d)", e.Peer)
+	}
+	if pos := e.Pos(); pos != "" {
+		b.WriteString(" at " + filepath.Base(pos))
+	}
+	return b.String()
+}
+
+// Live returns the last event of each spawned task that had not exited by
+// the end of the trace, in task order. For a deadlocked or failed run it
+// shows where each task was left. A task that recorded nothing after its
+// spawn is reported at the spawn, as a Spawn event of its own without a
+// peer.
+func (t *Trace) Live() []Event {
+	last := make(map[int]Event)
+	var tasks []int
+	see := func(ev Event) {
+		if _, ok := last[ev.Task]; !ok {
+			tasks = append(tasks, ev.Task)
+		}
+		last[ev.Task] = ev
+	}
+	for _, ev := range t.Events {
+		if ev.Kind == Spawn && ev.Peer != 0 {
+			see(Event{Step: ev.Step, Task: ev.Peer, Kind: Spawn, Stack: ev.Stack})
+		}
+		if ev.Task != 0 {
+			see(ev)
+		}
+	}
+	sort.Ints(tasks)
+	var live []Event
+	for _, task := range tasks {
+		if ev := last[task]; ev.Kind != Exit {
+			live = append(live, ev)
+		}
+	}
+	return live
+}
+
+// Lineage returns the tasks that spawned task, as far as the spawn events
+// of the trace record: its parent first, and last the test, task 0, when
+// the whole line is recorded.
+func (t *Trace) Lineage(task int) []int {
+	parent := make(map[int]int)
+	for _, ev := range t.Events {
+		if ev.Kind == Spawn && ev.Peer != 0 {
+			parent[ev.Peer] = ev.Task
+		}
+	}
+	var lineage []int
+	for task != 0 {
+		p, ok := parent[task]
+		if !ok {
+			break
+		}
+		lineage = append(lineage, p)
+		task = p
+	}
+	return lineage
+}
+
+// Describe returns ev.String followed by the lineage of its task, for
+// reports of unfinished tasks, as in "task 3 blocked on chan 1 at
+// queue.go:42, spawned by test > task 1".
+func (t *Trace) Describe(ev Event) string {
+	lineage := t.Lineage(ev.Task)
+	if len(lineage) == 0 {
+		return ev.String()
+	}
+	names := make([]string, len(lineage))
+	for i, task := range lineage {
+		name := "test"
+		if task != 0 {
+			name = fmt.Sprintf("task %d", task)
+		}
+		names[len(lineage)-1-i] = name
+	}
+	return ev.String() + ", spawned by " + strings.Join(names, " > ")
+}
+
+// Read decodes a trace from r, decompressing it if it was written by a
+// Writer.
+func Read(r io.Reader) (*Trace, error) {
+	br := bufio.NewReader(r)
+	r = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("trace: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	var t Trace
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, fmt.Errorf("trace: %w", err)
+	}
+	if t.Format != "" && t.Format != Format {
+		return nil, fmt.Errorf("trace: not a weft trace: format %q", t.Format)
+	}
+	if t.Version < 1 || t.Version > Version {
+		return nil, fmt.Errorf("trace: unsupported version %d", t.Version)
+	}
+	return &t, nil
+}
+
+// ReadFile reads the trace stored in the named file.
+func ReadFile(name string) (*Trace, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// Write encodes t to w as indented JSON in the current format, setting its
+// Format and Version.
+func (t *Trace) Write(w io.Writer) error {
+	t.Format, t.Version = Format, Version
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// WriteFile writes t to the named file.
+func (t *Trace) WriteFile(name string) error {
+	var buf bytes.Buffer
+	if err := t.Write(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(name, buf.Bytes(), 0o644)
+}
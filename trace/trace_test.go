@@ -0,0 +1,141 @@
+package trace
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRoundTrip verifies that a written trace reads back unchanged.
+func TestRoundTrip(t *testing.T) {
+	want := &Trace{
+		Package: "example.com/p",
+		Test:    "TestQueue",
+		Seed:    42,
+		Choices: []int{0, 2, 1},
+		Failure: "deadlock",
+	}
+	name := filepath.Join(t.TempDir(), "trace"+Ext)
+	if err := want.WriteFile(name); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	got, err := ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got.Version != Version {
+		t.Errorf("version = %d, want %d", got.Version, Version)
+	}
+}
+
+// TestReadRejectsUnknownVersion verifies that traces from newer formats are
+// not misread.
+func TestReadRejectsUnknownVersion(t *testing.T) {
+	for _, in := range []string{`{"version": 0}`, `{"version": 99}`, `not json`} {
+		if _, err := Read(strings.NewReader(in)); err == nil {
+			t.Errorf("Read(%q) succeeded", in)
+		}
+	}
+	var buf bytes.Buffer
+	(&Trace{Test: "T"}).Write(&buf)
+	if _, err := Read(&buf); err != nil {
+		t.Errorf("Read of current version: %v", err)
+	}
+}
+
+// TestEventString verifies the one-line description of events, with the
+// position of their innermost frame.
+func TestEventString(t *testing.T) {
+	tests := []struct {
+		ev   Event
+		want string
+	}{
+		{Event{Task: 2, Kind: Block, Object: "chan 1", Stack: []string{"app.(*Queue).Push /src/app/queue.go:42", "app.producer /src/app/main.go:10"}}, "task 2 blocked on chan 1 at queue.go:42"},
+		{Event{Task: 0, Kind: Spawn, Peer: 3}, "test spawn (task 3)"},
+		{Event{Task: 1, Kind: Unlock, Object: "mutex 2", Peer: 4}, "task 1 unlock mutex 2 (task 4)"},
+	}
+	for _, tt := range tests {
+		if got := tt.ev.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+	if got := tests[0].ev.Pos(); got != "/src/app/queue.go:42" {
+		t.Errorf("Pos() = %q, want /src/app/queue.go:42", got)
+	}
+}
+
+// TestLive verifies that only spawned tasks that never exited are reported,
+// at their last event.
+func TestLive(t *testing.T) {
+	tr := &Trace{Events: []Event{
+		{Step: 0, Task: 0, Kind: Spawn, Peer: 1},
+		{Step: 1, Task: 0, Kind: Spawn, Peer: 2},
+		{Step: 2, Task: 2, Kind: Lock, Object: "mutex 1"},
+		{Step: 3, Task: 1, Kind: Block, Object: "mutex 1"},
+		{Step: 4, Task: 2, Kind: Block, Object: "chan 1"},
+		{Step: 5, Task: 3, Kind: Exit},
+	}}
+	live := tr.Live()
+	if len(live) != 2 || live[0].Step != 3 || live[1].Step != 4 {
+		t.Errorf("Live() = %v, want the events at steps 3 and 4", live)
+	}
+}
+
+// TestLineage verifies that a task's lineage follows the spawn events back
+// to the test, and that Live reports a task that recorded nothing at its
+// spawn, described with its lineage.
+func TestLineage(t *testing.T) {
+	tr := &Trace{Events: []Event{
+		{Step: 0, Task: 0, Kind: Spawn, Peer: 1},
+		{Step: 1, Task: 1, Kind: Spawn, Peer: 2},
+		{Step: 2, Task: 1, Kind: Exit},
+		{Step: 3, Task: 2, Kind: Spawn, Peer: 3, Stack: []string{"app.serve /src/app/server.go:7"}},
+	}}
+	if got := tr.Lineage(3); !reflect.DeepEqual(got, []int{2, 1, 0}) {
+		t.Errorf("Lineage(3) = %v, want [2 1 0]", got)
+	}
+	live := tr.Live()
+	if len(live) != 2 || live[1].Task != 3 {
+		t.Fatalf("Live() = %v, want tasks 2 and 3", live)
+	}
+	want := "task 3 spawned at server.go:7, spawned by test > task 1 > task 2"
+	if got := tr.Describe(live[1]); got != want {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+}
+
+// TestReadV1 verifies that traces written by the first release of the format,
+// before it carried a format member, still read and are upgraded on write.
+func TestReadV1(t *testing.T) {
+	tr, err := ReadFile(filepath.Join("testdata", "v1"+Ext))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if tr.Test != "TestQueue" || tr.Seed != 42 || !reflect.DeepEqual(tr.Choices, []int{1, 0, 2}) || len(tr.Events) != 2 {
+		t.Errorf("got %+v", tr)
+	}
+	if got := tr.Events[0].Pos(); got != "/src/app/queue_test.go:12" {
+		t.Errorf("Pos() = %q", got)
+	}
+	var buf bytes.Buffer
+	if err := tr.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"format": "wefttrace"`) {
+		t.Errorf("written trace lacks the format member:\n%s", buf.String())
+	}
+}
+
+// TestReadRejectsOtherFormats verifies that JSON files that are not weft
+// traces are refused.
+func TestReadRejectsOtherFormats(t *testing.T) {
+	in := `{"format": "chrome", "version": 1}`
+	if _, err := Read(strings.NewReader(in)); err == nil {
+		t.Errorf("Read(%q) succeeded", in)
+	}
+}
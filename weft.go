@@ -3,14 +3,20 @@
 package weft
 
 import (
+	"io"
 	"time"
 
 	"github.com/mziter/weft/internal/scheduler"
+	"github.com/mziter/weft/trace"
 )
 
 // Scheduler controls the execution of deterministic tasks.
 type Scheduler struct {
 	sched *scheduler.Scheduler
+
+	// harness and name are set for the members of a Harness.
+	harness *Harness
+	name    string
 }
 
 // NewScheduler creates a new deterministic scheduler with the given seed.
@@ -20,39 +26,386 @@ func NewScheduler(seed uint64) *Scheduler {
 	}
 }
 
-// Go spawns a new deterministic goroutine.
+// NewSchedulerWithOptions creates a new deterministic scheduler with the
+// given seed, configured by opts.
+func NewSchedulerWithOptions(seed uint64, opts Options) *Scheduler {
+	s := NewReplayScheduler(seed, opts.Replay)
+	if opts.MaxSteps > 0 {
+		s.sched.SetMaxPoints(opts.MaxSteps, ErrStepLimit)
+	}
+	if opts.NoEvents {
+		s.sched.SetRecording(false)
+	}
+	if opts.EventMemory > 0 {
+		s.SetEventMemory(opts.EventMemory)
+	}
+	if opts.Stream != nil {
+		s.StreamEvents(opts.Stream)
+	}
+	if opts.Chaos.Rate > 0 {
+		s.SetChaos(opts.Chaos)
+	}
+	if opts.Observer != nil {
+		s.sched.SetObserver(opts.Observer)
+	}
+	if opts.StrictPriority {
+		s.sched.SetStrictPriority(true)
+	}
+	return s
+}
+
+// NewReplayScheduler creates a scheduler that replays a recorded sequence of
+// scheduling decisions, as returned by Choices, and then continues with
+// decisions drawn from seed.
+func NewReplayScheduler(seed uint64, choices []int) *Scheduler {
+	return &Scheduler{
+		sched: scheduler.NewReplay(seed, choices),
+	}
+}
+
+// Reset readies s for another run, as though it had just been created by
+// NewScheduler(seed), keeping the buffers it has grown so that exploring
+// many seeds does not allocate a scheduler for each. Call it once Wait has
+// returned; tasks of a killed node that never returned keep to the previous
+// run.
+func (s *Scheduler) Reset(seed uint64) {
+	s.sched = s.sched.Reset(seed, nil)
+}
+
+// ResetReplay is like Reset, but readies s to replay choices, as though it
+// had been created by NewReplayScheduler(seed, choices).
+func (s *Scheduler) ResetReplay(seed uint64, choices []int) {
+	s.sched = s.sched.Reset(seed, choices)
+}
+
+// Choices returns the scheduling decisions made so far.
+func (s *Scheduler) Choices() []int {
+	return s.sched.Choices()
+}
+
+// Seed returns the seed s draws its decisions from, as given to
+// NewScheduler or Reset.
+func (s *Scheduler) Seed() uint64 {
+	return s.sched.Seed()
+}
+
+// CurrentStep returns the position of the run in its schedule: the Step the
+// next trace event will have. With Seed and Context.TaskID it tags log
+// lines so that the output of interleaved tasks can be lined up with the
+// trace and compared across runs:
+//
+//	t.Logf("seed=%d step=%d task=%d: sent %v", s.Seed(), s.CurrentStep(), ctx.TaskID(), msg)
+func (s *Scheduler) CurrentStep() int {
+	return s.sched.Steps()
+}
+
+// Events returns the scheduling and synchronization events recorded so far.
+func (s *Scheduler) Events() []trace.Event {
+	return s.sched.Events()
+}
+
+// StreamEvents makes the scheduler write each event to w as it is recorded,
+// keeping only the most recent in Events, so that runs of any length use
+// bounded memory.
+func (s *Scheduler) StreamEvents(w *trace.Writer) {
+	s.sched.Stream(w)
+}
+
+// SetEventMemory bounds the memory the recorded events take to about bytes,
+// moving older events to a temporary file beyond it, so that long runs keep
+// their whole trace. A bytes of 0 or less lifts the bound.
+func (s *Scheduler) SetEventMemory(bytes int) {
+	s.sched.SetEventMemory(bytes)
+}
+
+// SetInteractive makes the scheduler put each choice between runnable tasks
+// to a person: it lists the candidates on w and reads commands from r, which
+// can also inspect tasks, events and primitives. The decisions are recorded
+// in Choices, so an interactive session can be saved and replayed.
+func (s *Scheduler) SetInteractive(r io.Reader, w io.Writer) {
+	s.sched.SetDecider(scheduler.NewInteractive(r, w))
+}
+
+// SetInteractiveRewind lets the person in an interactive session go back
+// in the run: the command "rewind N" calls rewind with the number of
+// decisions the run had made N decisions ago, for the caller to replay the
+// run up to there and resume the session. rewind should not return. Call it
+// after SetInteractive.
+func (s *Scheduler) SetInteractiveRewind(rewind func(decisions int)) {
+	s.sched.SetRewind(rewind)
+}
+
+// Choose returns a number in [0, n) as a decision of the schedule: drawn
+// from the seed, recorded in Choices and repeated on replay. Simulations use
+// it for choices other than which task runs, such as whether a message is
+// lost, so that a failure they cause replays and shrinks like any other.
+// Shrinking favors 0, so it should be the uneventful option.
+func (s *Scheduler) Choose(n int) int {
+	return s.sched.Choose(n)
+}
+
+// chanceResolution is the number of outcomes Chance draws a probability from.
+const chanceResolution = 1 << 16
+
+// Chance reports whether an event of probability p happens, as a decision
+// of the schedule. Decision 0 never makes it happen, so shrinking a failing
+// trace keeps only the faults the failure needs. A p of 0 or less, or 1 or
+// more, makes no decision.
+func (s *Scheduler) Chance(p float64) bool {
+	switch {
+	case p <= 0:
+		return false
+	case p >= 1:
+		return true
+	}
+	return s.Choose(chanceResolution) >= chanceResolution-int(p*chanceResolution)
+}
+
+// Go spawns a new deterministic goroutine on the scheduler of the current
+// run. It panics outside a run; see Bind.
 func Go(fn func(Context)) {
-	defaultScheduler.Go(fn)
+	current("Go").Go(fn)
 }
 
-// Go spawns a new deterministic goroutine on this scheduler.
+// Go spawns a new deterministic goroutine on this scheduler. Started from
+// a task, it is that task's child, and its context is cancelled with the
+// task's.
 func (s *Scheduler) Go(fn func(Context)) {
-	s.sched.Spawn(fn)
+	s.sched.Spawn(func(arg interface{}) {
+		fn(newTaskContext(s.sched, arg))
+	})
 }
 
-// Wait blocks until all spawned tasks complete.
-func (s *Scheduler) Wait() {
+// GoDetached is like Go, but the new goroutine's context is not cancelled
+// with that of the task starting it, as for background work that outlives
+// the request that began it. It panics outside a run; see Bind.
+func GoDetached(fn func(Context)) {
+	current("GoDetached").GoDetached(fn)
+}
+
+// GoDetached is like Go, but the new goroutine's context is not cancelled
+// with that of the task starting it.
+func (s *Scheduler) GoDetached(fn func(Context)) {
+	s.sched.SpawnDetached(func(arg interface{}) {
+		fn(newTaskContext(s.sched, arg))
+	})
+}
+
+// Wait lets tasks paused by RunUntil or Step run freely again, blocks until
+// all spawned tasks complete and returns the result of the run, as Result
+// does. If the tasks deadlock first, every one left blocked on a weft
+// primitive with no sleep or timer to wake it, Wait fails the run with
+// ErrDeadlock and returns, leaving them blocked.
+func (s *Scheduler) Wait() error {
 	s.sched.Wait()
+	return s.Result()
+}
+
+// RunUntil runs the tasks of s one at a time, each from one scheduling
+// point to the next, in an order drawn from the schedule, until pred holds,
+// and reports whether it did: false means no task could run any more, all
+// having finished or blocked. pred is checked before each turn, on the
+// caller, while every task is paused or blocked, so a phased test can drive
+// the system to a state, assert on it, and continue:
+//
+//	s.RunUntil(func() bool { return c.Leader() != "" })
+//	// ... crash the leader
+//	s.RunUntil(func() bool { return c.Leader() != old })
+//
+// Tasks stay paused when it returns until the next RunUntil or Step, or
+// until Wait lets them run freely again. Call it from the test rather than
+// from a task. A task waiting on anything but weft's primitives, such as a
+// Go channel or real I/O, holds RunUntil up until it stops waiting.
+func (s *Scheduler) RunUntil(pred func() bool) bool {
+	return s.sched.RunUntil(pred)
 }
 
-// Sleep pauses the current task for the specified duration.
+// Step is like RunUntil, but gives tasks n turns, each to run to its next
+// scheduling point, and returns the number given: fewer than n if no task
+// could run any more.
+func (s *Scheduler) Step(n int) int {
+	return s.sched.Step(n)
+}
+
+// Tasks returns the tasks of s that have not yet exited, in ID order.
+// Called while RunUntil or Step has them paused, it describes the state
+// they were paused in.
+func (s *Scheduler) Tasks() []Task {
+	infos := s.sched.Tasks()
+	tasks := make([]Task, len(infos))
+	for i, info := range infos {
+		tasks[i] = Task{
+			ID:        info.ID,
+			Parent:    info.Parent,
+			Node:      info.Group,
+			State:     taskStates[info.State],
+			BlockedOn: info.BlockedOn,
+			Spawn:     info.Spawn,
+			Steps:     info.Steps,
+			Priority:  info.Priority,
+		}
+	}
+	return tasks
+}
+
+// taskStates maps the scheduler's task states to weft's.
+var taskStates = map[scheduler.TaskState]TaskState{
+	scheduler.TaskReady:   TaskReady,
+	scheduler.TaskRunning: TaskRunning,
+	scheduler.TaskBlocked: TaskBlocked,
+	scheduler.TaskDone:    TaskKilled,
+}
+
+// Result returns the first failure of the run so far, or nil if there was
+// none. It wraps ErrStepLimit if a task took s past Options.MaxSteps,
+// ErrDeadlock if Wait found the tasks deadlocked, ErrTaskLeak if Run found
+// tasks unfinished otherwise, or whatever error was passed to Fail, such as
+// one from lincheck.Verify wrapping ErrLinearizability; test with
+// errors.Is.
+func (s *Scheduler) Result() error {
+	return s.sched.Err()
+}
+
+// Fail records err as a failure of the run, returned by Result and Wait
+// unless an earlier failure was. wefttest fails the schedule.
+func (s *Scheduler) Fail(err error) {
+	s.sched.Fail(err)
+}
+
+// Sleep pauses the current task for the specified duration of virtual
+// time, on the scheduler of the current run. It panics outside a run; see
+// Bind.
 func Sleep(d time.Duration) {
-	defaultScheduler.Sleep(d)
+	current("Sleep").Sleep(d)
 }
 
-// Sleep pauses the current task for the specified duration.
+// Sleep pauses the current task for the specified duration of the
+// scheduler's virtual time. The clock jumps to the earliest deadline once
+// every task is blocked, so a sleep takes no wall-clock time, and tasks
+// waking at the same time run in an order the schedule decides.
 func (s *Scheduler) Sleep(d time.Duration) {
 	s.sched.Sleep(d)
 }
 
-// After returns a channel that receives after the duration.
-func After(d time.Duration) <-chan time.Time {
-	return defaultScheduler.After(d)
+// After returns a channel that receives the virtual time after the
+// duration, on the scheduler of the current run. It panics outside a run;
+// see Bind.
+func After(d time.Duration) Chan[time.Time] {
+	return current("After").After(d)
+}
+
+// After returns a channel that receives the virtual time after the
+// duration of the scheduler's virtual time.
+func (s *Scheduler) After(d time.Duration) Chan[time.Time] {
+	return Chan[time.Time]{ch: s.sched.After(d)}
+}
+
+// Now returns the virtual time of the scheduler of the current run, for
+// the deadlines and expiry times the code under test computes, which then
+// come out the same in every run of a seed. It panics outside a run; see
+// Bind.
+func Now() time.Time {
+	return current("Now").Now()
+}
+
+// Now returns the scheduler's virtual time, which its Clock reads.
+func (s *Scheduler) Now() time.Time {
+	return s.sched.Now()
+}
+
+// Clock returns the virtual clock of s, which its Sleep and After wait on.
+// It starts at midnight UTC on 1 January 2000 and advances by itself once
+// every task is blocked while the test waits or steps them; Advance moves
+// it forward explicitly, firing the sleeps and timers due by then, so a
+// test can control time itself:
+//
+//	timeout := s.After(time.Minute)
+//	s.Clock().Advance(time.Minute)
+//	_, ok := timeout.TryRecv() // true
+//
+// Inside a testing/synctest bubble, see SetSynctest, it is the bubble's
+// fake clock, and Advance sleeps.
+func (s *Scheduler) Clock() Clock {
+	return s.sched
+}
+
+// Bind makes s the scheduler of the current run for the calling goroutine
+// and the tasks s spawns, which is the scheduler the package-level Go, Sleep
+// and After use, until unbind is called. wefttest's Explore and weft.Run
+// bind each run's scheduler, so that library code starting tasks with
+// weft.Go runs on it; outside a run, the package-level functions panic
+// rather than start tasks nothing waits for.
+//
+//	defer s.Bind()()
+func (s *Scheduler) Bind() (unbind func()) {
+	return s.sched.Bind()
+}
+
+// current returns the scheduler of the run the calling goroutine belongs
+// to, for the package-level function fn, and panics if there is none.
+func current(fn string) *Scheduler {
+	s := scheduler.Current()
+	if s == nil {
+		panic("weft: " + fn + " called outside a run; run the test with wefttest.Explore, weft.Run or weft.RunDefault, or use a Scheduler's methods")
+	}
+	return &Scheduler{sched: s}
 }
 
-// After returns a channel that receives after the duration.
-func (s *Scheduler) After(d time.Duration) <-chan time.Time {
-	return s.sched.After(d)
+// taskContext is the Context of a task; Done is closed when the task is
+// canceled by chaos, killed with its node or canceled with its parent.
+type taskContext struct {
+	s    *scheduler.Scheduler
+	done <-chan struct{}
+}
+
+// newTaskContext returns the context of a task of s spawned with arg.
+func newTaskContext(s *scheduler.Scheduler, arg interface{}) taskContext {
+	done, _ := arg.(<-chan struct{})
+	return taskContext{s: s, done: done}
+}
+
+func (taskContext) Yield()                  { scheduler.Checkpoint() }
+func (c taskContext) Done() <-chan struct{} { return c.done }
+func (c taskContext) SetPriority(p int)     { c.s.SetPriority(p) }
+func (c taskContext) TaskID() int           { return c.s.TaskID() }
+
+// taskGroup is the set of tasks of one incarnation of a Node.
+type taskGroup struct {
+	g *scheduler.Group
 }
 
-var defaultScheduler = NewScheduler(0)
\ No newline at end of file
+func (s *Scheduler) newGroup(name string) *taskGroup {
+	return &taskGroup{g: scheduler.NewGroup(name)}
+}
+
+// goIn spawns a task in g, unless g has been killed.
+func (s *Scheduler) goIn(g *taskGroup, fn func(Context)) {
+	s.sched.SpawnIn(g.g, func(arg interface{}) {
+		fn(newTaskContext(s.sched, arg))
+	})
+}
+
+// kill kills the tasks of g.
+func (s *Scheduler) kill(g *taskGroup) {
+	s.sched.Kill(g.g)
+}
+
+// SetChaos makes s inject the faults c enables into its tasks, from their
+// next scheduling point on.
+//
+//	s.SetChaos(weft.Chaos{Rate: 0.01, Cancel: true, MaxDelay: time.Second})
+func (s *Scheduler) SetChaos(c Chaos) {
+	sc := scheduler.Chaos{Rate: c.Rate, Cancel: c.Cancel, MaxDelay: c.MaxDelay}
+	if c.Panic {
+		sc.Panic = ErrChaos
+	}
+	s.sched.SetChaos(sc)
+}
+
+// SetSynctest marks s as running inside a testing/synctest bubble, so that
+// its Sleep and After wait out their full duration on the bubble's fake
+// clock and time.Now in the code under test agrees with the schedule's
+// time. wefttest.Synctest sets it.
+func (s *Scheduler) SetSynctest(on bool) {
+	s.sched.SetSynctest(on)
+}
@@ -3,7 +3,11 @@
 package weft
 
 import (
+	"io"
+	"math/rand/v2"
 	"time"
+
+	"github.com/mziter/weft/trace"
 )
 
 // Scheduler is a no-op in production mode.
@@ -14,6 +18,71 @@ func NewScheduler(seed uint64) *Scheduler {
 	return &Scheduler{}
 }
 
+// NewSchedulerWithOptions returns a no-op scheduler in production mode,
+// ignoring opts.
+func NewSchedulerWithOptions(seed uint64, opts Options) *Scheduler {
+	return &Scheduler{}
+}
+
+// NewReplayScheduler returns a no-op scheduler in production mode.
+func NewReplayScheduler(seed uint64, choices []int) *Scheduler {
+	return &Scheduler{}
+}
+
+// Reset is a no-op in production mode.
+func (s *Scheduler) Reset(seed uint64) {}
+
+// ResetReplay is a no-op in production mode.
+func (s *Scheduler) ResetReplay(seed uint64, choices []int) {}
+
+// Choices returns nil in production mode, where no decisions are made.
+func (s *Scheduler) Choices() []int {
+	return nil
+}
+
+// Seed returns 0 in production mode, where no decisions are drawn.
+func (s *Scheduler) Seed() uint64 {
+	return 0
+}
+
+// CurrentStep returns 0 in production mode, where nothing is recorded.
+func (s *Scheduler) CurrentStep() int {
+	return 0
+}
+
+// Events returns nil in production mode, where nothing is recorded.
+func (s *Scheduler) Events() []trace.Event {
+	return nil
+}
+
+// Choose returns a random number in [0, n) in production mode.
+func (s *Scheduler) Choose(n int) int {
+	return rand.IntN(n)
+}
+
+// Chance reports whether an event of probability p happens, at random in
+// production mode.
+func (s *Scheduler) Chance(p float64) bool {
+	return rand.Float64() < p
+}
+
+// StreamEvents is a no-op in production mode, where nothing is recorded.
+func (s *Scheduler) StreamEvents(w *trace.Writer) {}
+
+// SetEventMemory is a no-op in production mode, where nothing is recorded.
+func (s *Scheduler) SetEventMemory(bytes int) {}
+
+// SetInteractive is a no-op in production mode, where the Go runtime makes
+// every scheduling decision.
+func (s *Scheduler) SetInteractive(r io.Reader, w io.Writer) {}
+
+// SetInteractiveRewind is a no-op in production mode, where there are no
+// interactive sessions.
+func (s *Scheduler) SetInteractiveRewind(rewind func(decisions int)) {}
+
+// SetChaos is a no-op in production mode, where no faults are injected.
+func (s *Scheduler) SetChaos(c Chaos) {}
+
 // Go spawns a regular goroutine in production mode.
 func Go(fn func(Context)) {
 	go fn(productionContext{})
@@ -24,11 +93,51 @@ func (s *Scheduler) Go(fn func(Context)) {
 	go fn(productionContext{})
 }
 
-// Wait is a no-op in production mode.
-func (s *Scheduler) Wait() {
+// GoDetached spawns a regular goroutine in production mode, where no
+// context is cancelled.
+func GoDetached(fn func(Context)) {
+	go fn(productionContext{})
+}
+
+// GoDetached spawns a regular goroutine in production mode, where no
+// context is cancelled.
+func (s *Scheduler) GoDetached(fn func(Context)) {
+	go fn(productionContext{})
+}
+
+// Wait returns nil at once in production mode.
+func (s *Scheduler) Wait() error {
 	// In production mode, there's no tracking of goroutines
+	return nil
+}
+
+// RunUntil waits until pred holds in production mode, where tasks cannot be
+// paused, checking it every millisecond, and returns true.
+func (s *Scheduler) RunUntil(pred func() bool) bool {
+	for !pred() {
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+// Step returns 0 in production mode, where tasks cannot be paused.
+func (s *Scheduler) Step(n int) int {
+	return 0
+}
+
+// Tasks returns nil in production mode, where tasks are not tracked.
+func (s *Scheduler) Tasks() []Task {
+	return nil
 }
 
+// Result returns nil in production mode, where no run is checked.
+func (s *Scheduler) Result() error {
+	return nil
+}
+
+// Fail is a no-op in production mode.
+func (s *Scheduler) Fail(err error) {}
+
 // Sleep delegates to time.Sleep in production mode.
 func Sleep(d time.Duration) {
 	time.Sleep(d)
@@ -39,17 +148,98 @@ func (s *Scheduler) Sleep(d time.Duration) {
 	time.Sleep(d)
 }
 
-// After delegates to time.After in production mode.
-func After(d time.Duration) <-chan time.Time {
-	return time.After(d)
+// After returns a channel that receives the current time once the duration
+// has elapsed, like time.After, in production mode.
+func After(d time.Duration) Chan[time.Time] {
+	ch := make(timerChan, 1)
+	time.AfterFunc(d, ch.fire)
+	return Chan[time.Time]{ch: ch}
+}
+
+// timerChan is the channel of a timer made by After; its fire method,
+// rather than a closure, keeps After cheap enough to inline.
+type timerChan chan time.Time
+
+func (c timerChan) fire() {
+	c <- time.Now()
+}
+
+// After returns a channel that receives the current time once the duration
+// has elapsed, like time.After, in production mode.
+func (s *Scheduler) After(d time.Duration) Chan[time.Time] {
+	return After(d)
+}
+
+// Now returns time.Now() in production mode.
+func Now() time.Time {
+	return time.Now()
+}
+
+// Now returns time.Now() in production mode.
+func (s *Scheduler) Now() time.Time {
+	return time.Now()
 }
 
-// After delegates to time.After in production mode.
-func (s *Scheduler) After(d time.Duration) <-chan time.Time {
-	return time.After(d)
+// Clock returns the wall clock in production mode, where Advance sleeps,
+// since the wall clock cannot be moved.
+func (s *Scheduler) Clock() Clock {
+	return wallClock{}
 }
 
+// wallClock is the Clock of production mode.
+type wallClock struct{}
+
+func (wallClock) Now() time.Time          { return time.Now() }
+func (wallClock) Advance(d time.Duration) { time.Sleep(d) }
+
 type productionContext struct{}
 
-func (productionContext) Yield() {}
-func (productionContext) Done() <-chan struct{} { return nil }
\ No newline at end of file
+func (productionContext) Yield()                {}
+func (productionContext) Done() <-chan struct{} { return nil }
+func (productionContext) SetPriority(p int)     {}
+func (productionContext) TaskID() int           { return 0 }
+
+// taskGroup is the set of tasks of one incarnation of a Node. In
+// production mode its tasks are only told of a crash.
+type taskGroup struct {
+	done chan struct{}
+}
+
+func (s *Scheduler) newGroup(name string) *taskGroup {
+	return &taskGroup{done: make(chan struct{})}
+}
+
+// goIn spawns a goroutine in g, unless g has been killed.
+func (s *Scheduler) goIn(g *taskGroup, fn func(Context)) {
+	select {
+	case <-g.done:
+	default:
+		go fn(nodeContext{done: g.done})
+	}
+}
+
+// kill closes g's Done channel; g must not have been killed before.
+func (s *Scheduler) kill(g *taskGroup) {
+	close(g.done)
+}
+
+// nodeContext is the Context of a node's tasks; Done is closed when the
+// node crashes.
+type nodeContext struct {
+	done chan struct{}
+}
+
+func (nodeContext) Yield()                  {}
+func (c nodeContext) Done() <-chan struct{} { return c.done }
+func (nodeContext) SetPriority(p int)       {}
+func (nodeContext) TaskID() int             { return 0 }
+
+// Bind is a no-op in production mode, where the package-level Go, Sleep and
+// After need no scheduler.
+func (s *Scheduler) Bind() (unbind func()) {
+	return func() {}
+}
+
+// SetSynctest is a no-op in production mode, where Sleep and After are
+// time's and keep to a synctest bubble's clock already.
+func (s *Scheduler) SetSynctest(on bool) {}
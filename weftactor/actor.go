@@ -0,0 +1,349 @@
+// Package weftactor is a small actor framework on weft. Each actor runs on
+// a weft task and receives messages one at a time from a mailbox that is a
+// weft channel, so the order in which messages from different senders
+// arrive is a decision of the schedule, explored and replayed per seed.
+//
+// Actors form a tree: an actor's children are supervised under their
+// Props' Strategy, which restarts, resumes, stops or escalates an actor
+// whose Receive fails or panics. Races between restarts and messages in
+// flight, and between watchers and the actors they watch, become testable.
+//
+//	sys := weftactor.NewSystem(s)
+//	counter := sys.Spawn("counter", weftactor.Props{New: newCounter})
+//	counter.Send(increment{})
+//	// ...
+//	sys.Shutdown()
+package weftactor
+
+import (
+	"fmt"
+
+	"github.com/mziter/weft"
+)
+
+// defaultMailbox is the capacity of a mailbox whose Props give none.
+const defaultMailbox = 64
+
+// An Actor handles the messages sent to it, one at a time. Returning an
+// error, or panicking, fails the actor, and its Strategy decides what
+// happens next.
+type Actor interface {
+	Receive(ctx *Context, msg any) error
+}
+
+// ActorFunc adapts a function to the Actor interface.
+type ActorFunc func(ctx *Context, msg any) error
+
+// Receive calls f.
+func (f ActorFunc) Receive(ctx *Context, msg any) error {
+	return f(ctx, msg)
+}
+
+// Started is implemented by actors that act when they start, and each time
+// they restart, before receiving messages.
+type Started interface {
+	Started(ctx *Context)
+}
+
+// Stopped is implemented by actors that act when they stop.
+type Stopped interface {
+	Stopped(ctx *Context)
+}
+
+// Props describe how to create and supervise an actor.
+type Props struct {
+	// New returns a new instance of the actor: when it is spawned, and
+	// each time it restarts, discarding the state of the instance that
+	// failed.
+	New func() Actor
+
+	// Mailbox is the number of messages the actor's mailbox holds; Send
+	// waits while it is full. Zero means 64.
+	Mailbox int
+
+	// Supervisor decides what happens when the actor fails.
+	Supervisor Strategy
+}
+
+// Terminated is the message a watcher receives when an actor it watches
+// stops.
+type Terminated struct {
+	Ref *Ref
+}
+
+// PanicError is the error of an actor whose Receive panicked.
+type PanicError struct {
+	Value any
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("actor panicked: %v", e.Value)
+}
+
+// System is a tree of actors running on a scheduler. Its zero value is not
+// usable; create systems with NewSystem.
+type System struct {
+	s *weft.Scheduler
+
+	// mu guards the tree: the actors' children, watchers and states.
+	mu   weft.Mutex
+	root []*actor
+}
+
+// NewSystem returns a system without actors whose tasks run on s.
+func NewSystem(s *weft.Scheduler) *System {
+	return &System{s: s}
+}
+
+// Spawn starts a top-level actor called name.
+func (sys *System) Spawn(name string, props Props) *Ref {
+	return sys.spawn(nil, name, props)
+}
+
+// Shutdown stops every actor.
+func (sys *System) Shutdown() {
+	sys.mu.Lock()
+	root := sys.root
+	sys.root = nil
+	sys.mu.Unlock()
+	for _, a := range root {
+		a.stop()
+	}
+}
+
+// Ref refers to an actor, to send it messages.
+type Ref struct {
+	a *actor
+}
+
+// Name returns the path of the actor: its name, after its parent's path.
+func (r *Ref) Name() string {
+	return r.a.path
+}
+
+// Send puts msg in the actor's mailbox, waiting while it is full. It
+// reports false, dropping msg, if the actor has stopped.
+func (r *Ref) Send(msg any) bool {
+	return r.a.deliver(envelope{msg: msg})
+}
+
+// Stop stops the actor after the message it is handling, if any. The
+// messages left in its mailbox are dropped.
+func (r *Ref) Stop() {
+	r.a.stop()
+}
+
+// Context is an actor's view of the system while it handles a message.
+type Context struct {
+	a      *actor
+	sender *Ref
+}
+
+// Self returns the actor's own Ref.
+func (ctx *Context) Self() *Ref {
+	return ctx.a.ref
+}
+
+// Sender returns the actor that sent the message being handled with
+// Context.Send, or nil.
+func (ctx *Context) Sender() *Ref {
+	return ctx.sender
+}
+
+// Send sends msg to r with the actor as its sender, so r can reply.
+func (ctx *Context) Send(r *Ref, msg any) bool {
+	return r.a.deliver(envelope{msg: msg, sender: ctx.a.ref})
+}
+
+// Spawn starts a child of the actor called name. The child stops when the
+// actor stops or restarts.
+func (ctx *Context) Spawn(name string, props Props) *Ref {
+	return ctx.a.sys.spawn(ctx.a, name, props)
+}
+
+// Watch makes the actor receive a Terminated message when r stops, at
+// once if it has stopped already.
+func (ctx *Context) Watch(r *Ref) {
+	w, sys := ctx.a, ctx.a.sys
+	sys.mu.Lock()
+	stopped := r.a.stopped
+	if !stopped {
+		r.a.watchers = append(r.a.watchers, w)
+	}
+	sys.mu.Unlock()
+	if stopped {
+		sys.s.Go(func(weft.Context) { w.deliver(envelope{msg: Terminated{r}}) })
+	}
+}
+
+// envelope is a message and its sender.
+type envelope struct {
+	msg    any
+	sender *Ref
+}
+
+// actor is the state of an actor across its instances.
+type actor struct {
+	sys    *System
+	path   string
+	props  Props
+	parent *actor
+	ref    *Ref
+
+	mailbox weft.Chan[envelope]
+
+	// failures carries the errors children escalate.
+	failures weft.Chan[error]
+
+	// done is closed once the actor is told to stop.
+	done weft.Chan[struct{}]
+
+	// Guarded by sys.mu.
+	children []*actor
+	watchers []*actor
+	stopped  bool
+	restarts int
+}
+
+// spawn starts an actor called name, a child of parent or, if parent is
+// nil, at the top level.
+func (sys *System) spawn(parent *actor, name string, props Props) *Ref {
+	size := props.Mailbox
+	if size == 0 {
+		size = defaultMailbox
+	}
+	a := &actor{
+		sys:      sys,
+		path:     name,
+		props:    props,
+		parent:   parent,
+		mailbox:  weft.MakeChan[envelope](size),
+		failures: weft.MakeChan[error](1),
+		done:     weft.MakeChan[struct{}](0),
+	}
+	a.ref = &Ref{a}
+	sys.mu.Lock()
+	if parent == nil {
+		sys.root = append(sys.root, a)
+	} else {
+		a.path = parent.path + "/" + name
+		if parent.stopped {
+			a.stopped = true
+			a.done.Close()
+		}
+		parent.children = append(parent.children, a)
+	}
+	stopped := a.stopped
+	sys.mu.Unlock()
+	if !stopped {
+		sys.s.Go(func(weft.Context) { a.run() })
+	}
+	return a.ref
+}
+
+// deliver puts env in the mailbox, unless the actor stops first.
+func (a *actor) deliver(env envelope) bool {
+	if weft.TrySelect(weft.OnRecv(a.done)) == 0 {
+		return false
+	}
+	return weft.Select(weft.OnSend(a.mailbox, env), weft.OnRecv(a.done)) == 0
+}
+
+// stop tells the actor to stop, if it has not already.
+func (a *actor) stop() {
+	a.sys.mu.Lock()
+	defer a.sys.mu.Unlock()
+	if !a.stopped {
+		a.stopped = true
+		a.done.Close()
+	}
+}
+
+// run runs the actor's instances until it stops.
+func (a *actor) run() {
+	inst := a.start()
+	for {
+		msg := weft.OnRecv(a.mailbox)
+		failure := weft.OnRecv(a.failures)
+		var err error
+		switch weft.Select(weft.OnRecv(a.done), msg, failure) {
+		case 0:
+			a.terminate(inst)
+			return
+		case 1:
+			env, _ := msg.Value()
+			err = a.receive(inst, env)
+		case 2:
+			err, _ = failure.Value()
+		}
+		if err == nil {
+			continue
+		}
+		switch a.decide(err) {
+		case Resume:
+		case Restart:
+			a.stopChildren()
+			inst = a.start()
+		case Escalate:
+			if a.parent != nil {
+				a.parent.escalate(err)
+			}
+			fallthrough
+		case Stop:
+			a.stop()
+			a.terminate(inst)
+			return
+		}
+	}
+}
+
+// start returns a new instance of the actor, started.
+func (a *actor) start() Actor {
+	inst := a.props.New()
+	if s, ok := inst.(Started); ok {
+		s.Started(&Context{a: a})
+	}
+	return inst
+}
+
+// receive has inst handle env, turning a panic into an error.
+func (a *actor) receive(inst Actor, env envelope) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{r}
+		}
+	}()
+	return inst.Receive(&Context{a: a, sender: env.sender}, env.msg)
+}
+
+// escalate fails the actor with a child's error, unless it is stopping.
+func (a *actor) escalate(err error) {
+	weft.Select(weft.OnSend(a.failures, err), weft.OnRecv(a.done))
+}
+
+// stopChildren stops the actor's children.
+func (a *actor) stopChildren() {
+	a.sys.mu.Lock()
+	children := a.children
+	a.children = nil
+	a.sys.mu.Unlock()
+	for _, c := range children {
+		c.stop()
+	}
+}
+
+// terminate ends a stopped actor: it stops its children, lets inst know,
+// and tells its watchers.
+func (a *actor) terminate(inst Actor) {
+	a.stopChildren()
+	if s, ok := inst.(Stopped); ok {
+		s.Stopped(&Context{a: a})
+	}
+	a.sys.mu.Lock()
+	watchers := a.watchers
+	a.watchers = nil
+	a.sys.mu.Unlock()
+	for _, w := range watchers {
+		a.sys.s.Go(func(weft.Context) { w.deliver(envelope{msg: Terminated{a.ref}}) })
+	}
+}
@@ -0,0 +1,53 @@
+package weftactor
+
+// Directive is what a supervisor decides to do with a failed actor.
+type Directive int
+
+const (
+	// Restart replaces the actor's instance with a new one from its
+	// Props, stopping its children; its mailbox is kept, less the message
+	// that failed.
+	Restart Directive = iota
+
+	// Resume keeps the instance and goes on to the next message.
+	Resume
+
+	// Stop stops the actor.
+	Stop
+
+	// Escalate stops the actor and fails its parent with the same error,
+	// for the parent's supervisor to decide. A top-level actor just stops.
+	Escalate
+)
+
+// Strategy is how an actor is supervised. The zero Strategy restarts a
+// failed actor every time.
+type Strategy struct {
+	// Decide returns the directive for a failure with err, the error the
+	// actor returned, a *PanicError, or an error escalated by a child.
+	// Nil means always Restart.
+	Decide func(err error) Directive
+
+	// MaxRestarts is the number of times the actor restarts before a
+	// further Restart stops it instead. Zero means no limit.
+	MaxRestarts int
+}
+
+// decide returns the directive for the actor's failure with err.
+func (a *actor) decide(err error) Directive {
+	st := a.props.Supervisor
+	d := Restart
+	if st.Decide != nil {
+		d = st.Decide(err)
+	}
+	if d != Restart || st.MaxRestarts == 0 {
+		return d
+	}
+	a.sys.mu.Lock()
+	defer a.sys.mu.Unlock()
+	if a.restarts == st.MaxRestarts {
+		return Stop
+	}
+	a.restarts++
+	return Restart
+}
@@ -0,0 +1,177 @@
+package weftactor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/wefttest"
+)
+
+// errBoom fails a counter.
+var errBoom = errors.New("boom")
+
+// get asks a counter for its count, which it sends on reply.
+type get struct {
+	reply weft.Chan[int]
+}
+
+// counter adds the ints it receives, fails on errors and panics on
+// strings.
+type counter struct {
+	n int
+}
+
+func (c *counter) Receive(ctx *Context, msg any) error {
+	switch m := msg.(type) {
+	case int:
+		c.n += m
+	case get:
+		m.reply.Send(c.n)
+	case error:
+		return m
+	case string:
+		panic(m)
+	}
+	return nil
+}
+
+// count returns the count of the counter r.
+func count(r *Ref) int {
+	reply := weft.MakeChan[int](1)
+	r.Send(get{reply})
+	n, _ := reply.Recv()
+	return n
+}
+
+// counterProps returns the Props of a counter supervised by decide.
+func counterProps(decide func(error) Directive, maxRestarts int) Props {
+	return Props{
+		New:        func() Actor { return &counter{} },
+		Supervisor: Strategy{Decide: decide, MaxRestarts: maxRestarts},
+	}
+}
+
+// TestMessages verifies that an actor handles the messages of one sender
+// in order.
+func TestMessages(t *testing.T) {
+	sys := NewSystem(weft.NewScheduler(1))
+	defer sys.Shutdown()
+	r := sys.Spawn("counter", counterProps(nil, 0))
+	for i := 1; i <= 3; i++ {
+		r.Send(i)
+	}
+	if n := count(r); n != 6 {
+		t.Errorf("count = %d, want 6", n)
+	}
+	if r.Name() != "counter" {
+		t.Errorf("Name() = %q, want counter", r.Name())
+	}
+}
+
+// TestSupervision verifies each directive.
+func TestSupervision(t *testing.T) {
+	resume := func(error) Directive { return Resume }
+	sys := NewSystem(weft.NewScheduler(1))
+	defer sys.Shutdown()
+
+	restarted := sys.Spawn("restarted", counterProps(nil, 0))
+	restarted.Send(5)
+	restarted.Send(errBoom)
+	if n := count(restarted); n != 0 {
+		t.Errorf("count after Restart = %d, want 0", n)
+	}
+
+	resumed := sys.Spawn("resumed", counterProps(resume, 0))
+	resumed.Send(5)
+	resumed.Send("panic")
+	if n := count(resumed); n != 5 {
+		t.Errorf("count after Resume = %d, want 5", n)
+	}
+
+	limited := sys.Spawn("limited", counterProps(nil, 1))
+	terminated := weft.MakeChan[*Ref](1)
+	sys.Spawn("watcher", Props{New: func() Actor {
+		return ActorFunc(func(ctx *Context, msg any) error {
+			switch m := msg.(type) {
+			case *Ref:
+				ctx.Watch(m)
+			case Terminated:
+				terminated.Send(m.Ref)
+			}
+			return nil
+		})
+	}}).Send(limited)
+	limited.Send(errBoom)
+	limited.Send(errBoom)
+	if r, _ := terminated.Recv(); r != limited {
+		t.Errorf("Terminated.Ref = %v, want the actor past MaxRestarts", r)
+	}
+	if limited.Send(1) {
+		t.Error("Send() to a stopped actor = true")
+	}
+}
+
+// TestEscalate verifies that an escalated failure fails the parent, whose
+// restart stops its children.
+func TestEscalate(t *testing.T) {
+	sys := NewSystem(weft.NewScheduler(1))
+	defer sys.Shutdown()
+	children := weft.MakeChan[*Ref](2)
+	errs := weft.MakeChan[error](1)
+	parent := sys.Spawn("parent", Props{
+		New: func() Actor {
+			return &started{func(ctx *Context) {
+				children.Send(ctx.Spawn("child", counterProps(func(error) Directive { return Escalate }, 0)))
+			}}
+		},
+		Supervisor: Strategy{Decide: func(err error) Directive {
+			errs.Send(err)
+			return Restart
+		}},
+	})
+	first, _ := children.Recv()
+	if first.Name() != "parent/child" {
+		t.Errorf("Name() = %q, want parent/child", first.Name())
+	}
+	first.Send(errBoom)
+	if err, _ := errs.Recv(); err != errBoom {
+		t.Errorf("parent failed with %v, want %v", err, errBoom)
+	}
+	second, _ := children.Recv()
+	if first.Send(1) {
+		t.Error("child of the failed parent still receives messages")
+	}
+	second.Send(2)
+	if n := count(second); n != 2 {
+		t.Errorf("count of the new child = %d, want 2", n)
+	}
+	parent.Stop()
+}
+
+// started is an actor that only calls f when it starts.
+type started struct {
+	f func(*Context)
+}
+
+func (a *started) Started(ctx *Context)        { a.f(ctx) }
+func (a *started) Receive(*Context, any) error { return nil }
+
+// TestRestartRace explores a restart racing with messages in flight: the
+// messages sent around the failure reach either instance, and the count
+// is only ever that of the messages the new instance handled.
+func TestRestartRace(t *testing.T) {
+	wefttest.Explore(t, 50, func(s *weft.Scheduler) {
+		sys := NewSystem(s)
+		r := sys.Spawn("counter", counterProps(nil, 0))
+		s.Go(func(weft.Context) { r.Send(1) })
+		s.Go(func(weft.Context) { r.Send(errBoom) })
+		s.Go(func(weft.Context) {
+			r.Send(10)
+			if n := count(r); n != 0 && n != 1 && n != 10 && n != 11 {
+				t.Errorf("count = %d", n)
+			}
+			sys.Shutdown()
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package weftbus
+
+import (
+	"context"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+)
+
+// Consumer receives the messages of a consumer group. A consumer is used
+// by one task at a time.
+type Consumer struct {
+	b *Bus
+	g *group
+
+	// inflight are the deliveries to the consumer not yet settled, and
+	// closed is set, and closing closed, when the consumer closes. They
+	// are guarded by b.mu.
+	inflight []*delivery
+	closed   bool
+	closing  weft.Chan[struct{}]
+}
+
+// Message is a message delivered to a consumer.
+type Message struct {
+	Topic string
+	Key   string
+	Value []byte
+
+	// Offset is the position of the message in its topic, the same for
+	// every delivery of it.
+	Offset int64
+
+	// Attempt counts the deliveries of the message to the consumer's
+	// group: 1 the first time, more when it is delivered again.
+	Attempt int
+
+	b *Bus
+	d *delivery
+}
+
+// Receive returns the next message for the consumer, waiting until there
+// is one, the consumer is closed or ctx is done. Which of the messages
+// waiting is next is up to the topic's Faults.
+func (c *Consumer) Receive(ctx context.Context) (*Message, error) {
+	var done weft.Chan[struct{}]
+	if ctx.Done() != nil {
+		done = weft.MakeChan[struct{}](0)
+		stop := weftcontext.AfterFunc(ctx, done.Close)
+		defer stop()
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if m, err := c.next(); m != nil || err != nil {
+			return m, err
+		}
+		if ctx.Done() == nil {
+			weft.Select(weft.OnRecv(c.g.ready), weft.OnRecv(c.closing))
+		} else {
+			weft.Select(weft.OnRecv(c.g.ready), weft.OnRecv(c.closing), weft.OnRecv(done))
+		}
+	}
+}
+
+// next delivers a waiting message to the consumer, or returns nil if there
+// is none.
+func (c *Consumer) next() (*Message, error) {
+	b, g := c.b, c.g
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	if len(g.pending) == 0 {
+		return nil, nil
+	}
+	i := 0
+	if len(g.pending) > 1 && b.s.Chance(b.topicFaults(g.t.name).Reorder) {
+		i = 1 + b.s.Choose(len(g.pending)-1)
+	}
+	d := g.pending[i]
+	g.pending = append(g.pending[:i], g.pending[i+1:]...)
+	if len(g.pending) > 0 {
+		// Pass the wakeup on to any other consumer.
+		g.wake()
+	}
+
+	d.attempt++
+	d.consumer = c
+	d.done = weft.MakeChan[struct{}](0)
+	c.inflight = append(c.inflight, d)
+	if b.ackTimeout > 0 {
+		timeout := b.ackTimeout
+		b.s.Go(func(weft.Context) { b.expire(d, timeout) })
+	}
+	return &Message{
+		Topic:   g.t.name,
+		Key:     d.key,
+		Value:   d.value,
+		Offset:  d.offset,
+		Attempt: d.attempt,
+		b:       b,
+		d:       d,
+	}, nil
+}
+
+// forget removes d from the consumer's deliveries in flight. The caller
+// must hold b.mu.
+func (c *Consumer) forget(d *delivery) {
+	for i, in := range c.inflight {
+		if in == d {
+			c.inflight = append(c.inflight[:i], c.inflight[i+1:]...)
+			return
+		}
+	}
+}
+
+// Close closes the consumer. The messages delivered to it and not yet
+// acknowledged are delivered again to the group's other consumers.
+func (c *Consumer) Close() {
+	b := c.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closing.Close()
+	for len(c.inflight) > 0 {
+		b.settle(c.inflight[0], true)
+	}
+}
+
+// Ack acknowledges the message, so it is not delivered again unless the
+// topic's Faults lose the acknowledgement. Acknowledging a message that
+// has been given up on, for taking too long or because its consumer
+// closed, does nothing: it will be delivered again regardless.
+func (m *Message) Ack() {
+	b := m.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if m.d.settled {
+		return
+	}
+	b.settle(m.d, b.s.Chance(b.topicFaults(m.Topic).Duplicate))
+}
+
+// Nack rejects the message, to be delivered again.
+func (m *Message) Nack() {
+	b := m.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle(m.d, true)
+}
@@ -0,0 +1,39 @@
+package weftbus
+
+// Faults are the probabilities, from 0 to 1, of faults befalling the
+// messages of a topic. The zero Faults delivers each message once, in
+// order, unless it is rejected or not acknowledged in time.
+type Faults struct {
+	// Reorder is the probability that a consumer receives one of the
+	// later messages waiting instead of the first, as the schedule
+	// decides.
+	Reorder float64
+
+	// Duplicate is the probability that an acknowledgement is lost, so
+	// the acknowledged message is delivered again.
+	Duplicate float64
+}
+
+// SetFaults sets the faults of the named topic.
+func (b *Bus) SetFaults(topic string, f Faults) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.faults[topic] = f
+}
+
+// SetDefaultFaults sets the faults of every topic not given its own with
+// SetFaults.
+func (b *Bus) SetDefaultFaults(f Faults) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.defaults = f
+}
+
+// topicFaults returns the faults of the named topic. The caller must hold
+// b.mu.
+func (b *Bus) topicFaults(topic string) Faults {
+	if f, ok := b.faults[topic]; ok {
+		return f
+	}
+	return b.defaults
+}
@@ -0,0 +1,185 @@
+// Package weftbus simulates a publish/subscribe message bus, in the manner
+// of Kafka or NATS, for weft tests. Messages published to a topic are
+// delivered to every consumer group subscribed to it, and within a group to
+// one of its consumers. Delivery is at least once: a message that is not
+// acknowledged in time, is rejected, or was held by a consumer that closed
+// is delivered again, and Faults can reorder messages and deliver
+// acknowledged ones again, as a lost acknowledgement would. Whether a fault
+// happens is a decision of the schedule, made with Scheduler.Choose, so the
+// idempotence and ordering assumptions of event-driven consumers are
+// explored, and a failing run replays with the same deliveries.
+//
+//	bus := weftbus.New(s)
+//	bus.SetDefaultFaults(weftbus.Faults{Reorder: 0.2, Duplicate: 0.1})
+//	c := bus.Subscribe("orders", "billing")
+//	bus.Publish("orders", "order-1", payload)
+//	m, _ := c.Receive(ctx)
+//	m.Ack()
+package weftbus
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// ErrClosed is returned by Receive on a closed consumer.
+var ErrClosed = errors.New("weftbus: consumer closed")
+
+// Bus is a simulated message bus. Its zero value is not usable; create
+// buses with New.
+type Bus struct {
+	s *weft.Scheduler
+
+	mu       weft.Mutex
+	topics   map[string]*topic
+	faults   map[string]Faults
+	defaults Faults
+
+	// ackTimeout is how long a delivery waits for its acknowledgement
+	// before the message is delivered again; zero waits forever.
+	ackTimeout time.Duration
+}
+
+// New returns a bus without topics whose decisions are made by s.
+func New(s *weft.Scheduler) *Bus {
+	return &Bus{
+		s:      s,
+		topics: make(map[string]*topic),
+		faults: make(map[string]Faults),
+	}
+}
+
+// topic is a named stream of messages.
+type topic struct {
+	name string
+
+	// next is the offset of the next message published.
+	next int64
+
+	// groups are the topic's consumer groups, in the order they were
+	// created.
+	groups []*group
+	byName map[string]*group
+}
+
+// topic returns the topic called name, creating it if need be. The caller
+// must hold b.mu.
+func (b *Bus) topic(name string) *topic {
+	t := b.topics[name]
+	if t == nil {
+		t = &topic{name: name, byName: make(map[string]*group)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// group is a consumer group: each message of its topic is delivered to one
+// of its consumers.
+type group struct {
+	t *topic
+
+	// pending are the messages waiting to be delivered, in order.
+	pending []*delivery
+
+	// ready wakes a consumer waiting for a message.
+	ready weft.Chan[struct{}]
+}
+
+func newGroup(t *topic) *group {
+	return &group{t: t, ready: weft.MakeChan[struct{}](1)}
+}
+
+// wake wakes a waiting consumer, if there is one.
+func (g *group) wake() {
+	g.ready.TrySend(struct{}{})
+}
+
+// push adds d to the messages waiting for delivery.
+func (g *group) push(d *delivery) {
+	g.pending = append(g.pending, d)
+	g.wake()
+}
+
+// delivery is one delivery of a message to a group.
+type delivery struct {
+	g       *group
+	key     string
+	value   []byte
+	offset  int64
+	attempt int
+
+	// consumer is the consumer the message was delivered to, and settled
+	// is set, and done closed, once it is acknowledged, rejected or given
+	// up on.
+	consumer *Consumer
+	settled  bool
+	done     weft.Chan[struct{}]
+}
+
+// Publish appends a message to the named topic, to be delivered to each
+// of the topic's consumer groups, and returns its offset in the topic.
+// A topic keeps no messages for groups that subscribe later.
+func (b *Bus) Publish(topic, key string, value []byte) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(topic)
+	off := t.next
+	t.next++
+	for _, g := range t.groups {
+		g.push(&delivery{g: g, key: key, value: value, offset: off})
+	}
+	return off
+}
+
+// Subscribe returns a new consumer in the named group of the named topic.
+// Consumers of one group share its messages; each group receives every
+// message published from the time it was first subscribed.
+func (b *Bus) Subscribe(topic, group string) *Consumer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(topic)
+	g := t.byName[group]
+	if g == nil {
+		g = newGroup(t)
+		t.groups = append(t.groups, g)
+		t.byName[group] = g
+	}
+	return &Consumer{b: b, g: g, closing: weft.MakeChan[struct{}](0)}
+}
+
+// SetAckTimeout makes messages not acknowledged within d of virtual time of
+// their delivery be delivered again. Zero, the default, waits forever.
+func (b *Bus) SetAckTimeout(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ackTimeout = d
+}
+
+// settle ends delivery d, and delivers its message again if redeliver is
+// set. It reports whether d was still in flight. The caller must hold
+// b.mu.
+func (b *Bus) settle(d *delivery, redeliver bool) bool {
+	if d.settled {
+		return false
+	}
+	d.settled = true
+	d.consumer.forget(d)
+	d.done.Close()
+	if redeliver {
+		d.g.push(&delivery{g: d.g, key: d.key, value: d.value, offset: d.offset, attempt: d.attempt})
+	}
+	return true
+}
+
+// expire delivers d's message again if it is not acknowledged within
+// timeout.
+func (b *Bus) expire(d *delivery, timeout time.Duration) {
+	if weft.Select(weft.OnRecv(d.done), weft.OnRecv(b.s.After(timeout))) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle(d, true)
+}
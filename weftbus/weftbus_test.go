@@ -0,0 +1,123 @@
+package weftbus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/wefttest"
+)
+
+// receive returns the next message of c, failing the test if there is
+// none.
+func receive(t *testing.T, c *Consumer) *Message {
+	t.Helper()
+	m, err := c.Receive(context.Background())
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	return m
+}
+
+// TestGroups verifies that every group receives every message, in order,
+// and that consumers of one group share its messages.
+func TestGroups(t *testing.T) {
+	b := New(weft.NewScheduler(1))
+	billing := b.Subscribe("orders", "billing")
+	ship1, ship2 := b.Subscribe("orders", "shipping"), b.Subscribe("orders", "shipping")
+	for i := 0; i < 4; i++ {
+		b.Publish("orders", fmt.Sprint("order-", i), nil)
+	}
+
+	for i := 0; i < 4; i++ {
+		m := receive(t, billing)
+		if m.Offset != int64(i) || m.Key != fmt.Sprint("order-", i) || m.Attempt != 1 {
+			t.Errorf("billing message %d = %+v", i, m)
+		}
+		m.Ack()
+	}
+	seen := map[int64]bool{}
+	for _, c := range []*Consumer{ship1, ship2, ship1, ship2} {
+		m := receive(t, c)
+		seen[m.Offset] = true
+		m.Ack()
+	}
+	if len(seen) != 4 {
+		t.Errorf("shipping received offsets %v, want all 4", seen)
+	}
+}
+
+// TestRedelivery verifies that rejected messages, messages not
+// acknowledged in time and messages held by a closed consumer are
+// delivered again.
+func TestRedelivery(t *testing.T) {
+	b := New(weft.NewScheduler(1))
+	c1, c2 := b.Subscribe("t", "g"), b.Subscribe("t", "g")
+	b.Publish("t", "a", nil)
+
+	receive(t, c1).Nack()
+	if m := receive(t, c1); m.Attempt != 2 {
+		t.Errorf("Attempt after Nack = %d, want 2", m.Attempt)
+	}
+	c1.Close()
+	m := receive(t, c2)
+	if m.Attempt != 3 {
+		t.Errorf("Attempt after Close = %d, want 3", m.Attempt)
+	}
+	if _, err := c1.Receive(context.Background()); err != ErrClosed {
+		t.Errorf("Receive() on closed consumer error = %v, want ErrClosed", err)
+	}
+
+	b.SetAckTimeout(10 * time.Millisecond)
+	b.Publish("t", "b", nil)
+	m.Ack()
+	late := receive(t, c2)
+	if again := receive(t, c2); again.Offset != late.Offset || again.Attempt != 2 {
+		t.Errorf("after ack timeout got %+v, want offset %d again", again, late.Offset)
+	}
+	late.Ack() // too late: does nothing
+}
+
+// TestReceiveContext verifies that Receive returns when its context is
+// done.
+func TestReceiveContext(t *testing.T) {
+	b := New(weft.NewScheduler(1))
+	c := b.Subscribe("t", "g")
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+	if _, err := c.Receive(ctx); err != context.Canceled {
+		t.Errorf("Receive() error = %v, want context.Canceled", err)
+	}
+}
+
+// TestIdempotentConsumer explores a consumer that deduplicates by offset
+// under reordering and lost acknowledgements.
+func TestIdempotentConsumer(t *testing.T) {
+	wefttest.Explore(t, 50, func(s *weft.Scheduler) {
+		b := New(s)
+		b.SetDefaultFaults(Faults{Reorder: 0.5, Duplicate: 0.3})
+		c := b.Subscribe("events", "counter")
+		const n = 5
+		s.Go(func(weft.Context) {
+			for i := 0; i < n; i++ {
+				b.Publish("events", "", []byte{1})
+			}
+		})
+
+		applied := map[int64]bool{}
+		total := 0
+		for len(applied) < n {
+			m := receive(t, c)
+			if !applied[m.Offset] {
+				applied[m.Offset] = true
+				total += int(m.Value[0])
+			}
+			m.Ack()
+		}
+		if total != n {
+			t.Errorf("total = %d, want %d", total, n)
+		}
+	})
+}
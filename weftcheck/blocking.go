@@ -0,0 +1,300 @@
+package weftcheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/mziter/weft/internal/codemod"
+	"github.com/mziter/weft/internal/ignore"
+)
+
+// BlockingAnalyzer reports calls to blocking standard library APIs reachable
+// from the body of a weft task, started by weft.Go, GoDetached, AfterFunc,
+// StartNode or a Go method of a Scheduler, Node or Harness. Such calls block
+// the real goroutine running the task, which stalls the deterministic
+// scheduler instead of yielding to it, and typically surface as a hang.
+//
+// Reachability is followed through functions and methods declared in the
+// package being analyzed.
+var BlockingAnalyzer = &analysis.Analyzer{
+	Name: "weftblocking",
+	Doc: `report blocking calls reachable from weft.Go tasks
+
+Under detsched, weft tasks must only block on weft primitives. A call to
+time.Sleep, net, net/http, os file I/O, os/exec or syscall from a task, or
+from a function the task calls, blocks outside the scheduler and destroys
+determinism.`,
+	URL: "https://pkg.go.dev/github.com/mziter/weft/weftcheck",
+	Run: runBlocking,
+}
+
+// blockingFuncs lists blocking package-level functions by import path.
+var blockingFuncs = map[string]map[string]bool{
+	"time": {"Sleep": true, "After": true, "Tick": true},
+	"net": {
+		"Dial": true, "DialTimeout": true, "DialIP": true, "DialTCP": true, "DialUDP": true, "DialUnix": true,
+		"Listen": true, "ListenPacket": true, "ListenIP": true, "ListenTCP": true, "ListenUDP": true, "ListenUnix": true,
+		"LookupAddr": true, "LookupCNAME": true, "LookupHost": true, "LookupIP": true, "LookupMX": true,
+		"LookupNS": true, "LookupPort": true, "LookupSRV": true, "LookupTXT": true,
+	},
+	"net/http": {
+		"Get": true, "Head": true, "Post": true, "PostForm": true,
+		"ListenAndServe": true, "ListenAndServeTLS": true, "Serve": true, "ServeTLS": true,
+	},
+	"os": {
+		"Open": true, "OpenFile": true, "Create": true, "ReadFile": true, "WriteFile": true,
+		"ReadDir": true, "Mkdir": true, "MkdirAll": true, "Remove": true, "RemoveAll": true,
+		"Rename": true, "Stat": true, "Lstat": true,
+	},
+}
+
+// blockingMethods lists blocking methods by import path of the receiver's
+// package. Methods are matched by name on any type in the package.
+var blockingMethods = map[string]map[string]bool{
+	"net": {
+		"Accept": true, "Read": true, "Write": true, "ReadFrom": true, "WriteTo": true,
+		"Dial": true, "DialContext": true, "Listen": true, "ListenPacket": true,
+		"LookupAddr": true, "LookupHost": true, "LookupIP": true, "LookupIPAddr": true,
+	},
+	"net/http": {
+		"Do": true, "Get": true, "Head": true, "Post": true, "PostForm": true,
+		"ListenAndServe": true, "ListenAndServeTLS": true, "Serve": true, "ServeTLS": true, "Shutdown": true,
+	},
+	"os": {
+		"Read": true, "ReadAt": true, "Write": true, "WriteAt": true, "WriteString": true,
+		"ReadDir": true, "Readdir": true, "Readdirnames": true, "Sync": true, "Wait": true,
+	},
+	"os/exec": {"Run": true, "Output": true, "CombinedOutput": true, "Wait": true},
+}
+
+// isBlocking reports whether fn is a blocking standard library API.
+func isBlocking(fn *types.Func) bool {
+	if fn.Pkg() == nil {
+		return false
+	}
+	path := fn.Pkg().Path()
+	if path == "syscall" {
+		return true
+	}
+	if fn.Type().(*types.Signature).Recv() != nil {
+		return blockingMethods[path][fn.Name()]
+	}
+	return blockingFuncs[path][fn.Name()]
+}
+
+// blockingChecker follows calls from weft.Go bodies through the functions
+// declared in the package.
+type blockingChecker struct {
+	pass    *analysis.Pass
+	decls   map[*types.Func]*ast.FuncDecl
+	ignored map[*token.File]*ignore.Set
+	files   map[*token.File]*ast.File
+	sources map[*ast.File][]byte
+
+	// reached memoizes the blocking API a declared function reaches, or
+	// nil if it reaches none.
+	reached map[*types.Func]*types.Func
+
+	// visiting holds the functions whose visit by reach has not finished,
+	// and cut is set once reach returns for one of them.
+	visiting map[*types.Func]bool
+	cut      bool
+}
+
+func runBlocking(pass *analysis.Pass) (interface{}, error) {
+	if ignore.Package(pass.Files) {
+		return nil, nil
+	}
+	c := &blockingChecker{
+		pass:     pass,
+		decls:    make(map[*types.Func]*ast.FuncDecl),
+		ignored:  make(map[*token.File]*ignore.Set),
+		files:    make(map[*token.File]*ast.File),
+		sources:  make(map[*ast.File][]byte),
+		reached:  make(map[*types.Func]*types.Func),
+		visiting: make(map[*types.Func]bool),
+	}
+	var files []*ast.File
+	for _, f := range pass.Files {
+		set := ignore.File(pass.Fset, f)
+		if set.IgnoresFile() || stdlibOnly(f) {
+			continue
+		}
+		c.ignored[pass.Fset.File(f.Pos())] = set
+		c.files[pass.Fset.File(f.Pos())] = f
+		files = append(files, f)
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil && !set.Ignores(fd) {
+				if fn, ok := pass.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+					c.decls[fn] = fd
+				}
+			}
+		}
+	}
+
+	reported := make(map[token.Pos]bool)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil || c.ignores(n) {
+				return n == nil
+			}
+			call, ok := n.(*ast.CallExpr)
+			if !ok || !c.isSpawn(call) {
+				return true
+			}
+			for _, arg := range call.Args {
+				c.checkTask(arg, reported)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// checkTask reports the blocking calls reachable from the task function
+// passed to a spawn.
+func (c *blockingChecker) checkTask(task ast.Expr, reported map[token.Pos]bool) {
+	report := func(call *ast.CallExpr, target, via *types.Func) {
+		if reported[call.Pos()] {
+			return
+		}
+		reported[call.Pos()] = true
+		if via != nil {
+			c.pass.Reportf(call.Pos(), "weft task calls %s, which reaches %s; it blocks outside the weft scheduler", via.Name(), target.FullName())
+			return
+		}
+		if target.Type().(*types.Signature).Recv() == nil && codemod.Equivalent(target.Pkg().Path(), target.Name()) {
+			c.pass.Report(analysis.Diagnostic{
+				Pos:            call.Pos(),
+				Message:        fmt.Sprintf("%s blocks outside the weft scheduler; use weft.%s", target.FullName(), target.Name()),
+				SuggestedFixes: c.suggest(call.Fun, "Convert to weft."+target.Name()),
+			})
+			return
+		}
+		c.pass.Reportf(call.Pos(), "%s blocks outside the weft scheduler", target.FullName())
+	}
+
+	switch task := ast.Unparen(task).(type) {
+	case *ast.FuncLit:
+		c.calls(task.Body, report)
+	case *ast.Ident, *ast.SelectorExpr:
+		// A named function passed directly: report at the spawn site.
+		fn, ok := c.pass.TypesInfo.Uses[identOf(task)].(*types.Func)
+		if !ok {
+			return
+		}
+		if target := c.reach(fn); target != nil && !reported[task.Pos()] {
+			reported[task.Pos()] = true
+			c.pass.Reportf(task.Pos(), "weft task %s reaches %s; it blocks outside the weft scheduler", fn.Name(), target.FullName())
+		}
+	}
+}
+
+// calls invokes fn for each call under n that is, or reaches, a blocking
+// API. Tasks spawned under n run separately and are not followed.
+func (c *blockingChecker) calls(n ast.Node, fn func(call *ast.CallExpr, target, via *types.Func)) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if n == nil || c.ignores(n) {
+			return n == nil
+		}
+		switch n := n.(type) {
+		case *ast.GoStmt:
+			return false
+		case *ast.CallExpr:
+			if c.isSpawn(n) {
+				return false
+			}
+			callee, ok := typeutil.Callee(c.pass.TypesInfo, n).(*types.Func)
+			if !ok {
+				return true
+			}
+			if isBlocking(callee) {
+				fn(n, callee, nil)
+			} else if target := c.reach(callee); target != nil {
+				fn(n, target, callee)
+			}
+		}
+		return true
+	})
+}
+
+// reach returns the blocking API reachable from the declared function fn,
+// or nil. A function reached again while it is still being visited counts
+// as reaching nothing, which cuts recursion; a result that relied on such a
+// cut is not memoized, since the function cut may yet reach one, unless fn
+// started the visit and so explored everything reachable from it.
+func (c *blockingChecker) reach(fn *types.Func) *types.Func {
+	if target, ok := c.reached[fn]; ok {
+		return target
+	}
+	decl, ok := c.decls[fn]
+	if !ok {
+		return nil
+	}
+	if c.visiting[fn] {
+		c.cut = true
+		return nil
+	}
+	c.visiting[fn] = true
+	outer := c.cut
+	c.cut = false
+	var found *types.Func
+	c.calls(decl.Body, func(_ *ast.CallExpr, target, _ *types.Func) {
+		if found == nil {
+			found = target
+		}
+	})
+	delete(c.visiting, fn)
+	if found != nil || !c.cut || len(c.visiting) == 0 {
+		c.reached[fn] = found
+	}
+	c.cut = outer || c.cut && found == nil
+	return found
+}
+
+// spawnFuncs lists the weft functions and methods that run their function
+// arguments on new tasks: weft.Go and GoDetached, the Go methods of
+// Scheduler, Node and Harness, AfterFunc and StartNode.
+var spawnFuncs = map[string]bool{
+	"Go":         true,
+	"GoDetached": true,
+	"AfterFunc":  true,
+	"StartNode":  true,
+}
+
+// isSpawn reports whether call starts a weft task.
+func (c *blockingChecker) isSpawn(call *ast.CallExpr) bool {
+	fn, ok := typeutil.Callee(c.pass.TypesInfo, call).(*types.Func)
+	return ok && spawnFuncs[fn.Name()] && fn.Pkg() != nil && fn.Pkg().Path() == codemod.WeftPath
+}
+
+// suggest returns a fix converting n, which lies in one of the checked
+// files.
+func (c *blockingChecker) suggest(n ast.Node, message string) []analysis.SuggestedFix {
+	f := c.files[c.pass.Fset.File(n.Pos())]
+	src, ok := c.sources[f]
+	if !ok {
+		src = readSource(c.pass, f)
+		c.sources[f] = src
+	}
+	return suggest(c.pass, f, src, n, message)
+}
+
+// ignores reports whether n is excluded by a //weft:ignore directive.
+func (c *blockingChecker) ignores(n ast.Node) bool {
+	set := c.ignored[c.pass.Fset.File(n.Pos())]
+	return set != nil && set.Ignores(n)
+}
+
+// identOf returns the identifier naming a possibly qualified reference.
+func identOf(x ast.Expr) *ast.Ident {
+	if sel, ok := x.(*ast.SelectorExpr); ok {
+		return sel.Sel
+	}
+	return x.(*ast.Ident)
+}
@@ -0,0 +1,187 @@
+package weftcheck
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mziter/weft/internal/codemod"
+	"github.com/mziter/weft/internal/ignore"
+)
+
+// CopyAnalyzer reports weft locks copied by value. go vet's copylocks check
+// only knows the sync types, and under detsched a copy of a weft lock that
+// was already used shares its state with the original instead of starting
+// unlocked, so the bug hides until the two are used concurrently.
+var CopyAnalyzer = &analysis.Analyzer{
+	Name: "weftcopy",
+	Doc: `report weft locks passed or assigned by value
+
+A weft.Mutex, weft.RWMutex, weft.Cond or weft.Once must not be copied after
+first use, just like its sync counterpart. Pass pointers to them, or to the
+structs containing them, instead. weft.Chan values are references, like
+built-in channels, and may be copied freely.`,
+	URL: "https://pkg.go.dev/github.com/mziter/weft/weftcheck",
+	Run: runCopy,
+}
+
+// weftLocks lists the weft types that must not be copied.
+var weftLocks = map[string]bool{
+	"Mutex":   true,
+	"RWMutex": true,
+	"Cond":    true,
+	"Once":    true,
+}
+
+func runCopy(pass *analysis.Pass) (interface{}, error) {
+	if pass.Pkg.Path() == codemod.WeftPath || ignore.Package(pass.Files) {
+		return nil, nil
+	}
+	c := &copyChecker{pass: pass, qual: types.RelativeTo(pass.Pkg)}
+	for _, f := range pass.Files {
+		ignored := ignore.File(pass.Fset, f)
+		if ignored.IgnoresFile() {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil {
+				return true
+			}
+			if ignored.Ignores(n) {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if len(n.Lhs) == len(n.Rhs) {
+					for i, x := range n.Rhs {
+						if id, ok := n.Lhs[i].(*ast.Ident); ok && id.Name == "_" {
+							continue
+						}
+						c.checkExpr(x, "assignment copies lock value to %s: %s", types.ExprString(n.Lhs[i]))
+					}
+				}
+			case *ast.ValueSpec:
+				if len(n.Names) == len(n.Values) {
+					for i, x := range n.Values {
+						c.checkExpr(x, "variable declaration copies lock value to %s: %s", n.Names[i].Name)
+					}
+				}
+			case *ast.CompositeLit:
+				for _, x := range n.Elts {
+					if kv, ok := x.(*ast.KeyValueExpr); ok {
+						x = kv.Value
+					}
+					c.checkExpr(x, "literal copies lock value from %s: %s", types.ExprString(x))
+				}
+			case *ast.CallExpr:
+				c.checkCall(n)
+			case *ast.ReturnStmt:
+				for _, x := range n.Results {
+					c.checkExpr(x, "return copies lock value: %s")
+				}
+			case *ast.RangeStmt:
+				if n.Value != nil {
+					if path := c.lockPath(pass.TypesInfo.TypeOf(n.Value)); path != "" {
+						pass.Reportf(n.Value.Pos(), "range var %s copies lock: %s", types.ExprString(n.Value), path)
+					}
+				}
+			case *ast.FuncDecl:
+				c.checkParams(n.Recv, n.Name.Name)
+				c.checkParams(n.Type.Params, n.Name.Name)
+			case *ast.FuncLit:
+				c.checkParams(n.Type.Params, "func")
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// copyChecker reports the copies of weft locks in a package.
+type copyChecker struct {
+	pass *analysis.Pass
+	qual types.Qualifier
+}
+
+// checkExpr reports x if evaluating it copies a lock. The format receives
+// args followed by the lock's path.
+func (c *copyChecker) checkExpr(x ast.Expr, format string, args ...interface{}) {
+	x = ast.Unparen(x)
+	switch x := x.(type) {
+	case *ast.CompositeLit, *ast.CallExpr:
+		// A new value, not a copy of an existing one.
+		return
+	case *ast.StarExpr:
+		if _, ok := ast.Unparen(x.X).(*ast.CallExpr); ok {
+			// *new(T) and the like.
+			return
+		}
+	}
+	tv, ok := c.pass.TypesInfo.Types[x]
+	if !ok || !tv.IsValue() {
+		return
+	}
+	if path := c.lockPath(tv.Type); path != "" {
+		c.pass.Reportf(x.Pos(), format, append(args, path)...)
+	}
+}
+
+// checkCall reports arguments of call that copy a lock.
+func (c *copyChecker) checkCall(call *ast.CallExpr) {
+	if tv, ok := c.pass.TypesInfo.Types[call.Fun]; ok && tv.IsType() {
+		// A conversion copies nothing the operand's declaration doesn't.
+		return
+	}
+	if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok {
+		if _, ok := c.pass.TypesInfo.Uses[id].(*types.Builtin); ok {
+			return
+		}
+	}
+	for _, x := range call.Args {
+		c.checkExpr(x, "call of %s copies lock value: %s", types.ExprString(call.Fun))
+	}
+}
+
+// checkParams reports parameters, or receivers, that take a lock by value.
+func (c *copyChecker) checkParams(fields *ast.FieldList, fn string) {
+	if fields == nil {
+		return
+	}
+	for _, field := range fields.List {
+		if path := c.lockPath(c.pass.TypesInfo.TypeOf(field.Type)); path != "" {
+			c.pass.Reportf(field.Type.Pos(), "%s passes lock by value: %s", fn, path)
+		}
+	}
+}
+
+// lockPath returns the weft lock held by values of t, such as "weft.Mutex"
+// or, through struct fields and arrays, "T contains weft.Mutex", or "" if
+// t holds none.
+func (c *copyChecker) lockPath(t types.Type) string {
+	var path []string
+	for t != nil {
+		if named, ok := t.(*types.Named); ok {
+			obj := named.Obj()
+			if obj.Pkg() != nil && obj.Pkg().Path() == codemod.WeftPath && weftLocks[obj.Name()] {
+				return strings.Join(append(path, "weft."+obj.Name()), " contains ")
+			}
+		}
+		var next types.Type
+		switch u := t.Underlying().(type) {
+		case *types.Array:
+			next = u.Elem()
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				if c.lockPath(u.Field(i).Type()) != "" {
+					next = u.Field(i).Type()
+					path = append(path, types.TypeString(t, c.qual))
+					break
+				}
+			}
+		}
+		t = next
+	}
+	return ""
+}
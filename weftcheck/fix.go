@@ -0,0 +1,39 @@
+package weftcheck
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mziter/weft/internal/codemod"
+)
+
+// readSource returns the source of f, or nil if the driver cannot provide
+// it, in which case no fixes are offered for the file.
+func readSource(pass *analysis.Pass, f *ast.File) []byte {
+	if pass.ReadFile == nil {
+		return nil
+	}
+	src, err := pass.ReadFile(pass.Fset.File(f.Pos()).Name())
+	if err != nil {
+		return nil
+	}
+	return src
+}
+
+// suggest returns a fix converting n the way weftfix would, or nil if n
+// cannot be converted.
+func suggest(pass *analysis.Pass, f *ast.File, src []byte, n ast.Node, message string) []analysis.SuggestedFix {
+	if src == nil {
+		return nil
+	}
+	edits, err := codemod.Fix(pass.Fset, f, src, n, pass.Pkg, pass.TypesInfo)
+	if err != nil || len(edits) == 0 {
+		return nil
+	}
+	textEdits := make([]analysis.TextEdit, len(edits))
+	for i, e := range edits {
+		textEdits[i] = analysis.TextEdit{Pos: e.Pos, End: e.End, NewText: e.NewText}
+	}
+	return []analysis.SuggestedFix{{Message: message, TextEdits: textEdits}}
+}
@@ -0,0 +1,32 @@
+package a
+
+import (
+	"sync"
+
+	"github.com/mziter/weft"
+)
+
+type T struct {
+	mu    weft.Mutex
+	raw   sync.Mutex     // want `sync.Mutex is invisible to the weft scheduler; use weft.Mutex`
+	wg    sync.WaitGroup // want `sync.WaitGroup is invisible to the weft scheduler$`
+	ch    chan int       // want `built-in channel is invisible to the weft scheduler; use weft.Chan`
+	hot   sync.Mutex     //weft:ignore measured hot path
+	nodes chan chan int  // want `built-in channel`
+}
+
+func (t *T) Start() {
+	weft.Go(func(weft.Context) {
+		t.mu.Lock()
+		t.mu.Unlock()
+	})
+	go t.Start() // want `go statement is invisible to the weft scheduler; use weft.Go`
+	t.raw.Lock()
+	t.raw.Unlock()
+	t.wg.Wait()
+	done := make(chan struct{}) // want `built-in channel`
+	_ = done
+
+	//weft:ignore drains the OS signal channel
+	go func() {}()
+}
@@ -0,0 +1,7 @@
+//go:build !detsched
+
+package a
+
+import "sync"
+
+var legacy sync.Mutex
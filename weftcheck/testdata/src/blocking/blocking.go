@@ -0,0 +1,101 @@
+package blocking
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+func poll() {
+	time.Sleep(time.Second)
+}
+
+func fetch() {
+	poll()
+}
+
+func pure() int { return 1 }
+
+func recurse(n int) {
+	if n > 0 {
+		recurse(n - 1)
+	}
+}
+
+// pingA and pingB recurse mutually; pingB reaches time.Sleep through
+// pingA even when pingA is visited first.
+func pingA(n int) {
+	pingB(n)
+	time.Sleep(time.Second)
+}
+
+func pingB(n int) {
+	if n > 0 {
+		pingA(n - 1)
+	}
+}
+
+func spawnOnly() {
+	weft.Go(func(weft.Context) {})
+}
+
+//weft:ignore reviewed: runs against a local fixture
+func slowFixture() {
+	time.Sleep(time.Millisecond)
+}
+
+func worker(weft.Context) {
+	fetch()
+}
+
+func Start(f *os.File) {
+	weft.Go(func(weft.Context) {
+		time.Sleep(time.Second)        // want `time.Sleep blocks outside the weft scheduler; use weft.Sleep`
+		http.Get("http://example.com") // want `net/http.Get blocks outside the weft scheduler$`
+		f.Read(nil)                    // want `\(\*os.File\).Read blocks outside the weft scheduler`
+		fetch()                        // want `weft task calls fetch, which reaches time.Sleep`
+		pure()
+		recurse(3)
+		spawnOnly()
+		slowFixture()
+		time.Sleep(time.Millisecond) //weft:ignore
+		weft.Go(func(weft.Context) {
+			os.ReadFile("x") // want `os.ReadFile blocks outside the weft scheduler`
+		})
+	})
+	weft.Go(worker) // want `weft task worker reaches time.Sleep`
+	weft.Go(func(weft.Context) {
+		pingA(1) // want `weft task calls pingA, which reaches time.Sleep`
+		pingB(1) // want `weft task calls pingB, which reaches time.Sleep`
+	})
+	weft.GoDetached(func(weft.Context) {
+		fetch() // want `weft task calls fetch, which reaches time.Sleep`
+	})
+	weft.AfterFunc(time.Second, func() {
+		fetch() // want `weft task calls fetch, which reaches time.Sleep`
+	})
+	weft.AfterFunc(time.Second, poll) // want `weft task poll reaches time.Sleep`
+
+	// Not a task: blocking here is the caller's business.
+	time.Sleep(time.Second)
+}
+
+func Spawn(s *weft.Scheduler, h *weft.Harness) {
+	s.Go(func(weft.Context) {
+		fetch() // want `weft task calls fetch, which reaches time.Sleep`
+	})
+	s.AfterFunc(time.Second, func() {
+		fetch() // want `weft task calls fetch, which reaches time.Sleep`
+	})
+	s.StartNode("db", func(n *weft.Node) {
+		fetch() // want `weft task calls fetch, which reaches time.Sleep`
+		n.Go(func(weft.Context) {
+			fetch() // want `weft task calls fetch, which reaches time.Sleep`
+		})
+	})
+	h.Go(func(weft.Context) {
+		fetch() // want `weft task calls fetch, which reaches time.Sleep`
+	})
+}
@@ -0,0 +1,55 @@
+package copy
+
+import "github.com/mziter/weft"
+
+type Counter struct {
+	mu weft.Mutex
+	n  int
+}
+
+type Table struct {
+	rows [4]Counter
+}
+
+func byValue(c Counter) {} // want `byValue passes lock by value: Counter contains weft.Mutex`
+
+func (c Counter) Value() int { return c.n } // want `Value passes lock by value: Counter contains weft.Mutex`
+
+func initAll(once weft.Once) {} // want `initAll passes lock by value: weft.Once`
+
+func (c *Counter) Inc() {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+}
+
+func snapshot(c *Counter) Counter {
+	return *c // want `return copies lock value: Counter contains weft.Mutex`
+}
+
+func f(c *Counter, t *Table, mus []weft.RWMutex) {
+	d := *c                  // want `assignment copies lock value to d: Counter contains weft.Mutex`
+	var e = t.rows[0]        // want `variable declaration copies lock value to e: Counter contains weft.Mutex`
+	byValue(*c)              // want `call of byValue copies lock value: Counter contains weft.Mutex`
+	_ = Table{rows: t.rows}  // want `literal copies lock value from t.rows: Counter contains weft.Mutex`
+	for _, mu := range mus { // want `range var mu copies lock: weft.RWMutex`
+		_ = &mu
+	}
+	_ = d
+	_ = e
+	go1 := func(t Table) {} // want `func passes lock by value: Table contains Counter contains weft.Mutex`
+	_ = go1
+}
+
+func fine(c *Counter) {
+	fresh := Counter{}
+	p := c
+	q := new(Counter)
+	cond := weft.NewCond(&c.mu)
+	ch := weft.MakeChan[int](1)
+	ch2 := ch
+	_, _, _, _, _ = fresh, p, q, cond, ch2
+	//weft:ignore
+	ignored := *c
+	_ = ignored
+}
@@ -0,0 +1,20 @@
+package fix
+
+import (
+	"sync"
+
+	"github.com/mziter/weft"
+)
+
+type T struct {
+	mu weft.Mutex
+	wg sync.WaitGroup // want `sync.WaitGroup is invisible`
+}
+
+func (t *T) run() {}
+
+func (t *T) Start() {
+	go t.run()                     // want `go statement is invisible`
+	done := make(chan struct{}, 1) // want `built-in channel is invisible`
+	_ = done
+}
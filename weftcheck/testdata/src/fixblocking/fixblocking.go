@@ -0,0 +1,13 @@
+package fixblocking
+
+import (
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+func Start() {
+	weft.Go(func(weft.Context) {
+		time.Sleep(time.Second) // want `time.Sleep blocks outside the weft scheduler`
+	})
+}
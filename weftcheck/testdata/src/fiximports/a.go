@@ -0,0 +1,5 @@
+package fiximports
+
+import "github.com/mziter/weft"
+
+var _ weft.Mutex
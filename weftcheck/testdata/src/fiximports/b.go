@@ -0,0 +1,5 @@
+package fiximports
+
+import "sync"
+
+var mu sync.Mutex // want `sync.Mutex is invisible`
@@ -0,0 +1,51 @@
+package weft
+
+import "time"
+
+type Context interface{}
+
+type Mutex struct{}
+
+func (m *Mutex) Lock()   {}
+func (m *Mutex) Unlock() {}
+
+func Go(fn func(Context)) {}
+
+func Sleep(d time.Duration) {}
+
+type Chan[T any] struct{}
+
+func MakeChan[T any](n int) Chan[T] { return Chan[T]{} }
+
+type RWMutex struct{}
+
+func (rw *RWMutex) Lock()   {}
+func (rw *RWMutex) Unlock() {}
+
+type Cond struct{}
+
+func NewCond(l *Mutex) *Cond { return &Cond{} }
+
+type Once struct{}
+
+func (o *Once) Do(f func()) {}
+
+func GoDetached(fn func(Context)) {}
+
+type Timer struct{}
+
+func AfterFunc(d time.Duration, f func()) *Timer { return &Timer{} }
+
+type Scheduler struct{}
+
+func (s *Scheduler) Go(fn func(Context))                              {}
+func (s *Scheduler) AfterFunc(d time.Duration, f func()) *Timer       { return &Timer{} }
+func (s *Scheduler) StartNode(name string, start func(n *Node)) *Node { return &Node{} }
+
+type Node struct{}
+
+func (n *Node) Go(fn func(Context)) {}
+
+type Harness struct{}
+
+func (h *Harness) Go(fn func(Context)) {}
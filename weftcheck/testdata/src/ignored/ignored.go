@@ -0,0 +1,16 @@
+// Package ignored bridges weft to a callback-driven library.
+//
+//weft:ignore
+package ignored
+
+import (
+	"sync"
+
+	"github.com/mziter/weft"
+)
+
+var mu sync.Mutex
+
+func Start() {
+	go weft.Go(nil)
+}
@@ -0,0 +1,10 @@
+// Package plain does not use weft and is not checked.
+package plain
+
+import "sync"
+
+var mu sync.Mutex
+
+func Start() {
+	go Start()
+}
@@ -0,0 +1,161 @@
+// Package weftcheck provides go/analysis analyzers that catch code which
+// escapes the weft scheduler and so breaks deterministic replay.
+//
+// The analyzers can be run standalone or through go vet with the
+// cmd/weftcheck driver:
+//
+//	go vet -vettool=$(which weftcheck) ./...
+//
+// and can be registered with linters built on go/analysis, such as
+// golangci-lint, by importing this package.
+package weftcheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mziter/weft/internal/codemod"
+	"github.com/mziter/weft/internal/ignore"
+)
+
+// Analyzer reports raw go statements, sync primitives and built-in channels
+// in packages that import weft. A package that mixes the two is half
+// converted: goroutines and channels the scheduler cannot see make runs
+// under detsched irreproducible.
+var Analyzer = &analysis.Analyzer{
+	Name: "weftcheck",
+	Doc: `report raw concurrency in packages that use weft
+
+In a package that imports weft, go statements, sync primitives and built-in
+channels bypass the deterministic scheduler. Convert them with weftfix or
+mark intentional uses with a //weft:ignore directive.`,
+	URL: "https://pkg.go.dev/github.com/mziter/weft/weftcheck",
+	Run: run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !usesWeft(pass.Pkg) || ignore.Package(pass.Files) {
+		return nil, nil
+	}
+	for _, f := range pass.Files {
+		ignored := ignore.File(pass.Fset, f)
+		if ignored.IgnoresFile() || stdlibOnly(f) {
+			continue
+		}
+		src := readSource(pass, f)
+		// makes maps the channel type in make(chan T) to the call, which
+		// is converted as a whole.
+		makes := make(map[ast.Node]ast.Node)
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil {
+				return true
+			}
+			if ignored.Ignores(n) {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.GoStmt:
+				pass.Report(analysis.Diagnostic{
+					Pos:            n.Pos(),
+					Message:        "go statement is invisible to the weft scheduler; use weft.Go",
+					SuggestedFixes: suggest(pass, f, src, n, "Convert to weft.Go"),
+				})
+			case *ast.CallExpr:
+				if id, ok := n.Fun.(*ast.Ident); ok && id.Name == "make" && len(n.Args) > 0 {
+					if _, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok {
+						makes[n.Args[0]] = n
+					}
+				}
+			case *ast.ChanType:
+				target := ast.Node(n)
+				if call, ok := makes[n]; ok {
+					target = call
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:            n.Pos(),
+					Message:        "built-in channel is invisible to the weft scheduler; use weft.Chan",
+					SuggestedFixes: suggest(pass, f, src, target, "Convert to weft.Chan"),
+				})
+				return false
+			case *ast.SelectorExpr:
+				checkSync(pass, f, src, n)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// checkSync reports a reference to a sync primitive.
+func checkSync(pass *analysis.Pass, f *ast.File, src []byte, sel *ast.SelectorExpr) {
+	obj := pass.TypesInfo.Uses[sel.Sel]
+	if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != "sync" {
+		return
+	}
+	if _, ok := pass.TypesInfo.Selections[sel]; ok {
+		// A method or field of a sync value, reported at its declaration.
+		return
+	}
+	if codemod.Equivalent("sync", obj.Name()) {
+		pass.Report(analysis.Diagnostic{
+			Pos:            sel.Pos(),
+			End:            sel.End(),
+			Message:        fmt.Sprintf("sync.%s is invisible to the weft scheduler; use weft.%s", obj.Name(), obj.Name()),
+			SuggestedFixes: suggest(pass, f, src, sel, "Convert to weft."+obj.Name()),
+		})
+		return
+	}
+	pass.Reportf(sel.Pos(), "sync.%s is invisible to the weft scheduler", obj.Name())
+}
+
+// usesWeft reports whether pkg imports weft. The weft module's own packages
+// implement the primitives on top of the standard library and are exempt.
+func usesWeft(pkg *types.Package) bool {
+	if pkg.Path() == codemod.WeftPath || strings.HasPrefix(pkg.Path(), codemod.WeftPath+"/internal/") {
+		return false
+	}
+	for _, imp := range pkg.Imports() {
+		if imp.Path() == codemod.WeftPath {
+			return true
+		}
+	}
+	return false
+}
+
+// stdlibOnly reports whether f's build constraint requires !detsched, as the
+// standard library half of a file split by weftfix --split does.
+func stdlibOnly(f *ast.File) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if !constraint.IsGoBuild(c.Text) {
+				continue
+			}
+			expr, err := constraint.Parse(c.Text)
+			if err != nil {
+				continue
+			}
+			return requiresNotTag(expr)
+		}
+	}
+	return false
+}
+
+// requiresNotTag reports whether !detsched is one of the conjuncts of expr.
+func requiresNotTag(expr constraint.Expr) bool {
+	switch e := expr.(type) {
+	case *constraint.NotExpr:
+		tag, ok := e.X.(*constraint.TagExpr)
+		return ok && tag.Tag == codemod.Tag
+	case *constraint.AndExpr:
+		return requiresNotTag(e.X) || requiresNotTag(e.Y)
+	}
+	return false
+}
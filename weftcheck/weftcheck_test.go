@@ -0,0 +1,34 @@
+package weftcheck
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+// TestAnalyzer verifies diagnostics for a half-converted package and that
+// ignored packages, stdlib-only split files and packages without weft are
+// left alone.
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a", "ignored", "plain")
+}
+
+// TestBlockingAnalyzer verifies that blocking calls are reported in weft
+// tasks, directly and through functions declared in the package.
+func TestBlockingAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), BlockingAnalyzer, "blocking")
+}
+
+// TestCopyAnalyzer verifies that weft locks copied by value are reported
+// through structs, arrays and every kind of copy, and that fresh values,
+// pointers and channels are not.
+func TestCopyAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), CopyAnalyzer, "copy")
+}
+
+// TestSuggestedFixes verifies that fixes convert flagged code the way
+// weftfix would, including the imports of the file.
+func TestSuggestedFixes(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analyzer, "fix", "fiximports")
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), BlockingAnalyzer, "fixblocking")
+}
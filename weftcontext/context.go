@@ -0,0 +1,118 @@
+//go:build detsched
+
+// Package weftcontext derives context.Contexts whose deadlines run on the
+// weft scheduler's virtual time, so APIs that take a context.Context work
+// unmodified inside weft tests: a timeout is a point in the schedule the
+// scheduler explores, not a wall-clock timer racing the test.
+//
+// In builds without the detsched tag the functions delegate to package
+// context.
+//
+//	ctx, cancel := weftcontext.WithTimeout(ctx, time.Second)
+//	defer cancel()
+//	weft.Select(weft.OnRecv(weftcontext.Done(ctx)), weft.OnRecv(results))
+package weftcontext
+
+import (
+	"context"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/internal/scheduler"
+)
+
+// WithCancel is context.WithCancel, for code switching its imports to
+// weftcontext wholesale.
+func WithCancel(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithCancel(parent)
+}
+
+// WithTimeout returns a copy of parent that is canceled once timeout of
+// virtual time has passed, when its Err is context.DeadlineExceeded, or
+// when cancel is called or parent is done.
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return withTimeout(parent, weft.Now().Add(timeout), timeout)
+}
+
+// WithDeadline is like WithTimeout with a timeout of the virtual time
+// until d, which is measured from weft.Now.
+func WithDeadline(parent context.Context, d time.Time) (context.Context, context.CancelFunc) {
+	if cur, ok := parent.Deadline(); ok && cur.Before(d) {
+		// The parent's deadline comes first.
+		return context.WithCancel(parent)
+	}
+	return withTimeout(parent, d, d.Sub(weft.Now()))
+}
+
+func withTimeout(parent context.Context, deadline time.Time, timeout time.Duration) (context.Context, context.CancelFunc) {
+	cctx, cancel := context.WithCancelCause(parent)
+	ctx := &timerCtx{Context: cctx, deadline: deadline}
+	if timeout <= 0 {
+		ctx.expire(cancel)
+		return ctx, func() { cancel(context.Canceled) }
+	}
+	stop := Done(cctx)
+	weft.Go(func(weft.Context) {
+		if weft.Select(weft.OnRecv(stop), weft.OnRecv(weft.After(timeout))) == 1 {
+			ctx.expire(cancel)
+		}
+	})
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// AfterFunc arranges to call f on a new task once ctx is done, as
+// context.AfterFunc calls it on a new goroutine, and returns stop as it
+// does. The task is spawned once no task can run, so whether f runs
+// before or after the tasks woken with it is a decision of the schedule,
+// not up to the goroutine that canceled ctx. Outside a run, on a
+// goroutine that neither runs in one nor drives a scheduler's tasks, it
+// is context.AfterFunc.
+func AfterFunc(ctx context.Context, f func()) (stop func() bool) {
+	s := scheduler.Driving()
+	if s == nil {
+		return context.AfterFunc(ctx, f)
+	}
+	done := func() bool {
+		select {
+		case <-ctx.Done():
+			return true
+		default:
+			return false
+		}
+	}
+	return s.AfterDone(done, f)
+}
+
+// timerCtx is a context canceled when its deadline passes on virtual time.
+type timerCtx struct {
+	context.Context
+	deadline time.Time
+
+	mu      weft.Mutex
+	expired bool
+}
+
+// expire cancels c for having reached its deadline, unless it is done
+// already.
+func (c *timerCtx) expire(cancel context.CancelCauseFunc) {
+	c.mu.Lock()
+	c.expired = c.Context.Err() == nil
+	c.mu.Unlock()
+	cancel(context.DeadlineExceeded)
+}
+
+// Deadline returns the time the context expires.
+func (c *timerCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+// Err returns context.DeadlineExceeded once the context has expired.
+func (c *timerCtx) Err() error {
+	c.mu.Lock()
+	expired := c.expired
+	c.mu.Unlock()
+	if expired {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
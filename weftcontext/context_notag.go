@@ -0,0 +1,39 @@
+//go:build !detsched
+
+// Package weftcontext derives context.Contexts whose deadlines run on the
+// weft scheduler's virtual time, so APIs that take a context.Context work
+// unmodified inside weft tests: a timeout is a point in the schedule the
+// scheduler explores, not a wall-clock timer racing the test.
+//
+// In builds without the detsched tag the functions delegate to package
+// context.
+//
+//	ctx, cancel := weftcontext.WithTimeout(ctx, time.Second)
+//	defer cancel()
+//	weft.Select(weft.OnRecv(weftcontext.Done(ctx)), weft.OnRecv(results))
+package weftcontext
+
+import (
+	"context"
+	"time"
+)
+
+// WithCancel delegates to context.WithCancel in production mode.
+func WithCancel(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithCancel(parent)
+}
+
+// WithTimeout delegates to context.WithTimeout in production mode.
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, timeout)
+}
+
+// WithDeadline delegates to context.WithDeadline in production mode.
+func WithDeadline(parent context.Context, d time.Time) (context.Context, context.CancelFunc) {
+	return context.WithDeadline(parent, d)
+}
+
+// AfterFunc delegates to context.AfterFunc in production mode.
+func AfterFunc(ctx context.Context, f func()) (stop func() bool) {
+	return context.AfterFunc(ctx, f)
+}
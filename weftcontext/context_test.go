@@ -0,0 +1,75 @@
+package weftcontext
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// TestWithTimeout verifies that a timeout context expires with
+// context.DeadlineExceeded and that canceling it first ends it with
+// context.Canceled.
+func TestWithTimeout(t *testing.T) {
+	weft.RunDefault(t, func() {
+		ctx, cancel := WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		Done(ctx).Recv()
+		if err := ctx.Err(); err != context.DeadlineExceeded {
+			t.Errorf("Err() after timeout = %v, want context.DeadlineExceeded", err)
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Deadline() ok = false, want true")
+		}
+
+		ctx, cancel = WithTimeout(context.Background(), time.Hour)
+		cancel()
+		<-ctx.Done()
+		if err := ctx.Err(); err != context.Canceled {
+			t.Errorf("Err() after cancel = %v, want context.Canceled", err)
+		}
+	})
+}
+
+// TestWithDeadline verifies that a deadline in the past expires the context
+// at once, that one in the future does not, and that a parent's earlier
+// deadline is kept.
+func TestWithDeadline(t *testing.T) {
+	weft.RunDefault(t, func() {
+		ctx, cancel := WithDeadline(context.Background(), weft.Now().Add(time.Hour))
+		if err := ctx.Err(); err != nil {
+			t.Errorf("Err() with deadline an hour away = %v, want nil", err)
+		}
+		cancel()
+
+		ctx, cancel = WithDeadline(context.Background(), weft.Now().Add(-time.Second))
+		defer cancel()
+		<-ctx.Done()
+		if err := ctx.Err(); err != context.DeadlineExceeded {
+			t.Errorf("Err() with past deadline = %v, want context.DeadlineExceeded", err)
+		}
+
+		parent, cancelParent := WithTimeout(context.Background(), time.Minute)
+		defer cancelParent()
+		want, _ := parent.Deadline()
+		ctx, cancel = WithDeadline(parent, want.Add(time.Hour))
+		defer cancel()
+		if got, _ := ctx.Deadline(); !got.Equal(want) {
+			t.Errorf("Deadline() = %v, want parent's %v", got, want)
+		}
+	})
+}
+
+// TestDone verifies that the channel from Done is closed when the context
+// is canceled.
+func TestDone(t *testing.T) {
+	weft.RunDefault(t, func() {
+		ctx, cancel := WithCancel(context.Background())
+		done := Done(ctx)
+		cancel()
+		if _, ok := done.Recv(); ok {
+			t.Error("Recv() on Done ok = true, want false")
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package weftcontext
+
+import (
+	"context"
+
+	"github.com/mziter/weft"
+)
+
+// Done returns a weft channel closed when ctx is done, by AfterFunc.
+// Waiting on it, with Recv or weft.Select, is a wait the scheduler sees,
+// unlike a receive from ctx.Done().
+func Done(ctx context.Context) weft.Chan[struct{}] {
+	c := weft.MakeChan[struct{}](0)
+	AfterFunc(ctx, c.Close)
+	return c
+}
@@ -0,0 +1,183 @@
+package weftexec
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// Fake is a Runner of fake processes on a weft scheduler. Its zero value is
+// not usable; create fakes with NewFake.
+type Fake struct {
+	s *weft.Scheduler
+
+	mu       weft.Mutex
+	handlers map[string]Handler
+	calls    []Call
+}
+
+// NewFake returns a fake that knows no commands and whose processes run on
+// tasks of s.
+func NewFake(s *weft.Scheduler) *Fake {
+	return &Fake{s: s, handlers: make(map[string]Handler)}
+}
+
+// A Handler is the body of a fake process: it reads p.Stdin, writes
+// p.Stdout and p.Stderr, and returns the exit code. It runs on a task of
+// its own and should return soon after p is killed; until it does, Wait
+// waits, as for a process slow to die.
+type Handler func(p *Process) int
+
+// Process is a fake process, as its Handler sees it.
+type Process struct {
+	// Args are the command's name and arguments, and Env and Dir its
+	// environment and working directory.
+	Args []string
+	Env  []string
+	Dir  string
+
+	// Stdin is the command's standard input, empty if it has none, and
+	// Stdout and Stderr its outputs, discarded if it has none.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+
+	s *weft.Scheduler
+
+	// killed is closed when the process is killed, and exited receives
+	// the exit code of the handler.
+	killed weft.Chan[struct{}]
+	exited weft.Chan[int]
+
+	// isKilled is set when the process is killed, and done when its
+	// handler returns.
+	mu             weft.Mutex
+	isKilled, done bool
+}
+
+// Killed returns a channel that is closed when the process is killed.
+func (p *Process) Killed() weft.Chan[struct{}] {
+	return p.killed
+}
+
+// Sleep waits for d of virtual time to pass, reporting false if the process
+// is killed first.
+func (p *Process) Sleep(d time.Duration) bool {
+	return weft.Select(weft.OnRecv(p.s.After(d)), weft.OnRecv(p.killed)) == 0
+}
+
+// Call is a command a Fake was asked to run.
+type Call struct {
+	Args []string
+	Env  []string
+	Dir  string
+}
+
+// Handle makes the fake run h for commands called name. A name with no
+// handler fails to start with exec.ErrNotFound.
+func (f *Fake) Handle(name string, h Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[name] = h
+}
+
+// Result is a canned outcome of a fake process.
+type Result struct {
+	// Stdout and Stderr are written to the process's outputs.
+	Stdout, Stderr string
+
+	// Exit is the exit code.
+	Exit int
+
+	// Duration is the virtual time the process takes to exit, and
+	// KillDelay the time it takes to die once killed.
+	Duration, KillDelay time.Duration
+}
+
+// Set makes the fake run commands called name with the outcome r: after
+// r.Duration, the process writes its outputs and exits with r.Exit.
+func (f *Fake) Set(name string, r Result) {
+	f.Handle(name, func(p *Process) int {
+		if !p.Sleep(r.Duration) {
+			p.s.Sleep(r.KillDelay)
+			return -1
+		}
+		io.WriteString(p.Stdout, r.Stdout)
+		io.WriteString(p.Stderr, r.Stderr)
+		return r.Exit
+	})
+}
+
+// Calls returns the commands the fake has been asked to run, in order,
+// including those it did not know.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+func (f *Fake) start(c *Cmd) (process, error) {
+	f.mu.Lock()
+	h, ok := f.handlers[c.Path]
+	f.calls = append(f.calls, Call{Args: slices.Clone(c.Args), Env: slices.Clone(c.Env), Dir: c.Dir})
+	f.mu.Unlock()
+	if !ok {
+		return nil, &exec.Error{Name: c.Path, Err: exec.ErrNotFound}
+	}
+
+	p := &Process{
+		Args:   c.Args,
+		Env:    c.Env,
+		Dir:    c.Dir,
+		Stdin:  c.Stdin,
+		Stdout: c.Stdout,
+		Stderr: c.Stderr,
+		s:      f.s,
+		killed: weft.MakeChan[struct{}](0),
+		exited: weft.MakeChan[int](1),
+	}
+	if p.Stdin == nil {
+		p.Stdin = strings.NewReader("")
+	}
+	if p.Stdout == nil {
+		p.Stdout = io.Discard
+	}
+	if p.Stderr == nil {
+		p.Stderr = io.Discard
+	}
+	f.s.Go(func(weft.Context) {
+		code := h(p)
+		p.mu.Lock()
+		p.done = true
+		p.mu.Unlock()
+		p.exited.Send(code)
+	})
+	return p, nil
+}
+
+func (p *Process) wait() (int, error) {
+	code, _ := p.exited.Recv()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.isKilled {
+		return -1, nil
+	}
+	return code, nil
+}
+
+func (p *Process) kill() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return os.ErrProcessDone
+	}
+	if !p.isKilled {
+		p.isKilled = true
+		p.killed.Close()
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package weftexec runs subprocesses through a Runner, so that code that
+// shells out can run real commands in production and fake processes in
+// weft tests. Code builds commands with Command instead of exec.Command;
+// production passes Exec, which runs them with os/exec, and tests pass a
+// Fake, whose processes are handlers running on weft tasks: their output,
+// exit codes, virtual-time durations and response to being killed are the
+// test's to choose, and the scheduler explores them against everything
+// else.
+//
+//	func Deploy(ctx context.Context, r weftexec.Runner, host string) error {
+//		out, err := weftexec.CommandContext(ctx, r, "ssh", host, "systemctl restart app").Output()
+//		// ...
+//	}
+//
+//	f := weftexec.NewFake(s)
+//	f.Set("ssh", weftexec.Result{Stdout: "ok\n", Duration: 2 * time.Second})
+//	err := Deploy(ctx, f, "web-1")
+package weftexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// A Runner starts the processes of commands. The implementations are Exec
+// and *Fake.
+type Runner interface {
+	// start starts c and returns its process.
+	start(c *Cmd) (process, error)
+}
+
+// process is a started command.
+type process interface {
+	// wait waits for the process to exit and returns its exit code, -1
+	// if it was killed, or an error if it could not be waited for.
+	wait() (code int, err error)
+
+	// kill kills the process.
+	kill() error
+}
+
+// Cmd is a command to run, like exec.Cmd. Its fields are set before Start
+// and mean what exec.Cmd's do.
+type Cmd struct {
+	// Path is the name of the command, and Args its name and arguments.
+	Path string
+	Args []string
+
+	Env []string
+	Dir string
+
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+
+	ctx    context.Context
+	runner Runner
+	proc   process
+	stop   func() bool
+	code   int
+	waited bool
+}
+
+// Command returns a command to run name with arg on r.
+func Command(r Runner, name string, arg ...string) *Cmd {
+	return &Cmd{Path: name, Args: append([]string{name}, arg...), runner: r}
+}
+
+// CommandContext is like Command, but the process is killed if ctx is done
+// before it exits.
+func CommandContext(ctx context.Context, r Runner, name string, arg ...string) *Cmd {
+	c := Command(r, name, arg...)
+	c.ctx = ctx
+	return c
+}
+
+// ExitError is the error of a process that did not exit successfully.
+type ExitError struct {
+	// Code is the exit code, or -1 if the process was killed.
+	Code int
+
+	// Stderr is the standard error of the process, if Output collected
+	// it.
+	Stderr []byte
+}
+
+func (e *ExitError) Error() string {
+	if e.Code < 0 {
+		return "signal: killed"
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Start starts the command without waiting for it to exit.
+func (c *Cmd) Start() error {
+	if c.proc != nil {
+		return errors.New("weftexec: already started")
+	}
+	if c.ctx != nil {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	p, err := c.runner.start(c)
+	if err != nil {
+		return err
+	}
+	c.proc = p
+	if c.ctx != nil {
+		c.stop = context.AfterFunc(c.ctx, func() { p.kill() })
+	}
+	return nil
+}
+
+// Wait waits for the started command to exit. It returns an *ExitError if
+// the process exits with a code other than 0 or is killed.
+func (c *Cmd) Wait() error {
+	if c.proc == nil {
+		return errors.New("weftexec: not started")
+	}
+	if c.waited {
+		return errors.New("weftexec: Wait was already called")
+	}
+	c.waited = true
+	code, err := c.proc.wait()
+	if c.stop != nil {
+		c.stop()
+	}
+	c.code = code
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &ExitError{Code: code}
+	}
+	return nil
+}
+
+// Run starts the command and waits for it to exit.
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Output runs the command and returns its standard output. If Stderr is
+// nil, the standard error is collected in the *ExitError of a failure.
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("weftexec: Stdout already set")
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	captureErr := c.Stderr == nil
+	if captureErr {
+		c.Stderr = &stderr
+	}
+	err := c.Run()
+	var ee *ExitError
+	if captureErr && errors.As(err, &ee) {
+		ee.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its standard output and
+// standard error together.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil || c.Stderr != nil {
+		return nil, errors.New("weftexec: Stdout or Stderr already set")
+	}
+	var out bytes.Buffer
+	c.Stdout, c.Stderr = &out, &out
+	err := c.Run()
+	return out.Bytes(), err
+}
+
+// Kill kills the started command.
+func (c *Cmd) Kill() error {
+	if c.proc == nil {
+		return errors.New("weftexec: not started")
+	}
+	return c.proc.kill()
+}
+
+// ExitCode returns the exit code of the command once Wait has returned: -1
+// if it was killed.
+func (c *Cmd) ExitCode() int {
+	return c.code
+}
+
+// Exec is the Runner of real processes, started with os/exec.
+var Exec Runner = execRunner{}
+
+type execRunner struct{}
+
+func (execRunner) start(c *Cmd) (process, error) {
+	cmd := exec.Command(c.Path, c.Args[1:]...)
+	cmd.Env, cmd.Dir = c.Env, c.Dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = c.Stdin, c.Stdout, c.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return execProcess{cmd}, nil
+}
+
+type execProcess struct{ cmd *exec.Cmd }
+
+func (p execProcess) wait() (int, error) {
+	err := p.cmd.Wait()
+	var ee *exec.ExitError
+	switch {
+	case errors.As(err, &ee):
+		return ee.ExitCode(), nil
+	case p.cmd.ProcessState == nil:
+		return -1, err
+	}
+	return p.cmd.ProcessState.ExitCode(), err
+}
+
+func (p execProcess) kill() error {
+	return p.cmd.Process.Kill()
+}
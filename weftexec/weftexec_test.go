@@ -0,0 +1,87 @@
+package weftexec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// TestFake verifies that fake processes produce their results and that
+// their calls are recorded.
+func TestFake(t *testing.T) {
+	f := NewFake(weft.NewScheduler(1))
+	f.Set("git", Result{Stdout: "main\n"})
+	f.Set("make", Result{Stderr: "missing target\n", Exit: 2})
+	f.Handle("cat", func(p *Process) int {
+		io.Copy(p.Stdout, p.Stdin)
+		return 0
+	})
+
+	out, err := Command(f, "git", "branch", "--show-current").Output()
+	if err != nil || string(out) != "main\n" {
+		t.Errorf("git Output() = %q, %v", out, err)
+	}
+	_, err = Command(f, "make", "deploy").Output()
+	var ee *ExitError
+	if !errors.As(err, &ee) || ee.Code != 2 || string(ee.Stderr) != "missing target\n" {
+		t.Errorf("make Output() error = %v", err)
+	}
+	cat := Command(f, "cat")
+	cat.Stdin = strings.NewReader("meow")
+	if out, err := cat.CombinedOutput(); err != nil || string(out) != "meow" {
+		t.Errorf("cat CombinedOutput() = %q, %v", out, err)
+	}
+	if err := Command(f, "rm", "-rf", "/").Run(); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("unknown command Run() = %v, want exec.ErrNotFound", err)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 4 || strings.Join(calls[1].Args, " ") != "make deploy" {
+		t.Errorf("Calls() = %v", calls)
+	}
+}
+
+// TestKill verifies that killing a process, directly or through its
+// context, makes Wait report it killed.
+func TestKill(t *testing.T) {
+	f := NewFake(weft.NewScheduler(1))
+	f.Set("sleep", Result{Duration: time.Hour})
+
+	c := Command(f, "sleep", "3600")
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	c.Kill()
+	var ee *ExitError
+	if err := c.Wait(); !errors.As(err, &ee) || ee.Code != -1 || c.ExitCode() != -1 {
+		t.Errorf("Wait() after Kill = %v, want killed", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c = CommandContext(ctx, f, "sleep", "3600")
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := c.Wait(); !errors.As(err, &ee) || ee.Code != -1 {
+		t.Errorf("Wait() after cancel = %v, want killed", err)
+	}
+}
+
+// TestExec verifies that Exec runs real processes.
+func TestExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	out, err := Command(Exec, "sh", "-c", "echo hi; exit 3").Output()
+	var ee *ExitError
+	if string(out) != "hi\n" || !errors.As(err, &ee) || ee.Code != 3 {
+		t.Errorf("Output() = %q, %v; want hi, exit status 3", out, err)
+	}
+}
@@ -0,0 +1,136 @@
+package weftfs
+
+import (
+	"slices"
+)
+
+// write is a change to a file's data since it was synced.
+type write struct {
+	off  int64
+	data []byte
+
+	// truncate makes the change a truncation, or extension, to size off.
+	truncate bool
+}
+
+// write writes b at offset off of n, extending it with zeros if off is
+// beyond its end.
+func (n *inode) write(b []byte, off int64) {
+	n.data = apply(n.data, write{off: off, data: b})
+	n.pending = append(n.pending, write{off: off, data: slices.Clone(b)})
+}
+
+// truncate changes the size of n, extending it with zeros if size is beyond
+// its end.
+func (n *inode) truncate(size int64) {
+	w := write{off: size, truncate: true}
+	n.data = apply(n.data, w)
+	n.pending = append(n.pending, w)
+}
+
+// apply returns data with w applied.
+func apply(data []byte, w write) []byte {
+	end := w.off + int64(len(w.data))
+	if w.truncate && end < int64(len(data)) {
+		return data[:end:end]
+	}
+	if end > int64(len(data)) {
+		data = append(data, make([]byte, end-int64(len(data)))...)
+	}
+	copy(data[w.off:], w.data)
+	return data
+}
+
+// A CrashModel decides what becomes of changes to file data that were not
+// synced when the file system crashes. Each write and truncation since a
+// file was last synced survives or is lost on its own, as the page cache
+// flushes them in no particular order, and a surviving write larger than a
+// sector can be torn, leaving only its first sectors on disk. Entries not
+// synced in their directory are always lost.
+//
+// The zero CrashModel loses every change that was not synced.
+type CrashModel struct {
+	// Keep is the probability, from 0 to 1, that a change survives.
+	Keep float64
+
+	// Tear is the probability that a surviving write is torn.
+	Tear float64
+
+	// SectorSize is the size of the units, aligned in the file, that are
+	// written atomically. Zero means 512 bytes.
+	SectorSize int
+}
+
+// Crash discards everything that has not been made durable, as if the
+// machine lost power: file data written since the file was last synced,
+// and entries changed since their directory was last synced. Files opened
+// before the crash fail with ErrCrashed.
+func (fsys *FS) Crash() {
+	fsys.CrashWith(CrashModel{})
+}
+
+// CrashWith is like Crash, but changes to file data that were not synced
+// survive, or are torn, as m decides. Which ones do is a decision of the
+// schedule, so a failing run replays with the same disk contents.
+//
+//	fsys.CrashWith(weftfs.CrashModel{Keep: 0.5, Tear: 0.2})
+func (fsys *FS) CrashWith(m CrashModel) {
+	if m.SectorSize <= 0 {
+		m.SectorSize = 512
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	fsys.gen++
+	fsys.open = 0
+	fsys.revert(fsys.root, m, make(map[*inode]bool))
+}
+
+// revert returns n, and the inodes durably reachable from it, to their
+// synced state, with the pending changes m keeps. The caller must hold
+// fsys.mu.
+func (fsys *FS) revert(n *inode, m CrashModel, seen map[*inode]bool) {
+	if seen[n] {
+		return
+	}
+	seen[n] = true
+	if !n.isDir() {
+		data := slices.Clone(n.synced)
+		for _, w := range n.pending {
+			if !fsys.s.Chance(m.Keep) {
+				continue
+			}
+			if !w.truncate && fsys.s.Chance(m.Tear) {
+				w.data = w.data[:fsys.tear(w, int64(m.SectorSize))]
+			}
+			data = apply(data, w)
+		}
+		n.data, n.synced, n.pending = data, slices.Clone(data), nil
+		return
+	}
+	// Visit the entries in order, so that the decisions are made in the
+	// same order on replay.
+	names := make([]string, 0, len(n.syncedEntries))
+	for name := range n.syncedEntries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	n.entries = make(map[string]*inode, len(names))
+	for _, name := range names {
+		child := n.syncedEntries[name]
+		n.entries[name] = child
+		fsys.revert(child, m, seen)
+	}
+}
+
+// tear returns the length of the part of w that survives a tear: up to one
+// of the sector boundaries within it, or all of it if it lies within one
+// sector.
+func (fsys *FS) tear(w write, sector int64) int64 {
+	end := w.off + int64(len(w.data))
+	first := (w.off/sector + 1) * sector
+	if first >= end {
+		return int64(len(w.data))
+	}
+	bounds := int((end-1-first)/sector) + 1
+	return first + int64(fsys.s.Choose(bounds))*sector - w.off
+}
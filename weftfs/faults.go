@@ -0,0 +1,143 @@
+package weftfs
+
+import (
+	"errors"
+	"path"
+	"time"
+
+	"github.com/mziter/weft/latency"
+)
+
+// ErrInjected is the error of a Fault that gives none.
+var ErrInjected = errors.New("injected I/O error")
+
+// A Fault makes matching operations fail.
+type Fault struct {
+	// Op is the operation to fail, named as in the fs.PathError it
+	// returns: "open", "read", "write", "seek", "truncate", "sync",
+	// "stat", "readdir", "mkdir", "remove" or "rename". Empty matches
+	// every operation.
+	Op string
+
+	// Path is a path.Match pattern for the names to fail; a rename
+	// matches by its old name. Empty matches every name.
+	Path string
+
+	// Err is the error the operation fails with, wrapped in an
+	// fs.PathError. Nil means ErrInjected.
+	Err error
+
+	// Rate is the probability, from 0 to 1, that a matching operation
+	// fails. Zero means every matching operation fails.
+	Rate float64
+
+	// Count is the number of operations to fail before the fault is
+	// removed. Zero means no limit.
+	Count int
+}
+
+// injected is a fault in effect.
+type injected struct {
+	Fault
+	failed int
+}
+
+// Inject makes operations matching f fail from now until remove is
+// called.
+//
+//	fsys.Inject(weftfs.Fault{Op: "sync", Path: "wal/*", Count: 1})
+func (fsys *FS) Inject(f Fault) (remove func()) {
+	in := &injected{Fault: f}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	fsys.faults = append(fsys.faults, in)
+	return func() {
+		fsys.mu.Lock()
+		defer fsys.mu.Unlock()
+		fsys.remove(in)
+	}
+}
+
+// remove removes in from the faults in effect. The caller must hold
+// fsys.mu.
+func (fsys *FS) remove(in *injected) {
+	for i, f := range fsys.faults {
+		if f == in {
+			fsys.faults = append(fsys.faults[:i], fsys.faults[i+1:]...)
+			return
+		}
+	}
+}
+
+// fault returns the error of the first fault in effect that fails
+// operation op on name, or nil. The caller must hold fsys.mu.
+func (fsys *FS) fault(op, name string) error {
+	for _, in := range fsys.faults {
+		if in.Op != "" && in.Op != op {
+			continue
+		}
+		if in.Path != "" {
+			if ok, _ := path.Match(in.Path, name); !ok {
+				continue
+			}
+		}
+		if in.Rate > 0 && !fsys.s.Chance(in.Rate) {
+			continue
+		}
+		in.failed++
+		if in.Count > 0 && in.failed >= in.Count {
+			fsys.remove(in)
+		}
+		if in.Err == nil {
+			return ErrInjected
+		}
+		return in.Err
+	}
+	return nil
+}
+
+// Latency is the range of virtual time an operation takes.
+type Latency struct {
+	Min, Max time.Duration
+
+	// Dist, if set, draws the time instead, and Min and Max are ignored.
+	Dist latency.Distribution
+}
+
+// latencySteps is the number of durations a latency is drawn from.
+const latencySteps = 16
+
+// SetLatency makes each operation op, named as in Fault, take from l.Min to
+// l.Max of virtual time, as the schedule decides; an empty op sets the
+// latency of operations without one of their own. The operation takes
+// effect at the end of that time, and others proceed meanwhile.
+//
+//	fsys.SetLatency("sync", weftfs.Latency{Min: time.Millisecond, Max: 20 * time.Millisecond})
+//	fsys.SetLatency("read", weftfs.Latency{Dist: latency.Exponential(0, time.Millisecond)})
+func (fsys *FS) SetLatency(op string, l Latency) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if fsys.latency == nil {
+		fsys.latency = make(map[string]Latency)
+	}
+	fsys.latency[op] = l
+}
+
+// delay waits for the latency of operation op.
+func (fsys *FS) delay(op string) {
+	fsys.mu.Lock()
+	l, ok := fsys.latency[op]
+	if !ok {
+		l = fsys.latency[""]
+	}
+	d := l.Min
+	if l.Dist != nil {
+		d = l.Dist.Draw(fsys.s)
+	} else if l.Max > l.Min {
+		d += (l.Max - l.Min) * time.Duration(fsys.s.Choose(latencySteps)) / (latencySteps - 1)
+	}
+	fsys.mu.Unlock()
+	if d > 0 {
+		fsys.s.Sleep(d)
+	}
+}
@@ -0,0 +1,281 @@
+package weftfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"time"
+)
+
+// File is an open file or directory of an FS. It implements fs.File and
+// fs.ReadDirFile, and the reading, writing and seeking methods of os.File.
+// Its methods must not be called concurrently.
+type File struct {
+	fsys *FS
+	name string
+	node *inode
+	flag int
+
+	// gen is the generation of fsys the file was opened in.
+	gen int
+
+	offset int64
+	closed bool
+
+	// dirNames are the names left to return from ReadDir, once it has been
+	// called.
+	dirNames []string
+}
+
+// Name returns the name the file was opened with.
+func (f *File) Name() string {
+	return f.name
+}
+
+// check returns the error of an operation op on f that needs it writable,
+// readable, or neither, or nil if op can go ahead. The caller must hold
+// f.fsys.mu.
+func (f *File) check(op string, write, read bool) error {
+	var err error
+	switch {
+	case f.closed:
+		err = fs.ErrClosed
+	case f.gen != f.fsys.gen:
+		err = ErrCrashed
+	case write && f.flag&(os.O_WRONLY|os.O_RDWR) == 0,
+		read && f.flag&os.O_WRONLY != 0:
+		err = fs.ErrPermission
+	case (read || write) && f.node.isDir():
+		err = errIsDir
+	default:
+		err = f.fsys.fault(op, f.name)
+	}
+	if err != nil {
+		return &fs.PathError{Op: op, Path: f.name, Err: err}
+	}
+	return nil
+}
+
+// Read reads up to len(b) bytes from the file's offset.
+func (f *File) Read(b []byte) (int, error) {
+	f.fsys.delay("read")
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("read", false, true); err != nil {
+		return 0, err
+	}
+	n, err := f.readAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt reads len(b) bytes from offset off.
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	f.fsys.delay("read")
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("read", false, true); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	n, err := f.readAt(b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *File) readAt(b []byte, off int64) (int, error) {
+	if off >= int64(len(f.node.data)) {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return copy(b, f.node.data[off:]), nil
+}
+
+// Write writes b at the file's offset, or at its end if it was opened with
+// os.O_APPEND. The data is not durable until the file is synced.
+func (f *File) Write(b []byte) (int, error) {
+	f.fsys.delay("write")
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("write", true, false); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	n, err := f.writeAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteAt writes b at offset off, extending the file with zeros if off is
+// beyond its end.
+func (f *File) WriteAt(b []byte, off int64) (int, error) {
+	f.fsys.delay("write")
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("write", true, false); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrInvalid}
+	}
+	return f.writeAt(b, off)
+}
+
+// writeAt writes as much of b at off as fits within the file system's
+// space. The caller must hold f.fsys.mu.
+func (f *File) writeAt(b []byte, off int64) (int, error) {
+	room, err := f.fsys.room(f.node, off+int64(len(b)))
+	if err != nil {
+		// What fits overwrites the data past off, and then extends the
+		// file, from off or from its end, by room bytes.
+		size := int64(len(f.node.data))
+		keep := max(min(size-off, int64(len(b))), 0) + room - max(off-size, 0)
+		err = &fs.PathError{Op: "write", Path: f.name, Err: err}
+		if keep <= 0 {
+			return 0, err
+		}
+		b = b[:keep]
+	}
+	f.node.write(b, off)
+	return len(b), err
+}
+
+// Seek sets the offset of the next Read or Write, as io.Seeker describes.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("seek", false, false); err != nil {
+		return 0, err
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Truncate changes the size of the file, extending it with zeros if size is
+// beyond its end.
+func (f *File) Truncate(size int64) error {
+	f.fsys.delay("truncate")
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("truncate", true, false); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.fsys.room(f.node, size); err != nil {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: err}
+	}
+	f.node.truncate(size)
+	return nil
+}
+
+// Sync makes the file's data durable or, for a directory, the entries
+// created, renamed and removed in it.
+func (f *File) Sync() error {
+	f.fsys.delay("sync")
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("sync", false, false); err != nil {
+		return err
+	}
+	f.node.sync()
+	return nil
+}
+
+// Stat returns a FileInfo describing the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	f.fsys.delay("stat")
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("stat", false, false); err != nil {
+		return nil, err
+	}
+	return f.node.stat(path.Base(f.name)), nil
+}
+
+// ReadDir returns the next n entries of the directory, sorted by name, as
+// fs.ReadDirFile describes.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.fsys.delay("readdir")
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("readdir", false, false); err != nil {
+		return nil, err
+	}
+	if !f.node.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errNotDir}
+	}
+	if f.dirNames == nil {
+		f.dirNames = make([]string, 0, len(f.node.entries))
+		for name := range f.node.entries {
+			f.dirNames = append(f.dirNames, name)
+		}
+		slices.Sort(f.dirNames)
+	}
+	var entries []fs.DirEntry
+	for len(f.dirNames) > 0 && (n <= 0 || len(entries) < n) {
+		name := f.dirNames[0]
+		f.dirNames = f.dirNames[1:]
+		// Entries removed since the first call are skipped.
+		if child, ok := f.node.entries[name]; ok {
+			entries = append(entries, fs.FileInfoToDirEntry(child.stat(name)))
+		}
+	}
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+// Close closes the file. It does not sync it.
+func (f *File) Close() error {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.gen == f.fsys.gen {
+		f.fsys.open--
+	}
+	return nil
+}
+
+// stat returns a FileInfo describing n under name.
+func (n *inode) stat(name string) fs.FileInfo {
+	return &fileInfo{name: name, size: int64(len(n.data)), mode: n.mode}
+}
+
+// fileInfo describes a file. Its modification time is always zero.
+type fileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
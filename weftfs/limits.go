@@ -0,0 +1,75 @@
+package weftfs
+
+import "syscall"
+
+// Limits bound the resources of a file system. The zero Limits is
+// unbounded.
+type Limits struct {
+	// OpenFiles is the number of files and directories that may be open
+	// at once, like the file descriptors of a process. Opening one more
+	// fails with syscall.EMFILE until one is closed. Zero means no limit.
+	OpenFiles int
+
+	// Space is the number of bytes the files may hold. A write that would
+	// exceed it writes what fits and fails with syscall.ENOSPC, and a
+	// truncation that would fails outright. Zero means no limit.
+	Space int64
+
+	// Exhaustion is the probability, from 0 to 1, that an open, or a write
+	// or truncation that grows a file, fails as though its limit had been
+	// reached, as the schedule decides, as when other processes share the
+	// descriptors and the disk.
+	Exhaustion float64
+}
+
+// SetLimits sets the limits of the file system. Files already open count
+// against OpenFiles, and data already written against Space.
+//
+//	fsys.SetLimits(weftfs.Limits{OpenFiles: 16, Space: 1 << 20})
+func (fsys *FS) SetLimits(l Limits) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	fsys.limits = l
+}
+
+// acquire takes a file descriptor for a file being opened, or returns
+// syscall.EMFILE if there is none. The caller must hold fsys.mu.
+func (fsys *FS) acquire() error {
+	l := fsys.limits
+	if l.OpenFiles > 0 && fsys.open >= l.OpenFiles || fsys.s.Chance(l.Exhaustion) {
+		return syscall.EMFILE
+	}
+	fsys.open++
+	return nil
+}
+
+// room returns how many bytes of a write or truncation of n that would
+// extend it to end fit within the space left, and syscall.ENOSPC if not all
+// of them do. The caller must hold fsys.mu.
+func (fsys *FS) room(n *inode, end int64) (int64, error) {
+	grow := end - int64(len(n.data))
+	if grow <= 0 {
+		return grow, nil
+	}
+	l := fsys.limits
+	if fsys.s.Chance(l.Exhaustion) {
+		return 0, syscall.ENOSPC
+	}
+	if l.Space <= 0 {
+		return grow, nil
+	}
+	if left := l.Space - fsys.used(fsys.root); grow > left {
+		return max(left, 0), syscall.ENOSPC
+	}
+	return grow, nil
+}
+
+// used returns the bytes held by the files in and under directory n. The
+// caller must hold fsys.mu.
+func (fsys *FS) used(n *inode) int64 {
+	size := int64(len(n.data))
+	for _, child := range n.entries {
+		size += fsys.used(child)
+	}
+	return size
+}
@@ -0,0 +1,387 @@
+// Package weftfs is an in-memory file system for weft tests. Its operations
+// synchronize through the scheduler, so the scheduler explores how file
+// system calls interleave with everything else, and it keeps apart what has
+// been written from what has been made durable, so storage code such as
+// write-ahead logs and compactors can be tested for what survives a crash.
+//
+// As on a POSIX file system, File.Sync makes a file's data durable, and
+// syncing a directory makes the entries created, renamed or removed in it
+// durable. Crash discards everything that was not; CrashWith can instead
+// keep or tear some of the unsynced writes, so durability claims such as
+// "a record survives a crash once Commit returns" become testable.
+//
+// Inject makes chosen operations fail, SetLatency makes them take virtual
+// time, and SetLimits runs the file system out of descriptors and space.
+// Whether a probabilistic fault happens, how long an operation takes and
+// which unsynced writes a crash keeps are decisions of the schedule, made
+// with Scheduler.Choose, so a failing run replays, and shrinks, with
+// exactly the same faults.
+//
+//	fsys := weftfs.New(s)
+//	f, _ := fsys.Create("wal/000001.log")
+//	f.Write(record)
+//	f.Sync()
+//	fsys.SyncDir("wal")
+//	fsys.Crash()
+//
+// Names are slash-separated and unrooted, as in package io/fs; "." is the
+// root directory.
+package weftfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mziter/weft"
+)
+
+// ErrCrashed is returned, wrapped, by operations on a file opened before
+// the file system crashed.
+var ErrCrashed = errors.New("file opened before crash")
+
+// FS is an in-memory file system. Its zero value is not usable; create file
+// systems with New.
+type FS struct {
+	s *weft.Scheduler
+
+	mu   weft.Mutex
+	root *inode
+
+	// gen counts crashes; files opened in an earlier generation are dead.
+	gen int
+
+	faults  []*injected
+	latency map[string]Latency
+
+	// limits are the resource limits, and open the files open in the
+	// current generation.
+	limits Limits
+	open   int
+}
+
+// inode is a file or directory. The current state is what operations see,
+// and the synced state is what survives a crash.
+type inode struct {
+	mode fs.FileMode
+
+	data, synced []byte
+
+	// pending are the changes to data since it was synced, in order.
+	pending []write
+
+	entries, syncedEntries map[string]*inode
+}
+
+func newDir(perm fs.FileMode) *inode {
+	return &inode{
+		mode:          fs.ModeDir | perm.Perm(),
+		entries:       make(map[string]*inode),
+		syncedEntries: make(map[string]*inode),
+	}
+}
+
+func (n *inode) isDir() bool {
+	return n.mode.IsDir()
+}
+
+// New returns a file system holding an empty root directory, whose
+// operations synchronize through s.
+func New(s *weft.Scheduler) *FS {
+	return &FS{s: s, root: newDir(0o755)}
+}
+
+// walk returns the inode named name. The caller must hold fsys.mu.
+func (fsys *FS) walk(name string) (*inode, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	n := fsys.root
+	if name == "." {
+		return n, nil
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if !n.isDir() {
+			return nil, fs.ErrNotExist
+		}
+		child, ok := n.entries[elem]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// parent returns the directory holding name and the last element of name.
+// The caller must hold fsys.mu.
+func (fsys *FS) parent(name string) (*inode, string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, "", fs.ErrInvalid
+	}
+	dir, err := fsys.walk(path.Dir(name))
+	if err != nil {
+		return nil, "", err
+	}
+	if !dir.isDir() {
+		return nil, "", fs.ErrNotExist
+	}
+	return dir, path.Base(name), nil
+}
+
+// Open opens the named file or directory for reading.
+func (fsys *FS) Open(name string) (*File, error) {
+	return fsys.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create creates or truncates the named file and opens it for reading and
+// writing.
+func (fsys *FS) Create(name string) (*File, error) {
+	return fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// OpenFile opens the named file with flag, a combination of the os.O_
+// flags, creating it with perm if os.O_CREATE is given and it does not
+// exist. Directories can only be opened read-only.
+func (fsys *FS) OpenFile(name string, flag int, perm fs.FileMode) (*File, error) {
+	fsys.delay("open")
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if err := fsys.fault("open", name); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	n, err := fsys.walk(name)
+	switch {
+	case err == fs.ErrNotExist && flag&os.O_CREATE != 0:
+		dir, base, perr := fsys.parent(name)
+		if perr != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: perr}
+		}
+		n = &inode{mode: perm.Perm()}
+		dir.entries[base] = n
+	case err != nil:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case n.isDir() && flag&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC|os.O_APPEND) != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	case flag&os.O_TRUNC != 0:
+		n.truncate(0)
+	}
+	if err := fsys.acquire(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &File{fsys: fsys, name: name, node: n, flag: flag, gen: fsys.gen}, nil
+}
+
+// ReadFile returns the contents of the named file.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fsys.delay("read")
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if f.node.isDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	if err := fsys.fault("read", name); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return slices.Clone(f.node.data), nil
+}
+
+// WriteFile writes data to the named file, creating it with perm if
+// necessary and truncating it otherwise. Like os.WriteFile, it does not
+// sync the file.
+func (fsys *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Mkdir creates the named directory.
+func (fsys *FS) Mkdir(name string, perm fs.FileMode) error {
+	fsys.delay("mkdir")
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.mkdir(name, perm)
+}
+
+// mkdir creates the named directory. The caller must hold fsys.mu.
+func (fsys *FS) mkdir(name string, perm fs.FileMode) error {
+	if err := fsys.fault("mkdir", name); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	dir, base, err := fsys.parent(name)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if _, ok := dir.entries[base]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	dir.entries[base] = newDir(perm)
+	return nil
+}
+
+// MkdirAll creates the named directory and any parents it needs. It does
+// nothing if the directory exists.
+func (fsys *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.delay("mkdir")
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if name == "." {
+		return nil
+	}
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		dir := strings.Join(elems[:i+1], "/")
+		n, err := fsys.walk(dir)
+		switch {
+		case err == fs.ErrNotExist:
+			if err := fsys.mkdir(dir, perm); err != nil {
+				return err
+			}
+		case err != nil:
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: err}
+		case !n.isDir():
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errNotDir}
+		}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (fsys *FS) Remove(name string) error {
+	fsys.delay("remove")
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if err := fsys.fault("remove", name); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	dir, base, err := fsys.parent(name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	n, ok := dir.entries[base]
+	switch {
+	case !ok:
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	case n.isDir() && len(n.entries) > 0:
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(dir.entries, base)
+	return nil
+}
+
+// Rename renames oldname to newname, replacing any file at newname.
+// Neither directory is synced: until they are, a crash can leave the file
+// under its old name, its new name, or both.
+func (fsys *FS) Rename(oldname, newname string) error {
+	fsys.delay("rename")
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	fail := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	if err := fsys.fault("rename", oldname); err != nil {
+		return fail(err)
+	}
+	odir, obase, err := fsys.parent(oldname)
+	if err != nil {
+		return fail(err)
+	}
+	n, ok := odir.entries[obase]
+	if !ok {
+		return fail(fs.ErrNotExist)
+	}
+	if n.isDir() && strings.HasPrefix(newname, oldname+"/") {
+		return fail(fs.ErrInvalid)
+	}
+	ndir, nbase, err := fsys.parent(newname)
+	if err != nil {
+		return fail(err)
+	}
+	if old, ok := ndir.entries[nbase]; ok && old != n {
+		switch {
+		case old.isDir() && !n.isDir():
+			return fail(errIsDir)
+		case !old.isDir() && n.isDir():
+			return fail(errNotDir)
+		case old.isDir() && len(old.entries) > 0:
+			return fail(errNotEmpty)
+		}
+	}
+	delete(odir.entries, obase)
+	ndir.entries[nbase] = n
+	return nil
+}
+
+// Stat returns a FileInfo describing the named file.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	fsys.delay("stat")
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if err := fsys.fault("stat", name); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	n, err := fsys.walk(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return n.stat(path.Base(name)), nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.ReadDir(-1)
+}
+
+// SyncDir syncs the named directory, making the entries created, renamed
+// and removed in it durable.
+func (fsys *FS) SyncDir(name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// sync makes n's current state durable.
+func (n *inode) sync() {
+	if !n.isDir() {
+		n.synced = slices.Clone(n.data)
+		n.pending = nil
+		return
+	}
+	n.syncedEntries = make(map[string]*inode, len(n.entries))
+	for name, child := range n.entries {
+		n.syncedEntries[name] = child
+	}
+}
+
+var (
+	errIsDir    = errors.New("is a directory")
+	errNotDir   = errors.New("not a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
@@ -0,0 +1,208 @@
+package weftfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/latency"
+)
+
+// TestFS verifies that files are created, written, read back, renamed and
+// listed.
+func TestFS(t *testing.T) {
+	fsys := New(weft.NewScheduler(1))
+	if err := fsys.MkdirAll("data/wal", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.Create("data/wal/1.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, "hello ")
+	io.WriteString(f, "world")
+	f.Close()
+	if err := fsys.Rename("data/wal/1.tmp", "data/wal/1.log"); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := fsys.ReadFile("data/wal/1.log"); err != nil || string(b) != "hello world" {
+		t.Errorf("ReadFile() = %q, %v; want %q", b, err, "hello world")
+	}
+	if _, err := fsys.Stat("data/wal/1.tmp"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(old name) error = %v, want fs.ErrNotExist", err)
+	}
+	entries, err := fsys.ReadDir("data/wal")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "1.log" {
+		t.Errorf("ReadDir() = %v, %v; want [1.log]", entries, err)
+	}
+
+	f, _ = fsys.OpenFile("data/wal/1.log", os.O_WRONLY|os.O_APPEND, 0)
+	io.WriteString(f, "!")
+	f.Close()
+	if b, _ := fsys.ReadFile("data/wal/1.log"); string(b) != "hello world!" {
+		t.Errorf("after append, ReadFile() = %q, want %q", b, "hello world!")
+	}
+	if _, err := fsys.OpenFile("data/wal/1.log", os.O_CREATE|os.O_EXCL, 0o644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("OpenFile(O_EXCL) error = %v, want fs.ErrExist", err)
+	}
+	if err := fsys.Remove("data/wal"); err == nil {
+		t.Error("Remove(non-empty directory) succeeded")
+	}
+}
+
+// TestCrash verifies that a crash keeps exactly what was synced: file data
+// once the file is synced, and entries once their directory is.
+func TestCrash(t *testing.T) {
+	fsys := New(weft.NewScheduler(1))
+	write := func(name, data string, syncFile bool) {
+		t.Helper()
+		f, err := fsys.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, data)
+		if syncFile {
+			f.Sync()
+		}
+		f.Close()
+	}
+	write("durable", "kept", true)
+	write("unsynced", "lost", false)
+	fsys.SyncDir(".")
+	write("unlinked", "lost", true)
+
+	f, _ := fsys.OpenFile("durable", os.O_WRONLY|os.O_APPEND, 0)
+	io.WriteString(f, " and lost")
+	fsys.Crash()
+
+	if b, err := fsys.ReadFile("durable"); err != nil || string(b) != "kept" {
+		t.Errorf("durable = %q, %v; want %q", b, err, "kept")
+	}
+	if b, err := fsys.ReadFile("unsynced"); err != nil || len(b) != 0 {
+		t.Errorf("unsynced = %q, %v; want empty", b, err)
+	}
+	if _, err := fsys.Stat("unlinked"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(unlinked) error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, ErrCrashed) {
+		t.Errorf("Write() after crash error = %v, want ErrCrashed", err)
+	}
+}
+
+// TestInject verifies that faults fail the operations they match, and no
+// more than Count of them.
+func TestInject(t *testing.T) {
+	fsys := New(weft.NewScheduler(1))
+	fsys.WriteFile("a.log", []byte("a"), 0o644)
+	fsys.WriteFile("b.txt", []byte("b"), 0o644)
+	errFull := errors.New("no space left on device")
+	fsys.Inject(Fault{Op: "write", Path: "*.log", Err: errFull, Count: 1})
+
+	f, _ := fsys.OpenFile("b.txt", os.O_WRONLY, 0)
+	if _, err := f.Write([]byte("x")); err != nil {
+		t.Errorf("Write(b.txt) error = %v, want nil", err)
+	}
+	f, _ = fsys.OpenFile("a.log", os.O_WRONLY, 0)
+	if _, err := f.Write([]byte("x")); !errors.Is(err, errFull) {
+		t.Errorf("first Write(a.log) error = %v, want %v", err, errFull)
+	}
+	if _, err := f.Write([]byte("x")); err != nil {
+		t.Errorf("second Write(a.log) error = %v, want nil", err)
+	}
+
+	remove := fsys.Inject(Fault{Op: "open"})
+	if _, err := fsys.Open("b.txt"); !errors.Is(err, ErrInjected) {
+		t.Errorf("Open() error = %v, want ErrInjected", err)
+	}
+	remove()
+	if _, err := fsys.Open("b.txt"); err != nil {
+		t.Errorf("Open() after remove error = %v, want nil", err)
+	}
+}
+
+// TestCrashWith verifies that a crash model keeps and tears unsynced
+// writes, leaving synced data alone.
+func TestCrashWith(t *testing.T) {
+	tests := []struct {
+		name  string
+		model CrashModel
+		ok    func(string) bool
+	}{
+		{"lose", CrashModel{}, func(got string) bool { return got == "synced" }},
+		{"keep", CrashModel{Keep: 1}, func(got string) bool { return got == "synced0123456789" }},
+		{"tear", CrashModel{Keep: 1, Tear: 1, SectorSize: 4}, func(got string) bool {
+			// The write covers bytes 6 to 15, with sector boundaries at 8
+			// and 12 inside it.
+			return got == "synced01" || got == "synced012345"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := New(weft.NewScheduler(1))
+			f, _ := fsys.Create("log")
+			io.WriteString(f, "synced")
+			f.Sync()
+			fsys.SyncDir(".")
+			io.WriteString(f, "0123456789")
+			fsys.CrashWith(tt.model)
+
+			if b, _ := fsys.ReadFile("log"); !tt.ok(string(b)) {
+				t.Errorf("after crash, log = %q", b)
+			}
+		})
+	}
+}
+
+// TestLatency verifies that operations with a latency still take effect.
+func TestLatency(t *testing.T) {
+	fsys := New(weft.NewScheduler(1))
+	fsys.SetLatency("", Latency{Max: time.Millisecond})
+	fsys.SetLatency("sync", Latency{Min: time.Millisecond, Max: 2 * time.Millisecond})
+	fsys.SetLatency("write", Latency{Dist: latency.Exponential(0, time.Millisecond)})
+	f, err := fsys.Create("log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, "record")
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	fsys.SyncDir(".")
+	fsys.Crash()
+	if b, _ := fsys.ReadFile("log"); string(b) != "record" {
+		t.Errorf("after crash, log = %q, want %q", b, "record")
+	}
+}
+
+// TestLimits verifies that opening beyond OpenFiles fails with EMFILE until
+// a file is closed, and that a write beyond Space writes what fits.
+func TestLimits(t *testing.T) {
+	fsys := New(weft.NewScheduler(1))
+	fsys.SetLimits(Limits{OpenFiles: 1, Space: 8})
+	f, err := fsys.Create("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Create("b"); !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("Create beyond OpenFiles error = %v, want EMFILE", err)
+	}
+	if n, err := f.Write([]byte("0123456789")); n != 8 || !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("Write beyond Space = %d, %v; want 8, ENOSPC", n, err)
+	}
+	if n, err := f.WriteAt([]byte("ab"), 0); n != 2 || err != nil {
+		t.Errorf("overwriting WriteAt = %d, %v; want 2, nil", n, err)
+	}
+	if err := f.Truncate(9); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("Truncate beyond Space error = %v, want ENOSPC", err)
+	}
+	f.Close()
+	if b, err := fsys.ReadFile("a"); err != nil || string(b) != "ab234567" {
+		t.Errorf("ReadFile() = %q, %v; want %q", b, err, "ab234567")
+	}
+}
@@ -0,0 +1,89 @@
+// Package weftio wraps readers and writers to split their data at chunk
+// boundaries the schedule chooses, for weft tests. Parsers and framers that
+// assume a Read fills its buffer, or that one Write arrives as one read at
+// the other end, fail as they would on a busy socket or pipe; the split is
+// a decision of the schedule, made with Scheduler.Choose, so a failure
+// replays, and shrinks, with the same chunks.
+//
+//	r := weftio.NewReader(s, conn)
+//	msg, err := decoder.Decode(r) // must cope with short reads
+//
+// Decision 0 never shortens anything, so shrinking a failing trace keeps
+// only the splits the failure needs.
+package weftio
+
+import (
+	"io"
+
+	"github.com/mziter/weft"
+)
+
+// NewReader returns a Reader that reads from r, each Read asking r for as
+// many of the bytes requested, at least one, as the schedule decides.
+func NewReader(s *weft.Scheduler, r io.Reader) io.Reader {
+	return &reader{s: s, r: r}
+}
+
+type reader struct {
+	s *weft.Scheduler
+	r io.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:len(p)-r.s.Choose(len(p))]
+	}
+	return r.r.Read(p)
+}
+
+// NewWriter returns a Writer that writes each buffer to w in chunks, as
+// many and as long as the schedule decides. Every byte is still written,
+// so the split only shows at the other end of w.
+func NewWriter(s *weft.Scheduler, w io.Writer) io.Writer {
+	return &writer{s: s, w: w}
+}
+
+type writer struct {
+	s *weft.Scheduler
+	w io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n := 0
+	for {
+		chunk := p[n:]
+		if len(chunk) > 1 {
+			chunk = chunk[:len(chunk)-w.s.Choose(len(chunk))]
+		}
+		m, err := w.w.Write(chunk)
+		n += m
+		if err != nil || n == len(p) {
+			return n, err
+		}
+	}
+}
+
+// NewShortWriter returns a Writer that writes to w, but with probability
+// rate, from 0 to 1, a Write stops short after as many bytes as the
+// schedule decides and returns io.ErrShortWrite, as a writer may. Callers
+// that ignore the count written lose the rest.
+func NewShortWriter(s *weft.Scheduler, w io.Writer, rate float64) io.Writer {
+	return &shortWriter{s: s, w: w, rate: rate}
+}
+
+type shortWriter struct {
+	s    *weft.Scheduler
+	w    io.Writer
+	rate float64
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 || !w.s.Chance(w.rate) {
+		return w.w.Write(p)
+	}
+	n, err := w.w.Write(p[:w.s.Choose(len(p))])
+	if err == nil {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
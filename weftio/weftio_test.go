@@ -0,0 +1,82 @@
+package weftio
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mziter/weft"
+)
+
+const text = "the quick brown fox jumps over the lazy dog"
+
+// TestReader verifies that reads are short but lose nothing.
+func TestReader(t *testing.T) {
+	r := NewReader(weft.NewScheduler(1), strings.NewReader(text))
+	var got []byte
+	short := false
+	buf := make([]byte, 8)
+	for {
+		n, err := r.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		short = short || n < len(buf) && len(got) < len(text)
+	}
+	if string(got) != text {
+		t.Errorf("read %q, want %q", got, text)
+	}
+	if !short {
+		t.Error("no read was short")
+	}
+}
+
+// recorder records the writes made to it.
+type recorder struct {
+	bytes.Buffer
+	writes int
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.writes++
+	return r.Buffer.Write(p)
+}
+
+// TestWriter verifies that writes are split but complete.
+func TestWriter(t *testing.T) {
+	var rec recorder
+	w := NewWriter(weft.NewScheduler(1), &rec)
+	for i := 0; i < 4; i++ {
+		if n, err := io.WriteString(w, text); n != len(text) || err != nil {
+			t.Fatalf("WriteString() = %d, %v", n, err)
+		}
+	}
+	if rec.String() != strings.Repeat(text, 4) {
+		t.Errorf("wrote %q", rec.String())
+	}
+	if rec.writes <= 4 {
+		t.Errorf("%d writes reached the underlying writer, want more than 4", rec.writes)
+	}
+}
+
+// TestShortWriter verifies that a short write reports the bytes written
+// and io.ErrShortWrite.
+func TestShortWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewShortWriter(weft.NewScheduler(1), &buf, 1)
+	n, err := io.WriteString(w, text)
+	if err != io.ErrShortWrite || n >= len(text) || buf.String() != text[:n] {
+		t.Errorf("WriteString() = %d, %v, wrote %q", n, err, buf.String())
+	}
+
+	buf.Reset()
+	w = NewShortWriter(weft.NewScheduler(1), &buf, 0)
+	if n, err := io.WriteString(w, text); n != len(text) || err != nil {
+		t.Errorf("WriteString() with rate 0 = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build detsched
+
+package weftmember
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// TestFlap verifies that a flapping member is cut off from the others for
+// a while and stays live.
+func TestFlap(t *testing.T) {
+	s := weft.NewScheduler(1)
+	c := idle(s, "a", "b")
+	l, err := c.Network().Host("b").Listen(":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	done := weft.MakeChan[struct{}](0)
+	s.Go(func(weft.Context) {
+		defer done.Close()
+		c.Flap("a", 10*time.Second)
+	})
+	for {
+		// Wait for the partition to take effect.
+		if _, err := c.Network().Host("a").Dial("b:80"); err != nil {
+			break
+		}
+		if weft.TrySelect(weft.OnRecv(done)) >= 0 {
+			t.Fatal("Dial succeeded throughout the flap")
+		}
+		s.Sleep(time.Millisecond)
+	}
+	if !c.Live("a") {
+		t.Error("flapping member not live")
+	}
+	done.Recv()
+	if _, err := c.Network().Host("a").Dial("b:80"); err != nil {
+		t.Errorf("Dial after the flap: %v", err)
+	}
+	c.Fail("a")
+	c.Fail("b")
+	s.Wait()
+}
@@ -0,0 +1,383 @@
+// Package weftmember is a test harness for membership protocols, such as
+// gossip-based failure detectors, and for what depends on them, such as
+// consistent-hashing rebalancing. A Cluster runs each member as a weft.Node
+// on a host of a weftnet network and changes the membership under it:
+// members join, leave gracefully, fail without notice and flap, cut off
+// from the rest for a while. Churn makes such changes at intervals drawn
+// from the schedule, so the timing of joins and failures is explored and
+// replayed like any other interleaving, and the cluster's record of which
+// members are live is the ground truth to check the members' views
+// against.
+//
+//	c := weftmember.New(s, weftnet.New(s), []string{"a", "b", "c"}, start)
+//	done := c.Churn(weftmember.Churn{Changes: 10, Interval: latency.Uniform(0, time.Second), Min: 2})
+//	done.Recv()
+//	s.Sleep(10 * time.Second) // let the views settle
+//	if err := c.CheckViews(view); err != nil {
+//		t.Fatal(err)
+//	}
+package weftmember
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/latency"
+	"github.com/mziter/weft/weftnet"
+)
+
+// Kind is a kind of membership change.
+type Kind int
+
+// The kinds of membership change.
+const (
+	// Join starts a new member, or restarts one that left or failed.
+	Join Kind = iota
+
+	// Leave asks a member to leave, and stops it after a grace period.
+	Leave
+
+	// Fail crashes a member without notice.
+	Fail
+
+	// Flap cuts a member off from the others for a while; it stays live.
+	Flap
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Join:
+		return "join"
+	case Leave:
+		return "leave"
+	case Fail:
+		return "fail"
+	case Flap:
+		return "flap"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Change is a membership change the cluster made.
+type Change struct {
+	Kind   Kind
+	Member string
+}
+
+func (c Change) String() string {
+	return c.Kind.String() + " " + c.Member
+}
+
+// Cluster is a group of members whose membership changes. Its zero value
+// is not usable; create clusters with New.
+type Cluster struct {
+	s     *weft.Scheduler
+	net   *weftnet.Network
+	start func(c *Cluster, n *weft.Node)
+
+	mu      weft.Mutex
+	members map[string]*member
+	names   []string
+	history []Change
+
+	// joined counts the members Churn has added, to name them.
+	joined int
+}
+
+// member is a member of the cluster, live or not.
+type member struct {
+	node *weft.Node
+	live bool
+
+	// leaving is closed when the member is asked to leave.
+	leaving weft.Chan[struct{}]
+}
+
+// New starts a member called by each of names on a host of that name on
+// net, by running start for it as a weft.Node. start runs again whenever
+// the member rejoins, and should recover what the member kept durably, as
+// a process restarting would.
+func New(s *weft.Scheduler, net *weftnet.Network, names []string, start func(c *Cluster, n *weft.Node)) *Cluster {
+	c := &Cluster{s: s, net: net, start: start, members: make(map[string]*member)}
+	for _, name := range names {
+		c.Join(name)
+	}
+	return c
+}
+
+// Scheduler returns the scheduler the cluster runs on.
+func (c *Cluster) Scheduler() *weft.Scheduler {
+	return c.s
+}
+
+// Network returns the network the members communicate over.
+func (c *Cluster) Network() *weftnet.Network {
+	return c.net
+}
+
+// Node returns the node running the member called name, or nil if it
+// never joined.
+func (c *Cluster) Node(name string) *weft.Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m := c.members[name]; m != nil {
+		return m.node
+	}
+	return nil
+}
+
+// Members returns the names of the live members, sorted: those that have
+// joined and not since left or failed, including members cut off by a
+// flap.
+func (c *Cluster) Members() []string {
+	live, _ := c.split()
+	slices.Sort(live)
+	return live
+}
+
+// Live reports whether the member called name is live.
+func (c *Cluster) Live(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := c.members[name]
+	return m != nil && m.live
+}
+
+// History returns the changes made so far, in order, including the joins
+// of the first members.
+func (c *Cluster) History() []Change {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.history)
+}
+
+// Leaving returns a channel that is closed when the member called name is
+// asked to leave, for it to hand off its work and say goodbye before it is
+// stopped. The member must have joined.
+func (c *Cluster) Leaving(name string) weft.Chan[struct{}] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.members[name].leaving
+}
+
+// Join starts the member called name, or restarts it if it left or failed,
+// and returns its node. Joining a live member does nothing.
+func (c *Cluster) Join(name string) *weft.Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := c.members[name]
+	if m != nil && m.live {
+		return m.node
+	}
+	if m == nil {
+		m = &member{}
+		c.members[name] = m
+		c.names = append(c.names, name)
+	}
+	m.live = true
+	m.leaving = weft.MakeChan[struct{}](0)
+	c.history = append(c.history, Change{Join, name})
+	// The node starts on a task of its own, which can use the cluster
+	// once the lock is released.
+	if m.node != nil {
+		m.node.Restart()
+	} else {
+		m.node = c.s.StartNode(name, func(n *weft.Node) { c.start(c, n) })
+	}
+	return m.node
+}
+
+// Leave asks the live member called name to leave, closing its Leaving
+// channel, and stops it once grace has passed, unless it has rejoined
+// meanwhile. It returns when the member is stopped.
+func (c *Cluster) Leave(name string, grace time.Duration) {
+	m := c.depart(name, Leave)
+	if m == nil {
+		return
+	}
+	m.leaving.Close()
+	c.s.Sleep(grace)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !m.live {
+		m.node.Crash()
+	}
+}
+
+// Fail crashes the live member called name without notice.
+func (c *Cluster) Fail(name string) {
+	if m := c.depart(name, Fail); m != nil {
+		m.node.Crash()
+	}
+}
+
+// depart records that the live member called name departs with a change of
+// kind, and returns it, or nil if there is no such member.
+func (c *Cluster) depart(name string, kind Kind) *member {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := c.members[name]
+	if m == nil || !m.live {
+		return nil
+	}
+	m.live = false
+	c.history = append(c.history, Change{kind, name})
+	return m
+}
+
+// Flap cuts the member called name off from the other members for down,
+// and returns once it is back. The member stays live throughout: it is the
+// protocol's task not to declare it failed too eagerly, or to recover if
+// it does.
+func (c *Cluster) Flap(name string, down time.Duration) {
+	c.mu.Lock()
+	others := make([]string, 0, len(c.names))
+	for _, n := range c.names {
+		if n != name {
+			others = append(others, n)
+		}
+	}
+	c.history = append(c.history, Change{Flap, name})
+	c.mu.Unlock()
+	p := c.net.Partition([]string{name}, others)
+	c.s.Sleep(down)
+	p.Heal()
+}
+
+// CheckViews compares each live member's view of the membership, as view
+// returns it, with the live members. It returns an error describing the
+// first member whose view differs, or nil if every view agrees; views need
+// not be sorted.
+func (c *Cluster) CheckViews(view func(member string) []string) error {
+	live := c.Members()
+	for _, name := range live {
+		v := slices.Clone(view(name))
+		slices.Sort(v)
+		if !slices.Equal(v, live) {
+			return fmt.Errorf("weftmember: %s sees members %v, want %v", name, v, live)
+		}
+	}
+	return nil
+}
+
+// Churn describes a series of membership changes, made by Cluster.Churn.
+type Churn struct {
+	// Changes is the number of changes to make.
+	Changes int
+
+	// Interval draws the virtual time before each change. Nil means
+	// none.
+	Interval latency.Distribution
+
+	// Kinds are the kinds of change to choose among. Empty means all.
+	Kinds []Kind
+
+	// Min and Max bound the number of live members: a change that would
+	// take it outside them is not chosen. Zero Max means no bound.
+	Min, Max int
+
+	// Grace is the time a leaving member has before it is stopped, and
+	// Down draws how long a flapping member is cut off. Nil Down draws
+	// from Interval.
+	Grace time.Duration
+	Down  latency.Distribution
+}
+
+// Churn makes ch.Changes membership changes on a task of its own, each
+// after an interval, of a kind and to a member chosen by the schedule.
+// Joins restart a member that left or failed, or start a new one named
+// "member-N". Churn returns at once, with a channel that is closed once the
+// changes are made; History records them as they are.
+func (c *Cluster) Churn(ch Churn) (done weft.Chan[struct{}]) {
+	if len(ch.Kinds) == 0 {
+		ch.Kinds = []Kind{Join, Leave, Fail, Flap}
+	}
+	if ch.Down == nil {
+		ch.Down = ch.Interval
+	}
+	done = weft.MakeChan[struct{}](0)
+	c.s.Go(func(weft.Context) {
+		defer done.Close()
+		for i := 0; i < ch.Changes; i++ {
+			if ch.Interval != nil {
+				c.s.Sleep(ch.Interval.Draw(c.s))
+			}
+			c.change(ch)
+		}
+	})
+	return done
+}
+
+// change makes one change of the kinds ch allows, if any is possible.
+func (c *Cluster) change(ch Churn) {
+	live, departed := c.split()
+	var possible []Kind
+	for _, k := range ch.Kinds {
+		switch k {
+		case Join:
+			if ch.Max <= 0 || len(live) < ch.Max {
+				possible = append(possible, k)
+			}
+		case Leave, Fail:
+			if len(live) > max(ch.Min, 0) {
+				possible = append(possible, k)
+			}
+		case Flap:
+			if len(live) > 1 {
+				possible = append(possible, k)
+			}
+		}
+	}
+	if len(possible) == 0 {
+		return
+	}
+	switch possible[c.s.Choose(len(possible))] {
+	case Join:
+		// The last option is a new member.
+		if i := c.s.Choose(len(departed) + 1); i < len(departed) {
+			c.Join(departed[i])
+		} else {
+			c.Join(c.newName())
+		}
+	case Leave:
+		c.Leave(live[c.s.Choose(len(live))], ch.Grace)
+	case Fail:
+		c.Fail(live[c.s.Choose(len(live))])
+	case Flap:
+		var d time.Duration
+		if ch.Down != nil {
+			d = ch.Down.Draw(c.s)
+		}
+		c.Flap(live[c.s.Choose(len(live))], d)
+	}
+}
+
+// newName returns an unused name for a member Churn adds.
+func (c *Cluster) newName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.joined++
+		name := fmt.Sprintf("member-%d", c.joined)
+		if c.members[name] == nil {
+			return name
+		}
+	}
+}
+
+// split returns the names of the live members and of those that left or
+// failed, in the order they first joined.
+func (c *Cluster) split() (live, departed []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range c.names {
+		if c.members[name].live {
+			live = append(live, name)
+		} else {
+			departed = append(departed, name)
+		}
+	}
+	return live, departed
+}
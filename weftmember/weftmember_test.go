@@ -0,0 +1,102 @@
+package weftmember
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/latency"
+	"github.com/mziter/weft/weftnet"
+)
+
+// idle returns a cluster of members that do nothing.
+func idle(s *weft.Scheduler, names ...string) *Cluster {
+	return New(s, weftnet.New(s), names, func(*Cluster, *weft.Node) {})
+}
+
+// TestChanges verifies that members join, leave, fail and rejoin, and that
+// the cluster records each change.
+func TestChanges(t *testing.T) {
+	s := weft.NewScheduler(1)
+	c := idle(s, "a", "b", "c")
+	leaving := c.Leaving("b")
+	c.Leave("b", time.Millisecond)
+	if _, ok := leaving.TryRecv(); ok {
+		t.Error("Leaving channel not closed")
+	}
+	c.Fail("c")
+	c.Fail("c")
+	if got := c.Members(); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("Members() = %v, want [a]", got)
+	}
+	if !c.Node("c").Crashed() {
+		t.Error("failed member still running")
+	}
+	c.Join("c")
+	if !c.Live("c") || c.Node("c").Incarnation() != 2 {
+		t.Errorf("rejoined member: Live = %v, Incarnation = %d; want true, 2", c.Live("c"), c.Node("c").Incarnation())
+	}
+	var history []string
+	for _, ch := range c.History() {
+		history = append(history, ch.String())
+	}
+	want := []string{"join a", "join b", "join c", "leave b", "fail c", "join c"}
+	if !slices.Equal(history, want) {
+		t.Errorf("History() = %v, want %v", history, want)
+	}
+	c.Fail("a")
+	c.Fail("c")
+	s.Wait()
+}
+
+// TestCheckViews verifies that views differing from the live members are
+// reported.
+func TestCheckViews(t *testing.T) {
+	c := idle(weft.NewScheduler(1), "a", "b", "c")
+	c.Fail("c")
+	views := map[string][]string{"a": {"b", "a"}, "b": {"a", "b", "c"}}
+	err := c.CheckViews(func(m string) []string { return views[m] })
+	if err == nil || !strings.Contains(err.Error(), "b sees members [a b c]") {
+		t.Errorf("CheckViews() = %v, want b's view reported", err)
+	}
+	views["b"] = []string{"a", "b"}
+	if err := c.CheckViews(func(m string) []string { return views[m] }); err != nil {
+		t.Errorf("CheckViews() = %v, want nil", err)
+	}
+}
+
+// TestChurn verifies that churn makes the changes asked for, within the
+// bounds on live members.
+func TestChurn(t *testing.T) {
+	s := weft.NewScheduler(1)
+	c := idle(s, "a", "b", "c")
+	done := c.Churn(Churn{
+		Changes:  20,
+		Interval: latency.Constant(time.Millisecond),
+		Kinds:    []Kind{Join, Leave, Fail},
+		Min:      2,
+		Max:      4,
+	})
+	done.Recv()
+	history := c.History()
+	if len(history) != 23 {
+		t.Fatalf("History() has %d changes, want 23: %v", len(history), history)
+	}
+	live := 0
+	for _, ch := range history {
+		if ch.Kind == Join {
+			live++
+		} else {
+			live--
+		}
+		if live < 2 && ch.Kind != Join || live > 4 {
+			t.Fatalf("%d live members after %v: %v", live, ch, history)
+		}
+	}
+	for _, m := range c.Members() {
+		c.Fail(m)
+	}
+	s.Wait()
+}
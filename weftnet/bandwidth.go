@@ -0,0 +1,97 @@
+package weftnet
+
+import (
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// SetBandwidth caps the messages sent from host from to host to at
+// bytesPerSecond of virtual time; zero removes the cap. Messages on a
+// capped link are transmitted one at a time, in the order they were
+// written, each taking its size over the bandwidth before the link's
+// faults and latency apply. Links are directional, as with SetFaults.
+func (n *Network) SetBandwidth(from, to string, bytesPerSecond int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.bandwidth[link{from, to}] = bytesPerSecond
+}
+
+// SetSendBuffer bounds the data a connection holds for its peer, waiting to
+// be transmitted over a capped link or arrived and not yet read, at size
+// bytes; zero, the default, leaves it unbounded. Write waits while the
+// buffer has no room, so a reader that falls behind holds up its writer,
+// and two peers that only write to each other deadlock, as they would
+// with TCP. A write larger than the buffer waits until it is empty.
+func (n *Network) SetSendBuffer(size int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sendBuffer = size
+}
+
+// transmitter sends the messages queued on a capped link one at a time.
+// Its fields are guarded by net.mu.
+type transmitter struct {
+	queue   []transmission
+	running bool
+}
+
+// transmission is a message waiting to be sent over a link: its size and
+// how to deliver it.
+type transmission struct {
+	size    int
+	deliver func()
+}
+
+// transmit calls deliver, for a message of size bytes sent from host from
+// to host to, at once if the link is not capped and otherwise once the
+// messages queued before it and the message itself have been transmitted.
+func (n *Network) transmit(from, to string, size int, deliver func()) {
+	l := link{from, to}
+	n.mu.Lock()
+	t := n.transmitters[l]
+	if n.bandwidth[l] <= 0 && (t == nil || !t.running) {
+		n.mu.Unlock()
+		deliver()
+		return
+	}
+	if t == nil {
+		t = &transmitter{}
+		n.transmitters[l] = t
+	}
+	t.queue = append(t.queue, transmission{size, deliver})
+	start := !t.running
+	t.running = true
+	n.mu.Unlock()
+	if start {
+		n.s.Go(func(weft.Context) { n.runTransmitter(l, t) })
+	}
+}
+
+// runTransmitter transmits the messages queued on l until there are none.
+func (n *Network) runTransmitter(l link, t *transmitter) {
+	for {
+		n.mu.Lock()
+		if len(t.queue) == 0 {
+			t.running = false
+			n.mu.Unlock()
+			return
+		}
+		m := t.queue[0]
+		t.queue = t.queue[1:]
+		bw := n.bandwidth[l]
+		n.mu.Unlock()
+		if bw > 0 {
+			n.s.Sleep(time.Duration(m.size) * time.Second / time.Duration(bw))
+		}
+		m.deliver()
+	}
+}
+
+// bufferSize returns the size of connections' send buffers, or zero if
+// they are unbounded.
+func (n *Network) bufferSize() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.sendBuffer
+}
@@ -0,0 +1,277 @@
+package weftnet
+
+import (
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// Conn is one end of a connection on a simulated network. It implements
+// net.Conn.
+//
+// Each Write is delivered as a unit and faults apply to whole writes, so a
+// protocol that writes one message per call sees messages lost, repeated or
+// reordered, as it would across retries and reconnects on a real network.
+type Conn struct {
+	net           *Network
+	local, remote Addr
+
+	// in is read by this end and out by the peer.
+	in, out *pipe
+
+	mu            weft.Mutex
+	closed        bool
+	writeDeadline time.Time
+
+	// held is set once the connection holds a handle of its local host:
+	// when dialed, or when accepted.
+	held bool
+}
+
+// newConnPair returns the two ends of a connection between local and
+// remote.
+func newConnPair(n *Network, local, remote Addr) (*Conn, *Conn) {
+	a, b := newPipe(), newPipe()
+	return &Conn{net: n, local: local, remote: remote, in: a, out: b},
+		&Conn{net: n, local: remote, remote: local, in: b, out: a}
+}
+
+// Read reads data written by the peer, waiting until some arrives, the peer
+// closes the connection or the read deadline passes.
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.in.read(c.net.s, b)
+	if err != nil && err != io.EOF {
+		err = &net.OpError{Op: "read", Net: "weftnet", Source: c.local, Addr: c.remote, Err: err}
+	}
+	return n, err
+}
+
+// Write sends b to the peer, subject to the bandwidth and faults of the
+// link. It waits while the connection's send buffer is full, but not for
+// delivery.
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.waitRoom(len(b)); err != nil {
+		return 0, &net.OpError{Op: "write", Net: "weftnet", Source: c.local, Addr: c.remote, Err: err}
+	}
+	msg, p := append([]byte(nil), b...), c.out
+	p.mu.Lock()
+	p.queued += len(msg)
+	p.mu.Unlock()
+	c.net.transmit(c.local.Host, c.remote.Host, len(msg), func() {
+		p.mu.Lock()
+		p.queued -= len(msg)
+		p.wakeWriter()
+		p.mu.Unlock()
+		c.net.send(p, c.local.Host, c.remote.Host, msg)
+	})
+	return len(b), nil
+}
+
+// waitRoom waits until the send buffer has room for size bytes, the
+// connection is closed or the write deadline passes.
+func (c *Conn) waitRoom(size int) error {
+	p := c.out
+	for {
+		c.mu.Lock()
+		closed, deadline := c.closed, c.writeDeadline
+		c.mu.Unlock()
+		switch {
+		case closed:
+			return net.ErrClosed
+		case !deadline.IsZero() && !c.net.s.Now().Before(deadline):
+			return os.ErrDeadlineExceeded
+		}
+		limit := c.net.bufferSize()
+		p.mu.Lock()
+		used := len(p.buf) + p.queued
+		room := limit == 0 || p.readClosed || used == 0 || used+size <= limit
+		p.mu.Unlock()
+		if room {
+			return nil
+		}
+
+		if deadline.IsZero() {
+			p.room.Recv()
+			continue
+		}
+		weft.Select(weft.OnRecv(p.room), weft.OnRecv(c.net.s.After(deadline.Sub(c.net.s.Now()))))
+	}
+}
+
+// Close closes the connection. The peer reads the data already sent and
+// then io.EOF.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return &net.OpError{Op: "close", Net: "weftnet", Source: c.local, Addr: c.remote, Err: net.ErrClosed}
+	}
+	c.closed = true
+	c.out.closeWrite()
+	c.in.closeRead()
+	if c.held {
+		c.net.mu.Lock()
+		c.net.hosts[c.local.Host].release()
+		c.net.mu.Unlock()
+	}
+	return nil
+}
+
+// LocalAddr returns the address of this end of the connection.
+func (c *Conn) LocalAddr() net.Addr { return c.local }
+
+// RemoteAddr returns the address of the peer.
+func (c *Conn) RemoteAddr() net.Addr { return c.remote }
+
+// SetDeadline sets the read and write deadlines. Deadlines are times on
+// the network's scheduler clock, such as its Now plus a timeout.
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the time after which Read fails with an error
+// wrapping os.ErrDeadlineExceeded. The zero time means no deadline.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.in.mu.Lock()
+	defer c.in.mu.Unlock()
+	c.in.deadline = t
+	c.in.wake()
+	return nil
+}
+
+// SetWriteDeadline sets the time after which Write fails, including while
+// it waits for room in the send buffer.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	c.out.mu.Lock()
+	c.out.wakeWriter()
+	c.out.mu.Unlock()
+	return nil
+}
+
+// pipe carries the data of one direction of a connection.
+type pipe struct {
+	mu  weft.Mutex
+	buf []byte
+
+	// held is a message kept back to be delivered after the next one.
+	held []byte
+
+	// inflight counts delayed messages not yet delivered; the reader sees
+	// io.EOF only once they have arrived.
+	inflight int
+
+	// ordered counts the messages in flight that arrive in order, and last
+	// is closed when the last of them has arrived.
+	ordered int
+	last    weft.Chan[struct{}]
+
+	writeClosed, readClosed bool
+	deadline                time.Time
+
+	// queued counts the bytes written and not yet transmitted, which with
+	// buf fill the send buffer.
+	queued int
+
+	// ready wakes the reader when any of the above changes, and room the
+	// writer when the send buffer may have room.
+	ready, room weft.Chan[struct{}]
+
+	// hungUp is closed when the writer closes.
+	hungUp weft.Chan[struct{}]
+}
+
+func newPipe() *pipe {
+	return &pipe{ready: weft.MakeChan[struct{}](1), room: weft.MakeChan[struct{}](1), hungUp: weft.MakeChan[struct{}](0)}
+}
+
+// wake wakes the reader, if it is waiting.
+func (p *pipe) wake() {
+	p.ready.TrySend(struct{}{})
+}
+
+// wakeWriter wakes the writer, if it is waiting for room.
+func (p *pipe) wakeWriter() {
+	p.room.TrySend(struct{}{})
+}
+
+// push appends msgs to the data to be read. A message arriving after the
+// reader closed is discarded.
+func (p *pipe) push(msgs ...[]byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.readClosed {
+		return
+	}
+	for _, msg := range msgs {
+		p.buf = append(p.buf, msg...)
+	}
+	p.wake()
+}
+
+func (p *pipe) read(s *weft.Scheduler, b []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		switch {
+		case p.readClosed:
+			p.mu.Unlock()
+			return 0, net.ErrClosed
+		case len(p.buf) > 0:
+			n := copy(b, p.buf)
+			p.buf = p.buf[n:]
+			if len(p.buf) > 0 {
+				// Pass the wakeup on to any other reader.
+				p.wake()
+			}
+			p.wakeWriter()
+			p.mu.Unlock()
+			return n, nil
+		case p.writeClosed && p.inflight == 0 && p.held == nil:
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		deadline := p.deadline
+		p.mu.Unlock()
+
+		if deadline.IsZero() {
+			p.ready.Recv()
+			continue
+		}
+		d := deadline.Sub(s.Now())
+		if d <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		weft.Select(weft.OnRecv(p.ready), weft.OnRecv(s.After(d)))
+	}
+}
+
+// closeWrite marks the end of the data, once the held and delayed messages
+// are delivered.
+func (p *pipe) closeWrite() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.writeClosed = true
+	p.hungUp.Close()
+	p.wakeWriter()
+	if p.held != nil {
+		p.buf = append(p.buf, p.held...)
+		p.held = nil
+	}
+	p.wake()
+}
+
+// closeRead discards the data and fails further reads.
+func (p *pipe) closeRead() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readClosed = true
+	p.buf = nil
+	p.wake()
+	p.wakeWriter()
+}
@@ -0,0 +1,118 @@
+package weftnet
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+)
+
+// A Record is what a name resolves to on a simulated network, and how its
+// lookups misbehave. Names without a Record resolve to the host of the same
+// name, at once and without fail.
+type Record struct {
+	// Hosts are the hosts the name resolves to, in order of preference.
+	// With none, the name does not exist.
+	Hosts []string
+
+	// Rotate makes each lookup return Hosts rotated by as many places as
+	// the schedule decides, as round-robin DNS does.
+	Rotate bool
+
+	// Fail is the probability that a lookup fails with a temporary error,
+	// as when the resolver is unreachable, and NotFound the probability
+	// that it fails as though the name did not exist, as when a record is
+	// lagging or flapping.
+	Fail     float64
+	NotFound float64
+
+	// MaxDelay is the longest a lookup takes, in virtual time. Each takes
+	// as long as the schedule decides, up to MaxDelay.
+	MaxDelay time.Duration
+}
+
+// SetRecord makes name resolve as r says, from the next lookup on. Calling
+// it again, from a task, as virtual time passes simulates records changing
+// under clients that have resolved them.
+func (n *Network) SetRecord(name string, r Record) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	r.Hosts = append([]string(nil), r.Hosts...)
+	n.records[name] = r
+}
+
+// RemoveRecord makes name resolve to the host of the same name again.
+func (n *Network) RemoveRecord(name string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.records, name)
+}
+
+// LookupHost returns the hosts name resolves to, like
+// net.Resolver.LookupHost, or a *net.DNSError. A name without a Record
+// resolves to itself if it is a host on the network.
+func (h *Host) LookupHost(ctx context.Context, name string) ([]string, error) {
+	hosts, err := h.net.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if hosts == nil {
+		h.net.mu.Lock()
+		_, ok := h.net.hosts[name]
+		h.net.mu.Unlock()
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		hosts = []string{name}
+	}
+	return hosts, nil
+}
+
+// resolve looks name up as its Record says, returning nil if it has none.
+// The lookup waits for its delay unless ctx is done first.
+func (n *Network) resolve(ctx context.Context, name string) ([]string, error) {
+	n.mu.Lock()
+	r, ok := n.records[name]
+	n.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	if r.MaxDelay > 0 {
+		if d := r.MaxDelay * time.Duration(n.s.Choose(delaySteps+1)) / delaySteps; d > 0 {
+			if err := n.wait(ctx, d); err != nil {
+				return nil, &net.DNSError{Err: err.Error(), Name: name, IsTimeout: err == context.DeadlineExceeded}
+			}
+		}
+	}
+	switch {
+	case n.s.Chance(r.Fail):
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	case len(r.Hosts) == 0 || n.s.Chance(r.NotFound):
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	hosts := r.Hosts
+	if r.Rotate && len(hosts) > 1 {
+		i := n.s.Choose(len(hosts))
+		hosts = append(append([]string(nil), hosts[i:]...), hosts[:i]...)
+	}
+	return hosts, nil
+}
+
+// wait waits for d of virtual time to pass, or for ctx to be done, when it
+// returns ctx.Err().
+func (n *Network) wait(ctx context.Context, d time.Duration) error {
+	if ctx.Done() == nil {
+		n.s.Sleep(d)
+		return nil
+	}
+	done := weft.MakeChan[struct{}](0)
+	stop := weftcontext.AfterFunc(ctx, done.Close)
+	defer stop()
+	if weft.Select(weft.OnRecv(n.s.After(d)), weft.OnRecv(done)) == 1 {
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package weftnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/mziter/weft"
+)
+
+// dnsError returns the *net.DNSError in err, or nil.
+func dnsError(err error) *net.DNSError {
+	var de *net.DNSError
+	errors.As(err, &de)
+	return de
+}
+
+// TestLookupHost verifies that names resolve as their Records say, and
+// otherwise to the host of the same name.
+func TestLookupHost(t *testing.T) {
+	n := New(weft.NewScheduler(1))
+	h := n.Host("client")
+	ctx := context.Background()
+	n.SetRecord("db", Record{Hosts: []string{"db-1", "db-2"}})
+	if got, err := h.LookupHost(ctx, "db"); err != nil || !slices.Equal(got, []string{"db-1", "db-2"}) {
+		t.Errorf("LookupHost(db) = %v, %v", got, err)
+	}
+	if got, err := h.LookupHost(ctx, "client"); err != nil || !slices.Equal(got, []string{"client"}) {
+		t.Errorf("LookupHost(client) = %v, %v", got, err)
+	}
+	if _, err := h.LookupHost(ctx, "nowhere"); dnsError(err) == nil || !dnsError(err).IsNotFound {
+		t.Errorf("LookupHost(nowhere) error = %v, want not found", err)
+	}
+
+	n.SetRecord("db", Record{Hosts: []string{"db-1"}, Fail: 1})
+	if _, err := h.LookupHost(ctx, "db"); dnsError(err) == nil || !dnsError(err).IsTemporary {
+		t.Errorf("LookupHost(db) with Fail = %v, want temporary error", err)
+	}
+	n.SetRecord("db", Record{Hosts: []string{"db-1"}, NotFound: 1})
+	if _, err := h.LookupHost(ctx, "db"); dnsError(err) == nil || !dnsError(err).IsNotFound {
+		t.Errorf("LookupHost(db) with NotFound = %v, want not found", err)
+	}
+	n.RemoveRecord("db")
+	if _, err := h.LookupHost(ctx, "db"); dnsError(err) == nil || !dnsError(err).IsNotFound {
+		t.Errorf("LookupHost(db) after RemoveRecord = %v, want not found", err)
+	}
+}
+
+// TestDialName verifies that Dial resolves names and tries their hosts in
+// turn.
+func TestDialName(t *testing.T) {
+	n := New(weft.NewScheduler(1))
+	l, err := n.Host("db-2").Listen(":5432")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	n.SetRecord("db", Record{Hosts: []string{"db-1", "db-2"}})
+	c, err := n.Host("client").Dial("db:5432")
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	defer c.Close()
+	if got := c.RemoteAddr().String(); got != "db-2:5432" {
+		t.Errorf("RemoteAddr() = %s, want db-2:5432", got)
+	}
+
+	n.SetRecord("db", Record{Hosts: []string{"db-1"}, Fail: 1})
+	if _, err := n.Host("client").Dial("db:5432"); dnsError(err) == nil {
+		t.Errorf("Dial() with a failing lookup = %v, want a DNS error", err)
+	}
+}
@@ -0,0 +1,143 @@
+package weftnet
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/latency"
+)
+
+// Faults are the probabilities, from 0 to 1, of faults befalling each
+// message sent over a link. The zero Faults is a reliable link.
+type Faults struct {
+	// Drop is the probability that a message is lost.
+	Drop float64
+
+	// Duplicate is the probability that a message is delivered twice.
+	Duplicate float64
+
+	// Reorder is the probability that a message is held back and
+	// delivered after the next message on its connection.
+	Reorder float64
+
+	// Delay is the probability that a message is delayed, by up to
+	// MaxDelay of virtual time. Messages sent after it are not held up.
+	Delay    float64
+	MaxDelay time.Duration
+
+	// Latency, if set, draws the time every message takes to arrive. The
+	// messages of a connection still arrive in order, each waiting for
+	// those sent before it, while datagrams overtake each other.
+	Latency latency.Distribution
+}
+
+// link is a direction between two hosts.
+type link struct {
+	from, to string
+}
+
+// SetFaults sets the faults of messages sent from host from to host to.
+// Links are directional: call it twice to affect both directions.
+func (n *Network) SetFaults(from, to string, f Faults) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.faults[link{from, to}] = f
+}
+
+// SetDefaultFaults sets the faults of every link not given its own with
+// SetFaults.
+func (n *Network) SetDefaultFaults(f Faults) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.defaults = f
+}
+
+// linkFaults returns the faults of messages sent from host from to host to,
+// and whether a partition separates them.
+func (n *Network) linkFaults(from, to string) (f Faults, reachable bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, ok := n.faults[link{from, to}]
+	if !ok {
+		f = n.defaults
+	}
+	return f, n.reachable(from, to)
+}
+
+// isReachable reports whether messages from host from reach host to.
+func (n *Network) isReachable(from, to string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.reachable(from, to)
+}
+
+// delaySteps is the number of delays, evenly spaced up to MaxDelay, that a
+// delayed message chooses among.
+const delaySteps = 16
+
+// send delivers msg, sent from host from to host to, to p, subject to the
+// faults of the link and to partitions, both when it is sent and, if it is
+// delayed, when it arrives.
+func (n *Network) send(p *pipe, from, to string, msg []byte) {
+	f, reachable := n.linkFaults(from, to)
+	if !reachable || n.s.Chance(f.Drop) {
+		return
+	}
+	msgs := [][]byte{msg}
+	if n.s.Chance(f.Duplicate) {
+		msgs = append(msgs, msg)
+	}
+
+	p.mu.Lock()
+	if p.held == nil && n.s.Chance(f.Reorder) {
+		p.held = bytes.Join(msgs, nil)
+		p.mu.Unlock()
+		return
+	}
+	if p.held != nil {
+		msgs = append(msgs, p.held)
+		p.held = nil
+	}
+	var d time.Duration
+	if f.Latency != nil {
+		d = f.Latency.Draw(n.s)
+	}
+	delayed := f.MaxDelay > 0 && n.s.Chance(f.Delay)
+	if !delayed && d <= 0 && p.ordered == 0 {
+		p.mu.Unlock()
+		p.push(msgs...)
+		return
+	}
+	p.inflight++
+	// A delayed message is not held up by those before it; one that only
+	// takes its latency is.
+	var prev, arrived weft.Chan[struct{}]
+	waitPrev := false
+	if delayed {
+		d += f.MaxDelay * time.Duration(n.s.Choose(delaySteps)+1) / delaySteps
+	} else {
+		prev, waitPrev = p.last, p.ordered > 0
+		arrived = weft.MakeChan[struct{}](0)
+		p.last = arrived
+		p.ordered++
+	}
+	p.mu.Unlock()
+	n.s.Go(func(weft.Context) {
+		n.s.Sleep(d)
+		if waitPrev {
+			prev.Recv()
+		}
+		if n.isReachable(from, to) {
+			p.push(msgs...)
+		}
+		p.mu.Lock()
+		if !delayed {
+			arrived.Close()
+			p.ordered--
+		}
+		p.inflight--
+		p.wake()
+		p.mu.Unlock()
+	})
+}
@@ -0,0 +1,77 @@
+package weftnet
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// The pieces below bring gRPC services onto a simulated network without
+// weftnet depending on gRPC. A Listener is a net.Listener for the server,
+// DialContext is a dialer for the client, and WithTimeout gives calls
+// deadlines on virtual time:
+//
+//	go srv.Serve(l)
+//	conn, err := grpc.NewClient("passthrough:///server:50051",
+//		grpc.WithContextDialer(n.Host("client").DialContext),
+//		grpc.WithTransportCredentials(insecure.NewCredentials()),
+//		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any,
+//			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+//			ctx, cancel := n.WithTimeout(ctx, time.Second)
+//			defer cancel()
+//			return invoker(ctx, method, req, reply, cc, opts...)
+//		}))
+//
+// gRPC starts goroutines of its own, which the scheduler cannot see, so
+// exploration covers the interleavings of the service's own tasks with the
+// network's deliveries and faults rather than gRPC's internals.
+
+// DialContext connects to the listener at addr, as host:port, unless ctx is
+// done first, when it may be waiting for the host's name to resolve. Its signature suits grpc.WithContextDialer and
+// http.Transport.DialContext.
+func (h *Host) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &net.OpError{Op: "dial", Net: "weftnet", Err: err}
+	}
+	return h.dial(ctx, addr)
+}
+
+// WithTimeout returns a copy of ctx that is canceled once d of virtual time
+// has passed, when its Err is context.DeadlineExceeded, or when cancel is
+// called. Unlike context.WithTimeout, whose timer runs on the wall clock,
+// the timeout is a point in the schedule the scheduler explores.
+func (n *Network) WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	cctx, cancel := context.WithCancelCause(ctx)
+	tc := &timeoutContext{Context: cctx}
+	stop := afterFunc(n.s, d, func() {
+		tc.mu.Lock()
+		tc.expired = true
+		tc.mu.Unlock()
+		cancel(context.DeadlineExceeded)
+	})
+	return tc, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// timeoutContext is a context canceled by a virtual-time timeout.
+type timeoutContext struct {
+	context.Context
+
+	mu      weft.Mutex
+	expired bool
+}
+
+// Err returns context.DeadlineExceeded once the timeout has expired.
+func (c *timeoutContext) Err() error {
+	c.mu.Lock()
+	expired := c.expired
+	c.mu.Unlock()
+	if expired {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
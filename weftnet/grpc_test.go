@@ -0,0 +1,58 @@
+package weftnet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+)
+
+// TestDialContext verifies that DialContext connects unless its context is
+// already done.
+func TestDialContext(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	l, err := n.Host("server").Listen(":50051")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	h := n.Host("client")
+
+	c, err := h.DialContext(context.Background(), "server:50051")
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.DialContext(ctx, "server:50051"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DialContext() with canceled context error = %v, want context.Canceled", err)
+	}
+}
+
+// TestWithTimeout verifies that a timeout context expires with
+// context.DeadlineExceeded and that canceling it first ends it with
+// context.Canceled.
+func TestWithTimeout(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+
+	ctx, cancel := n.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	weftcontext.Done(ctx).Recv()
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Errorf("Err() after timeout = %v, want context.DeadlineExceeded", err)
+	}
+
+	ctx, cancel = n.WithTimeout(context.Background(), time.Hour)
+	cancel()
+	weftcontext.Done(ctx).Recv()
+	if err := ctx.Err(); err != context.Canceled {
+		t.Errorf("Err() after cancel = %v, want context.Canceled", err)
+	}
+}
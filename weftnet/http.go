@@ -0,0 +1,368 @@
+package weftnet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+)
+
+// HTTPServer serves HTTP/1.1 over a simulated network. Unlike http.Server,
+// which starts goroutines the scheduler cannot see, it handles each
+// connection on a weft task, and its timeouts run on virtual time, so the
+// scheduler explores handlers racing with each other, with cancellation and
+// with idle connections being closed.
+type HTTPServer struct {
+	Handler http.Handler
+
+	// IdleTimeout closes a keep-alive connection that has waited this long
+	// for its next request. Zero means connections are never closed for
+	// being idle.
+	IdleTimeout time.Duration
+}
+
+// Serve accepts connections on l and serves them until l is closed, when it
+// returns an error wrapping net.ErrClosed. Each request's context is
+// canceled when the client closes the connection or the handler returns.
+func (srv *HTTPServer) Serve(l *Listener) error {
+	for {
+		c, ok := l.conns.Recv()
+		if !ok {
+			return &net.OpError{Op: "accept", Net: "weftnet", Addr: l.addr, Err: net.ErrClosed}
+		}
+		l.net.s.Go(func(weft.Context) {
+			srv.serveConn(l.net.s, c)
+		})
+	}
+}
+
+// serveConn serves the requests on c until the client closes it or asks to.
+func (srv *HTTPServer) serveConn(s *weft.Scheduler, c *Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	for {
+		if srv.IdleTimeout > 0 {
+			stop := afterFunc(s, srv.IdleTimeout, func() { c.Close() })
+			_, err := br.Peek(1)
+			stop()
+			if err != nil {
+				return
+			}
+		}
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			if err != io.EOF {
+				io.WriteString(c, "HTTP/1.1 400 Bad Request\r\nConnection: close\r\n\r\n")
+			}
+			return
+		}
+		req.RemoteAddr = c.RemoteAddr().String()
+		ctx, cancel := context.WithCancel(context.Background())
+		req = req.WithContext(ctx)
+
+		// Cancel the request if the client hangs up while it is handled.
+		handled := weft.MakeChan[struct{}](0)
+		s.Go(func(weft.Context) {
+			if weft.Select(weft.OnRecv(handled), weft.OnRecv(c.in.hungUp)) == 1 {
+				cancel()
+			}
+		})
+		w := &responseWriter{header: make(http.Header)}
+		srv.Handler.ServeHTTP(w, req)
+		handled.Close()
+		cancel()
+		io.Copy(io.Discard, req.Body)
+
+		resp := w.response(req)
+		if err := resp.Write(c); err != nil || resp.Close {
+			return
+		}
+	}
+}
+
+// responseWriter collects a handler's response to write it in one piece.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// response returns the collected response to req.
+func (w *responseWriter) response(req *http.Request) *http.Response {
+	w.WriteHeader(http.StatusOK)
+	if w.header.Get("Content-Type") == "" && w.body.Len() > 0 {
+		w.header.Set("Content-Type", http.DetectContentType(w.body.Bytes()))
+	}
+	return &http.Response{
+		StatusCode:    w.status,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          io.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Close:         req.Close || w.header.Get("Connection") == "close",
+		Request:       req,
+	}
+}
+
+// HTTPTransport is an http.RoundTripper that makes requests from a host of
+// a simulated network. It makes each request on the calling task, keeps
+// connections alive for reuse, and times requests out on virtual time.
+//
+// Use it in place of http.Client.Timeout, whose timer runs on the wall
+// clock:
+//
+//	client := &http.Client{Transport: &weftnet.HTTPTransport{Host: h, Timeout: time.Second}}
+type HTTPTransport struct {
+	Host *Host
+
+	// Timeout limits the time from sending a request to reading the end
+	// of its response body. Zero means no limit.
+	Timeout time.Duration
+
+	// DisableKeepAlives closes every connection after one request.
+	DisableKeepAlives bool
+
+	mu   weft.Mutex
+	idle map[string][]*clientConn
+}
+
+// clientConn is a connection kept alive by an HTTPTransport.
+type clientConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// HTTPClient returns a client making requests from h.
+func (h *Host) HTTPClient() *http.Client {
+	return &http.Client{Transport: &HTTPTransport{Host: h}}
+}
+
+// errTimeout is returned, wrapped, by requests that time out.
+var errTimeout error = &timeoutError{}
+
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "weftnet: request timed out" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
+
+// RoundTrip implements http.RoundTripper.
+func (t *HTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), "80")
+	}
+	for {
+		cc, reused, err := t.conn(addr)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.roundTrip(req, addr, cc)
+		if err != nil && reused && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) && replayable(req) {
+			// The server closed the idle connection as it was reused;
+			// retry on a new one, as http.Transport does.
+			continue
+		}
+		return resp, err
+	}
+}
+
+// replayable reports whether req can be sent again after a failure: it has
+// no body and is idempotent.
+func replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// conn returns an idle connection to addr, or a new one.
+func (t *HTTPTransport) conn(addr string) (cc *clientConn, reused bool, err error) {
+	t.mu.Lock()
+	if conns := t.idle[addr]; len(conns) > 0 {
+		cc = conns[len(conns)-1]
+		t.idle[addr] = conns[:len(conns)-1]
+		t.mu.Unlock()
+		return cc, true, nil
+	}
+	t.mu.Unlock()
+	c, err := t.Host.Dial(addr)
+	if err != nil {
+		return nil, false, err
+	}
+	return &clientConn{conn: c, br: bufio.NewReader(c)}, false, nil
+}
+
+// putIdle keeps cc for reuse by requests to addr.
+func (t *HTTPTransport) putIdle(addr string, cc *clientConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.idle == nil {
+		t.idle = make(map[string][]*clientConn)
+	}
+	t.idle[addr] = append(t.idle[addr], cc)
+}
+
+// CloseIdleConnections closes the connections kept for reuse.
+func (t *HTTPTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	idle := t.idle
+	t.idle = nil
+	t.mu.Unlock()
+	for _, conns := range idle {
+		for _, cc := range conns {
+			cc.conn.Close()
+		}
+	}
+}
+
+// roundTrip makes req on cc, a connection to addr. The connection is closed if the request's
+// context is canceled or the timeout passes before the response body has
+// been read.
+func (t *HTTPTransport) roundTrip(req *http.Request, addr string, cc *clientConn) (*http.Response, error) {
+	s := t.Host.net.s
+	var (
+		mu                       weft.Mutex
+		done, timedOut, canceled bool
+	)
+	abort := func(flag *bool) func() {
+		return func() {
+			mu.Lock()
+			if done {
+				mu.Unlock()
+				return
+			}
+			*flag = true
+			mu.Unlock()
+			cc.conn.Close()
+		}
+	}
+	stopTimer := func() {}
+	if t.Timeout > 0 {
+		stopTimer = afterFunc(s, t.Timeout, abort(&timedOut))
+	}
+	stopCtx := weftcontext.AfterFunc(req.Context(), abort(&canceled))
+	// stop ends the watch for timeouts and cancellation and reports
+	// whether the request completed first.
+	stop := func() bool {
+		mu.Lock()
+		done = true
+		ok := !timedOut && !canceled
+		mu.Unlock()
+		stopTimer()
+		stopCtx()
+		return ok
+	}
+	fail := func(err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case timedOut:
+			return errTimeout
+		case canceled:
+			return context.Cause(req.Context())
+		}
+		return err
+	}
+
+	if err := req.Write(cc.conn); err != nil {
+		stop()
+		cc.conn.Close()
+		return nil, fail(err)
+	}
+	resp, err := http.ReadResponse(cc.br, req)
+	if err != nil {
+		stop()
+		cc.conn.Close()
+		return nil, fail(err)
+	}
+	resp.Body = &bodyReader{body: resp.Body, done: func(eof bool) {
+		if stop() && eof && !resp.Close && !t.DisableKeepAlives {
+			t.putIdle(addr, cc)
+			return
+		}
+		cc.conn.Close()
+	}, fail: fail}
+	return resp, nil
+}
+
+// bodyReader calls done once, when the body has been read to the end or
+// closed, and maps read errors with fail.
+type bodyReader struct {
+	body     io.ReadCloser
+	done     func(eof bool)
+	fail     func(error) error
+	finished bool
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	switch {
+	case err == io.EOF:
+		b.finish(true)
+	case err != nil:
+		b.finish(false)
+		err = b.fail(err)
+	}
+	return n, err
+}
+
+func (b *bodyReader) Close() error {
+	err := b.body.Close()
+	b.finish(false)
+	return err
+}
+
+func (b *bodyReader) finish(eof bool) {
+	if !b.finished {
+		b.finished = true
+		b.done(eof)
+	}
+}
+
+// afterFunc calls f on a new task once d of virtual time has passed, unless
+// the returned stop function is called first.
+func afterFunc(s *weft.Scheduler, d time.Duration, f func()) (stop func()) {
+	stopped := weft.MakeChan[struct{}](0)
+	s.Go(func(weft.Context) {
+		// Both cases may be ready by the time the task runs; stopping
+		// wins.
+		if weft.Select(weft.OnRecv(stopped), weft.OnRecv(s.After(d))) == 1 && weft.TrySelect(weft.OnRecv(stopped)) < 0 {
+			f()
+		}
+	})
+	var once bool
+	return func() {
+		if !once {
+			once = true
+			stopped.Close()
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package weftnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+)
+
+// serveHTTP serves handler on server:80 of n until the test ends.
+func serveHTTP(t *testing.T, s *weft.Scheduler, n *Network, srv *HTTPServer) {
+	t.Helper()
+	l, err := n.Host("server").Listen(":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Go(func(weft.Context) { srv.Serve(l) })
+	t.Cleanup(func() { l.Close() })
+}
+
+// get returns the body of a GET of url, or the error.
+func get(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+// TestHTTP verifies that requests are served over a kept-alive connection.
+func TestHTTP(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	var remotes []string
+	serveHTTP(t, s, n, &HTTPServer{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remotes = append(remotes, r.RemoteAddr)
+		io.WriteString(w, "hello "+r.URL.Path)
+	})})
+
+	client := n.Host("client").HTTPClient()
+	defer client.CloseIdleConnections()
+	for _, path := range []string{"/a", "/b"} {
+		body, err := get(client, "http://server"+path)
+		if err != nil || body != "hello "+path {
+			t.Errorf("GET %s = %q, %v; want %q", path, body, err, "hello "+path)
+		}
+	}
+	if len(remotes) != 2 || remotes[0] != remotes[1] {
+		t.Errorf("requests came from %v, want one kept-alive connection", remotes)
+	}
+}
+
+// TestHTTPCancel verifies that canceling a request's context fails it on
+// the client and cancels it in the handler.
+func TestHTTPCancel(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	started, stopped := weft.MakeChan[struct{}](1), weft.MakeChan[error](1)
+	serveHTTP(t, s, n, &HTTPServer{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Send(struct{}{})
+		weftcontext.Done(r.Context()).Recv()
+		stopped.Send(r.Context().Err())
+	})})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Go(func(weft.Context) {
+		started.Recv()
+		cancel()
+	})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://server/", nil)
+	if _, err := n.Host("client").HTTPClient().Do(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want context.Canceled", err)
+	}
+	if err, _ := stopped.Recv(); !errors.Is(err, context.Canceled) {
+		t.Errorf("handler context error = %v, want context.Canceled", err)
+	}
+}
+
+// TestHTTPTimeout verifies that a request outliving the transport's
+// timeout fails with a timeout error.
+func TestHTTPTimeout(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	serveHTTP(t, s, n, &HTTPServer{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		weftcontext.Done(r.Context()).Recv()
+	})})
+
+	client := &http.Client{Transport: &HTTPTransport{Host: n.Host("client"), Timeout: 10 * time.Millisecond}}
+	_, err := get(client, "http://server/")
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("GET error = %v, want a timeout", err)
+	}
+}
+
+// TestHTTPIdleTimeout verifies that a request on a connection the server
+// closed for being idle is retried on a new one.
+func TestHTTPIdleTimeout(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	serveHTTP(t, s, n, &HTTPServer{
+		Handler:     http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "ok") }),
+		IdleTimeout: time.Millisecond,
+	})
+
+	client := n.Host("client").HTTPClient()
+	defer client.CloseIdleConnections()
+	for i := 0; i < 2; i++ {
+		if body, err := get(client, "http://server/"); err != nil || body != "ok" {
+			t.Errorf("GET %d = %q, %v; want %q", i, body, err, "ok")
+		}
+		s.Sleep(10 * time.Millisecond)
+	}
+}
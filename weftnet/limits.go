@@ -0,0 +1,45 @@
+package weftnet
+
+import "syscall"
+
+// Limits bound the resources of a host. The zero Limits is unbounded.
+type Limits struct {
+	// Handles is the number of connections, listeners and packet
+	// connections the host may hold open at once, like the file
+	// descriptors of a process. Dialing, accepting or listening beyond
+	// it fails with syscall.EMFILE until one is closed. Zero means no
+	// limit.
+	Handles int
+
+	// Exhaustion is the probability, from 0 to 1, that opening a handle
+	// fails with syscall.EMFILE all the same, as the schedule decides, as
+	// when other processes on the machine hold the rest.
+	Exhaustion float64
+}
+
+// SetLimits sets the limits of host, for the handles it opens from now on.
+//
+//	n.SetLimits("server", weftnet.Limits{Handles: 64, Exhaustion: 0.01})
+func (n *Network) SetLimits(host string, l Limits) {
+	h := n.Host(host)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	h.limits = l
+}
+
+// acquire takes a handle of h, or returns syscall.EMFILE if h is out of
+// them. The caller must hold h.net.mu.
+func (h *Host) acquire() error {
+	l := h.limits
+	if l.Handles > 0 && h.handles >= l.Handles || h.net.s.Chance(l.Exhaustion) {
+		return syscall.EMFILE
+	}
+	h.handles++
+	return nil
+}
+
+// release returns a handle taken with acquire. The caller must hold
+// h.net.mu.
+func (h *Host) release() {
+	h.handles--
+}
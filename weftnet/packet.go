@@ -0,0 +1,286 @@
+package weftnet
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// ErrMessageTooLong is returned, wrapped, by WriteTo for a datagram larger
+// than the network's MTU.
+var ErrMessageTooLong = errors.New("message too long")
+
+// maxDatagram is the MTU of a network until SetMTU changes it: the largest
+// payload of a UDP datagram.
+const maxDatagram = 65507
+
+// packetQueue is the number of datagrams a PacketConn queues unread before
+// it drops new ones, as a full socket buffer does.
+const packetQueue = 256
+
+// SetMTU sets the size, in bytes, of the largest datagram PacketConns send.
+func (n *Network) SetMTU(mtu int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.mtu = mtu
+}
+
+// ListenPacket opens a datagram endpoint on addr, ":port" or "host:port"
+// for this host, like net.ListenPacket with "udp". Port 0 picks an unused
+// port. Datagram ports are apart from the ports Listen uses.
+func (h *Host) ListenPacket(addr string) (*PacketConn, error) {
+	a, err := parseAddr(addr, h.name)
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "weftnet", Err: err}
+	}
+	if a.Host != h.name {
+		return nil, &net.OpError{Op: "listen", Net: "weftnet", Addr: a, Err: errors.New("address not on host " + h.name)}
+	}
+	n := h.net
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if a.Port == 0 {
+		a.Port = h.packetEphemeral()
+	}
+	if _, ok := n.packetConns[a]; ok {
+		return nil, &net.OpError{Op: "listen", Net: "weftnet", Addr: a, Err: errors.New("address already in use")}
+	}
+	if err := h.acquire(); err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "weftnet", Addr: a, Err: err}
+	}
+	c := &PacketConn{net: n, addr: a, ready: weft.MakeChan[struct{}](1)}
+	n.packetConns[a] = c
+	return c, nil
+}
+
+// packetEphemeral returns an unused datagram port on h. The caller must
+// hold h.net.mu.
+func (h *Host) packetEphemeral() int {
+	for {
+		p := h.nextPacketPort
+		h.nextPacketPort++
+		if _, ok := h.net.packetConns[Addr{h.name, p}]; !ok {
+			return p
+		}
+	}
+}
+
+// PacketConn is a datagram endpoint on a simulated network. It implements
+// net.PacketConn.
+//
+// Datagrams are unreliable, as with UDP: the faults of the link between
+// two hosts drop, duplicate, reorder and delay them, and a partition loses
+// them, all without the sender knowing.
+type PacketConn struct {
+	net  *Network
+	addr Addr
+
+	mu weft.Mutex
+	// queue holds the datagrams received and not yet read, and held one
+	// kept back to be delivered after the next.
+	queue  []datagram
+	held   *datagram
+	closed bool
+
+	readDeadline, writeDeadline time.Time
+
+	// ready wakes the reader when any of the above changes.
+	ready weft.Chan[struct{}]
+}
+
+// datagram is a datagram received.
+type datagram struct {
+	from Addr
+	data []byte
+}
+
+// ReadFrom reads the next datagram into b, returning its size and sender,
+// waiting until one arrives, the connection closes or the read deadline
+// passes. A datagram larger than b is truncated, as with UDP.
+func (c *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, nil, c.opError("read", nil, net.ErrClosed)
+		}
+		if len(c.queue) == 0 && c.held != nil {
+			// Nothing overtook the held datagram.
+			c.queue, c.held = append(c.queue, *c.held), nil
+		}
+		if len(c.queue) > 0 {
+			d := c.queue[0]
+			c.queue = c.queue[1:]
+			if len(c.queue) > 0 {
+				// Pass the wakeup on to any other reader.
+				c.wake()
+			}
+			c.mu.Unlock()
+			return copy(b, d.data), d.from, nil
+		}
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		if deadline.IsZero() {
+			c.ready.Recv()
+			continue
+		}
+		d := deadline.Sub(c.net.s.Now())
+		if d <= 0 {
+			return 0, nil, c.opError("read", nil, os.ErrDeadlineExceeded)
+		}
+		weft.Select(weft.OnRecv(c.ready), weft.OnRecv(c.net.s.After(d)))
+	}
+}
+
+// WriteTo sends b as a datagram to addr, such as an Addr returned by
+// ReadFrom, subject to the bandwidth and faults of the link. It does not
+// wait for delivery, and a datagram lost on the way is not reported.
+func (c *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	to, err := parseAddr(addr.String(), c.addr.Host)
+	if err != nil {
+		return 0, c.opError("write", addr, err)
+	}
+	c.mu.Lock()
+	closed, deadline := c.closed, c.writeDeadline
+	c.mu.Unlock()
+	switch {
+	case closed:
+		err = net.ErrClosed
+	case !deadline.IsZero() && !c.net.s.Now().Before(deadline):
+		err = os.ErrDeadlineExceeded
+	}
+	if err != nil {
+		return 0, c.opError("write", to, err)
+	}
+
+	n := c.net
+	n.mu.Lock()
+	mtu := n.mtu
+	n.mu.Unlock()
+	if len(b) > mtu {
+		return 0, c.opError("write", to, ErrMessageTooLong)
+	}
+	d := datagram{from: c.addr, data: append([]byte(nil), b...)}
+	n.transmit(c.addr.Host, to.Host, len(d.data), func() { n.sendPacket(d, to) })
+	return len(b), nil
+}
+
+// opError wraps err in a net.OpError for operation op.
+func (c *PacketConn) opError(op string, addr net.Addr, err error) error {
+	return &net.OpError{Op: op, Net: "weftnet", Source: c.addr, Addr: addr, Err: err}
+}
+
+// wake wakes the reader, if it is waiting.
+func (c *PacketConn) wake() {
+	c.ready.TrySend(struct{}{})
+}
+
+// Close closes the connection, discarding the datagrams not yet read.
+func (c *PacketConn) Close() error {
+	n := c.net
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return c.opError("close", nil, net.ErrClosed)
+	}
+	c.closed = true
+	c.queue, c.held = nil, nil
+	delete(n.packetConns, c.addr)
+	n.hosts[c.addr.Host].release()
+	c.wake()
+	return nil
+}
+
+// LocalAddr returns the connection's address.
+func (c *PacketConn) LocalAddr() net.Addr { return c.addr }
+
+// SetDeadline sets the read and write deadlines. Deadlines are times on
+// the network's scheduler clock, such as its Now plus a timeout.
+func (c *PacketConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the time after which ReadFrom fails with an error
+// wrapping os.ErrDeadlineExceeded. The zero time means no deadline.
+func (c *PacketConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	c.wake()
+	return nil
+}
+
+// SetWriteDeadline sets the time after which WriteTo fails. Writes never
+// wait, so it only matters once it has passed.
+func (c *PacketConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+// sendPacket delivers d to the endpoint at to, subject to the faults of the
+// link and to partitions, both when it is sent and, if it takes time to
+// arrive, when it arrives.
+func (n *Network) sendPacket(d datagram, to Addr) {
+	f, reachable := n.linkFaults(d.from.Host, to.Host)
+	if !reachable || n.s.Chance(f.Drop) {
+		return
+	}
+	copies := 1
+	if n.s.Chance(f.Duplicate) {
+		copies = 2
+	}
+	var delay time.Duration
+	if f.Latency != nil {
+		delay = f.Latency.Draw(n.s)
+	}
+	if f.MaxDelay > 0 && n.s.Chance(f.Delay) {
+		delay += f.MaxDelay * time.Duration(n.s.Choose(delaySteps)+1) / delaySteps
+	}
+	if delay > 0 {
+		n.s.Go(func(weft.Context) {
+			n.s.Sleep(delay)
+			if n.isReachable(d.from.Host, to.Host) {
+				n.deliverPacket(d, to, copies, f.Reorder)
+			}
+		})
+		return
+	}
+	n.deliverPacket(d, to, copies, f.Reorder)
+}
+
+// deliverPacket queues copies of d at the endpoint at to, if there is one
+// with room, holding it back to be overtaken by the next with probability
+// reorder.
+func (n *Network) deliverPacket(d datagram, to Addr, copies int, reorder float64) {
+	n.mu.Lock()
+	c := n.packetConns[to]
+	n.mu.Unlock()
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	if c.held == nil && n.s.Chance(reorder) {
+		c.held = &d
+		copies--
+	}
+	for ; copies > 0 && len(c.queue) < packetQueue; copies-- {
+		c.queue = append(c.queue, d)
+	}
+	if c.held != nil && c.held != &d && len(c.queue) < packetQueue {
+		c.queue, c.held = append(c.queue, *c.held), nil
+	}
+	c.wake()
+}
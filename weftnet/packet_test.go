@@ -0,0 +1,111 @@
+package weftnet
+
+import (
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// packetPair returns datagram endpoints on hosts a and b.
+func packetPair(t *testing.T, n *Network) (a, b *PacketConn) {
+	t.Helper()
+	a, err := n.Host("a").ListenPacket(":53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = n.Host("b").ListenPacket(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+// readAll returns the datagrams c has queued, waiting at most a moment.
+func readAll(c *PacketConn) []string {
+	var got []string
+	buf := make([]byte, 64)
+	for {
+		c.SetReadDeadline(c.net.s.Now().Add(10 * time.Millisecond))
+		n, _, err := c.ReadFrom(buf)
+		if err != nil {
+			return got
+		}
+		got = append(got, string(buf[:n]))
+	}
+}
+
+// TestPacketConn verifies that datagrams arrive whole, with their sender,
+// and that oversized datagrams are refused.
+func TestPacketConn(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	a, b := packetPair(t, n)
+	defer a.Close()
+	defer b.Close()
+
+	if _, err := b.WriteTo([]byte("ping"), a.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2)
+	nr, from, err := a.ReadFrom(buf)
+	if err != nil || nr != 2 || from != b.LocalAddr() {
+		t.Errorf("ReadFrom() = %d, %v, %v; want truncated datagram from %v", nr, from, err, b.LocalAddr())
+	}
+
+	n.SetMTU(3)
+	if _, err := b.WriteTo([]byte("ping"), a.LocalAddr()); !errors.Is(err, ErrMessageTooLong) {
+		t.Errorf("WriteTo() over MTU error = %v, want ErrMessageTooLong", err)
+	}
+	if _, err := n.Host("a").ListenPacket(":53"); err == nil {
+		t.Error("ListenPacket() on a port in use succeeded")
+	}
+	a.SetReadDeadline(s.Now())
+	if _, _, err := a.ReadFrom(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("ReadFrom() past deadline error = %v, want os.ErrDeadlineExceeded", err)
+	}
+	a.Close()
+	if _, _, err := a.ReadFrom(buf); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("ReadFrom() after Close error = %v, want net.ErrClosed", err)
+	}
+}
+
+// TestPacketFaults verifies that the faults of a link drop, duplicate and
+// reorder datagrams, and that partitions lose them.
+func TestPacketFaults(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		faults Faults
+		want   []string
+	}{
+		{"reliable", Faults{}, []string{"1", "2"}},
+		{"drop", Faults{Drop: 1}, nil},
+		{"duplicate", Faults{Duplicate: 1}, []string{"1", "1", "2", "2"}},
+		{"reorder", Faults{Reorder: 1}, []string{"2", "1"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			n := New(weft.NewScheduler(1))
+			a, b := packetPair(t, n)
+			n.SetFaults("b", "a", tt.faults)
+			b.WriteTo([]byte("1"), a.LocalAddr())
+			b.WriteTo([]byte("2"), a.LocalAddr())
+			if got := readAll(a); !slices.Equal(got, tt.want) {
+				t.Errorf("received %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	n := New(weft.NewScheduler(1))
+	a, b := packetPair(t, n)
+	p := n.Partition([]string{"a"}, []string{"b"})
+	b.WriteTo([]byte("lost"), a.LocalAddr())
+	p.Heal()
+	b.WriteTo([]byte("found"), a.LocalAddr())
+	if got := readAll(a); !slices.Equal(got, []string{"found"}) {
+		t.Errorf("received %q across healed partition, want [found]", got)
+	}
+}
@@ -0,0 +1,129 @@
+package weftnet
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// ErrUnreachable is returned, wrapped, by Dial when a partition separates
+// the hosts.
+var ErrUnreachable = errors.New("network is unreachable")
+
+// A Partition cuts a network into groups of hosts that cannot reach each
+// other: messages between hosts in different groups are lost and dials
+// fail. Hosts in no group reach, and are reached by, every host. A
+// partition lasts until it is healed.
+type Partition struct {
+	net *Network
+
+	// group maps each host to the index of its group.
+	group map[string]int
+
+	// active and healed are guarded by net.mu.
+	active, healed bool
+}
+
+// Partition separates groups of hosts, named as in Host, from each other
+// from now until the partition is healed.
+//
+//	p := n.Partition([]string{"a", "b"}, []string{"c"})
+//	p.HealAfter(5 * time.Second)
+func (n *Network) Partition(groups ...[]string) *Partition {
+	p := newPartition(n, groups)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	p.start()
+	return p
+}
+
+// PartitionAfter is like Partition, but the partition begins once d of
+// virtual time has passed.
+func (n *Network) PartitionAfter(d time.Duration, groups ...[]string) *Partition {
+	p := newPartition(n, groups)
+	n.s.Go(func(weft.Context) {
+		n.s.Sleep(d)
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		p.start()
+	})
+	return p
+}
+
+func newPartition(n *Network, groups [][]string) *Partition {
+	p := &Partition{net: n, group: make(map[string]int)}
+	for i, hosts := range groups {
+		for _, h := range hosts {
+			p.group[h] = i
+		}
+	}
+	return p
+}
+
+// start makes p take effect, unless it was healed first. The caller must
+// hold p.net.mu.
+func (p *Partition) start() {
+	if p.healed {
+		return
+	}
+	p.active = true
+	p.net.partitions = append(p.net.partitions, p)
+}
+
+// Heal ends the partition. Messages lost to it stay lost.
+func (p *Partition) Heal() {
+	n := p.net
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	p.healed = true
+	if !p.active {
+		return
+	}
+	p.active = false
+	for i, q := range n.partitions {
+		if q == p {
+			n.partitions = append(n.partitions[:i], n.partitions[i+1:]...)
+			break
+		}
+	}
+}
+
+// HealAfter heals the partition once d of virtual time has passed.
+func (p *Partition) HealAfter(d time.Duration) {
+	p.net.s.Go(func(weft.Context) {
+		p.net.s.Sleep(d)
+		p.Heal()
+	})
+}
+
+// separates reports whether p keeps host from from reaching host to.
+func (p *Partition) separates(from, to string) bool {
+	a, ok := p.group[from]
+	if !ok {
+		return false
+	}
+	b, ok := p.group[to]
+	return ok && a != b
+}
+
+// Heal ends every partition in effect.
+func (n *Network) Heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, p := range n.partitions {
+		p.active, p.healed = false, true
+	}
+	n.partitions = nil
+}
+
+// reachable reports whether messages from host from reach host to. The
+// caller must hold n.mu.
+func (n *Network) reachable(from, to string) bool {
+	for _, p := range n.partitions {
+		if p.separates(from, to) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package weftnet
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mziter/weft"
+)
+
+// TestPartition verifies that a partition loses messages and refuses dials
+// between its groups, leaves other hosts alone, and can be healed.
+func TestPartition(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	client, server := pair(t, n)
+	p := n.Partition([]string{"client"}, []string{"server"})
+
+	client.Write([]byte("lost"))
+	if _, err := n.Host("client").Dial("server:80"); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Dial() across partition: %v, want ErrUnreachable", err)
+	}
+	l, err := n.Host("server").Listen(":81")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Host("bystander").Dial("server:81"); err != nil {
+		t.Errorf("Dial() from a host in no group: %v", err)
+	}
+
+	p.Heal()
+	client.Write([]byte("found"))
+	client.Close()
+	data, err := io.ReadAll(server)
+	if err != nil || string(data) != "found" {
+		t.Errorf("ReadAll() = %q, %v; want %q", data, err, "found")
+	}
+	l.Close()
+}
@@ -0,0 +1,211 @@
+//go:build detsched
+
+package weftnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/latency"
+)
+
+// TestPartitionAfter verifies that partitions begin and heal once the given
+// virtual time has passed.
+func TestPartitionAfter(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	l, err := n.Host("server").Listen(":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	dial := func() error {
+		c, err := n.Host("client").Dial("server:80")
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+
+	p := n.PartitionAfter(10*time.Millisecond, []string{"client"}, []string{"server"})
+	s.Wait()
+	if err := dial(); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Dial() during the partition: %v, want ErrUnreachable", err)
+	}
+	p.HealAfter(10 * time.Millisecond)
+	s.Wait()
+	if err := dial(); err != nil {
+		t.Errorf("Dial() after healing: %v", err)
+	}
+}
+
+// TestLookupDelay verifies that slow lookups take virtual time and give up
+// when their context is done.
+func TestLookupDelay(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	n.SetRecord("db", Record{Hosts: []string{"db-1"}, MaxDelay: time.Second})
+	h := n.Host("client")
+	var timedOut bool
+	for i := 0; i < 20 && !timedOut; i++ {
+		ctx, cancel := n.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, err := h.LookupHost(ctx, "db")
+		cancel()
+		if err != nil {
+			var de *net.DNSError
+			if !errors.As(err, &de) || !de.IsTimeout {
+				t.Fatalf("LookupHost() = %v, want timeout", err)
+			}
+			timedOut = true
+		}
+	}
+	if !timedOut {
+		t.Error("no lookup of up to 1s timed out after 100ms")
+	}
+}
+
+// TestLatency verifies that messages taking their latency arrive in order,
+// after virtual time has passed.
+func TestLatency(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	n.SetFaults("client", "server", Faults{Latency: latency.Exponential(time.Millisecond, 10*time.Millisecond)})
+	client, server := pair(t, n)
+	want := ""
+	for i := 0; i < 20; i++ {
+		msg := fmt.Sprint(i, " ")
+		want += msg
+		client.Write([]byte(msg))
+	}
+	client.Close()
+	data, err := io.ReadAll(server)
+	if err != nil || string(data) != want {
+		t.Errorf("ReadAll() = %q, %v; want %q", data, err, want)
+	}
+}
+
+// TestSendBuffer verifies that Write waits while the peer has not read
+// enough of the data sent before.
+func TestSendBuffer(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	n.SetSendBuffer(10)
+	client, server := pair(t, n)
+	client.Write([]byte("12345678"))
+	wrote := weft.MakeChan[struct{}](1)
+	s.Go(func(weft.Context) {
+		client.Write([]byte("abcdefgh"))
+		wrote.Send(struct{}{})
+	})
+	if weft.Select(weft.OnRecv(wrote), weft.OnRecv(s.After(time.Second))) == 0 {
+		t.Fatal("Write() did not wait for room in the send buffer")
+	}
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "12345678" {
+		t.Fatalf("ReadFull() = %q, %v", buf, err)
+	}
+	wrote.Recv()
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "abcdefgh" {
+		t.Errorf("ReadFull() = %q, %v", buf, err)
+	}
+}
+
+// TestDeadlines verifies that read and write deadlines are times on the
+// scheduler's clock, which a read or write waits for on virtual time.
+func TestDeadlines(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	n.SetSendBuffer(1)
+	client, server := pair(t, n)
+	start := s.Now()
+	client.SetReadDeadline(start.Add(time.Hour))
+	if _, err := client.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read() error = %v, want a deadline error", err)
+	}
+	if waited := s.Now().Sub(start); waited != time.Hour {
+		t.Errorf("Read() timed out after %v, want 1h0m0s", waited)
+	}
+
+	client.Write([]byte("1"))
+	client.SetWriteDeadline(s.Now().Add(time.Minute))
+	if _, err := client.Write([]byte("2")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Write() to a full buffer error = %v, want a deadline error", err)
+	}
+
+	a, err := n.Host("a").ListenPacket(":53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start = s.Now()
+	a.SetReadDeadline(start.Add(time.Second))
+	if _, _, err := a.ReadFrom(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("ReadFrom() error = %v, want a deadline error", err)
+	}
+	if waited := s.Now().Sub(start); waited != time.Second {
+		t.Errorf("ReadFrom() timed out after %v, want 1s", waited)
+	}
+	server.Close()
+}
+
+// TestBandwidth verifies that messages over a capped link take their size
+// over the bandwidth to arrive.
+func TestBandwidth(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	n.SetBandwidth("client", "server", 100)
+	client, server := pair(t, n)
+	client.Write(make([]byte, 100))
+	client.Write(make([]byte, 100))
+	read := weft.MakeChan[error](1)
+	s.Go(func(weft.Context) {
+		_, err := io.ReadFull(server, make([]byte, 200))
+		read.Send(err)
+	})
+	if weft.Select(weft.OnRecv(read), weft.OnRecv(s.After(100*time.Millisecond))) == 0 {
+		t.Fatal("messages of 100 bytes at 100 bytes/s arrived within 100ms")
+	}
+	if err, _ := read.Recv(); err != nil {
+		t.Errorf("ReadFull() = %v", err)
+	}
+}
+
+// TestWebSocketKeepAlive verifies that a WebSocket whose peer reads stays
+// up across pings, and that one whose peer stops reading fails with
+// ErrPongTimeout once the pong is late.
+func TestWebSocketKeepAlive(t *testing.T) {
+	s := weft.NewScheduler(1)
+	n := New(s)
+	// Only the client pings, so that only it can time out.
+	c, srv := pair(t, n)
+	client := n.NewWebSocket(c, WebSocketConfig{PingInterval: time.Second, PongTimeout: 500 * time.Millisecond})
+	server := n.NewWebSocket(srv, WebSocketConfig{})
+	read := weft.MakeChan[error](1)
+	s.Go(func(weft.Context) {
+		_, _, err := server.ReadMessage()
+		read.Send(err)
+	})
+	s.Go(func(weft.Context) {
+		// Read only to answer pings.
+		client.ReadMessage()
+	})
+	s.Sleep(5 * time.Second)
+	if err := client.WriteMessage(TextMessage, []byte("alive")); err != nil {
+		t.Fatalf("WriteMessage() after 5s of pings = %v", err)
+	}
+	if err, _ := read.Recv(); err != nil {
+		t.Fatalf("ReadMessage() = %v", err)
+	}
+
+	// The server no longer reads, so it answers no pings.
+	s.Sleep(5 * time.Second)
+	if err := client.WriteMessage(TextMessage, nil); !errors.Is(err, ErrPongTimeout) {
+		t.Errorf("WriteMessage() after the pong timeout = %v, want ErrPongTimeout", err)
+	}
+}
@@ -0,0 +1,307 @@
+package weftnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// WebSocket errors.
+var (
+	// ErrWebSocketClosed is returned by a WebSocket closed by either end
+	// with a close frame.
+	ErrWebSocketClosed = errors.New("websocket closed")
+
+	// ErrPongTimeout is returned by a WebSocket whose peer did not answer
+	// a ping in time.
+	ErrPongTimeout = errors.New("websocket pong timeout")
+
+	// ErrWebSocketDropped is returned by a WebSocket whose connection was
+	// dropped by its Drop fault.
+	ErrWebSocketDropped = errors.New("websocket connection dropped")
+)
+
+// MessageType is the type of a WebSocket message, numbered as the opcodes
+// of RFC 6455.
+type MessageType int
+
+// The message types.
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Opcodes of frames that are not messages.
+const (
+	opContinuation = 0
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// frameHeader is the size of a frame's header: its opcode, whether it ends
+// its message, and the length of its payload.
+const frameHeader = 6
+
+// WebSocketConfig configures a WebSocket.
+type WebSocketConfig struct {
+	// FrameSize is the largest payload of a frame; longer messages are
+	// split into several. Zero means 4096 bytes.
+	FrameSize int
+
+	// PingInterval, if not zero, makes the socket ping its peer at that
+	// interval of virtual time, and fail with ErrPongTimeout if no pong
+	// comes back within PongTimeout; zero PongTimeout means PingInterval.
+	// As with most WebSocket libraries, pongs are only sent and seen while
+	// ReadMessage is being called.
+	PingInterval, PongTimeout time.Duration
+
+	// Drop is the probability, from 0 to 1, that the connection breaks
+	// before each frame is written, as the schedule decides, so that a
+	// message of several frames can be cut off partway through.
+	Drop float64
+}
+
+// WebSocket is a message-framed, full-duplex connection, in the manner of
+// RFC 6455, over a connection of a simulated network. Messages are split
+// into frames of at most FrameSize bytes, control frames keep the
+// connection alive on virtual time, and the connection can drop between
+// any two frames, so that the reconnect and resume logic of realtime
+// clients and streaming services is explored. One task may read and
+// another write at the same time.
+type WebSocket struct {
+	n    *Network
+	conn net.Conn
+	cfg  WebSocketConfig
+
+	// wmu serializes the frames written.
+	wmu weft.Mutex
+
+	// mu guards the fields below it; done is closed when the socket
+	// fails or is closed.
+	mu        weft.Mutex
+	err       error
+	pinged    uint64
+	ponged    uint64
+	sentClose bool
+	done      weft.Chan[struct{}]
+}
+
+// NewWebSocket returns a WebSocket over c, a connection on n. Both ends of
+// a connection need one.
+func (n *Network) NewWebSocket(c net.Conn, cfg WebSocketConfig) *WebSocket {
+	if cfg.FrameSize <= 0 {
+		cfg.FrameSize = 4096
+	}
+	if cfg.PongTimeout <= 0 {
+		cfg.PongTimeout = cfg.PingInterval
+	}
+	ws := &WebSocket{n: n, conn: c, cfg: cfg, done: weft.MakeChan[struct{}](0)}
+	if cfg.PingInterval > 0 {
+		n.s.Go(func(weft.Context) { ws.keepAlive() })
+	}
+	return ws
+}
+
+// DialWebSocket dials addr, as Dial does, and returns a WebSocket over the
+// connection.
+func (h *Host) DialWebSocket(addr string, cfg WebSocketConfig) (*WebSocket, error) {
+	c, err := h.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return h.net.NewWebSocket(c, cfg), nil
+}
+
+// AcceptWebSocket accepts the next connection to the listener, as Accept
+// does, and returns a WebSocket over it.
+func (l *Listener) AcceptWebSocket(cfg WebSocketConfig) (*WebSocket, error) {
+	c, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.net.NewWebSocket(c, cfg), nil
+}
+
+// WriteMessage sends data as one message of type typ.
+func (ws *WebSocket) WriteMessage(typ MessageType, data []byte) error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	op := byte(typ)
+	for {
+		n := min(len(data), ws.cfg.FrameSize)
+		fin := n == len(data)
+		if err := ws.writeFrame(op, fin, data[:n]); err != nil {
+			return err
+		}
+		if fin {
+			return nil
+		}
+		op, data = opContinuation, data[n:]
+	}
+}
+
+// writeFrame writes a frame, unless the socket has failed or the Drop
+// fault breaks the connection first. The caller must hold ws.wmu.
+func (ws *WebSocket) writeFrame(op byte, fin bool, payload []byte) error {
+	if err := ws.failure(); err != nil {
+		return err
+	}
+	if ws.n.s.Chance(ws.cfg.Drop) {
+		ws.fail(ErrWebSocketDropped)
+		return ErrWebSocketDropped
+	}
+	frame := make([]byte, frameHeader+len(payload))
+	frame[0] = op
+	if fin {
+		frame[1] = 1
+	}
+	binary.BigEndian.PutUint32(frame[2:], uint32(len(payload)))
+	copy(frame[frameHeader:], payload)
+	if _, err := ws.conn.Write(frame); err != nil {
+		ws.fail(io.ErrUnexpectedEOF)
+		return ws.failure()
+	}
+	return nil
+}
+
+// ReadMessage waits for the next message and returns its type and data,
+// answering the pings and noting the pongs that arrive before it. It
+// returns ErrWebSocketClosed once either end has closed the socket,
+// io.ErrUnexpectedEOF if the connection ends without a close frame,
+// cutting off any message partway through, and ErrPongTimeout or
+// ErrWebSocketDropped if the socket failed.
+func (ws *WebSocket) ReadMessage() (MessageType, []byte, error) {
+	var (
+		typ  MessageType
+		msg  []byte
+		more bool
+	)
+	for {
+		op, fin, payload, err := ws.readFrame()
+		if err != nil {
+			ws.fail(io.ErrUnexpectedEOF)
+			return 0, nil, ws.failure()
+		}
+		switch op {
+		case opPing:
+			ws.wmu.Lock()
+			ws.writeFrame(opPong, true, payload)
+			ws.wmu.Unlock()
+		case opPong:
+			if len(payload) == 8 {
+				ws.mu.Lock()
+				ws.ponged = max(ws.ponged, binary.BigEndian.Uint64(payload))
+				ws.mu.Unlock()
+			}
+		case opClose:
+			ws.Close()
+			return 0, nil, ErrWebSocketClosed
+		case opContinuation:
+			if !more {
+				ws.fail(io.ErrUnexpectedEOF)
+				return 0, nil, ws.failure()
+			}
+			msg = append(msg, payload...)
+		default:
+			typ, msg = MessageType(op), payload
+		}
+		if op == opContinuation || op < opClose {
+			more = !fin
+			if fin {
+				return typ, msg, nil
+			}
+		}
+	}
+}
+
+// readFrame reads the next frame from the connection.
+func (ws *WebSocket) readFrame() (op byte, fin bool, payload []byte, err error) {
+	var h [frameHeader]byte
+	if _, err := io.ReadFull(ws.conn, h[:]); err != nil {
+		return 0, false, nil, err
+	}
+	payload = make([]byte, binary.BigEndian.Uint32(h[2:]))
+	if _, err := io.ReadFull(ws.conn, payload); err != nil {
+		return 0, false, nil, err
+	}
+	return h[0], h[1] == 1, payload, nil
+}
+
+// Close sends a close frame, if the socket has not failed, and closes the
+// connection. Reads and writes then fail with ErrWebSocketClosed.
+func (ws *WebSocket) Close() error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	ws.mu.Lock()
+	first := !ws.sentClose && ws.err == nil
+	ws.sentClose = true
+	ws.mu.Unlock()
+	if !first {
+		return nil
+	}
+	ws.writeFrame(opClose, true, nil)
+	ws.fail(ErrWebSocketClosed)
+	return nil
+}
+
+// keepAlive pings the peer every PingInterval until the socket fails,
+// failing it if a pong does not come back in time.
+func (ws *WebSocket) keepAlive() {
+	s := ws.n.s
+	for {
+		ws.mu.Lock()
+		ws.pinged++
+		seq := ws.pinged
+		ws.mu.Unlock()
+		var payload [8]byte
+		binary.BigEndian.PutUint64(payload[:], seq)
+		ws.wmu.Lock()
+		err := ws.writeFrame(opPing, true, payload[:])
+		ws.wmu.Unlock()
+		if err != nil {
+			return
+		}
+		if weft.Select(weft.OnRecv(s.After(ws.cfg.PongTimeout)), weft.OnRecv(ws.done)) == 1 {
+			return
+		}
+		ws.mu.Lock()
+		late := ws.ponged < seq
+		ws.mu.Unlock()
+		if late {
+			ws.fail(ErrPongTimeout)
+			return
+		}
+		if rest := ws.cfg.PingInterval - ws.cfg.PongTimeout; rest > 0 {
+			if weft.Select(weft.OnRecv(s.After(rest)), weft.OnRecv(ws.done)) == 1 {
+				return
+			}
+		}
+	}
+}
+
+// fail records err as the socket's error, unless it already has one, and
+// closes the connection.
+func (ws *WebSocket) fail(err error) {
+	ws.mu.Lock()
+	first := ws.err == nil
+	if first {
+		ws.err = err
+		ws.done.Close()
+	}
+	ws.mu.Unlock()
+	if first {
+		ws.conn.Close()
+	}
+}
+
+// failure returns the socket's error, or nil if it has not failed.
+func (ws *WebSocket) failure() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.err
+}
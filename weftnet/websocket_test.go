@@ -0,0 +1,83 @@
+package weftnet
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mziter/weft"
+)
+
+// wsPair returns both ends of a WebSocket between client and server:80.
+func wsPair(t *testing.T, n *Network, cfg WebSocketConfig) (client, server *WebSocket) {
+	t.Helper()
+	c, s := pair(t, n)
+	return n.NewWebSocket(c, cfg), n.NewWebSocket(s, cfg)
+}
+
+// TestWebSocket verifies that messages longer than a frame cross a
+// WebSocket whole and in order, in both directions.
+func TestWebSocket(t *testing.T) {
+	n := New(weft.NewScheduler(1))
+	client, server := wsPair(t, n, WebSocketConfig{FrameSize: 4})
+	long := strings.Repeat("abcdefg", 3)
+	if err := client.WriteMessage(TextMessage, []byte(long)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WriteMessage(BinaryMessage, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if typ, msg, err := server.ReadMessage(); err != nil || typ != TextMessage || string(msg) != long {
+		t.Errorf("ReadMessage() = %d, %q, %v; want %d, %q", typ, msg, err, TextMessage, long)
+	}
+	if typ, msg, err := server.ReadMessage(); err != nil || typ != BinaryMessage || string(msg) != "\x01\x02" {
+		t.Errorf("ReadMessage() = %d, %q, %v; want %d, [1 2]", typ, msg, err, BinaryMessage)
+	}
+	if err := server.WriteMessage(TextMessage, []byte("reply")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := client.ReadMessage(); err != nil || string(msg) != "reply" {
+		t.Errorf("ReadMessage() = %q, %v; want %q", msg, err, "reply")
+	}
+}
+
+// TestWebSocketClose verifies that closing one end of a WebSocket closes
+// the other once it reads the close frame.
+func TestWebSocketClose(t *testing.T) {
+	n := New(weft.NewScheduler(1))
+	client, server := wsPair(t, n, WebSocketConfig{})
+	client.Close()
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrWebSocketClosed) {
+		t.Errorf("ReadMessage() after the peer closed = %v, want ErrWebSocketClosed", err)
+	}
+	if err := server.WriteMessage(TextMessage, nil); !errors.Is(err, ErrWebSocketClosed) {
+		t.Errorf("WriteMessage() after close = %v, want ErrWebSocketClosed", err)
+	}
+	if err := client.WriteMessage(TextMessage, nil); !errors.Is(err, ErrWebSocketClosed) {
+		t.Errorf("WriteMessage() after Close = %v, want ErrWebSocketClosed", err)
+	}
+}
+
+// TestWebSocketDrop verifies that a dropped connection fails the writer
+// with ErrWebSocketDropped and the reader with io.ErrUnexpectedEOF,
+// including partway through a message.
+func TestWebSocketDrop(t *testing.T) {
+	n := New(weft.NewScheduler(1))
+	client, server := wsPair(t, n, WebSocketConfig{FrameSize: 4})
+	// Write the first frame of a message, then drop the connection.
+	client.wmu.Lock()
+	client.writeFrame(byte(TextMessage), false, []byte("half"))
+	client.cfg.Drop = 1
+	err := client.writeFrame(opContinuation, true, []byte("done"))
+	client.wmu.Unlock()
+	if !errors.Is(err, ErrWebSocketDropped) {
+		t.Errorf("writing after a drop = %v, want ErrWebSocketDropped", err)
+	}
+	if _, msg, err := server.ReadMessage(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadMessage() of a cut-off message = %q, %v; want io.ErrUnexpectedEOF", msg, err)
+	}
+	if err := client.WriteMessage(TextMessage, nil); !errors.Is(err, ErrWebSocketDropped) {
+		t.Errorf("WriteMessage() after a drop = %v, want ErrWebSocketDropped", err)
+	}
+}
@@ -0,0 +1,305 @@
+// Package weftnet simulates a network of hosts for weft tests. Hosts listen
+// and dial as they would with package net, and their connections are
+// net.Conns whose delivery runs on weft tasks, so the scheduler explores
+// how messages interleave with everything else.
+//
+// Faults are injected per link with Faults: messages can be dropped,
+// delayed, reordered or duplicated with given probabilities. Whether a fault
+// happens is a decision of the schedule, made with Scheduler.Choose, so a
+// failing run replays, and shrinks, with exactly the same faults. Partition
+// cuts groups of hosts off from each other until they are healed.
+//
+// SetLimits bounds the connections a host holds open, so that dialing,
+// accepting and listening fail as they do when a process runs out of file
+// descriptors.
+//
+// SetRecord registers names that resolve, after a delay or not at all as
+// the schedule decides, to hosts, for Dial and LookupHost.
+//
+// PacketConns send datagrams, as over UDP: the same faults apply to each
+// datagram, and one larger than the network's MTU is refused.
+//
+// HTTPServer and HTTPTransport carry net/http requests over the network;
+// Host.DialContext and Network.WithTimeout wire gRPC clients and servers to
+// it.
+//
+// WebSocket frames messages over a connection, in the manner of RFC 6455,
+// with pings on virtual time and connections that drop between frames.
+//
+//	n := weftnet.New(s)
+//	l, _ := n.Host("server").Listen(":80")
+//	c, _ := n.Host("client").Dial("server:80")
+//	n.SetFaults("client", "server", weftnet.Faults{Drop: 0.1})
+package weftnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/mziter/weft"
+)
+
+// ErrRefused is returned, wrapped, by Dial when nothing listens on the
+// address.
+var ErrRefused = errors.New("connection refused")
+
+// firstEphemeral is the first port assigned to listeners on port 0 and to
+// the local end of dialed connections.
+const firstEphemeral = 49152
+
+// backlog is the number of connections a listener queues before Dial is
+// refused.
+const backlog = 128
+
+// Network is a simulated network. Its zero value is not usable; create
+// networks with New.
+type Network struct {
+	s *weft.Scheduler
+
+	mu        weft.Mutex
+	hosts     map[string]*Host
+	listeners map[Addr]*Listener
+	faults    map[link]Faults
+	defaults  Faults
+
+	// packetConns are the datagram endpoints, by address, and mtu the
+	// size of the largest datagram.
+	packetConns map[Addr]*PacketConn
+	mtu         int
+
+	// partitions are the partitions in effect.
+	partitions []*Partition
+
+	// records are the names set with SetRecord.
+	records map[string]Record
+
+	// bandwidth caps links, whose messages transmitters queue, and
+	// sendBuffer bounds the data connections hold.
+	bandwidth    map[link]int
+	transmitters map[link]*transmitter
+	sendBuffer   int
+}
+
+// New returns an empty network whose deliveries run on s.
+func New(s *weft.Scheduler) *Network {
+	return &Network{
+		s:            s,
+		hosts:        make(map[string]*Host),
+		listeners:    make(map[Addr]*Listener),
+		faults:       make(map[link]Faults),
+		packetConns:  make(map[Addr]*PacketConn),
+		records:      make(map[string]Record),
+		bandwidth:    make(map[link]int),
+		transmitters: make(map[link]*transmitter),
+		mtu:          maxDatagram,
+	}
+}
+
+// Host returns the host called name, adding it to the network the first
+// time.
+func (n *Network) Host(name string) *Host {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	h, ok := n.hosts[name]
+	if !ok {
+		h = &Host{net: n, name: name, nextPort: firstEphemeral, nextPacketPort: firstEphemeral}
+		n.hosts[name] = h
+	}
+	return h
+}
+
+// Addr is the address of an endpoint on a simulated network.
+type Addr struct {
+	Host string
+	Port int
+}
+
+// Network implements net.Addr.
+func (a Addr) Network() string { return "weftnet" }
+
+// String returns the address as host:port.
+func (a Addr) String() string { return net.JoinHostPort(a.Host, strconv.Itoa(a.Port)) }
+
+// parseAddr parses a host:port address. An empty host is replaced with def.
+func parseAddr(addr, def string) (Addr, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return Addr{}, err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p > 65535 {
+		return Addr{}, fmt.Errorf("invalid port %q", port)
+	}
+	if host == "" {
+		host = def
+	}
+	return Addr{Host: host, Port: p}, nil
+}
+
+// Host is a machine on a simulated network.
+type Host struct {
+	net  *Network
+	name string
+
+	// nextPort and nextPacketPort are the next ephemeral stream and
+	// datagram ports, guarded by net.mu.
+	nextPort, nextPacketPort int
+
+	// limits are the host's limits, and handles the handles it holds
+	// open, guarded by net.mu.
+	limits  Limits
+	handles int
+}
+
+// Name returns the name of the host.
+func (h *Host) Name() string {
+	return h.name
+}
+
+// ephemeral returns an unused port on h. The caller must hold h.net.mu.
+func (h *Host) ephemeral() int {
+	for {
+		p := h.nextPort
+		h.nextPort++
+		if _, ok := h.net.listeners[Addr{h.name, p}]; !ok {
+			return p
+		}
+	}
+}
+
+// Listen listens on addr, ":port" or "host:port" for this host. Port 0
+// picks an unused port.
+func (h *Host) Listen(addr string) (*Listener, error) {
+	a, err := parseAddr(addr, h.name)
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "weftnet", Err: err}
+	}
+	if a.Host != h.name {
+		return nil, &net.OpError{Op: "listen", Net: "weftnet", Addr: a, Err: fmt.Errorf("address not on host %s", h.name)}
+	}
+	n := h.net
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if a.Port == 0 {
+		a.Port = h.ephemeral()
+	}
+	if _, ok := n.listeners[a]; ok {
+		return nil, &net.OpError{Op: "listen", Net: "weftnet", Addr: a, Err: errors.New("address already in use")}
+	}
+	if err := h.acquire(); err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "weftnet", Addr: a, Err: err}
+	}
+	l := &Listener{net: n, addr: a, conns: weft.MakeChan[*Conn](backlog)}
+	n.listeners[a] = l
+	return l, nil
+}
+
+// Dial connects to the listener at addr, as host:port. A host that is a
+// name with a Record is resolved first, and its hosts are tried in turn
+// until one accepts the connection.
+func (h *Host) Dial(addr string) (net.Conn, error) {
+	return h.dial(context.Background(), addr)
+}
+
+// dial is Dial, with ctx bounding the lookup of the host's name.
+func (h *Host) dial(ctx context.Context, addr string) (net.Conn, error) {
+	a, err := parseAddr(addr, h.name)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: "weftnet", Err: err}
+	}
+	hosts, err := h.net.resolve(ctx, a.Host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: "weftnet", Err: err}
+	}
+	if hosts == nil {
+		return h.connect(a)
+	}
+	for _, host := range hosts {
+		var c net.Conn
+		if c, err = h.connect(Addr{Host: host, Port: a.Port}); err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// connect connects to the listener at a.
+func (h *Host) connect(a Addr) (net.Conn, error) {
+	n := h.net
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.reachable(h.name, a.Host) {
+		return nil, &net.OpError{Op: "dial", Net: "weftnet", Addr: a, Err: ErrUnreachable}
+	}
+	l, ok := n.listeners[a]
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: "weftnet", Addr: a, Err: ErrRefused}
+	}
+	if err := h.acquire(); err != nil {
+		return nil, &net.OpError{Op: "dial", Net: "weftnet", Addr: a, Err: err}
+	}
+	local := Addr{Host: h.name, Port: h.ephemeral()}
+	c, peer := newConnPair(n, local, a)
+	if !l.conns.TrySend(peer) {
+		h.release()
+		return nil, &net.OpError{Op: "dial", Net: "weftnet", Addr: a, Err: ErrRefused}
+	}
+	c.held = true
+	return c, nil
+}
+
+// Listener accepts connections on a simulated network. It implements
+// net.Listener.
+type Listener struct {
+	net   *Network
+	addr  Addr
+	conns weft.Chan[*Conn]
+
+	// closed is guarded by net.mu.
+	closed bool
+}
+
+// Accept waits for and returns the next connection to the listener. It
+// fails at once, leaving the connections queued, while the host is out of
+// handles.
+func (l *Listener) Accept() (net.Conn, error) {
+	n := l.net
+	n.mu.Lock()
+	h := n.hosts[l.addr.Host]
+	err := h.acquire()
+	n.mu.Unlock()
+	if err != nil {
+		return nil, &net.OpError{Op: "accept", Net: "weftnet", Addr: l.addr, Err: err}
+	}
+	c, ok := l.conns.Recv()
+	if !ok {
+		n.mu.Lock()
+		h.release()
+		n.mu.Unlock()
+		return nil, &net.OpError{Op: "accept", Net: "weftnet", Addr: l.addr, Err: net.ErrClosed}
+	}
+	c.held = true
+	return c, nil
+}
+
+// Close stops the listener. Connections already accepted stay open.
+func (l *Listener) Close() error {
+	l.net.mu.Lock()
+	defer l.net.mu.Unlock()
+	if l.closed {
+		return &net.OpError{Op: "close", Net: "weftnet", Addr: l.addr, Err: net.ErrClosed}
+	}
+	l.closed = true
+	delete(l.net.listeners, l.addr)
+	l.net.hosts[l.addr.Host].release()
+	l.conns.Close()
+	return nil
+}
+
+// Addr returns the listener's address.
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,189 @@
+package weftnet
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// pair returns both ends of a connection from host client to host server.
+func pair(t *testing.T, n *Network) (client, server net.Conn) {
+	t.Helper()
+	l, err := n.Host("server").Listen(":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err = n.Host("client").Dial("server:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+// TestConn verifies that data crosses a connection in order and that
+// closing one end ends the other's reads.
+func TestConn(t *testing.T) {
+	n := New(weft.NewScheduler(1))
+	client, server := pair(t, n)
+	if got, want := client.RemoteAddr().String(), "server:80"; got != want {
+		t.Errorf("RemoteAddr() = %s, want %s", got, want)
+	}
+	if got, want := server.RemoteAddr(), client.LocalAddr(); got != want {
+		t.Errorf("server RemoteAddr() = %s, client LocalAddr() = %s", got, want)
+	}
+	for _, msg := range []string{"hello, ", "world"} {
+		if _, err := client.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Close()
+	data, err := io.ReadAll(server)
+	if err != nil || string(data) != "hello, world" {
+		t.Errorf("ReadAll() = %q, %v; want %q", data, err, "hello, world")
+	}
+	if _, err := client.Write([]byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write after Close: %v, want net.ErrClosed", err)
+	}
+}
+
+// TestDialRefused verifies that dialing an address nobody listens on fails.
+func TestDialRefused(t *testing.T) {
+	n := New(weft.NewScheduler(1))
+	if _, err := n.Host("client").Dial("server:80"); !errors.Is(err, ErrRefused) {
+		t.Errorf("Dial() error = %v, want ErrRefused", err)
+	}
+	l, err := n.Host("server").Listen(":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept() after Close: %v, want net.ErrClosed", err)
+	}
+	if _, err := n.Host("client").Dial("server:80"); !errors.Is(err, ErrRefused) {
+		t.Errorf("Dial() after Close: %v, want ErrRefused", err)
+	}
+}
+
+// TestReadDeadline verifies that a read with nothing to read times out.
+func TestReadDeadline(t *testing.T) {
+	s := weft.NewScheduler(1)
+	client, _ := pair(t, New(s))
+	client.SetReadDeadline(s.Now().Add(time.Millisecond))
+	if _, err := client.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read() error = %v, want a deadline error", err)
+	}
+}
+
+// TestFaults verifies each fault with probability 1, which involves no
+// random decisions.
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults Faults
+		want   string
+	}{
+		{"reliable", Faults{}, "abc"},
+		{"drop", Faults{Drop: 1}, ""},
+		{"duplicate", Faults{Duplicate: 1}, "aabbcc"},
+		{"reorder", Faults{Reorder: 1}, "bac"},
+		// A delayed message may be overtaken, but is delivered before
+		// the end of the stream.
+		{"delay", Faults{Delay: 1, MaxDelay: time.Millisecond}, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := weft.NewScheduler(1)
+			n := New(s)
+			n.SetFaults("client", "server", tt.faults)
+			client, server := pair(t, n)
+			msgs := []string{"a", "b", "c"}
+			if tt.faults.Delay > 0 {
+				msgs = msgs[:1]
+			}
+			for _, msg := range msgs {
+				client.Write([]byte(msg))
+			}
+			client.Close()
+			data, err := io.ReadAll(server)
+			if err != nil || string(data) != tt.want {
+				t.Errorf("ReadAll() = %q, %v; want %q", data, err, tt.want)
+			}
+			s.Wait()
+		})
+	}
+}
+
+// TestFaultsReplay verifies that replaying the choices of a run repeats its
+// faults.
+func TestFaultsReplay(t *testing.T) {
+	run := func(s *weft.Scheduler) string {
+		n := New(s)
+		n.SetDefaultFaults(Faults{Drop: 0.5, Duplicate: 0.5})
+		client, server := pair(t, n)
+		for _, msg := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			client.Write([]byte(msg))
+		}
+		client.Close()
+		data, _ := io.ReadAll(server)
+		return string(data)
+	}
+	s := weft.NewScheduler(42)
+	first := run(s)
+	if s.Choices() == nil {
+		t.Skip("faults are recorded only with -tags=detsched")
+	}
+	if again := run(weft.NewReplayScheduler(0, s.Choices())); again != first {
+		t.Errorf("replay delivered %q, want %q", again, first)
+	}
+}
+
+// TestLimits verifies that a host out of handles fails to dial, accept and
+// listen with EMFILE, and recovers as handles are closed.
+func TestLimits(t *testing.T) {
+	n := New(weft.NewScheduler(1))
+	n.SetLimits("server", Limits{Handles: 2})
+	n.SetLimits("client", Limits{Handles: 1})
+	l, err := n.Host("server").Listen(":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, err := n.Host("client").Dial("server:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Host("client").Dial("server:80"); !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("Dial beyond Handles error = %v, want EMFILE", err)
+	}
+	s1, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Host("server").ListenPacket(":53"); !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("ListenPacket beyond Handles error = %v, want EMFILE", err)
+	}
+
+	c1.Close()
+	c2, err := n.Host("client").Dial("server:80")
+	if err != nil {
+		t.Fatalf("Dial after Close: %v", err)
+	}
+	if _, err := l.Accept(); !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("Accept beyond Handles error = %v, want EMFILE", err)
+	}
+	s1.Close()
+	if s2, err := l.Accept(); err != nil || s2.RemoteAddr() != c2.LocalAddr() {
+		t.Errorf("Accept after Close = %v, %v; want the queued connection", s2, err)
+	}
+}
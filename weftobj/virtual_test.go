@@ -0,0 +1,28 @@
+//go:build detsched
+
+package weftobj
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/latency"
+)
+
+// TestSettle verifies that writes become visible everywhere once their lag
+// has passed.
+func TestSettle(t *testing.T) {
+	s := weft.NewScheduler(1)
+	st := New(s)
+	st.SetConsistency(Consistency{Lag: latency.Uniform(time.Millisecond, 10*time.Millisecond), StaleRead: 1, StaleList: 1})
+	put(t, st, "k", "v1")
+	put(t, st, "k", "v2")
+	s.Wait()
+	if got := get(t, st, "k"); got != "v2" {
+		t.Errorf("Get() after the lag = %q, want v2", got)
+	}
+	if got := keys(t, st, ""); len(got) != 1 {
+		t.Errorf("List() after the lag = %v, want [k]", got)
+	}
+}
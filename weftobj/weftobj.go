@@ -0,0 +1,291 @@
+// Package weftobj simulates an object store, in the manner of S3 or GCS,
+// for weft tests. Objects are put, read, listed and deleted by bucket and
+// key, and the store can be as weakly consistent as the real ones have
+// been: a write takes a while, drawn from a latency.Distribution, to become
+// visible everywhere, and until then reads and listings may still see what
+// was there before. Operations fail with server errors, some after taking
+// effect, so an upload that reports failure may have succeeded. Every such
+// outcome is a decision of the schedule, made with Scheduler.Choose, so
+// upload, compaction and garbage-collection pipelines are explored against
+// them and a failure replays with the same staleness and errors.
+//
+//	st := weftobj.New(s)
+//	st.SetConsistency(weftobj.Consistency{Lag: latency.Uniform(0, time.Second), StaleRead: 0.5, StaleList: 0.5})
+//	st.SetFaults(weftobj.Faults{Errors: 0.05})
+//	err := st.Put(ctx, "logs", "segment-7", data)
+package weftobj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/latency"
+)
+
+// ErrNotFound is returned by Get and Head when the object does not exist,
+// or when a stale read does not see it yet.
+var ErrNotFound = errors.New("weftobj: object not found")
+
+// Error is a server error injected by Faults.
+type Error struct {
+	Op, Bucket, Key string
+
+	// StatusCode is the HTTP status of the error: 500 or 503.
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("weftobj: %s %s/%s: %d %s", e.Op, e.Bucket, e.Key, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Consistency is how stale the store's reads may be. The zero Consistency
+// is strongly consistent.
+type Consistency struct {
+	// Lag draws how long each write takes to become visible everywhere.
+	// Nil means at once.
+	Lag latency.Distribution
+
+	// StaleRead is the probability, from 0 to 1, that Get or Head of an
+	// object written within its lag sees an earlier version instead of
+	// the latest, as the schedule decides; the object may not exist yet,
+	// or still exist after a delete.
+	StaleRead float64
+
+	// StaleList is the same probability for each object in a listing.
+	StaleList float64
+}
+
+// Faults are the failures and latency of the store's operations. The zero
+// Faults never fails and takes no time.
+type Faults struct {
+	// Errors is the probability, from 0 to 1, that an operation fails
+	// with an *Error. A failed Put or Delete has taken effect, or not, as
+	// the schedule decides.
+	Errors float64
+
+	// Latency draws the virtual time each operation takes. Nil means
+	// none.
+	Latency latency.Distribution
+}
+
+// ObjectInfo describes an object.
+type ObjectInfo struct {
+	Key  string
+	Size int
+
+	// ETag identifies the version of the object; every write makes a
+	// new one.
+	ETag string
+}
+
+// Store is a simulated object store. Its zero value is not usable; create
+// stores with New.
+type Store struct {
+	s *weft.Scheduler
+
+	mu          weft.Mutex
+	objects     map[string]map[string]*object
+	consistency Consistency
+	faults      Faults
+
+	// etag numbers the versions written.
+	etag int
+}
+
+// New returns an empty store whose decisions are made by s.
+func New(s *weft.Scheduler) *Store {
+	return &Store{s: s, objects: make(map[string]map[string]*object)}
+}
+
+// SetConsistency sets how stale reads may be, for writes from now on.
+func (st *Store) SetConsistency(c Consistency) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.consistency = c
+}
+
+// SetFaults sets the failures and latency of operations.
+func (st *Store) SetFaults(f Faults) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.faults = f
+}
+
+// object is the versions of an object that a read may see: the first is
+// visible everywhere, and the others, newest last, are still lagging.
+type object struct {
+	versions []*version
+}
+
+// version is what a write left. A deleted version, or the first version of
+// an object never written before, is a tombstone.
+type version struct {
+	data    []byte
+	deleted bool
+	etag    string
+}
+
+// Put stores data as the object key in bucket, replacing any object there.
+func (st *Store) Put(ctx context.Context, bucket, key string, data []byte) error {
+	apply, err := st.begin(ctx, "put", bucket, key)
+	if apply {
+		st.write(bucket, key, &version{data: slices.Clone(data)})
+	}
+	return err
+}
+
+// Delete deletes the object key in bucket. Deleting an object that does
+// not exist succeeds.
+func (st *Store) Delete(ctx context.Context, bucket, key string) error {
+	apply, err := st.begin(ctx, "delete", bucket, key)
+	if apply {
+		st.write(bucket, key, &version{deleted: true})
+	}
+	return err
+}
+
+// Get returns the data of the object key in bucket.
+func (st *Store) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	if _, err := st.begin(ctx, "get", bucket, key); err != nil {
+		return nil, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	v := st.read(bucket, key, st.consistency.StaleRead)
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(v.data), nil
+}
+
+// Head describes the object key in bucket.
+func (st *Store) Head(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	if _, err := st.begin(ctx, "head", bucket, key); err != nil {
+		return ObjectInfo{}, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	v := st.read(bucket, key, st.consistency.StaleRead)
+	if v == nil {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return ObjectInfo{Key: key, Size: len(v.data), ETag: v.etag}, nil
+}
+
+// List describes the objects in bucket whose keys begin with prefix, in
+// order of their keys.
+func (st *Store) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	if _, err := st.begin(ctx, "list", bucket, prefix); err != nil {
+		return nil, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var keys []string
+	for key := range st.objects[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	var infos []ObjectInfo
+	for _, key := range keys {
+		if v := st.read(bucket, key, st.consistency.StaleList); v != nil {
+			infos = append(infos, ObjectInfo{Key: key, Size: len(v.data), ETag: v.etag})
+		}
+	}
+	return infos, nil
+}
+
+// begin starts operation op: it waits for the operation's latency and
+// decides whether it fails. apply reports whether a write takes effect,
+// which a failed one may have.
+func (st *Store) begin(ctx context.Context, op, bucket, key string) (apply bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	st.mu.Lock()
+	f := st.faults
+	st.mu.Unlock()
+	if f.Latency != nil {
+		if d := f.Latency.Draw(st.s); d > 0 {
+			st.s.Sleep(d)
+		}
+	}
+	if !st.s.Chance(f.Errors) {
+		return true, nil
+	}
+	code := http.StatusInternalServerError
+	if st.s.Choose(2) == 1 {
+		code = http.StatusServiceUnavailable
+	}
+	return st.s.Choose(2) == 1, &Error{Op: op, Bucket: bucket, Key: key, StatusCode: code}
+}
+
+// write makes v the latest version of the object key in bucket, visible
+// everywhere once its lag has passed.
+func (st *Store) write(bucket, key string, v *version) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.etag++
+	v.etag = strconv.Itoa(st.etag)
+	objs := st.objects[bucket]
+	if objs == nil {
+		objs = make(map[string]*object)
+		st.objects[bucket] = objs
+	}
+	o := objs[key]
+	if o == nil {
+		o = &object{versions: []*version{{deleted: true}}}
+		objs[key] = o
+	}
+	o.versions = append(o.versions, v)
+
+	lag := st.consistency.Lag
+	if lag == nil {
+		o.settle(v)
+		return
+	}
+	d := lag.Draw(st.s)
+	if d <= 0 {
+		o.settle(v)
+		return
+	}
+	st.s.Go(func(weft.Context) {
+		st.s.Sleep(d)
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		o.settle(v)
+	})
+}
+
+// settle makes v visible everywhere, with any versions before it
+// forgotten, unless a later version has settled first.
+func (o *object) settle(v *version) {
+	if i := slices.Index(o.versions, v); i >= 0 {
+		o.versions = o.versions[i:]
+	}
+}
+
+// read returns the version of the object key in bucket that a read sees,
+// the latest or, with probability stale, an earlier one not yet replaced
+// everywhere, or nil if that is a tombstone. The caller must hold st.mu.
+func (st *Store) read(bucket, key string, stale float64) *version {
+	o := st.objects[bucket][key]
+	if o == nil {
+		return nil
+	}
+	v := o.versions[len(o.versions)-1]
+	if len(o.versions) > 1 && st.s.Chance(stale) {
+		v = o.versions[st.s.Choose(len(o.versions)-1)]
+	}
+	if v.deleted {
+		return nil
+	}
+	return v
+}
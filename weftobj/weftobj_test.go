@@ -0,0 +1,115 @@
+package weftobj
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/latency"
+)
+
+var ctx = context.Background()
+
+// put puts data as key in bucket b, failing the test on error.
+func put(t *testing.T, st *Store, key, data string) {
+	t.Helper()
+	if err := st.Put(ctx, "b", key, []byte(data)); err != nil {
+		t.Fatalf("Put(%s) = %v", key, err)
+	}
+}
+
+// get returns the data of key in bucket b, or "" if it is not found.
+func get(t *testing.T, st *Store, key string) string {
+	t.Helper()
+	data, err := st.Get(ctx, "b", key)
+	if err != nil && err != ErrNotFound {
+		t.Fatalf("Get(%s) = %v", key, err)
+	}
+	return string(data)
+}
+
+// keys returns the keys listed in bucket b under prefix.
+func keys(t *testing.T, st *Store, prefix string) []string {
+	t.Helper()
+	infos, err := st.List(ctx, "b", prefix)
+	if err != nil {
+		t.Fatalf("List(%s) = %v", prefix, err)
+	}
+	var ks []string
+	for _, info := range infos {
+		ks = append(ks, info.Key)
+	}
+	return ks
+}
+
+// TestStore verifies that a strongly consistent store reads its writes.
+func TestStore(t *testing.T) {
+	st := New(weft.NewScheduler(1))
+	put(t, st, "a/1", "one")
+	put(t, st, "a/2", "two")
+	put(t, st, "b/1", "three")
+	put(t, st, "a/1", "uno")
+	if got := get(t, st, "a/1"); got != "uno" {
+		t.Errorf("Get(a/1) = %q, want uno", got)
+	}
+	info, err := st.Head(ctx, "b", "a/2")
+	if err != nil || info.Size != 3 {
+		t.Errorf("Head(a/2) = %+v, %v", info, err)
+	}
+	if got := keys(t, st, "a/"); len(got) != 2 || got[0] != "a/1" || got[1] != "a/2" {
+		t.Errorf("List(a/) = %v", got)
+	}
+	if err := st.Delete(ctx, "b", "a/1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Get(ctx, "b", "a/1"); err != ErrNotFound {
+		t.Errorf("Get() after Delete = %v, want ErrNotFound", err)
+	}
+	if got := keys(t, st, ""); len(got) != 2 {
+		t.Errorf("List() after Delete = %v", got)
+	}
+}
+
+// TestStale verifies that reads and listings see earlier versions while a
+// write lags.
+func TestStale(t *testing.T) {
+	st := New(weft.NewScheduler(1))
+	st.SetConsistency(Consistency{Lag: latency.Constant(time.Hour), StaleRead: 1, StaleList: 1})
+	put(t, st, "k", "v1")
+	if got := get(t, st, "k"); got != "" {
+		t.Errorf("stale Get() of a new object = %q, want not found", got)
+	}
+	if got := keys(t, st, ""); len(got) != 0 {
+		t.Errorf("stale List() = %v, want nothing", got)
+	}
+	put(t, st, "k", "v2")
+	if got := get(t, st, "k"); got != "" && got != "v1" {
+		t.Errorf("stale Get() = %q, want an earlier version", got)
+	}
+
+	st.SetConsistency(Consistency{Lag: latency.Constant(time.Hour)})
+	if got := get(t, st, "k"); got != "v2" {
+		t.Errorf("Get() without StaleRead = %q, want v2", got)
+	}
+}
+
+// TestErrors verifies that injected errors are server errors.
+func TestErrors(t *testing.T) {
+	st := New(weft.NewScheduler(1))
+	st.SetFaults(Faults{Errors: 1})
+	err := st.Put(ctx, "b", "k", []byte("v"))
+	var se *Error
+	if !errors.As(err, &se) || (se.StatusCode != 500 && se.StatusCode != 503) {
+		t.Fatalf("Put() = %v, want a server error", err)
+	}
+	if _, err := st.List(ctx, "b", ""); !errors.As(err, &se) || se.Op != "list" {
+		t.Errorf("List() = %v, want a server error", err)
+	}
+
+	st.SetFaults(Faults{})
+	if got := get(t, st, "k"); got != "" && got != "v" {
+		t.Errorf("Get() after failed Put = %q", got)
+	}
+}
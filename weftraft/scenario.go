@@ -0,0 +1,96 @@
+package weftraft
+
+import (
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// A Scenario disturbs a cluster with partitions and crashes. It runs on a
+// task of its own, started by Cluster.Run.
+type Scenario func(c *Cluster)
+
+// Run runs each scenario on a task of its own.
+func (c *Cluster) Run(scenarios ...Scenario) {
+	for _, sc := range scenarios {
+		c.s.Go(func(weft.Context) { sc(c) })
+	}
+}
+
+// others returns the members other than name.
+func (c *Cluster) others(name string) []string {
+	var rest []string
+	for _, n := range c.names {
+		if n != name {
+			rest = append(rest, n)
+		}
+	}
+	return rest
+}
+
+// pick returns a member chosen by the schedule.
+func (c *Cluster) pick() string {
+	return c.names[c.s.Choose(len(c.names))]
+}
+
+// IsolateLeader cuts the leader of the moment off from the other members
+// once after has passed, and heals the partition after lasting, so the
+// others elect a new leader while the old one may still think it leads.
+// If there is no leader then, a member chosen by the schedule is isolated.
+func IsolateLeader(after, lasting time.Duration) Scenario {
+	return func(c *Cluster) {
+		c.s.Sleep(after)
+		leader := c.Leader()
+		if leader == "" {
+			leader = c.pick()
+		}
+		c.net.Partition([]string{leader}, c.others(leader)).HealAfter(lasting)
+	}
+}
+
+// SplitMinority splits the members into a minority and a majority, chosen
+// by the schedule, once after has passed, and heals the split after
+// lasting. Only the majority can make progress meanwhile.
+func SplitMinority(after, lasting time.Duration) Scenario {
+	return func(c *Cluster) {
+		c.s.Sleep(after)
+		rest := c.Names()
+		var minority []string
+		for len(minority) < (len(c.names)-1)/2 {
+			i := c.s.Choose(len(rest))
+			minority = append(minority, rest[i])
+			rest = append(rest[:i], rest[i+1:]...)
+		}
+		c.net.Partition(minority, rest).HealAfter(lasting)
+	}
+}
+
+// CrashLeader crashes the leader of the moment once after has passed, and
+// restarts it after down. If there is no leader then, a member chosen by
+// the schedule crashes.
+func CrashLeader(after, down time.Duration) Scenario {
+	return func(c *Cluster) {
+		c.s.Sleep(after)
+		leader := c.Leader()
+		if leader == "" {
+			leader = c.pick()
+		}
+		c.nodes[leader].Crash()
+		c.s.Sleep(down)
+		c.restart(leader)
+	}
+}
+
+// Flap isolates a member chosen by the schedule for each of times periods,
+// healing it before the next: the network flapping a protocol must ride
+// out without losing committed entries.
+func Flap(period time.Duration, times int) Scenario {
+	return func(c *Cluster) {
+		for i := 0; i < times; i++ {
+			name := c.pick()
+			p := c.net.Partition([]string{name}, c.others(name))
+			c.s.Sleep(period)
+			p.Heal()
+		}
+	}
+}
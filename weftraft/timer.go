@@ -0,0 +1,67 @@
+package weftraft
+
+import (
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// timeoutSteps is the number of timeouts, evenly spaced from Min to Max,
+// that a Timer chooses among.
+const timeoutSteps = 16
+
+// Timer is an election timer on virtual time. Each time it is reset it
+// fires once after a timeout the schedule chooses between min and max,
+// unless it is reset or stopped first, so which member times out first is
+// explored rather than left to chance.
+type Timer struct {
+	s        *weft.Scheduler
+	min, max time.Duration
+
+	mu  weft.Mutex
+	gen int
+
+	c weft.Chan[struct{}]
+}
+
+// NewTimer returns a running timer with timeouts from min to max.
+func NewTimer(s *weft.Scheduler, min, max time.Duration) *Timer {
+	t := &Timer{s: s, min: min, max: max, c: weft.MakeChan[struct{}](1)}
+	t.Reset()
+	return t
+}
+
+// C returns the channel the timer fires on.
+func (t *Timer) C() weft.Chan[struct{}] {
+	return t.c
+}
+
+// Reset restarts the timer with a new timeout, discarding a firing not yet
+// received.
+func (t *Timer) Reset() {
+	t.mu.Lock()
+	t.gen++
+	gen := t.gen
+	d := t.min
+	if t.max > t.min {
+		d += (t.max - t.min) * time.Duration(t.s.Choose(timeoutSteps)) / (timeoutSteps - 1)
+	}
+	t.c.TryRecv()
+	t.mu.Unlock()
+	t.s.Go(func(weft.Context) {
+		t.s.Sleep(d)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.gen == gen {
+			t.c.TrySend(struct{}{})
+		}
+	})
+}
+
+// Stop stops the timer.
+func (t *Timer) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gen++
+	t.c.TryRecv()
+}
@@ -0,0 +1,220 @@
+// Package weftraft is a test harness for consensus protocols such as Raft.
+// A Cluster starts each member as a weft.Node on a weftnet network, checks
+// the safety properties every consensus protocol must keep as the members
+// report their progress, and runs canned Scenarios of partitions and
+// crashes against them. Timer gives members election timeouts on virtual
+// time, drawn by the schedule, so split votes and dueling candidates are
+// explored and replayed like any other interleaving.
+//
+//	c := weftraft.NewCluster(s, weftnet.New(s), []string{"a", "b", "c"}, start)
+//	c.Run(weftraft.IsolateLeader(time.Second, 2*time.Second))
+//	s.Sleep(5 * time.Second)
+//	c.Stop()
+//	if err := c.Err(); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// The members report to the cluster with ReportLeader when they win an
+// election and ReportApplied as they apply committed entries to their
+// state machines. See examples/raft for a Raft implementation using it.
+package weftraft
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftnet"
+)
+
+// Entry is a log entry applied to a member's state machine.
+type Entry struct {
+	Index, Term uint64
+	Command     string
+}
+
+// Cluster is a group of members running a consensus protocol. Its zero
+// value is not usable; create clusters with NewCluster.
+type Cluster struct {
+	s     *weft.Scheduler
+	net   *weftnet.Network
+	names []string
+	nodes map[string]*weft.Node
+
+	mu weft.Mutex
+
+	// leaders maps each term to the member elected in it.
+	leaders map[uint64]string
+
+	// applied is the entries each member's state machine has applied, in
+	// the incarnation running, and committed the entries applied by any
+	// member, by index.
+	applied   map[string][]Entry
+	committed map[uint64]Entry
+
+	// err is the first safety violation reported.
+	err error
+
+	// stopped is set once the cluster is stopped, after which no member
+	// restarts.
+	stopped bool
+}
+
+// NewCluster starts a member called by each of names on a host of that
+// name on net, by running start for it as a weft.Node. start runs again
+// whenever the member restarts, and should recover the member's durable
+// state, as a process restarting after a crash would.
+func NewCluster(s *weft.Scheduler, net *weftnet.Network, names []string, start func(c *Cluster, n *weft.Node)) *Cluster {
+	c := &Cluster{
+		s:         s,
+		net:       net,
+		names:     slices.Clone(names),
+		nodes:     make(map[string]*weft.Node),
+		leaders:   make(map[uint64]string),
+		applied:   make(map[string][]Entry),
+		committed: make(map[uint64]Entry),
+	}
+	for _, name := range names {
+		c.nodes[name] = s.StartNode(name, func(n *weft.Node) {
+			c.restarted(n.Name())
+			start(c, n)
+		})
+	}
+	return c
+}
+
+// Names returns the names of the members.
+func (c *Cluster) Names() []string {
+	return slices.Clone(c.names)
+}
+
+// Node returns the node running the member called name.
+func (c *Cluster) Node(name string) *weft.Node {
+	return c.nodes[name]
+}
+
+// Network returns the network the members communicate over.
+func (c *Cluster) Network() *weftnet.Network {
+	return c.net
+}
+
+// Scheduler returns the scheduler the cluster runs on.
+func (c *Cluster) Scheduler() *weft.Scheduler {
+	return c.s
+}
+
+// Stop crashes every member, ending their tasks, and keeps scenarios from
+// restarting them.
+func (c *Cluster) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	for _, name := range c.names {
+		c.nodes[name].Crash()
+	}
+}
+
+// restart restarts the member called name, unless the cluster is stopped.
+func (c *Cluster) restart(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.stopped {
+		c.nodes[name].Restart()
+	}
+}
+
+// restarted forgets the state machine of the member called name, which
+// starts over.
+func (c *Cluster) restarted(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applied[name] = nil
+}
+
+// ReportLeader records that the member called name became leader for
+// term. Two leaders for one term violate election safety.
+func (c *Cluster) ReportLeader(name string, term uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if other, ok := c.leaders[term]; ok && other != name {
+		c.fail(fmt.Errorf("election safety: %s and %s both leaders for term %d", other, name, term))
+		return
+	}
+	c.leaders[term] = name
+}
+
+// ReportApplied records that the member called name applied e to its state
+// machine. Members apply entries in index order, starting from 1 when they
+// start; applying a different entry at an index than another member did
+// violates state machine safety.
+func (c *Cluster) ReportApplied(name string, e Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log := c.applied[name]
+	if want := uint64(len(log)) + 1; e.Index != want {
+		c.fail(fmt.Errorf("log order: %s applied index %d, want %d", name, e.Index, want))
+		return
+	}
+	c.applied[name] = append(log, e)
+	if other, ok := c.committed[e.Index]; ok && other != e {
+		c.fail(fmt.Errorf("state machine safety: %s applied %+v at index %d, another member %+v", name, e, e.Index, other))
+		return
+	}
+	c.committed[e.Index] = e
+}
+
+// fail records err if it is the first violation. The caller must hold
+// c.mu.
+func (c *Cluster) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// Err returns the first safety violation reported, or nil.
+func (c *Cluster) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Applied returns the entries the member called name has applied since it
+// last started.
+func (c *Cluster) Applied(name string) []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.applied[name])
+}
+
+// Committed returns the entries applied by any member, in index order, up
+// to the first index none has applied.
+func (c *Cluster) Committed() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var log []Entry
+	for i := uint64(1); ; i++ {
+		e, ok := c.committed[i]
+		if !ok {
+			return log
+		}
+		log = append(log, e)
+	}
+}
+
+// Leader returns the member elected in the latest term whose leader has
+// not crashed since, or "" if there is none.
+func (c *Cluster) Leader() string {
+	c.mu.Lock()
+	var latest uint64
+	var leader string
+	for term, name := range c.leaders {
+		if term > latest {
+			latest, leader = term, name
+		}
+	}
+	c.mu.Unlock()
+	if leader == "" || c.nodes[leader].Crashed() {
+		return ""
+	}
+	return leader
+}
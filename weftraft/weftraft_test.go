@@ -0,0 +1,69 @@
+package weftraft
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftnet"
+)
+
+// idle returns a cluster whose members do nothing, for reporting to by
+// hand.
+func idle(s *weft.Scheduler) *Cluster {
+	return NewCluster(s, weftnet.New(s), []string{"a", "b", "c"}, func(*Cluster, *weft.Node) {})
+}
+
+// TestElectionSafety verifies that two leaders in one term are reported.
+func TestElectionSafety(t *testing.T) {
+	c := idle(weft.NewScheduler(1))
+	c.ReportLeader("a", 1)
+	c.ReportLeader("b", 2)
+	if c.Err() != nil || c.Leader() != "b" {
+		t.Fatalf("Err() = %v, Leader() = %q; want nil, b", c.Err(), c.Leader())
+	}
+	c.ReportLeader("c", 2)
+	if err := c.Err(); err == nil || !strings.Contains(err.Error(), "election safety") {
+		t.Errorf("Err() = %v, want election safety violation", err)
+	}
+}
+
+// TestStateMachineSafety verifies that members applying different entries
+// at one index, or applying out of order, are reported.
+func TestStateMachineSafety(t *testing.T) {
+	c := idle(weft.NewScheduler(1))
+	c.ReportApplied("a", Entry{Index: 1, Term: 1, Command: "x"})
+	c.ReportApplied("b", Entry{Index: 1, Term: 1, Command: "x"})
+	if err := c.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if got := c.Committed(); len(got) != 1 || got[0].Command != "x" {
+		t.Errorf("Committed() = %v", got)
+	}
+	c.ReportApplied("c", Entry{Index: 1, Term: 2, Command: "y"})
+	if err := c.Err(); err == nil || !strings.Contains(err.Error(), "state machine safety") {
+		t.Errorf("Err() = %v, want state machine safety violation", err)
+	}
+
+	c = idle(weft.NewScheduler(1))
+	c.ReportApplied("a", Entry{Index: 2, Term: 1})
+	if err := c.Err(); err == nil || !strings.Contains(err.Error(), "log order") {
+		t.Errorf("Err() = %v, want log order violation", err)
+	}
+}
+
+// TestTimer verifies that a timer fires once per reset, and not after it
+// is stopped.
+func TestTimer(t *testing.T) {
+	s := weft.NewScheduler(1)
+	tm := NewTimer(s, time.Millisecond, 2*time.Millisecond)
+	tm.C().Recv()
+	tm.Stop()
+	s.Sleep(10 * time.Millisecond)
+	if _, ok := tm.C().TryRecv(); ok {
+		t.Error("stopped timer fired")
+	}
+	tm.Reset()
+	tm.C().Recv()
+}
@@ -0,0 +1,201 @@
+package weftsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+)
+
+// connector opens connections to a server.
+type connector struct{ srv *Server }
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	srv := c.srv
+	srv.delay("connect")
+	srv.mu.Lock()
+	slots, limited := srv.slots, srv.maxConns > 0
+	srv.mu.Unlock()
+	if limited {
+		if ctx.Done() == nil {
+			slots.Send(struct{}{})
+		} else if weft.Select(weft.OnSend(slots, struct{}{}), weft.OnRecv(weftcontext.Done(ctx))) == 1 {
+			return nil, ctx.Err()
+		}
+	}
+
+	srv.mu.Lock()
+	err := srv.fault("connect", "")
+	if err == nil {
+		srv.nextConn++
+	}
+	id := srv.nextConn
+	srv.mu.Unlock()
+	if err != nil {
+		if limited {
+			slots.TryRecv()
+		}
+		return nil, err
+	}
+	return &conn{srv: srv, id: id, slots: slots, limited: limited}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return drv{}
+}
+
+// drv is the driver of connectors, which cannot open servers by name.
+type drv struct{}
+
+func (drv) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("weftsql: open databases with Server.Open")
+}
+
+// conn is a connection to a server. database/sql uses a connection from
+// one goroutine at a time.
+type conn struct {
+	srv *Server
+	id  int
+
+	slots   weft.Chan[struct{}]
+	limited bool
+
+	inTx, bad, closed bool
+}
+
+// do sends q on c as operation op.
+func (c *conn) do(ctx context.Context, op string, q Query) (*Result, error) {
+	q.Conn, q.InTx = c.id, c.inTx
+	res, err := c.srv.do(ctx, op, q)
+	if errors.Is(err, driver.ErrBadConn) {
+		c.bad = true
+	}
+	if res == nil && err == nil {
+		res = &Result{}
+	}
+	return res, err
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	if !c.closed {
+		c.closed = true
+		if c.limited {
+			c.slots.TryRecv()
+		}
+	}
+	return nil
+}
+
+// IsValid reports whether database/sql may reuse the connection.
+func (c *conn) IsValid() bool {
+	return !c.bad
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.do(ctx, "begin", Query{SQL: "BEGIN"}); err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return tx{c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.do(ctx, "exec", Query{SQL: query, Args: args})
+	if err != nil {
+		return nil, err
+	}
+	return result{res}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.do(ctx, "query", Query{SQL: query, Args: args})
+	if err != nil {
+		return nil, err
+	}
+	return &rows{res: res}, nil
+}
+
+// tx is a transaction on a connection. Whether it commits or rolls back,
+// the transaction is over.
+type tx struct{ c *conn }
+
+func (t tx) Commit() error {
+	defer func() { t.c.inTx = false }()
+	_, err := t.c.do(context.Background(), "commit", Query{SQL: "COMMIT"})
+	return err
+}
+
+func (t tx) Rollback() error {
+	defer func() { t.c.inTx = false }()
+	_, err := t.c.do(context.Background(), "rollback", Query{SQL: "ROLLBACK"})
+	return err
+}
+
+// stmt is a prepared statement, sent in full each time it is executed.
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, s.query, args)
+}
+
+// named returns args as positional named values.
+func named(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nv
+}
+
+// result is the outcome of Exec.
+type result struct{ res *Result }
+
+func (r result) LastInsertId() (int64, error) { return r.res.LastInsertID, nil }
+func (r result) RowsAffected() (int64, error) { return r.res.RowsAffected, nil }
+
+// rows iterates over the rows of a query's result.
+type rows struct {
+	res  *Result
+	next int
+}
+
+func (r *rows) Columns() []string { return r.res.Columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next == len(r.res.Rows) {
+		return io.EOF
+	}
+	copy(dest, r.res.Rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,130 @@
+package weftsql
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInjected is the error of a Fault that gives none.
+var ErrInjected = errors.New("injected database error")
+
+// A Fault makes matching operations fail.
+type Fault struct {
+	// Op is the operation to fail: "connect", "query", "exec", "begin",
+	// "commit" or "rollback". Empty matches every operation.
+	Op string
+
+	// Query is a prefix of the statements to fail, compared as in
+	// Server.Handle. Empty matches every statement, and connecting.
+	Query string
+
+	// Err is the error the operation fails with. Nil means ErrInjected;
+	// driver.ErrBadConn makes database/sql retry on another connection.
+	Err error
+
+	// Rate is the probability, from 0 to 1, that a matching operation
+	// fails. Zero means every matching operation fails.
+	Rate float64
+
+	// Count is the number of operations to fail before the fault is
+	// removed. Zero means no limit.
+	Count int
+}
+
+// injected is a fault in effect.
+type injected struct {
+	Fault
+	failed int
+}
+
+// Inject makes operations matching f fail from now until remove is
+// called.
+//
+//	srv.Inject(weftsql.Fault{Op: "commit", Err: errSerialization, Count: 1})
+func (srv *Server) Inject(f Fault) (remove func()) {
+	in := &injected{Fault: f}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.faults = append(srv.faults, in)
+	return func() {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		srv.remove(in)
+	}
+}
+
+// remove removes in from the faults in effect. The caller must hold
+// srv.mu.
+func (srv *Server) remove(in *injected) {
+	for i, f := range srv.faults {
+		if f == in {
+			srv.faults = append(srv.faults[:i], srv.faults[i+1:]...)
+			return
+		}
+	}
+}
+
+// fault returns the error of the first fault in effect that fails
+// operation op on query, or nil. The caller must hold srv.mu.
+func (srv *Server) fault(op, query string) error {
+	for _, in := range srv.faults {
+		if in.Op != "" && in.Op != op {
+			continue
+		}
+		if in.Query != "" && !strings.HasPrefix(normalize(query), normalize(in.Query)) {
+			continue
+		}
+		if in.Rate > 0 && !srv.s.Chance(in.Rate) {
+			continue
+		}
+		in.failed++
+		if in.Count > 0 && in.failed >= in.Count {
+			srv.remove(in)
+		}
+		if in.Err == nil {
+			return ErrInjected
+		}
+		return in.Err
+	}
+	return nil
+}
+
+// Latency is the range of virtual time an operation takes.
+type Latency struct {
+	Min, Max time.Duration
+}
+
+// latencySteps is the number of durations a latency is drawn from.
+const latencySteps = 16
+
+// SetLatency makes each operation op, named as in Fault, take from l.Min to
+// l.Max of virtual time, as the schedule decides; an empty op sets the
+// latency of operations without one of their own.
+//
+//	srv.SetLatency("query", weftsql.Latency{Min: time.Millisecond, Max: 50 * time.Millisecond})
+func (srv *Server) SetLatency(op string, l Latency) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.latency == nil {
+		srv.latency = make(map[string]Latency)
+	}
+	srv.latency[op] = l
+}
+
+// delay waits for the latency of operation op.
+func (srv *Server) delay(op string) {
+	srv.mu.Lock()
+	l, ok := srv.latency[op]
+	if !ok {
+		l = srv.latency[""]
+	}
+	d := l.Min
+	if l.Max > l.Min {
+		d += (l.Max - l.Min) * time.Duration(srv.s.Choose(latencySteps)) / (latencySteps - 1)
+	}
+	srv.mu.Unlock()
+	if d > 0 {
+		srv.s.Sleep(d)
+	}
+}
@@ -0,0 +1,162 @@
+// Package weftsql is an in-memory database/sql driver for weft tests. A
+// Server stands in for the database: handlers registered on it answer the
+// statements the code under test sends, connections to it are limited like
+// a real server's, and its latencies and failures are decisions of the
+// schedule. Connection-pool exhaustion, statements racing with
+// transactions, and retry loops around failed commits become explorable:
+//
+//	srv := weftsql.New(s)
+//	srv.Handle("SELECT balance FROM accounts", func(ctx context.Context, q weftsql.Query) (*weftsql.Result, error) {
+//		return &weftsql.Result{Columns: []string{"balance"}, Rows: [][]driver.Value{{balance}}}, nil
+//	})
+//	srv.Inject(weftsql.Fault{Op: "commit", Err: errSerialization, Rate: 0.2})
+//	db := srv.Open()
+//
+// Handlers run on the task that sent the statement, so they should guard
+// state shared between connections with weft primitives. Whether a
+// probabilistic fault happens and how long an operation takes are decided
+// with Scheduler.Choose, so a failing run replays with the same faults.
+//
+// The waits inside database/sql's own pool, when a *sql.DB reaches
+// SetMaxOpenConns, are on channels the scheduler does not see; limit
+// connections with Server.SetMaxConns to have waiting for a connection
+// explored.
+package weftsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/mziter/weft"
+)
+
+// Server is a simulated database server. Its zero value is not usable;
+// create servers with New.
+type Server struct {
+	s *weft.Scheduler
+
+	mu       weft.Mutex
+	handlers map[string]Handler
+
+	// slots holds a value for each open connection, when connections are
+	// limited.
+	slots    weft.Chan[struct{}]
+	maxConns int
+	nextConn int
+
+	faults  []*injected
+	latency map[string]Latency
+}
+
+// New returns a server without handlers whose decisions are made by s.
+func New(s *weft.Scheduler) *Server {
+	return &Server{s: s, handlers: make(map[string]Handler)}
+}
+
+// Query is a statement sent to the server.
+type Query struct {
+	// SQL is the statement's text. Transactions send "BEGIN", "COMMIT"
+	// and "ROLLBACK".
+	SQL string
+
+	// Args are the statement's arguments.
+	Args []driver.NamedValue
+
+	// Conn identifies the connection the statement was sent on, numbered
+	// from 1 in the order connections were opened.
+	Conn int
+
+	// InTx reports whether the statement is part of a transaction.
+	InTx bool
+}
+
+// Result is the answer to a statement. Queries return its Columns and
+// Rows; Exec returns its LastInsertID and RowsAffected.
+type Result struct {
+	Columns []string
+	Rows    [][]driver.Value
+
+	LastInsertID int64
+	RowsAffected int64
+}
+
+// A Handler answers the statements it is registered for. A nil Result is
+// an empty one. Returning driver.ErrBadConn makes database/sql discard the
+// connection and retry on another, as it does for a real server.
+type Handler func(ctx context.Context, q Query) (*Result, error)
+
+// Handle registers h for the statements that start with prefix, compared
+// case-insensitively with runs of white space taken as one space. The
+// handler with the longest matching prefix answers a statement. Without a
+// handler, statements fail, except those of transactions, which succeed.
+func (srv *Server) Handle(prefix string, h Handler) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.handlers[normalize(prefix)] = h
+}
+
+// handler returns the handler for query, or nil. The caller must hold
+// srv.mu.
+func (srv *Server) handler(query string) Handler {
+	query = normalize(query)
+	var best string
+	var h Handler
+	for prefix, ph := range srv.handlers {
+		if strings.HasPrefix(query, prefix) && (h == nil || len(prefix) > len(best)) {
+			best, h = prefix, ph
+		}
+	}
+	return h
+}
+
+// normalize returns query lower-cased with its white space collapsed.
+func normalize(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// SetMaxConns limits the server to n open connections; further connection
+// attempts wait, on the scheduler, until one closes or their context is
+// done. Zero means no limit. Call it before the first connection is opened.
+func (srv *Server) SetMaxConns(n int) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.maxConns = n
+	if n > 0 {
+		srv.slots = weft.MakeChan[struct{}](n)
+	}
+}
+
+// Open returns a *sql.DB whose connections are to srv.
+func (srv *Server) Open() *sql.DB {
+	return sql.OpenDB(srv.Connector())
+}
+
+// Connector returns a driver.Connector for srv, for sql.OpenDB.
+func (srv *Server) Connector() driver.Connector {
+	return connector{srv}
+}
+
+// do sends q to the server as operation op, named as in Fault, and returns
+// its result.
+func (srv *Server) do(ctx context.Context, op string, q Query) (*Result, error) {
+	srv.delay(op)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	srv.mu.Lock()
+	err := srv.fault(op, q.SQL)
+	h := srv.handler(q.SQL)
+	srv.mu.Unlock()
+	switch {
+	case err != nil:
+		return nil, err
+	case h != nil:
+		return h(ctx, q)
+	case op == "begin" || op == "commit" || op == "rollback":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("weftsql: no handler for %q", q.SQL)
+}
@@ -0,0 +1,151 @@
+package weftsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/weftcontext"
+	"github.com/mziter/weft/wefttest"
+)
+
+// TestHandle verifies that statements are answered by the handler with
+// the longest matching prefix and fail without one.
+func TestHandle(t *testing.T) {
+	srv := New(weft.NewScheduler(1))
+	srv.Handle("select", func(ctx context.Context, q Query) (*Result, error) {
+		return &Result{Columns: []string{"n"}, Rows: [][]driver.Value{{int64(1)}}}, nil
+	})
+	srv.Handle("SELECT name  FROM users", func(ctx context.Context, q Query) (*Result, error) {
+		return &Result{Columns: []string{"name"}, Rows: [][]driver.Value{{q.Args[0].Value}}}, nil
+	})
+	srv.Handle("UPDATE", func(ctx context.Context, q Query) (*Result, error) {
+		return &Result{RowsAffected: 3}, nil
+	})
+	db := srv.Open()
+	defer db.Close()
+
+	var n int
+	if err := db.QueryRow("SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Errorf("SELECT 1 = %d, %v, want 1", n, err)
+	}
+	var name string
+	if err := db.QueryRow("select name from users where id = ?", "ada").Scan(&name); err != nil || name != "ada" {
+		t.Errorf("SELECT name = %q, %v, want ada", name, err)
+	}
+	res, err := db.Exec("UPDATE users SET active = true")
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if rows, _ := res.RowsAffected(); rows != 3 {
+		t.Errorf("RowsAffected() = %d, want 3", rows)
+	}
+	if _, err := db.Exec("DELETE FROM users"); err == nil {
+		t.Error("Exec() without a handler succeeded")
+	}
+}
+
+// TestTxRetry verifies that an application retrying failed commits
+// eventually commits, whichever commits the schedule fails.
+func TestTxRetry(t *testing.T) {
+	errConflict := errors.New("serialization failure")
+	wefttest.Explore(t, 20, func(s *weft.Scheduler) {
+		srv := New(s)
+		var (
+			mu        weft.Mutex
+			committed int
+		)
+		srv.Handle("INSERT", func(ctx context.Context, q Query) (*Result, error) {
+			if !q.InTx {
+				t.Error("INSERT not in transaction")
+			}
+			return nil, nil
+		})
+		srv.Handle("COMMIT", func(ctx context.Context, q Query) (*Result, error) {
+			mu.Lock()
+			committed++
+			mu.Unlock()
+			return nil, nil
+		})
+		srv.Inject(Fault{Op: "commit", Err: errConflict, Rate: 0.5})
+		srv.SetLatency("", Latency{Max: time.Millisecond})
+		db := srv.Open()
+		defer db.Close()
+
+		for attempt := 0; ; attempt++ {
+			if attempt == 100 {
+				t.Fatal("transaction never committed")
+			}
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.Exec("INSERT INTO log VALUES (?)", attempt); err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Commit(); err == nil {
+				break
+			} else if !errors.Is(err, errConflict) {
+				t.Fatalf("Commit() error = %v, want %v", err, errConflict)
+			}
+		}
+		if committed != 1 {
+			t.Errorf("commits = %d, want 1", committed)
+		}
+	})
+}
+
+// TestMaxConns verifies that connecting to a full server waits until a
+// connection closes, or the context is done.
+func TestMaxConns(t *testing.T) {
+	weft.RunDefault(t, func() {
+		srv := New(weft.NewScheduler(1))
+		srv.SetMaxConns(1)
+		db := srv.Open()
+		defer db.Close()
+
+		c1, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := weftcontext.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := db.Conn(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Conn() on full server error = %v, want context.DeadlineExceeded", err)
+		}
+
+		db.SetMaxIdleConns(0)
+		c1.Close()
+		c2, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("Conn() after close error = %v", err)
+		}
+		c2.Close()
+	})
+}
+
+// TestBadConn verifies that database/sql retries a statement failing with
+// driver.ErrBadConn on a new connection.
+func TestBadConn(t *testing.T) {
+	srv := New(weft.NewScheduler(1))
+	var conns []int
+	srv.Handle("SELECT", func(ctx context.Context, q Query) (*Result, error) {
+		conns = append(conns, q.Conn)
+		return nil, nil
+	})
+	srv.Inject(Fault{Query: "select", Err: driver.ErrBadConn, Count: 1})
+	db := srv.Open()
+	defer db.Close()
+
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	rows.Close()
+	if len(conns) != 1 || conns[0] != 2 {
+		t.Errorf("answered on connections %v, want [2]", conns)
+	}
+}
@@ -0,0 +1,129 @@
+package wefttest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Values of EnvAnnotate.
+const (
+	AnnotatePlain  = "plain"
+	AnnotateGitHub = "github"
+)
+
+// An Annotation locates a failing schedule for a CI system, which shows it
+// against the failing line with the command that replays it.
+type Annotation struct {
+	// Pos is the failure's source position, as "file:line", or "" if it
+	// is not known.
+	Pos     string
+	Test    string
+	Seed    uint64
+	Message string
+
+	// Replay is a command that reproduces the failure.
+	Replay string
+}
+
+// Format returns the annotation in format, AnnotatePlain or AnnotateGitHub.
+// The plain format is the "file:line: message (seed=N)" that editors and
+// most CI systems recognize, followed by the replay command on a second
+// line. The GitHub format is an Actions workflow command, with file names
+// relative to GITHUB_WORKSPACE when it is set.
+func (a Annotation) Format(format string) string {
+	file, line := a.Pos, ""
+	if i := strings.LastIndexByte(a.Pos, ':'); i >= 0 {
+		file, line = a.Pos[:i], a.Pos[i+1:]
+	}
+	if format != AnnotateGitHub {
+		pos := a.Pos
+		if pos == "" {
+			pos = a.Test
+		}
+		s := fmt.Sprintf("%s: %s (seed=%d)", pos, a.Message, a.Seed)
+		if a.Replay != "" {
+			s += "\n\treplay: " + a.Replay
+		}
+		return s
+	}
+
+	var props []string
+	if file != "" {
+		if ws := os.Getenv("GITHUB_WORKSPACE"); ws != "" {
+			if rel, err := filepath.Rel(ws, file); err == nil && !strings.HasPrefix(rel, "..") {
+				file = filepath.ToSlash(rel)
+			}
+		}
+		props = append(props, "file="+escapeProperty(file), "line="+escapeProperty(line))
+	}
+	props = append(props, "title="+escapeProperty(fmt.Sprintf("weft: %s seed %d", a.Test, a.Seed)))
+	msg := a.Message
+	if a.Replay != "" {
+		msg += "\nreplay: " + a.Replay
+	}
+	return "::error " + strings.Join(props, ",") + "::" + escapeData(msg)
+}
+
+// escapeData escapes s for the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes s for a property of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// annotate prints an annotation for the failing schedule ev to standard
+// output, where CI systems scan for them, if EnvAnnotate is set.
+func annotate(test string, sched schedule, ev Event) {
+	format := os.Getenv(EnvAnnotate)
+	if format == "" {
+		return
+	}
+	replay := fmt.Sprintf("%s=%d go test -tags=detsched -run '%s' .", EnvSeed, sched.seed, runPattern(test))
+	if ev.Trace != "" {
+		replay = fmt.Sprintf("weft replay %s", ev.Trace)
+	}
+	a := Annotation{Pos: ev.Location, Test: test, Seed: sched.seed, Message: ev.Failure, Replay: replay}
+	fmt.Println(a.Format(format))
+}
+
+// panicLocation returns the "file:line" that panicked, for use in the
+// deferred function recovering the panic. Frames in the runtime and in weft
+// itself are skipped, so that a failed assertion in a weft helper is
+// placed at its caller. It returns "" outside a panic.
+func panicLocation() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	panicking := false
+	for {
+		f, more := frames.Next()
+		switch {
+		case f.Function == "runtime.gopanic":
+			panicking = true
+		case panicking && !strings.HasPrefix(f.Function, "runtime.") && !weftFrame(f.Function):
+			return f.File + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// weftFrame reports whether fn, a qualified function name, belongs to weft
+// rather than to the code under test.
+func weftFrame(fn string) bool {
+	const module = "github.com/mziter/weft"
+	rest, ok := strings.CutPrefix(fn, module)
+	if !ok {
+		return false
+	}
+	// The root package and wefttest are weft's; other packages of the
+	// module, such as the examples, are code under test.
+	return strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "/wefttest.") || strings.HasPrefix(rest, "/internal/")
+}
@@ -0,0 +1,56 @@
+package wefttest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/trace"
+)
+
+// coverage accumulates the interleaving coverage of every schedule run by
+// the test process while EnvCoverDir is set.
+var coverage struct {
+	sync.Mutex
+	c *trace.Coverage
+}
+
+// addCoverage records the interleavings of the schedule run by s.
+func addCoverage(s *weft.Scheduler) {
+	if os.Getenv(EnvCoverDir) == "" {
+		return
+	}
+	coverage.Lock()
+	defer coverage.Unlock()
+	if coverage.c == nil {
+		coverage.c = trace.NewCoverage()
+	}
+	coverage.c.Add(&trace.Trace{Events: s.Events()})
+}
+
+// writeCoverage writes the coverage gathered so far to a file named after
+// the process in EnvCoverDir, so that packages tested in parallel and
+// separate CI shards never write the same file.
+func writeCoverage(t testing.TB) {
+	dir := os.Getenv(EnvCoverDir)
+	if dir == "" {
+		return
+	}
+	coverage.Lock()
+	defer coverage.Unlock()
+	if coverage.c == nil {
+		return
+	}
+	host, _ := os.Hostname()
+	name := filepath.Join(dir, fmt.Sprintf("cover-%s-%d.json", host, os.Getpid()))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Logf("wefttest: writing coverage: %v", err)
+		return
+	}
+	if err := coverage.c.WriteFile(name); err != nil {
+		t.Logf("wefttest: writing coverage: %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package wefttest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/trace"
+)
+
+// Environment variables that override exploration, used by the weft
+// command and for reproducing failures by hand.
+const (
+	// EnvRuns overrides the number of schedules Explore runs.
+	EnvRuns = "WEFT_RUNS"
+
+	// EnvSeed restricts exploration to a single seed.
+	EnvSeed = "WEFT_SEED"
+
+	// EnvTrace names a trace file to replay instead of exploring.
+	EnvTrace = "WEFT_TRACE"
+
+	// EnvDecisions, set to N with EnvTrace, replays only the first N
+	// decisions of the trace and makes the rest from its seed, or
+	// interactively with EnvInteractive: the run rewound to a point
+	// before its failure.
+	EnvDecisions = "WEFT_DECISIONS"
+
+	// EnvTraceDir names a directory that receives a trace file for every
+	// failing schedule.
+	EnvTraceDir = "WEFT_TRACE_DIR"
+
+	// EnvTraceAll, when non-empty, makes EnvTraceDir receive the traces
+	// of passing schedules too, for comparison with failing ones.
+	EnvTraceAll = "WEFT_TRACE_ALL"
+
+	// EnvTraceStream, when non-empty, makes each schedule stream its
+	// trace, compressed, to EnvTraceDir as it runs instead of holding its
+	// events in memory, for runs too long to trace otherwise. Traces of
+	// passing schedules are removed unless EnvTraceAll is set.
+	EnvTraceStream = "WEFT_TRACE_STREAM"
+
+	// EnvTraceSample, set to N, makes streamed traces keep only one event
+	// in N, along with every spawn and exit.
+	EnvTraceSample = "WEFT_TRACE_SAMPLE"
+
+	// EnvAnnotate makes each failing schedule write a line locating the
+	// failure for CI systems: "plain" for "file:line: message (seed=N)"
+	// and "github" for a GitHub Actions error annotation.
+	EnvAnnotate = "WEFT_ANNOTATE"
+
+	// EnvCoverDir names a directory that receives the interleaving
+	// coverage of the test process, for merging with weft cover merge.
+	EnvCoverDir = "WEFT_COVER_DIR"
+
+	// EnvParallel, set to N, makes Explore run N schedules at once, each
+	// on a scheduler of its own, which weft's package-level functions use
+	// in its tasks; the build function must then share nothing between
+	// runs.
+	EnvParallel = "WEFT_PARALLEL"
+
+	// EnvMemory, set to N, bounds the memory the trace of each schedule
+	// takes to about N megabytes, moving older events to a temporary file
+	// beyond it, so that long explorations of big scenarios keep their
+	// traces without exhausting memory.
+	EnvMemory = "WEFT_MEMORY"
+
+	// EnvGuided, when non-empty, makes Explore derive most schedules from
+	// earlier ones that covered new interleavings, replaying a prefix of
+	// their decisions and drawing the rest from a fresh seed, so that it
+	// dwells on rarely covered synchronization. A guided schedule is
+	// reproduced from its trace, under EnvTraceDir, not its seed alone.
+	EnvGuided = "WEFT_GUIDED"
+
+	// EnvEvents, when non-empty, makes wefttest write an Event line to
+	// standard output as each schedule starts and ends.
+	EnvEvents = "WEFT_EVENTS"
+
+	// EnvLaunch names a VS Code launch.json file to which each failing
+	// schedule adds a configuration that debugs it under dlv.
+	EnvLaunch = "WEFT_LAUNCH"
+
+	// EnvInteractive makes each schedule ask a person for its scheduling
+	// decisions, over standard input with "stdin" or over a TCP
+	// connection accepted on ADDR with "tcp:ADDR".
+	EnvInteractive = "WEFT_INTERACTIVE"
+
+	// EnvRewindFile names a file to which an interactive session's rewind
+	// command writes the number of decisions to replay, before the test
+	// process exits, so that the weft command can restart the replay from
+	// there with EnvDecisions.
+	EnvRewindFile = "WEFT_REWIND_FILE"
+)
+
+// skipMessage explains how to enable deterministic testing.
+const skipMessage = `
+Deterministic concurrency testing not available.
+For comprehensive concurrency testing that can detect race conditions,
+deadlocks, and other subtle bugs, run with:
+
+    go test -tags=detsched
+
+This enables Weft's deterministic scheduler which explores multiple
+execution orders to find bugs that standard tests might miss.`
+
+// schedule identifies one schedule to run.
+type schedule struct {
+	seed    uint64
+	choices []int
+
+	// run is the position of the schedule among runs, from 1, for
+	// progress events.
+	run, runs int
+}
+
+// override returns the schedules requested through the environment, or nil
+// if exploration should proceed as the test asked.
+func override(t testing.TB) []schedule {
+	t.Helper()
+	if name := os.Getenv(EnvTrace); name != "" {
+		tr, err := trace.ReadFile(name)
+		if err != nil {
+			t.Fatalf("wefttest: %s: %v", EnvTrace, err)
+		}
+		choices := tr.Choices
+		if v := os.Getenv(EnvDecisions); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				t.Fatalf("wefttest: invalid %s %q", EnvDecisions, v)
+			}
+			choices = choices[:min(n, len(choices))]
+		}
+		return []schedule{{seed: tr.Seed, choices: choices}}
+	}
+	if v := os.Getenv(EnvSeed); v != "" {
+		seed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			t.Fatalf("wefttest: invalid %s %q", EnvSeed, v)
+		}
+		return []schedule{{seed: seed}}
+	}
+	return nil
+}
+
+// runsFromEnv returns the number of runs requested through the environment,
+// or runs if none is.
+func runsFromEnv(t testing.TB, runs int) int {
+	t.Helper()
+	v := os.Getenv(EnvRuns)
+	if v == "" {
+		return runs
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		t.Fatalf("wefttest: invalid %s %q", EnvRuns, v)
+	}
+	return n
+}
+
+// parallelFromEnv returns the number of schedules to run at once, as
+// EnvParallel requests, or 1.
+func parallelFromEnv(t testing.TB) int {
+	t.Helper()
+	v := os.Getenv(EnvParallel)
+	if v == "" {
+		return 1
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		t.Fatalf("wefttest: invalid %s %q", EnvParallel, v)
+	}
+	return n
+}
+
+// memoryFromEnv returns the bytes of events a schedule may hold in memory,
+// as EnvMemory requests, or 0 for no bound.
+func memoryFromEnv(t testing.TB) int {
+	t.Helper()
+	v := os.Getenv(EnvMemory)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		t.Fatalf("wefttest: invalid %s %q", EnvMemory, v)
+	}
+	return n << 20
+}
+
+// saveTrace writes the trace of a schedule to the directory named by
+// EnvTraceDir, if set, and returns the file name. An empty failure marks a
+// passing schedule; location, if known, is where it failed. A streamed
+// trace is finished rather than written.
+func saveTrace(t testing.TB, test string, sched schedule, s *weft.Scheduler, stream *traceStream, failure, location string) string {
+	dir := os.Getenv(EnvTraceDir)
+	if dir == "" {
+		return ""
+	}
+	if stream != nil {
+		return stream.finish(t, s, failure, location)
+	}
+	tr := &trace.Trace{
+		Test:     test,
+		Seed:     sched.seed,
+		Choices:  s.Choices(),
+		Failure:  failure,
+		Location: location,
+		Events:   s.Events(),
+	}
+	name := traceName(dir, test, sched.seed)
+	if err := tr.WriteFile(name); err != nil {
+		t.Logf("wefttest: writing trace: %v", err)
+		return ""
+	}
+	return name
+}
+
+// traceName returns the name of the trace file of test's schedule seed in
+// dir.
+func traceName(dir, test string, seed uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-seed_%d"+trace.Ext, strings.ReplaceAll(test, "/", "_"), seed))
+}
+
+// logLive logs where each task that had not finished was left when a
+// schedule failed, and what spawned it, such as "task 2 blocked on chan 1
+// at queue.go:42, spawned by test".
+func logLive(t testing.TB, s *weft.Scheduler) {
+	if live := liveTasks(s); live != "" {
+		t.Log(strings.TrimSuffix(live, "\n"))
+	}
+}
+
+// liveTasks returns the lines logLive logs, each ended by a newline, or ""
+// if every task finished.
+func liveTasks(s *weft.Scheduler) string {
+	tr := &trace.Trace{Events: s.Events()}
+	live := tr.Live()
+	if len(live) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("unfinished tasks:\n")
+	for _, ev := range live {
+		b.WriteString("\t" + tr.Describe(ev) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,245 @@
+package wefttest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/trace"
+)
+
+// TestOverride verifies that WEFT_TRACE takes precedence over WEFT_SEED,
+// that WEFT_DECISIONS truncates its choices, and that neither set leaves
+// exploration alone.
+func TestOverride(t *testing.T) {
+	if got := override(t); got != nil {
+		t.Errorf("override with empty environment = %v, want nil", got)
+	}
+
+	t.Setenv(EnvSeed, "7")
+	if got, want := override(t), []schedule{{seed: 7}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("override with %s = %v, want %v", EnvSeed, got, want)
+	}
+
+	name := filepath.Join(t.TempDir(), "trace"+trace.Ext)
+	if err := (&trace.Trace{Test: "TestX", Seed: 3, Choices: []int{1, 0}}).WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvTrace, name)
+	if got, want := override(t), []schedule{{seed: 3, choices: []int{1, 0}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("override with %s = %v, want %v", EnvTrace, got, want)
+	}
+
+	t.Setenv(EnvDecisions, "1")
+	if got, want := override(t), []schedule{{seed: 3, choices: []int{1}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("override with %s=1 = %v, want %v", EnvDecisions, got, want)
+	}
+}
+
+// TestRunsFromEnv verifies that WEFT_RUNS overrides the requested runs.
+func TestRunsFromEnv(t *testing.T) {
+	if got := runsFromEnv(t, 10); got != 10 {
+		t.Errorf("runsFromEnv without %s = %d, want 10", EnvRuns, got)
+	}
+	t.Setenv(EnvRuns, "250")
+	if got := runsFromEnv(t, 10); got != 250 {
+		t.Errorf("runsFromEnv with %s=250 = %d, want 250", EnvRuns, got)
+	}
+}
+
+// TestSaveTrace verifies that failing schedules are written to
+// WEFT_TRACE_DIR in the trace format.
+func TestSaveTrace(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvTraceDir, dir)
+	saveTrace(t, "TestQueue/drain", schedule{seed: 9}, weft.NewScheduler(9), nil, "test failed", "")
+
+	name := filepath.Join(dir, "TestQueue_drain-seed_9"+trace.Ext)
+	if _, err := os.Stat(name); err != nil {
+		t.Fatalf("trace not written: %v", err)
+	}
+	tr, err := trace.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Test != "TestQueue/drain" || tr.Seed != 9 || tr.Failure != "test failed" {
+		t.Errorf("unexpected trace %+v", tr)
+	}
+}
+
+// TestStreamTrace verifies that streamed traces appear under their final
+// name only once finished, and that discarded ones leave nothing behind.
+func TestStreamTrace(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvTraceDir, dir)
+	t.Setenv(EnvTraceStream, "1")
+	t.Setenv(EnvTraceSample, "10")
+
+	s := weft.NewScheduler(9)
+	stream := startStream(t, "TestQueue", schedule{seed: 9}, s)
+	if stream == nil {
+		t.Fatal("startStream returned nil")
+	}
+	name := saveTrace(t, "TestQueue", schedule{seed: 9}, s, stream, "deadlock", "")
+	if want := filepath.Join(dir, "TestQueue-seed_9"+trace.Ext); name != want {
+		t.Errorf("saveTrace = %q, want %q", name, want)
+	}
+	tr, err := trace.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Test != "TestQueue" || tr.Seed != 9 || tr.Failure != "deadlock" || tr.Sampled != 10 {
+		t.Errorf("unexpected trace %+v", tr)
+	}
+
+	startStream(t, "TestPass", schedule{seed: 3}, weft.NewScheduler(3)).discard()
+	if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 1 {
+		t.Errorf("files after discard = %v, want only %s", names, name)
+	}
+}
+
+// TestWriteCoverage verifies that schedules run while WEFT_COVER_DIR is set
+// are written to a coverage file in that directory.
+func TestWriteCoverage(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvCoverDir, dir)
+	addCoverage(weft.NewScheduler(1))
+	addCoverage(weft.NewScheduler(2))
+	writeCoverage(t)
+
+	names, err := filepath.Glob(filepath.Join(dir, "cover-*.json"))
+	if err != nil || len(names) != 1 {
+		t.Fatalf("coverage files = %v, %v; want one", names, err)
+	}
+	c, err := trace.ReadCoverage(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Runs < 2 {
+		t.Errorf("coverage runs = %d, want at least 2", c.Runs)
+	}
+}
+
+// TestParseEvent verifies that event lines survive the round trip through
+// test output, including the indentation test2json preserves.
+func TestParseEvent(t *testing.T) {
+	want := Event{Action: ActionFail, Test: "TestQueue/seed_9", Seed: 9, Run: 2, Runs: 5, Failure: "test failed"}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := ParseEvent("    " + EventPrefix + string(data) + "\n")
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseEvent = %+v, %v; want %+v, true", got, ok, want)
+	}
+	if _, ok := ParseEvent("=== RUN   TestQueue"); ok {
+		t.Error("ParseEvent accepted a test2json framing line")
+	}
+}
+
+// TestWriteLaunch verifies that failing schedules add debug configurations
+// to WEFT_LAUNCH without disturbing existing ones or duplicating their own.
+func TestWriteLaunch(t *testing.T) {
+	name := filepath.Join(t.TempDir(), ".vscode", "launch.json")
+	t.Setenv(EnvLaunch, name)
+	writeLaunch(t, "TestQueue", schedule{seed: 9}, "")
+	writeLaunch(t, "TestQueue", schedule{seed: 9}, "")
+	writeLaunch(t, "TestQueue/drain", schedule{seed: 4}, "trace.json")
+
+	var file struct {
+		Version        string
+		Configurations []struct {
+			Name       string
+			Program    string
+			BuildFlags string
+			Args       []string
+			Env        map[string]string
+		}
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Configurations) != 2 {
+		t.Fatalf("got %d configurations, want 2:\n%s", len(file.Configurations), data)
+	}
+	seed, replay := file.Configurations[0], file.Configurations[1]
+	if seed.Name != "weft: TestQueue seed 9" || seed.Env[EnvSeed] != "9" || seed.BuildFlags != "-tags=detsched" {
+		t.Errorf("unexpected seed configuration %+v", seed)
+	}
+	if got, want := replay.Args[1], "^TestQueue$/^drain$"; got != want {
+		t.Errorf("run pattern = %q, want %q", got, want)
+	}
+	if !filepath.IsAbs(replay.Env[EnvTrace]) {
+		t.Errorf("trace %q is not absolute", replay.Env[EnvTrace])
+	}
+
+	commented := []byte("{\n\t// comments make this JSONC\n}\n")
+	if err := os.WriteFile(name, commented, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeLaunch(t, "TestQueue", schedule{seed: 9}, "")
+	if data, _ := os.ReadFile(name); string(data) != string(commented) {
+		t.Errorf("launch file with comments was rewritten:\n%s", data)
+	}
+}
+
+// TestAnnotationFormat verifies both annotation formats, including the
+// escaping GitHub workflow commands require.
+func TestAnnotationFormat(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", "/src")
+	a := Annotation{
+		Pos:     "/src/queue/queue_test.go:42",
+		Test:    "TestQueue/drain",
+		Seed:    7,
+		Message: "panic: 100% lost\nitem 3",
+		Replay:  "WEFT_SEED=7 go test -tags=detsched -run '^TestQueue$' .",
+	}
+	want := "/src/queue/queue_test.go:42: panic: 100% lost\nitem 3 (seed=7)\n\treplay: WEFT_SEED=7 go test -tags=detsched -run '^TestQueue$' ."
+	if got := a.Format(AnnotatePlain); got != want {
+		t.Errorf("plain annotation:\ngot  %q\nwant %q", got, want)
+	}
+	want = "::error file=queue/queue_test.go,line=42,title=weft%3A TestQueue/drain seed 7::panic: 100%25 lost%0Aitem 3%0Areplay: WEFT_SEED=7 go test -tags=detsched -run '^TestQueue$' ."
+	if got := a.Format(AnnotateGitHub); got != want {
+		t.Errorf("github annotation:\ngot  %q\nwant %q", got, want)
+	}
+
+	a.Pos = ""
+	want = "TestQueue/drain: panic: 100% lost\nitem 3 (seed=7)\n\treplay: WEFT_SEED=7 go test -tags=detsched -run '^TestQueue$' ."
+	if got := a.Format(AnnotatePlain); got != want {
+		t.Errorf("plain annotation without position:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+// TestReproduction verifies the instructions for rerunning a failing
+// schedule, from its seed, its trace or its choices.
+func TestReproduction(t *testing.T) {
+	tests := []struct {
+		name      string
+		sched     schedule
+		traceFile string
+		want      string
+	}{
+		{"seed", schedule{seed: 7}, "", "reproduce with:\n" +
+			"\tWEFT_SEED=7 go test -tags=detsched -run '^TestQueue$/^drain$' .\n" +
+			"or in the test with:\n" +
+			"\twefttest.Replay(t, 7, build)"},
+		{"trace", schedule{seed: 7, choices: []int{1}}, "traces/q.wtrace", "reproduce with:\n" +
+			"\tWEFT_TRACE=traces/q.wtrace go test -tags=detsched -run '^TestQueue$/^drain$' .\n" +
+			"or in the test with:\n" +
+			"\twefttest.ReplayChoices(t, []int{1, 0, 2}, build)"},
+		{"choices", schedule{seed: 7, choices: []int{1}}, "", "reproduce in the test with:\n" +
+			"\twefttest.ReplayChoices(t, []int{1, 0, 2}, build)"},
+	}
+	for _, tt := range tests {
+		if got := reproduction("TestQueue/drain", tt.sched, []int{1, 0, 2}, tt.traceFile); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package wefttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventPrefix starts each line wefttest writes to standard output for an
+// Event. Under go test -json, test2json passes such lines through as output
+// of the seed's subtest, so CI systems can pick them out of the Output field
+// to show per-seed status as it happens.
+const EventPrefix = "=== WEFT "
+
+// Actions reported by an Event.
+const (
+	ActionRun  = "run"  // The schedule started.
+	ActionPass = "pass" // The schedule passed.
+	ActionFail = "fail" // The schedule failed.
+)
+
+// Event reports the progress of one schedule. wefttest writes events when
+// EnvEvents is set. The field names follow those of test2json.
+type Event struct {
+	Time   time.Time
+	Action string
+
+	// Test is the name of the subtest running the schedule.
+	Test string
+
+	Seed uint64
+
+	// Run counts the schedules of the Explore call so far, from 1, out
+	// of Runs.
+	Run  int
+	Runs int
+
+	// Elapsed is the duration of the schedule in seconds, on pass or
+	// fail.
+	Elapsed float64 `json:",omitempty"`
+
+	// Failure describes a failure, and Trace names its trace file when
+	// EnvTraceDir is set. Location is the failure's source position, as
+	// "file:line", when it is known.
+	Failure  string `json:",omitempty"`
+	Location string `json:",omitempty"`
+	Trace    string `json:",omitempty"`
+
+	// Repeat is set, on pass or fail, if the schedule was equivalent to
+	// one the Explore call ran before, differing only in the order of
+	// independent events.
+	Repeat bool `json:",omitempty"`
+}
+
+// ParseEvent parses a line of test output, or the Output field of a
+// test2json event, written for an Event. It reports false for other lines.
+func ParseEvent(line string) (Event, bool) {
+	var ev Event
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), strings.TrimSpace(EventPrefix))
+	if !ok || json.Unmarshal([]byte(rest), &ev) != nil {
+		return Event{}, false
+	}
+	return ev, true
+}
+
+// eventsMu keeps the lines of parallel tests whole.
+var eventsMu sync.Mutex
+
+// emit writes ev to standard output if EnvEvents is set.
+func emit(ev Event) {
+	if os.Getenv(EnvEvents) == "" {
+		return
+	}
+	ev.Time = time.Now()
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	fmt.Fprintf(os.Stdout, "%s%s\n", EventPrefix, data)
+}
@@ -3,7 +3,10 @@ package wefttest
 import (
 	"fmt"
 	"math/rand/v2"
+	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mziter/weft"
 )
@@ -12,56 +15,46 @@ import (
 type BuildFunc func(*weft.Scheduler)
 
 // Explore runs the build function with multiple different schedules.
+//
+// The WEFT_RUNS environment variable overrides runs. WEFT_SEED or
+// WEFT_TRACE restrict exploration to a single seed or recorded trace.
+// WEFT_EVENTS reports the progress of each schedule as an Event,
+// WEFT_PARALLEL runs that many schedules at once, WEFT_MEMORY bounds the
+// memory each schedule's trace takes, and WEFT_GUIDED steers exploration
+// toward rarely covered interleavings.
 func Explore(t testing.TB, runs int, build BuildFunc) {
 	t.Helper()
 
 	if !isDeterministicModeAvailable() {
-		t.Skipf(`
-Deterministic concurrency testing not available.
-For comprehensive concurrency testing that can detect race conditions,
-deadlocks, and other subtle bugs, run with:
-
-    go test -tags=detsched
-
-This enables Weft's deterministic scheduler which explores multiple
-execution orders to find bugs that standard tests might miss.`)
+		t.Skipf(skipMessage)
 		return
 	}
 
-	rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	exploreRandom(t, runs, build, nil)
+}
 
-	for i := 0; i < runs; i++ {
-		seed := rng.Uint64()
-		// Type assert to *testing.T for Run method
-		if tt, ok := t.(*testing.T); ok {
-			tt.Run(fmt.Sprintf("seed_%d", seed), func(t *testing.T) {
-				t.Helper()
-				s := weft.NewScheduler(seed)
-
-				// Run the build function
-				defer func() {
-					if r := recover(); r != nil {
-						t.Fatalf("panic with seed %d: %v", seed, r)
-					}
-				}()
-
-				build(s)
-				s.Wait()
-			})
-		} else {
-			// Fallback for non-*testing.T types (like our mock)
-			s := weft.NewScheduler(seed)
-
-			defer func() {
-				if r := recover(); r != nil {
-					t.Fatalf("panic with seed %d: %v", seed, r)
-				}
-			}()
-
-			build(s)
-			s.Wait()
-		}
+// CheckFunc checks the outcome of a schedule once its tasks are done,
+// failing t if it is wrong.
+type CheckFunc func(t testing.TB, s *weft.Scheduler)
+
+// ExploreCheck is like Explore, but runs check after each schedule, and
+// only after those not equivalent to a schedule run before: those that
+// differ from it in more than the order of independent events, which
+// cannot change the outcome. Expensive checks, such as of linearizability,
+// then run once per class of schedules rather than once per run. The test
+// log counts the schedules whose check was skipped.
+//
+// Equivalence is judged from the events the scheduler records, so check
+// only what the schedule's synchronization through weft determines.
+func ExploreCheck(t testing.TB, runs int, build BuildFunc, check CheckFunc) {
+	t.Helper()
+
+	if !isDeterministicModeAvailable() {
+		t.Skipf(skipMessage)
+		return
 	}
+
+	exploreRandom(t, runs, build, check)
 }
 
 // ExploreWithSeeds runs the build function with specific seeds.
@@ -69,46 +62,206 @@ func ExploreWithSeeds(t testing.TB, seeds []uint64, build BuildFunc) {
 	t.Helper()
 
 	if !isDeterministicModeAvailable() {
-		t.Skipf(`
-Deterministic concurrency testing not available.
-For comprehensive concurrency testing that can detect race conditions,
-deadlocks, and other subtle bugs, run with:
+		t.Skipf(skipMessage)
+		return
+	}
 
-    go test -tags=detsched
+	scheds := override(t)
+	if scheds == nil {
+		for _, seed := range seeds {
+			scheds = append(scheds, schedule{seed: seed})
+		}
+	}
+	explore(t, scheds, nil, build, nil)
+}
 
-This enables Weft's deterministic scheduler which explores multiple
-execution orders to find bugs that standard tests might miss.`)
-		return
+// exploreRandom runs build under runs random schedules, unless the
+// environment asks for others, guided by coverage under EnvGuided.
+func exploreRandom(t testing.TB, runs int, build BuildFunc, check CheckFunc) {
+	t.Helper()
+	scheds := override(t)
+	var g *guide
+	if scheds == nil {
+		scheds = randomSchedules(runsFromEnv(t, runs))
+		if os.Getenv(EnvGuided) != "" {
+			g = newGuide()
+		}
 	}
+	explore(t, scheds, g, build, check)
+}
 
-	for _, seed := range seeds {
-		// Type assert to *testing.T for Run method
-		if tt, ok := t.(*testing.T); ok {
-			tt.Run(fmt.Sprintf("seed_%d", seed), func(t *testing.T) {
-				t.Helper()
-				s := weft.NewScheduler(seed)
-
-				defer func() {
-					if r := recover(); r != nil {
-						t.Fatalf("panic with seed %d: %v", seed, r)
-					}
-				}()
-
-				build(s)
-				s.Wait()
-			})
-		} else {
-			// Fallback for non-*testing.T types (like our mock)
-			s := weft.NewScheduler(seed)
-
-			defer func() {
-				if r := recover(); r != nil {
-					t.Fatalf("panic with seed %d: %v", seed, r)
-				}
-			}()
-
-			build(s)
-			s.Wait()
+// randomSchedules returns runs schedules with random seeds.
+func randomSchedules(runs int) []schedule {
+	rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	scheds := make([]schedule, runs)
+	for i := range scheds {
+		scheds[i].seed = rng.Uint64()
+	}
+	return scheds
+}
+
+// explore runs build under each of scheds, on EnvParallel workers at once,
+// and writes the coverage gathered. If g is not nil, it picks as many
+// schedules in their place, each from the coverage of those run before.
+func explore(t testing.TB, scheds []schedule, g *guide, build BuildFunc, check CheckFunc) {
+	t.Helper()
+	seen := new(scheduleTable)
+	next := make(chan schedule)
+	go func() {
+		for i, sched := range scheds {
+			if g != nil {
+				sched = g.next()
+			}
+			sched.run, sched.runs = i+1, len(scheds)
+			next <- sched
+		}
+		close(next)
+	}()
+	work := func() {
+		w := &worker{seen: seen, check: check, guide: g}
+		for sched := range next {
+			runSchedule(t, w, sched, build)
 		}
 	}
-}
\ No newline at end of file
+	// The test's own goroutine is one of the workers, so that without
+	// EnvParallel a failure can end the test from it as usual.
+	var wg sync.WaitGroup
+	for i := 1; i < min(parallelFromEnv(t), len(scheds)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work()
+		}()
+	}
+	work()
+	wg.Wait()
+	if n := seen.repeats.Load(); n > 0 {
+		t.Logf("wefttest: %d of %d schedules were equivalent to one already explored", n, seen.runs.Load())
+	}
+	writeCoverage(t)
+}
+
+// worker runs schedules of an exploration one at a time. It resets the
+// scheduler of a run that passed for the next, rather than creating one per
+// schedule; a failed run may have left tasks behind, so its scheduler is
+// not reused. seen and guide are shared by the workers of the exploration.
+type worker struct {
+	idle  *weft.Scheduler
+	seen  *scheduleTable
+	check CheckFunc
+	guide *guide
+}
+
+// repeat adds the class of the schedule s ran to the table and reports
+// whether an equivalent schedule had run before.
+func (w *worker) repeat(s *weft.Scheduler, streamed bool) bool {
+	class, ok := scheduleClass(s, streamed)
+	return ok && !w.seen.add(class)
+}
+
+// scheduler returns a scheduler ready to run sched.
+func (w *worker) scheduler(sched schedule) *weft.Scheduler {
+	s := w.idle
+	if s == nil {
+		return weft.NewReplayScheduler(sched.seed, sched.choices)
+	}
+	w.idle = nil
+	s.ResetReplay(sched.seed, sched.choices)
+	return s
+}
+
+// runSchedule runs build under one schedule, as a subtest when t supports
+// them.
+func runSchedule(t testing.TB, w *worker, sched schedule, build BuildFunc) {
+	t.Helper()
+	test := t.Name()
+	// Type assert to *testing.T for Run method
+	if tt, ok := t.(*testing.T); ok {
+		tt.Run(fmt.Sprintf("seed_%d", sched.seed), func(t *testing.T) {
+			t.Helper()
+			runOnce(t, tt, test, w, sched, build)
+		})
+	} else {
+		// Fallback for non-*testing.T types (like our mock)
+		runOnce(t, t, test, w, sched, build)
+	}
+}
+
+// runOnce runs build under one schedule and records the trace if it fails,
+// or in any case if EnvTraceAll is set. The schedule has failed if t has,
+// if parent, the test calling Explore, which build functions often assert
+// with, failed while it ran, or if the scheduler's Result reports a
+// failure.
+func runOnce(t, parent testing.TB, test string, w *worker, sched schedule, build BuildFunc) {
+	t.Helper()
+	parentFailed := parent.Failed()
+	s := w.scheduler(sched)
+	defer s.Bind()()
+	setInteractive(t, s)
+	stream := startStream(t, test, sched, s)
+	if stream == nil {
+		s.SetEventMemory(memoryFromEnv(t))
+	}
+	ev := Event{Action: ActionRun, Test: t.Name(), Seed: sched.seed, Run: sched.run, Runs: sched.runs}
+	emit(ev)
+	start := time.Now()
+	classified := false
+
+	defer func() {
+		if !classified {
+			ev.Repeat = w.repeat(s, stream != nil)
+		}
+		addCoverage(s)
+		if w.guide != nil {
+			w.guide.add(s.Events(), s.Choices())
+		}
+		ev.Elapsed = time.Since(start).Seconds()
+		r := recover()
+		switch {
+		case r != nil:
+			ev.Failure = fmt.Sprint("panic: ", r)
+			ev.Location = panicLocation()
+			ev.Trace = saveTrace(t, test, sched, s, stream, ev.Failure, ev.Location)
+			logLive(t, s)
+			ev.Action = ActionFail
+			emit(ev)
+			writeLaunch(t, test, sched, ev.Trace)
+			annotate(test, sched, ev)
+			t.Fatalf("panic with seed %d: %v\n%s", sched.seed, r, reproduction(test, sched, s.Choices(), ev.Trace))
+		case t.Failed() || !parentFailed && parent.Failed():
+			ev.Failure = "test failed"
+			if err := s.Result(); err != nil {
+				ev.Failure = err.Error()
+			}
+			ev.Trace = saveTrace(t, test, sched, s, stream, ev.Failure, "")
+			logLive(t, s)
+			ev.Action = ActionFail
+			emit(ev)
+			writeLaunch(t, test, sched, ev.Trace)
+			annotate(test, sched, ev)
+			failed := t
+			if !t.Failed() {
+				failed = parent
+			}
+			failed.Logf("failed with seed %d\n%s", sched.seed, reproduction(test, sched, s.Choices(), ev.Trace))
+		default:
+			if os.Getenv(EnvTraceAll) != "" {
+				ev.Trace = saveTrace(t, test, sched, s, stream, "", "")
+			} else {
+				stream.discard()
+			}
+			ev.Action = ActionPass
+			emit(ev)
+			w.idle = s
+		}
+	}()
+
+	build(s)
+	if err := s.Wait(); err != nil {
+		t.Error(err)
+	}
+	ev.Repeat, classified = w.repeat(s, stream != nil), true
+	if w.check != nil && !ev.Repeat {
+		w.check(t, s)
+	}
+}
@@ -1,10 +1,13 @@
 package wefttest
 
 import (
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/mziter/weft"
+	"github.com/mziter/weft/trace"
 )
 
 // TestExploreSkipsWithoutDetschedTag verifies that Explore skips gracefully
@@ -15,13 +18,14 @@ func TestExploreSkipsWithoutDetschedTag(t *testing.T) {
 
 	// Try to run Explore
 	Explore(mockT, 10, func(s *weft.Scheduler) {
-		// This should not be executed if detection works
-		t.Error("Build function should not run without detsched tag")
+		if !isDeterministicModeAvailable() {
+			t.Error("Build function should not run without detsched tag")
+		}
 	})
 
 	// Check behavior based on build tags
 	if isDeterministicModeAvailable() {
-		// With -tags=detsched, test should run (or panic since scheduler isn't implemented)
+		// With -tags=detsched, the build function runs
 		if mockT.skipped {
 			t.Error("Explore should not skip when deterministic mode is available")
 		}
@@ -49,7 +53,9 @@ func TestExploreWithSeedsSkipsWithoutDetschedTag(t *testing.T) {
 
 	seeds := []uint64{123, 456, 789}
 	ExploreWithSeeds(mockT, seeds, func(s *weft.Scheduler) {
-		t.Error("Build function should not run without detsched tag")
+		if !isDeterministicModeAvailable() {
+			t.Error("Build function should not run without detsched tag")
+		}
 	})
 
 	if isDeterministicModeAvailable() {
@@ -121,4 +127,152 @@ func (m *mockTestingT) Log(args ...interface{}) {
 
 func (m *mockTestingT) Logf(format string, args ...interface{}) {
 	m.logs = append(m.logs, format)
-}
\ No newline at end of file
+}
+
+// TestExploreReuse verifies that Explore reuses one scheduler across
+// passing runs, and that a reused scheduler decides as a new one would.
+func TestExploreReuse(t *testing.T) {
+	if !isDeterministicModeAvailable() {
+		t.Skip(skipMessage)
+	}
+	var scheds []*weft.Scheduler
+	var draws []int
+	ExploreWithSeeds(t, []uint64{5, 5}, func(s *weft.Scheduler) {
+		scheds = append(scheds, s)
+		draws = append(draws, s.Choose(1000))
+	})
+	if len(scheds) != 2 || scheds[0] != scheds[1] {
+		t.Error("ExploreWithSeeds created a scheduler for each run")
+	}
+	if len(draws) != 2 || draws[0] != draws[1] {
+		t.Errorf("the same seed drew %v, want the same choice twice", draws)
+	}
+}
+
+// TestExploreParallel verifies that EnvParallel runs every schedule, on
+// workers that each have a scheduler of their own.
+func TestExploreParallel(t *testing.T) {
+	if !isDeterministicModeAvailable() {
+		t.Skip(skipMessage)
+	}
+	t.Setenv(EnvParallel, "3")
+	var mu sync.Mutex
+	draws := make(map[int]int)
+	running := make(map[*weft.Scheduler]bool)
+	ExploreWithSeeds(t, []uint64{1, 2, 3, 4, 5, 6}, func(s *weft.Scheduler) {
+		mu.Lock()
+		if running[s] {
+			t.Error("two runs at once shared a scheduler")
+		}
+		running[s] = true
+		mu.Unlock()
+		draw := s.Choose(1000)
+		mu.Lock()
+		draws[draw]++
+		delete(running, s)
+		mu.Unlock()
+	})
+	n := 0
+	for _, c := range draws {
+		n += c
+	}
+	if n != 6 {
+		t.Errorf("ran %d schedules, want 6", n)
+	}
+}
+
+// TestScheduleTable verifies that the table reports classes it has seen
+// as repeats, and only those.
+func TestScheduleTable(t *testing.T) {
+	var st scheduleTable
+	for _, h := range []uint64{0, 1, seenShards, 1 << 40} {
+		if !st.add(h) {
+			t.Errorf("class %d reported as a repeat", h)
+		}
+	}
+	if st.add(seenShards) {
+		t.Error("class added twice reported as new")
+	}
+	if got := st.repeats.Load(); got != 1 {
+		t.Errorf("repeats = %d, want 1", got)
+	}
+}
+
+// TestExploreCheck verifies that the check runs once for schedules that
+// are all equivalent, and for each of schedules that are not.
+func TestExploreCheck(t *testing.T) {
+	if !isDeterministicModeAvailable() {
+		t.Skip(skipMessage)
+	}
+	checks := 0
+	ExploreCheck(t, 5, func(s *weft.Scheduler) {
+		s.Go(func(weft.Context) {})
+	}, func(t testing.TB, s *weft.Scheduler) {
+		checks++
+	})
+	if checks != 1 {
+		t.Errorf("check ran %d times for equivalent schedules, want 1", checks)
+	}
+
+	checks = 0
+	t.Setenv(EnvRuns, "20")
+	ExploreCheck(t, 1, func(s *weft.Scheduler) {
+		s.Choose(1 << 30)
+	}, func(t testing.TB, s *weft.Scheduler) {
+		checks++
+	})
+	if checks != 20 {
+		t.Errorf("check ran %d times for schedules deciding differently, want 20", checks)
+	}
+}
+
+// TestGuide verifies that the guide derives schedules from a schedule that
+// covered a new point, by a prefix of its decisions, and keeps only such
+// schedules in its corpus.
+func TestGuide(t *testing.T) {
+	g := newGuide()
+	if sched := g.next(); sched.choices != nil {
+		t.Errorf("next() with an empty corpus replays %v", sched.choices)
+	}
+	run := []trace.Event{
+		{Task: 1, Kind: trace.Send, Object: "chan 1"},
+		{Task: 2, Kind: trace.Recv, Object: "chan 1"},
+	}
+	choices := []int{5, 6, 7}
+	g.add(run, choices)
+	g.add(run, []int{1, 2})
+	if len(g.corpus) != 1 {
+		t.Fatalf("corpus holds %d schedules, want 1", len(g.corpus))
+	}
+	derived := 0
+	for i := 0; i < 100; i++ {
+		sched := g.next()
+		if sched.choices == nil {
+			continue
+		}
+		derived++
+		if !slices.Equal(sched.choices, choices[:len(sched.choices)]) {
+			t.Fatalf("next() replays %v, not a prefix of %v", sched.choices, choices)
+		}
+	}
+	if derived < 50 {
+		t.Errorf("%d of 100 schedules derived from the corpus, want about 75", derived)
+	}
+}
+
+// TestExploreGuided verifies that guided exploration runs as many
+// schedules as asked.
+func TestExploreGuided(t *testing.T) {
+	if !isDeterministicModeAvailable() {
+		t.Skip(skipMessage)
+	}
+	t.Setenv(EnvGuided, "1")
+	runs := 0
+	Explore(t, 20, func(s *weft.Scheduler) {
+		s.Choose(10)
+		runs++
+	})
+	if runs != 20 {
+		t.Errorf("ran %d schedules, want 20", runs)
+	}
+}
@@ -0,0 +1,97 @@
+package wefttest
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/mziter/weft/trace"
+)
+
+// guide steers exploration under EnvGuided toward rarely covered
+// interleavings. It keeps as its corpus the schedules that covered a point
+// no schedule had covered before, and derives most new schedules from
+// them: it replays a prefix of a corpus schedule's decisions, picked with
+// odds that grow as the points it covers stay rare, and makes the rest from
+// a fresh seed. The schedules derived from a corpus schedule share its
+// early decisions, which are what reached its rare points.
+type guide struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	// counts counts the schedules that covered each point.
+	counts map[string]int
+	corpus []guided
+}
+
+// guided is a schedule of the corpus, with the points it covered.
+type guided struct {
+	choices []int
+	points  []string
+}
+
+// newGuide returns a guide with an empty corpus.
+func newGuide() *guide {
+	return &guide{
+		rng:    rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		counts: make(map[string]int),
+	}
+}
+
+// next returns the schedule to run next: a fresh seed while the corpus is
+// empty, and otherwise, three times in four, one derived from the corpus.
+func (g *guide) next() schedule {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sched := schedule{seed: g.rng.Uint64()}
+	if len(g.corpus) == 0 || g.rng.IntN(4) == 0 {
+		return sched
+	}
+	parent := g.pick()
+	sched.choices = parent.choices[:g.rng.IntN(len(parent.choices)+1)]
+	return sched
+}
+
+// pick returns a schedule of the corpus, each with odds proportional to the
+// rarity of its points: the sum, over them, of one over the schedules that
+// covered each. The caller must hold g.mu.
+func (g *guide) pick() guided {
+	weights := make([]float64, len(g.corpus))
+	total := 0.0
+	for i, e := range g.corpus {
+		for _, p := range e.points {
+			weights[i] += 1 / float64(g.counts[p])
+		}
+		total += weights[i]
+	}
+	r := g.rng.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return g.corpus[i]
+		}
+		r -= w
+	}
+	return g.corpus[len(g.corpus)-1]
+}
+
+// add counts the points covered by a schedule that recorded events and made
+// choices, adding it to the corpus if it covered one first.
+func (g *guide) add(events []trace.Event, choices []int) {
+	c := trace.NewCoverage()
+	c.Add(&trace.Trace{Events: events})
+	points := make([]string, 0, len(c.Points))
+	for p := range c.Points {
+		points = append(points, p)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	novel := false
+	for _, p := range points {
+		if g.counts[p] == 0 {
+			novel = true
+		}
+		g.counts[p]++
+	}
+	if novel && len(choices) > 0 {
+		g.corpus = append(g.corpus, guided{choices: choices, points: points})
+	}
+}
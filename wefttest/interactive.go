@@ -0,0 +1,86 @@
+package wefttest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mziter/weft"
+)
+
+// session is the connection that interactive schedules are driven over. It
+// is opened by the first schedule and shared by the rest of the process.
+var session struct {
+	once sync.Once
+	r    io.Reader
+	w    io.Writer
+	err  error
+}
+
+// setInteractive hands the decisions of s to a person when EnvInteractive
+// is set: "stdin" reads commands from standard input, and "tcp:ADDR" waits
+// for a connection on ADDR, such as one made with nc.
+func setInteractive(t testing.TB, s *weft.Scheduler) {
+	mode := os.Getenv(EnvInteractive)
+	if mode == "" {
+		return
+	}
+	session.once.Do(func() {
+		switch {
+		case mode == "stdin":
+			session.r, session.w = lineReader{os.Stdin}, os.Stdout
+		case strings.HasPrefix(mode, "tcp:"):
+			var l net.Listener
+			l, session.err = net.Listen("tcp", strings.TrimPrefix(mode, "tcp:"))
+			if session.err != nil {
+				return
+			}
+			defer l.Close()
+			fmt.Fprintf(os.Stderr, "wefttest: waiting for a connection on %s\n", l.Addr())
+			var conn net.Conn
+			conn, session.err = l.Accept()
+			session.r, session.w = lineReader{conn}, conn
+		default:
+			session.err = fmt.Errorf("invalid %s %q; want stdin or tcp:ADDR", EnvInteractive, mode)
+		}
+	})
+	if session.err != nil {
+		t.Fatalf("wefttest: %v", session.err)
+	}
+	fmt.Fprintf(session.w, "%s\n", t.Name())
+	s.SetInteractive(session.r, session.w)
+	if name := os.Getenv(EnvRewindFile); name != "" {
+		s.SetInteractiveRewind(func(decisions int) {
+			if err := os.WriteFile(name, []byte(strconv.Itoa(decisions)), 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "wefttest: %s: %v\n", EnvRewindFile, err)
+			}
+			os.Exit(0)
+		})
+	}
+}
+
+// lineReader reads at most one line per call, so that each schedule's
+// scanner leaves the input after the line it consumed for the next one.
+type lineReader struct {
+	r io.Reader
+}
+
+func (lr lineReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		m, err := lr.r.Read(p[n : n+1])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if m == 1 && p[n-1] == '\n' {
+			break
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,116 @@
+package wefttest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// launchMu serializes updates to the launch file within a test process.
+var launchMu sync.Mutex
+
+// launchConfig returns a VS Code launch configuration, for the Go
+// extension's dlv integration, that debugs test in the package in dir under
+// the given schedule. A recorded trace replays the schedule exactly;
+// otherwise the seed does.
+func launchConfig(dir, test string, sched schedule, traceFile string) map[string]interface{} {
+	env := map[string]string{EnvSeed: strconv.FormatUint(sched.seed, 10)}
+	if traceFile != "" {
+		abs, err := filepath.Abs(traceFile)
+		if err == nil {
+			traceFile = abs
+		}
+		env = map[string]string{EnvTrace: traceFile}
+	}
+	return map[string]interface{}{
+		"name":       fmt.Sprintf("weft: %s seed %d", test, sched.seed),
+		"type":       "go",
+		"request":    "launch",
+		"mode":       "test",
+		"program":    dir,
+		"buildFlags": "-tags=detsched",
+		"args":       []string{"-test.run", runPattern(test), "-test.count=1", "-test.v"},
+		"env":        env,
+	}
+}
+
+// runPattern returns a -test.run pattern matching exactly test, including
+// subtests named with slashes.
+func runPattern(test string) string {
+	parts := strings.Split(test, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return strings.Join(parts, "/")
+}
+
+// writeLaunch adds a launch configuration for a failing schedule to the
+// launch.json file named by EnvLaunch, if set, replacing any configuration
+// of the same name, and logs the equivalent dlv command. A launch file that
+// cannot be parsed, such as one with comments, is left alone.
+func writeLaunch(t testing.TB, test string, sched schedule, traceFile string) {
+	name := os.Getenv(EnvLaunch)
+	if name == "" {
+		return
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Logf("wefttest: writing launch configuration: %v", err)
+		return
+	}
+	config := launchConfig(dir, test, sched, traceFile)
+
+	launchMu.Lock()
+	defer launchMu.Unlock()
+	file := map[string]interface{}{"version": "0.2.0"}
+	data, err := os.ReadFile(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		t.Logf("wefttest: writing launch configuration: %v", err)
+		return
+	default:
+		if err := json.Unmarshal(data, &file); err != nil {
+			t.Logf("wefttest: not updating %s: %v", name, err)
+			return
+		}
+	}
+	configs, _ := file["configurations"].([]interface{})
+	replaced := false
+	for i, c := range configs {
+		if m, ok := c.(map[string]interface{}); ok && m["name"] == config["name"] {
+			configs[i], replaced = config, true
+		}
+	}
+	if !replaced {
+		configs = append(configs, config)
+	}
+	file["configurations"] = configs
+
+	data, err = json.MarshalIndent(file, "", "    ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(name), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(name, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		t.Logf("wefttest: writing launch configuration: %v", err)
+		return
+	}
+
+	var env []string
+	for k, v := range config["env"].(map[string]string) {
+		env = append(env, k+"="+v)
+	}
+	t.Logf("debug with %q in %s, or: %s dlv test %s --build-flags=-tags=detsched -- -test.run '%s'",
+		config["name"], name, strings.Join(env, " "), dir, runPattern(test))
+}
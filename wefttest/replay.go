@@ -11,36 +11,43 @@ func Replay(t testing.TB, seed uint64, build BuildFunc) {
 	t.Helper()
 
 	if !isDeterministicModeAvailable() {
-		t.Skipf(`
-Deterministic concurrency testing not available.
-For comprehensive concurrency testing that can detect race conditions,
-deadlocks, and other subtle bugs, run with:
-
-    go test -tags=detsched
-
-This enables Weft's deterministic scheduler which explores multiple
-execution orders to find bugs that standard tests might miss.`)
+		t.Skipf(skipMessage)
 		return
 	}
 
 	s := weft.NewScheduler(seed)
-	
+
 	defer func() {
 		if r := recover(); r != nil {
 			t.Fatalf("panic during replay with seed %d: %v", seed, r)
 		}
 	}()
-	
+
+	defer s.Bind()()
 	build(s)
 	s.Wait()
 }
 
 // ReplayChoices runs the build function with an explicit choice sequence.
-// This is useful for replaying a minimal trace after shrinking.
+// This is useful for replaying a minimal trace after shrinking. Decisions
+// beyond the end of choices are made as if by seed 0.
 func ReplayChoices(t *testing.T, choices []int, build BuildFunc) {
 	t.Helper()
-	
-	// TODO: Implement choice-based replay when scheduler supports it
-	// For now, this is a placeholder
-	t.Skip("ReplayChoices not yet implemented")
-}
\ No newline at end of file
+
+	if !isDeterministicModeAvailable() {
+		t.Skipf(skipMessage)
+		return
+	}
+
+	s := weft.NewReplayScheduler(0, choices)
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("panic during replay of %d choices: %v", len(choices), r)
+		}
+	}()
+
+	defer s.Bind()()
+	build(s)
+	s.Wait()
+}
@@ -14,12 +14,14 @@ func TestReplaySkipsWithoutDetschedTag(t *testing.T) {
 
 	// Try to run Replay with a specific seed
 	Replay(mockT, 12345, func(s *weft.Scheduler) {
-		t.Error("Build function should not run without detsched tag")
+		if !isDeterministicModeAvailable() {
+			t.Error("Build function should not run without detsched tag")
+		}
 	})
 
 	// Check behavior based on build tags
 	if isDeterministicModeAvailable() {
-		// With -tags=detsched, test should run (or panic since scheduler isn't implemented)
+		// With -tags=detsched, the build function runs
 		if mockT.skipped {
 			t.Error("Replay should not skip when deterministic mode is available")
 		}
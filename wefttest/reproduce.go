@@ -0,0 +1,41 @@
+package wefttest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// reproduction returns instructions for rerunning alone a failing schedule
+// of test that made choices: a go test command and the wefttest call that
+// does the same inside a test, ready to copy. A schedule drawn wholly from
+// its seed is rerun from the seed; one that replayed decisions first, as
+// guided schedules do, from its trace file if it was saved and otherwise
+// from every choice it made.
+func reproduction(test string, sched schedule, choices []int, traceFile string) string {
+	run := fmt.Sprintf("go test -tags=detsched -run '%s' .", runPattern(test))
+	var cmd, code string
+	switch {
+	case len(sched.choices) == 0:
+		cmd = fmt.Sprintf("%s=%d %s", EnvSeed, sched.seed, run)
+		code = fmt.Sprintf("wefttest.Replay(t, %d, build)", sched.seed)
+	case traceFile != "":
+		cmd = fmt.Sprintf("%s=%s %s", EnvTrace, traceFile, run)
+	}
+	if code == "" {
+		code = fmt.Sprintf("wefttest.ReplayChoices(t, %s, build)", formatChoices(choices))
+	}
+	if cmd == "" {
+		return "reproduce in the test with:\n\t" + code
+	}
+	return "reproduce with:\n\t" + cmd + "\nor in the test with:\n\t" + code
+}
+
+// formatChoices returns choices as a Go []int literal.
+func formatChoices(choices []int) string {
+	elems := make([]string, len(choices))
+	for i, c := range choices {
+		elems[i] = strconv.Itoa(c)
+	}
+	return "[]int{" + strings.Join(elems, ", ") + "}"
+}
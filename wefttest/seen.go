@@ -0,0 +1,64 @@
+package wefttest
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/trace"
+)
+
+// seenShards is the number of shards of a scheduleTable, enough that the
+// workers of a parallel exploration rarely contend for one.
+const seenShards = 64
+
+// scheduleTable is the set of the equivalence classes of the schedules an
+// exploration has run, shared by its workers so that each knows what the
+// others have covered. It is sharded by hash, each shard behind its own
+// lock.
+type scheduleTable struct {
+	shards [seenShards]struct {
+		sync.Mutex
+		m map[uint64]struct{}
+	}
+
+	// runs and repeats count the schedules added and those whose class
+	// was already in the table.
+	runs, repeats atomic.Int64
+}
+
+// add adds the schedule whose class is h and reports whether it was new.
+func (st *scheduleTable) add(h uint64) bool {
+	st.runs.Add(1)
+	shard := &st.shards[h%seenShards]
+	shard.Lock()
+	defer shard.Unlock()
+	if _, ok := shard.m[h]; ok {
+		st.repeats.Add(1)
+		return false
+	}
+	if shard.m == nil {
+		shard.m = make(map[uint64]struct{})
+	}
+	shard.m[h] = struct{}{}
+	return true
+}
+
+// scheduleClass hashes the equivalence class of the schedule s ran, as
+// trace.Trace.Class does, together with its decisions, as a decision can
+// change what a task computes without an event showing it. It reports false
+// if s kept only its latest events, from which no class can be told.
+func scheduleClass(s *weft.Scheduler, streamed bool) (uint64, bool) {
+	if streamed {
+		return 0, false
+	}
+	h := fnv.New64a()
+	var buf [binary.MaxVarintLen64]byte
+	h.Write(buf[:binary.PutUvarint(buf[:], (&trace.Trace{Events: s.Events()}).Class())])
+	for _, c := range s.Choices() {
+		h.Write(buf[:binary.PutVarint(buf[:], int64(c))])
+	}
+	return h.Sum64(), true
+}
@@ -0,0 +1,77 @@
+package wefttest
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/mziter/weft"
+	"github.com/mziter/weft/trace"
+)
+
+// traceStream is a trace being streamed to EnvTraceDir while its schedule
+// runs. It is written under a temporary name, so that tools collecting
+// traces never see it unfinished.
+type traceStream struct {
+	name string
+	f    *os.File
+	w    *trace.Writer
+}
+
+// startStream starts streaming the trace of the schedule run by s if
+// EnvTraceStream and EnvTraceDir are set, and returns nil otherwise.
+func startStream(t testing.TB, test string, sched schedule, s *weft.Scheduler) *traceStream {
+	t.Helper()
+	dir := os.Getenv(EnvTraceDir)
+	if dir == "" || os.Getenv(EnvTraceStream) == "" {
+		return nil
+	}
+	sample := 0
+	if v := os.Getenv(EnvTraceSample); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			t.Fatalf("wefttest: invalid %s %q", EnvTraceSample, v)
+		}
+		sample = n
+	}
+	name := traceName(dir, test, sched.seed)
+	f, err := os.Create(name + ".partial")
+	if err != nil {
+		t.Logf("wefttest: streaming trace: %v", err)
+		return nil
+	}
+	ts := &traceStream{
+		name: name,
+		f:    f,
+		w:    trace.NewWriter(f, &trace.Trace{Test: test, Seed: sched.seed, Sampled: sample}),
+	}
+	s.StreamEvents(ts.w)
+	return ts
+}
+
+// finish completes the trace with the choices of s, failure and location
+// and moves it to its final name, which it returns.
+func (ts *traceStream) finish(t testing.TB, s *weft.Scheduler, failure, location string) string {
+	err := ts.w.Close(&trace.Trace{Choices: s.Choices(), Failure: failure, Location: location})
+	if cerr := ts.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(ts.f.Name(), ts.name)
+	}
+	if err != nil {
+		t.Logf("wefttest: writing trace: %v", err)
+		os.Remove(ts.f.Name())
+		return ""
+	}
+	return ts.name
+}
+
+// discard abandons the trace of a passing schedule.
+func (ts *traceStream) discard() {
+	if ts == nil {
+		return
+	}
+	ts.f.Close()
+	os.Remove(ts.f.Name())
+}
@@ -0,0 +1,39 @@
+//go:build go1.25
+
+package wefttest
+
+import (
+	"testing"
+	"testing/synctest"
+
+	"github.com/mziter/weft"
+)
+
+// Synctest runs build inside a testing/synctest bubble with a scheduler for
+// seed, so that tests migrating between the two tools can use both at once.
+//
+// Within the bubble the scheduler's Sleep and After run on the bubble's
+// fake clock at full duration, agreeing with time.Now, and tasks blocked on
+// weft channels, mutexes and condition variables count as durably blocked,
+// so synctest.Wait returns once every task, weft's or not, is stuck:
+//
+//	wefttest.Synctest(t, 1, func(t *testing.T, s *weft.Scheduler) {
+//		s.Go(func(weft.Context) { s.Sleep(time.Second); close(done) })
+//		synctest.Wait()
+//		...
+//	})
+//
+// Synctest returns when build and every goroutine it started have
+// returned. Unlike Explore it runs without the detsched tag too, where weft
+// is the standard library and a task blocked on a Mutex is, as synctest
+// documents for sync.Mutex, not durably blocked.
+func Synctest(t *testing.T, seed uint64, build func(t *testing.T, s *weft.Scheduler)) {
+	t.Helper()
+	synctest.Test(t, func(t *testing.T) {
+		s := weft.NewScheduler(seed)
+		s.SetSynctest(true)
+		defer s.Bind()()
+		build(t, s)
+		s.Wait()
+	})
+}
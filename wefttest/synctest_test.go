@@ -0,0 +1,51 @@
+//go:build go1.25
+
+package wefttest
+
+import (
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/mziter/weft"
+)
+
+// TestSynctestClock verifies that the scheduler's sleeps inside a bubble
+// advance the bubble's clock by their full duration.
+func TestSynctestClock(t *testing.T) {
+	Synctest(t, 1, func(t *testing.T, s *weft.Scheduler) {
+		start := time.Now()
+		s.Sleep(time.Hour)
+		s.After(time.Minute).Recv()
+		if got := time.Since(start); got != time.Hour+time.Minute {
+			t.Errorf("time passed = %v, want %v", got, time.Hour+time.Minute)
+		}
+	})
+}
+
+// TestSynctestWait verifies that a task blocked on a weft mutex counts as
+// durably blocked.
+func TestSynctestWait(t *testing.T) {
+	if !isDeterministicModeAvailable() {
+		t.Skip("a sync.Mutex is not durably blocking")
+	}
+	Synctest(t, 1, func(t *testing.T, s *weft.Scheduler) {
+		var mu weft.Mutex
+		mu.Lock()
+		locked := false
+		s.Go(func(weft.Context) {
+			mu.Lock()
+			locked = true
+			mu.Unlock()
+		})
+		synctest.Wait()
+		if locked {
+			t.Error("task locked a held mutex")
+		}
+		mu.Unlock()
+		synctest.Wait()
+		if !locked {
+			t.Error("task did not lock the released mutex")
+		}
+	})
+}